@@ -0,0 +1,51 @@
+#!/bin/bash
+#
+# Regenerates internal/toolinstall/checksums.txt, the SHA-256 checksums
+# that --auto-install verifies downloads against, from the checksums
+# published with each release.
+#
+# Run it after changing the pinned version of a tool in
+# internal/toolinstall/toolinstall.go, and keep the versions here in sync.
+
+set -euo pipefail
+
+REPO_ROOT=$(dirname $(dirname $(dirname "$0")))
+cd "${REPO_ROOT}"
+
+KIND_VERSION=v0.20.0
+TRIVY_VERSION=v0.45.0
+
+# Prints a line for each asset, with its checksum from the sums file at the URL,
+# named TOOL/VERSION/ASSET.
+function sums {
+  local tool=$1 version=$2 url=$3
+  shift 3
+  local sums
+  sums=$(curl --silent --show-error --location --fail --retry 3 "$url")
+  for asset in "$@"; do
+    local sum
+    sum=$(echo "$sums" | awk -v f="$asset" '{n=$2; sub(/^\*/, "", n); sub(/.*\//, "", n); if (n == f) print tolower($1)}')
+    if [[ "$sum" == "" ]]; then
+      echo "No checksum for $asset in $url" >&2
+      exit 1
+    fi
+    echo "$sum  $tool/$version/$asset"
+  done
+}
+
+TMP=$(mktemp)
+trap 'rm -f "$TMP"' EXIT
+
+{
+  echo "# Generated by hack/make-rules/tool-checksums.sh. Don't edit by hand."
+  for asset in kind-darwin-amd64 kind-darwin-arm64 kind-linux-amd64 kind-linux-arm64; do
+    sums kind "$KIND_VERSION" \
+      "https://github.com/kubernetes-sigs/kind/releases/download/$KIND_VERSION/$asset.sha256sum" "$asset"
+  done
+  sums trivy "$TRIVY_VERSION" \
+    "https://github.com/aquasecurity/trivy/releases/download/$TRIVY_VERSION/trivy_${TRIVY_VERSION#v}_checksums.txt" \
+    "trivy_${TRIVY_VERSION#v}_macOS-64bit.tar.gz" "trivy_${TRIVY_VERSION#v}_macOS-ARM64.tar.gz" \
+    "trivy_${TRIVY_VERSION#v}_Linux-64bit.tar.gz" "trivy_${TRIVY_VERSION#v}_Linux-ARM64.tar.gz"
+} > "$TMP"
+
+mv "$TMP" internal/toolinstall/checksums.txt
@@ -0,0 +1,147 @@
+// Package audit appends a JSON line to an audit log for each object that
+// ctlptl creates, updates, or deletes.
+//
+// The log is enabled with --audit-log, CTLPTL_AUDIT_LOG=path, or auditLog in
+// ~/.ctlptl/config.yaml. Each line records when it happened, the operation
+// (apply, create, delete), the kind and name of the object, what happened to
+// it, and who ran ctlptl where.
+//
+// ctlptl only ever appends to the log, and syncs each line to disk before
+// moving on, so that a crash loses at most the line being written.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"time"
+)
+
+// EnvVar is the path of the audit log. Empty disables it.
+const EnvVar = "CTLPTL_AUDIT_LOG"
+
+// Actions that aren't in reporter.Action.
+const (
+	ActionDeleted = "deleted"
+	ActionFailed  = "failed"
+)
+
+// Entry is one line of the audit log.
+type Entry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Operation string    `json:"operation"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Action    string    `json:"action"`
+	Error     string    `json:"error,omitempty"`
+	User      string    `json:"user"`
+	Host      string    `json:"host"`
+}
+
+// Enabled returns true if ctlptl should write an audit log.
+func Enabled() bool {
+	return os.Getenv(EnvVar) != ""
+}
+
+// Record appends an entry to the audit log, if it's enabled.
+//
+// If err isn't nil, the action is recorded as failed.
+func Record(operation, kind, name, action string, err error) error {
+	path := os.Getenv(EnvVar)
+	if path == "" {
+		return nil
+	}
+
+	entry := Entry{
+		Timestamp: time.Now().UTC(),
+		Operation: operation,
+		Kind:      kind,
+		Name:      name,
+		Action:    action,
+		User:      currentUser(),
+		Host:      currentHost(),
+	}
+	if err != nil {
+		entry.Action = ActionFailed
+		entry.Error = err.Error()
+	}
+
+	writeErr := appendEntry(path, entry)
+	if writeErr != nil {
+		return fmt.Errorf("writing audit log %s: %v", path, writeErr)
+	}
+	return nil
+}
+
+// Writes the entry as one line with a single write, so that concurrent
+// ctlptl invocations don't interleave their lines.
+func appendEntry(path string, entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	// If a crash cut off the last line, start a new one, so that
+	// this entry is still valid JSON.
+	partial, err := endsWithPartialLine(f)
+	if err != nil {
+		return err
+	}
+	if partial {
+		line = append([]byte{'\n'}, line...)
+	}
+
+	_, err = f.Write(line)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func endsWithPartialLine(f *os.File) (bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info.Size() == 0 {
+		return false, nil
+	}
+	last := make([]byte, 1)
+	_, err = f.ReadAt(last, info.Size()-1)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return last[0] != '\n', nil
+}
+
+func currentUser() string {
+	u, err := user.Current()
+	if err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+func currentHost() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return host
+}
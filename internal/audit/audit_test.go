@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readEntries(t *testing.T, path string) []Entry {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry Entry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestRecordDisabled(t *testing.T) {
+	t.Setenv(EnvVar, "")
+	assert.False(t, Enabled())
+	assert.NoError(t, Record("apply", "Cluster", "kind-kind", "created", nil))
+}
+
+func TestRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	t.Setenv(EnvVar, path)
+
+	require.NoError(t, Record("apply", "Cluster", "kind-kind", "created", nil))
+	require.NoError(t, Record("delete", "Registry", "ctlptl-registry", ActionDeleted, fmt.Errorf("not running")))
+
+	entries := readEntries(t, path)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "apply", entries[0].Operation)
+	assert.Equal(t, "Cluster", entries[0].Kind)
+	assert.Equal(t, "kind-kind", entries[0].Name)
+	assert.Equal(t, "created", entries[0].Action)
+	assert.Empty(t, entries[0].Error)
+	assert.False(t, entries[0].Timestamp.IsZero())
+
+	assert.Equal(t, ActionFailed, entries[1].Action)
+	assert.Equal(t, "not running", entries[1].Error)
+}
+
+func TestRecordAfterPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	t.Setenv(EnvVar, path)
+	require.NoError(t, os.WriteFile(path, []byte(`{"timestamp":"2022-`), 0600))
+
+	require.NoError(t, Record("create", "Cluster", "kind-kind", "created", nil))
+
+	entries := readEntries(t, path)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "kind-kind", entries[0].Name)
+}
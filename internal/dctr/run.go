@@ -14,6 +14,8 @@ import (
 	specs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/pflag"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/internal/egress"
 )
 
 // Docker Container client.
@@ -68,6 +70,12 @@ func RemoveIfNecessary(ctx context.Context, c Client, name string) error {
 
 // A simplified run-container-and-detach helper for background support containers (like socat and the registry).
 func Run(ctx context.Context, c Client, name string, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig) error {
+	return RunWithPlatform(ctx, c, name, config, hostConfig, networkingConfig, nil)
+}
+
+// Like Run, but pulls and runs the image for the given platform.
+// A nil platform uses the daemon's default.
+func RunWithPlatform(ctx context.Context, c Client, name string, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *specs.Platform) error {
 
 	ctr, err := c.ContainerInspect(ctx, name)
 	if err == nil && (ctr.ContainerJSONBase != nil && ctr.State.Running) {
@@ -83,18 +91,19 @@ func Run(ctx context.Context, c Client, name string, config *container.Config, h
 		return fmt.Errorf("inspecting %s: %v", name, err)
 	}
 
-	resp, err := c.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, name)
+	resp, err := c.ContainerCreate(ctx, config, hostConfig, networkingConfig, platform, name)
 	if err != nil {
+		// Docker also reports an image for another platform as not found.
 		if !client.IsErrNotFound(err) {
 			return fmt.Errorf("creating %s: %v", name, err)
 		}
 
-		err := pull(ctx, c, config.Image)
+		err := pull(ctx, c, config.Image, platform)
 		if err != nil {
 			return fmt.Errorf("pulling image %s: %v", config.Image, err)
 		}
 
-		resp, err = c.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, name)
+		resp, err = c.ContainerCreate(ctx, config, hostConfig, networkingConfig, platform, name)
 		if err != nil {
 			return fmt.Errorf("creating %s: %v", name, err)
 		}
@@ -108,8 +117,17 @@ func Run(ctx context.Context, c Client, name string, config *container.Config, h
 	return nil
 }
 
-func pull(ctx context.Context, c Client, image string) error {
-	resp, err := c.ImagePull(ctx, image, types.ImagePullOptions{})
+func pull(ctx context.Context, c Client, image string, platform *specs.Platform) error {
+	err := egress.CheckImage(fmt.Sprintf("pulling image %s", image), image)
+	if err != nil {
+		return err
+	}
+
+	options := types.ImagePullOptions{}
+	if platform != nil {
+		options.Platform = FormatPlatform(*platform)
+	}
+	resp, err := c.ImagePull(ctx, image, options)
 	if err != nil {
 		return fmt.Errorf("pulling image %s: %v", image, err)
 	}
@@ -118,3 +136,12 @@ func pull(ctx context.Context, c Client, image string) error {
 	_, _ = io.Copy(io.Discard, resp)
 	return nil
 }
+
+// Formats a platform like docker --platform, e.g., linux/arm64 or linux/arm/v7.
+func FormatPlatform(platform specs.Platform) string {
+	result := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		result += "/" + platform.Variant
+	}
+	return result
+}
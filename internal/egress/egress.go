@@ -0,0 +1,144 @@
+// Package egress lets users forbid ctlptl from connecting to anything
+// other than the Docker daemon, local registries, and their clusters.
+//
+// When egress is disabled (with --no-network-egress or
+// CTLPTL_NO_NETWORK_EGRESS=true), ctlptl:
+//
+//   - doesn't send analytics
+//   - fails to apply config from http:// or https:// URLs
+//   - fails to pull images, except from loopback registries and mirrors
+//   - fails to fetch manifests and Helm charts from the internet (calico,
+//     kyverno, metallb, openebs, longhorn, and helmCharts with a repo)
+//   - fails to talk to registries, except loopback registries and mirrors
+//   - runs `ctlptl registry scan` with trivy's cached vulnerability DBs,
+//     instead of letting trivy download them
+//
+// Mirrors are the hosts listed in CTLPTL_EGRESS_MIRRORS, separated by commas
+// (e.g., mirror.corp.example.com,10.0.0.5:5000).
+//
+// ctlptl can't stop the tools it runs (kind, k3d, minikube, docker) from
+// pulling images on their own, like the kind node image. Pre-pull them, or
+// point those tools at your mirror.
+package egress
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+)
+
+// EnvVar disables egress when set to true.
+const EnvVar = "CTLPTL_NO_NETWORK_EGRESS"
+
+// MirrorsEnvVar lists the hosts that ctlptl may still connect to when egress is disabled.
+const MirrorsEnvVar = "CTLPTL_EGRESS_MIRRORS"
+
+// BlockedError means that an operation needed network egress.
+type BlockedError struct {
+	Operation string
+}
+
+func (e BlockedError) Error() string {
+	return fmt.Sprintf("%s requires network egress, which is disabled by --no-network-egress ($%s)",
+		e.Operation, EnvVar)
+}
+
+// IsBlocked checks if the error is a BlockedError.
+func IsBlocked(err error) bool {
+	_, ok := err.(BlockedError)
+	if !ok {
+		_, ok = err.(*BlockedError)
+	}
+	return ok
+}
+
+// Disabled returns true if network egress is disabled.
+func Disabled() bool {
+	disabled, _ := strconv.ParseBool(os.Getenv(EnvVar))
+	return disabled
+}
+
+// Check fails if egress is disabled.
+//
+// The operation describes what needs egress, e.g., "fetching the calico manifest".
+func Check(operation string) error {
+	if Disabled() {
+		return BlockedError{Operation: operation}
+	}
+	return nil
+}
+
+// CheckURL fails if egress is disabled, unless the URL points at
+// a loopback address or a mirror.
+func CheckURL(operation, rawURL string) error {
+	if !Disabled() {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return BlockedError{Operation: operation}
+	}
+	return CheckHost(operation, u.Host)
+}
+
+// CheckHost fails if egress is disabled, unless the host (with an optional port)
+// is a loopback address or a mirror.
+func CheckHost(operation, host string) error {
+	if !Disabled() || isLoopback(host) || isMirror(host) {
+		return nil
+	}
+	return BlockedError{Operation: operation}
+}
+
+// CheckImage fails if egress is disabled, unless the image comes from
+// a loopback registry or a mirror.
+func CheckImage(operation, image string) error {
+	if !Disabled() {
+		return nil
+	}
+	ref, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return BlockedError{Operation: operation}
+	}
+	return CheckHost(operation, reference.Domain(ref))
+}
+
+func hostname(host string) string {
+	h, _, err := net.SplitHostPort(host)
+	if err != nil {
+		return strings.Trim(host, "[]")
+	}
+	return h
+}
+
+func isLoopback(host string) bool {
+	h := hostname(host)
+	if h == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(h)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Matches a mirror with the same host and port, or a mirror without a port
+// with the same host.
+func isMirror(host string) bool {
+	for _, mirror := range strings.Split(os.Getenv(MirrorsEnvVar), ",") {
+		mirror = strings.TrimSpace(mirror)
+		if mirror == "" {
+			continue
+		}
+		if mirror == host {
+			return true
+		}
+		if _, _, err := net.SplitHostPort(mirror); err != nil && mirror == hostname(host) {
+			return true
+		}
+	}
+	return false
+}
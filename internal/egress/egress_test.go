@@ -0,0 +1,39 @@
+package egress
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnabledByDefault(t *testing.T) {
+	t.Setenv(EnvVar, "")
+	assert.False(t, Disabled())
+	assert.NoError(t, Check("sending analytics"))
+	assert.NoError(t, CheckURL("fetching", "https://example.com/cluster.yaml"))
+	assert.NoError(t, CheckImage("pulling", "registry:2"))
+}
+
+func TestDisabled(t *testing.T) {
+	t.Setenv(EnvVar, "true")
+	t.Setenv(MirrorsEnvVar, "mirror.corp.example.com, 10.0.0.5:5000")
+
+	err := Check("fetching the calico manifest")
+	if assert.Error(t, err) {
+		assert.True(t, IsBlocked(err))
+		assert.Equal(t, "fetching the calico manifest requires network egress, "+
+			"which is disabled by --no-network-egress ($CTLPTL_NO_NETWORK_EGRESS)", err.Error())
+	}
+
+	assert.Error(t, CheckURL("fetching", "https://example.com/cluster.yaml"))
+	assert.NoError(t, CheckURL("fetching", "http://localhost:8000/cluster.yaml"))
+	assert.NoError(t, CheckURL("fetching", "http://127.0.0.1/cluster.yaml"))
+	assert.NoError(t, CheckURL("fetching", "http://[::1]:8000/cluster.yaml"))
+	assert.NoError(t, CheckURL("fetching", "https://mirror.corp.example.com:8443/cluster.yaml"))
+
+	assert.Error(t, CheckImage("pulling", "registry:2"))
+	assert.Error(t, CheckImage("pulling", "10.0.0.5:5001/registry:2"))
+	assert.NoError(t, CheckImage("pulling", "10.0.0.5:5000/registry:2"))
+	assert.NoError(t, CheckImage("pulling", "localhost:5000/registry:2"))
+	assert.NoError(t, CheckImage("pulling", "mirror.corp.example.com/library/registry:2"))
+}
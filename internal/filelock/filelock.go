@@ -0,0 +1,68 @@
+// Package filelock serializes ctlptl invocations that touch the same resources.
+//
+// Locks are advisory OS file locks, so they're released automatically
+// if the process holding them dies.
+package filelock
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const pollInterval = 100 * time.Millisecond
+
+type Lock struct {
+	f *os.File
+}
+
+// Acquire blocks until it holds the lock at path, or the timeout expires.
+//
+// If another process holds the lock, prints a message to errOut once.
+func Acquire(path string, timeout time.Duration, errOut io.Writer) (*Lock, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("creating lock dir: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock: %v", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	waiting := false
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("acquiring lock %s: %v", path, err)
+		}
+		if ok {
+			return &Lock{f: f}, nil
+		}
+
+		if time.Now().After(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("another ctlptl operation is in progress. Timed out after %s waiting for lock %s", timeout, path)
+		}
+		if !waiting {
+			waiting = true
+			_, _ = fmt.Fprintf(errOut, "Another ctlptl operation is in progress. Waiting up to %s for it to finish...\n", timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Release releases the lock. The lock file stays on disk, so that
+// other processes waiting on it keep a valid handle.
+func (l *Lock) Release() error {
+	err := unlock(l.f)
+	closeErr := l.f.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,31 @@
+package filelock
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireContention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "cluster-kind-kind.lock")
+	out := bytes.NewBuffer(nil)
+
+	lock, err := Acquire(path, time.Second, out)
+	require.NoError(t, err)
+
+	_, err = Acquire(path, 200*time.Millisecond, out)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "another ctlptl operation is in progress")
+	}
+	assert.Contains(t, out.String(), "Waiting up to 200ms")
+
+	require.NoError(t, lock.Release())
+
+	lock, err = Acquire(path, 200*time.Millisecond, out)
+	require.NoError(t, err)
+	require.NoError(t, lock.Release())
+}
@@ -0,0 +1,241 @@
+// Package hostsfile manages blocks of entries in /etc/hosts.
+//
+// Each block belongs to one owner (like a cluster or registry), and is
+// delimited by comments, so that we never touch entries we didn't write.
+//
+// Files that ctlptl owns outright, like the /etc/hosts of a node container,
+// may instead mark each entry with a trailing comment. See UpdateManaged.
+package hostsfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const beginPrefix = "# BEGIN ctlptl "
+const endPrefix = "# END ctlptl "
+
+// ManagedMarker ends each line that UpdateManaged writes.
+const ManagedMarker = "# managed by ctlptl"
+
+// Entry points hostnames at an IP.
+type Entry struct {
+	IP        string
+	Hostnames []string
+}
+
+// File is a hosts file.
+type File struct {
+	Path string
+}
+
+// Default returns the hosts file of the machine.
+func Default() *File {
+	if runtime.GOOS == "windows" {
+		root := os.Getenv("SystemRoot")
+		if root == "" {
+			root = `C:\Windows`
+		}
+		return &File{Path: filepath.Join(root, "System32", "drivers", "etc", "hosts")}
+	}
+	return &File{Path: "/etc/hosts"}
+}
+
+// PermissionError means that we don't have permission to write the hosts file.
+//
+// Usually means that ctlptl needs to run with sudo. Block contains the lines
+// that we wanted to write, so that the user can add them by hand.
+type PermissionError struct {
+	Path  string
+	Owner string
+	Block string
+	Err   error
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("updating %s: %v", e.Path, e.Err)
+}
+
+// Hint tells the user how to make the change by hand.
+func (e *PermissionError) Hint() string {
+	if e.Block == "" {
+		return fmt.Sprintf("Re-run with sudo, or remove the ctlptl %s block from %s yourself.\n", e.Owner, e.Path)
+	}
+	return fmt.Sprintf("Re-run with sudo, or add these lines to %s yourself:\n%s", e.Path, e.Block)
+}
+
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateHostnames checks that each hostname can go in a hosts file, once.
+func ValidateHostnames(hostnames []string) error {
+	seen := make(map[string]bool, len(hostnames))
+	for _, hostname := range hostnames {
+		err := ValidateHostname(hostname)
+		if err != nil {
+			return err
+		}
+		if seen[hostname] {
+			return fmt.Errorf("%s is listed more than once", hostname)
+		}
+		seen[hostname] = true
+	}
+	return nil
+}
+
+// ValidateHostname checks that a hostname can go in a hosts file.
+func ValidateHostname(hostname string) error {
+	if strings.Contains(hostname, "*") {
+		return fmt.Errorf("%s: hosts files don't support wildcards. List each hostname instead", hostname)
+	}
+	errs := validation.IsDNS1123Subdomain(hostname)
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %s", hostname, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Set replaces the owner's block with the given entries.
+//
+// Removes the block if there are no entries. Doesn't write the
+// file if nothing changed.
+func (f *File) Set(owner string, entries []Entry) error {
+	data, err := os.ReadFile(f.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	old := string(data)
+	updated, err := Update(old, owner, entries)
+	if err != nil {
+		return err
+	}
+	if updated == old {
+		return nil
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(f.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	// Write in place, rather than renaming over the file, because
+	// /etc/hosts is often a bind mount inside containers.
+	err = os.WriteFile(f.Path, []byte(updated), mode)
+	if err != nil {
+		if os.IsPermission(err) {
+			return &PermissionError{Path: f.Path, Owner: owner, Block: Block(owner, entries), Err: err}
+		}
+		return fmt.Errorf("updating %s: %v", f.Path, err)
+	}
+	return nil
+}
+
+// Remove removes the owner's block.
+func (f *File) Remove(owner string) error {
+	return f.Set(owner, nil)
+}
+
+// Update replaces the owner's block in the contents of a hosts file.
+//
+// Everything outside the block is left as-is.
+func Update(content, owner string, entries []Entry) (string, error) {
+	begin := beginPrefix + owner
+	end := endPrefix + owner
+
+	lines := strings.SplitAfter(content, "\n")
+	result := strings.Builder{}
+	inBlock := false
+	found := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == begin:
+			if found {
+				return "", fmt.Errorf("hosts file has more than one block for ctlptl %s", owner)
+			}
+			inBlock = true
+			found = true
+			result.WriteString(Block(owner, entries))
+			continue
+		case trimmed == end && inBlock:
+			inBlock = false
+			continue
+		case inBlock:
+			continue
+		}
+		result.WriteString(line)
+	}
+	if inBlock {
+		return "", fmt.Errorf("hosts file has no %q line to end the block for ctlptl %s", end, owner)
+	}
+
+	if !found {
+		block := Block(owner, entries)
+		if block == "" {
+			return content, nil
+		}
+		s := result.String()
+		if s != "" && !strings.HasSuffix(s, "\n") {
+			result.WriteString("\n")
+		}
+		result.WriteString(block)
+	}
+	return result.String(), nil
+}
+
+// Block renders the owner's block, or an empty string if there are no entries.
+func Block(owner string, entries []Entry) string {
+	lines := entryLines(entries)
+	if len(lines) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s%s\n%s\n%s%s\n", beginPrefix, owner, strings.Join(lines, "\n"), endPrefix, owner)
+}
+
+// UpdateManaged replaces every line that ends with ManagedMarker with the
+// entries, each marked with ManagedMarker. Leaves the other lines alone.
+func UpdateManaged(content string, entries []Entry) string {
+	result := strings.Builder{}
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if strings.HasSuffix(strings.TrimSpace(line), ManagedMarker) {
+			continue
+		}
+		result.WriteString(line)
+	}
+
+	lines := entryLines(entries)
+	if len(lines) == 0 {
+		return result.String()
+	}
+	s := result.String()
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		result.WriteString("\n")
+	}
+	for _, line := range lines {
+		result.WriteString(fmt.Sprintf("%s %s\n", line, ManagedMarker))
+	}
+	return result.String()
+}
+
+// The sorted "IP hostname..." lines of the entries.
+func entryLines(entries []Entry) []string {
+	lines := []string{}
+	for _, entry := range entries {
+		if len(entry.Hostnames) == 0 {
+			continue
+		}
+		hostnames := append([]string{}, entry.Hostnames...)
+		sort.Strings(hostnames)
+		lines = append(lines, fmt.Sprintf("%s %s", entry.IP, strings.Join(hostnames, " ")))
+	}
+	sort.Strings(lines)
+	return lines
+}
@@ -0,0 +1,126 @@
+package hostsfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const original = `127.0.0.1 localhost
+::1 localhost
+192.168.1.10 nas.home
+`
+
+func TestUpdateAddsBlock(t *testing.T) {
+	result, err := Update(original, "cluster kind-kind", []Entry{
+		{IP: "127.0.0.1", Hostnames: []string{"app.kind.local", "api.kind.local"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, original+`# BEGIN ctlptl cluster kind-kind
+127.0.0.1 api.kind.local app.kind.local
+# END ctlptl cluster kind-kind
+`, result)
+}
+
+func TestUpdateReplacesOnlyItsBlock(t *testing.T) {
+	content := original + `# BEGIN ctlptl registry ctlptl-registry
+127.0.0.1 registry.local
+# END ctlptl registry ctlptl-registry
+# BEGIN ctlptl cluster kind-kind
+127.0.0.1 old.kind.local
+# END ctlptl cluster kind-kind
+10.0.0.1 added-by-hand
+`
+	result, err := Update(content, "cluster kind-kind", []Entry{
+		{IP: "127.0.0.1", Hostnames: []string{"app.kind.local"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, original+`# BEGIN ctlptl registry ctlptl-registry
+127.0.0.1 registry.local
+# END ctlptl registry ctlptl-registry
+# BEGIN ctlptl cluster kind-kind
+127.0.0.1 app.kind.local
+# END ctlptl cluster kind-kind
+10.0.0.1 added-by-hand
+`, result)
+
+	result, err = Update(result, "cluster kind-kind", nil)
+	require.NoError(t, err)
+	assert.Equal(t, original+`# BEGIN ctlptl registry ctlptl-registry
+127.0.0.1 registry.local
+# END ctlptl registry ctlptl-registry
+10.0.0.1 added-by-hand
+`, result)
+}
+
+func TestUpdateNoTrailingNewline(t *testing.T) {
+	result, err := Update("127.0.0.1 localhost", "registry r", []Entry{
+		{IP: "127.0.0.1", Hostnames: []string{"registry.local"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1 localhost\n# BEGIN ctlptl registry r\n127.0.0.1 registry.local\n# END ctlptl registry r\n", result)
+}
+
+func TestUpdateUnterminatedBlock(t *testing.T) {
+	_, err := Update(original+"# BEGIN ctlptl registry r\n127.0.0.1 registry.local\n", "registry r", nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `no "# END ctlptl registry r" line`)
+	}
+}
+
+func TestUpdateManaged(t *testing.T) {
+	content := original + "10.0.0.5 old.local # managed by ctlptl\n10.0.0.1 added-by-hand\n"
+	result := UpdateManaged(content, []Entry{
+		{IP: "192.168.1.20", Hostnames: []string{"db.local", "cache.local"}},
+		{IP: "172.18.0.1", Hostnames: []string{"host.docker.internal"}},
+	})
+	assert.Equal(t, original+"10.0.0.1 added-by-hand\n"+
+		"172.18.0.1 host.docker.internal # managed by ctlptl\n"+
+		"192.168.1.20 cache.local db.local # managed by ctlptl\n", result)
+
+	// Re-applying the same entries doesn't change anything.
+	assert.Equal(t, result, UpdateManaged(result, []Entry{
+		{IP: "172.18.0.1", Hostnames: []string{"host.docker.internal"}},
+		{IP: "192.168.1.20", Hostnames: []string{"cache.local", "db.local"}},
+	}))
+
+	assert.Equal(t, original+"10.0.0.1 added-by-hand\n", UpdateManaged(result, nil))
+}
+
+func TestSetSkipsUnchangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	require.NoError(t, os.WriteFile(path, []byte(original), 0644))
+	f := &File{Path: path}
+
+	require.NoError(t, f.Remove("registry r"))
+
+	// A read-only file is fine, as long as there's nothing to write.
+	require.NoError(t, os.Chmod(path, 0444))
+	require.NoError(t, f.Remove("registry r"))
+
+	if os.Getuid() == 0 {
+		t.Skip("root can write read-only files")
+	}
+	err := f.Set("registry r", []Entry{{IP: "127.0.0.1", Hostnames: []string{"registry.local"}}})
+	var permErr *PermissionError
+	if assert.ErrorAs(t, err, &permErr) {
+		assert.Equal(t, "# BEGIN ctlptl registry r\n127.0.0.1 registry.local\n# END ctlptl registry r\n", permErr.Block)
+	}
+}
+
+func TestValidateHostname(t *testing.T) {
+	assert.NoError(t, ValidateHostname("registry.local"))
+	err := ValidateHostname("*.kind.local")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "hosts files don't support wildcards")
+	}
+	assert.Error(t, ValidateHostname("Not_A_Host"))
+
+	err = ValidateHostnames([]string{"registry.local", "app.local", "registry.local"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "registry.local is listed more than once")
+	}
+}
@@ -0,0 +1,157 @@
+package printers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// TemplatePrinter renders ctlptl objects with a Go template, like kubectl's
+// -o go-template.
+//
+// The object is converted to its JSON form first, so templates use the same
+// field names as -o yaml (e.g., {{.status.ready}}). Lists expose their
+// objects as {{.items}}.
+//
+// Unlike kubectl's GoTemplatePrinter, this doesn't write partial output or
+// debugging info when the template fails. It only returns an error.
+type TemplatePrinter struct {
+	raw      string
+	template *template.Template
+}
+
+// NewTemplatePrinter parses the template.
+func NewTemplatePrinter(tmpl string, allowMissingKeys bool) (*TemplatePrinter, error) {
+	missingKey := "missingkey=error"
+	if allowMissingKeys {
+		missingKey = "missingkey=default"
+	}
+	t, err := template.New("output").
+		Funcs(template.FuncMap{
+			"exists":       exists,
+			"base64decode": base64decode,
+		}).
+		Option(missingKey).
+		Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %q: %v", tmpl, err)
+	}
+	return &TemplatePrinter{raw: tmpl, template: t}, nil
+}
+
+// PrintObj renders the object with the template.
+func (p *TemplatePrinter) PrintObj(obj runtime.Object, w io.Writer) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	out := map[string]interface{}{}
+	err = json.Unmarshal(data, &out)
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = p.safeExecute(buf, out)
+	if err != nil {
+		hint := ""
+		if meta.IsListType(obj) {
+			hint = ". The object is a list; use {{range .items}} to render each item"
+		} else if strings.Contains(p.raw, ".items") {
+			hint = ". The object is a single " + GetObjectGroupKind(obj).Kind + ", not a list"
+		}
+		return fmt.Errorf("executing template %q: %v%s", p.raw, err, hint)
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// Catches panics from the template engine, and returns them as errors.
+func (p *TemplatePrinter) safeExecute(w io.Writer, obj interface{}) (err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("caught panic: %+v", x)
+		}
+	}()
+	return p.template.Execute(w, obj)
+}
+
+func base64decode(v string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return "", fmt.Errorf("base64 decode failed: %v", err)
+	}
+	return string(data), nil
+}
+
+// NOTE: exists and indirect are copied from k8s.io/cli-runtime/pkg/printers,
+// so that templates can use the same functions as in kubectl.
+
+// exists returns true if it would be possible to call the index function
+// with these arguments.
+func exists(item interface{}, indices ...interface{}) bool {
+	v := reflect.ValueOf(item)
+	for _, i := range indices {
+		index := reflect.ValueOf(i)
+		var isNil bool
+		if v, isNil = indirect(v); isNil {
+			return false
+		}
+		switch v.Kind() {
+		case reflect.Array, reflect.Slice, reflect.String:
+			var x int64
+			switch index.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				x = index.Int()
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+				x = int64(index.Uint())
+			default:
+				return false
+			}
+			if x < 0 || x >= int64(v.Len()) {
+				return false
+			}
+			v = v.Index(int(x))
+		case reflect.Map:
+			if !index.IsValid() {
+				index = reflect.Zero(v.Type().Key())
+			}
+			if !index.Type().AssignableTo(v.Type().Key()) {
+				return false
+			}
+			if x := v.MapIndex(index); x.IsValid() {
+				v = x
+			} else {
+				v = reflect.Zero(v.Type().Elem())
+			}
+		default:
+			return false
+		}
+	}
+	if _, isNil := indirect(v); isNil {
+		return false
+	}
+	return true
+}
+
+// indirect returns the item at the end of indirection, and a bool to indicate if it's nil.
+func indirect(v reflect.Value) (rv reflect.Value, isNil bool) {
+	for ; v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface; v = v.Elem() {
+		if v.IsNil() {
+			return v, true
+		}
+		if v.Kind() == reflect.Interface && v.NumMethod() > 0 {
+			break
+		}
+	}
+	return v, false
+}
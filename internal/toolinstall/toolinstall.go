@@ -0,0 +1,414 @@
+// Package toolinstall downloads the tools that ctlptl drives (kind and
+// trivy) for --auto-install, so that a fresh machine only needs Docker and ctlptl.
+//
+// Each tool is pinned to a version, and verified against a SHA-256 checksum
+// that's built into ctlptl (checksums.txt), rather than one downloaded from the
+// same release as the binary, so a swapped release asset fails to install.
+// Binaries go in a directory that ctlptl manages
+// (~/.ctlptl/bin), named after their version, and ctlptl runs them by absolute
+// path. ctlptl never changes the PATH, and never needs sudo.
+//
+// Downloads are written to a temporary file in the same directory, and only
+// renamed into place once the checksum matches, so an interrupted install
+// leaves nothing behind, and it's always safe to retry.
+package toolinstall
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver/v4"
+
+	"github.com/tilt-dev/ctlptl/internal/egress"
+)
+
+// EnvVar enables auto-install when set to true. Set by the --auto-install flag.
+const EnvVar = "CTLPTL_AUTO_INSTALL"
+
+// Enabled checks whether the user opted in to auto-install.
+func Enabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(EnvVar))
+	return enabled
+}
+
+// Tool is a command-line tool that ctlptl knows how to install.
+type Tool struct {
+	Name string
+
+	// The version that ctlptl installs. Its checksums must be in checksums.txt.
+	Version string
+
+	// The oldest version that ctlptl works with. Older versions on the PATH
+	// are ignored in favor of the pinned version.
+	MinVersion string
+
+	// The OS/architecture pairs that the release has binaries for.
+	Platforms []string
+
+	// The URL of the release binary for an OS and architecture.
+	BinaryURL func(goos, goarch string) string
+
+	// If the release is a .tar.gz archive, rather than a bare binary,
+	// the name of the binary in the archive. The checksum is of the archive.
+	ArchiveMember string
+}
+
+// The checksums of the release binaries of the pinned versions, in
+// sha256sum format, with files named TOOL/VERSION/ASSET.
+//
+// After changing the version of a tool, regenerate them with `make tool-checksums`.
+//
+//go:embed checksums.txt
+var pinnedChecksums string
+
+// The name of the release binary in the pinned checksums.
+func (t Tool) checksumName(goos, goarch string) string {
+	return fmt.Sprintf("%s/%s/%s", t.Name, t.Version, path.Base(t.BinaryURL(goos, goarch)))
+}
+
+var Kind = Tool{
+	Name:       "kind",
+	Version:    "v0.20.0",
+	MinVersion: "v0.15.0",
+	Platforms:  []string{"darwin/amd64", "darwin/arm64", "linux/amd64", "linux/arm64"},
+	BinaryURL: func(goos, goarch string) string {
+		return fmt.Sprintf("https://github.com/kubernetes-sigs/kind/releases/download/v0.20.0/kind-%s-%s", goos, goarch)
+	},
+}
+
+var Trivy = Tool{
+	Name:       "trivy",
+	Version:    "v0.45.0",
+	MinVersion: "v0.40.0",
+	Platforms:  []string{"darwin/amd64", "darwin/arm64", "linux/amd64", "linux/arm64"},
+	BinaryURL: func(goos, goarch string) string {
+		return "https://github.com/aquasecurity/trivy/releases/download/v0.45.0/" + trivyAsset(goos, goarch)
+	},
+	ArchiveMember: "trivy",
+}
+
+// Trivy names its releases like trivy_0.45.0_Linux-64bit.tar.gz.
+func trivyAsset(goos, goarch string) string {
+	osName := map[string]string{"darwin": "macOS", "linux": "Linux"}[goos]
+	archName := map[string]string{"amd64": "64bit", "arm64": "ARM64"}[goarch]
+	return fmt.Sprintf("trivy_0.45.0_%s-%s.tar.gz", osName, archName)
+}
+
+// Installer installs tools into a directory.
+type Installer struct {
+	// Where to put the binaries.
+	Dir string
+
+	GOOS   string
+	GOARCH string
+
+	Client *http.Client
+
+	// The checksums to verify downloads against, in sha256sum format.
+	Checksums string
+
+	// Where to print progress.
+	ErrOut io.Writer
+}
+
+// DefaultDir is where ctlptl installs tools: ~/.ctlptl/bin.
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".ctlptl", "bin"), nil
+}
+
+func NewInstaller(errOut io.Writer) (*Installer, error) {
+	dir, err := DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Installer{
+		Dir:       dir,
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		Client:    http.DefaultClient,
+		Checksums: pinnedChecksums,
+		ErrOut:    errOut,
+	}, nil
+}
+
+// Ensure returns the path of the tool to run, or "" if it isn't installed.
+//
+// With --auto-install, installs the pinned version of the tool if it's
+// missing from the PATH or too old. Without it, still uses a copy
+// that an earlier --auto-install put in ~/.ctlptl/bin.
+func Ensure(ctx context.Context, errOut io.Writer, tool Tool) (string, error) {
+	installer, err := NewInstaller(errOut)
+	if err != nil {
+		return "", err
+	}
+	if Enabled() {
+		return installer.Find(ctx, tool)
+	}
+
+	path, err := exec.LookPath(tool.Name)
+	if err == nil {
+		return path, nil
+	}
+	if _, err := os.Stat(installer.Path(tool)); err == nil {
+		return installer.Path(tool), nil
+	}
+	return "", nil
+}
+
+// Path is where the installer puts the pinned version of the tool.
+func (i *Installer) Path(tool Tool) string {
+	name := fmt.Sprintf("%s-%s", tool.Name, tool.Version)
+	if i.GOOS == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join(i.Dir, name)
+}
+
+// Find returns the tool to run: the tool on the PATH, if it's at a supported
+// version, or else the pinned version, installing it if needed.
+func (i *Installer) Find(ctx context.Context, tool Tool) (string, error) {
+	onPath, err := exec.LookPath(tool.Name)
+	if err == nil {
+		v, err := InstalledVersion(ctx, onPath)
+		if err == nil && Supports(tool, v) {
+			return onPath, nil
+		}
+		if err == nil {
+			_, _ = fmt.Fprintf(i.ErrOut, "%s %s on the PATH is older than %s. Using %s %s instead\n",
+				tool.Name, v, tool.MinVersion, tool.Name, tool.Version)
+		}
+	}
+	return i.Install(ctx, tool)
+}
+
+// Install downloads the pinned version of the tool, unless it's already
+// installed, and returns its path.
+func (i *Installer) Install(ctx context.Context, tool Tool) (string, error) {
+	dest := i.Path(tool)
+	if _, err := os.Stat(dest); err == nil {
+		return dest, nil
+	}
+
+	platform := i.GOOS + "/" + i.GOARCH
+	supported := false
+	for _, p := range tool.Platforms {
+		if p == platform {
+			supported = true
+		}
+	}
+	if !supported {
+		return "", fmt.Errorf("%s %s has no release binary for %s. Install it yourself", tool.Name, tool.Version, platform)
+	}
+
+	want, err := parseChecksum(i.Checksums, tool.checksumName(i.GOOS, i.GOARCH))
+	if err != nil {
+		return "", fmt.Errorf("installing %s: %v. Install it yourself", tool.Name, err)
+	}
+
+	binaryURL := tool.BinaryURL(i.GOOS, i.GOARCH)
+	err = egress.CheckURL(fmt.Sprintf("installing %s", tool.Name), binaryURL)
+	if err != nil {
+		return "", err
+	}
+
+	_, _ = fmt.Fprintf(i.ErrOut, "Installing %s %s to %s\n", tool.Name, tool.Version, dest)
+	err = i.download(ctx, binaryURL, dest, want, tool.ArchiveMember)
+	if err != nil {
+		return "", fmt.Errorf("installing %s: %v", tool.Name, err)
+	}
+	return dest, nil
+}
+
+func (i *Installer) open(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := i.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Downloads the binary next to dest, and renames it into place
+// once we've verified the checksum. If member is set, the download is
+// a .tar.gz archive, and the binary is the member with that name.
+func (i *Installer) download(ctx context.Context, url, dest, wantSum, member string) error {
+	err := os.MkdirAll(i.Dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	body, err := i.open(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	tmp, err := os.CreateTemp(i.Dir, filepath.Base(dest)+".*.download")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), body)
+	closeErr := tmp.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	gotSum := hex.EncodeToString(hash.Sum(nil))
+	if gotSum != wantSum {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, wantSum, gotSum)
+	}
+
+	binary := tmp.Name()
+	if member != "" {
+		binary, err = i.extract(tmp.Name(), member, filepath.Base(dest))
+		if err != nil {
+			return fmt.Errorf("extracting %s from %s: %v", member, url, err)
+		}
+		defer func() { _ = os.Remove(binary) }()
+	}
+
+	err = os.Chmod(binary, 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(binary, dest)
+	if err != nil {
+		// Another ctlptl may have installed it first (Windows can't rename over it).
+		if _, statErr := os.Stat(dest); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Extracts the member of a .tar.gz archive to a temp file in the install dir,
+// and returns the temp file's path.
+func (i *Installer) extract(archive, member, name string) (string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return "", err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return "", fmt.Errorf("not found in archive")
+		}
+		if err != nil {
+			return "", err
+		}
+		if header.Typeflag != tar.TypeReg || path.Clean(header.Name) != member {
+			continue
+		}
+
+		tmp, err := os.CreateTemp(i.Dir, name+".*.extract")
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(tmp, tr)
+		closeErr := tmp.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+			return "", err
+		}
+		return tmp.Name(), nil
+	}
+}
+
+// Finds the checksum of the file in the output of sha256sum,
+// which has a line for each file like "HASH  NAME" or "HASH *NAME".
+// Skips # comments.
+func parseChecksum(sums, file string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(sums))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if strings.TrimPrefix(fields[1], "*") == file {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no pinned checksum for %s", file)
+}
+
+var versionRe = regexp.MustCompile(`v?\d+\.\d+\.\d+`)
+
+// InstalledVersion runs `TOOL version` and returns the first version in
+// the output (e.g., "kind v0.20.0 go1.20.4 linux/amd64", or "Version: 0.45.0"
+// for trivy).
+func InstalledVersion(ctx context.Context, binary string) (string, error) {
+	out := bytes.NewBuffer(nil)
+	cmd := exec.CommandContext(ctx, binary, "version")
+	cmd.Stdout = out
+	err := cmd.Run()
+	if err != nil {
+		return "", err
+	}
+	v := versionRe.FindString(out.String())
+	if v == "" {
+		return "", fmt.Errorf("parsing %s version: %s", binary, out.String())
+	}
+	return v, nil
+}
+
+// Supports checks whether ctlptl works with this version of the tool.
+func Supports(tool Tool, version string) bool {
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		return false
+	}
+	min, err := semver.ParseTolerant(tool.MinVersion)
+	if err != nil {
+		return false
+	}
+	return v.GTE(min)
+}
@@ -0,0 +1,258 @@
+package toolinstall
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/internal/egress"
+)
+
+const fakeBinary = "#!/bin/sh\necho fake v1.2.3\n"
+
+type fixture struct {
+	t         *testing.T
+	installer *Installer
+	tool      Tool
+	errOut    *bytes.Buffer
+	requests  map[string]int
+}
+
+func newFixture(t *testing.T) *fixture {
+	sum := sha256.Sum256([]byte(fakeBinary))
+	f := &fixture{
+		t:        t,
+		errOut:   bytes.NewBuffer(nil),
+		requests: make(map[string]int),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/fake-linux-amd64", "/fake-darwin-amd64":
+			_, _ = fmt.Fprint(w, fakeBinary)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	f.tool = Tool{
+		Name:       "fake",
+		Version:    "v1.2.3",
+		MinVersion: "v1.0.0",
+		Platforms:  []string{"linux/amd64", "darwin/amd64"},
+		BinaryURL: func(goos, goarch string) string {
+			return fmt.Sprintf("%s/fake-%s-%s", server.URL, goos, goarch)
+		},
+	}
+	f.installer = &Installer{
+		Dir:       filepath.Join(t.TempDir(), "bin"),
+		GOOS:      "linux",
+		GOARCH:    "amd64",
+		Client:    server.Client(),
+		Checksums: fmt.Sprintf("# Pinned\n%s  fake/v1.2.3/fake-linux-amd64\n", hex.EncodeToString(sum[:])),
+		ErrOut:    f.errOut,
+	}
+	return f
+}
+
+// The files left in the install dir.
+func (f *fixture) files() []string {
+	entries, err := os.ReadDir(f.installer.Dir)
+	require.NoError(f.t, err)
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestInstall(t *testing.T) {
+	f := newFixture(t)
+
+	path, err := f.installer.Install(context.Background(), f.tool)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(f.installer.Dir, "fake-v1.2.3"), path)
+	assert.Contains(t, f.errOut.String(), "Installing fake v1.2.3 to "+path)
+
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, fakeBinary, string(contents))
+	assert.Equal(t, []string{"fake-v1.2.3"}, f.files())
+
+	// Installing again uses the binary we already have.
+	path2, err := f.installer.Install(context.Background(), f.tool)
+	require.NoError(t, err)
+	assert.Equal(t, path, path2)
+	assert.Equal(t, 1, f.requests["/fake-linux-amd64"])
+}
+
+func TestInstallFromArchive(t *testing.T) {
+	f := newFixture(t)
+
+	archive := bytes.NewBuffer(nil)
+	gz := gzip.NewWriter(archive)
+	tw := tar.NewWriter(gz)
+	for name, contents := range map[string]string{"README.md": "# fake\n", "fake": fakeBinary} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	sum := sha256.Sum256(archive.Bytes())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fake_1.2.3_Linux-64bit.tar.gz":
+			_, _ = w.Write(archive.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	f.installer.Client = server.Client()
+	f.tool.BinaryURL = func(goos, goarch string) string { return server.URL + "/fake_1.2.3_Linux-64bit.tar.gz" }
+	f.tool.ArchiveMember = "fake"
+	f.installer.Checksums = fmt.Sprintf("%[1]s  fake/v1.2.3/fake_1.2.3_Linux-64bit.tar.gz\n%[1]s  fake/v1.2.4/fake_1.2.3_Linux-64bit.tar.gz\n",
+		hex.EncodeToString(sum[:]))
+
+	path, err := f.installer.Install(context.Background(), f.tool)
+	require.NoError(t, err)
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, fakeBinary, string(contents))
+	assert.Equal(t, []string{"fake-v1.2.3"}, f.files())
+
+	f.tool.Version = "v1.2.4"
+	f.tool.ArchiveMember = "missing"
+	_, err = f.installer.Install(context.Background(), f.tool)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "extracting missing")
+	}
+	assert.Equal(t, []string{"fake-v1.2.3"}, f.files())
+}
+
+func TestInstallChecksumMismatch(t *testing.T) {
+	f := newFixture(t)
+	// The release asset changed since we pinned it.
+	f.installer.Checksums = "deadbeef  fake/v1.2.3/fake-linux-amd64\n"
+
+	_, err := f.installer.Install(context.Background(), f.tool)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "checksum mismatch")
+	}
+
+	// Nothing is left behind, so a retry starts over.
+	assert.Empty(t, f.files())
+}
+
+func TestInstallNoChecksum(t *testing.T) {
+	f := newFixture(t)
+	f.installer.GOOS = "darwin"
+
+	_, err := f.installer.Install(context.Background(), f.tool)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no pinned checksum for fake/v1.2.3/fake-darwin-amd64")
+	}
+	_, err = os.Stat(f.installer.Path(f.tool))
+	assert.True(t, os.IsNotExist(err))
+
+	// We never download a binary that we can't verify.
+	assert.Empty(t, f.requests)
+}
+
+func TestInstallUnsupportedPlatform(t *testing.T) {
+	f := newFixture(t)
+	f.installer.GOARCH = "s390x"
+
+	_, err := f.installer.Install(context.Background(), f.tool)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "fake v1.2.3 has no release binary for linux/s390x")
+	}
+	assert.Empty(t, f.requests)
+}
+
+func TestInstallNoEgress(t *testing.T) {
+	f := newFixture(t)
+	t.Setenv(egress.EnvVar, "true")
+	f.tool.BinaryURL = Kind.BinaryURL
+	f.installer.Checksums = "abcd  fake/v1.2.3/kind-linux-amd64\n"
+
+	_, err := f.installer.Install(context.Background(), f.tool)
+	if assert.Error(t, err) {
+		assert.True(t, egress.IsBlocked(err))
+	}
+	_, err = os.Stat(f.installer.Path(f.tool))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestParseChecksum(t *testing.T) {
+	sum, err := parseChecksum("# Pinned\nABCD  kind/v0.20.0/kind-linux-amd64\n", "kind/v0.20.0/kind-linux-amd64")
+	require.NoError(t, err)
+	assert.Equal(t, "abcd", sum)
+
+	sum, err = parseChecksum("1234 *kind/v0.20.0/kind-darwin-arm64\nabcd *kind/v0.20.0/kind-linux-amd64\n", "kind/v0.20.0/kind-linux-amd64")
+	require.NoError(t, err)
+	assert.Equal(t, "abcd", sum)
+
+	// The version has to match too.
+	_, err = parseChecksum("1234  kind/v0.19.0/kind-linux-amd64\n", "kind/v0.20.0/kind-linux-amd64")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no pinned checksum for kind/v0.20.0/kind-linux-amd64")
+	}
+}
+
+// Every platform of every tool has a checksum built in, or --auto-install
+// fails on it.
+func TestPinnedChecksums(t *testing.T) {
+	for _, tool := range []Tool{Kind, Trivy} {
+		for _, platform := range tool.Platforms {
+			goos, goarch, _ := strings.Cut(platform, "/")
+			sum, err := parseChecksum(pinnedChecksums, tool.checksumName(goos, goarch))
+			if assert.NoError(t, err, "Run make tool-checksums after changing the version of %s", tool.Name) {
+				assert.Regexp(t, "^[0-9a-f]{64}$", sum)
+			}
+		}
+	}
+}
+
+func TestChecksumName(t *testing.T) {
+	assert.Equal(t, "kind/v0.20.0/kind-darwin-arm64", Kind.checksumName("darwin", "arm64"))
+	assert.Equal(t, "trivy/v0.45.0/trivy_0.45.0_Linux-ARM64.tar.gz", Trivy.checksumName("linux", "arm64"))
+}
+
+func TestSupports(t *testing.T) {
+	assert.True(t, Supports(Kind, "v0.20.0"))
+	assert.True(t, Supports(Kind, "v0.15.0"))
+	assert.False(t, Supports(Kind, "v0.14.0"))
+	assert.False(t, Supports(Kind, "unknown"))
+	assert.True(t, Supports(Trivy, "0.45.0"))
+	assert.False(t, Supports(Trivy, "0.35.0"))
+}
+
+func TestVersionRe(t *testing.T) {
+	assert.Equal(t, "v0.20.0", versionRe.FindString("kind v0.20.0 go1.20.4 linux/amd64"))
+	assert.Equal(t, "0.45.0", versionRe.FindString("Version: 0.45.0\nVulnerability DB:\n  Version: 2\n"))
+}
+
+func TestTrivyURLs(t *testing.T) {
+	assert.Equal(t, "https://github.com/aquasecurity/trivy/releases/download/v0.45.0/trivy_0.45.0_macOS-ARM64.tar.gz",
+		Trivy.BinaryURL("darwin", "arm64"))
+	assert.Equal(t, "https://github.com/aquasecurity/trivy/releases/download/v0.45.0/trivy_0.45.0_Linux-64bit.tar.gz",
+		Trivy.BinaryURL("linux", "amd64"))
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	_ "embed"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// The source of the config types, so that we can explain fields with
+// their doc comments.
+//
+//go:embed types.go
+var typesSource string
+
+// FieldDoc describes a field of the cluster or registry config.
+type FieldDoc struct {
+	// The path to the field, e.g., cluster.networkCalico.version.
+	Path string
+
+	// The JSON name of the field.
+	Name string
+
+	// The Go type of the field, e.g., string or []HostEntry.
+	Type string
+
+	// The doc comment of the field.
+	Description string
+
+	// The fields of the field's type, if it's a struct type of this package.
+	Fields []FieldDoc
+}
+
+// Summary returns the first sentence of the field's description.
+func (d FieldDoc) Summary() string {
+	summary := strings.Join(strings.Fields(d.Description), " ")
+	if i := strings.Index(summary, ". "); i != -1 {
+		summary = summary[:i+1]
+	}
+	return summary
+}
+
+// The root types that Explain accepts, keyed by the lowercase kind.
+var explainKinds = map[string]string{
+	"cluster":  "Cluster",
+	"registry": "Registry",
+}
+
+// Explain describes the field at the given path, like `kubectl explain`.
+//
+// The path starts with a kind (cluster or registry), followed by JSON field
+// names, e.g., cluster.networkCalico.version. ctlptl configs don't nest
+// their fields under spec, but we skip a leading spec for people used
+// to Kubernetes objects.
+func Explain(path string) (FieldDoc, error) {
+	structs, err := parseStructs()
+	if err != nil {
+		return FieldDoc{}, err
+	}
+
+	parts := strings.Split(strings.TrimSpace(path), ".")
+	kind := strings.TrimSuffix(strings.ToLower(parts[0]), "s")
+	typeName, ok := explainKinds[kind]
+	if !ok {
+		return FieldDoc{}, fmt.Errorf("unknown kind %q. Supported: cluster, registry", parts[0])
+	}
+	parts = parts[1:]
+	if len(parts) > 0 && parts[0] == "spec" {
+		parts = parts[1:]
+	}
+
+	current := FieldDoc{
+		Path:        kind,
+		Name:        kind,
+		Type:        typeName,
+		Description: structs[typeName].doc,
+	}
+	for _, part := range parts {
+		st, ok := structs[elemTypeName(current.Type)]
+		if !ok {
+			return FieldDoc{}, fmt.Errorf("field %s has type %s, which has no fields that ctlptl documents",
+				current.Path, current.Type)
+		}
+		field, ok := st.field(part)
+		if !ok {
+			return FieldDoc{}, fmt.Errorf("field %s has no field %q. Fields: %s",
+				current.Path, part, strings.Join(st.fieldNames(), ", "))
+		}
+		current = FieldDoc{
+			Path:        current.Path + "." + field.name,
+			Name:        field.name,
+			Type:        field.typ,
+			Description: field.doc,
+		}
+	}
+
+	if st, ok := structs[elemTypeName(current.Type)]; ok {
+		for _, field := range st.fields {
+			current.Fields = append(current.Fields, FieldDoc{
+				Path:        current.Path + "." + field.name,
+				Name:        field.name,
+				Type:        field.typ,
+				Description: field.doc,
+			})
+		}
+	}
+	return current, nil
+}
+
+// The name of the type that a field holds, without pointers, slices, or
+// maps, e.g., HostEntry for []HostEntry.
+func elemTypeName(typ string) string {
+	for {
+		switch {
+		case strings.HasPrefix(typ, "*"):
+			typ = typ[1:]
+		case strings.HasPrefix(typ, "[]"):
+			typ = typ[2:]
+		case strings.HasPrefix(typ, "map["):
+			_, typ, _ = strings.Cut(typ, "]")
+		default:
+			return typ
+		}
+	}
+}
+
+type structDoc struct {
+	doc    string
+	fields []fieldDoc
+
+	// Embedded structs, whose fields JSON inlines.
+	embedded []string
+}
+
+type fieldDoc struct {
+	name string
+	typ  string
+	doc  string
+}
+
+var parsedStructs struct {
+	once    sync.Once
+	structs map[string]*structDoc
+	err     error
+}
+
+func parseStructs() (map[string]*structDoc, error) {
+	parsedStructs.once.Do(func() {
+		parsedStructs.structs, parsedStructs.err = parseStructsFrom(typesSource)
+	})
+	return parsedStructs.structs, parsedStructs.err
+}
+
+func parseStructsFrom(src string) (map[string]*structDoc, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "types.go", src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parsing config types: %v", err)
+	}
+
+	result := make(map[string]*structDoc)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok || !ts.Name.IsExported() {
+				continue
+			}
+			doc := gen.Doc
+			if ts.Doc != nil {
+				doc = ts.Doc
+			}
+			sd := &structDoc{doc: commentText(doc)}
+			for _, field := range st.Fields.List {
+				typ := types.ExprString(field.Type)
+				if len(field.Names) == 0 {
+					sd.embedded = append(sd.embedded, typ)
+					continue
+				}
+				name := jsonName(field)
+				if name == "" || !field.Names[0].IsExported() {
+					continue
+				}
+				sd.fields = append(sd.fields, fieldDoc{name: name, typ: typ, doc: commentText(field.Doc)})
+			}
+			result[ts.Name.Name] = sd
+		}
+	}
+
+	// Inline the fields of embedded structs, like TypeMeta.
+	for _, sd := range result {
+		var inlined []fieldDoc
+		for _, name := range sd.embedded {
+			if embedded, ok := result[name]; ok {
+				inlined = append(inlined, embedded.fields...)
+			}
+		}
+		sd.fields = append(inlined, sd.fields...)
+	}
+	return result, nil
+}
+
+func jsonName(field *ast.Field) string {
+	if field.Tag == nil {
+		return ""
+	}
+	tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
+	name, _, _ := strings.Cut(tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// The text of a doc comment, without the comment markers and
+// code generator directives.
+func commentText(group *ast.CommentGroup) string {
+	var lines []string
+	for _, line := range strings.Split(group.Text(), "\n") {
+		if strings.HasPrefix(line, "+") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func (s *structDoc) field(name string) (fieldDoc, bool) {
+	for _, f := range s.fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return fieldDoc{}, false
+}
+
+func (s *structDoc) fieldNames() []string {
+	names := make([]string, 0, len(s.fields))
+	for _, f := range s.fields {
+		names = append(names, f.name)
+	}
+	return names
+}
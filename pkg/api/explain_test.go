@@ -0,0 +1,70 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainField(t *testing.T) {
+	doc, err := Explain("cluster.networkCalico")
+	require.NoError(t, err)
+	assert.Equal(t, "cluster.networkCalico", doc.Path)
+	assert.Equal(t, "*CalicoSpec", doc.Type)
+	assert.Equal(t, "Replaces the default CNI with Calico, for testing NetworkPolicies against the same network plugin as production.",
+		doc.Summary())
+
+	var names []string
+	for _, field := range doc.Fields {
+		names = append(names, field.Name)
+	}
+	assert.Equal(t, []string{"version", "ipipMode", "vxlan", "calicoNodeResources"}, names)
+	assert.Equal(t, "cluster.networkCalico.version", doc.Fields[0].Path)
+}
+
+func TestExplainKind(t *testing.T) {
+	doc, err := Explain("registry")
+	require.NoError(t, err)
+	assert.Equal(t, "Registry", doc.Type)
+	require.True(t, len(doc.Fields) > 2)
+
+	// TypeMeta is inlined.
+	assert.Equal(t, "kind", doc.Fields[0].Name)
+	assert.Equal(t, "apiVersion", doc.Fields[1].Name)
+	assert.Equal(t, "name", doc.Fields[2].Name)
+}
+
+func TestExplainSkipsSpec(t *testing.T) {
+	doc, err := Explain("cluster.spec.registry")
+	require.NoError(t, err)
+	assert.Equal(t, "cluster.registry", doc.Path)
+	assert.Equal(t, "string", doc.Type)
+	assert.Empty(t, doc.Fields)
+}
+
+func TestExplainThroughLists(t *testing.T) {
+	doc, err := Explain("cluster.extraHosts.hostnames")
+	require.NoError(t, err)
+	assert.Equal(t, "[]string", doc.Type)
+}
+
+func TestExplainErrors(t *testing.T) {
+	_, err := Explain("pod.spec")
+	assert.EqualError(t, err, `unknown kind "pod". Supported: cluster, registry`)
+
+	_, err = Explain("cluster.nope")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `field cluster has no field "nope". Fields: kind, apiVersion, name,`)
+	}
+
+	_, err = Explain("cluster.kindV1Alpha4Cluster.nodes")
+	assert.EqualError(t, err,
+		"field cluster.kindV1Alpha4Cluster has type *v1alpha4.Cluster, which has no fields that ctlptl documents")
+}
+
+func TestCommentTextSkipsDirectives(t *testing.T) {
+	doc, err := Explain("cluster")
+	require.NoError(t, err)
+	assert.Equal(t, "Cluster contains cluster configuration.", doc.Description)
+}
@@ -44,3 +44,13 @@ func (obj *RegistryList) GroupVersionKind() schema.GroupVersionKind {
 }
 
 var _ runtime.Object = &RegistryList{}
+
+func (obj *List) GetObjectKind() schema.ObjectKind { return obj }
+func (obj *List) SetGroupVersionKind(gvk schema.GroupVersionKind) {
+	obj.APIVersion, obj.Kind = gvk.ToAPIVersionAndKind()
+}
+func (obj *List) GroupVersionKind() schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(obj.APIVersion, obj.Kind)
+}
+
+var _ runtime.Object = &List{}
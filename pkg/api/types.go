@@ -1,8 +1,16 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
 	"github.com/tilt-dev/localregistry-go"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
 )
 
@@ -13,6 +21,21 @@ type TypeMeta struct {
 	APIVersion string `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
 }
 
+// Set to "true" on clusters whose nodes and pods are simulated (e.g., kwok),
+// so that nobody mistakes them for clusters that can run workloads.
+const ClusterAnnotationSimulated = "ctlptl.dev/simulated"
+
+// The Git commit that was checked out when the cluster was created,
+// set by `ctlptl apply --annotate-git`.
+const (
+	ClusterAnnotationGitCommit  = "ctlptl.dev/git-commit"
+	ClusterAnnotationGitAuthor  = "ctlptl.dev/git-author"
+	ClusterAnnotationGitSubject = "ctlptl.dev/git-subject"
+)
+
+// The user who last applied the cluster, set by `ctlptl apply --owner`.
+const ClusterAnnotationOwner = "ctlptl.dev/owner"
+
 // Cluster contains cluster configuration.
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type Cluster struct {
@@ -21,9 +44,17 @@ type Cluster struct {
 	// The cluster name. Pulled from .kube/config.
 	Name string `json:"name,omitempty" yaml:"name,omitempty"`
 
+	// Key-value metadata about the cluster. Stored on the cluster itself.
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+
 	// The name of the tool used to create this cluster.
 	Product string `json:"product,omitempty" yaml:"product,omitempty"`
 
+	// The number of worker nodes.
+	//
+	// Only supported on clusters with product: kwok.
+	Workers int `json:"workers,omitempty" yaml:"workers,omitempty"`
+
 	// Make sure that the cluster has access to at least this many
 	// CPUs. This is mostly helpful for ensuring that your Docker Desktop
 	// VM has enough CPU. If ctlptl can't guarantee this many
@@ -47,6 +78,9 @@ type Cluster struct {
 	// Must start with 'v' and contain a major, minor, and patch version.
 	//
 	// Not all cluster products allow you to customize this.
+	//
+	// Changing it upgrades the cluster in place on products that support it
+	// (minikube). Otherwise, and on downgrades, ctlptl re-creates the cluster.
 	KubernetesVersion string `json:"kubernetesVersion,omitempty" yaml:"kubernetesVersion,omitempty"`
 
 	// The Kind cluster config. Only applicable for clusters with product: kind.
@@ -62,12 +96,446 @@ type Cluster struct {
 	// The Minikube cluster config. Only applicable for clusters with product: minikube.
 	Minikube *MinikubeCluster `json:"minikube,omitempty" yaml:"minikube,omitempty"`
 
+	// Options for how kind runs its node containers. Only applicable for
+	// clusters with product: kind.
+	//
+	// Useful on hosts where kind's defaults don't work, like rootless
+	// container runtimes or hardened images without systemd.
+	KindOptions *KindOptions `json:"kindOptions,omitempty" yaml:"kindOptions,omitempty"`
+
+	// Options for how k3d creates the cluster. Only applicable for
+	// clusters with product: k3d.
+	K3dOptions *K3dOptions `json:"k3dOptions,omitempty" yaml:"k3dOptions,omitempty"`
+
+	// Snapshots the cluster's etcd data to a directory on the host,
+	// so that it survives cluster deletion.
+	//
+	// Only supported on clusters with product: kind.
+	EtcdBackup *EtcdBackupSpec `json:"etcdBackup,omitempty" yaml:"etcdBackup,omitempty"`
+
+	// The apiserver URL to write into the kubeconfig, overriding the one
+	// the cluster product writes.
+	//
+	// Useful when the cluster is only reachable through a non-default address,
+	// like an SSH tunnel or a port-forward.
+	//
+	// Example: https://127.0.0.1:16443
+	KubeconfigServer string `json:"kubeconfigServer,omitempty" yaml:"kubeconfigServer,omitempty"`
+
+	// The node image for the control-plane nodes, for testing clusters where
+	// the control plane and the workers run different versions of Kubernetes.
+	//
+	// If only one of kindControlPlaneImage and kindWorkerImage is set, the other
+	// defaults to the same image. Nodes in the kind config that set their own
+	// image keep it.
+	//
+	// Only supported on clusters with product: kind or k3d. On k3d, only
+	// the control-plane image applies, because ctlptl creates k3d clusters
+	// without agent nodes. Changing it requires re-creating the cluster.
+	//
+	// Example: kindest/node:v1.29.2
+	KindControlPlaneImage string `json:"kindControlPlaneImage,omitempty" yaml:"kindControlPlaneImage,omitempty"`
+
+	// The node image for the worker nodes. See kindControlPlaneImage.
+	//
+	// Example: kindest/node:v1.28.7
+	KindWorkerImage string `json:"kindWorkerImage,omitempty" yaml:"kindWorkerImage,omitempty"`
+
+	// The container runtime that runs pods on the nodes. One of containerd
+	// (the default), docker, or crio.
+	//
+	// On minikube, passed as --container-runtime, unless minikube.containerRuntime
+	// is set. On k3d, configures k3s to use the runtime, but the default k3s
+	// images only include containerd, so docker and crio need a custom
+	// kindControlPlaneImage. Ignored on kind, which always uses containerd.
+	// Changing it requires re-creating the cluster.
+	ContainerRuntime string `json:"containerRuntime,omitempty" yaml:"containerRuntime,omitempty"`
+
+	// Extra hostnames and IPs to add to the apiserver's serving certificate,
+	// so that kubectl can verify it when the cluster is reached through
+	// something other than the default address (e.g., a kubeconfigServer
+	// or a remote Docker host).
+	//
+	// Only supported on clusters with product: kind. Changing it requires
+	// re-creating the cluster.
+	APIServerCertSANs []string `json:"apiServerCertSANs,omitempty" yaml:"apiServerCertSANs,omitempty"`
+
+	// Admission plugins to turn on in the apiserver, in addition to its
+	// defaults (e.g., PodSecurity, NodeRestriction, or
+	// ValidatingAdmissionWebhook for custom webhooks).
+	//
+	// Names are checked against the plugins built into the apiserver, and
+	// against kubernetesVersion, if set.
+	//
+	// Only supported on clusters with product: kind. Changing it requires
+	// re-creating the cluster.
+	EnableAdmissionPlugins []string `json:"enableAdmissionPlugins,omitempty" yaml:"enableAdmissionPlugins,omitempty"`
+
+	// Admission plugins to turn off in the apiserver, even if they're on
+	// by default.
+	//
+	// Only supported on clusters with product: kind. Changing it requires
+	// re-creating the cluster.
+	DisableAdmissionPlugins []string `json:"disableAdmissionPlugins,omitempty" yaml:"disableAdmissionPlugins,omitempty"`
+
+	// Encrypts resources in etcd (by default, Secrets) with a key that ctlptl
+	// generates, the way the apiserver's --encryption-provider-config does
+	// in production.
+	//
+	// Only supported on clusters with product: kind. Changing it requires
+	// re-creating the cluster, which generates a new key.
+	EncryptionAtRest *EncryptionAtRestSpec `json:"encryptionAtRest,omitempty" yaml:"encryptionAtRest,omitempty"`
+
+	// Extra command-line flags for the kubelet on every node, without the
+	// leading dashes (e.g., {"max-pods": "250"}).
+	//
+	// Only supported on clusters with product: kind or k3d. Changing it requires
+	// re-creating the cluster.
+	KubeletArgs map[string]string `json:"kubeletArgs,omitempty" yaml:"kubeletArgs,omitempty"`
+
+	// TOML fragments to add to the containerd config on every node, for
+	// containerd features that ctlptl doesn't have a field for (e.g., a
+	// snapshotter for lazy-pulling images).
+	//
+	// On kind, ctlptl appends them to /etc/containerd/config.toml after
+	// creating the cluster, and restarts containerd. On k3d, ctlptl passes
+	// them to k3s in a containerd config template, which needs a version
+	// of k3s that supports the "base" template.
+	//
+	// Only supported on clusters with product: kind or k3d. Changing it requires
+	// re-creating the cluster.
+	ContainerdConfigPatches []string `json:"containerdConfigPatches,omitempty" yaml:"containerdConfigPatches,omitempty"`
+
+	// Replaces the default CNI with Calico, for testing NetworkPolicies
+	// against the same network plugin as production.
+	//
+	// Only supported on clusters with product: kind. Changing it requires
+	// re-creating the cluster.
+	NetworkCalico *CalicoSpec `json:"networkCalico,omitempty" yaml:"networkCalico,omitempty"`
+
+	// A CNI to install in place of the product's default. One of:
+	//
+	// - cilium: installs the Cilium chart from https://helm.cilium.io in kube-system
+	//
+	// Only supported on clusters with product: kind. Changing it requires
+	// re-creating the cluster.
+	CNI string `json:"cni,omitempty" yaml:"cni,omitempty"`
+
+	// Installs MetalLB (https://metallb.universe.tf), so that Services with
+	// type: LoadBalancer get an external IP instead of staying <pending>.
+	//
+	// Only supported on clusters with product: kind. Can be changed without
+	// re-creating the cluster.
+	LoadBalancer *LoadBalancerSpec `json:"loadBalancer,omitempty" yaml:"loadBalancer,omitempty"`
+
+	// Helm charts to install or upgrade once the cluster is up, in order.
+	//
+	// Can be changed without re-creating the cluster.
+	// Removing a chart from the list doesn't uninstall its release.
+	HelmCharts []HelmChartSpec `json:"helmCharts,omitempty" yaml:"helmCharts,omitempty"`
+
+	// The namespace that kubectl uses by default in this cluster's context.
+	//
+	// ctlptl creates the namespace if it doesn't exist.
+	DefaultNamespace string `json:"defaultNamespace,omitempty" yaml:"defaultNamespace,omitempty"`
+
+	// Taints the control-plane nodes with node-role.kubernetes.io/control-plane:NoSchedule,
+	// like a production cluster, so that workloads only schedule on worker nodes.
+	//
+	// Can be toggled without re-creating the cluster.
+	TaintControlPlane bool `json:"taintControlPlane,omitempty" yaml:"taintControlPlane,omitempty"`
+
+	// Overrides the imagePullPolicy of every container in new pods. One of
+	// IfNotPresent, Never, or Always.
+	//
+	// Useful with IfNotPresent when images are loaded straight into the cluster,
+	// so that pods don't try to re-pull them, without editing every manifest.
+	//
+	// ctlptl installs Kyverno (https://kyverno.io) and a ClusterPolicy that mutates
+	// pods as they're created. Pods in kube-system and kyverno are left alone, and
+	// running pods aren't changed. Can be changed without re-creating the cluster.
+	DefaultImagePullPolicy string `json:"defaultImagePullPolicy,omitempty" yaml:"defaultImagePullPolicy,omitempty"`
+
+	// The rolling update strategy of new Deployments that don't set their own,
+	// for testing how workloads behave during rollouts with consistent defaults.
+	//
+	// Like defaultImagePullPolicy, ctlptl installs Kyverno and a ClusterPolicy,
+	// whose webhook mutates Deployments as they're created. Can be changed
+	// without re-creating the cluster.
+	RollingUpdateStrategy *RollingUpdateStrategySpec `json:"rollingUpdateStrategy,omitempty" yaml:"rollingUpdateStrategy,omitempty"`
+
+	// Workloads that must be ready before the cluster counts as ready, in
+	// addition to the apiserver answering (e.g., CoreDNS, or a CNI daemonset).
+	//
+	// `ctlptl get` reports their state in status.readinessChecks and the
+	// status.ready field selector, and `ctlptl apply --wait` blocks on them.
+	ReadinessChecks []ReadinessCheck `json:"readinessChecks,omitempty" yaml:"readinessChecks,omitempty"`
+
+	// How `ctlptl apply --wait` polls the cluster while it waits for it to be
+	// ready. The --wait-interval, --wait-backoff, and --wait-max-attempts
+	// flags take priority. Can be changed without re-creating the cluster.
+	ReadinessPoll *ReadinessPollSpec `json:"readinessPoll,omitempty" yaml:"readinessPoll,omitempty"`
+
+	// Service accounts to create after the cluster is up (e.g., for CI).
+	//
+	// Each apply creates any that are missing, and updates their bindings.
+	// Removing a service account from the list doesn't delete it.
+	ServiceAccounts []ServiceAccountSpec `json:"serviceAccounts,omitempty" yaml:"serviceAccounts,omitempty"`
+
+	// Installs the mirrord operator once the cluster is up, and writes a
+	// .mirrord/mirrord.json in the current directory that points at the cluster,
+	// so that local processes can mirror and steal the cluster's traffic.
+	//
+	// Requires the mirrord CLI. https://mirrord.dev/
+	MirrordEnabled bool `json:"mirrordEnabled,omitempty" yaml:"mirrordEnabled,omitempty"`
+
+	// Enables Hubble, Cilium's network observability, with its relay and UI.
+	//
+	// Requires cni: cilium. ctlptl passes the Cilium chart the values that
+	// enable Hubble, and waits for Hubble relay to be ready. Open the UI with
+	// `ctlptl hubble`. Can be changed without re-creating the cluster.
+	HubbleEnabled bool `json:"hubbleEnabled,omitempty" yaml:"hubbleEnabled,omitempty"`
+
+	// A storage driver to install for PersistentVolumeClaims, with a
+	// StorageClass named after it. One of:
+	//
+	// local-path: Pre-installed on kind and k3d. Nothing to install.
+	// openebs-hostpath: Installs the OpenEBS operator.
+	// longhorn: Installs Longhorn with Helm. Requires open-iscsi on each node.
+	//
+	// Doesn't change the default StorageClass, so PVCs that want the driver
+	// should set storageClassName. Can be changed without re-creating the
+	// cluster, but the old driver isn't removed.
+	PVCStorageDriver string `json:"pvcStorageDriver,omitempty" yaml:"pvcStorageDriver,omitempty"`
+
+	// The StorageClass to make the default, so that PVCs without a
+	// storageClassName use it (e.g., the one for pvcStorageDriver, or an NFS
+	// provisioner installed with helmCharts).
+	//
+	// ctlptl creates the StorageClass if it sets a provisioner, and takes the
+	// default annotation off the product's StorageClass. Can be changed without
+	// re-creating the cluster. Removing it leaves the current default as is.
+	DefaultStorageClass *DefaultStorageClassSpec `json:"defaultStorageClass,omitempty" yaml:"defaultStorageClass,omitempty"`
+
+	// Hostnames to point at 127.0.0.1 in /etc/hosts, like the hostnames of
+	// ingresses served on the cluster's host ports.
+	//
+	// ctlptl keeps them in a block of /etc/hosts that belongs to the cluster,
+	// and removes the block when the cluster is deleted. Updating /etc/hosts
+	// usually requires sudo. Without it, ctlptl prints the lines to add.
+	//
+	// /etc/hosts doesn't support wildcards, so list each hostname.
+	//
+	// Example: ["app.kind.local", "api.kind.local"]
+	Hosts []string `json:"hosts,omitempty" yaml:"hosts,omitempty"`
+
+	// Extra entries for /etc/hosts on each node container, like
+	// `docker run --add-host`. Useful for internal domains that aren't in
+	// public DNS. The IP may be host-gateway, which resolves to the gateway of
+	// the node's Docker network, so that pods can reach services on the host
+	// (e.g., a database on your laptop). Useful on Linux, where
+	// host.docker.internal doesn't resolve by default.
+	//
+	// ctlptl marks each line it writes with "# managed by ctlptl", and
+	// replaces the marked lines on each apply, so entries removed from the
+	// config are removed from the nodes. It also adds the entries to the
+	// CoreDNS hosts plugin, so that pods resolve them too. Only supported on
+	// kind and k3d. Re-applied on each apply, because Docker may rewrite
+	// /etc/hosts when a node restarts.
+	//
+	// Example: [{ip: host-gateway, hostnames: [host.docker.internal]},
+	//           {ip: 10.1.2.3, hostnames: [git.corp.example, wiki.corp.example]}]
+	ExtraHosts []HostEntry `json:"extraHosts,omitempty" yaml:"extraHosts,omitempty"`
+
+	// Estimates what the cluster's nodes would cost in a cloud provider after
+	// each apply, and warns if the estimate is over budget.
+	//
+	// A rough guide for teams that deploy to the cloud, not a quote.
+	CostBudget *CostBudgetSpec `json:"costBudget,omitempty" yaml:"costBudget,omitempty"`
+
 	// Most recently observed status of the cluster.
 	// Populated by the system.
 	// Read-only.
 	Status ClusterStatus `json:"status,omitempty" yaml:"status,omitempty"`
 }
 
+// LoadBalancerSpec describes how to hand out IPs to LoadBalancer services.
+type LoadBalancerSpec struct {
+	// The IPs that MetalLB may assign, as a range (172.19.255.200-172.19.255.250)
+	// or a CIDR (172.19.255.0/24). The IPs must be in the subnet of the Docker
+	// network that the cluster runs in, so that they're reachable from the host.
+	//
+	// If not specified, ctlptl picks 51 IPs at the top of the Docker network's subnet.
+	IPRange string `json:"ipRange,omitempty" yaml:"ipRange,omitempty"`
+}
+
+// DefaultStorageClassSpec describes the StorageClass to make the default.
+type DefaultStorageClassSpec struct {
+	// The name of the StorageClass.
+	Name string `json:"name" yaml:"name"`
+
+	// The provisioner of the StorageClass (e.g., rancher.io/local-path).
+	//
+	// If set, ctlptl creates the StorageClass when it doesn't exist.
+	// If not set, the StorageClass must already exist.
+	Provisioner string `json:"provisioner,omitempty" yaml:"provisioner,omitempty"`
+
+	// Parameters passed to the provisioner when ctlptl creates the StorageClass.
+	Parameters map[string]string `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+
+	// Delete or Retain. Defaults to Delete.
+	ReclaimPolicy string `json:"reclaimPolicy,omitempty" yaml:"reclaimPolicy,omitempty"`
+
+	// Immediate or WaitForFirstConsumer. Defaults to WaitForFirstConsumer,
+	// so that volumes are provisioned on the node where the pod runs.
+	VolumeBindingMode string `json:"volumeBindingMode,omitempty" yaml:"volumeBindingMode,omitempty"`
+}
+
+// HostEntry points hostnames at an IP, like a line of /etc/hosts.
+type HostEntry struct {
+	// An IP address, or host-gateway.
+	IP string `json:"ip" yaml:"ip"`
+
+	// The hostnames that resolve to the IP.
+	Hostnames []string `json:"hostnames" yaml:"hostnames"`
+}
+
+// CostBudgetSpec describes how much the cluster would be allowed to cost in the cloud.
+type CostBudgetSpec struct {
+	// The budget in US dollars per month. Apply warns if the estimate is higher.
+	MaxMonthlyCost float64 `json:"maxMonthlyCost,omitempty" yaml:"maxMonthlyCost,omitempty"`
+
+	// The cloud provider to price the nodes in. One of aws, gcp, or azure.
+	CloudProvider string `json:"cloudProvider" yaml:"cloudProvider"`
+}
+
+// RollingUpdateStrategySpec is the default rollout behavior of Deployments.
+//
+// Each field is a number of pods (1) or a percentage of the desired pods (25%),
+// like the fields of a Deployment's spec.strategy.rollingUpdate.
+type RollingUpdateStrategySpec struct {
+	// How many pods may be created over the desired number during a rollout.
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty" yaml:"maxSurge,omitempty"`
+
+	// How many pods may be unavailable during a rollout.
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty" yaml:"maxUnavailable,omitempty"`
+}
+
+// IntOrString only knows how to encode itself as JSON, so
+// RollingUpdateStrategySpec round-trips through JSON when encoded as YAML.
+type rollingUpdateStrategySpecJSON RollingUpdateStrategySpec
+
+func (s RollingUpdateStrategySpec) MarshalYAML() (interface{}, error) {
+	data, err := json.Marshal(rollingUpdateStrategySpecJSON(s))
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *RollingUpdateStrategySpec) UnmarshalYAML(node *yaml.Node) error {
+	var raw interface{}
+	err := node.Decode(&raw)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var result rollingUpdateStrategySpecJSON
+	err = decoder.Decode(&result)
+	if err != nil {
+		return fmt.Errorf("line %d: decoding rollingUpdateStrategy: %v", node.Line, err)
+	}
+	*s = RollingUpdateStrategySpec(result)
+	return nil
+}
+
+// ReadinessCheck names a workload that must be ready.
+type ReadinessCheck struct {
+	// The kind of workload. One of deployment, daemonset, or statefulset.
+	Kind string `json:"kind" yaml:"kind"`
+
+	// The name of the workload.
+	Name string `json:"name" yaml:"name"`
+
+	// The namespace of the workload. Defaults to default.
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+}
+
+// ReadinessPollSpec describes how often to check whether a cluster is ready.
+type ReadinessPollSpec struct {
+	// How long to wait after the first check, as a Go duration (e.g., 500ms).
+	// Defaults to 1s.
+	Interval string `json:"interval,omitempty" yaml:"interval,omitempty"`
+
+	// Multiplies the interval after each check that isn't ready (e.g., 1.5).
+	// Defaults to 1, which checks at a fixed interval.
+	Backoff float64 `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+
+	// Give up after this many checks, even if the --wait timeout hasn't
+	// passed. Defaults to 0, which checks until the timeout.
+	MaxAttempts int `json:"maxAttempts,omitempty" yaml:"maxAttempts,omitempty"`
+}
+
+// ServiceAccountSpec describes a service account and the access it gets.
+type ServiceAccountSpec struct {
+	// The name of the service account.
+	Name string `json:"name" yaml:"name"`
+
+	// The namespace of the service account. Created if it doesn't exist.
+	// Defaults to default.
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+
+	// The name of a ClusterRole to bind the service account to (e.g., edit).
+	ClusterRole string `json:"clusterRole,omitempty" yaml:"clusterRole,omitempty"`
+
+	// If set, each apply requests a token for the service account that
+	// expires after this many seconds, and reports it in
+	// status.serviceAccountTokens. Must be at least 600.
+	ExpireSeconds int `json:"expireSeconds,omitempty" yaml:"expireSeconds,omitempty"`
+}
+
+// HelmChartSpec describes a Helm release to install or upgrade.
+type HelmChartSpec struct {
+	// The name of the release. Defaults to the last path element of the chart.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// The chart to install: a chart name in the repo, an oci:// reference,
+	// or a path to a local chart.
+	//
+	// Example: ingress-nginx
+	Chart string `json:"chart" yaml:"chart"`
+
+	// The URL of the chart repository. Not needed for oci:// references or local charts.
+	//
+	// Example: https://kubernetes.github.io/ingress-nginx
+	Repo string `json:"repo,omitempty" yaml:"repo,omitempty"`
+
+	// The version of the chart. Defaults to the latest version.
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+
+	// The namespace of the release. Created if it doesn't exist.
+	// Defaults to the namespace of the cluster's kubeconfig context.
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+
+	// Paths to values files, applied in order.
+	ValuesFiles []string `json:"valuesFiles,omitempty" yaml:"valuesFiles,omitempty"`
+
+	// Values in key=value form, applied after the values files, like helm's --set.
+	//
+	// Example: ["controller.service.type=NodePort"]
+	Set []string `json:"set,omitempty" yaml:"set,omitempty"`
+}
+
 type ClusterStatus struct {
 	// When the cluster was first created.
 	CreationTimestamp metav1.Time `json:"creationTimestamp,omitempty" yaml:"creationTimestamp,omitempty"`
@@ -90,6 +558,59 @@ type ClusterStatus struct {
 	// v1.18.10-gke.601
 	// v1.19.3-34+fa32ff1c160058
 	KubernetesVersion string `json:"kubernetesVersion,omitempty" yaml:"kubernetesVersion,omitempty"`
+
+	// The range of IPs that services get their ClusterIPs from.
+	//
+	// Only reported once it's been read with `ctlptl service-cidr`
+	// (or the cluster controller's GetServiceCIDR).
+	ServiceCIDR string `json:"serviceCIDR,omitempty" yaml:"serviceCIDR,omitempty"`
+
+	// The imagePullPolicy that the installed admission policy sets on new pods.
+	DefaultImagePullPolicy string `json:"defaultImagePullPolicy,omitempty" yaml:"defaultImagePullPolicy,omitempty"`
+
+	// The name of the cluster's default StorageClass.
+	//
+	// Only reported on clusters with defaultStorageClass set.
+	DefaultStorageClass string `json:"defaultStorageClass,omitempty" yaml:"defaultStorageClass,omitempty"`
+
+	// The result of each of the cluster's readinessChecks.
+	ReadinessChecks []ReadinessCheckStatus `json:"readinessChecks,omitempty" yaml:"readinessChecks,omitempty"`
+
+	// Tokens requested for the cluster's serviceAccounts during apply.
+	//
+	// Only reported by apply, because tokens aren't stored anywhere.
+	// Use `ctlptl get-token` to request a new one.
+	ServiceAccountTokens []ServiceAccountToken `json:"serviceAccountTokens,omitempty" yaml:"serviceAccountTokens,omitempty"`
+
+	// The in-cluster URL of the mirrord operator, if mirrordEnabled is set
+	// and the operator is installed.
+	MirrordEndpoint string `json:"mirrordEndpoint,omitempty" yaml:"mirrordEndpoint,omitempty"`
+
+	// The cgroup version (1 or 2) of the host that runs the node containers.
+	//
+	// Only reported for products whose nodes are Docker containers (kind and k3d).
+	CgroupVersion string `json:"cgroupVersion,omitempty" yaml:"cgroupVersion,omitempty"`
+}
+
+// ServiceAccountToken is a time-limited token for a service account.
+type ServiceAccountToken struct {
+	Name      string `json:"name" yaml:"name"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Token     string `json:"token" yaml:"token"`
+
+	// When the token stops working.
+	ExpirationTimestamp metav1.Time `json:"expirationTimestamp,omitempty" yaml:"expirationTimestamp,omitempty"`
+}
+
+// ReadinessCheckStatus is the most recently observed result of a ReadinessCheck.
+type ReadinessCheckStatus struct {
+	ReadinessCheck `json:",inline" yaml:",inline"`
+
+	// Whether the workload has all of its replicas available and up to date.
+	Ready bool `json:"ready" yaml:"ready"`
+
+	// Why the workload isn't ready. Empty when it's ready.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
 }
 
 // MinikubeCluster describes minikube-specific options for starting a cluster.
@@ -118,14 +639,187 @@ type MinikubeCluster struct {
 	StartFlags []string `json:"startFlags,omitempty" yaml:"startFlags,omitempty"`
 }
 
+// KindOptions describes how kind runs its node containers.
+//
+// These are settings that kind reads from its flags and environment,
+// so they can't be expressed in the Kind cluster config.
+type KindOptions struct {
+	// The node image to boot every node with. Overrides the image chosen
+	// for the kubernetesVersion.
+	//
+	// Useful for custom node images that work without systemd, or images
+	// mirrored to a private registry.
+	NodeImage string `json:"nodeImage,omitempty" yaml:"nodeImage,omitempty"`
+
+	// The container runtime that kind runs the nodes in. One of
+	// docker (the default), podman, or nerdctl.
+	//
+	// Passed to kind as KIND_EXPERIMENTAL_PROVIDER.
+	Provider string `json:"provider,omitempty" yaml:"provider,omitempty"`
+
+	// Set to true when the container runtime runs in rootless mode.
+	//
+	// Rootless nodes need cgroup v2 on the host. ctlptl checks for this before
+	// creating the cluster, rather than letting kind fail midway through.
+	Rootless bool `json:"rootless,omitempty" yaml:"rootless,omitempty"`
+
+	// Whether to run the node containers privileged. Defaults to true,
+	// which is what kind needs to boot a node.
+	//
+	// To run unprivileged nodes, add back the capabilities that the node needs
+	// with capAdd (at least SYS_ADMIN and NET_ADMIN).
+	Privileged *bool `json:"privileged,omitempty" yaml:"privileged,omitempty"`
+
+	// Linux capabilities to add to unprivileged node containers (e.g., SYS_PTRACE).
+	// Privileged nodes already have every capability.
+	CapAdd []string `json:"capAdd,omitempty" yaml:"capAdd,omitempty"`
+
+	// Linux capabilities to drop from unprivileged node containers.
+	CapDrop []string `json:"capDrop,omitempty" yaml:"capDrop,omitempty"`
+
+	// Extra Docker security options for the node containers (e.g., label=type:container_t).
+	//
+	// Kind always runs nodes with seccomp=unconfined and apparmor=unconfined,
+	// so these can't be changed.
+	SecurityOpt []string `json:"securityOpt,omitempty" yaml:"securityOpt,omitempty"`
+
+	// The cgroup namespace of the node containers. One of private or host.
+	// Defaults to kind's choice, which is private.
+	//
+	// Node images without systemd may need the host's cgroups, e.g., on
+	// cgroup v1 hosts. Rootless nodes need private.
+	CgroupNS string `json:"cgroupns,omitempty" yaml:"cgroupns,omitempty"`
+}
+
+// K3dOptions describes how k3d creates the cluster.
+type K3dOptions struct {
+	// Has k3d create a registry along with the cluster, instead of using a
+	// registry that ctlptl manages.
+	//
+	// k3d configures every node to pull through it, and writes its host to the
+	// cluster's LocalRegistryHosting config. Images pushed to it stay cached
+	// across node restarts, and it's deleted with the cluster.
+	//
+	// Can't be combined with registry. Use one or the other.
+	EmbeddedRegistry *K3dEmbeddedRegistry `json:"embeddedRegistry,omitempty" yaml:"embeddedRegistry,omitempty"`
+}
+
+// K3dEmbeddedRegistry describes a registry that k3d manages.
+type K3dEmbeddedRegistry struct {
+	// The name of the registry container. k3d adds a k3d- prefix if missing.
+	//
+	// Defaults to the cluster name with a -registry suffix (e.g., k3d-my-cluster-registry).
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// The port that the registry listens on on the host.
+	//
+	// Defaults to a random port.
+	HostPort int `json:"hostPort,omitempty" yaml:"hostPort,omitempty"`
+}
+
+// EtcdBackupSpec describes where and when to snapshot etcd.
+type EtcdBackupSpec struct {
+	// The directory on the host where snapshots are stored.
+	//
+	// Mounted into the control-plane nodes when the cluster is created,
+	// so changing it requires re-creating the cluster.
+	HostPath string `json:"hostPath,omitempty" yaml:"hostPath,omitempty"`
+
+	// How often to take a snapshot, in cron syntax (e.g., "0 * * * *").
+	//
+	// If empty, snapshots are only taken by `ctlptl etcd backup`.
+	Schedule string `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+}
+
+// EncryptionAtRestSpec describes the apiserver's EncryptionConfiguration.
+//
+// ctlptl writes the config, with a new random key, to
+// ~/.ctlptl/encryption/CLUSTER.yaml, and mounts it into the control-plane
+// nodes. The file is removed when the cluster is deleted.
+type EncryptionAtRestSpec struct {
+	// The provider that encrypts new writes: aescbc, secretbox, or identity
+	// (which writes plain text, for testing migrations). Defaults to aescbc.
+	//
+	// The identity provider is always listed last, so that the apiserver
+	// can still read resources written before encryption was configured.
+	Provider string `json:"provider,omitempty" yaml:"provider,omitempty"`
+
+	// The resources to encrypt, as resource.group (e.g., secrets or
+	// configmaps). Defaults to secrets.
+	Resources []string `json:"resources,omitempty" yaml:"resources,omitempty"`
+}
+
+// CalicoSpec describes how to install Calico.
+//
+// The Calico IP pool uses the cluster's pod subnet, so that pod IPs match
+// what the cluster product configured.
+type CalicoSpec struct {
+	// The Calico release to install (e.g., 3.26.1).
+	//
+	// Defaults to a version that ctlptl has been tested with.
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+
+	// IP-in-IP encapsulation for the IP pool. One of Always (the default),
+	// CrossSubnet, or Never.
+	IPIPMode string `json:"ipipMode,omitempty" yaml:"ipipMode,omitempty"`
+
+	// Use VXLAN encapsulation instead of IP-in-IP. Useful on hosts that
+	// block IP-in-IP traffic.
+	VXLAN bool `json:"vxlan,omitempty" yaml:"vxlan,omitempty"`
+
+	// Resource requests and limits for the calico-node container.
+	CalicoNodeResources corev1.ResourceRequirements `json:"calicoNodeResources,omitempty" yaml:"calicoNodeResources,omitempty"`
+}
+
+// Resource quantities only know how to encode themselves as JSON,
+// so CalicoSpec round-trips through JSON when encoded as YAML.
+type calicoSpecJSON CalicoSpec
+
+func (s CalicoSpec) MarshalYAML() (interface{}, error) {
+	data, err := json.Marshal(calicoSpecJSON(s))
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, err
+	}
+	if resources, ok := result["calicoNodeResources"].(map[string]interface{}); ok && len(resources) == 0 {
+		delete(result, "calicoNodeResources")
+	}
+	return result, nil
+}
+
+func (s *CalicoSpec) UnmarshalYAML(node *yaml.Node) error {
+	var raw interface{}
+	err := node.Decode(&raw)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var result calicoSpecJSON
+	err = decoder.Decode(&result)
+	if err != nil {
+		return fmt.Errorf("line %d: decoding networkCalico: %v", node.Line, err)
+	}
+	*s = CalicoSpec(result)
+	return nil
+}
+
 // ClusterList is a list of Clusters.
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type ClusterList struct {
-	TypeMeta `json:",inline"`
+	TypeMeta `json:",inline" yaml:",inline"`
 
 	// List of clusters.
 	// More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md
-	Items []Cluster `json:"items" protobuf:"bytes,2,rep,name=items"`
+	Items []Cluster `json:"items" yaml:"items" protobuf:"bytes,2,rep,name=items"`
 }
 
 // Cluster contains registry configuration.
@@ -147,6 +841,14 @@ type Registry struct {
 	// or to preserve the existing port.
 	Port int `json:"port,omitempty" yaml:"port,omitempty"`
 
+	// An extra host port that forwards to the registry over the Docker network
+	// it's on, through a socat container on localhost.
+	//
+	// Unlike port, this reaches registries whose own port binding isn't
+	// reachable, like when Docker runs in a VM. The forwarder restarts with
+	// Docker, so it outlives ctlptl. Changing it doesn't re-create the registry.
+	ExposeOnHostPort int `json:"exposeOnHostPort,omitempty" yaml:"exposeOnHostPort,omitempty"`
+
 	// Labels that must be attached to the running registry.
 	//
 	// If you change the set of labels, the registry must be stopped and
@@ -164,12 +866,197 @@ type Registry struct {
 	// Defaults to `docker.io/library/registry:2`.
 	Image string `json:"image,omitempty" yaml:"image,omitempty"`
 
+	// The platform to run the registry container on, as OS/ARCH[/VARIANT]
+	// (e.g., linux/arm64). Passed to Docker when pulling and creating the
+	// container.
+	//
+	// Defaults to the Docker host's native platform. With the default image,
+	// must be one of the platforms that the image is published for.
+	// Changing it re-creates the registry, keeping its storage.
+	Platform string `json:"platform,omitempty" yaml:"platform,omitempty"`
+
+	// The number of registry containers to run. Defaults to 1.
+	//
+	// With more than 1, ctlptl runs the replicas on a shared storage volume,
+	// behind an nginx load balancer that takes the registry's name and port.
+	// Clusters connect to the load balancer. Changing it re-creates the registry.
+	ReplicaCount int `json:"replicaCount,omitempty" yaml:"replicaCount,omitempty"`
+
+	// The secret that the registry uses to sign upload state, passed to the
+	// registry as REGISTRY_HTTP_SECRET.
+	//
+	// Replicas of a registry behind a load balancer must share a secret, or
+	// uploads that hop between replicas fail. If not specified, ctlptl generates
+	// one, and keeps it when it re-creates the registry so that in-flight uploads
+	// survive. Changing it re-creates the registry.
+	//
+	// ctlptl never prints the secret, and doesn't report it in `ctlptl get`.
+	HTTPSecret string `json:"httpSecret,omitempty" yaml:"httpSecret,omitempty"`
+
+	// Docker networks to connect the registry to, in addition to the networks
+	// of the clusters that use it. ctlptl creates networks that don't exist.
+	//
+	// Networks can be added without re-creating the registry. ctlptl never
+	// disconnects the registry from a network.
+	Networks []string `json:"networks,omitempty" yaml:"networks,omitempty"`
+
+	// How the registry logs. Changing it re-creates the registry.
+	Log *RegistryLogSpec `json:"log,omitempty" yaml:"log,omitempty"`
+
+	// How much concurrent work the registry takes on. Changing it re-creates
+	// the registry.
+	Limits *RegistryLimitsSpec `json:"limits,omitempty" yaml:"limits,omitempty"`
+
+	// Requires clients to log in to the registry with a username and password
+	// (htpasswd auth).
+	//
+	// Changing the username or password re-creates the registry. To change the
+	// password of a running registry, use `ctlptl registry rotate-auth`.
+	Auth *RegistryAuthSpec `json:"auth,omitempty" yaml:"auth,omitempty"`
+
+	// Notifies a URL when images are pushed to (or deleted from) the
+	// registry, e.g., to start a rebuild pipeline.
+	//
+	// ctlptl writes a registry config with the webhook to
+	// ~/.ctlptl/registries/NAME-webhook.yml, readable only by you, and
+	// mounts it into the registry container.
+	//
+	// The registry only reads its config when it starts, and doesn't reload
+	// it on SIGHUP, so changing the webhook re-creates the registry. Its
+	// images are kept. Not supported with replicaCount greater than 1.
+	Webhook *RegistryWebhookSpec `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+
+	// How the registry cleans up uploads that never finished, like the ones
+	// that interrupted pushes leave behind.
+	//
+	// Defaults to purging uploads older than a week, once a day. Changing it
+	// re-creates the registry. Its images are kept.
+	UploadPurge *RegistryUploadPurgeSpec `json:"uploadPurge,omitempty" yaml:"uploadPurge,omitempty"`
+
+	// Hostnames to point at the registry in /etc/hosts, so that you can push
+	// to it as, e.g., registry.local:5000.
+	//
+	// ctlptl keeps them in a block of /etc/hosts that belongs to the registry,
+	// and removes the block when the registry is deleted. Updating /etc/hosts
+	// usually requires sudo. Without it, ctlptl prints the lines to add.
+	Hosts []string `json:"hosts,omitempty" yaml:"hosts,omitempty"`
+
 	// Most recently observed status of the registry.
 	// Populated by the system.
 	// Read-only.
 	Status RegistryStatus `json:"status,omitempty" yaml:"status,omitempty"`
 }
 
+// RegistryLogSpec configures the registry's logs.
+//
+// Maps onto the registry's REGISTRY_LOG_* environment variables.
+type RegistryLogSpec struct {
+	// The log level. One of error, warn, info, or debug. Defaults to info.
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+
+	// The log format. One of text, json, or logstash. Defaults to text.
+	Formatter string `json:"formatter,omitempty" yaml:"formatter,omitempty"`
+
+	// If true, the registry doesn't log each request it serves.
+	AccessLogDisabled bool `json:"accessLogDisabled,omitempty" yaml:"accessLogDisabled,omitempty"`
+
+	// An absolute path to a file on the Docker host to append logs to, in
+	// addition to the container logs. The directory is created if it
+	// doesn't exist.
+	//
+	// Requires an image with /bin/sh and tee (like the default image), and
+	// isn't supported with replicaCount greater than 1.
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+}
+
+// RegistryLimitsSpec tunes the registry for many concurrent pulls and pushes,
+// like when every node of a large cluster pulls its images at boot.
+//
+// For clusters with more than 10 or so nodes, try maxThreads: 200 and
+// maxConnections: 16384. With replicaCount, the limits apply to each replica.
+type RegistryLimitsSpec struct {
+	// The most storage operations the registry runs at once, passed to the
+	// registry as REGISTRY_STORAGE_FILESYSTEM_MAXTHREADS. Requests past the
+	// limit wait for a free thread.
+	//
+	// Must be at least 25. The registry defaults to 100.
+	MaxThreads int `json:"maxThreads,omitempty" yaml:"maxThreads,omitempty"`
+
+	// The most open connections the registry can hold, set as the nofile
+	// ulimit of the registry container. Each connection (and each open blob)
+	// takes a file descriptor.
+	//
+	// Must be at least 1024. Defaults to the Docker daemon's default ulimit.
+	MaxConnections int `json:"maxConnections,omitempty" yaml:"maxConnections,omitempty"`
+}
+
+// RegistryUploadPurgeSpec configures how the registry deletes stale uploads,
+// passed to the registry as its storage.maintenance.uploadpurging config.
+//
+// A push that's interrupted leaves its upload in the registry's storage, where
+// it takes up disk space until it's purged. Registries with persistent
+// storage, like the ones ctlptl creates, slowly fill up without purging.
+type RegistryUploadPurgeSpec struct {
+	// Whether the registry purges stale uploads. Defaults to true.
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+
+	// How old an upload must be before it's purged, as a duration
+	// (e.g., 24h). Uploads younger than this may still be in progress.
+	//
+	// Must be at least 1m. Defaults to 168h.
+	Age string `json:"age,omitempty" yaml:"age,omitempty"`
+
+	// How often the registry purges stale uploads, as a duration (e.g., 12h).
+	// The first purge runs within an hour of the registry starting.
+	//
+	// Must be at least 1m. Defaults to 24h.
+	Interval string `json:"interval,omitempty" yaml:"interval,omitempty"`
+}
+
+// RegistryAuthSpec configures the credentials that the registry accepts.
+type RegistryAuthSpec struct {
+	// The username that clients log in with.
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+
+	// The password that clients log in with. If not specified, ctlptl
+	// generates one, and keeps it when it re-creates the registry.
+	//
+	// Whenever ctlptl sets a new password, it logs Docker in to the registry,
+	// so that docker push and pull keep working.
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+
+	// Where to read the password from when the registry is applied, so that
+	// it doesn't have to be in the config. Either env:NAME, for an
+	// environment variable, or file:PATH, for a file whose trailing newline
+	// is ignored. Can't be combined with password.
+	//
+	// The registry status shows where the password came from when the
+	// registry was created, never the password itself.
+	PasswordFrom string `json:"passwordFrom,omitempty" yaml:"passwordFrom,omitempty"`
+}
+
+// RegistryWebhookSpec configures an endpoint in the registry's
+// notifications config.
+//
+// The registry POSTs a JSON envelope of events
+// (application/vnd.docker.distribution.events.v1+json) to the URL,
+// and retries until the endpoint answers with a 2xx or 3xx status.
+type RegistryWebhookSpec struct {
+	// The URL to POST events to. The registry runs in a container, so use
+	// host.docker.internal (not localhost) to reach a server on the host.
+	URL string `json:"url" yaml:"url"`
+
+	// The actions to send events for. Any of push, pull, mount, or delete.
+	// Defaults to push.
+	Events []string `json:"events,omitempty" yaml:"events,omitempty"`
+
+	// Headers to send with each request (e.g., Authorization).
+	//
+	// ctlptl doesn't report them in `ctlptl get`, and masks their values
+	// in `ctlptl apply --dry-run`.
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
 type RegistryStatus struct {
 	// When the registry was first created.
 	CreationTimestamp metav1.Time `json:"creationTimestamp,omitempty" yaml:"creationTimestamp,omitempty"`
@@ -198,21 +1085,51 @@ type RegistryStatus struct {
 	// Current health status of the registry container.
 	// Reflects underlying ContainerState.Status
 	// https://github.com/moby/moby/blob/v20.10.3/api/types/types.go#L314
-	State string
+	State string `json:"state,omitempty" yaml:"state,omitempty"`
 
 	// Labels attached to the running container.
 	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
 
 	// Image for the running container.
 	Image string `json:"image,omitempty" yaml:"image,omitempty"`
+
+	// The platform of the running container. Only reports platforms that
+	// ctlptl set.
+	Platform string `json:"platform,omitempty" yaml:"platform,omitempty"`
+
+	// The log config of the running container.
+	Log *RegistryLogSpec `json:"log,omitempty" yaml:"log,omitempty"`
+
+	// The limits of the running container. Only reports limits that ctlptl set.
+	Limits *RegistryLimitsSpec `json:"limits,omitempty" yaml:"limits,omitempty"`
+
+	// The auth config of the running container. Never includes the password.
+	Auth *RegistryAuthSpec `json:"auth,omitempty" yaml:"auth,omitempty"`
+
+	// The webhook of the running container. Never includes the headers.
+	Webhook *RegistryWebhookSpec `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+
+	// The upload purge config of the running container, with the defaults
+	// filled in. Empty if an older version of ctlptl created the registry.
+	UploadPurge *RegistryUploadPurgeSpec `json:"uploadPurge,omitempty" yaml:"uploadPurge,omitempty"`
 }
 
 // RegistryList is a list of Registrys.
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type RegistryList struct {
-	TypeMeta `json:",inline"`
+	TypeMeta `json:",inline" yaml:",inline"`
 
 	// List of registrys.
 	// More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md
-	Items []Registry `json:"items" protobuf:"bytes,2,rep,name=items"`
+	Items []Registry `json:"items" yaml:"items" protobuf:"bytes,2,rep,name=items"`
+}
+
+// List is a list of clusters and registries together,
+// like the output of `ctlptl get all`.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type List struct {
+	TypeMeta `json:",inline" yaml:",inline"`
+
+	// Clusters and registries, each with its own kind.
+	Items []runtime.Object `json:"items" yaml:"items"`
 }
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterDeepCopyKindOptions(t *testing.T) {
+	privileged := false
+	c := &Cluster{
+		KindOptions: &KindOptions{
+			Privileged:  &privileged,
+			CapAdd:      []string{"SYS_ADMIN"},
+			CapDrop:     []string{"NET_RAW"},
+			SecurityOpt: []string{"label=type:container_t"},
+		},
+	}
+
+	copied := c.DeepCopy()
+	*copied.KindOptions.Privileged = true
+	copied.KindOptions.CapAdd[0] = "NET_ADMIN"
+	copied.KindOptions.CapDrop[0] = "MKNOD"
+	copied.KindOptions.SecurityOpt[0] = "no-new-privileges"
+
+	assert.False(t, *c.KindOptions.Privileged)
+	assert.Equal(t, []string{"SYS_ADMIN"}, c.KindOptions.CapAdd)
+	assert.Equal(t, []string{"NET_RAW"}, c.KindOptions.CapDrop)
+	assert.Equal(t, []string{"label=type:container_t"}, c.KindOptions.SecurityOpt)
+}
@@ -24,13 +24,38 @@ package api
 import (
 	localregistrygo "github.com/tilt-dev/localregistry-go"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
 	v1alpha4 "sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CalicoSpec) DeepCopyInto(out *CalicoSpec) {
+	*out = *in
+	in.CalicoNodeResources.DeepCopyInto(&out.CalicoNodeResources)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CalicoSpec.
+func (in *CalicoSpec) DeepCopy() *CalicoSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CalicoSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Cluster) DeepCopyInto(out *Cluster) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.KindV1Alpha4Cluster != nil {
 		in, out := &in.KindV1Alpha4Cluster, &out.KindV1Alpha4Cluster
 		*out = new(v1alpha4.Cluster)
@@ -41,6 +66,112 @@ func (in *Cluster) DeepCopyInto(out *Cluster) {
 		*out = new(MinikubeCluster)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.KindOptions != nil {
+		in, out := &in.KindOptions, &out.KindOptions
+		*out = new(KindOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.K3dOptions != nil {
+		in, out := &in.K3dOptions, &out.K3dOptions
+		*out = new(K3dOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EtcdBackup != nil {
+		in, out := &in.EtcdBackup, &out.EtcdBackup
+		*out = new(EtcdBackupSpec)
+		**out = **in
+	}
+	if in.APIServerCertSANs != nil {
+		in, out := &in.APIServerCertSANs, &out.APIServerCertSANs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnableAdmissionPlugins != nil {
+		in, out := &in.EnableAdmissionPlugins, &out.EnableAdmissionPlugins
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DisableAdmissionPlugins != nil {
+		in, out := &in.DisableAdmissionPlugins, &out.DisableAdmissionPlugins
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EncryptionAtRest != nil {
+		in, out := &in.EncryptionAtRest, &out.EncryptionAtRest
+		*out = new(EncryptionAtRestSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KubeletArgs != nil {
+		in, out := &in.KubeletArgs, &out.KubeletArgs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ContainerdConfigPatches != nil {
+		in, out := &in.ContainerdConfigPatches, &out.ContainerdConfigPatches
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NetworkCalico != nil {
+		in, out := &in.NetworkCalico, &out.NetworkCalico
+		*out = new(CalicoSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LoadBalancer != nil {
+		in, out := &in.LoadBalancer, &out.LoadBalancer
+		*out = new(LoadBalancerSpec)
+		**out = **in
+	}
+	if in.HelmCharts != nil {
+		in, out := &in.HelmCharts, &out.HelmCharts
+		*out = make([]HelmChartSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RollingUpdateStrategy != nil {
+		in, out := &in.RollingUpdateStrategy, &out.RollingUpdateStrategy
+		*out = new(RollingUpdateStrategySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadinessChecks != nil {
+		in, out := &in.ReadinessChecks, &out.ReadinessChecks
+		*out = make([]ReadinessCheck, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReadinessPoll != nil {
+		in, out := &in.ReadinessPoll, &out.ReadinessPoll
+		*out = new(ReadinessPollSpec)
+		**out = **in
+	}
+	if in.ServiceAccounts != nil {
+		in, out := &in.ServiceAccounts, &out.ServiceAccounts
+		*out = make([]ServiceAccountSpec, len(*in))
+		copy(*out, *in)
+	}
+	if in.DefaultStorageClass != nil {
+		in, out := &in.DefaultStorageClass, &out.DefaultStorageClass
+		*out = new(DefaultStorageClassSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExtraHosts != nil {
+		in, out := &in.ExtraHosts, &out.ExtraHosts
+		*out = make([]HostEntry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CostBudget != nil {
+		in, out := &in.CostBudget, &out.CostBudget
+		*out = new(CostBudgetSpec)
+		**out = **in
+	}
 	in.Status.DeepCopyInto(&out.Status)
 	return
 }
@@ -104,6 +235,18 @@ func (in *ClusterStatus) DeepCopyInto(out *ClusterStatus) {
 		*out = new(localregistrygo.LocalRegistryHostingV1)
 		**out = **in
 	}
+	if in.ReadinessChecks != nil {
+		in, out := &in.ReadinessChecks, &out.ReadinessChecks
+		*out = make([]ReadinessCheckStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.ServiceAccountTokens != nil {
+		in, out := &in.ServiceAccountTokens, &out.ServiceAccountTokens
+		*out = make([]ServiceAccountToken, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -117,6 +260,275 @@ func (in *ClusterStatus) DeepCopy() *ClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CostBudgetSpec) DeepCopyInto(out *CostBudgetSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CostBudgetSpec.
+func (in *CostBudgetSpec) DeepCopy() *CostBudgetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CostBudgetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DefaultStorageClassSpec) DeepCopyInto(out *DefaultStorageClassSpec) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DefaultStorageClassSpec.
+func (in *DefaultStorageClassSpec) DeepCopy() *DefaultStorageClassSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DefaultStorageClassSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EncryptionAtRestSpec) DeepCopyInto(out *EncryptionAtRestSpec) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EncryptionAtRestSpec.
+func (in *EncryptionAtRestSpec) DeepCopy() *EncryptionAtRestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EncryptionAtRestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EtcdBackupSpec) DeepCopyInto(out *EtcdBackupSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdBackupSpec.
+func (in *EtcdBackupSpec) DeepCopy() *EtcdBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EtcdBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FieldDoc) DeepCopyInto(out *FieldDoc) {
+	*out = *in
+	if in.Fields != nil {
+		in, out := &in.Fields, &out.Fields
+		*out = make([]FieldDoc, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FieldDoc.
+func (in *FieldDoc) DeepCopy() *FieldDoc {
+	if in == nil {
+		return nil
+	}
+	out := new(FieldDoc)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmChartSpec) DeepCopyInto(out *HelmChartSpec) {
+	*out = *in
+	if in.ValuesFiles != nil {
+		in, out := &in.ValuesFiles, &out.ValuesFiles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Set != nil {
+		in, out := &in.Set, &out.Set
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmChartSpec.
+func (in *HelmChartSpec) DeepCopy() *HelmChartSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmChartSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostEntry) DeepCopyInto(out *HostEntry) {
+	*out = *in
+	if in.Hostnames != nil {
+		in, out := &in.Hostnames, &out.Hostnames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostEntry.
+func (in *HostEntry) DeepCopy() *HostEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(HostEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *K3dEmbeddedRegistry) DeepCopyInto(out *K3dEmbeddedRegistry) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3dEmbeddedRegistry.
+func (in *K3dEmbeddedRegistry) DeepCopy() *K3dEmbeddedRegistry {
+	if in == nil {
+		return nil
+	}
+	out := new(K3dEmbeddedRegistry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *K3dOptions) DeepCopyInto(out *K3dOptions) {
+	*out = *in
+	if in.EmbeddedRegistry != nil {
+		in, out := &in.EmbeddedRegistry, &out.EmbeddedRegistry
+		*out = new(K3dEmbeddedRegistry)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K3dOptions.
+func (in *K3dOptions) DeepCopy() *K3dOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(K3dOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KindOptions) DeepCopyInto(out *KindOptions) {
+	*out = *in
+	if in.Privileged != nil {
+		in, out := &in.Privileged, &out.Privileged
+		*out = new(bool)
+		**out = **in
+	}
+	if in.CapAdd != nil {
+		in, out := &in.CapAdd, &out.CapAdd
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CapDrop != nil {
+		in, out := &in.CapDrop, &out.CapDrop
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityOpt != nil {
+		in, out := &in.SecurityOpt, &out.SecurityOpt
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KindOptions.
+func (in *KindOptions) DeepCopy() *KindOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(KindOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *List) DeepCopyInto(out *List) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]runtime.Object, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				(*out)[i] = (*in)[i].DeepCopyObject()
+			}
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new List.
+func (in *List) DeepCopy() *List {
+	if in == nil {
+		return nil
+	}
+	out := new(List)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *List) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerSpec) DeepCopyInto(out *LoadBalancerSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerSpec.
+func (in *LoadBalancerSpec) DeepCopy() *LoadBalancerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MinikubeCluster) DeepCopyInto(out *MinikubeCluster) {
 	*out = *in
@@ -143,6 +555,55 @@ func (in *MinikubeCluster) DeepCopy() *MinikubeCluster {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReadinessCheck) DeepCopyInto(out *ReadinessCheck) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReadinessCheck.
+func (in *ReadinessCheck) DeepCopy() *ReadinessCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(ReadinessCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReadinessCheckStatus) DeepCopyInto(out *ReadinessCheckStatus) {
+	*out = *in
+	out.ReadinessCheck = in.ReadinessCheck
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReadinessCheckStatus.
+func (in *ReadinessCheckStatus) DeepCopy() *ReadinessCheckStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ReadinessCheckStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReadinessPollSpec) DeepCopyInto(out *ReadinessPollSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReadinessPollSpec.
+func (in *ReadinessPollSpec) DeepCopy() *ReadinessPollSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ReadinessPollSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Registry) DeepCopyInto(out *Registry) {
 	*out = *in
@@ -154,6 +615,41 @@ func (in *Registry) DeepCopyInto(out *Registry) {
 			(*out)[key] = val
 		}
 	}
+	if in.Networks != nil {
+		in, out := &in.Networks, &out.Networks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Log != nil {
+		in, out := &in.Log, &out.Log
+		*out = new(RegistryLogSpec)
+		**out = **in
+	}
+	if in.Limits != nil {
+		in, out := &in.Limits, &out.Limits
+		*out = new(RegistryLimitsSpec)
+		**out = **in
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(RegistryAuthSpec)
+		**out = **in
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(RegistryWebhookSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UploadPurge != nil {
+		in, out := &in.UploadPurge, &out.UploadPurge
+		*out = new(RegistryUploadPurgeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.Status.DeepCopyInto(&out.Status)
 	return
 }
@@ -176,6 +672,38 @@ func (in *Registry) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryAuthSpec) DeepCopyInto(out *RegistryAuthSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryAuthSpec.
+func (in *RegistryAuthSpec) DeepCopy() *RegistryAuthSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryAuthSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryLimitsSpec) DeepCopyInto(out *RegistryLimitsSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryLimitsSpec.
+func (in *RegistryLimitsSpec) DeepCopy() *RegistryLimitsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryLimitsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RegistryList) DeepCopyInto(out *RegistryList) {
 	*out = *in
@@ -208,6 +736,22 @@ func (in *RegistryList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryLogSpec) DeepCopyInto(out *RegistryLogSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryLogSpec.
+func (in *RegistryLogSpec) DeepCopy() *RegistryLogSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryLogSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RegistryStatus) DeepCopyInto(out *RegistryStatus) {
 	*out = *in
@@ -224,6 +768,31 @@ func (in *RegistryStatus) DeepCopyInto(out *RegistryStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.Log != nil {
+		in, out := &in.Log, &out.Log
+		*out = new(RegistryLogSpec)
+		**out = **in
+	}
+	if in.Limits != nil {
+		in, out := &in.Limits, &out.Limits
+		*out = new(RegistryLimitsSpec)
+		**out = **in
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(RegistryAuthSpec)
+		**out = **in
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(RegistryWebhookSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UploadPurge != nil {
+		in, out := &in.UploadPurge, &out.UploadPurge
+		*out = new(RegistryUploadPurgeSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -237,6 +806,114 @@ func (in *RegistryStatus) DeepCopy() *RegistryStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryUploadPurgeSpec) DeepCopyInto(out *RegistryUploadPurgeSpec) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryUploadPurgeSpec.
+func (in *RegistryUploadPurgeSpec) DeepCopy() *RegistryUploadPurgeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryUploadPurgeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryWebhookSpec) DeepCopyInto(out *RegistryWebhookSpec) {
+	*out = *in
+	if in.Events != nil {
+		in, out := &in.Events, &out.Events
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryWebhookSpec.
+func (in *RegistryWebhookSpec) DeepCopy() *RegistryWebhookSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryWebhookSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollingUpdateStrategySpec) DeepCopyInto(out *RollingUpdateStrategySpec) {
+	*out = *in
+	if in.MaxSurge != nil {
+		in, out := &in.MaxSurge, &out.MaxSurge
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollingUpdateStrategySpec.
+func (in *RollingUpdateStrategySpec) DeepCopy() *RollingUpdateStrategySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RollingUpdateStrategySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAccountSpec) DeepCopyInto(out *ServiceAccountSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAccountSpec.
+func (in *ServiceAccountSpec) DeepCopy() *ServiceAccountSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAccountSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAccountToken) DeepCopyInto(out *ServiceAccountToken) {
+	*out = *in
+	in.ExpirationTimestamp.DeepCopyInto(&out.ExpirationTimestamp)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAccountToken.
+func (in *ServiceAccountToken) DeepCopy() *ServiceAccountToken {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAccountToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TypeMeta) DeepCopyInto(out *TypeMeta) {
 	*out = *in
@@ -2,12 +2,16 @@ package cluster
 
 import (
 	"context"
+	"errors"
 
 	"github.com/tilt-dev/localregistry-go"
 
 	"github.com/tilt-dev/ctlptl/pkg/api"
 )
 
+// ErrNotSupported is returned by admins that can't do an operation in place.
+var ErrNotSupported = errors.New("not supported")
+
 // A cluster admin provides the basic start/stop functionality of a cluster,
 // independent of the configuration of the machine it's running on.
 type Admin interface {
@@ -17,6 +21,13 @@ type Admin interface {
 	// Infers the LocalRegistryHosting that this admin will try to configure.
 	LocalRegistryHosting(ctx context.Context, desired *api.Cluster, registry *api.Registry) (*localregistry.LocalRegistryHostingV1, error)
 
+	// Upgrades the Kubernetes version of a running cluster to
+	// desired.KubernetesVersion, keeping its workloads.
+	//
+	// Returns ErrNotSupported if the product can't upgrade in place,
+	// in which case the controller deletes and re-creates the cluster.
+	Upgrade(ctx context.Context, desired *api.Cluster) error
+
 	Delete(ctx context.Context, config *api.Cluster) error
 }
 
@@ -25,3 +36,17 @@ type Admin interface {
 type AdminInContainer interface {
 	ModifyConfigInContainer(ctx context.Context, cluster *api.Cluster, containerID string, dockerClient dockerClient, configWriter configWriter) error
 }
+
+// An extension of cluster admin that indicates the admin generates config files
+// for the underlying cluster tool.
+type AdminWithGeneratedConfig interface {
+	// Returns the generated files, keyed by file name.
+	GeneratedConfig(ctx context.Context, desired *api.Cluster, registry *api.Registry) (map[string][]byte, error)
+}
+
+// An extension of cluster admin that indicates the admin can point a running
+// cluster at a different registry, without re-creating it.
+type AdminWithRegistrySwitch interface {
+	// oldRegistry is nil if the cluster isn't connected to a registry.
+	SwitchRegistry(ctx context.Context, cluster *api.Cluster, oldRegistry, newRegistry *api.Registry) error
+}
@@ -47,6 +47,12 @@ func (a *dockerDesktopAdmin) LocalRegistryHosting(ctx context.Context, desired *
 	return nil, nil
 }
 
+// Docker Desktop decides its own Kubernetes version, and there's no way to change it
+// from outside Docker Desktop.
+func (a *dockerDesktopAdmin) Upgrade(ctx context.Context, desired *api.Cluster) error {
+	return ErrNotSupported
+}
+
 func (a *dockerDesktopAdmin) Delete(ctx context.Context, config *api.Cluster) error {
 	isLocalDockerHost := docker.IsLocalDockerDesktop(a.host, a.os)
 	if !isLocalDockerHost {
@@ -0,0 +1,218 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/tilt-dev/clusterid"
+	"github.com/tilt-dev/localregistry-go"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// An external cluster is an existing kubeconfig context that ctlptl tracks,
+// but didn't create, like a remote dev cluster.
+//
+// ctlptl only probes whether it's reachable, and switches to it. Deleting
+// it only removes the reference.
+const ProductExternal clusterid.Product = "external"
+
+// The file that lists the kubeconfig contexts referenced as external clusters.
+//
+// Returns an empty string if there's no home directory, which disables
+// external clusters.
+func defaultExternalClustersPath() string {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, ".ctlptl", "external-clusters.json")
+}
+
+// Reads the contexts referenced as external clusters.
+func readExternalContexts(path string) (map[string]bool, error) {
+	result := make(map[string]bool)
+	if path == "" {
+		return result, nil
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+
+	var contexts []string
+	err = json.Unmarshal(contents, &contexts)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	for _, ct := range contexts {
+		result[ct] = true
+	}
+	return result, nil
+}
+
+func writeExternalContexts(path string, contexts map[string]bool) error {
+	if path == "" {
+		return fmt.Errorf("external clusters need a home directory to store their references")
+	}
+	list := make([]string, 0, len(contexts))
+	for ct := range contexts {
+		list = append(list, ct)
+	}
+	sort.Strings(list)
+
+	contents, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(contents, '\n'), 0644)
+}
+
+// The contexts referenced as external clusters. If the file is unreadable,
+// treats every context as a normal cluster.
+func (c *Controller) externalContexts() map[string]bool {
+	contexts, err := readExternalContexts(c.externalClustersPath)
+	if err != nil {
+		klog.V(4).Infof("WARNING: reading external clusters: %v\n", err)
+		return make(map[string]bool)
+	}
+	return contexts
+}
+
+// Like productFromContext, but also recognizes external clusters.
+func productOfContext(external map[string]bool, contextName string, ct *clientcmdapi.Context, cl *clientcmdapi.Cluster) clusterid.Product {
+	if external[contextName] {
+		return ProductExternal
+	}
+	return productFromContext(ct, cl)
+}
+
+func validateExternal(desired *api.Cluster) error {
+	if desired.Name == "" {
+		return fmt.Errorf("product: external needs the name of an existing kubeconfig context")
+	}
+	rest := desired.DeepCopy()
+	rest.TypeMeta = typeMeta
+	rest.Name = ""
+	rest.Product = ""
+	rest.Status = api.ClusterStatus{}
+
+	// ctlptl apply records the owner, which it keeps in the state file.
+	delete(rest.Annotations, api.ClusterAnnotationOwner)
+	if len(rest.Annotations) == 0 {
+		rest.Annotations = nil
+	}
+	if !reflect.DeepEqual(rest, &api.Cluster{TypeMeta: typeMeta}) {
+		return fmt.Errorf("product: external only supports the name field, because ctlptl doesn't manage the cluster")
+	}
+	return nil
+}
+
+// Records a reference to an existing kubeconfig context, and switches to it.
+func (c *Controller) applyExternal(ctx context.Context, desired *api.Cluster) (*api.Cluster, error) {
+	err := validateExternal(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	contextName := c.contextName(desired.Name)
+	config := c.configCopy()
+	ct, ok := config.Contexts[contextName]
+	if !ok {
+		return nil, fmt.Errorf("product: external references kubeconfig context %q, which doesn't exist", contextName)
+	}
+	if _, ok := config.Clusters[ct.Cluster]; !ok {
+		return nil, fmt.Errorf("kubeconfig context %q references cluster %q, which doesn't exist", contextName, ct.Cluster)
+	}
+
+	unlock, err := c.lockCluster(desired.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	contexts, err := readExternalContexts(c.externalClustersPath)
+	if err != nil {
+		return nil, err
+	}
+	if !contexts[contextName] {
+		contexts[contextName] = true
+		err = writeExternalContexts(c.externalClustersPath, contexts)
+		if err != nil {
+			return nil, fmt.Errorf("referencing cluster %s: %v", desired.Name, err)
+		}
+	}
+
+	err = c.configWriter.SetContext(contextName)
+	if err != nil {
+		return nil, fmt.Errorf("switching to cluster context %s: %v", desired.Name, err)
+	}
+	err = c.reloadConfigs()
+	if err != nil {
+		return nil, err
+	}
+	return c.Get(ctx, desired.Name)
+}
+
+// externalAdmin refuses to create or delete external clusters, because
+// ctlptl doesn't own them. Deleting only removes the reference.
+type externalAdmin struct {
+	iostreams   genericclioptions.IOStreams
+	path        string
+	contextName func(name string) string
+}
+
+func newExternalAdmin(iostreams genericclioptions.IOStreams, path string, contextName func(name string) string) *externalAdmin {
+	return &externalAdmin{
+		iostreams:   iostreams,
+		path:        path,
+		contextName: contextName,
+	}
+}
+
+func (a *externalAdmin) EnsureInstalled(ctx context.Context) error { return nil }
+
+func (a *externalAdmin) Create(ctx context.Context, desired *api.Cluster, registry *api.Registry) error {
+	return fmt.Errorf("ctlptl can't create cluster %s with product: external. Create it with its own tools, then apply it to reference it", desired.Name)
+}
+
+func (a *externalAdmin) LocalRegistryHosting(ctx context.Context, desired *api.Cluster, registry *api.Registry) (*localregistry.LocalRegistryHostingV1, error) {
+	return nil, nil
+}
+
+// ctlptl doesn't manage external clusters.
+func (a *externalAdmin) Upgrade(ctx context.Context, desired *api.Cluster) error {
+	return ErrNotSupported
+}
+
+func (a *externalAdmin) Delete(ctx context.Context, config *api.Cluster) error {
+	contexts, err := readExternalContexts(a.path)
+	if err != nil {
+		return err
+	}
+	delete(contexts, a.contextName(config.Name))
+	err = writeExternalContexts(a.path, contexts)
+	if err != nil {
+		return fmt.Errorf("removing reference to cluster %s: %v", config.Name, err)
+	}
+	_, _ = fmt.Fprintf(a.iostreams.ErrOut,
+		"Removed the reference to external cluster %s. ctlptl doesn't delete external clusters, or their kubeconfig context\n",
+		config.Name)
+	return nil
+}
@@ -0,0 +1,98 @@
+package cluster
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestApplyExternal(t *testing.T) {
+	f := newFixture(t)
+	f.controller.externalClustersPath = filepath.Join(t.TempDir(), "external-clusters.json")
+
+	cluster, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Name:    "docker-desktop",
+		Product: string(ProductExternal),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, string(ProductExternal), cluster.Product)
+	assert.Equal(t, "docker-desktop", f.config.CurrentContext)
+
+	contexts, err := readExternalContexts(f.controller.externalClustersPath)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"docker-desktop": true}, contexts)
+
+	list, err := f.controller.List(context.Background(), ListOptions{})
+	require.NoError(t, err)
+	products := map[string]string{}
+	for _, cl := range list.Items {
+		products[cl.Name] = cl.Product
+	}
+	assert.Equal(t, string(ProductExternal), products["docker-desktop"])
+	assert.Equal(t, "microk8s", products["microk8s"])
+}
+
+func TestApplyExternalMissingContext(t *testing.T) {
+	f := newFixture(t)
+	f.controller.externalClustersPath = filepath.Join(t.TempDir(), "external-clusters.json")
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Name:    "remote-dev",
+		Product: string(ProductExternal),
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `kubeconfig context "remote-dev", which doesn't exist`)
+	}
+}
+
+func TestApplyExternalOnlyName(t *testing.T) {
+	f := newFixture(t)
+	f.controller.externalClustersPath = filepath.Join(t.TempDir(), "external-clusters.json")
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Name:    "docker-desktop",
+		Product: string(ProductExternal),
+		MinCPUs: 4,
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "product: external only supports the name field")
+	}
+}
+
+func TestDeleteExternal(t *testing.T) {
+	f := newFixture(t)
+	f.controller.externalClustersPath = filepath.Join(t.TempDir(), "external-clusters.json")
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Name:    "docker-desktop",
+		Product: string(ProductExternal),
+	})
+	require.NoError(t, err)
+
+	err = f.controller.Delete(context.Background(), "docker-desktop")
+	require.NoError(t, err)
+	assert.Contains(t, f.errOut.String(), "Removed the reference to external cluster docker-desktop")
+
+	// The context stays, as a normal cluster.
+	assert.Contains(t, f.config.Contexts, "docker-desktop")
+	cluster, err := f.controller.Get(context.Background(), "docker-desktop")
+	require.NoError(t, err)
+	assert.Equal(t, "docker-desktop", cluster.Product)
+}
+
+func TestUse(t *testing.T) {
+	f := newFixture(t)
+
+	cluster, err := f.controller.Use(context.Background(), "docker-desktop")
+	require.NoError(t, err)
+	assert.Equal(t, "docker-desktop", cluster.Name)
+	assert.Equal(t, "docker-desktop", f.config.CurrentContext)
+
+	_, err = f.controller.Use(context.Background(), "remote-dev")
+	assert.Error(t, err)
+}
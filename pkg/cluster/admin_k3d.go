@@ -3,10 +3,13 @@ package cluster
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"regexp"
 	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/tilt-dev/clusterid"
 	"github.com/tilt-dev/localregistry-go"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/klog/v2"
@@ -14,23 +17,65 @@ import (
 	"github.com/tilt-dev/ctlptl/pkg/api"
 )
 
+func validateK3dOptions(desired *api.Cluster) error {
+	if clusterid.Product(desired.Product) != clusterid.ProductK3D {
+		return fmt.Errorf("k3dOptions may only be set on clusters with product: k3d. Actual product: %s", desired.Product)
+	}
+	reg := desired.K3dOptions.EmbeddedRegistry
+	if reg == nil {
+		return nil
+	}
+	if desired.Registry != "" {
+		return fmt.Errorf("k3dOptions.embeddedRegistry and registry can't both be set. "+
+			"The embedded registry replaces the ctlptl registry %s. Remove one of them", desired.Registry)
+	}
+	if reg.HostPort < 0 || reg.HostPort > 65535 {
+		return fmt.Errorf("k3dOptions.embeddedRegistry.hostPort must be between 0 and 65535. Actual: %d", reg.HostPort)
+	}
+	return nil
+}
+
+// The --registry-create value for the embedded registry, NAME[:HOST][:HOSTPORT].
+func k3dEmbeddedRegistryFlag(desired *api.Cluster) string {
+	reg := desired.K3dOptions.EmbeddedRegistry
+	name := reg.Name
+	if name == "" {
+		name = fmt.Sprintf("%s-registry", desired.Name)
+	}
+	if reg.HostPort == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s:0.0.0.0:%d", name, reg.HostPort)
+}
+
 // k3dAdmin uses the k3d CLI to manipulate a k3d cluster,
 // once the underlying machine has been setup.
 type k3dAdmin struct {
 	iostreams genericclioptions.IOStreams
+
+	// The k3d binary, resolved by EnsureInstalled.
+	binary string
+
+	// Where the containerd config templates of clusters with
+	// containerdConfigPatches are stored.
+	containerdConfigDir string
 }
 
 func newK3dAdmin(iostreams genericclioptions.IOStreams) *k3dAdmin {
 	return &k3dAdmin{
-		iostreams: iostreams,
+		iostreams:           iostreams,
+		binary:              "k3d",
+		containerdConfigDir: defaultContainerdConfigDir(),
 	}
 }
 
 func (a *k3dAdmin) EnsureInstalled(ctx context.Context) error {
-	_, err := exec.LookPath("k3d")
+	// --auto-install doesn't cover k3d, since ctlptl has no pinned checksums for its releases.
+	path, err := exec.LookPath("k3d")
 	if err != nil {
 		return fmt.Errorf("k3d not installed. Please install k3d with these instructions: https://k3d.io/#installation")
 	}
+	a.binary = path
 	return nil
 }
 
@@ -45,14 +90,17 @@ func (a *k3dAdmin) Create(ctx context.Context, desired *api.Cluster, registry *a
 		return fmt.Errorf("all k3d clusters must have a name with the prefix k3d-*")
 	}
 
-	k3dName := strings.TrimPrefix(clusterName, "k3d-")
-
-	args := []string{"cluster", "create", k3dName}
-	if registry != nil {
-		args = append(args, "--registry-use", registry.Name)
+	if len(desired.ContainerdConfigPatches) > 0 {
+		if a.containerdConfigDir == "" {
+			return fmt.Errorf("containerdConfigPatches needs a home directory to store the containerd config")
+		}
+		err := writeContainerdConfigTemplate(containerdConfigTemplatePath(a.containerdConfigDir, clusterName), desired.ContainerdConfigPatches)
+		if err != nil {
+			return errors.Wrap(err, "creating k3d cluster")
+		}
 	}
 
-	cmd := exec.CommandContext(ctx, "k3d", args...)
+	cmd := exec.CommandContext(ctx, a.binary, a.createArgs(desired, registry)...)
 	cmd.Stdout = a.iostreams.Out
 	cmd.Stderr = a.iostreams.ErrOut
 	err := cmd.Run()
@@ -63,11 +111,56 @@ func (a *k3dAdmin) Create(ctx context.Context, desired *api.Cluster, registry *a
 	return nil
 }
 
+func (a *k3dAdmin) createArgs(desired *api.Cluster, registry *api.Registry) []string {
+	k3dName := strings.TrimPrefix(desired.Name, "k3d-")
+
+	args := []string{"cluster", "create", k3dName}
+	if image, _ := nodeImages(desired); image != "" {
+		args = append(args, "--image", image)
+	}
+	if registry != nil {
+		args = append(args, "--registry-use", registry.Name)
+	}
+	if desired.K3dOptions != nil && desired.K3dOptions.EmbeddedRegistry != nil {
+		args = append(args, "--registry-create", k3dEmbeddedRegistryFlag(desired))
+	}
+	args = append(args, kubeletArgsK3dFlags(desired.KubeletArgs)...)
+	args = append(args, containerRuntimeK3dFlags(desired)...)
+	if len(desired.ContainerdConfigPatches) > 0 {
+		args = append(args, containerdConfigK3dFlags(containerdConfigTemplatePath(a.containerdConfigDir, desired.Name))...)
+	}
+	return args
+}
+
+func (a *k3dAdmin) GeneratedConfig(ctx context.Context, desired *api.Cluster, registry *api.Registry) (map[string][]byte, error) {
+	args := append([]string{"k3d"}, a.createArgs(desired, registry)...)
+	for i, arg := range args {
+		args[i] = shellQuote(arg)
+	}
+	return map[string][]byte{"k3d-create.sh": []byte(strings.Join(args, " ") + "\n")}, nil
+}
+
+var shellSafe = regexp.MustCompile(`^[a-zA-Z0-9_./=:,+@%-]+$`)
+
+// Quotes an argument so that it can be pasted into a POSIX shell.
+func shellQuote(arg string) string {
+	if shellSafe.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+}
+
 // K3d manages the LocalRegistryHosting config itself :cheers:
 func (a *k3dAdmin) LocalRegistryHosting(ctx context.Context, desired *api.Cluster, registry *api.Registry) (*localregistry.LocalRegistryHostingV1, error) {
 	return nil, nil
 }
 
+// k3d's Kubernetes version is baked into its node image, so the nodes have to
+// be re-created.
+func (a *k3dAdmin) Upgrade(ctx context.Context, desired *api.Cluster) error {
+	return ErrNotSupported
+}
+
 func (a *k3dAdmin) Delete(ctx context.Context, config *api.Cluster) error {
 	clusterName := config.Name
 	if !strings.HasPrefix(clusterName, "k3d-") {
@@ -75,7 +168,7 @@ func (a *k3dAdmin) Delete(ctx context.Context, config *api.Cluster) error {
 	}
 
 	k3dName := strings.TrimPrefix(clusterName, "k3d-")
-	cmd := exec.CommandContext(ctx, "k3d", "cluster", "delete", k3dName)
+	cmd := exec.CommandContext(ctx, a.binary, "cluster", "delete", k3dName)
 	cmd.Stdout = a.iostreams.Out
 	cmd.Stderr = a.iostreams.ErrOut
 	cmd.Stdin = a.iostreams.In
@@ -83,5 +176,12 @@ func (a *k3dAdmin) Delete(ctx context.Context, config *api.Cluster) error {
 	if err != nil {
 		return errors.Wrap(err, "deleting k3d cluster")
 	}
+
+	if a.containerdConfigDir != "" {
+		err = os.Remove(containerdConfigTemplatePath(a.containerdConfigDir, clusterName))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "deleting containerd config template")
+		}
+	}
 	return nil
 }
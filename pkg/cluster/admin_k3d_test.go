@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestK3dEmbeddedRegistryArgs(t *testing.T) {
+	a := newK3dAdmin(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr})
+
+	args := a.createArgs(&api.Cluster{
+		Name:       "k3d-dev",
+		Product:    string(clusterid.ProductK3D),
+		K3dOptions: &api.K3dOptions{EmbeddedRegistry: &api.K3dEmbeddedRegistry{}},
+	}, nil)
+	assert.Equal(t, []string{"cluster", "create", "dev", "--registry-create", "k3d-dev-registry"}, args)
+
+	args = a.createArgs(&api.Cluster{
+		Name:    "k3d-dev",
+		Product: string(clusterid.ProductK3D),
+		K3dOptions: &api.K3dOptions{EmbeddedRegistry: &api.K3dEmbeddedRegistry{
+			Name:     "mirror",
+			HostPort: 5005,
+		}},
+	}, nil)
+	assert.Equal(t, []string{"cluster", "create", "dev", "--registry-create", "mirror:0.0.0.0:5005"}, args)
+}
+
+func TestK3dContainerRuntimeArgs(t *testing.T) {
+	a := newK3dAdmin(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr})
+
+	args := a.createArgs(&api.Cluster{
+		Name:             "k3d-dev",
+		Product:          string(clusterid.ProductK3D),
+		ContainerRuntime: "containerd",
+	}, nil)
+	assert.Equal(t, []string{"cluster", "create", "dev"}, args)
+
+	args = a.createArgs(&api.Cluster{
+		Name:             "k3d-dev",
+		Product:          string(clusterid.ProductK3D),
+		ContainerRuntime: "docker",
+	}, nil)
+	assert.Equal(t, []string{"cluster", "create", "dev", "--k3s-arg", "--docker@server:*;agent:*"}, args)
+
+	args = a.createArgs(&api.Cluster{
+		Name:             "k3d-dev",
+		Product:          string(clusterid.ProductK3D),
+		ContainerRuntime: "crio",
+	}, nil)
+	assert.Equal(t, []string{"cluster", "create", "dev",
+		"--k3s-arg", "--container-runtime-endpoint=unix:///var/run/crio/crio.sock@server:*;agent:*"}, args)
+}
+
+func TestValidateK3dOptions(t *testing.T) {
+	embedded := &api.K3dOptions{EmbeddedRegistry: &api.K3dEmbeddedRegistry{}}
+
+	assert.NoError(t, validateK3dOptions(&api.Cluster{Product: string(clusterid.ProductK3D), K3dOptions: embedded}))
+
+	err := validateK3dOptions(&api.Cluster{Product: string(clusterid.ProductKIND), K3dOptions: embedded})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "k3dOptions may only be set on clusters with product: k3d")
+	}
+
+	err = validateK3dOptions(&api.Cluster{
+		Product:    string(clusterid.ProductK3D),
+		Registry:   "ctlptl-registry",
+		K3dOptions: embedded,
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "k3dOptions.embeddedRegistry and registry can't both be set")
+	}
+
+	err = validateK3dOptions(&api.Cluster{
+		Product:    string(clusterid.ProductK3D),
+		K3dOptions: &api.K3dOptions{EmbeddedRegistry: &api.K3dEmbeddedRegistry{HostPort: 70000}},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "hostPort must be between 0 and 65535")
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
 
+	"github.com/tilt-dev/ctlptl/internal/toolinstall"
 	"github.com/tilt-dev/ctlptl/pkg/api"
 )
 
@@ -29,24 +30,110 @@ func kindNetworkName() string {
 	return networkName
 }
 
+func validateKindOptions(desired *api.Cluster) error {
+	opts := desired.KindOptions
+	switch opts.Provider {
+	case "", "docker":
+	case "podman", "nerdctl":
+		if desired.Registry != "" {
+			return fmt.Errorf("kindOptions.provider %s does not support a registry. ctlptl can only connect registries to clusters in Docker", opts.Provider)
+		}
+	default:
+		return fmt.Errorf("kindOptions.provider must be one of: docker, podman, nerdctl. Actual: %s", opts.Provider)
+	}
+	return validateKindNodeSecurity(opts)
+}
+
 // kindAdmin uses the kind CLI to manipulate a kind cluster,
 // once the underlying machine has been setup.
 type kindAdmin struct {
 	iostreams    genericclioptions.IOStreams
 	dockerClient dockerClient
+	cgroupRoot   string
+
+	// The kind binary, resolved by EnsureInstalled.
+	binary string
+
+	// Where the encryption configs of clusters with encryptionAtRest are stored.
+	encryptionConfigDir string
 }
 
 func newKindAdmin(iostreams genericclioptions.IOStreams, dockerClient dockerClient) *kindAdmin {
 	return &kindAdmin{
 		iostreams:    iostreams,
 		dockerClient: dockerClient,
+		cgroupRoot:   defaultCgroupRoot,
+		binary:       "kind",
+
+		encryptionConfigDir: defaultEncryptionConfigDir(),
 	}
 }
 
 func (a *kindAdmin) EnsureInstalled(ctx context.Context) error {
-	_, err := exec.LookPath("kind")
+	path, err := ensureTool(ctx, a.iostreams.ErrOut, toolinstall.Kind)
 	if err != nil {
-		return fmt.Errorf("kind not installed. Please install kind with these instructions: https://kind.sigs.k8s.io/")
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("kind not installed. Please install kind with these instructions: https://kind.sigs.k8s.io/ " +
+			"or rerun with --auto-install")
+	}
+	a.binary = path
+	return nil
+}
+
+// Creates a kind command that runs nodes with the given options.
+func (a *kindAdmin) kindCommand(ctx context.Context, opts *api.KindOptions, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, a.binary, args...)
+	if opts != nil && opts.Provider != "" {
+		cmd.Env = append(os.Environ(), fmt.Sprintf("KIND_EXPERIMENTAL_PROVIDER=%s", opts.Provider))
+	}
+	return cmd
+}
+
+// The kind node image runs systemd, which can only manage its cgroups
+// inside a rootless container runtime on cgroup v2, and only on cgroup v2
+// hosts that delegate the cgroup controllers it needs.
+//
+// Check the Docker daemon up front, so that we can point the user
+// at the fix instead of failing halfway through node boot.
+func (a *kindAdmin) checkHostCgroups(ctx context.Context, opts *api.KindOptions) error {
+	wantRootless := opts != nil && opts.Rootless
+	if opts != nil && opts.Provider != "" && opts.Provider != "docker" {
+		// We can only inspect Docker.
+		return nil
+	}
+
+	info, err := a.dockerClient.Info(ctx)
+	if err != nil {
+		return errors.Wrap(err, "checking docker info")
+	}
+
+	isRootless := false
+	for _, opt := range info.SecurityOptions {
+		if opt == "name=rootless" {
+			isRootless = true
+		}
+	}
+
+	if isRootless && info.CgroupVersion != "2" {
+		return fmt.Errorf("Docker is running in rootless mode with cgroup v%s.\n"+
+			"Kind nodes can only run rootless with cgroup v2. To set up your host, see:\n"+
+			"https://kind.sigs.k8s.io/docs/user/rootless/", info.CgroupVersion)
+	}
+	if info.CgroupVersion == "2" {
+		err := a.checkCgroupV2(ctx, isRootless)
+		if err != nil {
+			return err
+		}
+	}
+	if wantRootless && !isRootless {
+		return fmt.Errorf("kindOptions.rootless is set, but Docker is not running in rootless mode.\n" +
+			"Remove 'rootless: true' from your cluster config, or switch to a rootless Docker context")
+	}
+	if isRootless && !wantRootless {
+		_, _ = fmt.Fprintf(a.iostreams.ErrOut,
+			"   Detected rootless Docker. Set 'kindOptions: {rootless: true}' in your cluster config to make this explicit.\n")
 	}
 	return nil
 }
@@ -70,9 +157,51 @@ func (a *kindAdmin) kindClusterConfig(desired *api.Cluster, registry *api.Regist
 			registry.Name, registry.Status.ContainerPort, registry.Name, registry.Status.ContainerPort)
 		kindConfig.ContainerdConfigPatches = append(kindConfig.ContainerdConfigPatches, patch)
 	}
+
+	addKindNodeImages(kindConfig, desired)
+	if desired.EtcdBackup != nil {
+		addEtcdBackupMounts(kindConfig, desired.EtcdBackup.HostPath)
+	}
+	if desired.NetworkCalico != nil || desired.CNI != "" {
+		kindConfig.Networking.DisableDefaultCNI = true
+	}
+	if len(desired.APIServerCertSANs) > 0 {
+		kindConfig.KubeadmConfigPatches = append(kindConfig.KubeadmConfigPatches,
+			apiServerCertSANsPatch(desired.APIServerCertSANs))
+	}
+	if len(desired.EnableAdmissionPlugins) > 0 || len(desired.DisableAdmissionPlugins) > 0 {
+		kindConfig.KubeadmConfigPatches = append(kindConfig.KubeadmConfigPatches,
+			admissionPluginsPatch(desired.EnableAdmissionPlugins, desired.DisableAdmissionPlugins))
+	}
+	if len(desired.KubeletArgs) > 0 {
+		kindConfig.KubeadmConfigPatches = append(kindConfig.KubeadmConfigPatches,
+			kubeletArgsPatches(desired.KubeletArgs)...)
+	}
+	if desired.EncryptionAtRest != nil {
+		addEncryptionConfigMounts(kindConfig, encryptionConfigPath(a.encryptionConfigDir, desired.Name))
+		kindConfig.KubeadmConfigPatches = append(kindConfig.KubeadmConfigPatches, encryptionAtRestPatch())
+	}
 	return kindConfig
 }
 
+func (a *kindAdmin) kindClusterConfigYAML(desired *api.Cluster, registry *api.Registry) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	encoder := yaml.NewEncoder(buf)
+	err := encoder.Encode(a.kindClusterConfig(desired, registry))
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (a *kindAdmin) GeneratedConfig(ctx context.Context, desired *api.Cluster, registry *api.Registry) (map[string][]byte, error) {
+	kindConfig, err := a.kindClusterConfigYAML(desired, registry)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{"kind-config.yaml": kindConfig}, nil
+}
+
 func (a *kindAdmin) Create(ctx context.Context, desired *api.Cluster, registry *api.Registry) error {
 	klog.V(3).Infof("Creating cluster with config:\n%+v\n---\n", desired)
 	if registry != nil {
@@ -85,18 +214,24 @@ func (a *kindAdmin) Create(ctx context.Context, desired *api.Cluster, registry *
 	}
 
 	kindName := strings.TrimPrefix(clusterName, "kind-")
+	opts := desired.KindOptions
+
+	err := a.checkHostCgroups(ctx, opts)
+	if err != nil {
+		return err
+	}
 
 	// If a cluster has been registered with Kind, but deleted from our kubeconfig,
 	// Kind will refuse to create a new cluster. The only way to salvage it is
 	// to delete and recreate.
-	exists, err := a.clusterExists(ctx, kindName)
+	exists, err := a.clusterExists(ctx, opts, kindName)
 	if err != nil {
 		return err
 	}
 
 	if exists {
 		klog.V(3).Infof("Deleting orphaned KIND cluster: %s", kindName)
-		cmd := exec.CommandContext(ctx, "kind", "delete", "cluster", "--name", kindName)
+		cmd := a.kindCommand(ctx, opts, "delete", "cluster", "--name", kindName)
 		cmd.Stdout = a.iostreams.Out
 		cmd.Stderr = a.iostreams.ErrOut
 		err := cmd.Run()
@@ -105,8 +240,20 @@ func (a *kindAdmin) Create(ctx context.Context, desired *api.Cluster, registry *
 		}
 	}
 
+	if desired.EncryptionAtRest != nil {
+		if a.encryptionConfigDir == "" {
+			return fmt.Errorf("encryptionAtRest needs a home directory to store the encryption key")
+		}
+		err := writeEncryptionConfig(encryptionConfigPath(a.encryptionConfigDir, clusterName), desired.EncryptionAtRest)
+		if err != nil {
+			return errors.Wrap(err, "creating kind cluster")
+		}
+	}
+
 	args := []string{"create", "cluster", "--name", kindName}
-	if desired.KubernetesVersion != "" {
+	if opts != nil && opts.NodeImage != "" {
+		args = append(args, "--image", opts.NodeImage)
+	} else if desired.KubernetesVersion != "" {
 		kindVersion, err := a.getKindVersion(ctx)
 		if err != nil {
 			return errors.Wrap(err, "creating cluster")
@@ -119,20 +266,27 @@ func (a *kindAdmin) Create(ctx context.Context, desired *api.Cluster, registry *
 		args = append(args, "--image", node)
 	}
 
-	kindConfig := a.kindClusterConfig(desired, registry)
-	buf := bytes.NewBuffer(nil)
-	encoder := yaml.NewEncoder(buf)
-	err = encoder.Encode(kindConfig)
+	kindConfig, err := a.kindClusterConfigYAML(desired, registry)
 	if err != nil {
 		return errors.Wrap(err, "creating kind cluster")
 	}
 
 	args = append(args, "--config", "-")
 
-	cmd := exec.CommandContext(ctx, "kind", args...)
+	cmd := a.kindCommand(ctx, opts, args...)
+	if hasKindNodeSecurity(opts) {
+		wrapperDir, err := writeKindDockerWrapper(opts)
+		if err != nil {
+			return errors.Wrap(err, "creating kind cluster")
+		}
+		defer func() {
+			_ = os.RemoveAll(wrapperDir)
+		}()
+		useKindDockerWrapper(cmd, wrapperDir)
+	}
 	cmd.Stdout = a.iostreams.Out
 	cmd.Stderr = a.iostreams.ErrOut
-	cmd.Stdin = buf
+	cmd.Stdin = bytes.NewReader(kindConfig)
 	err = cmd.Run()
 	if err != nil {
 		return errors.Wrap(err, "creating kind cluster")
@@ -148,12 +302,19 @@ func (a *kindAdmin) Create(ctx context.Context, desired *api.Cluster, registry *
 		}
 	}
 
+	if desired.NetworkCalico != nil {
+		err := a.installCalico(ctx, desired)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (a *kindAdmin) clusterExists(ctx context.Context, cluster string) (bool, error) {
+func (a *kindAdmin) clusterExists(ctx context.Context, opts *api.KindOptions, cluster string) (bool, error) {
 	buf := bytes.NewBuffer(nil)
-	cmd := exec.CommandContext(ctx, "kind", "get", "clusters")
+	cmd := a.kindCommand(ctx, opts, "get", "clusters")
 	cmd.Stdout = buf
 	cmd.Stderr = a.iostreams.ErrOut
 	err := cmd.Run()
@@ -188,6 +349,12 @@ func (a *kindAdmin) LocalRegistryHosting(ctx context.Context, desired *api.Clust
 	}, nil
 }
 
+// Kind's Kubernetes version is baked into its node image, so the nodes have to
+// be re-created.
+func (a *kindAdmin) Upgrade(ctx context.Context, desired *api.Cluster) error {
+	return ErrNotSupported
+}
+
 func (a *kindAdmin) Delete(ctx context.Context, config *api.Cluster) error {
 	clusterName := config.Name
 	if !strings.HasPrefix(clusterName, "kind-") {
@@ -195,7 +362,7 @@ func (a *kindAdmin) Delete(ctx context.Context, config *api.Cluster) error {
 	}
 
 	kindName := strings.TrimPrefix(clusterName, "kind-")
-	cmd := exec.CommandContext(ctx, "kind", "delete", "cluster", "--name", kindName)
+	cmd := a.kindCommand(ctx, config.KindOptions, "delete", "cluster", "--name", kindName)
 	cmd.Stdout = a.iostreams.Out
 	cmd.Stderr = a.iostreams.ErrOut
 	cmd.Stdin = a.iostreams.In
@@ -203,6 +370,14 @@ func (a *kindAdmin) Delete(ctx context.Context, config *api.Cluster) error {
 	if err != nil {
 		return errors.Wrap(err, "deleting kind cluster")
 	}
+
+	// The key is useless without the cluster's etcd data.
+	if a.encryptionConfigDir != "" {
+		err = os.Remove(encryptionConfigPath(a.encryptionConfigDir, clusterName))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "deleting encryption config")
+		}
+	}
 	return nil
 }
 
@@ -246,7 +421,7 @@ func (a *kindAdmin) getNodeImage(ctx context.Context, kindVersion, k8sVersion st
 }
 
 func (a *kindAdmin) getKindVersion(ctx context.Context) (string, error) {
-	cmd := exec.CommandContext(ctx, "kind", "version")
+	cmd := exec.CommandContext(ctx, a.binary, "version")
 	out, err := cmd.Output()
 	if err != nil {
 		return "", errors.Wrap(err, "kind version")
@@ -263,6 +438,14 @@ func (a *kindAdmin) getKindVersion(ctx context.Context) (string, error) {
 // This table must be built up manually from the Kind release notes each
 // time a new Kind version is released :\
 var kindK8sNodeTable = map[string]map[string]string{
+	"v0.20.0": {
+		"1.27": "kindest/node:v1.27.3@sha256:3966ac761ae0136263ffdb6cfd4db23ef8a83cba8a463690e98317add2c9ba72",
+		"1.26": "kindest/node:v1.26.6@sha256:6e2d8b28a5b601defe327b98bd1c2d1930b49e5d8c512e1895099e4504007adb",
+		"1.25": "kindest/node:v1.25.11@sha256:227fa11ce74ea76a0474eeefb84cb75d8dad1b08638371ecf0e86259b35be0c8",
+		"1.24": "kindest/node:v1.24.15@sha256:7db4f8bea3e14b82d12e044e25e34bd53754b7f2b0e9d56df21774e6f66a70ab",
+		"1.23": "kindest/node:v1.23.17@sha256:59c989ff8a517a93127d4a536e7014d28e235fb3529d9fba91b3951d461edfdb",
+		"1.22": "kindest/node:v1.22.17@sha256:f5b2e5698c6c9d6d0adc419c0deae21a425c07d81bbf3b6a6834042f25d4fba2",
+	},
 	"v0.17.0": {
 		"1.25": "kindest/node:v1.25.3@sha256:f52781bc0d7a19fb6c405c2af83abfeb311f130707a0e219175677e366cc45d1",
 		"1.24": "kindest/node:v1.24.7@sha256:577c630ce8e509131eab1aea12c022190978dd2f745aac5eb1fe65c0807eb315",
@@ -7,6 +7,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/internal/toolinstall"
+	"github.com/tilt-dev/ctlptl/pkg/api"
 )
 
 func TestNodeImage(t *testing.T) {
@@ -38,3 +41,41 @@ func TestNodeImage(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "kindest/node:v1.16.9@sha256:7175872357bc85847ec4b1aba46ed1d12fa054c83ac7a8a11f5c268957fd5765", img)
 }
+
+func TestNodeImageForAutoInstalledKind(t *testing.T) {
+	// --auto-install downloads toolinstall.Kind, so kubernetesVersion
+	// needs to work with that version.
+	_, ok := kindK8sNodeTable[toolinstall.Kind.Version]
+	assert.True(t, ok, "no node images for kind %s", toolinstall.Kind.Version)
+}
+
+func TestCheckRootless(t *testing.T) {
+	iostreams := genericclioptions.IOStreams{
+		In:     os.Stdin,
+		Out:    os.Stdout,
+		ErrOut: os.Stderr,
+	}
+	dockerClient := &fakeDockerClient{started: true, cgroupVersion: "1"}
+	a := newKindAdmin(iostreams, dockerClient)
+	ctx := context.Background()
+
+	assert.NoError(t, a.checkHostCgroups(ctx, nil))
+
+	err := a.checkHostCgroups(ctx, &api.KindOptions{Rootless: true})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Docker is not running in rootless mode")
+	}
+
+	dockerClient.securityOptions = []string{"name=seccomp,profile=default", "name=rootless"}
+	err = a.checkHostCgroups(ctx, &api.KindOptions{Rootless: true})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "rootless mode with cgroup v1")
+	}
+
+	dockerClient.cgroupVersion = "2"
+	assert.NoError(t, a.checkHostCgroups(ctx, &api.KindOptions{Rootless: true}))
+
+	// Other providers can't be inspected.
+	dockerClient.started = false
+	assert.NoError(t, a.checkHostCgroups(ctx, &api.KindOptions{Provider: "podman"}))
+}
@@ -0,0 +1,119 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tilt-dev/clusterid"
+	"github.com/tilt-dev/localregistry-go"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
+
+	cexec "github.com/tilt-dev/ctlptl/internal/exec"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// kwok (Kubernetes WithOut Kubelet) simulates nodes and pods,
+// so you can test controllers against large clusters on a laptop.
+//
+// https://kwok.sigs.k8s.io/
+const ProductKwok clusterid.Product = "kwok"
+
+// Like clusterid.ProductFromContext, but also recognizes products
+// that clusterid doesn't know about yet.
+func productFromContext(ct *clientcmdapi.Context, cl *clientcmdapi.Cluster) clusterid.Product {
+	if strings.HasPrefix(ct.Cluster, "kwok-") {
+		return ProductKwok
+	}
+	return clusterid.ProductFromContext(ct, cl)
+}
+
+// DefaultClusterName is like Product.DefaultClusterName, but also handles products
+// that clusterid doesn't know about yet.
+func DefaultClusterName(product clusterid.Product) string {
+	if product == ProductKwok {
+		return "kwok-kwok"
+	}
+	return product.DefaultClusterName()
+}
+
+// kwokAdmin uses the kwokctl CLI to manipulate a kwok cluster.
+type kwokAdmin struct {
+	iostreams genericclioptions.IOStreams
+	runner    cexec.CmdRunner
+}
+
+func newKwokAdmin(iostreams genericclioptions.IOStreams, runner cexec.CmdRunner) *kwokAdmin {
+	return &kwokAdmin{
+		iostreams: iostreams,
+		runner:    runner,
+	}
+}
+
+func (a *kwokAdmin) EnsureInstalled(ctx context.Context) error {
+	_, err := exec.LookPath("kwokctl")
+	if err != nil {
+		return fmt.Errorf("kwokctl not installed. Please install kwok with these instructions: https://kwok.sigs.k8s.io/docs/user/installation/")
+	}
+	return nil
+}
+
+func (a *kwokAdmin) Create(ctx context.Context, desired *api.Cluster, registry *api.Registry) error {
+	klog.V(3).Infof("Creating cluster with config:\n%+v\n---\n", desired)
+
+	clusterName := desired.Name
+	if !strings.HasPrefix(clusterName, "kwok-") {
+		return fmt.Errorf("all kwok clusters must have a name with the prefix kwok-*")
+	}
+
+	kwokName := strings.TrimPrefix(clusterName, "kwok-")
+	err := a.runner.RunIO(ctx, a.iostreams, "kwokctl", "create", "cluster", "--name", kwokName)
+	if err != nil {
+		return errors.Wrap(err, "creating kwok cluster")
+	}
+
+	if desired.Workers > 0 {
+		err := a.runner.RunIO(ctx, a.iostreams,
+			"kwokctl", "scale", "node", "--name", kwokName, "--replicas", strconv.Itoa(desired.Workers))
+		if err != nil {
+			return errors.Wrap(err, "creating kwok nodes")
+		}
+	}
+
+	// Make it obvious to anyone inspecting the cluster that the nodes aren't real.
+	if desired.Annotations == nil {
+		desired.Annotations = make(map[string]string)
+	}
+	desired.Annotations[api.ClusterAnnotationSimulated] = "true"
+	return nil
+}
+
+// kwok clusters can't run containers, so there's nothing to pull from a registry.
+func (a *kwokAdmin) LocalRegistryHosting(ctx context.Context, desired *api.Cluster, registry *api.Registry) (*localregistry.LocalRegistryHostingV1, error) {
+	klog.V(2).Infof("kwok cluster %s simulates its pods, so it can't use registry %s", desired.Name, registry.Name)
+	return nil, nil
+}
+
+// Simulated clusters are cheap to re-create.
+func (a *kwokAdmin) Upgrade(ctx context.Context, desired *api.Cluster) error {
+	return ErrNotSupported
+}
+
+func (a *kwokAdmin) Delete(ctx context.Context, config *api.Cluster) error {
+	clusterName := config.Name
+	if !strings.HasPrefix(clusterName, "kwok-") {
+		return fmt.Errorf("all kwok clusters must have a name with the prefix kwok-*")
+	}
+
+	kwokName := strings.TrimPrefix(clusterName, "kwok-")
+	err := a.runner.RunIO(ctx, a.iostreams, "kwokctl", "delete", "cluster", "--name", kwokName)
+	if err != nil {
+		return errors.Wrap(err, "deleting kwok cluster")
+	}
+	return nil
+}
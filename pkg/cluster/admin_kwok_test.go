@@ -0,0 +1,51 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestKwokCreate(t *testing.T) {
+	calls := [][]string{}
+	runner := exec.NewFakeCmdRunner(func(argv []string) string {
+		calls = append(calls, argv)
+		return ""
+	})
+	a := newKwokAdmin(genericclioptions.IOStreams{Out: os.Stdout, ErrOut: os.Stderr}, runner)
+
+	cluster := &api.Cluster{Name: "kwok-big", Workers: 500}
+	err := a.Create(context.Background(), cluster, nil)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"kwokctl", "create", "cluster", "--name", "big"},
+		{"kwokctl", "scale", "node", "--name", "big", "--replicas", "500"},
+	}, calls)
+	assert.Equal(t, "true", cluster.Annotations[api.ClusterAnnotationSimulated])
+
+	err = a.Delete(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kwokctl", "delete", "cluster", "--name", "big"}, runner.LastArgs)
+}
+
+func TestKwokCreateBadName(t *testing.T) {
+	a := newKwokAdmin(genericclioptions.IOStreams{}, exec.NewFakeCmdRunner(func(argv []string) string { return "" }))
+	err := a.Create(context.Background(), &api.Cluster{Name: "big"}, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "must have a name with the prefix kwok-*")
+	}
+}
+
+func TestProductFromContextKwok(t *testing.T) {
+	product := productFromContext(&clientcmdapi.Context{Cluster: "kwok-big"},
+		&clientcmdapi.Cluster{Server: "https://127.0.0.1:32766"})
+	assert.Equal(t, ProductKwok, product)
+}
@@ -101,11 +101,6 @@ func (a *minikubeAdmin) Create(ctx context.Context, desired *api.Cluster, regist
 		}
 	}
 
-	containerRuntime := "containerd"
-	if desired.Minikube != nil && desired.Minikube.ContainerRuntime != "" {
-		containerRuntime = desired.Minikube.ContainerRuntime
-	}
-
 	extraConfigs := []string{"kubelet.max-pods=500"}
 	if desired.Minikube != nil && len(desired.Minikube.ExtraConfigs) > 0 {
 		extraConfigs = desired.Minikube.ExtraConfigs
@@ -122,7 +117,7 @@ func (a *minikubeAdmin) Create(ctx context.Context, desired *api.Cluster, regist
 	args = append(args,
 		"-p", clusterName,
 		"--driver=docker",
-		fmt.Sprintf("--container-runtime=%s", containerRuntime),
+		fmt.Sprintf("--container-runtime=%s", containerRuntimeMinikube(desired)),
 	)
 
 	for _, c := range extraConfigs {
@@ -219,17 +214,14 @@ func (a *minikubeAdmin) ensureRegistryDisconnected(ctx context.Context, registry
 	return nil
 }
 
-// We want to make sure that the image is pullable from either:
-// localhost:[registry-port] or
-// [registry-name]:5000
-// by cloning the registry config created by minikube's --insecure-registry.
-func (a *minikubeAdmin) applyContainerdPatchRegistryApiV2(ctx context.Context, desired *api.Cluster, registry *api.Registry, networkMode container.NetworkMode) error {
+// Lists the names of the nodes in the minikube cluster.
+func (a *minikubeAdmin) nodes(ctx context.Context, clusterName string) ([]string, error) {
 	nodeOutput := bytes.NewBuffer(nil)
 	err := a.runner.RunIO(ctx,
 		genericclioptions.IOStreams{Out: nodeOutput, ErrOut: a.iostreams.ErrOut},
-		"minikube", "-p", desired.Name, "node", "list")
+		"minikube", "-p", clusterName, "node", "list")
 	if err != nil {
-		return errors.Wrap(err, "configuring minikube registry")
+		return nil, err
 	}
 
 	nodes := []string{}
@@ -245,6 +237,18 @@ func (a *minikubeAdmin) applyContainerdPatchRegistryApiV2(ctx context.Context, d
 		}
 		nodes = append(nodes, node)
 	}
+	return nodes, nil
+}
+
+// We want to make sure that the image is pullable from either:
+// localhost:[registry-port] or
+// [registry-name]:5000
+// by cloning the registry config created by minikube's --insecure-registry.
+func (a *minikubeAdmin) applyContainerdPatchRegistryApiV2(ctx context.Context, desired *api.Cluster, registry *api.Registry, networkMode container.NetworkMode) error {
+	nodes, err := a.nodes(ctx, desired.Name)
+	if err != nil {
+		return errors.Wrap(err, "configuring minikube registry")
+	}
 
 	for _, node := range nodes {
 		networkHost := registry.Status.IPAddress
@@ -277,28 +281,11 @@ func (a *minikubeAdmin) applyContainerdPatchRegistryApiV2(ctx context.Context, d
 func (a *minikubeAdmin) applyContainerdPatchRegistryApiV1(ctx context.Context, desired *api.Cluster, registry *api.Registry, networkMode container.NetworkMode) error {
 	configPath := "/etc/containerd/config.toml"
 
-	nodeOutput := bytes.NewBuffer(nil)
-	err := a.runner.RunIO(ctx,
-		genericclioptions.IOStreams{Out: nodeOutput, ErrOut: a.iostreams.ErrOut},
-		"minikube", "-p", desired.Name, "node", "list")
+	nodes, err := a.nodes(ctx, desired.Name)
 	if err != nil {
 		return errors.Wrap(err, "configuring minikube registry")
 	}
 
-	nodes := []string{}
-	nodeOutputSplit := strings.Split(nodeOutput.String(), "\n")
-	for _, line := range nodeOutputSplit {
-		fields := strings.Fields(line)
-		if len(fields) == 0 {
-			continue
-		}
-		node := strings.TrimSpace(fields[0])
-		if node == "" {
-			continue
-		}
-		nodes = append(nodes, node)
-	}
-
 	for _, node := range nodes {
 		networkHost := registry.Status.IPAddress
 		if networkMode.IsUserDefined() {
@@ -359,6 +346,104 @@ func (a *minikubeAdmin) LocalRegistryHosting(ctx context.Context, desired *api.C
 	}, nil
 }
 
+// SwitchRegistry moves the cluster network from the old registry to the new one,
+// and rewrites the containerd mirror config on each node.
+//
+// Requires minikube v1.26+, which reads the mirror config from
+// /etc/containerd/certs.d. Assumes the docker driver, where each
+// node is a container with the same name as the node.
+func (a *minikubeAdmin) SwitchRegistry(ctx context.Context, cluster *api.Cluster, oldRegistry, newRegistry *api.Registry) error {
+	v, err := a.version(ctx)
+	if err != nil {
+		return err
+	}
+	if v.LT(v1_26) {
+		return fmt.Errorf("switching registries requires minikube v1.26+ (found v%s). Re-create the cluster with registry: %s",
+			v, newRegistry.Name)
+	}
+
+	container, err := a.dockerClient.ContainerInspect(ctx, cluster.Name)
+	if err != nil {
+		return errors.Wrap(err, "inspecting minikube cluster")
+	}
+	networkMode := container.HostConfig.NetworkMode
+
+	// Remember the old mirror dirs before we disconnect, which clears the registry networks.
+	staleDirs := []string{}
+	if oldRegistry != nil {
+		staleDirs = minikubeMirrorDirs(oldRegistry, networkMode)
+		err = a.ensureRegistryDisconnected(ctx, oldRegistry, networkMode)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = a.ensureRegistryConnected(ctx, newRegistry, networkMode)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := a.nodes(ctx, cluster.Name)
+	if err != nil {
+		return errors.Wrap(err, "switching minikube registry")
+	}
+
+	networkHost := minikubeRegistryNetworkHost(newRegistry, networkMode)
+	hostsTOML := fmt.Sprintf(`server = "http://%[1]s:%[2]d"
+
+[host."http://%[1]s:%[2]d"]
+  capabilities = ["pull", "resolve", "push"]
+`, networkHost, newRegistry.Status.ContainerPort)
+
+	script := []string{"set -e"}
+	for _, dir := range staleDirs {
+		script = append(script, fmt.Sprintf("rm -rf '%s'", dir))
+	}
+	for _, dir := range minikubeMirrorDirs(newRegistry, networkMode) {
+		script = append(script, fmt.Sprintf("mkdir -p '%[1]s' && cat /tmp/ctlptl-hosts.toml > '%[1]s/hosts.toml'", dir))
+	}
+	script = append(script, "systemctl restart containerd")
+
+	for _, node := range nodes {
+		err := a.runner.RunIO(ctx,
+			genericclioptions.IOStreams{In: strings.NewReader(hostsTOML), Out: a.iostreams.Out, ErrOut: a.iostreams.ErrOut},
+			"docker", "exec", "-i", node, "sh", "-c",
+			"cat > /tmp/ctlptl-hosts.toml && "+strings.Join(script, "\n"))
+		if err != nil {
+			return errors.Wrapf(err, "switching minikube registry on node %s", node)
+		}
+	}
+	return nil
+}
+
+func minikubeRegistryNetworkHost(registry *api.Registry, networkMode container.NetworkMode) string {
+	if networkMode.IsUserDefined() {
+		return registry.Name
+	}
+	return registry.Status.IPAddress
+}
+
+// The containerd mirror config dirs for each address the registry can be pulled from.
+func minikubeMirrorDirs(registry *api.Registry, networkMode container.NetworkMode) []string {
+	return []string{
+		fmt.Sprintf("/etc/containerd/certs.d/localhost:%d", registry.Status.HostPort),
+		fmt.Sprintf("/etc/containerd/certs.d/%s:%d",
+			minikubeRegistryNetworkHost(registry, networkMode), registry.Status.ContainerPort),
+	}
+}
+
+// Minikube upgrades a running profile in place when you start it with a newer
+// Kubernetes version. It refuses to downgrade.
+func (a *minikubeAdmin) Upgrade(ctx context.Context, desired *api.Cluster) error {
+	err := a.runner.RunIO(ctx,
+		genericclioptions.IOStreams{In: strings.NewReader(""), Out: a.iostreams.Out, ErrOut: a.iostreams.ErrOut},
+		"minikube", "start", "-p", desired.Name, "--kubernetes-version", desired.KubernetesVersion)
+	if err != nil {
+		return errors.Wrap(err, "upgrading minikube cluster")
+	}
+	return nil
+}
+
 func (a *minikubeAdmin) Delete(ctx context.Context, config *api.Cluster) error {
 	err := a.runner.RunIO(ctx, a.iostreams, "minikube", "delete", "-p", config.Name)
 	if err != nil {
@@ -2,6 +2,7 @@ package cluster
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"testing"
 
@@ -28,22 +29,94 @@ func TestMinikubeStartFlags(t *testing.T) {
 	}, f.runner.LastArgs)
 }
 
+func TestMinikubeUpgrade(t *testing.T) {
+	f := newMinikubeFixture()
+	err := f.a.Upgrade(context.Background(), &api.Cluster{Name: "minikube", KubernetesVersion: "v1.27.3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"minikube", "start", "-p", "minikube", "--kubernetes-version", "v1.27.3",
+	}, f.runner.LastArgs)
+}
+
+func TestMinikubeContainerRuntime(t *testing.T) {
+	f := newMinikubeFixture()
+	ctx := context.Background()
+	err := f.a.Create(ctx, &api.Cluster{Name: "minikube", ContainerRuntime: "crio"}, nil)
+	require.NoError(t, err)
+	assert.Contains(t, f.runner.LastArgs, "--container-runtime=cri-o")
+
+	err = f.a.Create(ctx, &api.Cluster{Name: "minikube", ContainerRuntime: "docker"}, nil)
+	require.NoError(t, err)
+	assert.Contains(t, f.runner.LastArgs, "--container-runtime=docker")
+
+	// minikube.containerRuntime takes precedence.
+	err = f.a.Create(ctx, &api.Cluster{
+		Name:             "minikube",
+		ContainerRuntime: "docker",
+		Minikube:         &api.MinikubeCluster{ContainerRuntime: "containerd"},
+	}, nil)
+	require.NoError(t, err)
+	assert.Contains(t, f.runner.LastArgs, "--container-runtime=containerd")
+}
+
+func TestMinikubeSwitchRegistry(t *testing.T) {
+	f := newMinikubeFixture()
+	f.version = "v1.28.0"
+	f.dockerClient.networkMode = "minikube"
+	ctx := context.Background()
+
+	oldRegistry := &api.Registry{
+		Name:   "old-registry",
+		Status: api.RegistryStatus{HostPort: 5001, ContainerPort: 5000, Networks: []string{"bridge", "minikube"}},
+	}
+	newRegistry := &api.Registry{
+		Name:   "new-registry",
+		Status: api.RegistryStatus{HostPort: 5002, ContainerPort: 5000, Networks: []string{"bridge"}},
+	}
+	err := f.a.SwitchRegistry(ctx, &api.Cluster{Name: "minikube"}, oldRegistry, newRegistry)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"docker", "exec", "-i", "minikube-m02", "sh", "-c"}, f.runner.LastArgs[:6])
+	script := f.runner.LastArgs[6]
+	assert.Contains(t, script, "rm -rf '/etc/containerd/certs.d/localhost:5001'")
+	assert.Contains(t, script, "rm -rf '/etc/containerd/certs.d/old-registry:5000'")
+	assert.Contains(t, script, "mkdir -p '/etc/containerd/certs.d/localhost:5002'")
+	assert.Contains(t, script, "mkdir -p '/etc/containerd/certs.d/new-registry:5000'")
+	assert.Contains(t, script, "systemctl restart containerd")
+	assert.Equal(t, []string{"minikube"}, f.dockerClient.networks)
+	assert.Equal(t, []string{"bridge"}, oldRegistry.Status.Networks)
+}
+
+func TestMinikubeSwitchRegistryOldVersion(t *testing.T) {
+	f := newMinikubeFixture()
+	err := f.a.SwitchRegistry(context.Background(), &api.Cluster{Name: "minikube"}, nil, &api.Registry{Name: "new-registry"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "switching registries requires minikube v1.26+ (found v1.25.2)")
+	}
+}
+
 type minikubeFixture struct {
-	runner *exec.FakeCmdRunner
-	a      *minikubeAdmin
+	runner       *exec.FakeCmdRunner
+	dockerClient *fakeDockerClient
+	a            *minikubeAdmin
+	version      string
 }
 
 func newMinikubeFixture() *minikubeFixture {
-	dockerClient := &fakeDockerClient{ncpu: 1}
+	f := &minikubeFixture{
+		dockerClient: &fakeDockerClient{ncpu: 1},
+		version:      "v1.25.2",
+	}
 	iostreams := genericclioptions.IOStreams{Out: os.Stdout, ErrOut: os.Stderr}
-	runner := exec.NewFakeCmdRunner(func(argv []string) string {
+	f.runner = exec.NewFakeCmdRunner(func(argv []string) string {
 		if argv[1] == "version" {
-			return `{"commit":"62e108c3dfdec8029a890ad6d8ef96b6461426dc","minikubeVersion":"v1.25.2"}`
+			return fmt.Sprintf(`{"commit":"62e108c3dfdec8029a890ad6d8ef96b6461426dc","minikubeVersion":"%s"}`, f.version)
+		}
+		if len(argv) > 3 && argv[3] == "node" {
+			return "minikube\t192.168.49.2\nminikube-m02\t192.168.49.3\n"
 		}
 		return ""
 	})
-	return &minikubeFixture{
-		runner: runner,
-		a:      newMinikubeAdmin(iostreams, dockerClient, runner),
-	}
+	f.a = newMinikubeAdmin(iostreams, f.dockerClient, f.runner)
+	return f
 }
@@ -0,0 +1,151 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// The Kubernetes versions that an admission plugin is available in.
+// A zero version means no bound.
+type admissionPluginVersions struct {
+	Added   semver.Version
+	Removed semver.Version
+}
+
+// The admission plugins built into the apiserver, from
+// https://kubernetes.io/docs/reference/access-authn-authz/admission-controllers/
+//
+// Webhooks aren't plugins of their own. They're called by
+// MutatingAdmissionWebhook and ValidatingAdmissionWebhook.
+var admissionPlugins = map[string]admissionPluginVersions{
+	"AlwaysAdmit":                          {},
+	"AlwaysDeny":                           {},
+	"AlwaysPullImages":                     {},
+	"CertificateApproval":                  {},
+	"CertificateSigning":                   {},
+	"CertificateSubjectRestriction":        {},
+	"ClusterTrustBundleAttest":             {Added: semver.MustParse("1.27.0")},
+	"DefaultIngressClass":                  {},
+	"DefaultStorageClass":                  {},
+	"DefaultTolerationSeconds":             {},
+	"DenyServiceExternalIPs":               {Added: semver.MustParse("1.21.0")},
+	"EventRateLimit":                       {},
+	"ExtendedResourceToleration":           {},
+	"ImagePolicyWebhook":                   {},
+	"LimitPodHardAntiAffinityTopology":     {},
+	"LimitRanger":                          {},
+	"MutatingAdmissionPolicy":              {Added: semver.MustParse("1.32.0")},
+	"MutatingAdmissionWebhook":             {},
+	"NamespaceAutoProvision":               {},
+	"NamespaceExists":                      {},
+	"NamespaceLifecycle":                   {},
+	"NodeRestriction":                      {},
+	"OwnerReferencesPermissionEnforcement": {},
+	"PersistentVolumeClaimResize":          {},
+	"PersistentVolumeLabel":                {Removed: semver.MustParse("1.31.0")},
+	"PodNodeSelector":                      {},
+	"PodSecurity":                          {Added: semver.MustParse("1.22.0")},
+	"PodSecurityPolicy":                    {Removed: semver.MustParse("1.25.0")},
+	"PodTolerationRestriction":             {},
+	"Priority":                             {},
+	"ResourceQuota":                        {},
+	"RuntimeClass":                         {},
+	"SecurityContextDeny":                  {Removed: semver.MustParse("1.30.0")},
+	"ServiceAccount":                       {},
+	"StorageObjectInUseProtection":         {},
+	"TaintNodesByCondition":                {},
+	"ValidatingAdmissionPolicy":            {Added: semver.MustParse("1.26.0")},
+	"ValidatingAdmissionWebhook":           {},
+}
+
+func validateAdmissionPlugins(desired *api.Cluster) error {
+	if clusterid.Product(desired.Product) != clusterid.ProductKIND {
+		return fmt.Errorf("enableAdmissionPlugins and disableAdmissionPlugins may only be set on clusters with product: kind. Actual product: %s", desired.Product)
+	}
+
+	// If we don't know the version, we can only check the names.
+	var version *semver.Version
+	if desired.KubernetesVersion != "" {
+		v, err := semver.ParseTolerant(desired.KubernetesVersion)
+		if err == nil {
+			version = &v
+		}
+	}
+
+	enabled := make(map[string]bool)
+	for _, name := range desired.EnableAdmissionPlugins {
+		err := validateAdmissionPlugin("enableAdmissionPlugins", name, version)
+		if err != nil {
+			return err
+		}
+		enabled[name] = true
+	}
+	for _, name := range desired.DisableAdmissionPlugins {
+		err := validateAdmissionPlugin("disableAdmissionPlugins", name, version)
+		if err != nil {
+			return err
+		}
+		if enabled[name] {
+			return fmt.Errorf("admission plugin %s is in both enableAdmissionPlugins and disableAdmissionPlugins", name)
+		}
+	}
+	return nil
+}
+
+func validateAdmissionPlugin(field, name string, version *semver.Version) error {
+	versions, ok := admissionPlugins[name]
+	if !ok {
+		return fmt.Errorf("invalid %s entry %q: not a built-in admission plugin. "+
+			"For webhooks, enable MutatingAdmissionWebhook or ValidatingAdmissionWebhook", field, name)
+	}
+	if version == nil {
+		return nil
+	}
+
+	// Compare without pre-release tags, so that alphas of a release count as that release.
+	v := semver.Version{Major: version.Major, Minor: version.Minor, Patch: version.Patch}
+	if versions.Added.Major != 0 && v.LT(versions.Added) {
+		return fmt.Errorf("invalid %s entry %q: added in Kubernetes v%d.%d, but the cluster runs v%s",
+			field, name, versions.Added.Major, versions.Added.Minor, version)
+	}
+	if versions.Removed.Major != 0 && v.GTE(versions.Removed) {
+		return fmt.Errorf("invalid %s entry %q: removed in Kubernetes v%d.%d, but the cluster runs v%s",
+			field, name, versions.Removed.Major, versions.Removed.Minor, version)
+	}
+	return nil
+}
+
+// Compares two lists of admission plugins, ignoring order.
+func admissionPluginsEqual(a, b []string) bool {
+	return sortedAdmissionPlugins(a) == sortedAdmissionPlugins(b)
+}
+
+// A kubeadm config patch that passes the admission plugins to the apiserver.
+//
+// Kind merges this into the ClusterConfiguration it generates for kubeadm.
+func admissionPluginsPatch(enable, disable []string) string {
+	var b strings.Builder
+	b.WriteString("kind: ClusterConfiguration\napiServer:\n  extraArgs:\n")
+	if len(enable) > 0 {
+		b.WriteString(fmt.Sprintf("    enable-admission-plugins: %s\n", strconv.Quote(sortedAdmissionPlugins(enable))))
+	}
+	if len(disable) > 0 {
+		b.WriteString(fmt.Sprintf("    disable-admission-plugins: %s\n", strconv.Quote(sortedAdmissionPlugins(disable))))
+	}
+	return b.String()
+}
+
+// The apiserver doesn't care about order, so sort the plugins to keep
+// the generated config stable.
+func sortedAdmissionPlugins(plugins []string) string {
+	plugins = append([]string{}, plugins...)
+	sort.Strings(plugins)
+	return strings.Join(plugins, ",")
+}
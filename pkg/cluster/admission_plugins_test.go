@@ -0,0 +1,128 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"gopkg.in/yaml.v3"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestClusterApplyAdmissionPlugins(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	kindAdmin := f.newFakeAdmin(clusterid.ProductKIND)
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:                 string(clusterid.ProductKIND),
+		EnableAdmissionPlugins:  []string{"PodSecurity", "NodeRestriction"},
+		DisableAdmissionPlugins: []string{"DefaultStorageClass"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	kindAdmin.created = nil
+
+	// Re-applying the same plugins, in any order, doesn't re-create.
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:                 string(clusterid.ProductKIND),
+		EnableAdmissionPlugins:  []string{"NodeRestriction", "PodSecurity"},
+		DisableAdmissionPlugins: []string{"DefaultStorageClass"},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, kindAdmin.created)
+	assert.Nil(t, kindAdmin.deleted)
+
+	f.errOut.Truncate(0)
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:                string(clusterid.ProductKIND),
+		EnableAdmissionPlugins: []string{"NodeRestriction", "PodSecurity"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.deleted.Name)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	assert.Contains(t, f.errOut.String(),
+		"desired admission plugins (enabled: NodeRestriction,PodSecurity; disabled: ) do not match current "+
+			"(enabled: NodeRestriction,PodSecurity; disabled: DefaultStorageClass)")
+}
+
+func TestClusterApplyAdmissionPluginsInvalid(t *testing.T) {
+	f := newFixture(t)
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:                string(clusterid.ProductMinikube),
+		EnableAdmissionPlugins: []string{"PodSecurity"},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "may only be set on clusters with product: kind")
+	}
+
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:                string(clusterid.ProductKIND),
+		EnableAdmissionPlugins: []string{"my-webhook"},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid enableAdmissionPlugins entry "my-webhook": not a built-in admission plugin`)
+	}
+
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:                 string(clusterid.ProductKIND),
+		EnableAdmissionPlugins:  []string{"PodSecurity"},
+		DisableAdmissionPlugins: []string{"PodSecurity"},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "admission plugin PodSecurity is in both")
+	}
+}
+
+func TestValidateAdmissionPluginsVersion(t *testing.T) {
+	cluster := &api.Cluster{
+		Product:                string(clusterid.ProductKIND),
+		KubernetesVersion:      "v1.26.3",
+		EnableAdmissionPlugins: []string{"PodSecurity", "ValidatingAdmissionPolicy"},
+	}
+	assert.NoError(t, validateAdmissionPlugins(cluster))
+
+	cluster.EnableAdmissionPlugins = []string{"PodSecurityPolicy"}
+	err := validateAdmissionPlugins(cluster)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `"PodSecurityPolicy": removed in Kubernetes v1.25, but the cluster runs v1.26.3`)
+	}
+
+	cluster.KubernetesVersion = "v1.21.0-alpha.1"
+	cluster.EnableAdmissionPlugins = []string{"PodSecurity"}
+	err = validateAdmissionPlugins(cluster)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `"PodSecurity": added in Kubernetes v1.22, but the cluster runs v1.21.0-alpha.1`)
+	}
+}
+
+func TestKindConfigAdmissionPlugins(t *testing.T) {
+	iostreams := genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
+	a := newKindAdmin(iostreams, &fakeDockerClient{})
+
+	config := a.kindClusterConfig(&api.Cluster{
+		Product:                 string(clusterid.ProductKIND),
+		EnableAdmissionPlugins:  []string{"PodSecurity", "NodeRestriction"},
+		DisableAdmissionPlugins: []string{"DefaultStorageClass"},
+	}, nil)
+	require.Len(t, config.KubeadmConfigPatches, 1)
+
+	var patch struct {
+		Kind      string `yaml:"kind"`
+		APIServer struct {
+			ExtraArgs map[string]string `yaml:"extraArgs"`
+		} `yaml:"apiServer"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(config.KubeadmConfigPatches[0]), &patch))
+	assert.Equal(t, "ClusterConfiguration", patch.Kind)
+	assert.Equal(t, map[string]string{
+		"enable-admission-plugins":  "NodeRestriction,PodSecurity",
+		"disable-admission-plugins": "DefaultStorageClass",
+	}, patch.APIServer.ExtraArgs)
+}
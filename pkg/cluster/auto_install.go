@@ -0,0 +1,14 @@
+package cluster
+
+import (
+	"context"
+	"io"
+
+	"github.com/tilt-dev/ctlptl/internal/toolinstall"
+)
+
+// Returns the path of the tool to run, or "" if it isn't installed.
+// See toolinstall.Ensure.
+func ensureTool(ctx context.Context, errOut io.Writer, tool toolinstall.Tool) (string, error) {
+	return toolinstall.Ensure(ctx, errOut, tool)
+}
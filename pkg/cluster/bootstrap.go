@@ -0,0 +1,218 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/phayes/freeport"
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/registry"
+)
+
+// The products that bootstrap can create, in order of preference.
+var bootstrapProducts = []clusterid.Product{
+	clusterid.ProductKIND,
+	clusterid.ProductK3D,
+	clusterid.ProductMinikube,
+}
+
+const bootstrapRegistryName = "ctlptl-registry"
+
+// The port most registry docs use. We use it when it's free,
+// so that image names are predictable.
+const bootstrapRegistryPort = 5000
+
+var invalidClusterNameChars = regexp.MustCompile("[^a-z0-9-]+")
+
+type BootstrapOptions struct {
+	// The cluster product. If empty, uses the first of kind, k3d, or minikube
+	// that's installed.
+	Product clusterid.Product
+
+	// The cluster name. If empty, uses the name of the current directory.
+	//
+	// The product prefix (e.g., kind-) is added if missing.
+	Name string
+}
+
+// Bootstrap creates a cluster with a local registry, using defaults
+// for everything that the options leave out.
+//
+// When it returns, the cluster is healthy and is the current kubectl context.
+func (c *Controller) Bootstrap(ctx context.Context, options BootstrapOptions) (*api.Cluster, error) {
+	product := options.Product
+	if product == "" {
+		var err error
+		product, err = detectBootstrapProduct(exec.LookPath)
+		if err != nil {
+			return nil, err
+		}
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔎 Found %s. Using it to create the cluster\n", product)
+	} else if !isBootstrapProduct(product) {
+		return nil, fmt.Errorf("unsupported provider %q. Must be one of: %s", product, bootstrapProductNames())
+	}
+
+	name := options.Name
+	if name == "" {
+		dir, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		name = bootstrapClusterName(filepath.Base(dir))
+	}
+	name = withProductPrefix(product, name)
+
+	err := c.ensureBootstrapRegistry(ctx, product)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Apply(ctx, &api.Cluster{
+		TypeMeta: typeMeta,
+		Product:  string(product),
+		Name:     name,
+		Registry: bootstrapRegistryName,
+	})
+}
+
+// Creates the registry, unless it already exists.
+func (c *Controller) ensureBootstrapRegistry(ctx context.Context, product clusterid.Product) error {
+	regCtl, err := c.registryController(ctx)
+	if err != nil {
+		return err
+	}
+
+	regList, err := regCtl.List(ctx, registry.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, reg := range regList.Items {
+		if reg.Name == bootstrapRegistryName {
+			return nil
+		}
+	}
+
+	port, err := bootstrapRegistryHostPort(regList.Items)
+	if err != nil {
+		return err
+	}
+
+	_, err = regCtl.Apply(ctx, &api.Registry{
+		TypeMeta: registry.TypeMeta(),
+		Name:     bootstrapRegistryName,
+		Port:     port,
+		Labels:   registryLabels(product),
+	})
+	return err
+}
+
+// Chooses a host port for the registry that no other registry or process is using.
+func bootstrapRegistryHostPort(registries []api.Registry) (int, error) {
+	for _, reg := range registries {
+		if reg.Status.HostPort == bootstrapRegistryPort {
+			return freeport.GetFreePort()
+		}
+	}
+
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", bootstrapRegistryPort))
+	if err != nil {
+		return freeport.GetFreePort()
+	}
+	_ = l.Close()
+	return bootstrapRegistryPort, nil
+}
+
+func detectBootstrapProduct(lookPath func(file string) (string, error)) (clusterid.Product, error) {
+	for _, product := range bootstrapProducts {
+		_, err := lookPath(string(product))
+		if err == nil {
+			return product, nil
+		}
+	}
+	return "", fmt.Errorf("no cluster provider found. Install one of: %s", bootstrapProductNames())
+}
+
+func isBootstrapProduct(product clusterid.Product) bool {
+	for _, p := range bootstrapProducts {
+		if p == product {
+			return true
+		}
+	}
+	return false
+}
+
+func bootstrapProductNames() string {
+	names := make([]string, 0, len(bootstrapProducts))
+	for _, p := range bootstrapProducts {
+		names = append(names, string(p))
+	}
+	return strings.Join(names, ", ")
+}
+
+// Converts a directory name to a valid cluster name.
+func bootstrapClusterName(dir string) string {
+	name := invalidClusterNameChars.ReplaceAllString(strings.ToLower(dir), "-")
+	if len(name) > 40 {
+		name = name[:40]
+	}
+	name = strings.Trim(name, "-")
+	if name == "" {
+		return "ctlptl"
+	}
+	return name
+}
+
+// Kind and k3d cluster names are prefixed with the product name in the kubeconfig.
+func withProductPrefix(product clusterid.Product, name string) string {
+	if product != clusterid.ProductKIND && product != clusterid.ProductK3D {
+		return name
+	}
+	prefix := fmt.Sprintf("%s-", product)
+	if strings.HasPrefix(name, prefix) {
+		return name
+	}
+	return prefix + name
+}
+
+// Kubeconfig returns a standalone kubeconfig for the named cluster,
+// with credentials inlined.
+func (c *Controller) Kubeconfig(name string) ([]byte, error) {
+	return c.MinifiedKubeconfig(name, true)
+}
+
+// MinifiedKubeconfig returns a kubeconfig with only the context, cluster,
+// and user of the named cluster, so that it can be shared without the rest
+// of the kubeconfig.
+//
+// If flatten is set, inlines the certificates and keys that the kubeconfig
+// references by path, so that the kubeconfig works on other machines.
+func (c *Controller) MinifiedKubeconfig(name string, flatten bool) ([]byte, error) {
+	config := c.configCopy()
+	contextName := c.contextName(name)
+	if _, ok := config.Contexts[contextName]; !ok {
+		return nil, fmt.Errorf("cluster %s: kubectl context not found", name)
+	}
+
+	config.CurrentContext = contextName
+	err := clientcmdapi.MinifyConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if flatten {
+		err = clientcmdapi.FlattenConfig(config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return clientcmd.Write(*config)
+}
@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestDetectBootstrapProduct(t *testing.T) {
+	installed := map[string]bool{"minikube": true, "k3d": true}
+	lookPath := func(file string) (string, error) {
+		if installed[file] {
+			return "/usr/local/bin/" + file, nil
+		}
+		return "", fmt.Errorf("not found")
+	}
+
+	product, err := detectBootstrapProduct(lookPath)
+	require.NoError(t, err)
+	assert.Equal(t, clusterid.ProductK3D, product)
+
+	installed = map[string]bool{}
+	_, err = detectBootstrapProduct(lookPath)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Install one of: kind, k3d, minikube")
+	}
+}
+
+func TestBootstrapClusterName(t *testing.T) {
+	assert.Equal(t, "my-app", bootstrapClusterName("My_App"))
+	assert.Equal(t, "ctlptl", bootstrapClusterName("/"))
+	assert.Equal(t, "kind-my-app", withProductPrefix(clusterid.ProductKIND, "my-app"))
+	assert.Equal(t, "k3d-my-app", withProductPrefix(clusterid.ProductK3D, "k3d-my-app"))
+	assert.Equal(t, "my-app", withProductPrefix(clusterid.ProductMinikube, "my-app"))
+}
+
+func TestBootstrap(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+
+	f.dockerClient.started = true
+	f.newFakeAdmin(clusterid.ProductKIND)
+
+	result, err := f.controller.Bootstrap(context.Background(), BootstrapOptions{
+		Product: clusterid.ProductKIND,
+		Name:    "my-app",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "kind-my-app", result.Name)
+	assert.Equal(t, bootstrapRegistryName, result.Registry)
+	assert.Equal(t, "kind-my-app", f.config.CurrentContext)
+	assert.Equal(t, bootstrapRegistryName, f.registryCtl.lastApply.Name)
+	assert.NotEqual(t, 0, f.registryCtl.lastApply.Status.HostPort)
+
+	kubeconfig, err := f.controller.Kubeconfig("kind-my-app")
+	require.NoError(t, err)
+	assert.Contains(t, string(kubeconfig), "current-context: kind-my-app")
+	assert.Contains(t, string(kubeconfig), "server: http://kind-my-app.localhost/")
+}
+
+func TestBootstrapUnsupportedProvider(t *testing.T) {
+	f := newFixture(t)
+	_, err := f.controller.Bootstrap(context.Background(), BootstrapOptions{Product: clusterid.ProductDockerDesktop})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `unsupported provider "docker-desktop"`)
+	}
+}
+
+func TestMinifiedKubeconfig(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+	f.setupKindNodes("kind-bar")
+	certPath := filepath.Join(t.TempDir(), "client.crt")
+	require.NoError(t, os.WriteFile(certPath, []byte("my-cert"), 0600))
+	f.controller.config.Contexts["kind-foo"].AuthInfo = "kind-foo"
+	f.controller.config.AuthInfos = map[string]*clientcmdapi.AuthInfo{
+		"kind-foo": {ClientCertificate: certPath},
+	}
+
+	kubeconfig, err := f.controller.MinifiedKubeconfig("kind-foo", false)
+	require.NoError(t, err)
+	config, err := clientcmd.Load(kubeconfig)
+	require.NoError(t, err)
+	assert.Equal(t, "kind-foo", config.CurrentContext)
+	assert.Len(t, config.Contexts, 1)
+	assert.Contains(t, config.Contexts, "kind-foo")
+	assert.Len(t, config.Clusters, 1)
+	assert.Contains(t, config.Clusters, "kind-foo")
+	assert.Equal(t, certPath, config.AuthInfos["kind-foo"].ClientCertificate)
+
+	kubeconfig, err = f.controller.MinifiedKubeconfig("kind-foo", true)
+	require.NoError(t, err)
+	config, err = clientcmd.Load(kubeconfig)
+	require.NoError(t, err)
+	assert.Equal(t, "", config.AuthInfos["kind-foo"].ClientCertificate)
+	assert.Equal(t, []byte("my-cert"), config.AuthInfos["kind-foo"].ClientCertificateData)
+
+	_, err = f.controller.MinifiedKubeconfig("kind-baz", false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cluster kind-baz: kubectl context not found")
+	}
+}
@@ -0,0 +1,321 @@
+package cluster
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tilt-dev/clusterid"
+	"gopkg.in/yaml.v3"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Files on each node that configure how it pulls images.
+var bundleNodeConfigPaths = []string{
+	"/etc/containerd/config.toml",
+	"/etc/containerd/certs.d",
+	"/etc/rancher/k3s/registries.yaml",
+}
+
+// The command that prints the version of each cluster tool.
+var bundleProductVersionCommands = map[clusterid.Product][]string{
+	clusterid.ProductKIND:     {"kind", "version"},
+	clusterid.ProductK3D:      {"k3d", "version"},
+	clusterid.ProductMinikube: {"minikube", "version"},
+}
+
+// Keys and flags whose values shouldn't leave the machine.
+var secretNameRe = regexp.MustCompile(`(?i)(secret|token|password|passwd|credential|apikey|api-key|private)`)
+
+const redacted = "REDACTED"
+
+// A key = value line in a TOML config patch.
+var tomlKeyValueRe = regexp.MustCompile(`^(\s*([A-Za-z0-9_.\-"]+)\s*=\s*).*$`)
+
+// A key: value line in a YAML config, like k3s's registries.yaml.
+// Keys with no value (the start of a nested map) don't match.
+var yamlKeyValueRe = regexp.MustCompile(`^(\s*([A-Za-z0-9_.\-"]+)\s*:\s+)\S.*$`)
+
+type BundleOptions struct {
+	// The version of ctlptl, recorded in the bundle.
+	Version string
+}
+
+// WriteBundle collects diagnostics about the named cluster into a gzipped tarball:
+// the cluster spec (with secrets redacted), the configs ctlptl generates for the
+// cluster tool, the logs and registry config of each node, and tool versions.
+//
+// Collection is best-effort. Anything that can't be collected is listed
+// in errors.txt in the bundle, rather than failing the whole bundle.
+func (c *Controller) WriteBundle(ctx context.Context, name string, w io.Writer, options BundleOptions) error {
+	cluster, err := c.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	b := &bundleWriter{tw: tw, dir: fmt.Sprintf("ctlptl-bundle-%s", name), now: time.Now()}
+
+	spec, err := yaml.Marshal(redactCluster(cluster))
+	b.add("cluster.yaml", spec, err)
+
+	b.add("versions.txt", c.bundleVersions(ctx, cluster, options), nil)
+
+	dockerClient, err := c.getDockerClient(ctx)
+	if err == nil {
+		info, infoErr := dockerClient.Info(ctx)
+		var data []byte
+		if infoErr == nil {
+			data, infoErr = json.MarshalIndent(info, "", "  ")
+		}
+		b.add("docker-info.json", data, infoErr)
+	} else {
+		b.add("docker-info.json", nil, err)
+	}
+
+	c.bundleGeneratedConfigs(ctx, b, cluster)
+	c.bundleNodes(ctx, b, cluster)
+
+	if len(b.errors) > 0 {
+		b.add("errors.txt", []byte(strings.Join(b.errors, "\n")+"\n"), nil)
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+type bundleWriter struct {
+	tw     *tar.Writer
+	dir    string
+	now    time.Time
+	errors []string
+	err    error
+}
+
+// Adds a file to the bundle, or records why we couldn't collect it.
+func (b *bundleWriter) add(name string, data []byte, err error) {
+	if err != nil {
+		b.errors = append(b.errors, fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+	if b.err != nil {
+		return
+	}
+	b.err = b.tw.WriteHeader(&tar.Header{
+		Name:    path.Join(b.dir, name),
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: b.now,
+	})
+	if b.err == nil {
+		_, b.err = b.tw.Write(data)
+	}
+	if b.err != nil {
+		b.errors = append(b.errors, fmt.Sprintf("%s: %v", name, b.err))
+	}
+}
+
+func (c *Controller) bundleVersions(ctx context.Context, cluster *api.Cluster, options BundleOptions) []byte {
+	buf := bytes.NewBuffer(nil)
+	_, _ = fmt.Fprintf(buf, "ctlptl: %s\n", options.Version)
+	_, _ = fmt.Fprintf(buf, "product: %s\n", cluster.Product)
+	_, _ = fmt.Fprintf(buf, "kubernetes: %s\n", cluster.Status.KubernetesVersion)
+
+	dockerClient, err := c.getDockerClient(ctx)
+	if err == nil {
+		v, err := dockerClient.ServerVersion(ctx)
+		if err == nil {
+			_, _ = fmt.Fprintf(buf, "docker: %s (%s/%s)\n", v.Version, v.Os, v.Arch)
+		} else {
+			_, _ = fmt.Fprintf(buf, "docker: %v\n", err)
+		}
+	}
+
+	argv, ok := bundleProductVersionCommands[clusterid.Product(cluster.Product)]
+	if ok {
+		out, err := c.bundleCommand(ctx, argv[0], argv[1:]...)
+		if err != nil {
+			_, _ = fmt.Fprintf(buf, "%s: %v\n", argv[0], err)
+		} else {
+			_, _ = fmt.Fprintf(buf, "%s: %s\n", argv[0], strings.TrimSpace(string(out)))
+		}
+	}
+	return buf.Bytes()
+}
+
+// Regenerates the configs that ctlptl passes to the cluster tool.
+func (c *Controller) bundleGeneratedConfigs(ctx context.Context, b *bundleWriter, cluster *api.Cluster) {
+	admin, err := c.admin(ctx, clusterid.Product(cluster.Product))
+	if err != nil {
+		b.add("generated", nil, err)
+		return
+	}
+	generator, ok := admin.(AdminWithGeneratedConfig)
+	if !ok {
+		return
+	}
+
+	var reg *api.Registry
+	if cluster.Registry != "" {
+		regCtl, err := c.registryController(ctx)
+		if err == nil {
+			reg, err = findRegistry(ctx, regCtl, cluster.Registry)
+		}
+		if err != nil {
+			b.add("generated", nil, fmt.Errorf("finding registry %s: %v", cluster.Registry, err))
+		}
+	}
+
+	files, err := generator.GeneratedConfig(ctx, redactCluster(cluster), reg)
+	if err != nil {
+		b.add("generated", nil, err)
+		return
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.add(path.Join("generated", name), files[name], nil)
+	}
+}
+
+// Collects the logs and registry config of each node container.
+func (c *Controller) bundleNodes(ctx context.Context, b *bundleWriter, cluster *api.Cluster) {
+	containers, err := c.nodeContainers(ctx, cluster.Name, false)
+	if err != nil {
+		b.add("nodes", nil, err)
+		return
+	}
+
+	// Prints each config file with a header, like `tail` does with multiple files.
+	script := fmt.Sprintf(
+		`find %s -type f 2>/dev/null | sort | while read f; do echo "==> $f <=="; cat "$f"; echo; done`,
+		strings.Join(bundleNodeConfigPaths, " "))
+
+	for _, container := range containers {
+		node := containerName(container)
+		logs, err := c.bundleCommand(ctx, "docker", "logs", "--timestamps", container.ID)
+		b.add(path.Join("nodes", node, "logs.txt"), logs, err)
+
+		config, err := c.bundleCommand(ctx, "docker", "exec", container.ID, "sh", "-c", script)
+		if err == nil {
+			// The containerd config includes any containerdConfigPatches, and
+			// registries.yaml has the registry auth, so neither is safe to copy raw.
+			config = []byte(redactYAML(redactTOML(string(config))))
+		}
+		b.add(path.Join("nodes", node, "registry-config.txt"), config, err)
+	}
+}
+
+// Runs a command, and returns its combined output.
+func (c *Controller) bundleCommand(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+	out := bytes.NewBuffer(nil)
+	err := c.runner.RunIO(ctx, genericclioptions.IOStreams{Out: out, ErrOut: out}, cmd, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %v: %s", cmd, strings.Join(args, " "), err, strings.TrimSpace(out.String()))
+	}
+	return out.Bytes(), nil
+}
+
+// Returns a copy of the cluster with anything that looks like a secret removed.
+func redactCluster(cluster *api.Cluster) *api.Cluster {
+	result := cluster.DeepCopy()
+	for key := range result.Annotations {
+		if secretNameRe.MatchString(key) {
+			result.Annotations[key] = redacted
+		}
+	}
+
+	if result.KubeconfigServer != "" {
+		u, err := url.Parse(result.KubeconfigServer)
+		if err == nil && u.User != nil {
+			u.User = url.User(redacted)
+			result.KubeconfigServer = u.String()
+		}
+	}
+
+	if result.Minikube != nil {
+		result.Minikube.ExtraConfigs = redactFlags(result.Minikube.ExtraConfigs)
+		result.Minikube.StartFlags = redactFlags(result.Minikube.StartFlags)
+	}
+
+	for i := range result.HelmCharts {
+		result.HelmCharts[i].Set = redactFlags(result.HelmCharts[i].Set)
+	}
+	for i, patch := range result.ContainerdConfigPatches {
+		result.ContainerdConfigPatches[i] = redactTOML(patch)
+	}
+	return result
+}
+
+// Redacts the value of any key = value line whose key looks like a secret.
+//
+// Registry auth in containerd config also has an `auth` key, with base64
+// credentials, so we redact that too.
+func redactTOML(patch string) string {
+	lines := strings.Split(patch, "\n")
+	for i, line := range lines {
+		m := tomlKeyValueRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := strings.Trim(m[2], `"`)
+		if secretNameRe.MatchString(key) || key == "auth" {
+			lines[i] = m[1] + `"` + redacted + `"`
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Redacts the value of any key: value line whose key looks like a secret,
+// including the `auth` key in k3s's registries.yaml.
+func redactYAML(config string) string {
+	lines := strings.Split(config, "\n")
+	for i, line := range lines {
+		m := yamlKeyValueRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := strings.Trim(m[2], `"`)
+		if secretNameRe.MatchString(key) || key == "auth" {
+			lines[i] = m[1] + redacted
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Redacts the value of any key=value flag whose key looks like a secret.
+func redactFlags(flags []string) []string {
+	result := make([]string, 0, len(flags))
+	for _, flag := range flags {
+		// Checks everything before the value, so that nested flags like
+		// --docker-env=REGISTRY_PASSWORD=x are caught too.
+		i := strings.LastIndex(flag, "=")
+		if i != -1 && secretNameRe.MatchString(flag[:i]) {
+			flag = flag[:i+1] + redacted
+		}
+		result = append(result, flag)
+	}
+	if len(flags) == 0 {
+		return flags
+	}
+	return result
+}
@@ -0,0 +1,234 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tilt-dev/clusterid"
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/tilt-dev/ctlptl/internal/egress"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+const defaultCalicoVersion = "3.26.1"
+
+// Kind's default pod subnet, when the kind config doesn't set one.
+const kindDefaultPodSubnet = "10.244.0.0/16"
+
+// The Calico manifest, by version.
+var calicoManifestURL = "https://raw.githubusercontent.com/projectcalico/calico/v%s/manifests/calico.yaml"
+
+var calicoVersionRe = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+var yamlDocumentSeparatorRe = regexp.MustCompile(`(?m)^---\s*$`)
+
+func validateNetworkCalico(desired *api.Cluster) error {
+	if clusterid.Product(desired.Product) != clusterid.ProductKIND {
+		return fmt.Errorf("networkCalico may only be set on clusters with product: kind. Actual product: %s", desired.Product)
+	}
+
+	spec := desired.NetworkCalico
+	version := strings.TrimPrefix(spec.Version, "v")
+	if version != "" && !calicoVersionRe.MatchString(version) {
+		return fmt.Errorf("networkCalico.version must be a release version like 3.26.1. Actual: %s", spec.Version)
+	}
+
+	switch spec.IPIPMode {
+	case "", "Always", "CrossSubnet", "Never":
+	default:
+		return fmt.Errorf("networkCalico.ipipMode must be one of: Always, CrossSubnet, Never. Actual: %s", spec.IPIPMode)
+	}
+	if spec.VXLAN && spec.IPIPMode != "" && spec.IPIPMode != "Never" {
+		return fmt.Errorf("networkCalico.vxlan can't be combined with ipipMode: %s. Calico uses one encapsulation per pool", spec.IPIPMode)
+	}
+	return nil
+}
+
+func calicoVersion(spec *api.CalicoSpec) string {
+	version := strings.TrimPrefix(spec.Version, "v")
+	if version == "" {
+		return defaultCalicoVersion
+	}
+	return version
+}
+
+func calicoPodCIDR(kindConfig *v1alpha4.Cluster) string {
+	if kindConfig.Networking.PodSubnet != "" {
+		return kindConfig.Networking.PodSubnet
+	}
+	return kindDefaultPodSubnet
+}
+
+// Installs Calico into a kind cluster created with the default CNI disabled.
+func (a *kindAdmin) installCalico(ctx context.Context, desired *api.Cluster) error {
+	spec := desired.NetworkCalico
+	podCIDR := calicoPodCIDR(a.kindClusterConfig(desired, nil))
+	_, _ = fmt.Fprintf(a.iostreams.ErrOut, "   Installing Calico v%s with pod CIDR %s\n", calicoVersion(spec), podCIDR)
+
+	manifest, err := calicoManifest(ctx, spec, podCIDR)
+	if err != nil {
+		return errors.Wrap(err, "installing calico")
+	}
+
+	cmd := exec.CommandContext(ctx, "kubectl", "--context", desired.Name, "apply", "-f", "-")
+	cmd.Stdin = bytes.NewReader(manifest)
+	cmd.Stdout = a.iostreams.Out
+	cmd.Stderr = a.iostreams.ErrOut
+	err = cmd.Run()
+	if err != nil {
+		return errors.Wrap(err, "installing calico")
+	}
+	return nil
+}
+
+// Fetches the Calico manifest, and customizes it for the cluster.
+//
+// calico-node creates the default IP pool from its environment the first time
+// it starts, so we set the pool CIDR and encapsulation there before applying,
+// rather than patching the pool afterwards.
+func calicoManifest(ctx context.Context, spec *api.CalicoSpec, podCIDR string) ([]byte, error) {
+	url := fmt.Sprintf(calicoManifestURL, calicoVersion(spec))
+	err := egress.CheckURL("fetching the calico manifest", url)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %v", url, err)
+	}
+
+	docs := yamlDocumentSeparatorRe.Split(string(data), -1)
+	result := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		var meta struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		err := yaml.Unmarshal([]byte(doc), &meta)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %v", url, err)
+		}
+
+		switch {
+		case meta.Kind == "DaemonSet" && meta.Metadata.Name == "calico-node":
+			doc, err = customizeCalicoNode(doc, spec, podCIDR)
+		case meta.Kind == "ConfigMap" && meta.Metadata.Name == "calico-config" && spec.VXLAN:
+			doc, err = customizeCalicoConfig(doc)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, doc)
+	}
+	return []byte(strings.Join(result, "\n---\n")), nil
+}
+
+func customizeCalicoNode(doc string, spec *api.CalicoSpec, podCIDR string) (string, error) {
+	var ds appsv1.DaemonSet
+	err := sigsyaml.Unmarshal([]byte(doc), &ds)
+	if err != nil {
+		return "", fmt.Errorf("decoding calico-node: %v", err)
+	}
+
+	found := false
+	containers := ds.Spec.Template.Spec.Containers
+	for i := range containers {
+		c := &containers[i]
+		if c.Name != "calico-node" {
+			continue
+		}
+		found = true
+
+		setEnv(c, "CALICO_IPV4POOL_CIDR", podCIDR)
+		if spec.VXLAN {
+			setEnv(c, "CALICO_IPV4POOL_IPIP", "Never")
+			setEnv(c, "CALICO_IPV4POOL_VXLAN", "Always")
+
+			// The VXLAN backend doesn't run BIRD, so the BIRD health checks never pass.
+			removeProbeArgs(c.LivenessProbe, "-bird-live")
+			removeProbeArgs(c.ReadinessProbe, "-bird-ready")
+		} else if spec.IPIPMode != "" {
+			setEnv(c, "CALICO_IPV4POOL_IPIP", spec.IPIPMode)
+		}
+
+		if len(spec.CalicoNodeResources.Limits) > 0 || len(spec.CalicoNodeResources.Requests) > 0 {
+			c.Resources = *spec.CalicoNodeResources.DeepCopy()
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("calico-node DaemonSet has no calico-node container")
+	}
+
+	out, err := sigsyaml.Marshal(ds)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func customizeCalicoConfig(doc string) (string, error) {
+	var cm corev1.ConfigMap
+	err := sigsyaml.Unmarshal([]byte(doc), &cm)
+	if err != nil {
+		return "", fmt.Errorf("decoding calico-config: %v", err)
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data["calico_backend"] = "vxlan"
+
+	out, err := sigsyaml.Marshal(cm)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func setEnv(c *corev1.Container, name, value string) {
+	for i, env := range c.Env {
+		if env.Name == name {
+			c.Env[i] = corev1.EnvVar{Name: name, Value: value}
+			return
+		}
+	}
+	c.Env = append(c.Env, corev1.EnvVar{Name: name, Value: value})
+}
+
+func removeProbeArgs(probe *corev1.Probe, arg string) {
+	if probe == nil || probe.Exec == nil {
+		return
+	}
+	command := []string{}
+	for _, a := range probe.Exec.Command {
+		if a != arg {
+			command = append(command, a)
+		}
+	}
+	probe.Exec.Command = command
+}
@@ -0,0 +1,268 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+const fakeCalicoManifest = `---
+# Source: calico/templates/calico-config.yaml
+kind: ConfigMap
+apiVersion: v1
+metadata:
+  name: calico-config
+  namespace: kube-system
+data:
+  calico_backend: "bird"
+---
+kind: DaemonSet
+apiVersion: apps/v1
+metadata:
+  name: calico-node
+  namespace: kube-system
+spec:
+  selector:
+    matchLabels:
+      k8s-app: calico-node
+  template:
+    spec:
+      containers:
+        - name: calico-node
+          image: docker.io/calico/node:v3.26.1
+          env:
+            - name: CALICO_IPV4POOL_IPIP
+              value: "Always"
+            - name: CALICO_IPV4POOL_VXLAN
+              value: "Never"
+          livenessProbe:
+            exec:
+              command:
+              - /bin/calico-node
+              - -felix-live
+              - -bird-live
+          readinessProbe:
+            exec:
+              command:
+              - /bin/calico-node
+              - -felix-ready
+              - -bird-ready
+`
+
+func serveFakeCalicoManifest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3.25.0/calico.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(fakeCalicoManifest))
+	}))
+	t.Cleanup(server.Close)
+
+	oldURL := calicoManifestURL
+	calicoManifestURL = server.URL + "/v%s/calico.yaml"
+	t.Cleanup(func() { calicoManifestURL = oldURL })
+}
+
+// Returns the calico-node container and calico-config data from a manifest.
+func decodeCalicoManifest(t *testing.T, manifest []byte) (corev1.Container, map[string]string) {
+	docs := yamlDocumentSeparatorRe.Split(string(manifest), -1)
+	var container corev1.Container
+	var config map[string]string
+	for _, doc := range docs {
+		if strings.Contains(doc, "kind: DaemonSet") {
+			var ds appsv1.DaemonSet
+			require.NoError(t, sigsyaml.Unmarshal([]byte(doc), &ds))
+			container = ds.Spec.Template.Spec.Containers[0]
+		}
+		if strings.Contains(doc, "kind: ConfigMap") {
+			var cm corev1.ConfigMap
+			require.NoError(t, sigsyaml.Unmarshal([]byte(doc), &cm))
+			config = cm.Data
+		}
+	}
+	return container, config
+}
+
+func envValue(c corev1.Container, name string) string {
+	for _, env := range c.Env {
+		if env.Name == name {
+			return env.Value
+		}
+	}
+	return ""
+}
+
+func TestCalicoManifest(t *testing.T) {
+	serveFakeCalicoManifest(t)
+
+	spec := &api.CalicoSpec{
+		Version:  "v3.25.0",
+		IPIPMode: "CrossSubnet",
+		CalicoNodeResources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")},
+		},
+	}
+	manifest, err := calicoManifest(context.Background(), spec, "10.100.0.0/16")
+	require.NoError(t, err)
+
+	container, config := decodeCalicoManifest(t, manifest)
+	assert.Equal(t, "10.100.0.0/16", envValue(container, "CALICO_IPV4POOL_CIDR"))
+	assert.Equal(t, "CrossSubnet", envValue(container, "CALICO_IPV4POOL_IPIP"))
+	assert.Equal(t, "Never", envValue(container, "CALICO_IPV4POOL_VXLAN"))
+	assert.Equal(t, "250m", container.Resources.Requests.Cpu().String())
+	assert.Contains(t, container.LivenessProbe.Exec.Command, "-bird-live")
+	assert.Equal(t, "bird", config["calico_backend"])
+}
+
+func TestCalicoManifestVXLAN(t *testing.T) {
+	serveFakeCalicoManifest(t)
+
+	manifest, err := calicoManifest(context.Background(), &api.CalicoSpec{Version: "3.25.0", VXLAN: true}, kindDefaultPodSubnet)
+	require.NoError(t, err)
+
+	container, config := decodeCalicoManifest(t, manifest)
+	assert.Equal(t, kindDefaultPodSubnet, envValue(container, "CALICO_IPV4POOL_CIDR"))
+	assert.Equal(t, "Never", envValue(container, "CALICO_IPV4POOL_IPIP"))
+	assert.Equal(t, "Always", envValue(container, "CALICO_IPV4POOL_VXLAN"))
+	assert.Equal(t, []string{"/bin/calico-node", "-felix-live"}, container.LivenessProbe.Exec.Command)
+	assert.Equal(t, []string{"/bin/calico-node", "-felix-ready"}, container.ReadinessProbe.Exec.Command)
+	assert.Equal(t, "vxlan", config["calico_backend"])
+}
+
+func TestCalicoManifestNotFound(t *testing.T) {
+	serveFakeCalicoManifest(t)
+
+	_, err := calicoManifest(context.Background(), &api.CalicoSpec{Version: "3.0.0"}, kindDefaultPodSubnet)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "404 Not Found")
+	}
+}
+
+func TestKindConfigNetworkCalico(t *testing.T) {
+	iostreams := genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
+	a := newKindAdmin(iostreams, &fakeDockerClient{})
+
+	desired := &api.Cluster{
+		Product:       string(clusterid.ProductKIND),
+		NetworkCalico: &api.CalicoSpec{},
+		KindV1Alpha4Cluster: &v1alpha4.Cluster{
+			Networking: v1alpha4.Networking{PodSubnet: "192.168.0.0/16"},
+		},
+	}
+	config := a.kindClusterConfig(desired, nil)
+	assert.True(t, config.Networking.DisableDefaultCNI)
+	assert.Equal(t, "192.168.0.0/16", calicoPodCIDR(config))
+
+	// Doesn't modify the desired config.
+	assert.False(t, desired.KindV1Alpha4Cluster.Networking.DisableDefaultCNI)
+}
+
+func TestValidateNetworkCalico(t *testing.T) {
+	for _, tc := range []struct {
+		product string
+		spec    api.CalicoSpec
+		err     string
+	}{
+		{"kind", api.CalicoSpec{}, ""},
+		{"kind", api.CalicoSpec{Version: "v3.26.1", IPIPMode: "Never", VXLAN: true}, ""},
+		{"minikube", api.CalicoSpec{}, "networkCalico may only be set on clusters with product: kind"},
+		{"kind", api.CalicoSpec{Version: "latest"}, "networkCalico.version must be a release version"},
+		{"kind", api.CalicoSpec{IPIPMode: "Sometimes"}, "networkCalico.ipipMode must be one of"},
+		{"kind", api.CalicoSpec{IPIPMode: "Always", VXLAN: true}, "can't be combined with ipipMode: Always"},
+	} {
+		t.Run(fmt.Sprintf("%s-%+v", tc.product, tc.spec), func(t *testing.T) {
+			spec := tc.spec
+			err := validateNetworkCalico(&api.Cluster{Product: tc.product, NetworkCalico: &spec})
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestCalicoSpecYAML(t *testing.T) {
+	var cluster api.Cluster
+	err := yaml.Unmarshal([]byte(`
+product: kind
+networkCalico:
+  version: 3.26.1
+  vxlan: true
+  calicoNodeResources:
+    requests:
+      cpu: 100m
+    limits:
+      memory: 1Gi
+`), &cluster)
+	require.NoError(t, err)
+	assert.True(t, cluster.NetworkCalico.VXLAN)
+	assert.Equal(t, "100m", cluster.NetworkCalico.CalicoNodeResources.Requests.Cpu().String())
+	assert.Equal(t, "1Gi", cluster.NetworkCalico.CalicoNodeResources.Limits.Memory().String())
+
+	out, err := yaml.Marshal(cluster)
+	require.NoError(t, err)
+	var roundTrip api.Cluster
+	require.NoError(t, yaml.Unmarshal(out, &roundTrip))
+	assert.Equal(t, cluster.NetworkCalico.Version, roundTrip.NetworkCalico.Version)
+	assert.Equal(t, "100m", roundTrip.NetworkCalico.CalicoNodeResources.Requests.Cpu().String())
+
+	out, err = yaml.Marshal(api.CalicoSpec{Version: "3.26.1"})
+	require.NoError(t, err)
+	assert.Equal(t, "version: 3.26.1\n", string(out))
+
+	err = yaml.Unmarshal([]byte("networkCalico:\n  vxlna: true\n"), &cluster)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `unknown field "vxlna"`)
+	}
+}
+
+func TestClusterApplyNetworkCalico(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	kindAdmin := f.newFakeAdmin(clusterid.ProductKIND)
+
+	cluster := &api.Cluster{
+		Product: string(clusterid.ProductKIND),
+		NetworkCalico: &api.CalicoSpec{
+			Version: "3.26.1",
+			CalicoNodeResources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")},
+			},
+		},
+	}
+	_, err := f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	kindAdmin.created = nil
+
+	_, err = f.controller.Apply(context.Background(), cluster.DeepCopy())
+	require.NoError(t, err)
+	assert.Nil(t, kindAdmin.created)
+	assert.Nil(t, kindAdmin.deleted)
+
+	cluster.NetworkCalico.VXLAN = true
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.deleted.Name)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	assert.Contains(t, f.errOut.String(), "desired Calico config does not match current")
+}
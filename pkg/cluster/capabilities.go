@@ -0,0 +1,75 @@
+package cluster
+
+import (
+	"strings"
+
+	"github.com/tilt-dev/clusterid"
+)
+
+// The products that ctlptl can create clusters with.
+var managedProducts = []clusterid.Product{
+	clusterid.ProductDockerDesktop,
+	clusterid.ProductKIND,
+	clusterid.ProductK3D,
+	clusterid.ProductMinikube,
+	ProductKwok,
+}
+
+// External clusters only support the fields that identify them.
+var allProducts = []clusterid.Product{
+	clusterid.ProductDockerDesktop,
+	clusterid.ProductKIND,
+	clusterid.ProductK3D,
+	clusterid.ProductMinikube,
+	ProductKwok,
+	ProductExternal,
+}
+
+// The products that support each cluster field, keyed by its JSON name.
+// Mirrors the product checks in Apply. Fields that aren't listed work with
+// every product that ctlptl creates.
+var fieldProducts = map[string][]clusterid.Product{
+	"kind":       allProducts,
+	"apiVersion": allProducts,
+	"name":       allProducts,
+	"product":    allProducts,
+
+	"registry":          {clusterid.ProductKIND, clusterid.ProductK3D, clusterid.ProductMinikube},
+	"kubernetesVersion": {clusterid.ProductKIND, clusterid.ProductMinikube},
+	"workers":           {ProductKwok},
+
+	"kindV1Alpha4Cluster":     {clusterid.ProductKIND},
+	"kindOptions":             {clusterid.ProductKIND},
+	"etcdBackup":              {clusterid.ProductKIND},
+	"apiServerCertSANs":       {clusterid.ProductKIND},
+	"enableAdmissionPlugins":  {clusterid.ProductKIND},
+	"disableAdmissionPlugins": {clusterid.ProductKIND},
+	"encryptionAtRest":        {clusterid.ProductKIND},
+	"networkCalico":           {clusterid.ProductKIND},
+	"loadBalancer":            {clusterid.ProductKIND},
+
+	"kindControlPlaneImage":   {clusterid.ProductKIND, clusterid.ProductK3D},
+	"kindWorkerImage":         {clusterid.ProductKIND, clusterid.ProductK3D},
+	"kubeletArgs":             {clusterid.ProductKIND, clusterid.ProductK3D},
+	"containerdConfigPatches": {clusterid.ProductKIND, clusterid.ProductK3D},
+	"extraHosts":              {clusterid.ProductKIND, clusterid.ProductK3D},
+
+	"k3dOptions": {clusterid.ProductK3D},
+	"minikube":   {clusterid.ProductMinikube},
+}
+
+// SupportedProducts returns the products that support the cluster field with
+// the given JSON path (e.g., networkCalico.version). Nested fields work with
+// the same products as the top-level field that contains them.
+//
+// Returns nil for status fields, which ctlptl reports rather than applies.
+func SupportedProducts(fieldPath string) []clusterid.Product {
+	top, _, _ := strings.Cut(fieldPath, ".")
+	if top == "status" {
+		return nil
+	}
+	if products, ok := fieldProducts[top]; ok {
+		return products
+	}
+	return managedProducts
+}
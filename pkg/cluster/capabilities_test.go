@@ -0,0 +1,25 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestSupportedProducts(t *testing.T) {
+	assert.Equal(t, []clusterid.Product{clusterid.ProductKIND}, SupportedProducts("networkCalico.version"))
+	assert.Equal(t, []clusterid.Product{ProductKwok}, SupportedProducts("workers"))
+	assert.Equal(t, managedProducts, SupportedProducts("hosts"))
+	assert.Contains(t, SupportedProducts("name"), ProductExternal)
+	assert.Nil(t, SupportedProducts("status.cpus"))
+}
+
+func TestFieldProductsAreClusterFields(t *testing.T) {
+	for field := range fieldProducts {
+		_, err := api.Explain("cluster." + field)
+		assert.NoError(t, err)
+	}
+}
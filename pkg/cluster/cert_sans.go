@@ -0,0 +1,59 @@
+package cluster
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func validateAPIServerCertSANs(desired *api.Cluster) error {
+	if clusterid.Product(desired.Product) != clusterid.ProductKIND {
+		return fmt.Errorf("apiServerCertSANs may only be set on clusters with product: kind. Actual product: %s", desired.Product)
+	}
+	for _, san := range desired.APIServerCertSANs {
+		if net.ParseIP(san) != nil {
+			continue
+		}
+		errs := validation.IsDNS1123Subdomain(strings.TrimPrefix(san, "*."))
+		if len(errs) > 0 {
+			return fmt.Errorf("invalid apiServerCertSANs entry %q: must be an IP address or hostname: %s", san, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// Compares two lists of SANs, ignoring order.
+func apiServerCertSANsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A kubeadm config patch that adds SANs to the apiserver's serving certificate.
+//
+// Kind merges this into the ClusterConfiguration it generates for kubeadm.
+func apiServerCertSANsPatch(sans []string) string {
+	var b strings.Builder
+	b.WriteString("kind: ClusterConfiguration\napiServer:\n  certSANs:\n")
+	for _, san := range sans {
+		b.WriteString(fmt.Sprintf("  - %s\n", strconv.Quote(san)))
+	}
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"gopkg.in/yaml.v3"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestClusterApplyAPIServerCertSANs(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	kindAdmin := f.newFakeAdmin(clusterid.ProductKIND)
+
+	cluster := &api.Cluster{
+		Product:           string(clusterid.ProductKIND),
+		APIServerCertSANs: []string{"my-laptop.local", "192.168.1.20"},
+	}
+	_, err := f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	kindAdmin.created = nil
+
+	// Re-applying the same SANs, in any order, doesn't re-create.
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:           string(clusterid.ProductKIND),
+		APIServerCertSANs: []string{"192.168.1.20", "my-laptop.local"},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, kindAdmin.created)
+	assert.Nil(t, kindAdmin.deleted)
+
+	f.errOut.Truncate(0)
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:           string(clusterid.ProductKIND),
+		APIServerCertSANs: []string{"my-laptop.local"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.deleted.Name)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	assert.Contains(t, f.errOut.String(), "desired apiserver certificate SANs (my-laptop.local) do not match current")
+}
+
+func TestClusterApplyAPIServerCertSANsInvalid(t *testing.T) {
+	f := newFixture(t)
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:           string(clusterid.ProductMinikube),
+		APIServerCertSANs: []string{"my-laptop.local"},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "apiServerCertSANs may only be set on clusters with product: kind")
+	}
+
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:           string(clusterid.ProductKIND),
+		APIServerCertSANs: []string{"https://my-laptop.local:6443"},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid apiServerCertSANs entry "https://my-laptop.local:6443"`)
+	}
+}
+
+func TestKindConfigAPIServerCertSANs(t *testing.T) {
+	iostreams := genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
+	a := newKindAdmin(iostreams, &fakeDockerClient{})
+
+	config := a.kindClusterConfig(&api.Cluster{
+		Product:           string(clusterid.ProductKIND),
+		APIServerCertSANs: []string{"my-laptop.local", "10.0.0.5"},
+	}, nil)
+	require.Len(t, config.KubeadmConfigPatches, 1)
+
+	var patch struct {
+		Kind      string `yaml:"kind"`
+		APIServer struct {
+			CertSANs []string `yaml:"certSANs"`
+		} `yaml:"apiServer"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(config.KubeadmConfigPatches[0]), &patch))
+	assert.Equal(t, "ClusterConfiguration", patch.Kind)
+	assert.Equal(t, []string{"my-laptop.local", "10.0.0.5"}, patch.APIServer.CertSANs)
+}
@@ -0,0 +1,223 @@
+package cluster
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/tilt-dev/clusterid"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+const kubeadmPKIPath = "/etc/kubernetes/pki"
+
+// Certificates in the kubeadm PKI directory of a control-plane node,
+// keyed by the name we report them under.
+var kubeadmCertFiles = map[string]string{
+	"ca":                       "ca.crt",
+	"apiserver":                "apiserver.crt",
+	"apiserver-kubelet-client": "apiserver-kubelet-client.crt",
+	"front-proxy-ca":           "front-proxy-ca.crt",
+	"front-proxy-client":       "front-proxy-client.crt",
+	"etcd-ca":                  "etcd/ca.crt",
+}
+
+// GetCerts returns the certificates that secure the named cluster,
+// keyed by role (e.g., ca, apiserver, client).
+//
+// Certificates are read from wherever the product keeps them. The ca and client
+// certificates fall back to the kubeconfig, so every product reports at least those.
+func (c *Controller) GetCerts(ctx context.Context, name string) (map[string]*x509.Certificate, error) {
+	product, err := c.productFromConfig(name)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make(map[string]*x509.Certificate)
+	switch product {
+	case clusterid.ProductKIND:
+		err = c.addKindCerts(ctx, name, certs)
+	case clusterid.ProductMinikube:
+		err = addMinikubeCerts(name, certs)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.addKubeconfigCerts(name, certs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("cluster %s: no certificates found", name)
+	}
+	return certs, nil
+}
+
+// Copies the certificates out of the kind control-plane node.
+func (c *Controller) addKindCerts(ctx context.Context, name string, certs map[string]*x509.Certificate) error {
+	containerID, err := c.GetContainerID(ctx, name, "")
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(kubeadmCertFiles))
+	for key := range kubeadmCertFiles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		// `docker cp` to stdout writes a tar archive.
+		out := bytes.NewBuffer(nil)
+		streams := genericclioptions.IOStreams{Out: out, ErrOut: c.iostreams.ErrOut}
+		src := fmt.Sprintf("%s:%s", containerID, path.Join(kubeadmPKIPath, kubeadmCertFiles[key]))
+		err := c.runner.RunIO(ctx, streams, "docker", "cp", src, "-")
+		if err != nil {
+			return fmt.Errorf("copying %s: %v", src, err)
+		}
+
+		data, err := readFirstTarFile(out)
+		if err != nil {
+			return fmt.Errorf("reading %s: %v", src, err)
+		}
+
+		cert, err := parseCert(data)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %v", src, err)
+		}
+		certs[key] = cert
+	}
+	return nil
+}
+
+func minikubeHome() (string, error) {
+	if dir := os.Getenv("MINIKUBE_HOME"); dir != "" {
+		// MINIKUBE_HOME may point at the .minikube dir or its parent.
+		if filepath.Base(dir) == ".minikube" {
+			return dir, nil
+		}
+		return filepath.Join(dir, ".minikube"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".minikube"), nil
+}
+
+// Reads the certificates from the minikube home directory.
+func addMinikubeCerts(name string, certs map[string]*x509.Certificate) error {
+	dir, err := minikubeHome()
+	if err != nil {
+		return err
+	}
+
+	files := map[string]string{
+		"ca":              filepath.Join(dir, "ca.crt"),
+		"proxy-client-ca": filepath.Join(dir, "proxy-client-ca.crt"),
+		"apiserver":       filepath.Join(dir, "profiles", name, "apiserver.crt"),
+		"client":          filepath.Join(dir, "profiles", name, "client.crt"),
+	}
+	for key, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		cert, err := parseCert(data)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %v", file, err)
+		}
+		certs[key] = cert
+	}
+	return nil
+}
+
+// Reads the CA and client certificates from the kubeconfig,
+// unless we already found them elsewhere.
+func (c *Controller) addKubeconfigCerts(name string, certs map[string]*x509.Certificate) error {
+	config := c.configCopy()
+	ct, ok := config.Contexts[c.contextName(name)]
+	if !ok {
+		return apierrors.NewNotFound(groupResource, name)
+	}
+
+	if cl, ok := config.Clusters[ct.Cluster]; ok && certs["ca"] == nil {
+		cert, err := parseCertDataOrFile(cl.CertificateAuthorityData, cl.CertificateAuthority)
+		if err != nil {
+			return fmt.Errorf("reading certificate-authority: %v", err)
+		}
+		if cert != nil {
+			certs["ca"] = cert
+		}
+	}
+
+	if authInfo, ok := config.AuthInfos[ct.AuthInfo]; ok && certs["client"] == nil {
+		cert, err := parseCertDataOrFile(authInfo.ClientCertificateData, authInfo.ClientCertificate)
+		if err != nil {
+			return fmt.Errorf("reading client-certificate: %v", err)
+		}
+		if cert != nil {
+			certs["client"] = cert
+		}
+	}
+	return nil
+}
+
+func parseCertDataOrFile(data []byte, file string) (*x509.Certificate, error) {
+	if len(data) == 0 && file != "" {
+		var err error
+		data, err = os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return parseCert(data)
+}
+
+// Parses the first PEM-encoded certificate in data.
+func parseCert(data []byte) (*x509.Certificate, error) {
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM certificate found")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+		data = rest
+	}
+}
+
+func readFirstTarFile(r io.Reader) ([]byte, error) {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("empty archive")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg {
+			return io.ReadAll(tr)
+		}
+	}
+}
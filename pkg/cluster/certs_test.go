@@ -0,0 +1,97 @@
+package cluster
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+)
+
+func TestGetCertsKind(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+	f.controller.runner = exec.NewFakeCmdRunner(func(argv []string) string {
+		src := argv[2]
+		switch {
+		case strings.HasSuffix(src, "/pki/apiserver.crt"):
+			return tarFile(t, "apiserver.crt", readTestCert(t, "apiserver.crt"))
+		case strings.HasSuffix(src, "ca.crt"):
+			return tarFile(t, "ca.crt", readTestCert(t, "ca.crt"))
+		default:
+			return tarFile(t, "client.crt", readTestCert(t, "client.crt"))
+		}
+	})
+
+	certs, err := f.controller.GetCerts(context.Background(), "kind-foo")
+	require.NoError(t, err)
+	assert.Equal(t, "kubernetes", certs["ca"].Subject.CommonName)
+	assert.Equal(t, "kube-apiserver", certs["apiserver"].Subject.CommonName)
+	assert.Contains(t, certs["apiserver"].DNSNames, "kubernetes.default")
+	assert.Equal(t, "127.0.0.1", certs["apiserver"].IPAddresses[0].String())
+	assert.Contains(t, certs, "front-proxy-client")
+}
+
+func TestGetCertsMinikube(t *testing.T) {
+	f := newFixture(t)
+	f.config.Contexts["minikube"] = &clientcmdapi.Context{Cluster: "minikube"}
+	f.config.Clusters["minikube"] = &clientcmdapi.Cluster{Server: "https://192.168.49.2:8443"}
+
+	dir := filepath.Join(t.TempDir(), ".minikube")
+	t.Setenv("MINIKUBE_HOME", dir)
+	writeTestCert(t, filepath.Join(dir, "ca.crt"), "ca.crt")
+	writeTestCert(t, filepath.Join(dir, "profiles", "minikube", "apiserver.crt"), "apiserver.crt")
+	writeTestCert(t, filepath.Join(dir, "profiles", "minikube", "client.crt"), "client.crt")
+
+	certs, err := f.controller.GetCerts(context.Background(), "minikube")
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(certs))
+	assert.Equal(t, "kubernetes", certs["ca"].Subject.CommonName)
+	assert.Equal(t, "kube-apiserver", certs["apiserver"].Subject.CommonName)
+	assert.Equal(t, "kubernetes-admin", certs["client"].Subject.CommonName)
+}
+
+func TestGetCertsDockerDesktop(t *testing.T) {
+	f := newFixture(t)
+	f.config.Contexts["docker-desktop"].AuthInfo = "docker-desktop"
+	f.config.Clusters["docker-desktop"].CertificateAuthorityData = readTestCert(t, "ca.crt")
+	f.controller.config.AuthInfos = map[string]*clientcmdapi.AuthInfo{
+		"docker-desktop": {ClientCertificateData: readTestCert(t, "client.crt")},
+	}
+
+	certs, err := f.controller.GetCerts(context.Background(), "docker-desktop")
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(certs))
+	assert.Equal(t, "kubernetes", certs["ca"].Subject.CommonName)
+	assert.Equal(t, "kubernetes-admin", certs["client"].Subject.CommonName)
+	assert.Equal(t, []string{"system:masters"}, certs["client"].Subject.Organization)
+}
+
+func readTestCert(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", "certs", name))
+	require.NoError(t, err)
+	return data
+}
+
+func writeTestCert(t *testing.T, path, name string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, readTestCert(t, name), 0644))
+}
+
+func tarFile(t *testing.T, name string, data []byte) string {
+	buf := bytes.NewBuffer(nil)
+	tw := tar.NewWriter(buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	return buf.String()
+}
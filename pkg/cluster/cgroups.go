@@ -0,0 +1,118 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"k8s.io/klog/v2"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/docker"
+)
+
+// Kind v0.11.0 is the first release that starts nodes in their own cgroup
+// namespace, which systemd in the node needs on cgroup v2 hosts.
+var kindCgroupV2MinVersion = semver.MustParse("0.11.0")
+
+// The cgroup controllers that kind nodes need on cgroup v2.
+var kindCgroupV2Controllers = []string{"cpu", "cpuset", "io", "memory", "pids"}
+
+// Where the host mounts the cgroup v2 hierarchy.
+const defaultCgroupRoot = "/sys/fs/cgroup"
+
+// Checks that the installed kind, and the host's cgroup delegation,
+// can boot nodes on a cgroup v2 host.
+func (a *kindAdmin) checkCgroupV2(ctx context.Context, isRootless bool) error {
+	kindVersion, err := a.getKindVersion(ctx)
+	if err != nil {
+		// We'll find out soon enough when kind runs.
+		klog.V(4).Infof("WARNING: reading kind version: %v\n", err)
+	} else {
+		err := checkKindCgroupV2Support(kindVersion)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Rootless Docker runs in the user's systemd session, so it only gets the
+	// controllers that systemd delegates to the user. We can only check that
+	// on a local Linux host.
+	if isRootless && runtime.GOOS == "linux" && docker.IsLocalHost(a.dockerClient.DaemonHost()) {
+		uid := os.Getuid()
+		path := filepath.Join(a.cgroupRoot, "user.slice", fmt.Sprintf("user-%d.slice", uid),
+			fmt.Sprintf("user@%d.service", uid), "cgroup.controllers")
+		return checkCgroupDelegation(path)
+	}
+	return nil
+}
+
+func checkKindCgroupV2Support(kindVersion string) error {
+	v, err := semver.ParseTolerant(kindVersion)
+	if err != nil {
+		// Probably a dev build.
+		return nil
+	}
+	if v.LT(kindCgroupV2MinVersion) {
+		return fmt.Errorf("Docker is running on a cgroup v2 host, but kind %s can't start nodes with cgroup v2.\n"+
+			"Upgrade kind to v%s or later: https://kind.sigs.k8s.io/docs/user/quick-start/#installation",
+			kindVersion, kindCgroupV2MinVersion)
+	}
+	return nil
+}
+
+// Checks that the cgroup.controllers file at path lists every controller
+// that kind nodes need.
+//
+// If the file doesn't exist (e.g., the host doesn't use systemd), we can't
+// tell, so assume delegation is set up.
+func checkCgroupDelegation(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("checking cgroup delegation: %v", err)
+	}
+
+	delegated := map[string]bool{}
+	for _, c := range strings.Fields(string(contents)) {
+		delegated[c] = true
+	}
+	missing := []string{}
+	for _, c := range kindCgroupV2Controllers {
+		if !delegated[c] {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("Rootless Docker is missing cgroup controllers that kind nodes need: %s.\n"+
+		"systemd only delegates some controllers to users by default. To delegate all of them, run:\n\n"+
+		"  sudo mkdir -p /etc/systemd/system/user@.service.d\n"+
+		"  printf '[Service]\\nDelegate=yes\\n' | sudo tee /etc/systemd/system/user@.service.d/delegate.conf\n"+
+		"  sudo systemctl daemon-reload\n\n"+
+		"Then log out and back in, and restart Docker. See:\n"+
+		"https://kind.sigs.k8s.io/docs/user/rootless/",
+		strings.Join(missing, ", "))
+}
+
+// Reads the cgroup version of the host that runs the node containers.
+func (c *Controller) populateCgroupVersion(ctx context.Context, cluster *api.Cluster) error {
+	dockerClient, err := c.getDockerClient(ctx)
+	if err != nil {
+		return err
+	}
+	info, err := dockerClient.Info(ctx)
+	if err != nil {
+		return err
+	}
+	cluster.Status.CgroupVersion = info.CgroupVersion
+	return nil
+}
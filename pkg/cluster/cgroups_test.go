@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestCheckKindCgroupV2Support(t *testing.T) {
+	assert.NoError(t, checkKindCgroupV2Support("v0.17.0"))
+	assert.NoError(t, checkKindCgroupV2Support("v0.11.0"))
+	assert.NoError(t, checkKindCgroupV2Support("dev"))
+
+	err := checkKindCgroupV2Support("v0.9.0")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "kind v0.9.0 can't start nodes with cgroup v2")
+		assert.Contains(t, err.Error(), "Upgrade kind to v0.11.0 or later")
+	}
+}
+
+func TestCheckCgroupDelegation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cgroup.controllers")
+	assert.NoError(t, checkCgroupDelegation(path))
+
+	require.NoError(t, os.WriteFile(path, []byte("cpuset cpu io memory hugetlb pids rdma misc\n"), 0644))
+	assert.NoError(t, checkCgroupDelegation(path))
+
+	require.NoError(t, os.WriteFile(path, []byte("memory pids\n"), 0644))
+	err := checkCgroupDelegation(path)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "missing cgroup controllers that kind nodes need: cpu, cpuset, io")
+		assert.Contains(t, err.Error(), "Delegate=yes")
+	}
+}
+
+func TestCheckHostCgroupsRootlessDelegation(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("cgroup delegation is only checked on linux")
+	}
+	iostreams := genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
+	dockerClient := &fakeDockerClient{
+		started:         true,
+		cgroupVersion:   "2",
+		securityOptions: []string{"name=rootless"},
+	}
+	a := newKindAdmin(iostreams, dockerClient)
+	a.cgroupRoot = t.TempDir()
+
+	uid := os.Getuid()
+	dir := filepath.Join(a.cgroupRoot, "user.slice", fmt.Sprintf("user-%d.slice", uid), fmt.Sprintf("user@%d.service", uid))
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cgroup.controllers"), []byte("memory pids\n"), 0644))
+
+	err := a.checkHostCgroups(context.Background(), &api.KindOptions{Rootless: true})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "missing cgroup controllers")
+	}
+}
+
+func TestCgroupVersionStatus(t *testing.T) {
+	f := newFixture(t)
+	f.dockerClient.started = true
+	f.dockerClient.cgroupVersion = "2"
+	f.newFakeAdmin(clusterid.ProductKIND)
+
+	cluster, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product: string(clusterid.ProductKIND),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "2", cluster.Status.CgroupVersion)
+}
@@ -0,0 +1,63 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// The CNIs that ctlptl can install in place of the product's default.
+const cniCilium = "cilium"
+
+// The chart that installs Cilium.
+var ciliumChart = api.HelmChartSpec{
+	Name:      "cilium",
+	Chart:     "cilium",
+	Repo:      "https://helm.cilium.io",
+	Namespace: "kube-system",
+	Set:       []string{"ipam.mode=kubernetes"},
+}
+
+func validateCNI(desired *api.Cluster) error {
+	if desired.CNI != cniCilium {
+		return fmt.Errorf("cni must be one of: %s. Actual: %s", cniCilium, desired.CNI)
+	}
+	if clusterid.Product(desired.Product) != clusterid.ProductKIND {
+		return fmt.Errorf("cni may only be set on clusters with product: kind. Actual product: %s", desired.Product)
+	}
+	if desired.NetworkCalico != nil {
+		return fmt.Errorf("cni: %s can't be combined with networkCalico", desired.CNI)
+	}
+	return nil
+}
+
+// Returns the Cilium chart for the cluster, with the values that enable
+// Hubble if the cluster has Hubble enabled.
+func ciliumChartFor(cluster *api.Cluster) api.HelmChartSpec {
+	chart := *ciliumChart.DeepCopy()
+	if cluster.HubbleEnabled {
+		chart.Set = append(chart.Set, hubbleValues...)
+	}
+	return chart
+}
+
+// Installs Cilium into a cluster created with the default CNI disabled,
+// or upgrades it to turn Hubble on or off.
+func (c *Controller) installCilium(ctx context.Context, desired *api.Cluster) error {
+	err := c.ApplyHelmChart(ctx, desired.Name, ciliumChartFor(desired))
+	if err != nil {
+		return err
+	}
+
+	if desired.HubbleEnabled {
+		err = c.waitForHubbleRelay(ctx, desired)
+		if err != nil {
+			return errors.Wrap(err, "enabling hubble")
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"runtime"
 	"sort"
 	"strconv"
@@ -20,9 +21,10 @@ import (
 	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v3"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/duration"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -36,6 +38,7 @@ import (
 
 	"github.com/tilt-dev/ctlptl/internal/dctr"
 	"github.com/tilt-dev/ctlptl/internal/exec"
+	"github.com/tilt-dev/ctlptl/internal/hostsfile"
 	"github.com/tilt-dev/ctlptl/internal/socat"
 	"github.com/tilt-dev/ctlptl/pkg/api"
 	"github.com/tilt-dev/ctlptl/pkg/docker"
@@ -47,6 +50,17 @@ import (
 
 const clusterSpecConfigMap = "ctlptl-cluster-spec"
 
+// ContextPrefixEnv is the environment variable that sets a prefix on every
+// kubeconfig context that ctlptl manages.
+//
+// This lets several ctlptl users share one kubeconfig (e.g., on a CI machine)
+// without stepping on each other's contexts. With a prefix of "ci-42-", the
+// cluster "kind-foo" lives in the context "ci-42-kind-foo".
+//
+// Takes priority over contextPrefix in ~/.ctlptl/config.yaml. The
+// --context-prefix flag takes priority over both.
+const ContextPrefixEnv = "CTLPTL_CONTEXT_PREFIX"
+
 var typeMeta = api.TypeMeta{APIVersion: "ctlptl.dev/v1alpha1", Kind: "Cluster"}
 var listTypeMeta = api.TypeMeta{APIVersion: "ctlptl.dev/v1alpha1", Kind: "ClusterList"}
 var groupResource = schema.GroupResource{Group: "ctlptl.dev", Resource: "clusters"}
@@ -75,6 +89,8 @@ type configLoader func() (clientcmdapi.Config, error)
 type registryController interface {
 	Apply(ctx context.Context, r *api.Registry) (*api.Registry, error)
 	List(ctx context.Context, options registry.ListOptions) (*api.RegistryList, error)
+	StartForwarder(ctx context.Context, name string, hostPort int, permanent bool) (string, int, error)
+	StopForwarder(ctx context.Context, containerName string) error
 }
 
 type clientLoader func(*rest.Config) (kubernetes.Interface, error)
@@ -88,6 +104,7 @@ type Controller struct {
 	runner                      exec.CmdRunner
 	config                      clientcmdapi.Config
 	clients                     map[string]kubernetes.Interface
+	serviceCIDRs                map[string]*net.IPNet
 	admins                      map[clusterid.Product]Admin
 	dockerClient                dockerClient
 	dmachine                    *dockerMachine
@@ -99,6 +116,16 @@ type Controller struct {
 	waitForKubeConfigTimeout    time.Duration
 	waitForClusterCreateTimeout time.Duration
 	os                          string
+	contextPrefix               string
+	strictOwnership             bool
+	outputDir                   string
+	lockDir                     string
+	externalClustersPath        string
+	clusterStatePath            string
+	helm                        helmClient
+	onHelmStatus                func(status string)
+	mirrordDir                  string
+	hostsFile                   *hostsfile.File
 
 	// TODO(nick): I deeply regret making this struct use goroutines. It makes
 	// everything so much more complex.
@@ -109,7 +136,17 @@ type Controller struct {
 	mu sync.Mutex
 }
 
+// DefaultController creates a controller with the options from the
+// environment and the ctlptl config file. See DefaultControllerOptions.
 func DefaultController(iostreams genericclioptions.IOStreams) (*Controller, error) {
+	options, err := DefaultControllerOptions()
+	if err != nil {
+		return nil, err
+	}
+	return DefaultControllerWithOptions(iostreams, options)
+}
+
+func DefaultControllerWithOptions(iostreams genericclioptions.IOStreams, options ControllerOptions) (*Controller, error) {
 	configLoader := configLoader(func() (clientcmdapi.Config, error) {
 		rules := clientcmd.NewDefaultClientConfigLoadingRules()
 		rules.DefaultClientConfig = &clientcmd.DefaultClientConfig
@@ -119,7 +156,15 @@ func DefaultController(iostreams genericclioptions.IOStreams) (*Controller, erro
 		return loader.RawConfig()
 	})
 
-	configWriter := kubeconfigWriter{iostreams: iostreams}
+	lockDir := defaultLockDir()
+	var configWriter configWriter = kubeconfigWriter{iostreams: iostreams}
+	if lockDir != "" {
+		configWriter = lockingConfigWriter{
+			writer:   configWriter,
+			lockPath: kubeconfigLockPath(lockDir, clientcmd.NewDefaultClientConfigLoadingRules().GetLoadingPrecedence()),
+			errOut:   iostreams.ErrOut,
+		}
+	}
 
 	clientLoader := clientLoader(func(restConfig *rest.Config) (kubernetes.Interface, error) {
 		return kubernetes.NewForConfig(restConfig)
@@ -136,12 +181,19 @@ func DefaultController(iostreams genericclioptions.IOStreams) (*Controller, erro
 		config:                      config,
 		configWriter:                configWriter,
 		clients:                     make(map[string]kubernetes.Interface),
+		serviceCIDRs:                make(map[string]*net.IPNet),
 		admins:                      make(map[clusterid.Product]Admin),
 		configLoader:                configLoader,
 		clientLoader:                clientLoader,
 		waitForKubeConfigTimeout:    waitForKubeConfigTimeout,
 		waitForClusterCreateTimeout: waitForClusterCreateTimeout,
 		os:                          runtime.GOOS,
+		contextPrefix:               options.ContextPrefix,
+		strictOwnership:             options.StrictOwnership,
+		lockDir:                     lockDir,
+		externalClustersPath:        defaultExternalClustersPath(),
+		clusterStatePath:            defaultClusterStatePath(),
+		hostsFile:                   hostsfile.Default(),
 	}, nil
 }
 
@@ -188,7 +240,7 @@ func (c *Controller) machine(ctx context.Context, name string, product clusterid
 	defer c.mu.Unlock()
 
 	switch product {
-	case clusterid.ProductDockerDesktop, clusterid.ProductKIND, clusterid.ProductK3D:
+	case clusterid.ProductDockerDesktop, clusterid.ProductKIND, clusterid.ProductK3D, ProductKwok:
 		if c.dmachine == nil {
 			machine, err := NewDockerMachine(ctx, dockerClient, c.iostreams)
 			if err != nil {
@@ -232,6 +284,11 @@ func (c *Controller) registryController(ctx context.Context) (registryController
 // A cluster admin provides the basic start/stop functionality of a cluster,
 // independent of the configuration of the machine it's running on.
 func (c *Controller) admin(ctx context.Context, product clusterid.Product) (Admin, error) {
+	// External clusters may not run on Docker at all.
+	if product == ProductExternal {
+		return newExternalAdmin(c.iostreams, c.externalClustersPath, c.contextName), nil
+	}
+
 	dockerClient, err := c.getDockerClient(ctx)
 	if err != nil {
 		return nil, err
@@ -259,6 +316,8 @@ func (c *Controller) admin(ctx context.Context, product clusterid.Product) (Admi
 		admin = newK3dAdmin(c.iostreams)
 	case clusterid.ProductMinikube:
 		admin = newMinikubeAdmin(c.iostreams, dockerClient, c.runner)
+	case ProductKwok:
+		admin = newKwokAdmin(c.iostreams, c.runner)
 	}
 
 	if product == "" {
@@ -302,6 +361,21 @@ func (c *Controller) currentAPIServerPort() int {
 	return port
 }
 
+// Converts a cluster name to the name of its kubeconfig context.
+func (c *Controller) contextName(name string) string {
+	return c.contextPrefix + name
+}
+
+// Converts a kubeconfig context name to a cluster name.
+//
+// Returns false if the context doesn't have the context prefix.
+func (c *Controller) clusterName(contextName string) (string, bool) {
+	if !strings.HasPrefix(contextName, c.contextPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(contextName, c.contextPrefix), true
+}
+
 func (c *Controller) configCurrent() string {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -319,7 +393,7 @@ func (c *Controller) client(name string) (kubernetes.Interface, error) {
 	}
 
 	restConfig, err := clientcmd.NewDefaultClientConfig(
-		c.config, &clientcmd.ConfigOverrides{CurrentContext: name}).ClientConfig()
+		c.config, &clientcmd.ConfigOverrides{CurrentContext: c.contextName(name)}).ClientConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -409,18 +483,29 @@ func (c *Controller) populateMachineStatus(ctx context.Context, cluster *api.Clu
 	return nil
 }
 
-func (c *Controller) populateClusterSpec(ctx context.Context, cluster *api.Cluster, client kubernetes.Interface) error {
+// Reads the cluster spec that ctlptl wrote when it created the cluster.
+//
+// Returns nil if the cluster wasn't created by ctlptl.
+func readClusterSpec(ctx context.Context, client kubernetes.Interface) (*api.Cluster, error) {
 	cMap, err := client.CoreV1().ConfigMaps("kube-public").Get(ctx, clusterSpecConfigMap, metav1.GetOptions{})
 	if err != nil {
 		if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
-			return nil
+			return nil, nil
 		}
-		return err
+		return nil, err
 	}
 
-	spec := api.Cluster{}
-	err = yaml.Unmarshal([]byte(cMap.Data["cluster.v1alpha1"]), &spec)
+	spec := &api.Cluster{}
+	err = yaml.Unmarshal([]byte(cMap.Data["cluster.v1alpha1"]), spec)
 	if err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func (c *Controller) populateClusterSpec(ctx context.Context, cluster *api.Cluster, client kubernetes.Interface) error {
+	spec, err := readClusterSpec(ctx, client)
+	if err != nil || spec == nil {
 		return err
 	}
 
@@ -428,6 +513,39 @@ func (c *Controller) populateClusterSpec(ctx context.Context, cluster *api.Clust
 	cluster.MinCPUs = spec.MinCPUs
 	cluster.KindV1Alpha4Cluster = spec.KindV1Alpha4Cluster
 	cluster.Minikube = spec.Minikube
+	cluster.KindOptions = spec.KindOptions
+	cluster.K3dOptions = spec.K3dOptions
+	cluster.EtcdBackup = spec.EtcdBackup
+	cluster.KubeconfigServer = spec.KubeconfigServer
+	cluster.APIServerCertSANs = spec.APIServerCertSANs
+	cluster.EnableAdmissionPlugins = spec.EnableAdmissionPlugins
+	cluster.DisableAdmissionPlugins = spec.DisableAdmissionPlugins
+	cluster.EncryptionAtRest = spec.EncryptionAtRest
+	cluster.KubeletArgs = spec.KubeletArgs
+	cluster.ContainerdConfigPatches = spec.ContainerdConfigPatches
+	cluster.ContainerRuntime = spec.ContainerRuntime
+	cluster.KindControlPlaneImage = spec.KindControlPlaneImage
+	cluster.KindWorkerImage = spec.KindWorkerImage
+	cluster.NetworkCalico = spec.NetworkCalico
+	cluster.CNI = spec.CNI
+	cluster.LoadBalancer = spec.LoadBalancer
+	cluster.HelmCharts = spec.HelmCharts
+	cluster.ReadinessChecks = spec.ReadinessChecks
+	cluster.ReadinessPoll = spec.ReadinessPoll
+	cluster.ServiceAccounts = spec.ServiceAccounts
+	cluster.MirrordEnabled = spec.MirrordEnabled
+	cluster.HubbleEnabled = spec.HubbleEnabled
+	cluster.Hosts = spec.Hosts
+	cluster.ExtraHosts = spec.ExtraHosts
+	cluster.CostBudget = spec.CostBudget
+	cluster.PVCStorageDriver = spec.PVCStorageDriver
+	cluster.DefaultStorageClass = spec.DefaultStorageClass
+	cluster.DefaultImagePullPolicy = spec.DefaultImagePullPolicy
+	cluster.RollingUpdateStrategy = spec.RollingUpdateStrategy
+	cluster.DefaultNamespace = spec.DefaultNamespace
+	cluster.Annotations = spec.Annotations
+	cluster.Workers = spec.Workers
+	cluster.TaintControlPlane = spec.TaintControlPlane
 	return nil
 }
 
@@ -470,6 +588,10 @@ func (c *Controller) populateCluster(ctx context.Context, cluster *api.Cluster)
 	// creates a persistent tunnel, but is probably closer to what users expect.
 	name := cluster.Name
 	product := clusterid.Product(cluster.Product)
+
+	// Runs last, so that the owner in the cluster spec takes precedence.
+	defer c.populateOwner(cluster)
+
 	if product == clusterid.ProductKIND || product == clusterid.ProductK3D || product == clusterid.ProductMinikube {
 		err := c.maybeCreateForwarderForCurrentCluster(ctx, io.Discard)
 		if err != nil {
@@ -483,6 +605,15 @@ func (c *Controller) populateCluster(ctx context.Context, cluster *api.Cluster)
 		klog.V(4).Infof("WARNING: creating cluster %s client: %v\n", name, err)
 		return
 	}
+
+	// ctlptl doesn't manage external clusters, so only check that they're reachable.
+	if product == ProductExternal {
+		v, err := c.healthCheckCluster(ctx, client)
+		if err == nil {
+			cluster.Status.KubernetesVersion = v.GitVersion
+		}
+		return
+	}
 	wg := sync.WaitGroup{}
 	ctx, cancel := context.WithCancel(ctx)
 
@@ -529,18 +660,58 @@ func (c *Controller) populateCluster(ctx context.Context, cluster *api.Cluster)
 		}
 	}()
 
+	if product == clusterid.ProductKIND || product == clusterid.ProductK3D {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := c.populateCgroupVersion(ctx, cluster)
+			if err != nil {
+				klog.V(4).Infof("WARNING: reading cluster %s cgroup version: %v\n", name, err)
+			}
+		}()
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		err := c.populateClusterSpec(ctx, cluster, client)
 		if err != nil {
 			klog.V(4).Infof("WARNING: reading cluster %s spec: %v\n", name, err)
+			return
+		}
+
+		if cluster.DefaultImagePullPolicy != "" {
+			err := c.populateImagePullPolicyStatus(ctx, cluster)
+			if err != nil {
+				klog.V(4).Infof("WARNING: reading cluster %s imagePullPolicy: %v\n", name, err)
+			}
+		}
+
+		if cluster.DefaultStorageClass != nil {
+			err := c.populateDefaultStorageClassStatus(ctx, cluster, client)
+			if err != nil {
+				klog.V(4).Infof("WARNING: reading cluster %s default StorageClass: %v\n", name, err)
+			}
+		}
+
+		if len(cluster.ReadinessChecks) > 0 {
+			c.populateReadinessChecks(ctx, cluster, client)
+		}
+
+		if cluster.MirrordEnabled {
+			err := c.populateMirrordEndpoint(ctx, cluster, client)
+			if err != nil {
+				klog.V(4).Infof("WARNING: reading cluster %s mirrord operator: %v\n", name, err)
+			}
 		}
 	}()
 
 	wg.Wait()
 
-	cluster.Status.Current = c.configCurrent() == cluster.Name
+	if cidr := c.cachedServiceCIDR(name); cidr != nil {
+		cluster.Status.ServiceCIDR = cidr.String()
+	}
+	cluster.Status.Current = c.configCurrent() == c.contextName(cluster.Name)
 }
 
 func FillDefaults(cluster *api.Cluster) {
@@ -553,7 +724,7 @@ func FillDefaults(cluster *api.Cluster) {
 	// Create a default name if one isn't in the YAML.
 	// The default name is determined by the underlying product.
 	if cluster.Name == "" {
-		cluster.Name = clusterid.Product(cluster.Product).DefaultClusterName()
+		cluster.Name = DefaultClusterName(clusterid.Product(cluster.Product))
 	}
 
 	// Override the Kind config if necessary.
@@ -596,6 +767,37 @@ func (c *Controller) canReconcileK8sVersion(ctx context.Context, desired, existi
 	return false
 }
 
+// Upgrades the cluster to the desired Kubernetes version in place, if the admin can.
+//
+// Returns false if the cluster needs to be re-created instead, including on
+// downgrades, which no product supports in place.
+func (c *Controller) upgradeK8sVersion(ctx context.Context, desired, existing *api.Cluster) (bool, error) {
+	dv, err := semver.ParseTolerant(desired.KubernetesVersion)
+	if err != nil {
+		return false, nil
+	}
+	ev, err := semver.ParseTolerant(existing.Status.KubernetesVersion)
+	if err != nil || dv.LT(ev) {
+		return false, nil
+	}
+
+	admin, err := c.admin(ctx, clusterid.Product(desired.Product))
+	if err != nil {
+		return false, err
+	}
+
+	err = admin.Upgrade(ctx, desired)
+	if errors.Is(err, ErrNotSupported) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "upgrading cluster %s", desired.Name)
+	}
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Upgraded cluster %s from Kubernetes %s to %s\n",
+		desired.Name, existing.Status.KubernetesVersion, desired.KubernetesVersion)
+	return true, nil
+}
+
 func (c *Controller) deleteIfIrreconcilable(ctx context.Context, desired, existing *api.Cluster) error {
 	if existing.Name == "" {
 		// Nothing to delete
@@ -614,6 +816,16 @@ func (c *Controller) deleteIfIrreconcilable(ctx context.Context, desired, existi
 			desired.Name, desired.Registry)
 		needsDelete = true
 	} else if !c.canReconcileK8sVersion(ctx, desired, existing) {
+		upgraded, err := c.upgradeK8sVersion(ctx, desired, existing)
+		if err != nil {
+			return err
+		}
+		if upgraded {
+			// Check whether anything else needs a new cluster.
+			existing = existing.DeepCopy()
+			existing.Status.KubernetesVersion = desired.KubernetesVersion
+			return c.deleteIfIrreconcilable(ctx, desired, existing)
+		}
 		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
 			"Deleting cluster %s because desired Kubernetes version (%s) does not match current (%s)\n",
 			desired.Name, desired.KubernetesVersion, existing.Status.KubernetesVersion)
@@ -628,13 +840,76 @@ func (c *Controller) deleteIfIrreconcilable(ctx context.Context, desired, existi
 			"Deleting cluster %s because desired Minikube config does not match current.\nCluster config diff: %s\n",
 			desired.Name, cmp.Diff(existing.Minikube, desired.Minikube))
 		needsDelete = true
+	} else if desired.KindOptions != nil && !cmp.Equal(existing.KindOptions, desired.KindOptions) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Deleting cluster %s because desired Kind options do not match current.\nCluster config diff: %s\n",
+			desired.Name, cmp.Diff(existing.KindOptions, desired.KindOptions))
+		needsDelete = true
+	} else if desired.K3dOptions != nil && !cmp.Equal(existing.K3dOptions, desired.K3dOptions) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Deleting cluster %s because desired k3d options do not match current.\nCluster config diff: %s\n",
+			desired.Name, cmp.Diff(existing.K3dOptions, desired.K3dOptions))
+		needsDelete = true
+	} else if desired.EtcdBackup != nil && desired.EtcdBackup.HostPath != etcdBackupHostPath(existing) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Deleting cluster %s to mount etcd backup directory %s\n",
+			desired.Name, desired.EtcdBackup.HostPath)
+		needsDelete = true
+	} else if desired.CNI != existing.CNI {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Deleting cluster %s because desired CNI (%s) does not match current (%s)\n",
+			desired.Name, desired.CNI, existing.CNI)
+		needsDelete = true
+	} else if desired.NetworkCalico != nil && !equality.Semantic.DeepEqual(existing.NetworkCalico, desired.NetworkCalico) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Deleting cluster %s because desired Calico config does not match current\n", desired.Name)
+		needsDelete = true
+	} else if !apiServerCertSANsEqual(existing.APIServerCertSANs, desired.APIServerCertSANs) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Deleting cluster %s because desired apiserver certificate SANs (%s) do not match current (%s)\n",
+			desired.Name, strings.Join(desired.APIServerCertSANs, ", "), strings.Join(existing.APIServerCertSANs, ", "))
+		needsDelete = true
+	} else if !admissionPluginsEqual(existing.EnableAdmissionPlugins, desired.EnableAdmissionPlugins) ||
+		!admissionPluginsEqual(existing.DisableAdmissionPlugins, desired.DisableAdmissionPlugins) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Deleting cluster %s because desired admission plugins (enabled: %s; disabled: %s) do not match current (enabled: %s; disabled: %s)\n",
+			desired.Name,
+			sortedAdmissionPlugins(desired.EnableAdmissionPlugins), sortedAdmissionPlugins(desired.DisableAdmissionPlugins),
+			sortedAdmissionPlugins(existing.EnableAdmissionPlugins), sortedAdmissionPlugins(existing.DisableAdmissionPlugins))
+		needsDelete = true
+	} else if !encryptionAtRestEqual(existing.EncryptionAtRest, desired.EncryptionAtRest) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Deleting cluster %s because desired encryption at rest (%s) does not match current (%s)\n",
+			desired.Name, describeEncryptionAtRest(desired.EncryptionAtRest), describeEncryptionAtRest(existing.EncryptionAtRest))
+		needsDelete = true
+	} else if !nodeImagesEqual(existing, desired) {
+		controlPlane, worker := nodeImages(desired)
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Deleting cluster %s to change node images to %s (control plane) and %s (workers)\n",
+			desired.Name, controlPlane, worker)
+		needsDelete = true
+	} else if !equality.Semantic.DeepEqual(existing.KubeletArgs, desired.KubeletArgs) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Deleting cluster %s because desired kubelet args do not match current.\nCluster config diff: %s\n",
+			desired.Name, cmp.Diff(existing.KubeletArgs, desired.KubeletArgs))
+		needsDelete = true
+	} else if !equality.Semantic.DeepEqual(existing.ContainerdConfigPatches, desired.ContainerdConfigPatches) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Deleting cluster %s because desired containerd config patches do not match current.\nCluster config diff: %s\n",
+			desired.Name, cmp.Diff(existing.ContainerdConfigPatches, desired.ContainerdConfigPatches))
+		needsDelete = true
+	} else if !containerRuntimeEqual(existing, desired) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Deleting cluster %s to change container runtime from %s to %s\n",
+			desired.Name, containerRuntime(existing), containerRuntime(desired))
+		needsDelete = true
 	}
 
 	if !needsDelete {
 		return nil
 	}
 
-	err := c.Delete(ctx, desired.Name)
+	err := c.deleteCluster(ctx, desired.Name)
 	if err != nil {
 		return err
 	}
@@ -649,14 +924,6 @@ func (c *Controller) ensureRegistryExistsForCluster(ctx context.Context, desired
 		return nil, nil
 	}
 
-	regLabels := map[string]string{}
-	if desired.Product == string(clusterid.ProductK3D) {
-		// A K3d cluster will only connect to a registry
-		// with these labels.
-		regLabels["app"] = "k3d"
-		regLabels["k3d.role"] = "registry"
-	}
-
 	regCtl, err := c.registryController(ctx)
 	if err != nil {
 		return nil, err
@@ -665,16 +932,30 @@ func (c *Controller) ensureRegistryExistsForCluster(ctx context.Context, desired
 	return regCtl.Apply(ctx, &api.Registry{
 		TypeMeta: registry.TypeMeta(),
 		Name:     regName,
-		Labels:   regLabels,
+		Labels:   registryLabels(clusterid.Product(desired.Product)),
 	})
 }
 
+func registryLabels(product clusterid.Product) map[string]string {
+	regLabels := map[string]string{}
+	if product == clusterid.ProductK3D {
+		// A K3d cluster will only connect to a registry
+		// with these labels.
+		regLabels["app"] = "k3d"
+		regLabels["k3d.role"] = "registry"
+	}
+	return regLabels
+}
+
 // Compare the desired cluster against the existing cluster, and reconcile
 // the two to match.
 func (c *Controller) Apply(ctx context.Context, desired *api.Cluster) (*api.Cluster, error) {
 	if desired.Product == "" {
 		return nil, fmt.Errorf("product field must be non-empty")
 	}
+	if clusterid.Product(desired.Product) == ProductExternal {
+		return c.applyExternal(ctx, desired)
+	}
 	if desired.Registry != "" && !supportsRegistry(clusterid.Product(desired.Product)) {
 		return nil, fmt.Errorf("product %s does not support a registry", desired.Product)
 	}
@@ -684,12 +965,194 @@ func (c *Controller) Apply(ctx context.Context, desired *api.Cluster) (*api.Clus
 	if desired.KindV1Alpha4Cluster != nil && clusterid.Product(desired.Product) != clusterid.ProductKIND {
 		return nil, fmt.Errorf("kind config may only be set on clusters with product: kind. Actual product: %s", desired.Product)
 	}
+	if desired.Workers != 0 && clusterid.Product(desired.Product) != ProductKwok {
+		return nil, fmt.Errorf("workers may only be set on clusters with product: kwok. Actual product: %s", desired.Product)
+	}
+	if desired.Workers < 0 {
+		return nil, fmt.Errorf("workers must be non-negative. Actual: %d", desired.Workers)
+	}
 	if desired.Minikube != nil && clusterid.Product(desired.Product) != clusterid.ProductMinikube {
 		return nil, fmt.Errorf("minikube config may only be set on clusters with product: minikube. Actual product: %s", desired.Product)
 	}
+	if desired.KindOptions != nil {
+		if clusterid.Product(desired.Product) != clusterid.ProductKIND {
+			return nil, fmt.Errorf("kindOptions may only be set on clusters with product: kind. Actual product: %s", desired.Product)
+		}
+		err := validateKindOptions(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.K3dOptions != nil {
+		err := validateK3dOptions(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.EtcdBackup != nil {
+		err := validateEtcdBackup(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.KubeconfigServer != "" {
+		err := validateKubeconfigServer(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(desired.APIServerCertSANs) > 0 {
+		err := validateAPIServerCertSANs(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(desired.EnableAdmissionPlugins) > 0 || len(desired.DisableAdmissionPlugins) > 0 {
+		err := validateAdmissionPlugins(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.EncryptionAtRest != nil {
+		err := validateEncryptionAtRest(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.KindControlPlaneImage != "" || desired.KindWorkerImage != "" {
+		err := validateNodeImages(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(desired.KubeletArgs) > 0 {
+		err := validateKubeletArgs(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(desired.ContainerdConfigPatches) > 0 {
+		err := validateContainerdConfigPatches(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.CostBudget != nil {
+		err := validateCostBudget(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.ContainerRuntime != "" {
+		err := validateContainerRuntime(desired)
+		if err != nil {
+			return nil, err
+		}
+		if warning := containerRuntimeWarning(desired); warning != "" {
+			_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Warning: %s\n", warning)
+		}
+	}
+	if desired.NetworkCalico != nil {
+		err := validateNetworkCalico(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.CNI != "" {
+		err := validateCNI(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.LoadBalancer != nil {
+		err := validateLoadBalancer(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(desired.HelmCharts) > 0 {
+		err := validateHelmCharts(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.HubbleEnabled {
+		err := validateHubble(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(desired.ReadinessChecks) > 0 {
+		err := validateReadinessChecks(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.ReadinessPoll != nil {
+		_, err := readinessPollOptions(desired.ReadinessPoll)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(desired.ServiceAccounts) > 0 {
+		err := validateServiceAccounts(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.DefaultStorageClass != nil {
+		err := validateDefaultStorageClass(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if desired.PVCStorageDriver != "" {
+		err := validatePVCStorageDriver(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(desired.Hosts) > 0 {
+		err := validateHosts(desired.Hosts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(desired.ExtraHosts) > 0 {
+		err := validateExtraHosts(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.DefaultImagePullPolicy != "" {
+		err := validateDefaultImagePullPolicy(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.RollingUpdateStrategy != nil {
+		err := validateRollingUpdateStrategy(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if desired.DefaultNamespace != "" {
+		err := validateDefaultNamespace(desired)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	FillDefaults(desired)
 
+	// Make sure no other ctlptl is creating or deleting this cluster.
+	unlock, err := c.lockCluster(desired.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	// Fetch the machine driver for this product and cluster name,
 	// and use it to apply the constraints to the underlying VM.
 	machine, err := c.machine(ctx, desired.Name, clusterid.Product(desired.Product))
@@ -735,6 +1198,10 @@ func (c *Controller) Apply(ctx context.Context, desired *api.Cluster) (*api.Clus
 	if err != nil {
 		return nil, err
 	}
+	err = admin.EnsureInstalled(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	existingStatus := existingCluster.Status
 	needsRestart := existingStatus.CreationTimestamp.Time.IsZero() ||
@@ -751,6 +1218,13 @@ func (c *Controller) Apply(ctx context.Context, desired *api.Cluster) (*api.Clus
 		return nil, err
 	}
 
+	if c.outputDir != "" {
+		err := c.writeGeneratedConfigs(ctx, admin, desired, reg, c.outputDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Configure the cluster to match what we want.
 	needsCreate := existingStatus.CreationTimestamp.Time.IsZero() ||
 		desired.Name != existingCluster.Name ||
@@ -760,6 +1234,9 @@ func (c *Controller) Apply(ctx context.Context, desired *api.Cluster) (*api.Clus
 		if err != nil {
 			return nil, err
 		}
+		c.updateClusterState(desired.Name, func(state *clusterState) {
+			*state = clusterState{CreationTimestamp: time.Now(), Owner: OwnerOf(desired)}
+		})
 
 		err = c.waitForContextCreate(ctx, desired)
 		if err != nil {
@@ -768,7 +1245,7 @@ func (c *Controller) Apply(ctx context.Context, desired *api.Cluster) (*api.Clus
 	}
 
 	// Update the kubectl context to match this cluster.
-	err = c.configWriter.SetContext(desired.Name)
+	err = c.configWriter.SetContext(c.contextName(desired.Name))
 	if err != nil {
 		return nil, fmt.Errorf("switching to cluster context %s: %v", desired.Name, err)
 	}
@@ -778,7 +1255,14 @@ func (c *Controller) Apply(ctx context.Context, desired *api.Cluster) (*api.Clus
 		return nil, err
 	}
 
-	if needsCreate {
+	err = c.maybeSetKubeconfigServer(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	// An explicit server means the user is handling the networking,
+	// so don't try to guess how to reach the apiserver.
+	if needsCreate && desired.KubeconfigServer == "" {
 		// If the cluster apiserver is in a remote docker cluster,
 		// set up a portforwarder.
 		err := c.maybeCreateForwarderForCurrentCluster(ctx, c.iostreams.ErrOut)
@@ -790,6 +1274,16 @@ func (c *Controller) Apply(ctx context.Context, desired *api.Cluster) (*api.Clus
 		if err != nil {
 			return nil, err
 		}
+	}
+
+	if needsCreate {
+		// k3d passes the patches to k3s when it creates the nodes.
+		if len(desired.ContainerdConfigPatches) > 0 && clusterid.Product(desired.Product) == clusterid.ProductKIND {
+			err = c.applyContainerdConfigPatches(ctx, desired)
+			if err != nil {
+				return nil, errors.Wrap(err, "configuring containerd")
+			}
+		}
 
 		err = c.waitForHealthCheckAfterCreate(ctx, desired)
 		if err != nil {
@@ -807,25 +1301,225 @@ func (c *Controller) Apply(ctx context.Context, desired *api.Cluster) (*api.Clus
 				return nil, errors.Wrap(err, "configuring cluster registry")
 			}
 		}
+	} else if desired.Registry != "" {
+		// The registry may have changed since the cluster was created,
+		// so make sure the cluster still points at it.
+		_, err = c.repairRegistryHosting(ctx, admin, desired, reg)
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring cluster registry")
+		}
+	}
+
+	err = c.ensureDefaultNamespace(ctx, desired)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing else can run until the CNI is up, so install it first.
+	// Hubble can be toggled without re-creating the cluster.
+	hubbleChanged := desired.HubbleEnabled != existingCluster.HubbleEnabled
+	if desired.CNI == cniCilium && (needsCreate || hubbleChanged) {
+		err = c.installCilium(ctx, desired)
+		if err != nil {
+			return nil, errors.Wrap(err, "installing cilium")
+		}
+	}
+
+	// The taint can be toggled without re-creating the cluster.
+	taintChanged := !needsCreate && desired.TaintControlPlane != existingCluster.TaintControlPlane
+	if taintChanged || (needsCreate && desired.TaintControlPlane) {
+		err = c.TaintControlPlane(ctx, desired.Name, desired.TaintControlPlane)
+		if err != nil {
+			return nil, errors.Wrap(err, "tainting control plane")
+		}
+	}
+
+	// Docker may rewrite the nodes' /etc/hosts, so always re-apply extraHosts.
+	if len(desired.ExtraHosts) > 0 || (!needsCreate && len(existingCluster.ExtraHosts) > 0) {
+		err = c.applyExtraHosts(ctx, desired, existingCluster.ExtraHosts)
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring extraHosts")
+		}
+	}
+
+	// The pull policy can be changed without re-creating the cluster.
+	pullPolicyChanged := desired.DefaultImagePullPolicy != existingCluster.DefaultImagePullPolicy
+	if pullPolicyChanged && (!needsCreate || desired.DefaultImagePullPolicy != "") {
+		err = c.ensureDefaultImagePullPolicy(ctx, desired)
+		if err != nil {
+			return nil, errors.Wrap(err, "setting default imagePullPolicy")
+		}
+	}
+
+	// So can the rolling update strategy.
+	strategyChanged := !equality.Semantic.DeepEqual(desired.RollingUpdateStrategy, existingCluster.RollingUpdateStrategy)
+	if strategyChanged && (!needsCreate || desired.RollingUpdateStrategy != nil) {
+		err = c.ensureRollingUpdateStrategy(ctx, desired)
+		if err != nil {
+			return nil, errors.Wrap(err, "setting default rolling update strategy")
+		}
+	}
+
+	// The load balancer can be changed without re-creating the cluster.
+	loadBalancerChanged := !equality.Semantic.DeepEqual(desired.LoadBalancer, existingCluster.LoadBalancer)
+	if desired.LoadBalancer != nil && (needsCreate || loadBalancerChanged) {
+		err = c.EnsureLoadBalancer(ctx, desired.Name, desired.LoadBalancer.IPRange)
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring load balancer")
+		}
+	} else if desired.LoadBalancer == nil && loadBalancerChanged && !needsCreate {
+		err = c.removeLoadBalancer(ctx, desired.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring load balancer")
+		}
+	}
+
+	// Helm charts can be changed without re-creating the cluster.
+	helmChartsChanged := !equality.Semantic.DeepEqual(desired.HelmCharts, existingCluster.HelmCharts)
+	if len(desired.HelmCharts) > 0 && (needsCreate || helmChartsChanged) {
+		for _, chart := range desired.HelmCharts {
+			err = c.ApplyHelmChart(ctx, desired.Name, chart)
+			if err != nil {
+				return nil, errors.Wrapf(err, "installing helm chart %s", chart.Chart)
+			}
+		}
+	}
+
+	// The storage driver can be installed without re-creating the cluster.
+	storageDriverChanged := desired.PVCStorageDriver != existingCluster.PVCStorageDriver
+	if desired.PVCStorageDriver != "" && (needsCreate || storageDriverChanged) {
+		err = c.installPVCStorageDriver(ctx, desired)
+		if err != nil {
+			return nil, errors.Wrapf(err, "installing pvcStorageDriver %s", desired.PVCStorageDriver)
+		}
+	}
+
+	// The default StorageClass can be changed without re-creating the cluster.
+	// Re-apply it if something else (e.g., a provisioner's chart) took over as the default.
+	defaultStorageClassChanged := !equality.Semantic.DeepEqual(desired.DefaultStorageClass, existingCluster.DefaultStorageClass)
+	if desired.DefaultStorageClass != nil && (needsCreate || defaultStorageClassChanged ||
+		existingCluster.Status.DefaultStorageClass != desired.DefaultStorageClass.Name) {
+		err = c.ensureDefaultStorageClass(ctx, desired)
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring default StorageClass")
+		}
+	}
+
+	// mirrord can be enabled without re-creating the cluster.
+	// Disabling it leaves the operator installed.
+	mirrordChanged := desired.MirrordEnabled != existingCluster.MirrordEnabled
+	if desired.MirrordEnabled && (needsCreate || mirrordChanged) {
+		var setupAdmin AdminWithSetup = newMirrordAdmin(admin, c.iostreams, c.runner,
+			clientcmd.NewDefaultClientConfigLoadingRules().GetDefaultFilename(), c.mirrordDir)
+		err = setupAdmin.Setup(ctx, desired, c.contextName(desired.Name))
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring mirrord")
+		}
+	}
+
+	// Hosts can be changed without re-creating the cluster.
+	hostsChanged := !equality.Semantic.DeepEqual(desired.Hosts, existingCluster.Hosts)
+	if (needsCreate && len(desired.Hosts) > 0) || hostsChanged {
+		err = c.updateHosts(desired.Name, desired.Hosts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Service accounts are always applied, so that each apply hands out fresh tokens.
+	var serviceAccountTokens []api.ServiceAccountToken
+	if len(desired.ServiceAccounts) > 0 {
+		serviceAccountTokens, err = c.ensureServiceAccounts(ctx, desired)
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring service accounts")
+		}
+	}
+
+	if desired.EtcdBackup != nil {
+		err = c.ensureEtcdBackupSchedule(ctx, desired)
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring etcd backup")
+		}
+	}
+
+	if desired.CostBudget != nil {
+		c.checkCostBudget(ctx, desired)
+	}
+
+	// Most settings can be changed without re-creating the cluster, so make
+	// sure the stored spec is current.
+	if !needsCreate {
+		err = c.writeClusterSpec(ctx, desired)
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring cluster")
+		}
+	}
+
+	result, err := c.Get(ctx, desired.Name)
+	if err != nil {
+		return nil, err
 	}
+	c.recordClusterSettings(ctx, result)
+	result.Status.ServiceAccountTokens = serviceAccountTokens
+	return result, nil
+}
 
-	return c.Get(ctx, desired.Name)
+// Annotations that ctlptl sets on the stored spec itself, rather than
+// taking them from the config file.
+var ctlptlAnnotations = []string{
+	api.ClusterAnnotationSimulated,
+	api.ClusterAnnotationGitCommit,
+	api.ClusterAnnotationGitAuthor,
+	api.ClusterAnnotationGitSubject,
+	api.ClusterAnnotationOwner,
 }
 
 // Writes the cluster spec to the cluster itself, so
 // we can read it later to determine how the cluster was initialized.
+//
+// Keeps the annotations that ctlptl set on the stored spec, unless the new
+// spec sets them too. Does nothing if the stored spec is already current.
 func (c *Controller) writeClusterSpec(ctx context.Context, cluster *api.Cluster) error {
 	client, err := c.client(cluster.Name)
 	if err != nil {
 		return err
 	}
 
+	storedData := ""
+	existing, err := client.CoreV1().ConfigMaps("kube-public").Get(ctx, clusterSpecConfigMap, metav1.GetOptions{})
+	if err == nil {
+		storedData = existing.Data["cluster.v1alpha1"]
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+
 	specOnly := cluster.DeepCopy()
 	specOnly.Status = api.ClusterStatus{}
+	if storedData != "" {
+		stored := &api.Cluster{}
+		err = yaml.Unmarshal([]byte(storedData), stored)
+		if err != nil {
+			return err
+		}
+		for _, key := range ctlptlAnnotations {
+			value, ok := stored.Annotations[key]
+			if _, set := specOnly.Annotations[key]; !ok || set {
+				continue
+			}
+			if specOnly.Annotations == nil {
+				specOnly.Annotations = make(map[string]string)
+			}
+			specOnly.Annotations[key] = value
+		}
+	}
+
 	data, err := yaml.Marshal(specOnly)
 	if err != nil {
 		return err
 	}
+	if storedData == string(data) {
+		return nil
+	}
 
 	err = client.CoreV1().ConfigMaps("kube-public").Delete(ctx, clusterSpecConfigMap, metav1.DeleteOptions{})
 	if err != nil && !apierrors.IsNotFound(err) {
@@ -858,18 +1552,7 @@ func (c *Controller) createRegistryHosting(ctx context.Context, admin Admin, clu
 		return err
 	}
 
-	data, err := yaml.Marshal(hosting)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.CoreV1().ConfigMaps("kube-public").Create(ctx, &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "local-registry-hosting",
-			Namespace: "kube-public",
-		},
-		Data: map[string]string{"localRegistryHosting.v1": string(data)},
-	}, metav1.CreateOptions{})
+	err = writeRegistryHosting(ctx, client, hosting)
 	if err != nil {
 		return err
 	}
@@ -881,6 +1564,17 @@ func (c *Controller) createRegistryHosting(ctx context.Context, admin Admin, clu
 }
 
 func (c *Controller) Delete(ctx context.Context, name string) error {
+	unlock, err := c.lockCluster(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return c.deleteCluster(ctx, name)
+}
+
+// Deletes the cluster. The caller must hold the cluster lock.
+func (c *Controller) deleteCluster(ctx context.Context, name string) error {
 	existing, err := c.Get(ctx, name)
 	if err != nil {
 		return err
@@ -890,21 +1584,42 @@ func (c *Controller) Delete(ctx context.Context, name string) error {
 	if err != nil {
 		return err
 	}
+	err = admin.EnsureInstalled(ctx)
+	if err != nil {
+		return err
+	}
 
 	err = admin.Delete(ctx, existing)
 	if err != nil {
 		return err
 	}
+	c.updateClusterState(existing.Name, nil)
+
+	if len(existing.Hosts) > 0 {
+		err = c.updateHosts(existing.Name, nil)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.removeIngressHosts(existing.Name)
+	if err != nil {
+		return err
+	}
 
 	err = c.reloadConfigs()
 	if err != nil {
 		return err
 	}
 
+	// External clusters keep their context, because ctlptl didn't create it.
+	if clusterid.Product(existing.Product) == ProductExternal {
+		return nil
+	}
+
 	// If the context is still in the configs, delete it.
-	_, ok := c.configCopy().Contexts[existing.Name]
+	_, ok := c.configCopy().Contexts[c.contextName(existing.Name)]
 	if ok {
-		return c.configWriter.DeleteContext(existing.Name)
+		return c.configWriter.DeleteContext(c.contextName(existing.Name))
 	}
 	return nil
 }
@@ -919,6 +1634,7 @@ func (c *Controller) reloadConfigs() error {
 	defer c.mu.Unlock()
 	c.config = config
 	c.clients = make(map[string]kubernetes.Interface)
+	c.serviceCIDRs = make(map[string]*net.IPNet)
 	return nil
 }
 
@@ -927,12 +1643,36 @@ func (c *Controller) Current(ctx context.Context) (*api.Cluster, error) {
 	if current == "" {
 		return nil, fmt.Errorf("no cluster selected in kubeconfig")
 	}
-	return c.Get(ctx, current)
+	name, ok := c.clusterName(current)
+	if !ok {
+		return nil, fmt.Errorf("current context %q does not have context prefix %q", current, c.contextPrefix)
+	}
+	return c.Get(ctx, name)
+}
+
+// Use switches the kubectl context to the named cluster.
+//
+// Works the same for clusters that ctlptl created and external clusters.
+func (c *Controller) Use(ctx context.Context, name string) (*api.Cluster, error) {
+	contextName := c.contextName(name)
+	if _, ok := c.configCopy().Contexts[contextName]; !ok {
+		return nil, apierrors.NewNotFound(groupResource, name)
+	}
+
+	err := c.configWriter.SetContext(contextName)
+	if err != nil {
+		return nil, fmt.Errorf("switching to cluster context %s: %v", name, err)
+	}
+	err = c.reloadConfigs()
+	if err != nil {
+		return nil, err
+	}
+	return c.Get(ctx, name)
 }
 
 func (c *Controller) Get(ctx context.Context, name string) (*api.Cluster, error) {
 	config := c.configCopy()
-	ct, ok := config.Contexts[name]
+	ct, ok := config.Contexts[c.contextName(name)]
 	if !ok {
 		return nil, apierrors.NewNotFound(groupResource, name)
 	}
@@ -945,7 +1685,7 @@ func (c *Controller) Get(ctx context.Context, name string) (*api.Cluster, error)
 	cluster := &api.Cluster{
 		TypeMeta: typeMeta,
 		Name:     name,
-		Product:  clusterid.ProductFromContext(ct, configCluster).String(),
+		Product:  productOfContext(c.externalContexts(), c.contextName(name), ct, configCluster).String(),
 	}
 	c.populateCluster(ctx, cluster)
 
@@ -953,42 +1693,67 @@ func (c *Controller) Get(ctx context.Context, name string) (*api.Cluster, error)
 }
 
 func (c *Controller) List(ctx context.Context, options ListOptions) (*api.ClusterList, error) {
-	selector, err := fields.ParseSelector(options.FieldSelector)
+	selector, specSelector, err := parseFieldSelector(options.FieldSelector)
+	if err != nil {
+		return nil, err
+	}
+	labelSelector, err := labels.Parse(options.LabelSelector)
 	if err != nil {
 		return nil, err
 	}
 
 	config := c.configCopy()
-	names := make([]string, 0, len(c.config.Contexts))
-	for name, ct := range config.Contexts {
+	external := c.externalContexts()
+	names := make([]string, 0, len(config.Contexts))
+	for contextName, ct := range config.Contexts {
 		_, ok := config.Clusters[ct.Cluster]
 		if !ok {
 			// Filter out malformed contexts.
 			continue
 		}
 
+		name, ok := c.clusterName(contextName)
+		if !ok {
+			// Filter out contexts that belong to other prefixes.
+			continue
+		}
+
 		names = append(names, name)
 	}
 	sort.Strings(names)
 
+	// Measure age from when we started listing, so that a slow list
+	// doesn't change which clusters match.
+	now := time.Now()
+
 	// Listing all clusters can take a long time, so parallelize it.
 	all := make([]*api.Cluster, len(names))
 	g, ctx := errgroup.WithContext(ctx)
 
 	for i, name := range names {
-		ct := c.config.Contexts[name]
+		ct := config.Contexts[c.contextName(name)]
 		name := name
 		i := i
 		g.Go(func() error {
 			cluster := &api.Cluster{
 				TypeMeta: typeMeta,
 				Name:     name,
-				Product:  clusterid.ProductFromContext(ct, config.Clusters[ct.Cluster]).String(),
+				Product:  productOfContext(external, c.contextName(name), ct, config.Clusters[ct.Cluster]).String(),
 			}
-			if !selector.Matches((*clusterFields)(cluster)) {
+			// Skip the expensive status checks for clusters that can't match.
+			if !specSelector.Matches((*clusterFields)(cluster)) {
 				return nil
 			}
 			c.populateCluster(ctx, cluster)
+			if !selector.Matches((*clusterFields)(cluster)) {
+				return nil
+			}
+			if !labelSelector.Matches(labels.Set(cluster.Annotations)) {
+				return nil
+			}
+			if options.OlderThan > 0 && !createdBefore(cluster.Status.CreationTimestamp, now.Add(-options.OlderThan)) {
+				return nil
+			}
 			all[i] = cluster
 			return nil
 		})
@@ -1013,6 +1778,12 @@ func (c *Controller) List(ctx context.Context, options ListOptions) (*api.Cluste
 	}, nil
 }
 
+// Returns true if the object was created before the cutoff.
+// Objects with no creation time are never old enough.
+func createdBefore(created metav1.Time, cutoff time.Time) bool {
+	return !created.IsZero() && created.Time.Before(cutoff)
+}
+
 // If the current cluster is on a remote docker instance,
 // we need a port-forwarder to connect it.
 func (c *Controller) maybeCreateForwarderForCurrentCluster(ctx context.Context, errOut io.Writer) error {
@@ -1047,6 +1818,10 @@ func (c *Controller) waitForContextCreate(ctx context.Context, cluster *api.Clus
 		if err != nil {
 			return err
 		}
+		err = c.maybeAddContextPrefix(cluster.Name)
+		if err != nil {
+			return err
+		}
 		_, err = c.client(cluster.Name)
 		if err != nil {
 			return err
@@ -1074,6 +1849,31 @@ func (c *Controller) waitForContextCreate(ctx context.Context, cluster *api.Clus
 	return nil
 }
 
+// Cluster creation tools write contexts under their own names.
+//
+// If we have a context prefix, rename the tool's context to the prefixed name.
+func (c *Controller) maybeAddContextPrefix(name string) error {
+	contextName := c.contextName(name)
+	if contextName == name {
+		return nil
+	}
+
+	config := c.configCopy()
+	if _, ok := config.Contexts[contextName]; ok {
+		return nil
+	}
+	if _, ok := config.Contexts[name]; !ok {
+		// The tool hasn't written the context yet.
+		return nil
+	}
+
+	err := c.configWriter.RenameContext(name, contextName)
+	if err != nil {
+		return fmt.Errorf("renaming context %s to %s: %v", name, contextName, err)
+	}
+	return c.reloadConfigs()
+}
+
 // Our cluster creation tools aren't super trustworthy.
 //
 // After the cluster is created, we poll the kubeconfig until
@@ -0,0 +1,60 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/clusterid"
+)
+
+// The ClusterIP of the kube-dns service in most clusters, including
+// docker-desktop and kind: the 10th IP of the default service CIDR, 10.96.0.0/12.
+const defaultClusterDNS = "10.96.0.10"
+
+// k3d (k3s) uses the service CIDR 10.43.0.0/16.
+const k3dClusterDNS = "10.43.0.10"
+
+// GetClusterDNS returns the IP of the cluster's DNS server: the ClusterIP of
+// the kube-dns service in kube-system. CoreDNS keeps the kube-dns name for
+// compatibility.
+//
+// If the cluster is unreachable, warns and falls back to the default IP for
+// the product, which is right unless the cluster has a custom service CIDR.
+func (c *Controller) GetClusterDNS(ctx context.Context, clusterName string) (string, error) {
+	product, err := c.productFromConfig(clusterName)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := c.client(clusterName)
+	if err != nil {
+		return c.fallbackClusterDNS(clusterName, product, err), nil
+	}
+
+	svc, err := client.CoreV1().Services("kube-system").Get(ctx, "kube-dns", metav1.GetOptions{})
+	if err != nil {
+		if _, ok := err.(apierrors.APIStatus); ok {
+			return "", fmt.Errorf("cluster %s: reading kube-dns service: %v", clusterName, err)
+		}
+		return c.fallbackClusterDNS(clusterName, product, err), nil
+	}
+
+	ip := svc.Spec.ClusterIP
+	if ip == "" || ip == "None" {
+		return "", fmt.Errorf("cluster %s: kube-dns service has no ClusterIP", clusterName)
+	}
+	return ip, nil
+}
+
+func (c *Controller) fallbackClusterDNS(clusterName string, product clusterid.Product, err error) string {
+	ip := defaultClusterDNS
+	if product == clusterid.ProductK3D {
+		ip = k3dClusterDNS
+	}
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+		"Warning: cluster %s is unreachable (%v). Assuming the default DNS IP %s\n", clusterName, err, ip)
+	return ip
+}
@@ -0,0 +1,48 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestGetClusterDNS(t *testing.T) {
+	f := newFixture(t)
+	_, err := f.fakeK8s.CoreV1().Services("kube-system").Create(context.Background(), &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-dns", Namespace: "kube-system"},
+		Spec:       v1.ServiceSpec{ClusterIP: "10.100.0.10"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	ip, err := f.controller.GetClusterDNS(context.Background(), "docker-desktop")
+	require.NoError(t, err)
+	assert.Equal(t, "10.100.0.10", ip)
+}
+
+func TestGetClusterDNSNoService(t *testing.T) {
+	f := newFixture(t)
+
+	_, err := f.controller.GetClusterDNS(context.Background(), "docker-desktop")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cluster docker-desktop: reading kube-dns service")
+	}
+}
+
+func TestGetClusterDNSUnreachable(t *testing.T) {
+	f := newFixture(t)
+	f.fakeK8s.PrependReactor("get", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("dial tcp 127.0.0.1:6443: connect: connection refused")
+	})
+
+	ip, err := f.controller.GetClusterDNS(context.Background(), "docker-desktop")
+	require.NoError(t, err)
+	assert.Equal(t, "10.96.0.10", ip)
+	assert.Contains(t, f.errOut.String(), "Warning: cluster docker-desktop is unreachable")
+}
@@ -5,7 +5,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -20,6 +22,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
@@ -31,6 +34,7 @@ import (
 	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
 
 	"github.com/tilt-dev/ctlptl/internal/exec"
+	"github.com/tilt-dev/ctlptl/internal/hostsfile"
 	"github.com/tilt-dev/ctlptl/pkg/api"
 	"github.com/tilt-dev/ctlptl/pkg/registry"
 )
@@ -102,6 +106,54 @@ func TestClusterListSelectorNoMatch(t *testing.T) {
 	assert.Equal(t, 0, len(clusters.Items))
 }
 
+func TestClusterListSelectorStatus(t *testing.T) {
+	c := newFakeController(t)
+	clusters, err := c.List(context.Background(), ListOptions{FieldSelector: "status.current=true"})
+	assert.NoError(t, err)
+	require.Equal(t, 1, len(clusters.Items))
+	assert.Equal(t, "microk8s", clusters.Items[0].Name)
+
+	clusters, err = c.List(context.Background(), ListOptions{FieldSelector: "product!=microk8s,status.ready=true"})
+	assert.NoError(t, err)
+	require.Equal(t, 1, len(clusters.Items))
+	assert.Equal(t, "docker-desktop", clusters.Items[0].Name)
+
+	clusters, err = c.List(context.Background(), ListOptions{FieldSelector: "status.ready=false"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(clusters.Items))
+}
+
+func TestClusterListOlderThan(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	clusters, err := f.controller.List(ctx, ListOptions{OlderThan: time.Hour})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(clusters.Items))
+
+	node, err := f.fakeK8s.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	node.CreationTimestamp = metav1.Time{Time: time.Now().Add(-2 * time.Hour)}
+	_, err = f.fakeK8s.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	clusters, err = f.controller.List(ctx, ListOptions{OlderThan: time.Hour})
+	assert.NoError(t, err)
+	require.Equal(t, 2, len(clusters.Items))
+	assert.Equal(t, "docker-desktop", clusters.Items[0].Name)
+	assert.Equal(t, "microk8s", clusters.Items[1].Name)
+
+	// A cluster with no nodes has no known age, so it never matches.
+	assert.False(t, createdBefore(metav1.Time{}, time.Now()))
+}
+
+func TestClusterListSelectorUnsupportedField(t *testing.T) {
+	c := newFakeController(t)
+	_, err := c.List(context.Background(), ListOptions{FieldSelector: "status.color=blue"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `unsupported field "status.color"`)
+	}
+}
+
 func TestClusterGetMissing(t *testing.T) {
 	c := newFakeController(t)
 	_, err := c.Get(context.Background(), "dunkees")
@@ -126,6 +178,91 @@ func TestClusterApplyKIND(t *testing.T) {
 	assert.Equal(t, "kind-kind", result.Name)
 }
 
+func TestClusterApplyKINDContextPrefix(t *testing.T) {
+	f := newFixture(t)
+	f.controller.contextPrefix = "ci-42-"
+	kindAdmin := f.newFakeAdmin(clusterid.ProductKIND)
+
+	result, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product: string(clusterid.ProductKIND),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	assert.Equal(t, "kind-kind", result.Name)
+	assert.Equal(t, "ci-42-kind-kind", f.config.CurrentContext)
+	assert.Contains(t, f.config.Contexts, "ci-42-kind-kind")
+	assert.NotContains(t, f.config.Contexts, "kind-kind")
+
+	current, err := f.controller.Current(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", current.Name)
+	assert.True(t, current.Status.Current)
+
+	clusters, err := f.controller.List(context.Background(), ListOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(clusters.Items))
+	assert.Equal(t, "kind-kind", clusters.Items[0].Name)
+
+	err = f.controller.Delete(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.NotContains(t, f.config.Contexts, "ci-42-kind-kind")
+}
+
+func TestClusterApplyKINDUnchangedKeepsSpec(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	f.newFakeAdmin(clusterid.ProductKIND)
+
+	cluster := &api.Cluster{Product: string(clusterid.ProductKIND), DefaultNamespace: "dev"}
+	_, err := f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+
+	// Re-applying the same spec doesn't rewrite the stored spec.
+	f.fakeK8s.ClearActions()
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	for _, action := range f.fakeK8s.Actions() {
+		if action.GetResource().Resource == "configmaps" {
+			assert.NotEqual(t, "delete", action.GetVerb())
+			assert.NotEqual(t, "create", action.GetVerb())
+		}
+	}
+
+	c, err := f.controller.Get(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.Equal(t, "dev", c.DefaultNamespace)
+}
+
+func TestWriteClusterSpecKeepsCtlptlAnnotations(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	err := f.controller.writeClusterSpec(ctx, &api.Cluster{
+		Name:    "microk8s",
+		Product: "microk8s",
+		Annotations: map[string]string{
+			api.ClusterAnnotationSimulated: "true",
+			api.ClusterAnnotationOwner:     "alice",
+			"example.com/team":             "platform",
+		},
+	})
+	require.NoError(t, err)
+
+	err = f.controller.writeClusterSpec(ctx, &api.Cluster{
+		Name:        "microk8s",
+		Product:     "microk8s",
+		Annotations: map[string]string{api.ClusterAnnotationOwner: "bob"},
+	})
+	require.NoError(t, err)
+
+	spec, err := readClusterSpec(ctx, f.fakeK8s)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		api.ClusterAnnotationSimulated: "true",
+		api.ClusterAnnotationOwner:     "bob",
+	}, spec.Annotations)
+}
+
 // Make sure an empty context doesn't confuse ctlptl.
 func TestClusterApplyKINDEmptyConfig(t *testing.T) {
 	f := newFixture(t)
@@ -189,6 +326,84 @@ func TestClusterApplyKINDWithCluster(t *testing.T) {
 	assert.Equal(t, "kind-registry", f.registryCtl.lastApply.Name)
 }
 
+func TestClusterApplyKINDWithKubeconfigServer(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+
+	f.dockerClient.started = true
+	f.newFakeAdmin(clusterid.ProductKIND)
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:          string(clusterid.ProductKIND),
+		KubeconfigServer: "https://127.0.0.1:16443",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://127.0.0.1:16443", f.configWriter.opts["clusters.kind-kind.server"])
+	assert.Contains(t, f.errOut.String(), "Pointed cluster kind-kind at server https://127.0.0.1:16443")
+}
+
+func TestClusterApplyInvalidKubeconfigServer(t *testing.T) {
+	f := newFixture(t)
+
+	for _, server := range []string{"127.0.0.1:6443", "ftp://127.0.0.1:6443", "https://", "https://localhost:6443?x=1"} {
+		_, err := f.controller.Apply(context.Background(), &api.Cluster{
+			Product:          string(clusterid.ProductKIND),
+			KubeconfigServer: server,
+		})
+		if assert.Error(t, err, server) {
+			assert.Contains(t, err.Error(), "invalid kubeconfigServer")
+		}
+	}
+}
+
+func TestClusterApplyKINDWithDefaultNamespace(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+
+	f.dockerClient.started = true
+	f.newFakeAdmin(clusterid.ProductKIND)
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:          string(clusterid.ProductKIND),
+		DefaultNamespace: "dev",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "dev", f.configWriter.opts["contexts.kind-kind.namespace"])
+
+	_, err = f.fakeK8s.CoreV1().Namespaces().Get(context.Background(), "dev", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestClusterApplyInvalidDefaultNamespace(t *testing.T) {
+	f := newFixture(t)
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:          string(clusterid.ProductKIND),
+		DefaultNamespace: "My_Namespace",
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid defaultNamespace "My_Namespace"`)
+	}
+}
+
+func TestClusterApplyAndDeleteWithLocks(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+	f.controller.lockDir = t.TempDir()
+
+	f.dockerClient.started = true
+	f.newFakeAdmin(clusterid.ProductKIND)
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product: string(clusterid.ProductKIND),
+	})
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(f.controller.lockDir, "cluster-kind-kind.lock"))
+
+	// Make sure the lock was released.
+	err = f.controller.Delete(context.Background(), "kind-kind")
+	require.NoError(t, err)
+}
+
 func TestClusterApplyDockerDesktop(t *testing.T) {
 	f := newFixture(t)
 	f.setOS("darwin")
@@ -310,6 +525,45 @@ func TestClusterApplyMinikubeVersion(t *testing.T) {
 			"does not match current (v1.14.0)")
 }
 
+func TestClusterApplyMinikubeUpgrade(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	minikubeAdmin := f.newFakeAdmin(clusterid.ProductMinikube)
+	minikubeAdmin.upgradeSupported = true
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:           string(clusterid.ProductMinikube),
+		KubernetesVersion: "v1.14.0",
+	})
+	require.NoError(t, err)
+	minikubeAdmin.created = nil
+
+	result, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:           string(clusterid.ProductMinikube),
+		KubernetesVersion: "v1.15.0",
+	})
+	require.NoError(t, err)
+
+	// Make sure we upgrade in place, rather than re-create the cluster.
+	assert.Nil(t, minikubeAdmin.deleted)
+	assert.Nil(t, minikubeAdmin.created)
+	assert.Equal(t, "v1.15.0", minikubeAdmin.upgraded.KubernetesVersion)
+	assert.Equal(t, "v1.15.0", result.Status.KubernetesVersion)
+	assert.Contains(t, f.errOut.String(), "Upgraded cluster minikube from Kubernetes v1.14.0 to v1.15.0")
+
+	// Downgrades re-create the cluster.
+	minikubeAdmin.upgraded = nil
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:           string(clusterid.ProductMinikube),
+		KubernetesVersion: "v1.14.0",
+	})
+	require.NoError(t, err)
+	assert.Nil(t, minikubeAdmin.upgraded)
+	assert.Equal(t, "minikube", minikubeAdmin.created.Name)
+	assert.Contains(t, f.errOut.String(),
+		"Deleting cluster minikube because desired Kubernetes version (v1.14.0) does not match current (v1.15.0)")
+}
+
 func TestFillDefaultsKindConfig(t *testing.T) {
 	c := &api.Cluster{
 		Product: "kind",
@@ -452,6 +706,7 @@ type fixture struct {
 	configWriter fakeConfigWriter
 	registryCtl  *fakeRegistryController
 	fakeK8s      *fake.Clientset
+	helm         *fakeHelmClient
 }
 
 func newFixture(t *testing.T) *fixture {
@@ -509,6 +764,7 @@ func newFixture(t *testing.T) *fixture {
 	})
 
 	registryCtl := &fakeRegistryController{}
+	helm := &fakeHelmClient{}
 	controller := &Controller{
 		iostreams:                   iostreams,
 		runner:                      exec.NewFakeCmdRunner(func(argv []string) string { return "" }),
@@ -519,11 +775,14 @@ func newFixture(t *testing.T) *fixture {
 		configLoader:                configLoader,
 		clientLoader:                clientLoader,
 		clients:                     make(map[string]kubernetes.Interface),
+		serviceCIDRs:                make(map[string]*net.IPNet),
 		registryCtl:                 registryCtl,
 		waitForKubeConfigTimeout:    time.Millisecond,
 		waitForClusterCreateTimeout: time.Millisecond,
 		os:                          osName,
 		dockerClient:                dockerClient,
+		hostsFile:                   &hostsfile.File{Path: filepath.Join(t.TempDir(), "hosts")},
+		helm:                        helm,
 	}
 	return &fixture{
 		t:            t,
@@ -536,6 +795,7 @@ func newFixture(t *testing.T) *fixture {
 		configWriter: configWriter,
 		registryCtl:  registryCtl,
 		fakeK8s:      fakeK8s,
+		helm:         helm,
 	}
 }
 
@@ -569,6 +829,15 @@ type fakeDockerClient struct {
 	host        string
 	networks    []string
 	containerID string
+	containers  []types.Container
+	networkMode string
+	subnets     map[string][]string
+
+	// Container ID -> IP on the kind network.
+	containerIPs map[string]string
+
+	securityOptions []string
+	cgroupVersion   string
 }
 
 func (c *fakeDockerClient) DaemonHost() string {
@@ -588,11 +857,26 @@ func (c *fakeDockerClient) Info(ctx context.Context) (types.Info, error) {
 		return types.Info{}, fmt.Errorf("not started")
 	}
 
-	return types.Info{NCPU: c.ncpu}, nil
+	return types.Info{
+		NCPU:            c.ncpu,
+		SecurityOptions: c.securityOptions,
+		CgroupVersion:   c.cgroupVersion,
+	}, nil
 }
 
 func (c *fakeDockerClient) ContainerInspect(ctx context.Context, id string) (types.ContainerJSON, error) {
-	return types.ContainerJSON{}, nil
+	result := types.ContainerJSON{}
+	if c.networkMode != "" {
+		result.ContainerJSONBase = &types.ContainerJSONBase{
+			HostConfig: &container.HostConfig{NetworkMode: container.NetworkMode(c.networkMode)},
+		}
+	}
+	if ip, ok := c.containerIPs[id]; ok {
+		result.NetworkSettings = &types.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{"kind": {IPAddress: ip}},
+		}
+	}
+	return result, nil
 }
 
 func (d *fakeDockerClient) ContainerRemove(ctx context.Context, id string, options types.ContainerRemoveOptions) error {
@@ -604,7 +888,14 @@ func (d *fakeDockerClient) ImagePull(ctx context.Context, image string, options
 }
 
 func (d *fakeDockerClient) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
-	return nil, nil
+	var result []types.Container
+	for _, c := range d.containers {
+		if options.Filters.Contains("label") && !options.Filters.MatchKVList("label", c.Labels) {
+			continue
+		}
+		result = append(result, c)
+	}
+	return result, nil
 }
 
 func (d *fakeDockerClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *specs.Platform, containerName string) (container.ContainerCreateCreatedBody, error) {
@@ -633,6 +924,26 @@ func (d *fakeDockerClient) NetworkDisconnect(ctx context.Context, networkID, con
 	return nil
 }
 
+func (d *fakeDockerClient) NetworkInspect(ctx context.Context, networkID string, options types.NetworkInspectOptions) (types.NetworkResource, error) {
+	subnets, ok := d.subnets[networkID]
+	if !ok {
+		return types.NetworkResource{}, fmt.Errorf("network %s not found", networkID)
+	}
+	result := types.NetworkResource{Name: networkID}
+	for _, subnet := range subnets {
+		result.IPAM.Config = append(result.IPAM.Config, network.IPAMConfig{Subnet: subnet})
+	}
+	return result, nil
+}
+
+func (d *fakeDockerClient) NetworkCreate(ctx context.Context, name string, options types.NetworkCreate) (types.NetworkCreateResponse, error) {
+	if d.subnets == nil {
+		d.subnets = make(map[string][]string)
+	}
+	d.subnets[name] = []string{}
+	return types.NetworkCreateResponse{ID: name}, nil
+}
+
 func (d *fakeDockerClient) insideContainer(ctx context.Context) string {
 	return d.containerID
 }
@@ -696,8 +1007,12 @@ type fakeAdmin struct {
 	created         *api.Cluster
 	createdRegistry *api.Registry
 	deleted         *api.Cluster
+	upgraded        *api.Cluster
 	config          *clientcmdapi.Config
 	fakeK8s         *fake.Clientset
+
+	// If false, Upgrade returns ErrNotSupported, like most products.
+	upgradeSupported bool
 }
 
 func newFakeAdmin(config *clientcmdapi.Config, fakeK8s *fake.Clientset) *fakeAdmin {
@@ -724,11 +1039,23 @@ func (a *fakeAdmin) Create(ctx context.Context, config *api.Cluster, registry *a
 
 func (a *fakeAdmin) LocalRegistryHosting(ctx context.Context, cluster *api.Cluster, registry *api.Registry) (*localregistry.LocalRegistryHostingV1, error) {
 	return &localregistry.LocalRegistryHostingV1{
-		Host: fmt.Sprintf("localhost:%d", registry.Status.HostPort),
-		Help: "https://github.com/tilt-dev/ctlptl",
+		Host:                     fmt.Sprintf("localhost:%d", registry.Status.HostPort),
+		HostFromContainerRuntime: fmt.Sprintf("%s:%d", registry.Name, registry.Status.ContainerPort),
+		Help:                     "https://github.com/tilt-dev/ctlptl",
 	}, nil
 }
 
+func (a *fakeAdmin) Upgrade(ctx context.Context, config *api.Cluster) error {
+	if !a.upgradeSupported {
+		return ErrNotSupported
+	}
+	a.upgraded = config.DeepCopy()
+	a.fakeK8s.Discovery().(*discoveryfake.FakeDiscovery).FakedServerVersion = &version.Info{
+		GitVersion: config.KubernetesVersion,
+	}
+	return nil
+}
+
 func (a *fakeAdmin) Delete(ctx context.Context, config *api.Cluster) error {
 	a.deleted = config.DeepCopy()
 	delete(a.config.Contexts, config.Name)
@@ -736,32 +1063,80 @@ func (a *fakeAdmin) Delete(ctx context.Context, config *api.Cluster) error {
 }
 
 type fakeRegistryController struct {
-	lastApply *api.Registry
+	lastApply  *api.Registry
+	registries []*api.Registry
+
+	// Forwarder container name -> host port.
+	forwarders map[string]int
 }
 
 func (c *fakeRegistryController) List(ctx context.Context, options registry.ListOptions) (*api.RegistryList, error) {
+	selector, err := fields.ParseSelector(options.FieldSelector)
+	if err != nil {
+		return nil, err
+	}
+
 	list := &api.RegistryList{}
-	if c.lastApply != nil {
-		item := c.lastApply.DeepCopy()
-		list.Items = append(list.Items, *item)
+	for _, r := range c.registries {
+		if !selector.Matches(fields.Set{"name": r.Name, "port": fmt.Sprintf("%d", r.Status.HostPort)}) {
+			continue
+		}
+		list.Items = append(list.Items, *r.DeepCopy())
 	}
 	return list, nil
 }
 
+// Each new registry gets the next host port, starting at 5000.
 func (c *fakeRegistryController) Apply(ctx context.Context, r *api.Registry) (*api.Registry, error) {
-	c.lastApply = r.DeepCopy()
-
 	newR := r.DeepCopy()
 	newR.Status = api.RegistryStatus{
 		ContainerPort: 5000,
 		ContainerID:   "fake-container-id",
-		HostPort:      5000,
+		HostPort:      5000 + len(c.registries),
 		IPAddress:     "172.0.0.2",
 		Networks:      []string{"bridge"},
 	}
+	replaced := false
+	for i, existing := range c.registries {
+		if existing.Name == r.Name {
+			newR.Status.HostPort = existing.Status.HostPort
+			c.registries[i] = newR.DeepCopy()
+			replaced = true
+		}
+	}
+	if !replaced {
+		c.registries = append(c.registries, newR.DeepCopy())
+	}
+	c.lastApply = newR.DeepCopy()
 	return newR, nil
 }
 
+func (c *fakeRegistryController) StartForwarder(ctx context.Context, name string, hostPort int, permanent bool) (string, int, error) {
+	found := false
+	for _, r := range c.registries {
+		if r.Name == name {
+			found = true
+		}
+	}
+	if !found {
+		return "", 0, fmt.Errorf("registry %s not found", name)
+	}
+	if hostPort == 0 {
+		hostPort = 6000 + len(c.forwarders)
+	}
+	if c.forwarders == nil {
+		c.forwarders = make(map[string]int)
+	}
+	containerName := fmt.Sprintf("%s-forward-%d", name, hostPort)
+	c.forwarders[containerName] = hostPort
+	return containerName, hostPort, nil
+}
+
+func (c *fakeRegistryController) StopForwarder(ctx context.Context, containerName string) error {
+	delete(c.forwarders, containerName)
+	return nil
+}
+
 type fakeConfigWriter struct {
 	config *clientcmdapi.Config
 	opts   map[string]string
@@ -780,7 +1155,88 @@ func (w fakeConfigWriter) DeleteContext(name string) error {
 	return nil
 }
 
+func (w fakeConfigWriter) RenameContext(oldName, newName string) error {
+	ct, ok := w.config.Contexts[oldName]
+	if !ok {
+		return fmt.Errorf("context %s not found", oldName)
+	}
+	delete(w.config.Contexts, oldName)
+	w.config.Contexts[newName] = ct
+	if w.config.CurrentContext == oldName {
+		w.config.CurrentContext = newName
+	}
+	return nil
+}
+
 func (w fakeConfigWriter) SetConfig(name, value string) error {
 	w.opts[name] = value
 	return nil
 }
+
+func TestClusterRepairRegistryHosting(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+
+	f.dockerClient.started = true
+	f.newFakeAdmin(clusterid.ProductKIND)
+
+	ctx := context.Background()
+	_, err := f.controller.Apply(ctx, &api.Cluster{
+		Product:  string(clusterid.ProductKIND),
+		Registry: "kind-registry",
+	})
+	require.NoError(t, err)
+
+	repaired, err := f.controller.RepairRegistryHosting(ctx, "kind-kind")
+	require.NoError(t, err)
+	assert.False(t, repaired)
+
+	// Simulate a registry that was re-created on a different port.
+	cMap, err := f.fakeK8s.CoreV1().ConfigMaps("kube-public").Get(ctx, registryHostingConfigMap, metav1.GetOptions{})
+	require.NoError(t, err)
+	cMap.Data["localRegistryHosting.v1"] = "host: localhost:5001\n"
+	_, err = f.fakeK8s.CoreV1().ConfigMaps("kube-public").Update(ctx, cMap, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	repaired, err = f.controller.RepairRegistryHosting(ctx, "kind-kind")
+	require.NoError(t, err)
+	assert.True(t, repaired)
+	assert.Contains(t, f.errOut.String(), "Updated registry kind-registry from localhost:5001 to localhost:5000")
+
+	hosting, err := localregistry.Discover(ctx, f.fakeK8s.CoreV1())
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:5000", hosting.Host)
+
+	repaired, err = f.controller.RepairRegistryHosting(ctx, "kind-kind")
+	require.NoError(t, err)
+	assert.False(t, repaired)
+}
+
+func TestClusterGetRegistryAddress(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+
+	f.dockerClient.started = true
+	f.newFakeAdmin(clusterid.ProductKIND)
+
+	ctx := context.Background()
+	_, err := f.controller.Apply(ctx, &api.Cluster{
+		Product:  string(clusterid.ProductKIND),
+		Registry: "kind-registry",
+	})
+	require.NoError(t, err)
+
+	host, internalHost, err := f.controller.GetRegistryAddress(ctx, "kind-kind")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:5000", host)
+	assert.Equal(t, "kind-registry:5000", internalHost)
+}
+
+func TestClusterGetRegistryAddressNoRegistry(t *testing.T) {
+	f := newFixture(t)
+
+	host, internalHost, err := f.controller.GetRegistryAddress(context.Background(), "microk8s")
+	require.NoError(t, err)
+	assert.Equal(t, "", host)
+	assert.Equal(t, "", internalHost)
+}
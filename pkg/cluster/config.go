@@ -9,6 +9,7 @@ import (
 type configWriter interface {
 	SetContext(name string) error
 	DeleteContext(name string) error
+	RenameContext(oldName, newName string) error
 	SetConfig(name, value string) error
 }
 
@@ -30,6 +31,13 @@ func (w kubeconfigWriter) DeleteContext(name string) error {
 	return cmd.Run()
 }
 
+func (w kubeconfigWriter) RenameContext(oldName, newName string) error {
+	cmd := exec.Command("kubectl", "config", "rename-context", oldName, newName)
+	cmd.Stdout = w.iostreams.Out
+	cmd.Stderr = w.iostreams.ErrOut
+	return cmd.Run()
+}
+
 func (w kubeconfigWriter) SetConfig(name, value string) error {
 	cmd := exec.Command("kubectl", "config", "set", name, value)
 	cmd.Stdout = w.iostreams.Out
@@ -0,0 +1,88 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+const (
+	ContainerRuntimeContainerd = "containerd"
+	ContainerRuntimeDocker     = "docker"
+	ContainerRuntimeCRIO       = "crio"
+)
+
+// Where CRI-O listens, in nodes that include it.
+const crioSocket = "unix:///var/run/crio/crio.sock"
+
+func validateContainerRuntime(desired *api.Cluster) error {
+	switch desired.ContainerRuntime {
+	case "", ContainerRuntimeContainerd, ContainerRuntimeDocker, ContainerRuntimeCRIO:
+		return nil
+	}
+	return fmt.Errorf("containerRuntime must be one of: %s, %s, %s. Actual: %s",
+		ContainerRuntimeContainerd, ContainerRuntimeDocker, ContainerRuntimeCRIO, desired.ContainerRuntime)
+}
+
+// Returns a warning if the container runtime probably won't do what the user expects.
+func containerRuntimeWarning(desired *api.Cluster) string {
+	runtime := containerRuntime(desired)
+	if runtime == ContainerRuntimeContainerd {
+		return ""
+	}
+	switch clusterid.Product(desired.Product) {
+	case clusterid.ProductKIND:
+		return fmt.Sprintf("kind always uses containerd. Ignoring containerRuntime: %s", runtime)
+	case clusterid.ProductK3D:
+		if desired.KindControlPlaneImage == "" {
+			return fmt.Sprintf("the default k3s images only include containerd. "+
+				"containerRuntime: %s needs a kindControlPlaneImage with %s installed", runtime, runtime)
+		}
+	}
+	return ""
+}
+
+// The container runtime, defaulting to containerd.
+func containerRuntime(cluster *api.Cluster) string {
+	if cluster.ContainerRuntime == "" {
+		return ContainerRuntimeContainerd
+	}
+	return cluster.ContainerRuntime
+}
+
+// Checks if changing the container runtime requires re-creating the cluster.
+// Kind ignores it, and minikube.containerRuntime takes precedence on minikube.
+func containerRuntimeEqual(existing, desired *api.Cluster) bool {
+	switch clusterid.Product(desired.Product) {
+	case clusterid.ProductK3D:
+		return containerRuntime(existing) == containerRuntime(desired)
+	case clusterid.ProductMinikube:
+		return containerRuntimeMinikube(existing) == containerRuntimeMinikube(desired)
+	}
+	return true
+}
+
+// K3d flags that point k3s at the container runtime on servers and agents.
+func containerRuntimeK3dFlags(cluster *api.Cluster) []string {
+	switch containerRuntime(cluster) {
+	case ContainerRuntimeDocker:
+		return []string{"--k3s-arg", "--docker@server:*;agent:*"}
+	case ContainerRuntimeCRIO:
+		return []string{"--k3s-arg", fmt.Sprintf("--container-runtime-endpoint=%s@server:*;agent:*", crioSocket)}
+	}
+	return nil
+}
+
+// The --container-runtime value for minikube. minikube calls CRI-O cri-o.
+func containerRuntimeMinikube(cluster *api.Cluster) string {
+	if cluster.Minikube != nil && cluster.Minikube.ContainerRuntime != "" {
+		return cluster.Minikube.ContainerRuntime
+	}
+	runtime := containerRuntime(cluster)
+	if runtime == ContainerRuntimeCRIO {
+		return "cri-o"
+	}
+	return runtime
+}
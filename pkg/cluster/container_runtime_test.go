@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestValidateContainerRuntime(t *testing.T) {
+	for _, runtime := range []string{"", "containerd", "docker", "crio"} {
+		assert.NoError(t, validateContainerRuntime(&api.Cluster{ContainerRuntime: runtime}))
+	}
+
+	err := validateContainerRuntime(&api.Cluster{ContainerRuntime: "rkt"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "containerRuntime must be one of: containerd, docker, crio. Actual: rkt")
+	}
+}
+
+func TestContainerRuntimeWarning(t *testing.T) {
+	assert.Equal(t, "", containerRuntimeWarning(&api.Cluster{
+		Product: string(clusterid.ProductK3D), ContainerRuntime: "containerd"}))
+	assert.Contains(t, containerRuntimeWarning(&api.Cluster{
+		Product: string(clusterid.ProductK3D), ContainerRuntime: "docker"}),
+		"default k3s images only include containerd")
+	assert.Equal(t, "", containerRuntimeWarning(&api.Cluster{
+		Product: string(clusterid.ProductK3D), ContainerRuntime: "crio",
+		KindControlPlaneImage: "example.com/k3s-crio:v1.27.1-k3s1"}))
+	assert.Contains(t, containerRuntimeWarning(&api.Cluster{
+		Product: string(clusterid.ProductKIND), ContainerRuntime: "docker"}),
+		"kind always uses containerd")
+	assert.Equal(t, "", containerRuntimeWarning(&api.Cluster{
+		Product: string(clusterid.ProductMinikube), ContainerRuntime: "crio"}))
+}
+
+func TestContainerRuntimeEqual(t *testing.T) {
+	k3d := func(runtime string) *api.Cluster {
+		return &api.Cluster{Product: string(clusterid.ProductK3D), ContainerRuntime: runtime}
+	}
+	assert.True(t, containerRuntimeEqual(k3d(""), k3d("containerd")))
+	assert.False(t, containerRuntimeEqual(k3d(""), k3d("docker")))
+
+	kind := func(runtime string) *api.Cluster {
+		return &api.Cluster{Product: string(clusterid.ProductKIND), ContainerRuntime: runtime}
+	}
+	assert.True(t, containerRuntimeEqual(kind(""), kind("docker")))
+}
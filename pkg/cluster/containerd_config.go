@@ -0,0 +1,123 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// The containerd config in kind nodes.
+const kindContainerdConfigPath = "/etc/containerd/config.toml"
+
+// The template that k3s renders its containerd config from, if it exists.
+const k3sContainerdConfigTemplatePath = "/var/lib/rancher/k3s/agent/etc/containerd/config.toml.tmpl"
+
+func validateContainerdConfigPatches(desired *api.Cluster) error {
+	product := clusterid.Product(desired.Product)
+	if product != clusterid.ProductKIND && product != clusterid.ProductK3D {
+		return fmt.Errorf("containerdConfigPatches may only be set on clusters with product: kind or k3d. Actual product: %s", desired.Product)
+	}
+	if product == clusterid.ProductK3D && containerRuntime(desired) != ContainerRuntimeContainerd {
+		return fmt.Errorf("containerdConfigPatches need containerRuntime: containerd. Actual: %s", containerRuntime(desired))
+	}
+	for i, patch := range desired.ContainerdConfigPatches {
+		if strings.TrimSpace(patch) == "" {
+			return fmt.Errorf("containerdConfigPatches[%d] must not be empty", i)
+		}
+	}
+	return nil
+}
+
+// The TOML to add to the containerd config, one patch after another.
+func containerdConfigPatchesTOML(patches []string) string {
+	var b strings.Builder
+	for _, patch := range patches {
+		b.WriteString("\n")
+		b.WriteString(strings.TrimRight(patch, "\n"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Appends the patches to the containerd config on each kind node, and
+// restarts containerd to pick them up.
+//
+// Only runs after the cluster is created, so that re-applying the cluster
+// doesn't append the same patches twice.
+func (c *Controller) applyContainerdConfigPatches(ctx context.Context, cluster *api.Cluster) error {
+	containers, err := c.nodeContainers(ctx, cluster.Name, false)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("cluster %s: no node containers found", cluster.Name)
+	}
+
+	toml := containerdConfigPatchesTOML(cluster.ContainerdConfigPatches)
+	for _, container := range containers {
+		errOut := bytes.NewBuffer(nil)
+		err := c.runner.RunIO(ctx, genericclioptions.IOStreams{In: strings.NewReader(toml), Out: errOut, ErrOut: errOut},
+			"docker", "exec", "-i", container.ID, "sh", "-c", "cat >> "+kindContainerdConfigPath)
+		if err != nil {
+			return fmt.Errorf("cluster %s: patching containerd config on node %s: %v: %s",
+				cluster.Name, containerName(container), err, strings.TrimSpace(errOut.String()))
+		}
+
+		errOut.Reset()
+		err = c.runner.RunIO(ctx, genericclioptions.IOStreams{Out: errOut, ErrOut: errOut},
+			"docker", "exec", container.ID, "systemctl", "restart", "containerd")
+		if err != nil {
+			return fmt.Errorf("cluster %s: restarting containerd on node %s: %v: %s",
+				cluster.Name, containerName(container), err, strings.TrimSpace(errOut.String()))
+		}
+	}
+	return nil
+}
+
+// The directory where the containerd config templates of k3d clusters are stored.
+//
+// Returns an empty string if there's no home directory, which disables
+// containerdConfigPatches on k3d.
+func defaultContainerdConfigDir() string {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, ".ctlptl", "containerd")
+}
+
+func containerdConfigTemplatePath(dir, clusterName string) string {
+	return filepath.Join(dir, invalidLockNameChars.ReplaceAllString(clusterName, "_")+".toml.tmpl")
+}
+
+// A k3s containerd config template that renders the config k3s would
+// generate anyway, followed by the patches.
+func k3sContainerdConfigTemplate(patches []string) string {
+	return `{{ template "base" . }}` + "\n" + containerdConfigPatchesTOML(patches)
+}
+
+func writeContainerdConfigTemplate(path string, patches []string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("writing containerd config template: %v", err)
+	}
+	err = os.WriteFile(path, []byte(k3sContainerdConfigTemplate(patches)), 0644)
+	if err != nil {
+		return fmt.Errorf("writing containerd config template: %v", err)
+	}
+	return nil
+}
+
+// K3d flags that mount the containerd config template into servers and agents.
+func containerdConfigK3dFlags(templatePath string) []string {
+	return []string{"--volume", fmt.Sprintf("%s:%s@server:*;agent:*", templatePath, k3sContainerdConfigTemplatePath)}
+}
@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+const stargzPatch = `[proxy_plugins]
+  [proxy_plugins.stargz]
+    type = "snapshot"
+    address = "/run/containerd-stargz-grpc/containerd-stargz-grpc.sock"
+`
+
+func TestValidateContainerdConfigPatches(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		cluster api.Cluster
+		err     string
+	}{
+		{"kind", api.Cluster{Product: "kind", ContainerdConfigPatches: []string{stargzPatch}}, ""},
+		{"k3d", api.Cluster{Product: "k3d", ContainerdConfigPatches: []string{stargzPatch}}, ""},
+		{"minikube", api.Cluster{Product: "minikube", ContainerdConfigPatches: []string{stargzPatch}},
+			"containerdConfigPatches may only be set on clusters with product: kind or k3d. Actual product: minikube"},
+		{"k3d with docker", api.Cluster{Product: "k3d", ContainerRuntime: "docker", ContainerdConfigPatches: []string{stargzPatch}},
+			"containerdConfigPatches need containerRuntime: containerd. Actual: docker"},
+		{"empty", api.Cluster{Product: "kind", ContainerdConfigPatches: []string{stargzPatch, " \n"}},
+			"containerdConfigPatches[1] must not be empty"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateContainerdConfigPatches(&tc.cluster)
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestApplyContainerdConfigPatches(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+	runner := &kubectlRunner{}
+	f.controller.runner = runner
+
+	err := f.controller.applyContainerdConfigPatches(context.Background(), &api.Cluster{
+		Name:                    "kind-foo",
+		Product:                 "kind",
+		ContainerdConfigPatches: []string{stargzPatch, `[plugins."io.containerd.grpc.v1.cri"]` + "\n  enable_unprivileged_ports = true"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"docker exec -i foo-control-plane-id sh -c cat >> /etc/containerd/config.toml",
+		"docker exec foo-control-plane-id systemctl restart containerd",
+		"docker exec -i foo-worker-id sh -c cat >> /etc/containerd/config.toml",
+		"docker exec foo-worker-id systemctl restart containerd",
+	}, runner.calls)
+
+	expected := "\n" + stargzPatch +
+		"\n" + `[plugins."io.containerd.grpc.v1.cri"]` + "\n  enable_unprivileged_ports = true\n"
+	assert.Equal(t, []string{expected, expected}, runner.stdin)
+}
+
+func TestClusterApplyPatchesContainerdOnCreate(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	f.newFakeAdmin(clusterid.ProductKIND)
+	f.setupKindNodes("kind-kind")
+	runner := &kubectlRunner{}
+	f.controller.runner = runner
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:                 string(clusterid.ProductKIND),
+		ContainerdConfigPatches: []string{stargzPatch},
+	})
+	require.NoError(t, err)
+	assert.Len(t, runner.callsMatching("systemctl restart containerd"), 2)
+	assert.Contains(t, runner.stdin, "\n"+stargzPatch)
+}
+
+func TestK3dCreateArgsContainerdConfigPatches(t *testing.T) {
+	a := newK3dAdmin(genericclioptions.IOStreams{})
+	a.containerdConfigDir = "/home/me/.ctlptl/containerd"
+	args := a.createArgs(&api.Cluster{
+		Name:                    "k3d-foo",
+		ContainerdConfigPatches: []string{stargzPatch},
+	}, nil)
+	assert.Equal(t, []string{
+		"cluster", "create", "foo",
+		"--volume", "/home/me/.ctlptl/containerd/k3d-foo.toml.tmpl:" +
+			"/var/lib/rancher/k3s/agent/etc/containerd/config.toml.tmpl@server:*;agent:*",
+	}, args)
+}
+
+func TestWriteContainerdConfigTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "containerd", "k3d-foo.toml.tmpl")
+	err := writeContainerdConfigTemplate(path, []string{stargzPatch})
+	require.NoError(t, err)
+
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{{ template \"base\" . }}\n\n"+stargzPatch, string(contents))
+}
@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/mitchellh/go-homedir"
+	"gopkg.in/yaml.v3"
+
+	"github.com/tilt-dev/ctlptl/internal/audit"
+)
+
+// ControllerOptions are the settings that apply to every cluster that the
+// controller manages.
+type ControllerOptions struct {
+	// A prefix on every kubeconfig context that ctlptl manages. See ContextPrefixEnv.
+	ContextPrefix string
+
+	// Fail instead of warning when applying a cluster that someone else owns.
+	StrictOwnership bool
+
+	// The path of the audit log. Empty disables it. See audit.EnvVar.
+	AuditLog string
+}
+
+// The ctlptl config file, for settings that should apply to every command
+// without passing flags or setting environment variables.
+type configFile struct {
+	ContextPrefix   string `yaml:"contextPrefix,omitempty"`
+	StrictOwnership bool   `yaml:"strictOwnership,omitempty"`
+	AuditLog        string `yaml:"auditLog,omitempty"`
+}
+
+// Returns an empty string if there's no home directory, which disables
+// the config file.
+func defaultConfigFilePath() string {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, ".ctlptl", "config.yaml")
+}
+
+func readConfigFile(path string) (configFile, error) {
+	result := configFile{}
+	if path == "" {
+		return result, nil
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return result, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&result)
+	if err != nil && err != io.EOF {
+		return result, fmt.Errorf("reading %s: %v", path, err)
+	}
+	return result, nil
+}
+
+// DefaultControllerOptions reads the options from the environment, falling
+// back to the ctlptl config file (~/.ctlptl/config.yaml).
+func DefaultControllerOptions() (ControllerOptions, error) {
+	return controllerOptions(defaultConfigFilePath())
+}
+
+func controllerOptions(configPath string) (ControllerOptions, error) {
+	config, err := readConfigFile(configPath)
+	if err != nil {
+		return ControllerOptions{}, err
+	}
+
+	options := ControllerOptions{
+		ContextPrefix:   config.ContextPrefix,
+		StrictOwnership: config.StrictOwnership,
+		AuditLog:        config.AuditLog,
+	}
+	if prefix, ok := os.LookupEnv(ContextPrefixEnv); ok {
+		options.ContextPrefix = prefix
+	}
+	if strict, ok := os.LookupEnv(StrictOwnershipEnv); ok {
+		options.StrictOwnership, _ = strconv.ParseBool(strict)
+	}
+	if path, ok := os.LookupEnv(audit.EnvVar); ok {
+		options.AuditLog = path
+	}
+	return options, nil
+}
@@ -0,0 +1,54 @@
+package cluster
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/internal/audit"
+)
+
+func TestControllerOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("contextPrefix: ci-42-\nstrictOwnership: true\nauditLog: /var/log/ctlptl.log\n"), 0644))
+
+	// The environment isn't set in tests, so make sure it stays that way.
+	t.Setenv(ContextPrefixEnv, "")
+	t.Setenv(StrictOwnershipEnv, "")
+	t.Setenv(audit.EnvVar, "")
+	require.NoError(t, os.Unsetenv(ContextPrefixEnv))
+	require.NoError(t, os.Unsetenv(StrictOwnershipEnv))
+	require.NoError(t, os.Unsetenv(audit.EnvVar))
+
+	options, err := controllerOptions(path)
+	require.NoError(t, err)
+	assert.Equal(t, ControllerOptions{ContextPrefix: "ci-42-", StrictOwnership: true, AuditLog: "/var/log/ctlptl.log"}, options)
+
+	// The environment takes priority over the config file, even when it's empty.
+	t.Setenv(ContextPrefixEnv, "")
+	t.Setenv(StrictOwnershipEnv, "false")
+	t.Setenv(audit.EnvVar, "")
+	options, err = controllerOptions(path)
+	require.NoError(t, err)
+	assert.Equal(t, ControllerOptions{}, options)
+}
+
+func TestControllerOptionsNoConfigFile(t *testing.T) {
+	t.Setenv(ContextPrefixEnv, "ci-7-")
+	options, err := controllerOptions(filepath.Join(t.TempDir(), "config.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "ci-7-", options.ContextPrefix)
+}
+
+func TestControllerOptionsInvalidConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("contextPrefx: ci-42-\n"), 0644))
+
+	_, err := controllerOptions(path)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "field contextPrefx not found")
+	}
+}
@@ -0,0 +1,224 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/internal/hostsfile"
+)
+
+// The ConfigMap that holds the CoreDNS config, in every product we support.
+const (
+	coreDNSNamespace = "kube-system"
+	coreDNSConfigMap = "coredns"
+	coreDNSConfigKey = "Corefile"
+	coreDNSPodLabel  = "k8s-app=kube-dns"
+)
+
+// PatchCoreDNS adds hostname-to-IP entries to the hosts plugin of the
+// cluster's CoreDNS config, so that pods can resolve names that aren't in
+// any DNS server, without rebuilding CoreDNS.
+//
+// Merges with the entries that are already there. An empty IP removes
+// the hostname. Restarts the CoreDNS pods if the config changed, so that
+// they don't wait for the reload plugin.
+func (c *Controller) PatchCoreDNS(ctx context.Context, clusterName string, entries map[string]string) error {
+	for hostname, ip := range entries {
+		err := hostsfile.ValidateHostname(hostname)
+		if err != nil {
+			return fmt.Errorf("patching CoreDNS: %v", err)
+		}
+		if ip != "" && net.ParseIP(ip) == nil {
+			return fmt.Errorf("patching CoreDNS: %s: invalid IP %q", hostname, ip)
+		}
+	}
+
+	client, err := c.client(clusterName)
+	if err != nil {
+		return err
+	}
+
+	cm, err := client.CoreV1().ConfigMaps(coreDNSNamespace).Get(ctx, coreDNSConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("cluster %s: reading CoreDNS config: %v", clusterName, err)
+	}
+
+	corefile, ok := cm.Data[coreDNSConfigKey]
+	if !ok {
+		return fmt.Errorf("cluster %s: CoreDNS config has no %s", clusterName, coreDNSConfigKey)
+	}
+	patched, err := patchCorefile(corefile, entries)
+	if err != nil {
+		return fmt.Errorf("cluster %s: patching CoreDNS config: %v", clusterName, err)
+	}
+	if patched == corefile {
+		return nil
+	}
+
+	cm.Data[coreDNSConfigKey] = patched
+	_, err = client.CoreV1().ConfigMaps(coreDNSNamespace).Update(ctx, cm, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("cluster %s: updating CoreDNS config: %v", clusterName, err)
+	}
+
+	pods, err := client.CoreV1().Pods(coreDNSNamespace).List(ctx, metav1.ListOptions{LabelSelector: coreDNSPodLabel})
+	if err != nil {
+		return fmt.Errorf("cluster %s: restarting CoreDNS: %v", clusterName, err)
+	}
+	for _, pod := range pods.Items {
+		err := client.CoreV1().Pods(coreDNSNamespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
+		if err != nil {
+			return fmt.Errorf("cluster %s: restarting CoreDNS: %v", clusterName, err)
+		}
+	}
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Updated CoreDNS hosts in cluster %s\n", clusterName)
+	return nil
+}
+
+// Counts the braces on a line of a Corefile, ignoring comments.
+func braceDelta(line string) int {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.Count(line, "{") - strings.Count(line, "}")
+}
+
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// Merges the entries into the hosts plugin of the first server block,
+// adding the plugin if it's not there.
+func patchCorefile(corefile string, entries map[string]string) (string, error) {
+	lines := strings.Split(corefile, "\n")
+
+	// Find the first server block, and the hosts plugin inside it.
+	serverStart, serverEnd := -1, -1
+	hostsStart, hostsEnd := -1, -1
+	depth := 0
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if depth == 0 && serverStart == -1 && strings.HasSuffix(trimmed, "{") {
+			serverStart = i
+		} else if depth == 1 && serverStart != -1 && hostsStart == -1 {
+			fields := strings.Fields(trimmed)
+			if len(fields) > 0 && fields[0] == "hosts" {
+				hostsStart = i
+				if !strings.HasSuffix(trimmed, "{") {
+					hostsEnd = i
+				}
+			}
+		}
+
+		depth += braceDelta(line)
+		if depth < 0 {
+			return "", fmt.Errorf("unbalanced braces at line %d", i+1)
+		}
+		if hostsStart != -1 && hostsEnd == -1 && i > hostsStart && depth == 1 {
+			hostsEnd = i
+		}
+		if serverStart != -1 && serverEnd == -1 && i >= serverStart && depth == 0 {
+			serverEnd = i
+			break
+		}
+	}
+	if serverStart == -1 || serverEnd == -1 {
+		return "", fmt.Errorf("no server block")
+	}
+
+	// Read the existing entries and options of the hosts plugin.
+	hosts := make(map[string]string)
+	var args []string
+	var options []string
+	indent := "    "
+	if serverStart+1 < serverEnd {
+		if s := leadingSpace(lines[serverStart+1]); s != "" {
+			indent = s
+		}
+	}
+	if hostsStart != -1 {
+		indent = leadingSpace(lines[hostsStart])
+		args = strings.Fields(strings.TrimSuffix(strings.TrimSpace(lines[hostsStart]), "{"))[1:]
+		var body []string
+		if hostsEnd > hostsStart {
+			body = lines[hostsStart+1 : hostsEnd]
+		}
+		for _, line := range body {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && net.ParseIP(fields[0]) != nil {
+				for _, hostname := range fields[1:] {
+					hosts[hostname] = fields[0]
+				}
+				continue
+			}
+			if len(fields) > 0 {
+				options = append(options, strings.TrimSpace(line))
+			}
+		}
+	} else {
+		// Without fallthrough, the hosts plugin answers NXDOMAIN for every
+		// other name.
+		options = []string{"fallthrough"}
+	}
+
+	for hostname, ip := range entries {
+		if ip == "" {
+			delete(hosts, hostname)
+		} else {
+			hosts[hostname] = ip
+		}
+	}
+	if hostsStart == -1 && len(hosts) == 0 {
+		return corefile, nil
+	}
+
+	hostnames := make([]string, 0, len(hosts))
+	for hostname := range hosts {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	block := []string{indent + strings.Join(append([]string{"hosts"}, append(args, "{")...), " ")}
+	for _, hostname := range hostnames {
+		block = append(block, fmt.Sprintf("%s%s%s %s", indent, indent, hosts[hostname], hostname))
+	}
+	for _, option := range options {
+		block = append(block, indent+indent+option)
+	}
+	block = append(block, indent+"}")
+
+	var result []string
+	if hostsStart == -1 {
+		result = append(result, lines[:serverStart+1]...)
+		result = append(result, block...)
+		result = append(result, lines[serverStart+1:]...)
+	} else {
+		// Leave the existing block alone if nothing changed, so that we
+		// don't reformat it.
+		existing := strings.Join(lines[hostsStart:hostsEnd+1], "\n")
+		if sameHostsBlock(existing, strings.Join(block, "\n")) {
+			return corefile, nil
+		}
+		result = append(result, lines[:hostsStart]...)
+		result = append(result, block...)
+		result = append(result, lines[hostsEnd+1:]...)
+	}
+	return strings.Join(result, "\n"), nil
+}
+
+// Compares two hosts blocks, ignoring whitespace.
+func sameHostsBlock(a, b string) bool {
+	normalize := func(s string) string {
+		var lines []string
+		for _, line := range strings.Split(s, "\n") {
+			lines = append(lines, strings.Join(strings.Fields(line), " "))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return normalize(a) == normalize(b)
+}
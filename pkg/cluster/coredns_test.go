@@ -0,0 +1,133 @@
+package cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const kindCorefile = `.:53 {
+    errors
+    health {
+       lameduck 5s
+    }
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+       pods insecure
+       fallthrough in-addr.arpa ip6.arpa
+       ttl 30
+    }
+    forward . /etc/resolv.conf {
+       max_concurrent 1000
+    }
+    cache 30
+    reload
+}
+`
+
+func TestPatchCorefileAddsHosts(t *testing.T) {
+	patched, err := patchCorefile(kindCorefile, map[string]string{
+		"db.example.com":  "10.0.0.5",
+		"api.example.com": "10.0.0.6",
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(patched, `.:53 {
+    hosts {
+        10.0.0.6 api.example.com
+        10.0.0.5 db.example.com
+        fallthrough
+    }
+    errors
+`), patched)
+	assert.True(t, strings.HasSuffix(patched, "    reload\n}\n"), patched)
+}
+
+func TestPatchCorefileMergesHosts(t *testing.T) {
+	corefile := `.:53 {
+    errors
+    hosts /etc/coredns/NodeHosts {
+      172.18.0.2 k3d-k3s-default-server-0
+      ttl 60
+      reload 15s
+      fallthrough
+    }
+    cache 30
+}
+`
+	patched, err := patchCorefile(corefile, map[string]string{
+		"db.example.com":           "10.0.0.5",
+		"k3d-k3s-default-server-0": "",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `.:53 {
+    errors
+    hosts /etc/coredns/NodeHosts {
+        10.0.0.5 db.example.com
+        ttl 60
+        reload 15s
+        fallthrough
+    }
+    cache 30
+}
+`, patched)
+
+	// Patching again with the same entries changes nothing.
+	again, err := patchCorefile(patched, map[string]string{"db.example.com": "10.0.0.5"})
+	require.NoError(t, err)
+	assert.Equal(t, patched, again)
+}
+
+func TestPatchCorefileRemoveMissing(t *testing.T) {
+	patched, err := patchCorefile(kindCorefile, map[string]string{"db.example.com": ""})
+	require.NoError(t, err)
+	assert.Equal(t, kindCorefile, patched)
+}
+
+func TestPatchCorefileUnbalanced(t *testing.T) {
+	_, err := patchCorefile("}\n", map[string]string{"db.example.com": "10.0.0.5"})
+	assert.Error(t, err)
+}
+
+func TestPatchCoreDNS(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	_, err := f.fakeK8s.CoreV1().ConfigMaps("kube-system").Create(ctx, &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": kindCorefile},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	_, err = f.fakeK8s.CoreV1().Pods("kube-system").Create(ctx, &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns-abc", Namespace: "kube-system", Labels: map[string]string{"k8s-app": "kube-dns"}},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	_, err = f.fakeK8s.CoreV1().Pods("kube-system").Create(ctx, &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "etcd", Namespace: "kube-system"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	err = f.controller.PatchCoreDNS(ctx, "microk8s", map[string]string{"db.example.com": "10.0.0.5"})
+	require.NoError(t, err)
+
+	cm, err := f.fakeK8s.CoreV1().ConfigMaps("kube-system").Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, cm.Data["Corefile"], "10.0.0.5 db.example.com")
+
+	pods, err := f.fakeK8s.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, pods.Items, 1)
+	assert.Equal(t, "etcd", pods.Items[0].Name)
+}
+
+func TestPatchCoreDNSInvalid(t *testing.T) {
+	f := newFixture(t)
+
+	err := f.controller.PatchCoreDNS(context.Background(), "microk8s", map[string]string{"db.example.com": "not-an-ip"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `db.example.com: invalid IP "not-an-ip"`)
+	}
+}
@@ -0,0 +1,84 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/cost"
+)
+
+func validateCostBudget(desired *api.Cluster) error {
+	budget := desired.CostBudget
+	err := cost.ValidateProvider(budget.CloudProvider)
+	if err != nil {
+		return fmt.Errorf("costBudget: %v", err)
+	}
+	if budget.MaxMonthlyCost < 0 {
+		return fmt.Errorf("costBudget.maxMonthlyCost must not be negative. Actual: %v", budget.MaxMonthlyCost)
+	}
+	return nil
+}
+
+// EstimateCost prices the nodes of the named cluster as cloud instances.
+//
+// Each node is priced as the cheapest instance type in the provider with
+// at least the node's CPU and memory capacity. Nodes in containers (like kind's)
+// report the capacity of the whole machine, so a multi-node cluster is priced
+// as several machines that size.
+func (c *Controller) EstimateCost(ctx context.Context, clusterName, provider string) (*cost.Estimate, error) {
+	client, err := c.client(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %v", err)
+	}
+
+	sizes := make([]cost.NodeSize, 0, len(nodes.Items))
+	for _, node := range nodes.Items {
+		cpu := node.Status.Capacity[corev1.ResourceCPU]
+		memory := node.Status.Capacity[corev1.ResourceMemory]
+		sizes = append(sizes, cost.NodeSize{
+			Name:      node.Name,
+			CPUs:      float64(cpu.MilliValue()) / 1000,
+			MemoryGiB: float64(memory.Value()) / (1 << 30),
+		})
+	}
+	return cost.EstimateNodes(provider, sizes)
+}
+
+// Prints the estimated cost of the cluster, and warns if it's over budget.
+//
+// The budget is advice, so failing to estimate is only a warning.
+func (c *Controller) checkCostBudget(ctx context.Context, cluster *api.Cluster) {
+	budget := cluster.CostBudget
+	estimate, err := c.EstimateCost(ctx, cluster.Name, budget.CloudProvider)
+	if err != nil {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Warning: estimating cost of cluster %s: %v\n", cluster.Name, err)
+		return
+	}
+
+	instances := make([]string, 0, len(estimate.Nodes))
+	for _, node := range estimate.Nodes {
+		name := node.InstanceType.Name
+		if node.Count > 1 {
+			name = fmt.Sprintf("%dx %s", node.Count, name)
+		}
+		instances = append(instances, name)
+	}
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 💰 Estimated cost on %s: $%.2f/month (%s)\n",
+		estimate.Provider, estimate.MonthlyCost, strings.Join(instances, ", "))
+
+	if estimate.MonthlyCost > budget.MaxMonthlyCost {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Warning: cluster %s would cost an estimated $%.2f/month on %s, over its costBudget of $%.2f/month\n",
+			cluster.Name, estimate.MonthlyCost, estimate.Provider, budget.MaxMonthlyCost)
+	}
+}
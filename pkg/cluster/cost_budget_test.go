@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func (f *fixture) setNodeCapacity(name, cpu, memory string) {
+	ctx := context.Background()
+	node, err := f.fakeK8s.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	require.NoError(f.t, err)
+	node.Status.Capacity = v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(cpu),
+		v1.ResourceMemory: resource.MustParse(memory),
+	}
+	_, err = f.fakeK8s.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+	require.NoError(f.t, err)
+}
+
+func TestCostBudgetOverBudget(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+	f.dockerClient.started = true
+	f.newFakeAdmin(clusterid.ProductKIND)
+	f.setNodeCapacity("node-1", "4", "16Gi")
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:    string(clusterid.ProductKIND),
+		CostBudget: &api.CostBudgetSpec{CloudProvider: "aws", MaxMonthlyCost: 100},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, f.errOut.String(), "Estimated cost on aws: $140.16/month (m5.xlarge)")
+	assert.Contains(t, f.errOut.String(),
+		"Warning: cluster kind-kind would cost an estimated $140.16/month on aws, over its costBudget of $100.00/month")
+}
+
+func TestCostBudgetUnderBudget(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+	f.dockerClient.started = true
+	f.newFakeAdmin(clusterid.ProductKIND)
+	f.setNodeCapacity("node-1", "2", "4Gi")
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:    string(clusterid.ProductKIND),
+		CostBudget: &api.CostBudgetSpec{CloudProvider: "gcp", MaxMonthlyCost: 100},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, f.errOut.String(), "Estimated cost on gcp: $24.46/month (e2-medium)")
+	assert.NotContains(t, f.errOut.String(), "over its costBudget")
+}
+
+func TestValidateCostBudget(t *testing.T) {
+	err := validateCostBudget(&api.Cluster{CostBudget: &api.CostBudgetSpec{CloudProvider: "digitalocean"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "costBudget: cloud provider must be one of: aws, azure, gcp. Actual: digitalocean")
+	}
+
+	err = validateCostBudget(&api.Cluster{CostBudget: &api.CostBudgetSpec{CloudProvider: "aws", MaxMonthlyCost: -1}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "costBudget.maxMonthlyCost must not be negative")
+	}
+}
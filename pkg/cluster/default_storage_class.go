@@ -0,0 +1,157 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// The annotation that marks the default StorageClass, and its deprecated beta
+// version, which some products (e.g., older minikube) still set.
+const (
+	defaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+func validateDefaultStorageClass(desired *api.Cluster) error {
+	spec := desired.DefaultStorageClass
+	if spec.Name == "" {
+		return fmt.Errorf("defaultStorageClass.name must be set")
+	}
+	if spec.Provisioner == "" && (len(spec.Parameters) > 0 || spec.ReclaimPolicy != "" || spec.VolumeBindingMode != "") {
+		return fmt.Errorf("defaultStorageClass: parameters, reclaimPolicy, and volumeBindingMode only apply " +
+			"when ctlptl creates the StorageClass. Set provisioner")
+	}
+	switch corev1.PersistentVolumeReclaimPolicy(spec.ReclaimPolicy) {
+	case "", corev1.PersistentVolumeReclaimDelete, corev1.PersistentVolumeReclaimRetain:
+	default:
+		return fmt.Errorf("defaultStorageClass.reclaimPolicy must be one of: Delete, Retain. Actual: %s", spec.ReclaimPolicy)
+	}
+	switch storagev1.VolumeBindingMode(spec.VolumeBindingMode) {
+	case "", storagev1.VolumeBindingImmediate, storagev1.VolumeBindingWaitForFirstConsumer:
+	default:
+		return fmt.Errorf("defaultStorageClass.volumeBindingMode must be one of: Immediate, WaitForFirstConsumer. Actual: %s",
+			spec.VolumeBindingMode)
+	}
+	return nil
+}
+
+func isDefaultStorageClass(sc *storagev1.StorageClass) bool {
+	return sc.Annotations[defaultStorageClassAnnotation] == "true" ||
+		sc.Annotations[betaDefaultStorageClassAnnotation] == "true"
+}
+
+func newDefaultStorageClass(spec *api.DefaultStorageClassSpec) *storagev1.StorageClass {
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	if spec.ReclaimPolicy != "" {
+		reclaimPolicy = corev1.PersistentVolumeReclaimPolicy(spec.ReclaimPolicy)
+	}
+	bindingMode := storagev1.VolumeBindingWaitForFirstConsumer
+	if spec.VolumeBindingMode != "" {
+		bindingMode = storagev1.VolumeBindingMode(spec.VolumeBindingMode)
+	}
+	return &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        spec.Name,
+			Annotations: map[string]string{defaultStorageClassAnnotation: "true"},
+		},
+		Provisioner:       spec.Provisioner,
+		Parameters:        spec.Parameters,
+		ReclaimPolicy:     &reclaimPolicy,
+		VolumeBindingMode: &bindingMode,
+	}
+}
+
+// Makes the cluster's defaultStorageClass the only default StorageClass,
+// creating it if it has a provisioner.
+func (c *Controller) ensureDefaultStorageClass(ctx context.Context, desired *api.Cluster) error {
+	spec := desired.DefaultStorageClass
+	client, err := c.client(desired.Name)
+	if err != nil {
+		return err
+	}
+	storageClasses := client.StorageV1().StorageClasses()
+
+	sc, err := storageClasses.Get(ctx, spec.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		if spec.Provisioner == "" {
+			return fmt.Errorf("StorageClass %s not found. Set defaultStorageClass.provisioner to create it", spec.Name)
+		}
+		sc, err = storageClasses.Create(ctx, newDefaultStorageClass(spec), metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "creating StorageClass %s", spec.Name)
+		}
+	} else if spec.Provisioner != "" && sc.Provisioner != spec.Provisioner {
+		// The provisioner is immutable, so we'd have to delete the StorageClass
+		// out from under any PVCs that use it.
+		return fmt.Errorf("StorageClass %s already exists with provisioner %s, not %s. Delete it to re-create it",
+			spec.Name, sc.Provisioner, spec.Provisioner)
+	}
+
+	list, err := storageClasses.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range list.Items {
+		other := &list.Items[i]
+		if other.Name == spec.Name || !isDefaultStorageClass(other) {
+			continue
+		}
+		for _, key := range []string{defaultStorageClassAnnotation, betaDefaultStorageClassAnnotation} {
+			if _, ok := other.Annotations[key]; ok {
+				other.Annotations[key] = "false"
+			}
+		}
+		_, err = storageClasses.Update(ctx, other, metav1.UpdateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "unsetting default StorageClass %s", other.Name)
+		}
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, "   StorageClass %s is no longer the default\n", other.Name)
+	}
+
+	if sc.Annotations[defaultStorageClassAnnotation] != "true" {
+		if sc.Annotations == nil {
+			sc.Annotations = make(map[string]string)
+		}
+		sc.Annotations[defaultStorageClassAnnotation] = "true"
+		delete(sc.Annotations, betaDefaultStorageClassAnnotation)
+		_, err = storageClasses.Update(ctx, sc, metav1.UpdateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "setting default StorageClass %s", sc.Name)
+		}
+	}
+
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔧 Set default StorageClass %s on cluster %s\n", spec.Name, desired.Name)
+	return nil
+}
+
+// Reports the cluster's default StorageClasses. If there's more than one,
+// Kubernetes uses the newest, so list them all to make the problem visible.
+func (c *Controller) populateDefaultStorageClassStatus(ctx context.Context, cluster *api.Cluster, client kubernetes.Interface) error {
+	list, err := client.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for i := range list.Items {
+		if isDefaultStorageClass(&list.Items[i]) {
+			names = append(names, list.Items[i].Name)
+		}
+	}
+	sort.Strings(names)
+	cluster.Status.DefaultStorageClass = strings.Join(names, ",")
+	return nil
+}
@@ -0,0 +1,139 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func (f *fixture) storageClass(name string) *storagev1.StorageClass {
+	sc, err := f.fakeK8s.StorageV1().StorageClasses().Get(context.Background(), name, metav1.GetOptions{})
+	require.NoError(f.t, err)
+	return sc
+}
+
+func newKindWithStandardStorageClass(t *testing.T) *fixture {
+	f := newFixture(t)
+	f.setOS("darwin")
+	_ = f.newFakeAdmin(clusterid.ProductKIND)
+	f.controller.runner = &kubectlRunner{}
+
+	// kind ships with local-path as the default.
+	_, err := f.fakeK8s.StorageV1().StorageClasses().Create(context.Background(), &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "standard",
+			Annotations: map[string]string{defaultStorageClassAnnotation: "true"},
+		},
+		Provisioner: "rancher.io/local-path",
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	return f
+}
+
+func TestClusterApplyDefaultStorageClass(t *testing.T) {
+	f := newKindWithStandardStorageClass(t)
+
+	cluster := &api.Cluster{
+		Product: string(clusterid.ProductKIND),
+		DefaultStorageClass: &api.DefaultStorageClassSpec{
+			Name:        "nfs",
+			Provisioner: "nfs.csi.k8s.io",
+			Parameters:  map[string]string{"server": "nfs.local", "share": "/exports"},
+		},
+	}
+	_, err := f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+
+	nfs := f.storageClass("nfs")
+	assert.Equal(t, "nfs.csi.k8s.io", nfs.Provisioner)
+	assert.Equal(t, "nfs.local", nfs.Parameters["server"])
+	assert.Equal(t, storagev1.VolumeBindingWaitForFirstConsumer, *nfs.VolumeBindingMode)
+	assert.Equal(t, "true", nfs.Annotations[defaultStorageClassAnnotation])
+	assert.Equal(t, "false", f.storageClass("standard").Annotations[defaultStorageClassAnnotation])
+	assert.Contains(t, f.errOut.String(), "StorageClass standard is no longer the default")
+	assert.Contains(t, f.errOut.String(), "Set default StorageClass nfs on cluster kind-kind")
+
+	c, err := f.controller.Get(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.Equal(t, "nfs", c.Status.DefaultStorageClass)
+	assert.Equal(t, cluster.DefaultStorageClass, c.DefaultStorageClass)
+
+	// Applying again does nothing.
+	f.errOut.Reset()
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.NotContains(t, f.errOut.String(), "StorageClass")
+}
+
+func TestClusterApplyDefaultStorageClassExisting(t *testing.T) {
+	f := newKindWithStandardStorageClass(t)
+	_, err := f.fakeK8s.StorageV1().StorageClasses().Create(context.Background(), &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "openebs-hostpath"},
+		Provisioner: "openebs.io/local",
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	cluster := &api.Cluster{
+		Product:             string(clusterid.ProductKIND),
+		DefaultStorageClass: &api.DefaultStorageClassSpec{Name: "openebs-hostpath"},
+	}
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, "true", f.storageClass("openebs-hostpath").Annotations[defaultStorageClassAnnotation])
+	assert.Equal(t, "false", f.storageClass("standard").Annotations[defaultStorageClassAnnotation])
+
+	// If something else takes over as the default, applying again fixes it.
+	standard := f.storageClass("standard")
+	standard.Annotations[defaultStorageClassAnnotation] = "true"
+	_, err = f.fakeK8s.StorageV1().StorageClasses().Update(context.Background(), standard, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, "false", f.storageClass("standard").Annotations[defaultStorageClassAnnotation])
+}
+
+func TestClusterApplyDefaultStorageClassMissing(t *testing.T) {
+	f := newKindWithStandardStorageClass(t)
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:             string(clusterid.ProductKIND),
+		DefaultStorageClass: &api.DefaultStorageClassSpec{Name: "nfs"},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "StorageClass nfs not found. Set defaultStorageClass.provisioner to create it")
+	}
+}
+
+func TestValidateDefaultStorageClass(t *testing.T) {
+	for _, tc := range []struct {
+		spec api.DefaultStorageClassSpec
+		err  string
+	}{
+		{api.DefaultStorageClassSpec{Name: "standard"}, ""},
+		{api.DefaultStorageClassSpec{Name: "nfs", Provisioner: "nfs.csi.k8s.io", ReclaimPolicy: "Retain"}, ""},
+		{api.DefaultStorageClassSpec{}, "defaultStorageClass.name must be set"},
+		{api.DefaultStorageClassSpec{Name: "standard", ReclaimPolicy: "Retain"}, "Set provisioner"},
+		{api.DefaultStorageClassSpec{Name: "nfs", Provisioner: "nfs.csi.k8s.io", ReclaimPolicy: "Recycle"},
+			"reclaimPolicy must be one of: Delete, Retain"},
+		{api.DefaultStorageClassSpec{Name: "nfs", Provisioner: "nfs.csi.k8s.io", VolumeBindingMode: "Lazy"},
+			"volumeBindingMode must be one of: Immediate, WaitForFirstConsumer"},
+	} {
+		t.Run(tc.spec.Name, func(t *testing.T) {
+			spec := tc.spec
+			err := validateDefaultStorageClass(&api.Cluster{DefaultStorageClass: &spec})
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// DiskUsage describes how full the root filesystem of a node container is.
+//
+// Sizes are in bytes, rounded to the precision of `df -h`.
+type DiskUsage struct {
+	Node       string  `json:"node"`
+	Total      int64   `json:"total"`
+	Used       int64   `json:"used"`
+	Available  int64   `json:"available"`
+	UsePercent float64 `json:"usePercent"`
+}
+
+// GetNodeDiskUsage returns the disk usage of the root filesystem in the named
+// node's container, by running df in it.
+//
+// If nodeName is empty, returns the usage of the first control-plane node.
+//
+// Only supported on clusters whose nodes are Docker containers (kind and k3d).
+func (c *Controller) GetNodeDiskUsage(ctx context.Context, clusterName, nodeName string) (*DiskUsage, error) {
+	containers, err := c.nodeContainers(ctx, clusterName, nodeName == "")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, container := range containers {
+		if nodeName == "" || containerName(container) == nodeName {
+			return c.containerDiskUsage(ctx, container)
+		}
+	}
+
+	if nodeName == "" {
+		return nil, fmt.Errorf("cluster %s: no control-plane node found", clusterName)
+	}
+	return nil, fmt.Errorf("cluster %s: node %q not found", clusterName, nodeName)
+}
+
+// ListNodeDiskUsage returns the disk usage of every node in the named cluster.
+func (c *Controller) ListNodeDiskUsage(ctx context.Context, clusterName string) ([]DiskUsage, error) {
+	containers, err := c.nodeContainers(ctx, clusterName, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("cluster %s: no node containers found", clusterName)
+	}
+
+	result := make([]DiskUsage, 0, len(containers))
+	for _, container := range containers {
+		usage, err := c.containerDiskUsage(ctx, container)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *usage)
+	}
+	return result, nil
+}
+
+func (c *Controller) containerDiskUsage(ctx context.Context, container types.Container) (*DiskUsage, error) {
+	node := containerName(container)
+	out := bytes.NewBuffer(nil)
+	errOut := bytes.NewBuffer(nil)
+	err := c.runner.RunIO(ctx, genericclioptions.IOStreams{Out: out, ErrOut: errOut},
+		"docker", "exec", container.ID, "df", "-h", "/")
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading disk usage on node %s: %s", node, strings.TrimSpace(errOut.String()))
+	}
+
+	usage, err := parseDF(out.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading disk usage on node %s", node)
+	}
+	usage.Node = node
+	return usage, nil
+}
+
+// Parses the output of `df -h /`.
+//
+// GNU df prints one line per filesystem:
+//
+//	Filesystem      Size  Used Avail Use% Mounted on
+//	overlay          59G   23G   34G  41% /
+//
+// Older dfs (like busybox) wrap the line when the filesystem name is long,
+// so the sizes land on a line of their own with one field fewer. Both have
+// the size, used, and available columns right before the Use% column.
+func parseDF(out string) (*DiskUsage, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("parsing df: unexpected output %q", out)
+	}
+
+	fields := strings.Fields(strings.Join(lines[1:], " "))
+	percentIndex := -1
+	for i, field := range fields {
+		if i >= 3 && strings.HasSuffix(field, "%") {
+			percentIndex = i
+			break
+		}
+	}
+	if percentIndex == -1 {
+		return nil, fmt.Errorf("parsing df: no Use%% column in %q", out)
+	}
+
+	sizes := make([]int64, 3)
+	for i, field := range fields[percentIndex-3 : percentIndex] {
+		size, err := parseDFSize(field)
+		if err != nil {
+			return nil, fmt.Errorf("parsing df: %v", err)
+		}
+		sizes[i] = size
+	}
+
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(fields[percentIndex], "%"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing df: invalid Use%% %q", fields[percentIndex])
+	}
+
+	return &DiskUsage{
+		Total:      sizes[0],
+		Used:       sizes[1],
+		Available:  sizes[2],
+		UsePercent: percent,
+	}, nil
+}
+
+// df -h prints sizes in powers of 1024, like 59G or 1.5T.
+func parseDFSize(s string) (int64, error) {
+	size, err := units.RAMInBytes(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return size, nil
+}
@@ -0,0 +1,75 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+)
+
+const gnuDFOutput = `Filesystem      Size  Used Avail Use% Mounted on
+overlay          59G   48G  8.5G  85% /
+`
+
+// Busybox wraps the line when the filesystem name is long.
+const wrappedDFOutput = `Filesystem                Size      Used Available Use% Mounted on
+/dev/mapper/docker-thinpool-root
+                          1.5T    512.0G      1.0T  34% /
+`
+
+func TestParseDF(t *testing.T) {
+	usage, err := parseDF(gnuDFOutput)
+	require.NoError(t, err)
+	assert.Equal(t, &DiskUsage{
+		Total:      59 << 30,
+		Used:       48 << 30,
+		Available:  int64(8.5 * (1 << 30)),
+		UsePercent: 85,
+	}, usage)
+
+	usage, err = parseDF(wrappedDFOutput)
+	require.NoError(t, err)
+	assert.Equal(t, &DiskUsage{
+		Total:      int64(1.5 * (1 << 40)),
+		Used:       512 << 30,
+		Available:  1 << 40,
+		UsePercent: 34,
+	}, usage)
+
+	_, err = parseDF("df: /: No such file or directory\n")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "parsing df: unexpected output")
+	}
+}
+
+func TestGetNodeDiskUsage(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+
+	execs := [][]string{}
+	f.controller.runner = exec.NewFakeCmdRunner(func(argv []string) string {
+		execs = append(execs, argv)
+		return gnuDFOutput
+	})
+
+	ctx := context.Background()
+	usage, err := f.controller.GetNodeDiskUsage(ctx, "kind-foo", "foo-worker")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"docker", "exec", "foo-worker-id", "df", "-h", "/"}}, execs)
+	assert.Equal(t, "foo-worker", usage.Node)
+	assert.Equal(t, 85.0, usage.UsePercent)
+
+	usages, err := f.controller.ListNodeDiskUsage(ctx, "kind-foo")
+	require.NoError(t, err)
+	require.Len(t, usages, 2)
+	assert.Equal(t, "foo-control-plane", usages[0].Node)
+	assert.Equal(t, "foo-worker", usages[1].Node)
+
+	_, err = f.controller.GetNodeDiskUsage(ctx, "kind-foo", "foo-worker2")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `node "foo-worker2" not found`)
+	}
+}
@@ -0,0 +1,112 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// The settings of a cluster that ctlptl manages, and checks for drift.
+type clusterSettings struct {
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+	Nodes             int    `json:"nodes,omitempty"`
+
+	// The host of the registry the cluster pulls from, or none.
+	Registry string `json:"registry,omitempty"`
+}
+
+// ClusterDrift is a managed setting of a cluster whose live value
+// doesn't match the value that ctlptl recorded.
+type ClusterDrift struct {
+	Field    string `json:"field"`
+	Recorded string `json:"recorded"`
+	Live     string `json:"live"`
+}
+
+func (d ClusterDrift) String() string {
+	return fmt.Sprintf("%s: recorded %s, live %s", d.Field, d.Recorded, d.Live)
+}
+
+// Reads the managed settings of a running cluster.
+//
+// The cluster must be populated by Get or List.
+func (c *Controller) liveClusterSettings(ctx context.Context, cluster *api.Cluster) (*clusterSettings, error) {
+	if cluster.Status.KubernetesVersion == "" {
+		return nil, fmt.Errorf("cluster %s is not reachable", cluster.Name)
+	}
+
+	client, err := c.client(cluster.Name)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes of cluster %s: %v", cluster.Name, err)
+	}
+
+	registry := "none"
+	if cluster.Status.LocalRegistryHosting != nil && cluster.Status.LocalRegistryHosting.Host != "" {
+		registry = cluster.Status.LocalRegistryHosting.Host
+	}
+	return &clusterSettings{
+		KubernetesVersion: cluster.Status.KubernetesVersion,
+		Nodes:             len(nodes.Items),
+		Registry:          registry,
+	}, nil
+}
+
+// Records the managed settings of the cluster after an apply, so that
+// VerifyDrift can tell when they change out-of-band.
+func (c *Controller) recordClusterSettings(ctx context.Context, cluster *api.Cluster) {
+	if c.clusterStatePath == "" {
+		return
+	}
+	settings, err := c.liveClusterSettings(ctx, cluster)
+	if err != nil {
+		klog.V(4).Infof("WARNING: recording settings of cluster %s: %v\n", cluster.Name, err)
+		return
+	}
+	c.updateClusterState(cluster.Name, func(state *clusterState) {
+		state.Settings = settings
+	})
+}
+
+// VerifyDrift compares the live Kubernetes version, node count, and registry
+// of the cluster against the ones that ctlptl recorded when it last applied
+// the cluster. Returns the settings that changed out-of-band, like when
+// someone upgrades the cluster, adds a node, or re-wires the registry by hand.
+//
+// Unlike diffing against a config file, this only needs the cluster.
+// The cluster must be populated by Get or List.
+func (c *Controller) VerifyDrift(ctx context.Context, cluster *api.Cluster) ([]ClusterDrift, error) {
+	state, _, err := c.readClusterState(cluster.Name)
+	if err != nil {
+		return nil, err
+	}
+	recorded := state.Settings
+	if recorded == nil {
+		return nil, fmt.Errorf("cluster %s: no settings recorded. Apply the cluster with ctlptl to record them", cluster.Name)
+	}
+
+	live, err := c.liveClusterSettings(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []ClusterDrift{}
+	if recorded.KubernetesVersion != live.KubernetesVersion {
+		result = append(result, ClusterDrift{Field: "kubernetesVersion", Recorded: recorded.KubernetesVersion, Live: live.KubernetesVersion})
+	}
+	if recorded.Nodes != live.Nodes {
+		result = append(result, ClusterDrift{Field: "nodes", Recorded: strconv.Itoa(recorded.Nodes), Live: strconv.Itoa(live.Nodes)})
+	}
+	if recorded.Registry != live.Registry {
+		result = append(result, ClusterDrift{Field: "registry", Recorded: recorded.Registry, Live: live.Registry})
+	}
+	return result, nil
+}
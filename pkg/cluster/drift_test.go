@@ -0,0 +1,67 @@
+package cluster
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"github.com/tilt-dev/localregistry-go"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestVerifyDrift(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	f.newFakeAdmin(clusterid.ProductKIND)
+	f.controller.clusterStatePath = filepath.Join(t.TempDir(), "clusters.json")
+	ctx := context.Background()
+
+	result, err := f.controller.Apply(ctx, &api.Cluster{Product: string(clusterid.ProductKIND)})
+	require.NoError(t, err)
+
+	drifts, err := f.controller.VerifyDrift(ctx, result)
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+
+	// Someone adds a node, and re-wires the registry, by hand.
+	_, err = f.fakeK8s.CoreV1().Nodes().Create(ctx, &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "kind-worker2"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	live, err := f.controller.Get(ctx, "kind-kind")
+	require.NoError(t, err)
+	live.Status.LocalRegistryHosting = &localregistry.LocalRegistryHostingV1{Host: "localhost:5999"}
+
+	drifts, err = f.controller.VerifyDrift(ctx, live)
+	require.NoError(t, err)
+	assert.Equal(t, []ClusterDrift{
+		{Field: "nodes", Recorded: "1", Live: "2"},
+		{Field: "registry", Recorded: "none", Live: "localhost:5999"},
+	}, drifts)
+
+	// Applying again accepts the new settings.
+	result, err = f.controller.Apply(ctx, &api.Cluster{Product: string(clusterid.ProductKIND)})
+	require.NoError(t, err)
+	drifts, err = f.controller.VerifyDrift(ctx, result)
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+}
+
+func TestVerifyDriftNotRecorded(t *testing.T) {
+	f := newFixture(t)
+	f.controller.clusterStatePath = filepath.Join(t.TempDir(), "clusters.json")
+	ctx := context.Background()
+
+	cluster, err := f.controller.Get(ctx, "docker-desktop")
+	require.NoError(t, err)
+	_, err = f.controller.VerifyDrift(ctx, cluster)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cluster docker-desktop: no settings recorded")
+	}
+}
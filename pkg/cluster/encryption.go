@@ -0,0 +1,176 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/tilt-dev/clusterid"
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Where the EncryptionConfiguration is mounted in the control-plane nodes,
+// and in the apiserver pod.
+const encryptionConfigNodePath = "/etc/kubernetes/ctlptl-encryption-config.yaml"
+
+const defaultEncryptionProvider = "aescbc"
+
+// The providers that we can generate a key for. Each takes a 32-byte key.
+var encryptionProviders = []string{"aescbc", "secretbox", "identity"}
+
+// A resource, optionally with its group (e.g., secrets or widgets.example.com).
+var encryptionResourcePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// The directory where the encryption configs of kind clusters are stored.
+//
+// Returns an empty string if there's no home directory, which disables
+// encryption at rest.
+func defaultEncryptionConfigDir() string {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, ".ctlptl", "encryption")
+}
+
+func encryptionConfigPath(dir, clusterName string) string {
+	return filepath.Join(dir, invalidLockNameChars.ReplaceAllString(clusterName, "_")+".yaml")
+}
+
+func validateEncryptionAtRest(desired *api.Cluster) error {
+	if clusterid.Product(desired.Product) != clusterid.ProductKIND {
+		return fmt.Errorf("encryptionAtRest may only be set on clusters with product: kind. Actual product: %s", desired.Product)
+	}
+	spec := desired.EncryptionAtRest
+	if spec.Provider != "" && !containsString(encryptionProviders, spec.Provider) {
+		return fmt.Errorf("encryptionAtRest.provider must be one of: %s. Actual: %s",
+			strings.Join(encryptionProviders, ", "), spec.Provider)
+	}
+	seen := make(map[string]bool, len(spec.Resources))
+	for _, r := range spec.Resources {
+		if !encryptionResourcePattern.MatchString(r) {
+			return fmt.Errorf("invalid encryptionAtRest.resources entry %q: must be a lowercase resource, "+
+				"optionally with its group (e.g., secrets or widgets.example.com)", r)
+		}
+		if seen[r] {
+			return fmt.Errorf("encryptionAtRest.resources lists %s more than once", r)
+		}
+		seen[r] = true
+	}
+	return nil
+}
+
+func encryptionProvider(spec *api.EncryptionAtRestSpec) string {
+	if spec.Provider == "" {
+		return defaultEncryptionProvider
+	}
+	return spec.Provider
+}
+
+// The resources to encrypt, sorted. Defaults to secrets.
+func encryptionResources(spec *api.EncryptionAtRestSpec) []string {
+	if len(spec.Resources) == 0 {
+		return []string{"secrets"}
+	}
+	resources := append([]string{}, spec.Resources...)
+	sort.Strings(resources)
+	return resources
+}
+
+// Compares encryption specs after applying defaults.
+func encryptionAtRestEqual(a, b *api.EncryptionAtRestSpec) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return encryptionProvider(a) == encryptionProvider(b) &&
+		strings.Join(encryptionResources(a), ",") == strings.Join(encryptionResources(b), ",")
+}
+
+// Describes the spec for log messages, e.g., "aescbc for secrets".
+func describeEncryptionAtRest(spec *api.EncryptionAtRestSpec) string {
+	if spec == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%s for %s", encryptionProvider(spec), strings.Join(encryptionResources(spec), ", "))
+}
+
+// The EncryptionConfiguration for the spec, with the given base64 key.
+//
+// The identity provider comes last, so that the apiserver can still read
+// resources that were written in plain text.
+func encryptionConfig(spec *api.EncryptionAtRestSpec, key string) string {
+	var b strings.Builder
+	b.WriteString("apiVersion: apiserver.config.k8s.io/v1\nkind: EncryptionConfiguration\nresources:\n- resources:\n")
+	for _, r := range encryptionResources(spec) {
+		b.WriteString(fmt.Sprintf("  - %s\n", r))
+	}
+	b.WriteString("  providers:\n")
+	provider := encryptionProvider(spec)
+	if provider != "identity" {
+		b.WriteString(fmt.Sprintf("  - %s:\n      keys:\n      - name: ctlptl-key1\n        secret: %s\n", provider, key))
+	}
+	b.WriteString("  - identity: {}\n")
+	return b.String()
+}
+
+// Writes the EncryptionConfiguration with a new random key.
+//
+// The file holds the key, so only the current user can read it.
+func writeEncryptionConfig(path string, spec *api.EncryptionAtRestSpec) error {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return fmt.Errorf("generating encryption key: %v", err)
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return fmt.Errorf("writing encryption config: %v", err)
+	}
+	err = os.WriteFile(path, []byte(encryptionConfig(spec, base64.StdEncoding.EncodeToString(key))), 0600)
+	if err != nil {
+		return fmt.Errorf("writing encryption config: %v", err)
+	}
+	return nil
+}
+
+// Mounts the encryption config into every control-plane node.
+func addEncryptionConfigMounts(kindConfig *v1alpha4.Cluster, hostPath string) {
+	if len(kindConfig.Nodes) == 0 {
+		kindConfig.Nodes = []v1alpha4.Node{{Role: v1alpha4.ControlPlaneRole}}
+	}
+	for i, node := range kindConfig.Nodes {
+		// Kind defaults empty roles to control-plane.
+		if node.Role != v1alpha4.ControlPlaneRole && node.Role != "" {
+			continue
+		}
+		kindConfig.Nodes[i].ExtraMounts = append(kindConfig.Nodes[i].ExtraMounts, v1alpha4.Mount{
+			HostPath:      hostPath,
+			ContainerPath: encryptionConfigNodePath,
+			Readonly:      true,
+		})
+	}
+}
+
+// A kubeadm patch that mounts the encryption config into the apiserver pod,
+// and points the apiserver at it.
+func encryptionAtRestPatch() string {
+	return fmt.Sprintf(`kind: ClusterConfiguration
+apiServer:
+  extraArgs:
+    encryption-provider-config: %[1]s
+  extraVolumes:
+  - name: ctlptl-encryption-config
+    hostPath: %[1]s
+    mountPath: %[1]s
+    readOnly: true
+    pathType: File
+`, encryptionConfigNodePath)
+}
@@ -0,0 +1,180 @@
+package cluster
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"gopkg.in/yaml.v3"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestClusterApplyEncryptionAtRest(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	kindAdmin := f.newFakeAdmin(clusterid.ProductKIND)
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:          string(clusterid.ProductKIND),
+		EncryptionAtRest: &api.EncryptionAtRestSpec{},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	kindAdmin.created = nil
+
+	// Spelling out the defaults doesn't re-create.
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:          string(clusterid.ProductKIND),
+		EncryptionAtRest: &api.EncryptionAtRestSpec{Provider: "aescbc", Resources: []string{"secrets"}},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, kindAdmin.created)
+	assert.Nil(t, kindAdmin.deleted)
+
+	f.errOut.Truncate(0)
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:          string(clusterid.ProductKIND),
+		EncryptionAtRest: &api.EncryptionAtRestSpec{Provider: "secretbox", Resources: []string{"secrets", "configmaps"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.deleted.Name)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	assert.Contains(t, f.errOut.String(),
+		"desired encryption at rest (secretbox for configmaps, secrets) does not match current (aescbc for secrets)")
+}
+
+func TestClusterApplyEncryptionAtRestInvalid(t *testing.T) {
+	f := newFixture(t)
+
+	for _, tc := range []struct {
+		name    string
+		cluster *api.Cluster
+		err     string
+	}{
+		{"product", &api.Cluster{Product: string(clusterid.ProductMinikube), EncryptionAtRest: &api.EncryptionAtRestSpec{}},
+			"encryptionAtRest may only be set on clusters with product: kind"},
+		{"provider", &api.Cluster{Product: string(clusterid.ProductKIND), EncryptionAtRest: &api.EncryptionAtRestSpec{Provider: "kms"}},
+			"encryptionAtRest.provider must be one of: aescbc, secretbox, identity. Actual: kms"},
+		{"resource", &api.Cluster{Product: string(clusterid.ProductKIND), EncryptionAtRest: &api.EncryptionAtRestSpec{Resources: []string{"Secrets"}}},
+			`invalid encryptionAtRest.resources entry "Secrets"`},
+		{"duplicate", &api.Cluster{Product: string(clusterid.ProductKIND), EncryptionAtRest: &api.EncryptionAtRestSpec{Resources: []string{"secrets", "secrets"}}},
+			"encryptionAtRest.resources lists secrets more than once"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := f.controller.Apply(context.Background(), tc.cluster)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestKindConfigEncryptionAtRest(t *testing.T) {
+	iostreams := genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
+	a := newKindAdmin(iostreams, &fakeDockerClient{})
+	a.encryptionConfigDir = "/home/me/.ctlptl/encryption"
+
+	config := a.kindClusterConfig(&api.Cluster{
+		Name:             "kind-kind",
+		Product:          string(clusterid.ProductKIND),
+		EncryptionAtRest: &api.EncryptionAtRestSpec{},
+		KindV1Alpha4Cluster: &v1alpha4.Cluster{
+			Nodes: []v1alpha4.Node{{Role: v1alpha4.ControlPlaneRole}, {Role: v1alpha4.WorkerRole}},
+		},
+	}, nil)
+	assert.Equal(t, []v1alpha4.Mount{{
+		HostPath:      "/home/me/.ctlptl/encryption/kind-kind.yaml",
+		ContainerPath: encryptionConfigNodePath,
+		Readonly:      true,
+	}}, config.Nodes[0].ExtraMounts)
+	assert.Empty(t, config.Nodes[1].ExtraMounts)
+
+	require.Len(t, config.KubeadmConfigPatches, 1)
+	var patch struct {
+		Kind      string `yaml:"kind"`
+		APIServer struct {
+			ExtraArgs    map[string]string `yaml:"extraArgs"`
+			ExtraVolumes []struct {
+				HostPath  string `yaml:"hostPath"`
+				MountPath string `yaml:"mountPath"`
+				ReadOnly  bool   `yaml:"readOnly"`
+			} `yaml:"extraVolumes"`
+		} `yaml:"apiServer"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(config.KubeadmConfigPatches[0]), &patch))
+	assert.Equal(t, "ClusterConfiguration", patch.Kind)
+	assert.Equal(t, encryptionConfigNodePath, patch.APIServer.ExtraArgs["encryption-provider-config"])
+	require.Len(t, patch.APIServer.ExtraVolumes, 1)
+	assert.Equal(t, encryptionConfigNodePath, patch.APIServer.ExtraVolumes[0].HostPath)
+	assert.Equal(t, encryptionConfigNodePath, patch.APIServer.ExtraVolumes[0].MountPath)
+	assert.True(t, patch.APIServer.ExtraVolumes[0].ReadOnly)
+}
+
+// The parts of the EncryptionConfiguration that we generate.
+type testEncryptionConfig struct {
+	Kind      string `yaml:"kind"`
+	Resources []struct {
+		Resources []string `yaml:"resources"`
+		Providers []map[string]struct {
+			Keys []struct {
+				Name   string `yaml:"name"`
+				Secret string `yaml:"secret"`
+			} `yaml:"keys"`
+		} `yaml:"providers"`
+	} `yaml:"resources"`
+}
+
+func TestWriteEncryptionConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "encryption", "kind-kind.yaml")
+	err := writeEncryptionConfig(path, &api.EncryptionAtRestSpec{
+		Provider:  "secretbox",
+		Resources: []string{"secrets", "configmaps"},
+	})
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var config testEncryptionConfig
+	require.NoError(t, yaml.Unmarshal(contents, &config))
+	assert.Equal(t, "EncryptionConfiguration", config.Kind)
+	require.Len(t, config.Resources, 1)
+	assert.Equal(t, []string{"configmaps", "secrets"}, config.Resources[0].Resources)
+
+	providers := config.Resources[0].Providers
+	require.Len(t, providers, 2)
+	require.Len(t, providers[0]["secretbox"].Keys, 1)
+	key, err := base64.StdEncoding.DecodeString(providers[0]["secretbox"].Keys[0].Secret)
+	require.NoError(t, err)
+	assert.Len(t, key, 32)
+	assert.Contains(t, providers[1], "identity")
+
+	// Each cluster gets a new key.
+	err = writeEncryptionConfig(path, &api.EncryptionAtRestSpec{Provider: "secretbox"})
+	require.NoError(t, err)
+	newContents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(newContents), providers[0]["secretbox"].Keys[0].Secret)
+}
+
+func TestEncryptionConfigIdentity(t *testing.T) {
+	assert.Equal(t, `apiVersion: apiserver.config.k8s.io/v1
+kind: EncryptionConfiguration
+resources:
+- resources:
+  - secrets
+  providers:
+  - identity: {}
+`, encryptionConfig(&api.EncryptionAtRestSpec{Provider: "identity"}, ""))
+}
@@ -0,0 +1,78 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+)
+
+// The port that the apiserver listens on inside kind and k3d node containers.
+const nodeAPIServerPort = 6443
+
+// GetEndpoint returns the apiserver URL that the kubeconfig uses for the
+// named cluster. This is usually only reachable from the host.
+func (c *Controller) GetEndpoint(ctx context.Context, clusterName string) (string, error) {
+	config := c.configCopy()
+	ct, ok := config.Contexts[c.contextName(clusterName)]
+	if !ok {
+		return "", fmt.Errorf("cluster %s: kubectl context not found", clusterName)
+	}
+	cl, ok := config.Clusters[ct.Cluster]
+	if !ok || cl.Server == "" {
+		return "", fmt.Errorf("cluster %s: kubeconfig has no server", clusterName)
+	}
+	return cl.Server, nil
+}
+
+// GetReachableEndpoint returns the apiserver URL that's reachable from inside
+// the cluster's Docker network, e.g., from a pod, or from another container on
+// the network.
+//
+// The kubeconfig usually points at a port forwarded to 127.0.0.1, which
+// only works from the host. Instead, this uses the IP of the first
+// control-plane node's container.
+//
+// Only supported on products whose nodes are Docker containers (kind and k3d).
+func (c *Controller) GetReachableEndpoint(ctx context.Context, clusterName string) (string, error) {
+	product, err := c.productFromConfig(clusterName)
+	if err != nil {
+		return "", err
+	}
+
+	containers, err := c.nodeContainers(ctx, clusterName, true)
+	if err != nil {
+		return "", err
+	}
+	if len(containers) == 0 {
+		return "", fmt.Errorf("cluster %s: no control-plane node found", clusterName)
+	}
+
+	networkName, err := clusterNetworkName(product, clusterName)
+	if err != nil {
+		return "", err
+	}
+	ip := containerIP(containers[0], networkName)
+	if ip == "" {
+		return "", fmt.Errorf("cluster %s: node %s has no IP on network %s",
+			clusterName, containerName(containers[0]), networkName)
+	}
+	return fmt.Sprintf("https://%s", net.JoinHostPort(ip, strconv.Itoa(nodeAPIServerPort))), nil
+}
+
+// The IP of the container on the given network, preferring IPv4.
+func containerIP(container types.Container, networkName string) string {
+	if container.NetworkSettings == nil {
+		return ""
+	}
+	network, ok := container.NetworkSettings.Networks[networkName]
+	if !ok || network == nil {
+		return ""
+	}
+	if network.IPAddress != "" {
+		return network.IPAddress
+	}
+	return network.GlobalIPv6Address
+}
@@ -0,0 +1,53 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetReachableEndpoint(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+	for i, c := range f.dockerClient.containers {
+		if c.ID == "foo-control-plane-id" {
+			f.dockerClient.containers[i].NetworkSettings = &types.SummaryNetworkSettings{
+				Networks: map[string]*network.EndpointSettings{
+					"bridge": {IPAddress: "172.17.0.2"},
+					"kind":   {IPAddress: "172.18.0.3"},
+				},
+			}
+		}
+	}
+
+	ctx := context.Background()
+	endpoint, err := f.controller.GetEndpoint(ctx, "kind-foo")
+	require.NoError(t, err)
+	assert.Equal(t, "https://127.0.0.1:6443", endpoint)
+
+	endpoint, err = f.controller.GetReachableEndpoint(ctx, "kind-foo")
+	require.NoError(t, err)
+	assert.Equal(t, "https://172.18.0.3:6443", endpoint)
+}
+
+func TestGetReachableEndpointNoNetwork(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+
+	_, err := f.controller.GetReachableEndpoint(context.Background(), "kind-foo")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "node foo-control-plane has no IP on network kind")
+	}
+}
+
+func TestGetReachableEndpointUnsupportedProduct(t *testing.T) {
+	f := newFixture(t)
+	_, err := f.controller.GetReachableEndpoint(context.Background(), "docker-desktop")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not supported for product docker-desktop")
+	}
+}
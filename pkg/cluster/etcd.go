@@ -0,0 +1,267 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tilt-dev/clusterid"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// The directory inside the control-plane nodes where etcd snapshots are stored.
+// Mounted from etcdBackup.hostPath.
+const etcdBackupNodePath = "/var/lib/ctlptl/etcd-backup"
+
+const etcdBackupCronJob = "ctlptl-etcd-backup"
+
+// The etcd data directory. Mounted into the etcd static pod by kubeadm,
+// so it's a convenient place to hand snapshots to and from etcdctl.
+const etcdDataPath = "/var/lib/etcd"
+
+const etcdPKIPath = "/etc/kubernetes/pki/etcd"
+
+var etcdctlFlags = []string{
+	"--endpoints=https://127.0.0.1:2379",
+	"--cacert=" + etcdPKIPath + "/ca.crt",
+	"--cert=" + etcdPKIPath + "/healthcheck-client.crt",
+	"--key=" + etcdPKIPath + "/healthcheck-client.key",
+}
+
+// Runs on the control-plane node. Uses etcdctl inside the running etcd container
+// to write a snapshot, then moves it to the backup directory.
+const etcdBackupScript = `set -e
+ETCD=$(crictl ps -q --name '^etcd$' | head -n 1)
+crictl exec "$ETCD" etcdctl %s snapshot save %s/ctlptl-snapshot.db
+mv %s/ctlptl-snapshot.db %s/%s
+`
+
+// Runs on the control-plane node after the snapshot has been copied to
+// /var/lib/etcd/ctlptl-snapshot.db.
+//
+// Restores the snapshot into a new data dir while etcd is still running,
+// then stops etcd, swaps the data dirs, and starts etcd again.
+const etcdRestoreScript = `set -e
+MANIFEST=/etc/kubernetes/manifests/etcd.yaml
+NAME=$(sed -n 's/.*--name=//p' "$MANIFEST")
+PEER_URL=$(sed -n 's/.*--initial-advertise-peer-urls=//p' "$MANIFEST")
+ETCD=$(crictl ps -q --name '^etcd$' | head -n 1)
+rm -rf /var/lib/etcd/ctlptl-restore
+crictl exec "$ETCD" etcdctl snapshot restore /var/lib/etcd/ctlptl-snapshot.db \
+  --data-dir=/var/lib/etcd/ctlptl-restore \
+  --name="$NAME" \
+  --initial-cluster="$NAME=$PEER_URL" \
+  --initial-advertise-peer-urls="$PEER_URL"
+mv "$MANIFEST" /etc/kubernetes/etcd.yaml
+while crictl ps -q --name '^etcd$' | grep -q .; do sleep 1; done
+rm -rf /var/lib/etcd/member.bak
+mv /var/lib/etcd/member /var/lib/etcd/member.bak
+mv /var/lib/etcd/ctlptl-restore/member /var/lib/etcd/member
+rm -rf /var/lib/etcd/ctlptl-restore /var/lib/etcd/ctlptl-snapshot.db
+mv /etc/kubernetes/etcd.yaml "$MANIFEST"
+`
+
+func validateEtcdBackup(desired *api.Cluster) error {
+	if clusterid.Product(desired.Product) != clusterid.ProductKIND {
+		return fmt.Errorf("etcdBackup may only be set on clusters with product: kind. Actual product: %s", desired.Product)
+	}
+	if !filepath.IsAbs(desired.EtcdBackup.HostPath) {
+		return fmt.Errorf("etcdBackup.hostPath must be an absolute path. Actual: %q", desired.EtcdBackup.HostPath)
+	}
+	return nil
+}
+
+func etcdBackupHostPath(cluster *api.Cluster) string {
+	if cluster.EtcdBackup == nil {
+		return ""
+	}
+	return cluster.EtcdBackup.HostPath
+}
+
+// Mounts the etcd backup directory into every control-plane node.
+func addEtcdBackupMounts(kindConfig *v1alpha4.Cluster, hostPath string) {
+	if len(kindConfig.Nodes) == 0 {
+		kindConfig.Nodes = []v1alpha4.Node{{Role: v1alpha4.ControlPlaneRole}}
+	}
+	for i, node := range kindConfig.Nodes {
+		// Kind defaults empty roles to control-plane.
+		if node.Role != v1alpha4.ControlPlaneRole && node.Role != "" {
+			continue
+		}
+		kindConfig.Nodes[i].ExtraMounts = append(kindConfig.Nodes[i].ExtraMounts, v1alpha4.Mount{
+			HostPath:      hostPath,
+			ContainerPath: etcdBackupNodePath,
+		})
+	}
+}
+
+// BackupEtcd snapshots the etcd data of the named cluster into
+// the etcd backup directory.
+//
+// Returns the path of the snapshot on the host.
+func (c *Controller) BackupEtcd(ctx context.Context, name string) (string, error) {
+	cluster, err := c.Get(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	hostPath := etcdBackupHostPath(cluster)
+	if hostPath == "" {
+		return "", fmt.Errorf("cluster %s: no etcdBackup.hostPath configured", name)
+	}
+
+	containerID, err := c.GetContainerID(ctx, name, "")
+	if err != nil {
+		return "", err
+	}
+
+	fileName := fmt.Sprintf("etcd-%s.db", time.Now().UTC().Format("20060102-150405"))
+	script := fmt.Sprintf(etcdBackupScript,
+		strings.Join(etcdctlFlags, " "), etcdDataPath, etcdDataPath, etcdBackupNodePath, fileName)
+	err = c.runner.RunIO(ctx, c.iostreams, "docker", "exec", containerID, "sh", "-c", script)
+	if err != nil {
+		return "", fmt.Errorf("backing up etcd: %v", err)
+	}
+	return filepath.Join(hostPath, fileName), nil
+}
+
+// RestoreEtcd loads an etcd snapshot from the host into a running cluster,
+// replacing all its current data.
+func (c *Controller) RestoreEtcd(ctx context.Context, name, snapshotPath string) error {
+	cluster, err := c.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	containerID, err := c.GetContainerID(ctx, name, "")
+	if err != nil {
+		return err
+	}
+
+	err = c.runner.RunIO(ctx, c.iostreams, "docker", "cp", snapshotPath,
+		fmt.Sprintf("%s:%s", containerID, path.Join(etcdDataPath, "ctlptl-snapshot.db")))
+	if err != nil {
+		return fmt.Errorf("copying snapshot: %v", err)
+	}
+
+	err = c.runner.RunIO(ctx, c.iostreams, "docker", "exec", containerID, "sh", "-c", etcdRestoreScript)
+	if err != nil {
+		return fmt.Errorf("restoring etcd: %v", err)
+	}
+
+	return c.waitForHealthCheckAfterCreate(ctx, cluster)
+}
+
+// Installs a CronJob on the control-plane that snapshots etcd
+// on the etcdBackup schedule, or removes it if there's no schedule.
+func (c *Controller) ensureEtcdBackupSchedule(ctx context.Context, cluster *api.Cluster) error {
+	client, err := c.client(cluster.Name)
+	if err != nil {
+		return err
+	}
+
+	cronJobs := client.BatchV1().CronJobs("kube-system")
+	existing, err := cronJobs.Get(ctx, etcdBackupCronJob, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	schedule := ""
+	if cluster.EtcdBackup != nil {
+		schedule = cluster.EtcdBackup.Schedule
+	}
+	if schedule == "" {
+		if exists {
+			return cronJobs.Delete(ctx, etcdBackupCronJob, metav1.DeleteOptions{})
+		}
+		return nil
+	}
+
+	// Use the same image as etcd itself, so that etcdctl matches the server.
+	pods, err := client.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{LabelSelector: "component=etcd"})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 || len(pods.Items[0].Spec.Containers) == 0 {
+		return fmt.Errorf("cluster %s: no etcd pod found", cluster.Name)
+	}
+	image := pods.Items[0].Spec.Containers[0].Image
+
+	hostPathType := corev1.HostPathDirectoryOrCreate
+	desired := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      etcdBackupCronJob,
+			Namespace: "kube-system",
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:          schedule,
+			ConcurrencyPolicy: batchv1.ForbidConcurrent,
+			JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							HostNetwork:   true,
+							RestartPolicy: corev1.RestartPolicyOnFailure,
+							NodeSelector:  map[string]string{"node-role.kubernetes.io/control-plane": ""},
+							Tolerations:   []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+							Containers: []corev1.Container{
+								{
+									Name:    "etcdctl",
+									Image:   image,
+									Command: []string{"etcdctl"},
+									// Kubernetes expands $(POD_NAME), which gives every snapshot a unique name.
+									Args: append(append([]string{}, etcdctlFlags...),
+										"snapshot", "save", path.Join(etcdBackupNodePath, "$(POD_NAME).db")),
+									Env: []corev1.EnvVar{
+										{
+											Name: "POD_NAME",
+											ValueFrom: &corev1.EnvVarSource{
+												FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+											},
+										},
+									},
+									VolumeMounts: []corev1.VolumeMount{
+										{Name: "etcd-certs", MountPath: etcdPKIPath, ReadOnly: true},
+										{Name: "etcd-backup", MountPath: etcdBackupNodePath},
+									},
+								},
+							},
+							Volumes: []corev1.Volume{
+								{
+									Name: "etcd-certs",
+									VolumeSource: corev1.VolumeSource{
+										HostPath: &corev1.HostPathVolumeSource{Path: etcdPKIPath},
+									},
+								},
+								{
+									Name: "etcd-backup",
+									VolumeSource: corev1.VolumeSource{
+										HostPath: &corev1.HostPathVolumeSource{Path: etcdBackupNodePath, Type: &hostPathType},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if !exists {
+		_, err = cronJobs.Create(ctx, desired, metav1.CreateOptions{})
+		return err
+	}
+
+	existing.Spec = desired.Spec
+	_, err = cronJobs.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestAddEtcdBackupMounts(t *testing.T) {
+	config := &v1alpha4.Cluster{}
+	addEtcdBackupMounts(config, "/tmp/backup")
+	require.Equal(t, 1, len(config.Nodes))
+	assert.Equal(t, []v1alpha4.Mount{{HostPath: "/tmp/backup", ContainerPath: etcdBackupNodePath}},
+		config.Nodes[0].ExtraMounts)
+
+	config = &v1alpha4.Cluster{
+		Nodes: []v1alpha4.Node{{Role: v1alpha4.ControlPlaneRole}, {Role: v1alpha4.WorkerRole}},
+	}
+	addEtcdBackupMounts(config, "/tmp/backup")
+	assert.Equal(t, 1, len(config.Nodes[0].ExtraMounts))
+	assert.Equal(t, 0, len(config.Nodes[1].ExtraMounts))
+}
+
+func TestBackupEtcd(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+	f.writeClusterSpec(&api.Cluster{
+		Name:       "kind-foo",
+		Product:    "kind",
+		EtcdBackup: &api.EtcdBackupSpec{HostPath: "/tmp/backup"},
+	})
+	runner := exec.NewFakeCmdRunner(func(argv []string) string { return "" })
+	f.controller.runner = runner
+
+	snapshotPath, err := f.controller.BackupEtcd(context.Background(), "kind-foo")
+	require.NoError(t, err)
+	assert.Regexp(t, `^/tmp/backup/etcd-\d{8}-\d{6}\.db$`, snapshotPath)
+	require.Equal(t, 6, len(runner.LastArgs))
+	assert.Equal(t, []string{"docker", "exec", "foo-control-plane-id", "sh", "-c"}, runner.LastArgs[:5])
+	assert.Contains(t, runner.LastArgs[5], "snapshot save /var/lib/etcd/ctlptl-snapshot.db")
+}
+
+func TestBackupEtcdNotConfigured(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+
+	_, err := f.controller.BackupEtcd(context.Background(), "kind-foo")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no etcdBackup.hostPath configured")
+	}
+}
+
+func TestEnsureEtcdBackupSchedule(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	_, err := f.fakeK8s.CoreV1().Pods("kube-system").Create(ctx, &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "etcd-kind-control-plane",
+			Namespace: "kube-system",
+			Labels:    map[string]string{"component": "etcd"},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "etcd", Image: "k8s.gcr.io/etcd:3.5.3-0"}},
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	cluster := &api.Cluster{
+		Name:       "microk8s",
+		EtcdBackup: &api.EtcdBackupSpec{HostPath: "/tmp/backup", Schedule: "0 * * * *"},
+	}
+	err = f.controller.ensureEtcdBackupSchedule(ctx, cluster)
+	require.NoError(t, err)
+
+	cronJob, err := f.fakeK8s.BatchV1().CronJobs("kube-system").Get(ctx, etcdBackupCronJob, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "0 * * * *", cronJob.Spec.Schedule)
+	assert.Equal(t, "k8s.gcr.io/etcd:3.5.3-0", cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Image)
+
+	cluster.EtcdBackup.Schedule = "*/5 * * * *"
+	err = f.controller.ensureEtcdBackupSchedule(ctx, cluster)
+	require.NoError(t, err)
+	cronJob, err = f.fakeK8s.BatchV1().CronJobs("kube-system").Get(ctx, etcdBackupCronJob, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "*/5 * * * *", cronJob.Spec.Schedule)
+
+	cluster.EtcdBackup.Schedule = ""
+	err = f.controller.ensureEtcdBackupSchedule(ctx, cluster)
+	require.NoError(t, err)
+	_, err = f.fakeK8s.BatchV1().CronJobs("kube-system").Get(ctx, etcdBackupCronJob, metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func (f *fixture) writeClusterSpec(cluster *api.Cluster) {
+	err := f.controller.writeClusterSpec(context.Background(), cluster)
+	require.NoError(f.t, err)
+}
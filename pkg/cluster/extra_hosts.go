@@ -0,0 +1,164 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/internal/hostsfile"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Like docker run --add-host, resolves to an IP that reaches the host.
+const hostGateway = "host-gateway"
+
+func validateExtraHosts(desired *api.Cluster) error {
+	product := clusterid.Product(desired.Product)
+	if product != clusterid.ProductKIND && product != clusterid.ProductK3D {
+		return fmt.Errorf("extraHosts may only be set on clusters with product: kind or k3d. Actual product: %s", desired.Product)
+	}
+
+	hostnames := []string{}
+	for i, entry := range desired.ExtraHosts {
+		if entry.IP == "" {
+			return fmt.Errorf("extraHosts[%d]: ip must be set (e.g., 10.1.2.3 or %s)", i, hostGateway)
+		}
+		if entry.IP != hostGateway && net.ParseIP(entry.IP) == nil {
+			return fmt.Errorf("extraHosts[%d]: %s must be an IP or %s", i, entry.IP, hostGateway)
+		}
+		if len(entry.Hostnames) == 0 {
+			return fmt.Errorf("extraHosts[%d]: hostnames must not be empty", i)
+		}
+		hostnames = append(hostnames, entry.Hostnames...)
+	}
+	err := hostsfile.ValidateHostnames(hostnames)
+	if err != nil {
+		return fmt.Errorf("extraHosts: %v", err)
+	}
+	return nil
+}
+
+// The gateway of the node's Docker network, which reaches the host on Linux.
+func nodeGateway(container types.Container) string {
+	if container.NetworkSettings == nil {
+		return ""
+	}
+	names := make([]string, 0, len(container.NetworkSettings.Networks))
+	for name := range container.NetworkSettings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if gw := container.NetworkSettings.Networks[name].Gateway; gw != "" {
+			return gw
+		}
+	}
+	return ""
+}
+
+// Resolves the extraHosts entries to hostname-to-IP pairs for one node.
+func resolveExtraHosts(extraHosts []api.HostEntry, container types.Container) (map[string]string, error) {
+	result := make(map[string]string, len(extraHosts))
+	for _, entry := range extraHosts {
+		ip := entry.IP
+		if ip == hostGateway {
+			ip = nodeGateway(container)
+			if ip == "" {
+				return nil, fmt.Errorf("extraHosts: node %s has no network gateway for %s", containerName(container), hostGateway)
+			}
+		}
+		for _, hostname := range entry.Hostnames {
+			result[hostname] = ip
+		}
+	}
+	return result, nil
+}
+
+func hostsEntries(hosts map[string]string) []hostsfile.Entry {
+	byIP := make(map[string][]string)
+	for hostname, ip := range hosts {
+		byIP[ip] = append(byIP[ip], hostname)
+	}
+	entries := make([]hostsfile.Entry, 0, len(byIP))
+	for ip, hostnames := range byIP {
+		entries = append(entries, hostsfile.Entry{IP: ip, Hostnames: hostnames})
+	}
+	return entries
+}
+
+// Points the extraHosts at their IPs on each node, and in CoreDNS, so that
+// both the nodes and the pods can resolve them. Removes the hostnames that
+// were in oldExtraHosts, but aren't anymore.
+//
+// Docker may rewrite a node's /etc/hosts when the node restarts, so apply
+// runs this every time.
+func (c *Controller) applyExtraHosts(ctx context.Context, cluster *api.Cluster, oldExtraHosts []api.HostEntry) error {
+	containers, err := c.nodeContainers(ctx, cluster.Name, false)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("cluster %s: no node containers found", cluster.Name)
+	}
+
+	var coreDNSEntries map[string]string
+	for _, container := range containers {
+		hosts, err := resolveExtraHosts(cluster.ExtraHosts, container)
+		if err != nil {
+			return err
+		}
+		if coreDNSEntries == nil {
+			// Pods resolve through CoreDNS, so they get the first node's IPs.
+			coreDNSEntries = hosts
+		}
+
+		err = c.updateNodeHosts(ctx, container, hostsEntries(hosts))
+		if err != nil {
+			return fmt.Errorf("cluster %s: updating /etc/hosts on node %s: %v", cluster.Name, containerName(container), err)
+		}
+	}
+
+	for _, entry := range oldExtraHosts {
+		for _, hostname := range entry.Hostnames {
+			if _, ok := coreDNSEntries[hostname]; !ok {
+				coreDNSEntries[hostname] = ""
+			}
+		}
+	}
+	return c.PatchCoreDNS(ctx, cluster.Name, coreDNSEntries)
+}
+
+// Replaces the lines of the node's /etc/hosts that ctlptl manages.
+//
+// Docker bind-mounts /etc/hosts into the container, so it has to be
+// rewritten in place, not replaced.
+func (c *Controller) updateNodeHosts(ctx context.Context, container types.Container, entries []hostsfile.Entry) error {
+	out := bytes.NewBuffer(nil)
+	errOut := bytes.NewBuffer(nil)
+	err := c.runner.RunIO(ctx, genericclioptions.IOStreams{Out: out, ErrOut: errOut},
+		"docker", "exec", container.ID, "cat", "/etc/hosts")
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(errOut.String()))
+	}
+
+	content := out.String()
+	updated := hostsfile.UpdateManaged(content, entries)
+	if updated == content {
+		return nil
+	}
+
+	errOut.Reset()
+	err = c.runner.RunIO(ctx, genericclioptions.IOStreams{In: strings.NewReader(updated), Out: out, ErrOut: errOut},
+		"docker", "exec", "-i", container.ID, "sh", "-c", "cat > /etc/hosts")
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(errOut.String()))
+	}
+	return nil
+}
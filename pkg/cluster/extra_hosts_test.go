@@ -0,0 +1,122 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/internal/hostsfile"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestValidateExtraHosts(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		product    string
+		extraHosts []api.HostEntry
+		err        string
+	}{
+		{"kind", "kind", []api.HostEntry{
+			{IP: "host-gateway", Hostnames: []string{"host.docker.internal"}},
+			{IP: "192.168.1.20", Hostnames: []string{"db.local", "cache.local"}},
+			{IP: "fd00::1", Hostnames: []string{"v6.local"}},
+		}, ""},
+		{"k3d", "k3d", []api.HostEntry{{IP: "10.0.0.5", Hostnames: []string{"db.local"}}}, ""},
+		{"minikube", "minikube", []api.HostEntry{{IP: "10.0.0.5", Hostnames: []string{"db.local"}}},
+			"extraHosts may only be set on clusters with product: kind or k3d"},
+		{"no ip", "kind", []api.HostEntry{{Hostnames: []string{"db.local"}}}, "extraHosts[0]: ip must be set"},
+		{"bad ip", "kind", []api.HostEntry{{IP: "gateway", Hostnames: []string{"db.local"}}},
+			"extraHosts[0]: gateway must be an IP or host-gateway"},
+		{"no hostnames", "kind", []api.HostEntry{{IP: "10.0.0.5"}}, "extraHosts[0]: hostnames must not be empty"},
+		{"bad hostname", "kind", []api.HostEntry{{IP: "10.0.0.5", Hostnames: []string{"db_local"}}}, "extraHosts: db_local"},
+		{"duplicate", "kind", []api.HostEntry{
+			{IP: "10.0.0.5", Hostnames: []string{"db.local"}},
+			{IP: "10.0.0.6", Hostnames: []string{"db.local"}},
+		}, "db.local is listed more than once"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateExtraHosts(&api.Cluster{Product: tc.product, ExtraHosts: tc.extraHosts})
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestApplyExtraHosts(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+	for i := range f.dockerClient.containers {
+		f.dockerClient.containers[i].NetworkSettings = &types.SummaryNetworkSettings{
+			Networks: map[string]*network.EndpointSettings{"kind": {Gateway: "172.18.0.1"}},
+		}
+	}
+	runner := &kubectlRunner{}
+	f.controller.runner = runner
+
+	ctx := context.Background()
+	_, err := f.fakeK8s.CoreV1().ConfigMaps("kube-system").Create(ctx, &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": kindCorefile},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	cluster := &api.Cluster{
+		Name:    "kind-foo",
+		Product: "kind",
+		ExtraHosts: []api.HostEntry{
+			{IP: "host-gateway", Hostnames: []string{"host.docker.internal"}},
+			{IP: "192.168.1.20", Hostnames: []string{"db.local"}},
+		},
+	}
+	err = f.controller.applyExtraHosts(ctx, cluster, []api.HostEntry{{IP: "10.0.0.5", Hostnames: []string{"old.local"}}})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"docker exec foo-control-plane-id cat /etc/hosts",
+		"docker exec -i foo-control-plane-id sh -c cat > /etc/hosts",
+		"docker exec foo-worker-id cat /etc/hosts",
+		"docker exec -i foo-worker-id sh -c cat > /etc/hosts",
+	}, runner.calls)
+	require.Len(t, runner.stdin, 2)
+	assert.Equal(t, "172.18.0.1 host.docker.internal # managed by ctlptl\n"+
+		"192.168.1.20 db.local # managed by ctlptl\n", runner.stdin[0])
+
+	cm, err := f.fakeK8s.CoreV1().ConfigMaps("kube-system").Get(ctx, "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, cm.Data["Corefile"], "192.168.1.20 db.local\n")
+	assert.Contains(t, cm.Data["Corefile"], "172.18.0.1 host.docker.internal\n")
+	assert.NotContains(t, cm.Data["Corefile"], "old.local")
+}
+
+func TestUpdateNodeHostsReplacesManagedLines(t *testing.T) {
+	f := newFixture(t)
+	runner := &kubectlRunner{nodeHosts: "127.0.0.1 localhost\n" +
+		"10.0.0.5 stale.local # managed by ctlptl\n" +
+		"172.18.0.2 foo-control-plane\n"}
+	f.controller.runner = runner
+
+	container := types.Container{ID: "node-id"}
+	entries := []hostsfile.Entry{{IP: "10.1.2.3", Hostnames: []string{"wiki.corp.example", "git.corp.example"}}}
+	err := f.controller.updateNodeHosts(context.Background(), container, entries)
+	require.NoError(t, err)
+	require.Len(t, runner.stdin, 1)
+	assert.Equal(t, "127.0.0.1 localhost\n"+
+		"172.18.0.2 foo-control-plane\n"+
+		"10.1.2.3 git.corp.example wiki.corp.example # managed by ctlptl\n", runner.stdin[0])
+
+	// Nothing changed, so nothing is written.
+	runner.nodeHosts = runner.stdin[0]
+	runner.calls = nil
+	err = f.controller.updateNodeHosts(context.Background(), container, entries)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docker exec node-id cat /etc/hosts"}, runner.calls)
+}
@@ -0,0 +1,98 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/registry"
+)
+
+// SetOutputDir makes Apply write the configs it generates for the underlying
+// cluster tool (e.g., the kind cluster config) to dir.
+func (c *Controller) SetOutputDir(dir string) {
+	c.outputDir = dir
+}
+
+// WriteGeneratedConfigs writes the configs that Apply would generate for the
+// desired cluster to dir, without creating anything.
+//
+// If the cluster's registry doesn't exist yet, the configs assume it will
+// listen on the default port.
+func (c *Controller) WriteGeneratedConfigs(ctx context.Context, desired *api.Cluster, dir string) error {
+	desired = desired.DeepCopy()
+	FillDefaults(desired)
+
+	admin, err := c.admin(ctx, clusterid.Product(desired.Product))
+	if err != nil {
+		return err
+	}
+
+	var reg *api.Registry
+	if desired.Registry != "" {
+		regCtl, err := c.registryController(ctx)
+		if err != nil {
+			return err
+		}
+		regList, err := regCtl.List(ctx, registry.ListOptions{FieldSelector: fmt.Sprintf("name=%s", desired.Registry)})
+		if err != nil {
+			return err
+		}
+		if len(regList.Items) > 0 {
+			reg = &regList.Items[0]
+		} else {
+			_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+				"Registry %s doesn't exist yet. Generated config assumes it will listen on localhost:%d\n",
+				desired.Registry, bootstrapRegistryPort)
+			reg = &api.Registry{
+				TypeMeta: registry.TypeMeta(),
+				Name:     desired.Registry,
+				Status: api.RegistryStatus{
+					HostPort:      bootstrapRegistryPort,
+					ContainerPort: bootstrapRegistryPort,
+				},
+			}
+		}
+	}
+
+	return c.writeGeneratedConfigs(ctx, admin, desired, reg, dir)
+}
+
+func (c *Controller) writeGeneratedConfigs(ctx context.Context, admin Admin, desired *api.Cluster, reg *api.Registry, dir string) error {
+	adminWithConfig, ok := admin.(AdminWithGeneratedConfig)
+	if !ok {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Product %s has no generated config to write\n", desired.Product)
+		return nil
+	}
+
+	files, err := adminWithConfig.GeneratedConfig(ctx, desired, reg)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("creating output dir: %v", err)
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		err := os.WriteFile(path, files[name], 0644)
+		if err != nil {
+			return fmt.Errorf("writing generated config: %v", err)
+		}
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Wrote %s\n", path)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestWriteGeneratedConfigsKind(t *testing.T) {
+	f := newFixture(t)
+	dir := filepath.Join(t.TempDir(), "generated")
+
+	err := f.controller.WriteGeneratedConfigs(context.Background(), &api.Cluster{
+		Product:  string(clusterid.ProductKIND),
+		Registry: "kind-registry",
+	}, dir)
+	require.NoError(t, err)
+
+	contents, err := os.ReadFile(filepath.Join(dir, "kind-config.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), "kind: Cluster")
+	assert.Contains(t, string(contents), `endpoint = ["http://kind-registry:5000"]`)
+	assert.Contains(t, f.errOut.String(), "Registry kind-registry doesn't exist yet")
+}
+
+func TestWriteGeneratedConfigsK3d(t *testing.T) {
+	f := newFixture(t)
+	dir := t.TempDir()
+
+	err := f.controller.WriteGeneratedConfigs(context.Background(), &api.Cluster{
+		Product: string(clusterid.ProductK3D),
+		Name:    "k3d-my cluster",
+	}, dir)
+	require.NoError(t, err)
+
+	contents, err := os.ReadFile(filepath.Join(dir, "k3d-create.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, "k3d cluster create 'my cluster'\n", string(contents))
+}
+
+func TestClusterApplyOutputDir(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+	f.dockerClient.started = true
+	dir := t.TempDir()
+	f.controller.SetOutputDir(dir)
+
+	f.controller.admins[clusterid.ProductKIND] = &generatedConfigAdmin{fakeAdmin: f.newFakeAdmin(clusterid.ProductKIND)}
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:  string(clusterid.ProductKIND),
+		Registry: "kind-registry",
+	})
+	require.NoError(t, err)
+
+	contents, err := os.ReadFile(filepath.Join(dir, "fake-config.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind kind-registry", string(contents))
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "--registry-use", shellQuote("--registry-use"))
+	assert.Equal(t, "''", shellQuote(""))
+	assert.Equal(t, `'it'"'"'s'`, shellQuote("it's"))
+}
+
+type generatedConfigAdmin struct {
+	*fakeAdmin
+}
+
+func (a *generatedConfigAdmin) GeneratedConfig(ctx context.Context, desired *api.Cluster, registry *api.Registry) (map[string][]byte, error) {
+	return map[string][]byte{"fake-config.txt": []byte(desired.Name + " " + registry.Name)}, nil
+}
@@ -0,0 +1,77 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+type gitInfo struct {
+	commit  string
+	author  string
+	subject string
+}
+
+// AnnotateWithGitInfo records the Git commit checked out in the current
+// directory on the cluster, so that you can tell which commit (e.g., in CI)
+// created it.
+//
+// Stores the commit hash, author email, and subject in the cluster's
+// annotations. Does nothing if git isn't installed, or if the current
+// directory isn't in a Git repo.
+func (c *Controller) AnnotateWithGitInfo(ctx context.Context, clusterName string) error {
+	info, ok := c.readGitInfo(ctx)
+	if !ok {
+		return nil
+	}
+
+	cluster, err := c.Get(ctx, clusterName)
+	if err != nil {
+		return err
+	}
+
+	client, err := c.client(clusterName)
+	if err != nil {
+		return err
+	}
+	spec, err := readClusterSpec(ctx, client)
+	if err != nil {
+		return err
+	}
+	if spec == nil {
+		spec = &api.Cluster{TypeMeta: cluster.TypeMeta, Name: cluster.Name, Product: cluster.Product}
+	}
+	if spec.Annotations == nil {
+		spec.Annotations = make(map[string]string)
+	}
+	spec.Annotations[api.ClusterAnnotationGitCommit] = info.commit
+	spec.Annotations[api.ClusterAnnotationGitAuthor] = info.author
+	spec.Annotations[api.ClusterAnnotationGitSubject] = info.subject
+	return c.writeClusterSpec(ctx, spec)
+}
+
+// Reads the last commit with git log. Returns false if git fails for any reason.
+func (c *Controller) readGitInfo(ctx context.Context) (gitInfo, bool) {
+	out := bytes.NewBuffer(nil)
+	err := c.runner.RunIO(ctx, genericclioptions.IOStreams{Out: out, ErrOut: io.Discard},
+		"git", "log", "-1", "--format=%H,%ae,%s")
+	if err != nil {
+		return gitInfo{}, false
+	}
+	return parseGitInfo(out.String())
+}
+
+// Parses the output of `git log -1 --format=%H,%ae,%s`.
+// The subject may contain commas; the hash and email don't.
+func parseGitInfo(out string) (gitInfo, bool) {
+	parts := strings.SplitN(strings.TrimSpace(out), ",", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return gitInfo{}, false
+	}
+	return gitInfo{commit: parts[0], author: parts[1], subject: parts[2]}, true
+}
@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Fails every command, like a machine without git.
+type failingRunner struct{}
+
+func (failingRunner) Run(ctx context.Context, cmd string, args ...string) error {
+	return fmt.Errorf("exec: %q: executable file not found in $PATH", cmd)
+}
+
+func (r failingRunner) RunIO(ctx context.Context, streams genericclioptions.IOStreams, cmd string, args ...string) error {
+	return r.Run(ctx, cmd, args...)
+}
+
+func TestAnnotateWithGitInfo(t *testing.T) {
+	f := newFixture(t)
+	runner := exec.NewFakeCmdRunner(func(argv []string) string {
+		return "0123abcd,dev@example.com,Fix the build, again\n"
+	})
+	f.controller.runner = runner
+
+	ctx := context.Background()
+	err := f.controller.AnnotateWithGitInfo(ctx, "microk8s")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"git", "log", "-1", "--format=%H,%ae,%s"}, runner.LastArgs)
+
+	cluster, err := f.controller.Get(ctx, "microk8s")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		api.ClusterAnnotationGitCommit:  "0123abcd",
+		api.ClusterAnnotationGitAuthor:  "dev@example.com",
+		api.ClusterAnnotationGitSubject: "Fix the build, again",
+	}, cluster.Annotations)
+}
+
+func TestReapplyKeepsGitInfo(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	f.newFakeAdmin(clusterid.ProductKIND)
+	ctx := context.Background()
+
+	cluster := &api.Cluster{
+		Product:     string(clusterid.ProductKIND),
+		Annotations: map[string]string{"example.com/team": "platform"},
+	}
+	_, err := f.controller.Apply(ctx, cluster)
+	require.NoError(t, err)
+
+	f.controller.runner = exec.NewFakeCmdRunner(func(argv []string) string {
+		return "0123abcd,dev@example.com,Fix the build\n"
+	})
+	err = f.controller.AnnotateWithGitInfo(ctx, "kind-kind")
+	require.NoError(t, err)
+
+	// Changing the config rewrites the stored spec, but keeps the annotations
+	// that ctlptl set. Annotations from the config follow the config.
+	cluster.DefaultNamespace = "dev"
+	cluster.Annotations = nil
+	_, err = f.controller.Apply(ctx, cluster)
+	require.NoError(t, err)
+
+	result, err := f.controller.Get(ctx, "kind-kind")
+	require.NoError(t, err)
+	assert.Equal(t, "dev", result.DefaultNamespace)
+	assert.Equal(t, map[string]string{
+		api.ClusterAnnotationGitCommit:  "0123abcd",
+		api.ClusterAnnotationGitAuthor:  "dev@example.com",
+		api.ClusterAnnotationGitSubject: "Fix the build",
+	}, result.Annotations)
+}
+
+func TestAnnotateWithGitInfoNoGit(t *testing.T) {
+	f := newFixture(t)
+	f.controller.runner = failingRunner{}
+
+	ctx := context.Background()
+	err := f.controller.AnnotateWithGitInfo(ctx, "microk8s")
+	require.NoError(t, err)
+
+	spec, err := readClusterSpec(ctx, f.fakeK8s)
+	require.NoError(t, err)
+	assert.Nil(t, spec)
+}
@@ -0,0 +1,221 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/storage/driver"
+
+	"github.com/tilt-dev/ctlptl/internal/egress"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// How long to wait for the resources of a release to become ready.
+var helmTimeout = 5 * time.Minute
+
+// Installs or upgrades a Helm release in the cluster with the given context.
+//
+// An interface, so that tests don't need a cluster.
+type helmClient interface {
+	applyChart(ctx context.Context, contextName string, chart api.HelmChartSpec, onStatus func(status string)) error
+}
+
+func validateHelmCharts(desired *api.Cluster) error {
+	releases := make(map[string]bool, len(desired.HelmCharts))
+	for _, chart := range desired.HelmCharts {
+		err := validateHelmChart(chart)
+		if err != nil {
+			return err
+		}
+
+		key := chart.Namespace + "/" + helmReleaseName(chart)
+		if releases[key] {
+			return fmt.Errorf("helmCharts: release %s is listed more than once", helmReleaseName(chart))
+		}
+		releases[key] = true
+	}
+	return nil
+}
+
+func validateHelmChart(chart api.HelmChartSpec) error {
+	if chart.Chart == "" {
+		return fmt.Errorf("helmCharts: chart is required")
+	}
+	for _, s := range chart.Set {
+		if !strings.Contains(s, "=") {
+			return fmt.Errorf("helmCharts: set values must be in key=value form. Actual: %s", s)
+		}
+	}
+	return nil
+}
+
+// Charts come from a repo or an OCI registry, unless the chart is a local path.
+func checkHelmChartEgress(chart api.HelmChartSpec) error {
+	operation := fmt.Sprintf("installing chart %s", chart.Chart)
+	if chart.Repo != "" {
+		return egress.CheckURL(operation, chart.Repo)
+	}
+	if strings.Contains(chart.Chart, "://") {
+		return egress.CheckURL(operation, chart.Chart)
+	}
+	if _, err := os.Stat(chart.Chart); err == nil {
+		return nil
+	}
+	// A chart from a repo that was added with `helm repo add`.
+	return egress.Check(operation)
+}
+
+// The release name, defaulting to the last path element of the chart
+// (e.g., ingress-nginx for oci://ghcr.io/nginxinc/charts/ingress-nginx).
+func helmReleaseName(chart api.HelmChartSpec) string {
+	if chart.Name != "" {
+		return chart.Name
+	}
+	name := path.Base(strings.TrimSuffix(chart.Chart, "/"))
+	return strings.TrimSuffix(name, ".tgz")
+}
+
+// OnStatus sets the callback that ApplyHelmChart reports each step of an
+// install to, along with Helm's progress while it waits for the release.
+// By default, progress is printed to stderr.
+func (c *Controller) OnStatus(onStatus func(status string)) {
+	c.onHelmStatus = onStatus
+}
+
+// ApplyHelmChart installs a Helm chart in a cluster, or upgrades its release
+// if it's already installed, and waits for the release to become ready.
+func (c *Controller) ApplyHelmChart(ctx context.Context, clusterName string, chart api.HelmChartSpec) error {
+	err := validateHelmChart(chart)
+	if err != nil {
+		return err
+	}
+
+	err = checkHelmChartEgress(chart)
+	if err != nil {
+		return err
+	}
+
+	onStatus := c.onHelmStatus
+	if onStatus == nil {
+		onStatus = func(status string) {
+			_, _ = fmt.Fprintf(c.iostreams.ErrOut, " ⎈ %s\n", status)
+		}
+	}
+
+	helm := c.helm
+	if helm == nil {
+		helm = sdkHelmClient{}
+	}
+
+	release := helmReleaseName(chart)
+	onStatus(fmt.Sprintf("Installing chart %s as release %s in cluster %s", chart.Chart, release, clusterName))
+	err = helm.applyChart(ctx, c.contextName(clusterName), chart, onStatus)
+	if err != nil {
+		return err
+	}
+	onStatus(fmt.Sprintf("Release %s is ready", release))
+	return nil
+}
+
+// Applies charts with the Helm SDK, like `helm upgrade --install --wait`.
+//
+// Reads repositories and registry credentials from the same places as the
+// helm CLI, so charts from `helm repo add` work.
+type sdkHelmClient struct{}
+
+func (sdkHelmClient) applyChart(ctx context.Context, contextName string, chart api.HelmChartSpec, onStatus func(status string)) error {
+	settings := cli.New()
+	settings.KubeContext = contextName
+	if chart.Namespace != "" {
+		settings.SetNamespace(chart.Namespace)
+	}
+	namespace := settings.Namespace()
+
+	registryClient, err := registry.NewClient(registry.ClientOptCredentialsFile(settings.RegistryConfig))
+	if err != nil {
+		return errors.Wrap(err, "creating helm registry client")
+	}
+
+	cfg := &action.Configuration{RegistryClient: registryClient}
+	err = cfg.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), func(format string, v ...interface{}) {
+		onStatus(fmt.Sprintf(format, v...))
+	})
+	if err != nil {
+		return errors.Wrap(err, "configuring helm")
+	}
+
+	pathOptions := action.ChartPathOptions{RepoURL: chart.Repo, Version: chart.Version}
+	install := action.NewInstall(cfg)
+	install.ChartPathOptions = pathOptions
+	chartPath, err := install.ChartPathOptions.LocateChart(chart.Chart, settings)
+	if err != nil {
+		return errors.Wrapf(err, "locating chart %s", chart.Chart)
+	}
+	ch, err := loader.Load(chartPath)
+	if err != nil {
+		return errors.Wrapf(err, "loading chart %s", chart.Chart)
+	}
+	if deps := ch.Metadata.Dependencies; deps != nil {
+		err = action.CheckDependencies(ch, deps)
+		if err != nil {
+			return errors.Wrapf(err, "chart %s", chart.Chart)
+		}
+	}
+
+	vals, err := helmValues(chart, settings)
+	if err != nil {
+		return err
+	}
+
+	release := helmReleaseName(chart)
+	history := action.NewHistory(cfg)
+	history.Max = 1
+	_, err = history.Run(release)
+	if err == driver.ErrReleaseNotFound {
+		install.ReleaseName = release
+		install.Namespace = namespace
+		install.CreateNamespace = true
+		install.Wait = true
+		install.Timeout = helmTimeout
+		_, err = install.RunWithContext(ctx, ch, vals)
+		if err != nil {
+			return errors.Wrapf(err, "installing release %s", release)
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "reading history of release %s", release)
+	}
+
+	upgrade := action.NewUpgrade(cfg)
+	upgrade.ChartPathOptions = pathOptions
+	upgrade.Namespace = namespace
+	upgrade.Wait = true
+	upgrade.Timeout = helmTimeout
+	_, err = upgrade.RunWithContext(ctx, release, ch, vals)
+	if err != nil {
+		return errors.Wrapf(err, "upgrading release %s", release)
+	}
+	return nil
+}
+
+// Merges the chart's values files and --set values, in that order.
+func helmValues(chart api.HelmChartSpec, settings *cli.EnvSettings) (map[string]interface{}, error) {
+	opts := values.Options{ValueFiles: chart.ValuesFiles, Values: chart.Set}
+	vals, err := opts.MergeValues(getter.All(settings))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading values of chart %s", chart.Chart)
+	}
+	return vals, nil
+}
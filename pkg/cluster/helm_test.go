@@ -0,0 +1,168 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"helm.sh/helm/v3/pkg/cli"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Records each chart it applies as "CONTEXT NAMESPACE/RELEASE REPO CHART [set...]".
+type fakeHelmClient struct {
+	applied []string
+}
+
+func (h *fakeHelmClient) applyChart(ctx context.Context, contextName string, chart api.HelmChartSpec, onStatus func(status string)) error {
+	onStatus("waiting for release")
+	entry := fmt.Sprintf("%s %s/%s %s %s", contextName, chart.Namespace, helmReleaseName(chart), chart.Repo, chart.Chart)
+	for _, s := range chart.Set {
+		entry += " " + s
+	}
+	h.applied = append(h.applied, entry)
+	return nil
+}
+
+func TestApplyHelmChart(t *testing.T) {
+	f := newFixture(t)
+	statuses := []string{}
+	f.controller.OnStatus(func(status string) {
+		statuses = append(statuses, status)
+	})
+
+	err := f.controller.ApplyHelmChart(context.Background(), "kind-kind", api.HelmChartSpec{
+		Chart:     "ingress-nginx",
+		Repo:      "https://kubernetes.github.io/ingress-nginx",
+		Version:   "4.7.1",
+		Namespace: "ingress-nginx",
+		Set:       []string{"controller.service.type=NodePort"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"kind-kind ingress-nginx/ingress-nginx https://kubernetes.github.io/ingress-nginx ingress-nginx " +
+			"controller.service.type=NodePort",
+	}, f.helm.applied)
+	assert.Equal(t, []string{
+		"Installing chart ingress-nginx as release ingress-nginx in cluster kind-kind",
+		"waiting for release",
+		"Release ingress-nginx is ready",
+	}, statuses)
+	assert.Empty(t, f.errOut.String())
+}
+
+func TestApplyHelmChartDefaultsReleaseName(t *testing.T) {
+	f := newFixture(t)
+
+	err := f.controller.ApplyHelmChart(context.Background(), "kind-kind", api.HelmChartSpec{
+		Chart: "oci://registry-1.docker.io/bitnamicharts/redis",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"kind-kind /redis  oci://registry-1.docker.io/bitnamicharts/redis",
+	}, f.helm.applied)
+	assert.Contains(t, f.errOut.String(), " ⎈ Release redis is ready")
+}
+
+func TestApplyHelmChartContextPrefix(t *testing.T) {
+	f := newFixture(t)
+	f.controller.contextPrefix = "ci-42-"
+
+	err := f.controller.ApplyHelmChart(context.Background(), "kind-kind", api.HelmChartSpec{
+		Chart: "./charts/app",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ci-42-kind-kind /app  ./charts/app"}, f.helm.applied)
+	assert.Contains(t, f.errOut.String(), "in cluster kind-kind")
+}
+
+func TestClusterApplyHelmCharts(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	kindAdmin := f.newFakeAdmin(clusterid.ProductKIND)
+
+	cluster := &api.Cluster{
+		Product:    string(clusterid.ProductKIND),
+		HelmCharts: []api.HelmChartSpec{{Chart: "./charts/app"}},
+	}
+	_, err := f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	assert.Equal(t, []string{"kind-kind /app  ./charts/app"}, f.helm.applied)
+
+	c, err := f.controller.Get(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.Equal(t, []api.HelmChartSpec{{Chart: "./charts/app"}}, c.HelmCharts)
+
+	// Applying the same charts again does nothing.
+	f.helm.applied = nil
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Empty(t, f.helm.applied)
+
+	// Changing the charts upgrades them without re-creating the cluster.
+	kindAdmin.created = nil
+	cluster.HelmCharts[0].Set = []string{"replicas=2"}
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Nil(t, kindAdmin.created)
+	assert.Equal(t, []string{"kind-kind /app  ./charts/app replicas=2"}, f.helm.applied)
+}
+
+func TestHelmValues(t *testing.T) {
+	valuesFile := filepath.Join(t.TempDir(), "values.yaml")
+	require.NoError(t, os.WriteFile(valuesFile, []byte("replicas: 1\nimage:\n  tag: v1\n"), 0644))
+
+	vals, err := helmValues(api.HelmChartSpec{
+		Chart:       "./charts/app",
+		ValuesFiles: []string{valuesFile},
+		Set:         []string{"replicas=3", "image.pullPolicy=Never"},
+	}, cli.New())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"replicas": int64(3),
+		"image":    map[string]interface{}{"tag": "v1", "pullPolicy": "Never"},
+	}, vals)
+
+	_, err = helmValues(api.HelmChartSpec{
+		Chart:       "./charts/app",
+		ValuesFiles: []string{filepath.Join(t.TempDir(), "missing.yaml")},
+	}, cli.New())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "reading values of chart ./charts/app")
+	}
+}
+
+func TestValidateHelmCharts(t *testing.T) {
+	err := validateHelmCharts(&api.Cluster{
+		HelmCharts: []api.HelmChartSpec{{Chart: "redis"}, {Chart: "redis", Namespace: "other"}},
+	})
+	assert.NoError(t, err)
+
+	err = validateHelmCharts(&api.Cluster{
+		HelmCharts: []api.HelmChartSpec{{Name: "redis"}},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "helmCharts: chart is required")
+	}
+
+	err = validateHelmCharts(&api.Cluster{
+		HelmCharts: []api.HelmChartSpec{{Chart: "redis", Set: []string{"replicas"}}},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "set values must be in key=value form. Actual: replicas")
+	}
+
+	err = validateHelmCharts(&api.Cluster{
+		HelmCharts: []api.HelmChartSpec{{Chart: "bitnami/redis"}, {Chart: "./redis"}},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "release redis is listed more than once")
+	}
+}
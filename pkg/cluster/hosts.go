@@ -0,0 +1,42 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tilt-dev/ctlptl/internal/hostsfile"
+)
+
+// Ingresses on local clusters are served on the host's loopback interface.
+const hostsIP = "127.0.0.1"
+
+func validateHosts(hosts []string) error {
+	err := hostsfile.ValidateHostnames(hosts)
+	if err != nil {
+		return fmt.Errorf("hosts: %v", err)
+	}
+	return nil
+}
+
+// Replaces the cluster's block of /etc/hosts. Removes the block if hosts is empty.
+//
+// If we don't have permission to write /etc/hosts, prints the
+// lines to add instead of failing.
+func (c *Controller) updateHosts(clusterName string, hosts []string) error {
+	var entries []hostsfile.Entry
+	if len(hosts) > 0 {
+		entries = []hostsfile.Entry{{IP: hostsIP, Hostnames: hosts}}
+	}
+
+	err := c.hostsFile.Set("cluster "+clusterName, entries)
+	var permErr *hostsfile.PermissionError
+	if errors.As(err, &permErr) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, " ⚠️ Couldn't update %s for cluster %s: permission denied. %s",
+			permErr.Path, clusterName, permErr.Hint())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("updating hosts for cluster %s: %v", clusterName, err)
+	}
+	return nil
+}
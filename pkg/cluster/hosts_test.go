@@ -0,0 +1,64 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestClusterApplyHosts(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	kindAdmin := f.newFakeAdmin(clusterid.ProductKIND)
+	path := f.controller.hostsFile.Path
+
+	cluster := &api.Cluster{
+		Product: string(clusterid.ProductKIND),
+		Hosts:   []string{"app.kind.local"},
+	}
+	_, err := f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "# BEGIN ctlptl cluster kind-kind\n127.0.0.1 app.kind.local\n# END ctlptl cluster kind-kind\n", string(data))
+
+	c, err := f.controller.Get(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app.kind.local"}, c.Hosts)
+
+	// Changing the hosts updates them without re-creating the cluster.
+	kindAdmin.created = nil
+	cluster.Hosts = []string{"app.kind.local", "api.kind.local"}
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Nil(t, kindAdmin.created)
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "# BEGIN ctlptl cluster kind-kind\n127.0.0.1 api.kind.local app.kind.local\n# END ctlptl cluster kind-kind\n", string(data))
+
+	err = f.controller.Delete(context.Background(), "kind-kind")
+	require.NoError(t, err)
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "", string(data))
+}
+
+func TestClusterApplyHostsInvalid(t *testing.T) {
+	f := newFixture(t)
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product: string(clusterid.ProductKIND),
+		Hosts:   []string{"*.kind.local"},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "hosts: *.kind.local: hosts files don't support wildcards")
+	}
+}
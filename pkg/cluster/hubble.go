@@ -0,0 +1,95 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// The Cilium chart values that enable Hubble relay and the Hubble UI.
+var hubbleValues = []string{"hubble.relay.enabled=true", "hubble.ui.enabled=true"}
+
+// The Deployment that the Cilium chart creates for Hubble relay.
+const hubbleRelayDeployment = "hubble-relay"
+
+// How long to wait for Hubble relay once the Cilium chart is installed.
+var hubbleRelayTimeout = 5 * time.Minute
+
+func validateHubble(desired *api.Cluster) error {
+	if desired.CNI != cniCilium {
+		return fmt.Errorf("hubbleEnabled requires Cilium as the CNI. Set cni: %s", cniCilium)
+	}
+	return nil
+}
+
+// Waits for the Hubble relay pods to be ready.
+func (c *Controller) waitForHubbleRelay(ctx context.Context, cluster *api.Cluster) error {
+	client, err := c.client(cluster.Name)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, "   Waiting for Hubble relay in cluster %s\n", cluster.Name)
+	check := api.ReadinessCheck{Kind: "deployment", Name: hubbleRelayDeployment, Namespace: ciliumChart.Namespace}
+	var lastReason string
+	err = wait.PollImmediate(time.Second, hubbleRelayTimeout, func() (bool, error) {
+		reason, err := workloadNotReadyReason(ctx, client, check)
+		if err != nil {
+			lastReason = err.Error()
+			return false, nil
+		}
+		lastReason = reason
+		return reason == "", nil
+	})
+	if err != nil {
+		return fmt.Errorf("timed out waiting for hubble relay: %s", lastReason)
+	}
+	return nil
+}
+
+// Hubble forwards Hubble relay to localhost, so that the hubble CLI can
+// observe flows in the cluster, and opens the Hubble UI in the browser.
+//
+// Runs until the UI port-forward stops. Requires the cilium CLI.
+func (c *Controller) Hubble(ctx context.Context, clusterName string) error {
+	cluster, err := c.Get(ctx, clusterName)
+	if err != nil {
+		return err
+	}
+	if !cluster.HubbleEnabled {
+		return fmt.Errorf("cluster %s doesn't have Hubble enabled. Set hubbleEnabled: true and apply it", clusterName)
+	}
+	contextName := c.contextName(clusterName)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	streams := genericclioptions.IOStreams{Out: c.iostreams.ErrOut, ErrOut: c.iostreams.ErrOut}
+	forwardDone := make(chan error, 1)
+	go func() {
+		forwardDone <- c.runner.RunIO(ctx, streams, "cilium", "hubble", "port-forward", "--context", contextName)
+	}()
+
+	err = c.runner.RunIO(ctx, streams, "cilium", "hubble", "ui", "--context", contextName)
+
+	// Only report port-forward errors if it stopped on its own.
+	var forwardErr error
+	select {
+	case forwardErr = <-forwardDone:
+	default:
+		cancel()
+		<-forwardDone
+	}
+	if err != nil {
+		return fmt.Errorf("opening the hubble UI (requires the cilium CLI: https://github.com/cilium/cilium-cli): %v", err)
+	}
+	if forwardErr != nil {
+		return fmt.Errorf("forwarding hubble relay: %v", forwardErr)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package cluster
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func hubbleCluster() *api.Cluster {
+	return &api.Cluster{
+		Product:       string(clusterid.ProductKIND),
+		CNI:           cniCilium,
+		HubbleEnabled: true,
+	}
+}
+
+func (f *fixture) createHubbleRelay(ready bool) {
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: hubbleRelayDeployment, Namespace: "kube-system"},
+	}
+	if ready {
+		d.Status = appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+	}
+	_, err := f.fakeK8s.AppsV1().Deployments("kube-system").Create(context.Background(), d, metav1.CreateOptions{})
+	require.NoError(f.t, err)
+}
+
+func TestClusterApplyHubble(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	f.newFakeAdmin(clusterid.ProductKIND)
+	f.createHubbleRelay(true)
+
+	cluster := hubbleCluster()
+	_, err := f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"kind-kind kube-system/cilium https://helm.cilium.io cilium ipam.mode=kubernetes " +
+			"hubble.relay.enabled=true hubble.ui.enabled=true",
+	}, f.helm.applied)
+	assert.Contains(t, f.errOut.String(), "Waiting for Hubble relay in cluster kind-kind")
+
+	c, err := f.controller.Get(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.True(t, c.HubbleEnabled)
+
+	// Applying again does nothing.
+	f.helm.applied = nil
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Empty(t, f.helm.applied)
+
+	// Disabling Hubble upgrades Cilium without the Hubble values.
+	cluster.HubbleEnabled = false
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"kind-kind kube-system/cilium https://helm.cilium.io cilium ipam.mode=kubernetes",
+	}, f.helm.applied)
+	c, err = f.controller.Get(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.False(t, c.HubbleEnabled)
+}
+
+func TestClusterApplyHubbleRelayNotReady(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	f.newFakeAdmin(clusterid.ProductKIND)
+	f.createHubbleRelay(false)
+
+	oldTimeout := hubbleRelayTimeout
+	hubbleRelayTimeout = time.Millisecond
+	defer func() { hubbleRelayTimeout = oldTimeout }()
+
+	_, err := f.controller.Apply(context.Background(), hubbleCluster())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "installing cilium: enabling hubble: timed out waiting for hubble relay: 0 of 1 pods updated")
+	}
+}
+
+func TestValidateHubble(t *testing.T) {
+	assert.NoError(t, validateHubble(hubbleCluster()))
+
+	err := validateHubble(&api.Cluster{
+		Product:       string(clusterid.ProductKIND),
+		HelmCharts:    []api.HelmChartSpec{{Chart: "cilium", Repo: "https://helm.cilium.io"}},
+		HubbleEnabled: true,
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "hubbleEnabled requires Cilium as the CNI. Set cni: cilium")
+	}
+}
+
+func TestValidateCNI(t *testing.T) {
+	assert.NoError(t, validateCNI(hubbleCluster()))
+
+	err := validateCNI(&api.Cluster{Product: string(clusterid.ProductKIND), CNI: "flannel"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cni must be one of: cilium. Actual: flannel")
+	}
+
+	err = validateCNI(&api.Cluster{Product: string(clusterid.ProductK3D), CNI: cniCilium})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cni may only be set on clusters with product: kind")
+	}
+
+	err = validateCNI(&api.Cluster{Product: string(clusterid.ProductKIND), CNI: cniCilium, NetworkCalico: &api.CalicoSpec{}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cni: cilium can't be combined with networkCalico")
+	}
+}
+
+// Blocks port-forwards until they're cancelled, like the real cilium CLI.
+type hubbleRunner struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *hubbleRunner) Run(ctx context.Context, cmd string, args ...string) error {
+	return r.RunIO(ctx, genericclioptions.IOStreams{}, cmd, args...)
+}
+
+func (r *hubbleRunner) RunIO(ctx context.Context, streams genericclioptions.IOStreams, cmd string, args ...string) error {
+	call := strings.Join(append([]string{cmd}, args...), " ")
+	r.mu.Lock()
+	r.calls = append(r.calls, call)
+	r.mu.Unlock()
+	if strings.Contains(call, "port-forward") {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func TestHubble(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	f.newFakeAdmin(clusterid.ProductKIND)
+	f.createHubbleRelay(true)
+	f.controller.contextPrefix = "ci-42-"
+	_, err := f.controller.Apply(context.Background(), hubbleCluster())
+	require.NoError(t, err)
+
+	runner := &hubbleRunner{}
+	f.controller.runner = runner
+	err = f.controller.Hubble(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"cilium hubble port-forward --context ci-42-kind-kind",
+		"cilium hubble ui --context ci-42-kind-kind",
+	}, runner.calls)
+}
+
+func TestHubbleNotEnabled(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	f.newFakeAdmin(clusterid.ProductKIND)
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{Product: string(clusterid.ProductKIND)})
+	require.NoError(t, err)
+
+	err = f.controller.Hubble(context.Background(), "kind-kind")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cluster kind-kind doesn't have Hubble enabled")
+	}
+}
@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// Waiting reasons that mean the kubelet couldn't pull the image.
+var imagePullFailures = map[string]bool{
+	"ErrImagePull":        true,
+	"ImagePullBackOff":    true,
+	"InvalidImageName":    true,
+	"ErrImageNeverPull":   true,
+	"RegistryUnavailable": true,
+}
+
+// CheckImagePull starts a short-lived pod that runs the image, to check
+// that the cluster's container runtime can pull it.
+//
+// The pod only has to get as far as pulling the image. Images with nothing
+// to run (like the one that the registry controller's PushTestImage pushes)
+// fail to start after they're pulled, and that counts as a success.
+//
+// The pod is deleted when the check is done.
+func (c *Controller) CheckImagePull(ctx context.Context, clusterName, image string, timeout time.Duration) error {
+	client, err := c.client(clusterName)
+	if err != nil {
+		return err
+	}
+
+	pod, err := client.CoreV1().Pods("default").Create(ctx, &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "ctlptl-image-pull-",
+			Labels:       map[string]string{"app.kubernetes.io/managed-by": "ctlptl"},
+		},
+		Spec: v1.PodSpec{
+			RestartPolicy: v1.RestartPolicyNever,
+			Containers: []v1.Container{
+				{
+					Name:            "pull",
+					Image:           image,
+					ImagePullPolicy: v1.PullAlways,
+					Command:         []string{"/ctlptl-image-pull"},
+				},
+			},
+			Tolerations: []v1.Toleration{{Operator: v1.TolerationOpExists}},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "cluster %s: creating pod", clusterName)
+	}
+	defer func() {
+		// Use a fresh context, so that we clean up even if ctx was canceled.
+		err := client.CoreV1().Pods(pod.Namespace).Delete(context.Background(), pod.Name, metav1.DeleteOptions{})
+		if err != nil {
+			klog.V(4).Infof("WARNING: deleting pod %s/%s: %v\n", pod.Namespace, pod.Name, err)
+		}
+	}()
+
+	var pullErr error
+	err = wait.PollImmediate(500*time.Millisecond, timeout, func() (bool, error) {
+		current, err := client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		pulled, err := imagePulled(current)
+		if err != nil {
+			pullErr = err
+			return true, nil
+		}
+		return pulled, nil
+	})
+	if err != nil {
+		if err == wait.ErrWaitTimeout {
+			return fmt.Errorf("cluster %s: timed out after %s waiting to pull %s",
+				clusterName, duration.ShortHumanDuration(timeout), image)
+		}
+		return errors.Wrapf(err, "cluster %s", clusterName)
+	}
+	if pullErr != nil {
+		return fmt.Errorf("cluster %s: %v", clusterName, pullErr)
+	}
+	return nil
+}
+
+// Checks whether the pod's image has been pulled, or failed to pull.
+func imagePulled(pod *v1.Pod) (bool, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running != nil || status.State.Terminated != nil {
+			return true, nil
+		}
+		waiting := status.State.Waiting
+		if waiting == nil || waiting.Reason == "" || waiting.Reason == "ContainerCreating" {
+			continue
+		}
+		if imagePullFailures[waiting.Reason] {
+			return false, fmt.Errorf("pulling %s: %s: %s", status.Image, waiting.Reason, waiting.Message)
+		}
+
+		// Any other reason (e.g., CreateContainerError, because the image
+		// has nothing to run) comes after the pull.
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,148 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/internal/egress"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Kyverno runs the mutating webhook that applies the pull policy.
+var kyvernoVersion = "1.10.3"
+
+var kyvernoManifestURL = "https://github.com/kyverno/kyverno/releases/download/v%s/install.yaml"
+
+const imagePullPolicyName = "ctlptl-default-image-pull-policy"
+
+// Records the pull policy on the Kyverno policy itself, so that we can
+// report what's actually installed.
+const imagePullPolicyAnnotation = "ctlptl.dev/image-pull-policy"
+
+// A Kyverno policy that overrides the pull policy of every container in new pods.
+//
+// The apiserver fills in a default pull policy before admission webhooks run,
+// so the policy can't tell an explicit Always from a defaulted one. It always overrides.
+const imagePullPolicyTemplate = `apiVersion: kyverno.io/v1
+kind: ClusterPolicy
+metadata:
+  name: %[1]s
+  annotations:
+    %[2]s: %[3]s
+spec:
+  background: false
+  rules:
+  - name: set-image-pull-policy
+    match:
+      any:
+      - resources:
+          kinds:
+          - Pod
+    exclude:
+      any:
+      - resources:
+          namespaces:
+          - kube-system
+          - kyverno
+    mutate:
+      patchStrategicMerge:
+        spec:
+          containers:
+          - (name): "?*"
+            imagePullPolicy: %[3]s
+`
+
+func validateDefaultImagePullPolicy(desired *api.Cluster) error {
+	switch desired.DefaultImagePullPolicy {
+	case "IfNotPresent", "Never", "Always":
+		return nil
+	}
+	return fmt.Errorf("defaultImagePullPolicy must be one of: IfNotPresent, Never, Always. Actual: %s", desired.DefaultImagePullPolicy)
+}
+
+// Installs the pull policy, or removes it if the cluster no longer wants one.
+func (c *Controller) ensureDefaultImagePullPolicy(ctx context.Context, cluster *api.Cluster) error {
+	policy := cluster.DefaultImagePullPolicy
+	if policy == "" {
+		_, err := c.kubectl(ctx, cluster.Name, nil, "delete", "clusterpolicy", imagePullPolicyName, "--ignore-not-found")
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔧 Removed default imagePullPolicy from cluster %s\n", cluster.Name)
+		return nil
+	}
+
+	err := c.ensureKyverno(ctx, cluster, "set the default imagePullPolicy")
+	if err != nil {
+		return err
+	}
+
+	manifest := fmt.Sprintf(imagePullPolicyTemplate, imagePullPolicyName, imagePullPolicyAnnotation, policy)
+	_, err = c.kubectl(ctx, cluster.Name, strings.NewReader(manifest), "apply", "-f", "-")
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔧 Set default imagePullPolicy %s on cluster %s\n", policy, cluster.Name)
+	return nil
+}
+
+// Installs Kyverno, unless it's already installed. The purpose says what
+// it's for, like "set the default imagePullPolicy".
+func (c *Controller) ensureKyverno(ctx context.Context, cluster *api.Cluster, purpose string) error {
+	_, err := c.kubectl(ctx, cluster.Name, nil, "get", "crd", "clusterpolicies.kyverno.io")
+	if err == nil {
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔧 Installing Kyverno v%s to %s\n", kyvernoVersion, purpose)
+
+	// Kyverno's CRDs are too big for client-side apply.
+	_, err = c.kubectl(ctx, cluster.Name, nil, "create", "-f", fmt.Sprintf(kyvernoManifestURL, kyvernoVersion))
+	if err != nil {
+		return err
+	}
+	_, err = c.kubectl(ctx, cluster.Name, nil,
+		"rollout", "status", "-n", "kyverno", "deployment/kyverno-admission-controller", "--timeout=3m")
+	return err
+}
+
+// Reports the pull policy that's actually installed.
+func (c *Controller) populateImagePullPolicyStatus(ctx context.Context, cluster *api.Cluster) error {
+	jsonPath := fmt.Sprintf("{.metadata.annotations.%s}", strings.ReplaceAll(imagePullPolicyAnnotation, ".", `\.`))
+	out, err := c.kubectl(ctx, cluster.Name, nil,
+		"get", "clusterpolicy", imagePullPolicyName, "--ignore-not-found", "-o", "jsonpath="+jsonPath)
+	if err != nil {
+		return err
+	}
+	cluster.Status.DefaultImagePullPolicy = strings.TrimSpace(out)
+	return nil
+}
+
+// Runs kubectl against the cluster's context, which has the context prefix.
+func (c *Controller) kubectl(ctx context.Context, clusterName string, stdin *strings.Reader, args ...string) (string, error) {
+	out := bytes.NewBuffer(nil)
+	errOut := bytes.NewBuffer(nil)
+	streams := genericclioptions.IOStreams{Out: out, ErrOut: errOut}
+	if stdin != nil {
+		streams.In = stdin
+	}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+			err := egress.CheckURL(fmt.Sprintf("fetching %s", arg), arg)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	args = append([]string{"--context", c.contextName(clusterName)}, args...)
+	err := c.runner.RunIO(ctx, streams, "kubectl", args...)
+	if err != nil {
+		return "", fmt.Errorf("kubectl %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(errOut.String()))
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,154 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Records kubectl commands and their stdin.
+type kubectlRunner struct {
+	calls          []string
+	stdin          []string
+	kyvernoMissing bool
+	installed      string
+	lbPool         string
+	nodeHosts      string
+}
+
+func (r *kubectlRunner) Run(ctx context.Context, cmd string, args ...string) error {
+	return r.RunIO(ctx, genericclioptions.IOStreams{Out: io.Discard, ErrOut: io.Discard}, cmd, args...)
+}
+
+func (r *kubectlRunner) RunIO(ctx context.Context, streams genericclioptions.IOStreams, cmd string, args ...string) error {
+	call := strings.Join(append([]string{cmd}, args...), " ")
+	r.calls = append(r.calls, call)
+	if streams.In != nil {
+		data, err := io.ReadAll(streams.In)
+		if err != nil {
+			return err
+		}
+		r.stdin = append(r.stdin, string(data))
+	}
+
+	switch {
+	case strings.Contains(call, "get crd clusterpolicies.kyverno.io") && r.kyvernoMissing:
+		_, _ = fmt.Fprintln(streams.ErrOut, `Error from server (NotFound): customresourcedefinitions.apiextensions.k8s.io "clusterpolicies.kyverno.io" not found`)
+		return fmt.Errorf("exit status 1")
+	case strings.Contains(call, "ipaddresspool.metallb.io/"+metallbPoolName):
+		_, _ = fmt.Fprint(streams.Out, r.lbPool)
+	case strings.Contains(call, "get clusterpolicy"):
+		_, _ = fmt.Fprint(streams.Out, r.installed)
+	case strings.HasSuffix(call, " cat /etc/hosts"):
+		_, _ = fmt.Fprint(streams.Out, r.nodeHosts)
+	case strings.HasPrefix(call, "mirrord operator setup"):
+		_, _ = fmt.Fprint(streams.Out, fakeMirrordManifest)
+	}
+	return nil
+}
+
+func (r *kubectlRunner) callsMatching(s string) []string {
+	result := []string{}
+	for _, call := range r.calls {
+		if strings.Contains(call, s) {
+			result = append(result, call)
+		}
+	}
+	return result
+}
+
+func TestClusterApplyDefaultImagePullPolicy(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	kindAdmin := f.newFakeAdmin(clusterid.ProductKIND)
+	runner := &kubectlRunner{kyvernoMissing: true}
+	f.controller.runner = runner
+
+	cluster := &api.Cluster{
+		Product:                string(clusterid.ProductKIND),
+		DefaultImagePullPolicy: "IfNotPresent",
+	}
+	_, err := f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+
+	assert.Equal(t, []string{
+		"kubectl --context kind-kind get crd clusterpolicies.kyverno.io",
+		"kubectl --context kind-kind create -f https://github.com/kyverno/kyverno/releases/download/v1.10.3/install.yaml",
+		"kubectl --context kind-kind rollout status -n kyverno deployment/kyverno-admission-controller --timeout=3m",
+		"kubectl --context kind-kind apply -f -",
+	}, runner.callsMatching("kubectl --context kind-kind ")[:4])
+	require.Len(t, runner.stdin, 1)
+	assert.Contains(t, runner.stdin[0], "name: "+imagePullPolicyName)
+	assert.Contains(t, runner.stdin[0], "imagePullPolicy: IfNotPresent")
+	assert.Contains(t, f.errOut.String(), "Set default imagePullPolicy IfNotPresent on cluster kind-kind")
+
+	// Status reports the installed policy.
+	runner.installed = "IfNotPresent"
+	c, err := f.controller.Get(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.Equal(t, "IfNotPresent", c.DefaultImagePullPolicy)
+	assert.Equal(t, "IfNotPresent", c.Status.DefaultImagePullPolicy)
+
+	// Changing the policy doesn't re-create the cluster or re-install Kyverno.
+	kindAdmin.created = nil
+	runner.calls = nil
+	runner.kyvernoMissing = false
+	cluster.DefaultImagePullPolicy = "Never"
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Nil(t, kindAdmin.created)
+	assert.Empty(t, runner.callsMatching("create -f"))
+	assert.Len(t, runner.callsMatching("apply -f -"), 1)
+	assert.Contains(t, runner.stdin[1], "imagePullPolicy: Never")
+
+	// Removing the policy deletes it.
+	runner.calls = nil
+	cluster.DefaultImagePullPolicy = ""
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"kubectl --context kind-kind delete clusterpolicy " + imagePullPolicyName + " --ignore-not-found",
+	}, runner.callsMatching("delete clusterpolicy"))
+}
+
+func TestClusterApplyDefaultImagePullPolicyContextPrefix(t *testing.T) {
+	f := newFixture(t)
+	f.controller.contextPrefix = "ci-42-"
+	_ = f.newFakeAdmin(clusterid.ProductKIND)
+	runner := &kubectlRunner{kyvernoMissing: true}
+	f.controller.runner = runner
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:                string(clusterid.ProductKIND),
+		DefaultImagePullPolicy: "IfNotPresent",
+	})
+	require.NoError(t, err)
+
+	// kubectl targets the prefixed context, never the bare cluster name,
+	// which may belong to someone else's cluster.
+	require.NotEmpty(t, runner.callsMatching("kubectl "))
+	assert.Empty(t, runner.callsMatching("--context kind-kind "))
+	assert.Equal(t, runner.callsMatching("kubectl "), runner.callsMatching("kubectl --context ci-42-kind-kind "))
+}
+
+func TestClusterApplyDefaultImagePullPolicyInvalid(t *testing.T) {
+	f := newFixture(t)
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:                string(clusterid.ProductKIND),
+		DefaultImagePullPolicy: "Sometimes",
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "defaultImagePullPolicy must be one of: IfNotPresent, Never, Always. Actual: Sometimes")
+	}
+}
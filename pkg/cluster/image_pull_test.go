@@ -0,0 +1,69 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func (f *fixture) setPullPodState(state v1.ContainerState) {
+	f.fakeK8s.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &v1.Pod{
+			Status: v1.PodStatus{
+				ContainerStatuses: []v1.ContainerStatus{{Name: "pull", Image: "localhost:5000/test", State: state}},
+			},
+		}, nil
+	})
+}
+
+func TestCheckImagePull(t *testing.T) {
+	f := newFixture(t)
+	f.setPullPodState(v1.ContainerState{
+		Waiting: &v1.ContainerStateWaiting{Reason: "CreateContainerError", Message: "no command specified"},
+	})
+
+	err := f.controller.CheckImagePull(context.Background(), "microk8s", "localhost:5000/test", time.Second)
+	require.NoError(t, err)
+
+	var created *v1.Pod
+	for _, action := range f.fakeK8s.Actions() {
+		if create, ok := action.(k8stesting.CreateAction); ok && action.GetResource().Resource == "pods" {
+			created = create.GetObject().(*v1.Pod)
+		}
+	}
+	require.NotNil(t, created)
+	assert.Equal(t, "localhost:5000/test", created.Spec.Containers[0].Image)
+	assert.Equal(t, v1.PullAlways, created.Spec.Containers[0].ImagePullPolicy)
+}
+
+func TestCheckImagePullFailure(t *testing.T) {
+	f := newFixture(t)
+	f.setPullPodState(v1.ContainerState{
+		Waiting: &v1.ContainerStateWaiting{
+			Reason:  "ErrImagePull",
+			Message: "http: server gave HTTP response to HTTPS client",
+		},
+	})
+
+	err := f.controller.CheckImagePull(context.Background(), "microk8s", "localhost:5000/test", time.Second)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(),
+			"cluster microk8s: pulling localhost:5000/test: ErrImagePull: http: server gave HTTP response to HTTPS client")
+	}
+}
+
+func TestCheckImagePullTimeout(t *testing.T) {
+	f := newFixture(t)
+	f.setPullPodState(v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ContainerCreating"}})
+
+	err := f.controller.CheckImagePull(context.Background(), "microk8s", "localhost:5000/test", time.Second)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cluster microk8s: timed out after 1s waiting to pull localhost:5000/test")
+	}
+}
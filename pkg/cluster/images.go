@@ -0,0 +1,182 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/pkg/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// NodeImages lists the images in a node's container runtime,
+// where the kubelet looks for them.
+type NodeImages struct {
+	Node   string      `json:"node"`
+	Images []NodeImage `json:"images"`
+}
+
+// NodeImage is an image in a node's container runtime.
+type NodeImage struct {
+	ID          string   `json:"id"`
+	RepoTags    []string `json:"repoTags,omitempty"`
+	RepoDigests []string `json:"repoDigests,omitempty"`
+	Size        int64    `json:"size"`
+}
+
+// The subset of `crictl images -o json` output that we read.
+type crictlImages struct {
+	Images []struct {
+		ID          string   `json:"id"`
+		RepoTags    []string `json:"repoTags"`
+		RepoDigests []string `json:"repoDigests"`
+
+		// crictl prints the size as a string.
+		Size string `json:"size"`
+	} `json:"images"`
+}
+
+// ListNodeImages lists the images in the container runtime of each node
+// of the named cluster, by running crictl in the node containers.
+//
+// Only supported on clusters whose nodes are Docker containers (kind and k3d).
+func (c *Controller) ListNodeImages(ctx context.Context, clusterName string) ([]NodeImages, error) {
+	containers, err := c.nodeContainers(ctx, clusterName, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("cluster %s: no node containers found", clusterName)
+	}
+
+	result := make([]NodeImages, 0, len(containers))
+	for _, container := range containers {
+		node := containerName(container)
+		out := bytes.NewBuffer(nil)
+		errOut := bytes.NewBuffer(nil)
+		err := c.runner.RunIO(ctx, genericclioptions.IOStreams{Out: out, ErrOut: errOut},
+			"docker", "exec", container.ID, "crictl", "images", "-o", "json")
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing images on node %s: %s", node, strings.TrimSpace(errOut.String()))
+		}
+
+		images, err := parseCrictlImages(out.Bytes())
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing images on node %s", node)
+		}
+		result = append(result, NodeImages{Node: node, Images: images})
+	}
+	return result, nil
+}
+
+// GetAvailableImages lists the images already cached on the nodes of the named
+// cluster, by running ctr in the node containers. Returns the sorted union of
+// the image references on every node, like docker.io/library/nginx:1.25.
+//
+// Images that are only known by ID are left out. An image may be on some
+// nodes and not others. Use ListNodeImages to see which.
+//
+// Only supported on clusters whose nodes are Docker containers (kind and k3d).
+func (c *Controller) GetAvailableImages(ctx context.Context, clusterName string) ([]string, error) {
+	containers, err := c.nodeContainers(ctx, clusterName, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("cluster %s: no node containers found", clusterName)
+	}
+
+	seen := make(map[string]bool)
+	for _, container := range containers {
+		node := containerName(container)
+		out := bytes.NewBuffer(nil)
+		errOut := bytes.NewBuffer(nil)
+
+		// The kubelet's images are in containerd's k8s.io namespace.
+		err := c.runner.RunIO(ctx, genericclioptions.IOStreams{Out: out, ErrOut: errOut},
+			"docker", "exec", container.ID, "ctr", "--namespace", "k8s.io", "images", "ls", "-q")
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing images on node %s: %s", node, strings.TrimSpace(errOut.String()))
+		}
+		for _, ref := range parseCtrImages(out.String()) {
+			seen[ref] = true
+		}
+	}
+
+	result := make([]string, 0, len(seen))
+	for ref := range seen {
+		result = append(result, ref)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// Parses `ctr images ls -q`, which prints one reference per line. Containerd
+// also names each image by its ID (sha256:...), which we skip.
+func parseCtrImages(out string) []string {
+	result := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		ref := strings.TrimSpace(line)
+		if ref == "" || strings.HasPrefix(ref, "sha256:") {
+			continue
+		}
+		result = append(result, ref)
+	}
+	return result
+}
+
+func parseCrictlImages(data []byte) ([]NodeImage, error) {
+	var parsed crictlImages
+	err := json.Unmarshal(data, &parsed)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing crictl images")
+	}
+
+	images := make([]NodeImage, 0, len(parsed.Images))
+	for _, image := range parsed.Images {
+		size, _ := strconv.ParseInt(image.Size, 10, 64)
+		images = append(images, NodeImage{
+			ID:          image.ID,
+			RepoTags:    image.RepoTags,
+			RepoDigests: image.RepoDigests,
+			Size:        size,
+		})
+	}
+	return images, nil
+}
+
+// HasImage reports whether the node has the image with the given reference.
+//
+// The reference is normalized the way the kubelet normalizes it, so
+// nginx matches docker.io/library/nginx:latest. A digest reference
+// matches an image with that digest.
+func (n NodeImages) HasImage(ref string) (bool, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return false, fmt.Errorf("invalid image reference %q: %v", ref, err)
+	}
+
+	want := reference.TagNameOnly(named).String()
+	digested, isDigest := named.(reference.Digested)
+	if isDigest {
+		// Nodes record digests without tags.
+		want = fmt.Sprintf("%s@%s", named.Name(), digested.Digest())
+	}
+	for _, image := range n.Images {
+		candidates := image.RepoTags
+		if isDigest {
+			candidates = image.RepoDigests
+		}
+		for _, candidate := range candidates {
+			if candidate == want {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,149 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+)
+
+// Trimmed from `crictl images -o json` on a kind node.
+const crictlImagesOutput = `{
+  "images": [
+    {
+      "id": "sha256:ead0a4a53df89fd173874b46093b6e62d8c72967bbf606d672c9e8c9b601a4fc",
+      "repoTags": [
+        "registry.k8s.io/coredns/coredns:v1.10.1"
+      ],
+      "repoDigests": [],
+      "size": "16190758",
+      "uid": null,
+      "username": "",
+      "spec": null,
+      "pinned": false
+    },
+    {
+      "id": "sha256:a8758716bb6aa4d90071160d27028fe4eaee7ce8166221a97d30440c8eac2be6",
+      "repoTags": [
+        "docker.io/library/nginx:1.25"
+      ],
+      "repoDigests": [
+        "docker.io/library/nginx@sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac"
+      ],
+      "size": "70520324",
+      "uid": null,
+      "username": "",
+      "spec": null,
+      "pinned": false
+    }
+  ]
+}`
+
+func TestListNodeImages(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+
+	execs := []string{}
+	f.controller.runner = exec.NewFakeCmdRunner(func(argv []string) string {
+		execs = append(execs, argv[2])
+		if argv[2] == "foo-worker-id" {
+			return `{"images": []}`
+		}
+		return crictlImagesOutput
+	})
+
+	nodes, err := f.controller.ListNodeImages(context.Background(), "kind-foo")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo-control-plane-id", "foo-worker-id"}, execs)
+	require.Len(t, nodes, 2)
+	assert.Equal(t, "foo-control-plane", nodes[0].Node)
+	assert.Equal(t, NodeImage{
+		ID:          "sha256:a8758716bb6aa4d90071160d27028fe4eaee7ce8166221a97d30440c8eac2be6",
+		RepoTags:    []string{"docker.io/library/nginx:1.25"},
+		RepoDigests: []string{"docker.io/library/nginx@sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac"},
+		Size:        70520324,
+	}, nodes[0].Images[1])
+	assert.Equal(t, NodeImages{Node: "foo-worker", Images: []NodeImage{}}, nodes[1])
+}
+
+func TestListNodeImagesUnsupportedProduct(t *testing.T) {
+	f := newFixture(t)
+	_, err := f.controller.ListNodeImages(context.Background(), "docker-desktop")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not supported for product docker-desktop")
+	}
+}
+
+// Trimmed from `ctr -n k8s.io images ls -q` on a kind node.
+const ctrImagesOutput = `docker.io/kindest/kindnetd:v20230511-dc714da8
+docker.io/library/nginx:1.25
+docker.io/library/nginx@sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac
+registry.k8s.io/coredns/coredns:v1.10.1
+sha256:a8758716bb6aa4d90071160d27028fe4eaee7ce8166221a97d30440c8eac2be6
+sha256:ead0a4a53df89fd173874b46093b6e62d8c72967bbf606d672c9e8c9b601a4fc
+`
+
+func TestGetAvailableImages(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+
+	execs := [][]string{}
+	f.controller.runner = exec.NewFakeCmdRunner(func(argv []string) string {
+		execs = append(execs, argv)
+		if argv[2] == "foo-worker-id" {
+			return "docker.io/kindest/kindnetd:v20230511-dc714da8\nlocalhost:5001/my-app:dev\n"
+		}
+		return ctrImagesOutput
+	})
+
+	images, err := f.controller.GetAvailableImages(context.Background(), "kind-foo")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"docker", "exec", "foo-control-plane-id", "ctr", "--namespace", "k8s.io", "images", "ls", "-q",
+	}, execs[0])
+	assert.Equal(t, "foo-worker-id", execs[1][2])
+	assert.Equal(t, []string{
+		"docker.io/kindest/kindnetd:v20230511-dc714da8",
+		"docker.io/library/nginx:1.25",
+		"docker.io/library/nginx@sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac",
+		"localhost:5001/my-app:dev",
+		"registry.k8s.io/coredns/coredns:v1.10.1",
+	}, images)
+}
+
+func TestGetAvailableImagesUnsupportedProduct(t *testing.T) {
+	f := newFixture(t)
+	_, err := f.controller.GetAvailableImages(context.Background(), "docker-desktop")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not supported for product docker-desktop")
+	}
+}
+
+func TestNodeHasImage(t *testing.T) {
+	images, err := parseCrictlImages([]byte(crictlImagesOutput))
+	require.NoError(t, err)
+	node := NodeImages{Node: "kind-control-plane", Images: images}
+
+	for ref, expected := range map[string]bool{
+		"nginx:1.25":                   true,
+		"docker.io/library/nginx:1.25": true,
+		"nginx":                        false,
+		"registry.k8s.io/coredns/coredns:v1.10.1": true,
+		"coredns/coredns:v1.10.1":                 false,
+		"nginx@sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac":      true,
+		"nginx:1.25@sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac": true,
+		"nginx@sha256:0000000000000000000000000000000000000000000000000000000000000000":      false,
+	} {
+		present, err := node.HasImage(ref)
+		require.NoError(t, err)
+		assert.Equal(t, expected, present, ref)
+	}
+
+	_, err = node.HasImage("Nginx")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid image reference "Nginx"`)
+	}
+}
@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/docker/docker/api/types"
+	"github.com/tilt-dev/clusterid"
+)
+
+// GetIngressIP returns the IP that serves the Ingresses of the named
+// cluster, e.g., to point hostnames at in /etc/hosts.
+//
+// If a node publishes port 80 on port 80 of the host (with kind's
+// extraPortMappings), that's 127.0.0.1, or the listenAddress of the mapping.
+// Otherwise, it's the IP of the control-plane node on its Docker network,
+// which the host can only reach on Linux.
+//
+// Only supported on kind clusters.
+func (c *Controller) GetIngressIP(ctx context.Context, clusterName string) (net.IP, error) {
+	product, err := c.productFromConfig(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if product != clusterid.ProductKIND {
+		return nil, fmt.Errorf("cluster %s: ingress IP not supported for product %s. Supported: kind", clusterName, product)
+	}
+
+	containers, err := c.nodeContainers(ctx, clusterName, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("cluster %s: no node containers found", clusterName)
+	}
+
+	for _, container := range containers {
+		for _, port := range container.Ports {
+			if port.PrivatePort != 80 || port.PublicPort != 80 {
+				continue
+			}
+			ip := net.ParseIP(port.IP)
+			if ip == nil || ip.IsUnspecified() {
+				return net.ParseIP("127.0.0.1"), nil
+			}
+			return ip, nil
+		}
+	}
+
+	for _, container := range containers {
+		if container.Labels[kindRoleLabel] != "control-plane" {
+			continue
+		}
+		ip := nodeNetworkIP(container)
+		if ip == nil {
+			return nil, fmt.Errorf("cluster %s: node %s has no IP on its Docker network", clusterName, containerName(container))
+		}
+		return ip, nil
+	}
+	return nil, fmt.Errorf("cluster %s: no control-plane node found", clusterName)
+}
+
+// The IP of the node container on its Docker network.
+func nodeNetworkIP(container types.Container) net.IP {
+	if container.NetworkSettings == nil {
+		return nil
+	}
+	names := make([]string, 0, len(container.NetworkSettings.Networks))
+	for name := range container.NetworkSettings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ip := net.ParseIP(container.NetworkSettings.Networks[name].IPAddress); ip != nil {
+			return ip
+		}
+	}
+	return nil
+}
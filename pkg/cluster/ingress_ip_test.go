@@ -0,0 +1,53 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetIngressIP(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+	for i := range f.dockerClient.containers {
+		f.dockerClient.containers[i].NetworkSettings = &types.SummaryNetworkSettings{
+			Networks: map[string]*network.EndpointSettings{"kind": {IPAddress: "172.18.0.3"}},
+		}
+	}
+	f.dockerClient.containers[1].NetworkSettings.Networks["kind"].IPAddress = "172.18.0.2"
+	ctx := context.Background()
+
+	// Without extraPortMappings, the control-plane node's IP.
+	ip, err := f.controller.GetIngressIP(ctx, "kind-foo")
+	require.NoError(t, err)
+	assert.Equal(t, "172.18.0.2", ip.String())
+
+	// Mapped to another host port, so hostnames can't use it.
+	f.dockerClient.containers[1].Ports = []types.Port{{IP: "0.0.0.0", PrivatePort: 80, PublicPort: 8080}}
+	ip, err = f.controller.GetIngressIP(ctx, "kind-foo")
+	require.NoError(t, err)
+	assert.Equal(t, "172.18.0.2", ip.String())
+
+	f.dockerClient.containers[1].Ports = []types.Port{{IP: "0.0.0.0", PrivatePort: 80, PublicPort: 80}}
+	ip, err = f.controller.GetIngressIP(ctx, "kind-foo")
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", ip.String())
+
+	f.dockerClient.containers[1].Ports = []types.Port{{IP: "127.0.0.2", PrivatePort: 80, PublicPort: 80}}
+	ip, err = f.controller.GetIngressIP(ctx, "kind-foo")
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.2", ip.String())
+}
+
+func TestGetIngressIPUnsupported(t *testing.T) {
+	f := newFixture(t)
+
+	_, err := f.controller.GetIngressIP(context.Background(), "docker-desktop")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "ingress IP not supported for product docker-desktop")
+	}
+}
@@ -0,0 +1,188 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Capabilities that an unprivileged kind node needs to boot systemd,
+// containerd, and the kubelet.
+var kindNodeRequiredCaps = []string{"SYS_ADMIN", "NET_ADMIN"}
+
+// Kind always runs nodes with these security options.
+var kindNodeUnconfinedOpts = []string{"seccomp", "apparmor"}
+
+// The cgroup namespace modes that Docker supports.
+var kindNodeCgroupNSModes = []string{"private", "host"}
+
+func hasKindNodeSecurity(opts *api.KindOptions) bool {
+	return opts != nil &&
+		((opts.Privileged != nil && !*opts.Privileged) ||
+			len(opts.CapAdd) > 0 || len(opts.CapDrop) > 0 || len(opts.SecurityOpt) > 0 ||
+			opts.CgroupNS != "")
+}
+
+func kindNodePrivileged(opts *api.KindOptions) bool {
+	return opts == nil || opts.Privileged == nil || *opts.Privileged
+}
+
+// Normalizes a capability name the way Docker does, e.g., cap_sys_admin -> SYS_ADMIN.
+func normalizeCap(c string) string {
+	c = strings.ToUpper(c)
+	return strings.TrimPrefix(c, "CAP_")
+}
+
+// Checks that the node container settings can still boot a kind node.
+func validateKindNodeSecurity(opts *api.KindOptions) error {
+	if !hasKindNodeSecurity(opts) {
+		return nil
+	}
+	if opts.Provider != "" && opts.Provider != "docker" {
+		return fmt.Errorf("kindOptions.privileged, capAdd, capDrop, securityOpt, and cgroupns are only supported with provider: docker. Actual: %s", opts.Provider)
+	}
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("kindOptions.privileged, capAdd, capDrop, securityOpt, and cgroupns are not supported on Windows")
+	}
+
+	if opts.CgroupNS != "" {
+		if !containsString(kindNodeCgroupNSModes, opts.CgroupNS) {
+			return fmt.Errorf("kindOptions.cgroupns must be one of: %s. Actual: %s",
+				strings.Join(kindNodeCgroupNSModes, ", "), opts.CgroupNS)
+		}
+		if opts.Rootless && opts.CgroupNS == "host" {
+			return fmt.Errorf("kindOptions.cgroupns: rootless nodes can't use the host's cgroups. " +
+				"Remove 'cgroupns: host', or 'rootless: true'")
+		}
+	}
+
+	for _, opt := range opts.SecurityOpt {
+		for _, name := range kindNodeUnconfinedOpts {
+			if (strings.HasPrefix(opt, name+"=") || strings.HasPrefix(opt, name+":")) &&
+				opt[len(name)+1:] != "unconfined" {
+				return fmt.Errorf("kindOptions.securityOpt: kind nodes need %s=unconfined to boot. Actual: %s", name, opt)
+			}
+		}
+	}
+
+	if kindNodePrivileged(opts) {
+		if len(opts.CapAdd) > 0 || len(opts.CapDrop) > 0 {
+			return fmt.Errorf("kindOptions.capAdd and capDrop have no effect on privileged nodes. " +
+				"Set 'privileged: false' to run nodes with a custom set of capabilities")
+		}
+		return nil
+	}
+
+	added := map[string]bool{}
+	for _, c := range opts.CapAdd {
+		added[normalizeCap(c)] = true
+	}
+	dropped := map[string]bool{}
+	for _, c := range opts.CapDrop {
+		dropped[normalizeCap(c)] = true
+	}
+	for _, c := range kindNodeRequiredCaps {
+		if dropped[c] {
+			return fmt.Errorf("kindOptions.capDrop: kind nodes need %s to boot", c)
+		}
+		if !added[c] && !added["ALL"] {
+			return fmt.Errorf("kindOptions.capAdd: unprivileged kind nodes need %s to boot. "+
+				"Add it to capAdd, or remove 'privileged: false'", c)
+		}
+	}
+	return nil
+}
+
+// The extra `docker run` flags for node containers.
+func kindNodeRunArgs(opts *api.KindOptions) []string {
+	args := []string{}
+	for _, c := range opts.CapAdd {
+		args = append(args, "--cap-add", c)
+	}
+	for _, c := range opts.CapDrop {
+		args = append(args, "--cap-drop", c)
+	}
+	for _, o := range opts.SecurityOpt {
+		args = append(args, "--security-opt", o)
+	}
+	if opts.CgroupNS != "" {
+		args = append(args, "--cgroupns="+opts.CgroupNS)
+	}
+	return args
+}
+
+// Kind doesn't let us configure how it runs node containers. Instead, we put
+// a docker wrapper first on its PATH, which rewrites the `docker run` commands
+// for control-plane and worker nodes, and passes everything else through.
+func kindDockerWrapperScript(dockerPath string, opts *api.KindOptions) string {
+	quoted := []string{}
+	for _, arg := range kindNodeRunArgs(opts) {
+		quoted = append(quoted, shellQuote(arg))
+	}
+
+	filter := ""
+	if !kindNodePrivileged(opts) {
+		filter += `      [ "$arg" = "--privileged" ] && continue
+`
+	}
+	if opts.CgroupNS != "" {
+		// Replace the cgroup namespace that kind picked.
+		filter += `      case "$arg" in --cgroupns=*) continue ;; esac
+`
+	}
+
+	return fmt.Sprintf(`#!/bin/sh
+# Generated by ctlptl. Adds security settings to kind node containers.
+if [ "$1" = "run" ]; then
+  case "$*" in
+    *io.x-k8s.kind.role=control-plane*|*io.x-k8s.kind.role=worker*)
+      shift
+      for arg do
+        shift
+%s        set -- "$@" "$arg"
+      done
+      set -- run %s "$@"
+      ;;
+  esac
+fi
+exec %s "$@"
+`, filter, strings.Join(quoted, " "), shellQuote(dockerPath))
+}
+
+// Writes the docker wrapper to a temp dir, and returns the dir.
+//
+// The caller should remove the dir when kind is done.
+func writeKindDockerWrapper(opts *api.KindOptions) (string, error) {
+	dockerPath, err := exec.LookPath("docker")
+	if err != nil {
+		return "", errors.Wrap(err, "finding docker")
+	}
+
+	dir, err := os.MkdirTemp("", "ctlptl-kind-docker-")
+	if err != nil {
+		return "", errors.Wrap(err, "writing docker wrapper")
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "docker"), []byte(kindDockerWrapperScript(dockerPath, opts)), 0755)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", errors.Wrap(err, "writing docker wrapper")
+	}
+	return dir, nil
+}
+
+// Puts the docker wrapper in dir first on the command's PATH.
+func useKindDockerWrapper(cmd *exec.Cmd, dir string) {
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, fmt.Sprintf("PATH=%s%c%s", dir, os.PathListSeparator, os.Getenv("PATH")))
+}
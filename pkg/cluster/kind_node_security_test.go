@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestValidateKindNodeSecurity(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("node security options are not supported on windows")
+	}
+	f := false
+	tt := []struct {
+		name string
+		opts api.KindOptions
+		err  string
+	}{
+		{"default", api.KindOptions{}, ""},
+		{"privileged with security opt", api.KindOptions{SecurityOpt: []string{"label=type:container_t"}}, ""},
+		{"privileged with capAdd", api.KindOptions{CapAdd: []string{"SYS_PTRACE"}},
+			"capAdd and capDrop have no effect on privileged nodes"},
+		{"unprivileged", api.KindOptions{Privileged: &f, CapAdd: []string{"cap_sys_admin", "NET_ADMIN", "SYS_PTRACE"}}, ""},
+		{"unprivileged with all", api.KindOptions{Privileged: &f, CapAdd: []string{"ALL"}, CapDrop: []string{"SYS_MODULE"}}, ""},
+		{"unprivileged without caps", api.KindOptions{Privileged: &f},
+			"unprivileged kind nodes need SYS_ADMIN to boot"},
+		{"dropping required cap", api.KindOptions{Privileged: &f, CapAdd: []string{"ALL"}, CapDrop: []string{"NET_ADMIN"}},
+			"kindOptions.capDrop: kind nodes need NET_ADMIN to boot"},
+		{"seccomp profile", api.KindOptions{SecurityOpt: []string{"seccomp=default.json"}},
+			"kind nodes need seccomp=unconfined to boot. Actual: seccomp=default.json"},
+		{"seccomp unconfined", api.KindOptions{SecurityOpt: []string{"seccomp=unconfined"}}, ""},
+		{"podman", api.KindOptions{Provider: "podman", SecurityOpt: []string{"label=disable"}},
+			"only supported with provider: docker"},
+		{"host cgroupns", api.KindOptions{CgroupNS: "host"}, ""},
+		{"invalid cgroupns", api.KindOptions{CgroupNS: "shared"},
+			"kindOptions.cgroupns must be one of: private, host. Actual: shared"},
+		{"rootless host cgroupns", api.KindOptions{Rootless: true, CgroupNS: "host"},
+			"rootless nodes can't use the host's cgroups"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.opts
+			err := validateKindNodeSecurity(&opts)
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestKindDockerWrapperScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the docker wrapper is a shell script")
+	}
+	dir := t.TempDir()
+	fakeDocker := filepath.Join(dir, "fake-docker")
+	require.NoError(t, os.WriteFile(fakeDocker, []byte("#!/bin/sh\necho \"$@\"\n"), 0755))
+
+	f := false
+	wrapper := filepath.Join(dir, "docker")
+	script := kindDockerWrapperScript(fakeDocker, &api.KindOptions{
+		Privileged:  &f,
+		CapAdd:      []string{"SYS_ADMIN", "NET_ADMIN"},
+		SecurityOpt: []string{"label=type:my type"},
+	})
+	require.NoError(t, os.WriteFile(wrapper, []byte(script), 0755))
+
+	run := func(args ...string) string {
+		out, err := exec.Command(wrapper, args...).Output()
+		require.NoError(t, err)
+		return strings.TrimSpace(string(out))
+	}
+
+	assert.Equal(t,
+		"run --cap-add SYS_ADMIN --cap-add NET_ADMIN --security-opt label=type:my type "+
+			"--name kind-control-plane --label io.x-k8s.kind.role=control-plane "+
+			"--security-opt seccomp=unconfined kindest/node",
+		run("run", "--name", "kind-control-plane", "--label", "io.x-k8s.kind.role=control-plane",
+			"--privileged", "--security-opt", "seccomp=unconfined", "kindest/node"))
+
+	// The load balancer and other commands pass through.
+	assert.Equal(t,
+		"run --name kind-external-load-balancer --label io.x-k8s.kind.role=external-load-balancer kindest/haproxy",
+		run("run", "--name", "kind-external-load-balancer", "--label", "io.x-k8s.kind.role=external-load-balancer",
+			"kindest/haproxy"))
+	assert.Equal(t, "ps -a", run("ps", "-a"))
+
+	// A cgroup namespace replaces the one that kind picked.
+	script = kindDockerWrapperScript(fakeDocker, &api.KindOptions{CgroupNS: "host"})
+	require.NoError(t, os.WriteFile(wrapper, []byte(script), 0755))
+	assert.Equal(t,
+		"run --cgroupns=host --name kind-worker --label io.x-k8s.kind.role=worker --privileged kindest/node",
+		run("run", "--name", "kind-worker", "--label", "io.x-k8s.kind.role=worker",
+			"--privileged", "--cgroupns=private", "kindest/node"))
+}
@@ -0,0 +1,102 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ExportKubeconfig returns a standalone kubeconfig for the named cluster,
+// with credentials inlined, and with contextPrefix prepended to the names of
+// its context, cluster, and user.
+//
+// Prefixed names let kubeconfigs from many projects, which may all have a
+// cluster named kind-dev, be merged into one file without collisions.
+// See MergeKubeconfigs.
+func (c *Controller) ExportKubeconfig(ctx context.Context, clusterName, contextPrefix string) (string, error) {
+	data, err := c.MinifiedKubeconfig(clusterName, true)
+	if err != nil {
+		return "", err
+	}
+	config, err := clientcmd.Load(data)
+	if err != nil {
+		return "", err
+	}
+
+	result := clientcmdapi.NewConfig()
+	result.Preferences = config.Preferences
+	for name, cluster := range config.Clusters {
+		result.Clusters[contextPrefix+name] = cluster
+	}
+	for name, authInfo := range config.AuthInfos {
+		result.AuthInfos[contextPrefix+name] = authInfo
+	}
+	for name, ct := range config.Contexts {
+		ct.Cluster = contextPrefix + ct.Cluster
+		if ct.AuthInfo != "" {
+			ct.AuthInfo = contextPrefix + ct.AuthInfo
+		}
+		result.Contexts[contextPrefix+name] = ct
+	}
+	result.CurrentContext = contextPrefix + config.CurrentContext
+
+	out, err := clientcmd.Write(*result)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// MergeKubeconfigs merges kubeconfigs into one.
+//
+// Fails if two kubeconfigs have different contexts, clusters, or users with
+// the same name, rather than letting one silently win. The current context is
+// the current context of the first kubeconfig that has one.
+func MergeKubeconfigs(configs []string) (string, error) {
+	result := clientcmdapi.NewConfig()
+	for i, data := range configs {
+		config, err := clientcmd.Load([]byte(data))
+		if err != nil {
+			return "", fmt.Errorf("kubeconfig %d: %v", i, err)
+		}
+
+		for name, cluster := range config.Clusters {
+			existing, ok := result.Clusters[name]
+			if ok && !equality.Semantic.DeepEqual(existing, cluster) {
+				return "", kubeconfigConflictError(i, "cluster", name)
+			}
+			result.Clusters[name] = cluster
+		}
+		for name, authInfo := range config.AuthInfos {
+			existing, ok := result.AuthInfos[name]
+			if ok && !equality.Semantic.DeepEqual(existing, authInfo) {
+				return "", kubeconfigConflictError(i, "user", name)
+			}
+			result.AuthInfos[name] = authInfo
+		}
+		for name, ct := range config.Contexts {
+			existing, ok := result.Contexts[name]
+			if ok && !equality.Semantic.DeepEqual(existing, ct) {
+				return "", kubeconfigConflictError(i, "context", name)
+			}
+			result.Contexts[name] = ct
+		}
+		if result.CurrentContext == "" {
+			result.CurrentContext = config.CurrentContext
+		}
+	}
+
+	out, err := clientcmd.Write(*result)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func kubeconfigConflictError(index int, kind, name string) error {
+	return fmt.Errorf("kubeconfig %d: %s %s conflicts with an earlier kubeconfig. "+
+		"Export it with a different prefix to avoid the collision", index, kind, name)
+}
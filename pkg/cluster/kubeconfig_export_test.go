@@ -0,0 +1,63 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestExportKubeconfig(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-dev")
+	f.controller.config.Contexts["kind-dev"].AuthInfo = "kind-dev"
+	f.controller.config.AuthInfos = map[string]*clientcmdapi.AuthInfo{
+		"kind-dev": {Token: "my-token"},
+	}
+
+	data, err := f.controller.ExportKubeconfig(context.Background(), "kind-dev", "myproject-")
+	require.NoError(t, err)
+	config, err := clientcmd.Load([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, "myproject-kind-dev", config.CurrentContext)
+	require.Contains(t, config.Contexts, "myproject-kind-dev")
+	assert.Equal(t, "myproject-kind-dev", config.Contexts["myproject-kind-dev"].Cluster)
+	assert.Equal(t, "myproject-kind-dev", config.Contexts["myproject-kind-dev"].AuthInfo)
+	require.Contains(t, config.Clusters, "myproject-kind-dev")
+	assert.Equal(t, "https://127.0.0.1:6443", config.Clusters["myproject-kind-dev"].Server)
+	require.Contains(t, config.AuthInfos, "myproject-kind-dev")
+	assert.Equal(t, "my-token", config.AuthInfos["myproject-kind-dev"].Token)
+	assert.Len(t, config.Contexts, 1)
+}
+
+func TestMergeKubeconfigs(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-dev")
+
+	a, err := f.controller.ExportKubeconfig(context.Background(), "kind-dev", "a-")
+	require.NoError(t, err)
+	b, err := f.controller.ExportKubeconfig(context.Background(), "kind-dev", "b-")
+	require.NoError(t, err)
+
+	// Merging the same kubeconfig twice is fine.
+	data, err := MergeKubeconfigs([]string{a, b, a})
+	require.NoError(t, err)
+	config, err := clientcmd.Load([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, "a-kind-dev", config.CurrentContext)
+	assert.Len(t, config.Contexts, 2)
+	assert.Contains(t, config.Contexts, "a-kind-dev")
+	assert.Contains(t, config.Contexts, "b-kind-dev")
+	assert.Len(t, config.Clusters, 2)
+
+	f.controller.config.Clusters["kind-dev"].Server = "https://127.0.0.1:7443"
+	other, err := f.controller.ExportKubeconfig(context.Background(), "kind-dev", "a-")
+	require.NoError(t, err)
+	_, err = MergeKubeconfigs([]string{a, other})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "kubeconfig 1: cluster a-kind-dev conflicts with an earlier kubeconfig")
+	}
+}
@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func validateKubeconfigServer(desired *api.Cluster) error {
+	u, err := url.Parse(desired.KubeconfigServer)
+	if err != nil {
+		return fmt.Errorf("invalid kubeconfigServer %q: %v", desired.KubeconfigServer, err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("invalid kubeconfigServer %q: must be an http:// or https:// URL", desired.KubeconfigServer)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid kubeconfigServer %q: missing host", desired.KubeconfigServer)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid kubeconfigServer %q: must not contain a query or fragment", desired.KubeconfigServer)
+	}
+	return nil
+}
+
+// Points the cluster's kubeconfig entry at the server URL in the cluster config,
+// overwriting whatever the cluster product wrote there.
+func (c *Controller) maybeSetKubeconfigServer(cluster *api.Cluster) error {
+	if cluster.KubeconfigServer == "" {
+		return nil
+	}
+
+	config := c.configCopy()
+	ct, ok := config.Contexts[c.contextName(cluster.Name)]
+	if !ok {
+		return fmt.Errorf("cluster %s: kubectl context not found", cluster.Name)
+	}
+	if cl, ok := config.Clusters[ct.Cluster]; ok && cl.Server == cluster.KubeconfigServer {
+		return nil
+	}
+
+	err := c.configWriter.SetConfig(fmt.Sprintf("clusters.%s.server", ct.Cluster), cluster.KubeconfigServer)
+	if err != nil {
+		return fmt.Errorf("setting kubeconfig server: %v", err)
+	}
+
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔌 Pointed cluster %s at server %s\n", cluster.Name, cluster.KubeconfigServer)
+	return c.reloadConfigs()
+}
@@ -0,0 +1,69 @@
+package cluster
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Kubelet flag names, like max-pods or eviction-hard.
+var kubeletArgNameRe = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func validateKubeletArgs(desired *api.Cluster) error {
+	product := clusterid.Product(desired.Product)
+	if product != clusterid.ProductKIND && product != clusterid.ProductK3D {
+		return fmt.Errorf("kubeletArgs may only be set on clusters with product: kind or k3d. Actual product: %s", desired.Product)
+	}
+	for _, name := range sortedKubeletArgNames(desired.KubeletArgs) {
+		if strings.HasPrefix(name, "-") {
+			return fmt.Errorf("invalid kubeletArgs name %q: leave off the leading dashes", name)
+		}
+		if !kubeletArgNameRe.MatchString(name) {
+			return fmt.Errorf("invalid kubeletArgs name %q: must be a kubelet flag name, like max-pods", name)
+		}
+		if strings.ContainsAny(desired.KubeletArgs[name], "\n\r") {
+			return fmt.Errorf("invalid kubeletArgs value for %s: must be a single line", name)
+		}
+	}
+	return nil
+}
+
+func sortedKubeletArgNames(args map[string]string) []string {
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Kubeadm config patches that pass the args to the kubelet.
+//
+// Kind applies the InitConfiguration to the first control-plane node,
+// and the JoinConfiguration to every other node.
+func kubeletArgsPatches(args map[string]string) []string {
+	var b strings.Builder
+	b.WriteString("nodeRegistration:\n  kubeletExtraArgs:\n")
+	for _, name := range sortedKubeletArgNames(args) {
+		b.WriteString(fmt.Sprintf("    %s: %s\n", name, strconv.Quote(args[name])))
+	}
+	return []string{
+		"kind: InitConfiguration\n" + b.String(),
+		"kind: JoinConfiguration\n" + b.String(),
+	}
+}
+
+// K3d flags that pass the args to the kubelet on servers and agents.
+func kubeletArgsK3dFlags(args map[string]string) []string {
+	flags := []string{}
+	for _, name := range sortedKubeletArgNames(args) {
+		flags = append(flags, "--k3s-arg", fmt.Sprintf("--kubelet-arg=%s=%s@server:*;agent:*", name, args[name]))
+	}
+	return flags
+}
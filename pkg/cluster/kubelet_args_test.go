@@ -0,0 +1,114 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"gopkg.in/yaml.v3"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestClusterApplyKubeletArgs(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	kindAdmin := f.newFakeAdmin(clusterid.ProductKIND)
+
+	cluster := &api.Cluster{
+		Product:     string(clusterid.ProductKIND),
+		KubeletArgs: map[string]string{"max-pods": "250"},
+	}
+	_, err := f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	kindAdmin.created = nil
+
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Nil(t, kindAdmin.created)
+	assert.Nil(t, kindAdmin.deleted)
+
+	f.errOut.Truncate(0)
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:     string(clusterid.ProductKIND),
+		KubeletArgs: map[string]string{"max-pods": "500"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.deleted.Name)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	assert.Contains(t, f.errOut.String(), "desired kubelet args do not match current")
+}
+
+func TestValidateKubeletArgs(t *testing.T) {
+	err := validateKubeletArgs(&api.Cluster{
+		Product:     string(clusterid.ProductK3D),
+		KubeletArgs: map[string]string{"eviction-hard": "memory.available<5%", "feature-gates": "A=true,B=false"},
+	})
+	assert.NoError(t, err)
+
+	err = validateKubeletArgs(&api.Cluster{
+		Product:     string(clusterid.ProductMinikube),
+		KubeletArgs: map[string]string{"max-pods": "250"},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "kubeletArgs may only be set on clusters with product: kind or k3d")
+	}
+
+	err = validateKubeletArgs(&api.Cluster{
+		Product:     string(clusterid.ProductKIND),
+		KubeletArgs: map[string]string{"--max-pods": "250"},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid kubeletArgs name "--max-pods": leave off the leading dashes`)
+	}
+
+	err = validateKubeletArgs(&api.Cluster{
+		Product:     string(clusterid.ProductKIND),
+		KubeletArgs: map[string]string{"max_pods": "250"},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid kubeletArgs name "max_pods"`)
+	}
+}
+
+func TestKindConfigKubeletArgs(t *testing.T) {
+	iostreams := genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
+	a := newKindAdmin(iostreams, &fakeDockerClient{})
+
+	config := a.kindClusterConfig(&api.Cluster{
+		Product:     string(clusterid.ProductKIND),
+		KubeletArgs: map[string]string{"max-pods": "250", "eviction-hard": "memory.available<5%"},
+	}, nil)
+	require.Len(t, config.KubeadmConfigPatches, 2)
+
+	for i, kind := range []string{"InitConfiguration", "JoinConfiguration"} {
+		var patch struct {
+			Kind             string `yaml:"kind"`
+			NodeRegistration struct {
+				KubeletExtraArgs map[string]string `yaml:"kubeletExtraArgs"`
+			} `yaml:"nodeRegistration"`
+		}
+		require.NoError(t, yaml.Unmarshal([]byte(config.KubeadmConfigPatches[i]), &patch))
+		assert.Equal(t, kind, patch.Kind)
+		assert.Equal(t, map[string]string{"max-pods": "250", "eviction-hard": "memory.available<5%"},
+			patch.NodeRegistration.KubeletExtraArgs)
+	}
+}
+
+func TestK3dCreateArgsKubeletArgs(t *testing.T) {
+	a := newK3dAdmin(genericclioptions.IOStreams{})
+	args := a.createArgs(&api.Cluster{
+		Name:        "k3d-foo",
+		KubeletArgs: map[string]string{"max-pods": "250", "eviction-hard": "memory.available<5%"},
+	}, nil)
+	assert.Equal(t, []string{
+		"cluster", "create", "foo",
+		"--k3s-arg", "--kubelet-arg=eviction-hard=memory.available<5%@server:*;agent:*",
+		"--k3s-arg", "--kubelet-arg=max-pods=250@server:*;agent:*",
+	}, args)
+}
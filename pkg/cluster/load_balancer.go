@@ -0,0 +1,155 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+var metallbVersion = "0.13.10"
+
+var metallbManifestURL = "https://raw.githubusercontent.com/metallb/metallb/v%s/config/manifests/metallb-native.yaml"
+
+const metallbPoolName = "ctlptl"
+
+// Hands out the IPs in the pool, and answers ARP requests for them on the
+// Docker network.
+const metallbPoolTemplate = `apiVersion: metallb.io/v1beta1
+kind: IPAddressPool
+metadata:
+  name: %[1]s
+  namespace: metallb-system
+spec:
+  addresses:
+  - %[2]s
+---
+apiVersion: metallb.io/v1beta1
+kind: L2Advertisement
+metadata:
+  name: %[1]s
+  namespace: metallb-system
+spec:
+  ipAddressPools:
+  - %[1]s
+`
+
+func validateLoadBalancer(desired *api.Cluster) error {
+	if clusterid.Product(desired.Product) != clusterid.ProductKIND {
+		return fmt.Errorf("loadBalancer may only be set on clusters with product: kind. Actual product: %s", desired.Product)
+	}
+
+	ipRange := desired.LoadBalancer.IPRange
+	if ipRange != "" && !isValidIPRange(ipRange) {
+		return fmt.Errorf("loadBalancer.ipRange must be a CIDR (172.19.255.0/24) or a range of IPs (172.19.255.200-172.19.255.250). Actual: %s", ipRange)
+	}
+	return nil
+}
+
+func isValidIPRange(ipRange string) bool {
+	if strings.Contains(ipRange, "/") {
+		_, _, err := net.ParseCIDR(ipRange)
+		return err == nil
+	}
+
+	start, end, ok := strings.Cut(ipRange, "-")
+	if !ok {
+		return false
+	}
+	startIP := net.ParseIP(strings.TrimSpace(start))
+	endIP := net.ParseIP(strings.TrimSpace(end))
+	if startIP == nil || endIP == nil || (startIP.To4() == nil) != (endIP.To4() == nil) {
+		return false
+	}
+	return bytes.Compare(startIP.To16(), endIP.To16()) <= 0
+}
+
+// EnsureLoadBalancer installs MetalLB in a kind cluster, and configures it to
+// assign LoadBalancer services IPs from ipRange.
+//
+// If ipRange is empty, picks IPs at the top of the kind Docker network's subnet,
+// which are reachable from the host on Linux.
+func (c *Controller) EnsureLoadBalancer(ctx context.Context, clusterName string, ipRange string) error {
+	if ipRange == "" {
+		var err error
+		ipRange, err = c.defaultLoadBalancerIPRange(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔧 Installing MetalLB v%s for LoadBalancer services\n", metallbVersion)
+	_, err := c.kubectl(ctx, clusterName, nil, "apply", "-f", fmt.Sprintf(metallbManifestURL, metallbVersion))
+	if err != nil {
+		return err
+	}
+
+	// MetalLB's webhook rejects address pools until the controller is up.
+	_, err = c.kubectl(ctx, clusterName, nil,
+		"wait", "-n", "metallb-system", "--for=condition=ready", "pod", "--selector=app=metallb", "--timeout=3m")
+	if err != nil {
+		return err
+	}
+
+	manifest := fmt.Sprintf(metallbPoolTemplate, metallbPoolName, ipRange)
+	_, err = c.kubectl(ctx, clusterName, strings.NewReader(manifest), "apply", "-f", "-")
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔧 LoadBalancer services in cluster %s get IPs from %s\n", clusterName, ipRange)
+	return nil
+}
+
+// Removes the address pool, so that MetalLB stops assigning IPs.
+//
+// Leaves MetalLB itself installed, in case something else depends on its CRDs.
+func (c *Controller) removeLoadBalancer(ctx context.Context, clusterName string) error {
+	_, err := c.kubectl(ctx, clusterName, nil, "delete", "-n", "metallb-system",
+		"l2advertisement.metallb.io/"+metallbPoolName, "ipaddresspool.metallb.io/"+metallbPoolName, "--ignore-not-found")
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔧 Removed LoadBalancer IPs from cluster %s\n", clusterName)
+	return nil
+}
+
+// Picks x.y.z.200-x.y.z.250 in the last /24 of the kind network's IPv4 subnet,
+// which Docker is unlikely to hand out to containers.
+func (c *Controller) defaultLoadBalancerIPRange(ctx context.Context) (string, error) {
+	dockerClient, err := c.getDockerClient(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	networkName := kindNetworkName()
+	network, err := dockerClient.NetworkInspect(ctx, networkName, types.NetworkInspectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("detecting loadBalancer IPs: %v", err)
+	}
+
+	for _, config := range network.IPAM.Config {
+		_, subnet, err := net.ParseCIDR(config.Subnet)
+		if err != nil || subnet.IP.To4() == nil {
+			continue
+		}
+		ones, _ := subnet.Mask.Size()
+		if ones > 24 {
+			return "", fmt.Errorf("detecting loadBalancer IPs: Docker network %s subnet %s is too small. Set loadBalancer.ipRange",
+				networkName, config.Subnet)
+		}
+
+		last := make(net.IP, 4)
+		for i, b := range subnet.IP.To4() {
+			last[i] = b | ^subnet.Mask[i]
+		}
+		return fmt.Sprintf("%d.%d.%d.200-%d.%d.%d.250",
+			last[0], last[1], last[2], last[0], last[1], last[2]), nil
+	}
+	return "", fmt.Errorf("detecting loadBalancer IPs: Docker network %s has no IPv4 subnet. Set loadBalancer.ipRange", networkName)
+}
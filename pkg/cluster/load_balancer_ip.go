@@ -0,0 +1,106 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// LoadBalancerAddress is where a LoadBalancer service is reachable.
+type LoadBalancerAddress struct {
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
+
+	// The external IPs (or hostnames) of the service. Empty while pending.
+	IPs []string `json:"ips"`
+
+	// The IPs that MetalLB assigns from, if ctlptl installed it.
+	Pool string `json:"pool,omitempty"`
+}
+
+// GetLoadBalancerPool returns the IPs that MetalLB assigns to LoadBalancer
+// services in the named cluster, as configured by loadBalancer.ipRange.
+//
+// Returns an empty string if the cluster has no ctlptl address pool.
+func (c *Controller) GetLoadBalancerPool(ctx context.Context, clusterName string) (string, error) {
+	out, err := c.kubectl(ctx, clusterName, nil, "get", "-n", "metallb-system",
+		"ipaddresspool.metallb.io/"+metallbPoolName, "--ignore-not-found", "-o", "jsonpath={.spec.addresses[*]}")
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(out), ","), nil
+}
+
+func serviceIngressAddresses(svc *v1.Service) []string {
+	result := []string{}
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			result = append(result, ingress.IP)
+		} else if ingress.Hostname != "" {
+			result = append(result, ingress.Hostname)
+		}
+	}
+	return result
+}
+
+// WaitForLoadBalancerIP waits up to timeout for the LoadBalancer service to
+// get an external IP, and returns it along with the cluster's address pool.
+//
+// If timeout is 0, doesn't wait, and returns no IPs if the service is pending.
+func (c *Controller) WaitForLoadBalancerIP(ctx context.Context, clusterName, namespace, name string, timeout time.Duration) (*LoadBalancerAddress, error) {
+	client, err := c.client(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LoadBalancerAddress{Namespace: namespace, Service: name, IPs: []string{}}
+	pool, err := c.GetLoadBalancerPool(ctx, clusterName)
+	if err != nil {
+		klog.V(4).Infof("WARNING: reading cluster %s address pool: %v\n", clusterName, err)
+	}
+	result.Pool = pool
+
+	get := func() (bool, error) {
+		svc, err := client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if svc.Spec.Type != v1.ServiceTypeLoadBalancer {
+			return false, fmt.Errorf("service %s/%s has type %s, not LoadBalancer", namespace, name, svc.Spec.Type)
+		}
+		result.IPs = serviceIngressAddresses(svc)
+		return len(result.IPs) > 0, nil
+	}
+
+	done, err := get()
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s: %v", clusterName, err)
+	}
+	if done || timeout == 0 {
+		return result, nil
+	}
+
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Waiting %s for service %s/%s to get an external IP...\n",
+		duration.ShortHumanDuration(timeout), namespace, name)
+	err = wait.PollImmediate(time.Second, timeout, get)
+	if err != nil {
+		if err == wait.ErrWaitTimeout {
+			hint := "Set loadBalancer in the cluster config to install MetalLB"
+			if pool != "" {
+				hint = fmt.Sprintf("Check that the pool %s has free IPs", pool)
+			}
+			return nil, fmt.Errorf("timed out waiting for service %s/%s in cluster %s to get an external IP. %s",
+				namespace, name, clusterName, hint)
+		}
+		return nil, errors.Wrapf(err, "cluster %s", clusterName)
+	}
+	return result, nil
+}
@@ -0,0 +1,64 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (f *fixture) createService(name string, svcType v1.ServiceType, ingress ...v1.LoadBalancerIngress) {
+	_, err := f.fakeK8s.CoreV1().Services("default").Create(context.Background(), &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       v1.ServiceSpec{Type: svcType},
+		Status:     v1.ServiceStatus{LoadBalancer: v1.LoadBalancerStatus{Ingress: ingress}},
+	}, metav1.CreateOptions{})
+	require.NoError(f.t, err)
+}
+
+func TestWaitForLoadBalancerIP(t *testing.T) {
+	f := newFixture(t)
+	f.controller.runner = &kubectlRunner{lbPool: "172.18.255.200-172.18.255.250"}
+	f.createService("my-app", v1.ServiceTypeLoadBalancer,
+		v1.LoadBalancerIngress{IP: "172.18.255.200"}, v1.LoadBalancerIngress{Hostname: "my-app.local"})
+
+	addr, err := f.controller.WaitForLoadBalancerIP(context.Background(), "docker-desktop", "default", "my-app", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, &LoadBalancerAddress{
+		Namespace: "default",
+		Service:   "my-app",
+		IPs:       []string{"172.18.255.200", "my-app.local"},
+		Pool:      "172.18.255.200-172.18.255.250",
+	}, addr)
+}
+
+func TestWaitForLoadBalancerIPPending(t *testing.T) {
+	f := newFixture(t)
+	f.controller.runner = &kubectlRunner{}
+	f.createService("my-app", v1.ServiceTypeLoadBalancer)
+
+	addr, err := f.controller.WaitForLoadBalancerIP(context.Background(), "docker-desktop", "default", "my-app", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, addr.IPs)
+
+	_, err = f.controller.WaitForLoadBalancerIP(context.Background(), "docker-desktop", "default", "my-app", time.Second)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "timed out waiting for service default/my-app in cluster docker-desktop to get an external IP")
+		assert.Contains(t, err.Error(), "Set loadBalancer in the cluster config")
+	}
+}
+
+func TestWaitForLoadBalancerIPNotLoadBalancer(t *testing.T) {
+	f := newFixture(t)
+	f.controller.runner = &kubectlRunner{}
+	f.createService("my-app", v1.ServiceTypeClusterIP)
+
+	_, err := f.controller.WaitForLoadBalancerIP(context.Background(), "docker-desktop", "default", "my-app", time.Minute)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "service default/my-app has type ClusterIP, not LoadBalancer")
+	}
+}
@@ -0,0 +1,114 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestClusterApplyLoadBalancer(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+	f.dockerClient.started = true
+	f.dockerClient.subnets = map[string][]string{"kind": {"fc00:f853:ccd:e793::/64", "172.19.0.0/16"}}
+	kindAdmin := f.newFakeAdmin(clusterid.ProductKIND)
+	runner := &kubectlRunner{}
+	f.controller.runner = runner
+
+	cluster := &api.Cluster{
+		Product:      string(clusterid.ProductKIND),
+		LoadBalancer: &api.LoadBalancerSpec{},
+	}
+	_, err := f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+
+	assert.Equal(t, []string{
+		"kubectl --context kind-kind apply -f https://raw.githubusercontent.com/metallb/metallb/v0.13.10/config/manifests/metallb-native.yaml",
+		"kubectl --context kind-kind wait -n metallb-system --for=condition=ready pod --selector=app=metallb --timeout=3m",
+	}, runner.callsMatching("metallb"))
+	assert.Len(t, runner.callsMatching("apply -f -"), 1)
+	require.Len(t, runner.stdin, 1)
+	assert.Contains(t, runner.stdin[0], "kind: IPAddressPool")
+	assert.Contains(t, runner.stdin[0], "- 172.19.255.200-172.19.255.250\n")
+	assert.Contains(t, runner.stdin[0], "kind: L2Advertisement")
+	assert.Contains(t, f.errOut.String(), "LoadBalancer services in cluster kind-kind get IPs from 172.19.255.200-172.19.255.250")
+
+	c, err := f.controller.Get(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.Equal(t, &api.LoadBalancerSpec{}, c.LoadBalancer)
+
+	// Changing the range doesn't re-create the cluster.
+	kindAdmin.created = nil
+	runner.calls = nil
+	cluster.LoadBalancer = &api.LoadBalancerSpec{IPRange: "172.19.100.0/28"}
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Nil(t, kindAdmin.created)
+	require.Len(t, runner.stdin, 2)
+	assert.Contains(t, runner.stdin[1], "- 172.19.100.0/28\n")
+
+	// Applying the same spec again does nothing.
+	runner.calls = nil
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Empty(t, runner.callsMatching("metallb"))
+
+	// Removing the load balancer removes the pool.
+	cluster.LoadBalancer = nil
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"kubectl --context kind-kind delete -n metallb-system l2advertisement.metallb.io/ctlptl ipaddresspool.metallb.io/ctlptl --ignore-not-found",
+	}, runner.callsMatching("metallb"))
+}
+
+func TestClusterApplyLoadBalancerNoIPv4Subnet(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+	f.dockerClient.started = true
+	f.dockerClient.subnets = map[string][]string{"kind": {"fc00:f853:ccd:e793::/64"}}
+	f.newFakeAdmin(clusterid.ProductKIND)
+	f.controller.runner = &kubectlRunner{}
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:      string(clusterid.ProductKIND),
+		LoadBalancer: &api.LoadBalancerSpec{},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Docker network kind has no IPv4 subnet. Set loadBalancer.ipRange")
+	}
+}
+
+func TestValidateLoadBalancer(t *testing.T) {
+	for _, ipRange := range []string{"", "172.19.255.200-172.19.255.250", "172.19.255.0/24", "fc00::10-fc00::20"} {
+		err := validateLoadBalancer(&api.Cluster{
+			Product:      string(clusterid.ProductKIND),
+			LoadBalancer: &api.LoadBalancerSpec{IPRange: ipRange},
+		})
+		assert.NoError(t, err, ipRange)
+	}
+
+	for _, ipRange := range []string{"172.19.255.200", "172.19.255.250-172.19.255.200", "172.19.255.0/33", "172.19.255.1-fc00::1"} {
+		err := validateLoadBalancer(&api.Cluster{
+			Product:      string(clusterid.ProductKIND),
+			LoadBalancer: &api.LoadBalancerSpec{IPRange: ipRange},
+		})
+		if assert.Error(t, err, ipRange) {
+			assert.Contains(t, err.Error(), "loadBalancer.ipRange must be a CIDR")
+		}
+	}
+
+	err := validateLoadBalancer(&api.Cluster{
+		Product:      string(clusterid.ProductMinikube),
+		LoadBalancer: &api.LoadBalancerSpec{},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "loadBalancer may only be set on clusters with product: kind")
+	}
+}
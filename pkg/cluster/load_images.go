@@ -0,0 +1,171 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// LoadImagesOptions controls how LoadImages warms up a cluster.
+type LoadImagesOptions struct {
+	// How many images to pull and load at once. Loading copies each image
+	// into every node, so high parallelism can saturate the disk.
+	// Defaults to 1.
+	Parallelism int
+
+	// Called when each image starts and finishes loading. Never called
+	// concurrently.
+	OnProgress func(p ImageLoadProgress)
+}
+
+// ImageLoadProgress reports on one image passed to LoadImages.
+type ImageLoadProgress struct {
+	Image string
+
+	// The position of the image in the list, and the length of the list.
+	Index int
+	Total int
+
+	// Set when the image finished loading, successfully or not.
+	Done     bool
+	Duration time.Duration
+	Err      error
+}
+
+// ImageLoadError lists the images that LoadImages failed to load.
+type ImageLoadError struct {
+	Total    int
+	Failures map[string]error
+}
+
+func (e *ImageLoadError) Error() string {
+	images := make([]string, 0, len(e.Failures))
+	for image := range e.Failures {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	lines := make([]string, 0, len(images))
+	for _, image := range images {
+		lines = append(lines, fmt.Sprintf("  %s: %v", image, e.Failures[image]))
+	}
+	return fmt.Sprintf("failed to load %d of %d images:\n%s", len(e.Failures), e.Total, strings.Join(lines, "\n"))
+}
+
+// LoadImages copies images from the local Docker daemon into the nodes of
+// the named cluster, so pods can start without pulling them. Images that
+// the daemon doesn't have are pulled first.
+//
+// Loads up to options.Parallelism images at once. Keeps going when an image
+// fails, and returns an *ImageLoadError that lists every failure.
+//
+// Only supported on kind and k3d clusters.
+func (c *Controller) LoadImages(ctx context.Context, clusterName string, images []string, options LoadImagesOptions) error {
+	product, err := c.productFromConfig(clusterName)
+	if err != nil {
+		return err
+	}
+	var loadArgs func(image string) []string
+	switch product {
+	case clusterid.ProductKIND:
+		loadArgs = func(image string) []string {
+			return []string{"kind", "load", "docker-image", image, "--name", strings.TrimPrefix(clusterName, "kind-")}
+		}
+	case clusterid.ProductK3D:
+		loadArgs = func(image string) []string {
+			return []string{"k3d", "image", "import", image, "--cluster", strings.TrimPrefix(clusterName, "k3d-")}
+		}
+	default:
+		return fmt.Errorf("cluster %s: loading images not supported for product %s. Supported: kind, k3d",
+			clusterName, product)
+	}
+
+	parallelism := options.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var mu sync.Mutex
+	failures := make(map[string]error)
+	report := func(p ImageLoadProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		if p.Err != nil {
+			failures[p.Image] = p.Err
+		}
+		if options.OnProgress != nil {
+			options.OnProgress(p)
+		}
+	}
+
+	sem := make(chan struct{}, parallelism)
+	wg := sync.WaitGroup{}
+	for i, image := range images {
+		i, image := i, image
+
+		// Acquire before starting, so that images start in order.
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			p := ImageLoadProgress{Image: image, Index: i, Total: len(images)}
+			report(p)
+
+			start := time.Now()
+			err := ctx.Err()
+			if err == nil {
+				err = c.loadImage(ctx, image, loadArgs(image))
+			}
+			p.Done = true
+			p.Duration = time.Since(start)
+			p.Err = err
+			report(p)
+		}()
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		return &ImageLoadError{Total: len(images), Failures: failures}
+	}
+	return nil
+}
+
+// Pulls the image if the local Docker daemon doesn't have it, then loads it.
+func (c *Controller) loadImage(ctx context.Context, image string, loadArgs []string) error {
+	err := c.runImageCommand(ctx, "docker", "image", "inspect", image)
+	if err != nil {
+		err = c.runImageCommand(ctx, "docker", "pull", image)
+		if err != nil {
+			return fmt.Errorf("pulling: %v", err)
+		}
+	}
+	err = c.runImageCommand(ctx, loadArgs[0], loadArgs[1:]...)
+	if err != nil {
+		return fmt.Errorf("loading: %v", err)
+	}
+	return nil
+}
+
+// Runs a command, and includes its stderr in the error.
+func (c *Controller) runImageCommand(ctx context.Context, cmd string, args ...string) error {
+	out := bytes.NewBuffer(nil)
+	errOut := bytes.NewBuffer(nil)
+	err := c.runner.RunIO(ctx, genericclioptions.IOStreams{Out: out, ErrOut: errOut}, cmd, args...)
+	if err != nil {
+		msg := strings.TrimSpace(errOut.String())
+		if msg != "" {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// Records the commands that it runs, and how many ran at once.
+type loadImagesRunner struct {
+	mu       sync.Mutex
+	commands []string
+	running  int
+	maxSeen  int
+	missing  map[string]bool
+	failing  map[string]bool
+}
+
+func (r *loadImagesRunner) Run(ctx context.Context, cmd string, args ...string) error {
+	return r.RunIO(ctx, genericclioptions.IOStreams{}, cmd, args...)
+}
+
+func (r *loadImagesRunner) RunIO(ctx context.Context, iostreams genericclioptions.IOStreams, cmd string, args ...string) error {
+	r.mu.Lock()
+	r.commands = append(r.commands, strings.Join(append([]string{cmd}, args...), " "))
+	r.running++
+	if r.running > r.maxSeen {
+		r.maxSeen = r.running
+	}
+	r.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running--
+	image := args[len(args)-1]
+	if cmd == "kind" {
+		image = args[2]
+	}
+	if args[0] == "image" && r.missing[image] {
+		return fmt.Errorf("exit status 1")
+	}
+	if cmd == "kind" && r.failing[image] {
+		_, _ = fmt.Fprintf(iostreams.ErrOut, "ERROR: image %s not present locally\n", image)
+		return fmt.Errorf("exit status 1")
+	}
+	return nil
+}
+
+func TestLoadImages(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+	runner := &loadImagesRunner{missing: map[string]bool{"redis:7": true}}
+	f.controller.runner = runner
+
+	var progress []ImageLoadProgress
+	images := []string{"nginx:1.25", "redis:7", "busybox", "alpine:3"}
+	err := f.controller.LoadImages(context.Background(), "kind-foo", images, LoadImagesOptions{
+		Parallelism: 2,
+		OnProgress: func(p ImageLoadProgress) {
+			progress = append(progress, p)
+		},
+	})
+	require.NoError(t, err)
+
+	assert.LessOrEqual(t, runner.maxSeen, 2)
+	assert.Contains(t, runner.commands, "docker pull redis:7")
+	assert.NotContains(t, runner.commands, "docker pull nginx:1.25")
+	assert.Contains(t, runner.commands, "kind load docker-image busybox --name foo")
+
+	require.Len(t, progress, 8)
+	done := 0
+	for _, p := range progress {
+		assert.Equal(t, 4, p.Total)
+		if p.Done {
+			done++
+			assert.NoError(t, p.Err)
+		}
+	}
+	assert.Equal(t, 4, done)
+}
+
+func TestLoadImagesFailures(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+	f.controller.runner = &loadImagesRunner{failing: map[string]bool{"b": true, "c": true}}
+
+	err := f.controller.LoadImages(context.Background(), "kind-foo", []string{"a", "b", "c"}, LoadImagesOptions{Parallelism: 3})
+	var loadErr *ImageLoadError
+	require.ErrorAs(t, err, &loadErr)
+	assert.Len(t, loadErr.Failures, 2)
+	assert.Equal(t, "failed to load 2 of 3 images:\n"+
+		"  b: loading: exit status 1: ERROR: image b not present locally\n"+
+		"  c: loading: exit status 1: ERROR: image c not present locally", err.Error())
+}
+
+func TestLoadImagesUnsupported(t *testing.T) {
+	f := newFixture(t)
+
+	err := f.controller.LoadImages(context.Background(), "docker-desktop", []string{"a"}, LoadImagesOptions{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "loading images not supported for product docker-desktop")
+	}
+}
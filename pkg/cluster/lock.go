@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/tilt-dev/ctlptl/internal/filelock"
+)
+
+// Creating a cluster can take a while, so wait as long as a create might take.
+const clusterLockTimeout = waitForClusterCreateTimeout
+
+const kubeconfigLockTimeout = 30 * time.Second
+
+var invalidLockNameChars = regexp.MustCompile("[^a-zA-Z0-9_.-]+")
+
+// The directory where concurrent ctlptl invocations coordinate.
+//
+// Returns an empty string if there's no home directory, which disables locking.
+func defaultLockDir() string {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, ".ctlptl", "locks")
+}
+
+// Locks the named cluster, so that only one ctlptl invocation at a time
+// can create or delete it.
+func (c *Controller) lockCluster(name string) (func(), error) {
+	if c.lockDir == "" {
+		return func() {}, nil
+	}
+
+	contextName := c.contextName(name)
+	path := filepath.Join(c.lockDir, fmt.Sprintf("cluster-%s.lock", invalidLockNameChars.ReplaceAllString(contextName, "_")))
+	lock, err := filelock.Acquire(path, clusterLockTimeout, c.iostreams.ErrOut)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s: %v", name, err)
+	}
+	return func() { _ = lock.Release() }, nil
+}
+
+// The path of the lock for a kubeconfig, which may be a list of files.
+func kubeconfigLockPath(lockDir string, kubeconfigPaths []string) string {
+	hash := sha256.Sum256([]byte(strings.Join(kubeconfigPaths, string(filepath.ListSeparator))))
+	return filepath.Join(lockDir, fmt.Sprintf("kubeconfig-%x.lock", hash[:6]))
+}
+
+// Serializes writes to the kubeconfig across ctlptl invocations.
+//
+// Only covers the writes that ctlptl makes with kubectl. The cluster tools
+// (kind, k3d, kwokctl, ...) write the kubeconfig themselves while
+// admin.Create runs, outside of this lock, because holding it for a whole
+// create would stall every other ctlptl invocation for minutes. Kind and
+// kubectl both take client-go's lock on the file itself (<kubeconfig>.lock)
+// while they write, but fail instead of waiting if it's taken.
+type lockingConfigWriter struct {
+	writer   configWriter
+	lockPath string
+	errOut   io.Writer
+}
+
+func (w lockingConfigWriter) withLock(f func() error) error {
+	lock, err := filelock.Acquire(w.lockPath, kubeconfigLockTimeout, w.errOut)
+	if err != nil {
+		return fmt.Errorf("writing kubeconfig: %v", err)
+	}
+	defer func() { _ = lock.Release() }()
+	return f()
+}
+
+func (w lockingConfigWriter) SetContext(name string) error {
+	return w.withLock(func() error { return w.writer.SetContext(name) })
+}
+
+func (w lockingConfigWriter) DeleteContext(name string) error {
+	return w.withLock(func() error { return w.writer.DeleteContext(name) })
+}
+
+func (w lockingConfigWriter) RenameContext(oldName, newName string) error {
+	return w.withLock(func() error { return w.writer.RenameContext(oldName, newName) })
+}
+
+func (w lockingConfigWriter) SetConfig(name, value string) error {
+	return w.withLock(func() error { return w.writer.SetConfig(name, value) })
+}
+
+var _ configWriter = lockingConfigWriter{}
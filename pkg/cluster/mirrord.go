@@ -0,0 +1,140 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Where `mirrord operator setup` installs the operator.
+const mirrordOperatorNamespace = "mirrord"
+const mirrordOperatorService = "mirrord-operator"
+
+// The mirrord config that local processes pick up, relative to the current directory.
+var mirrordConfigPath = filepath.Join(".mirrord", "mirrord.json")
+
+// An extension of cluster admin that indicates the admin has more to install
+// once the cluster is up and healthy.
+type AdminWithSetup interface {
+	Setup(ctx context.Context, cluster *api.Cluster, contextName string) error
+}
+
+// mirrordAdmin wraps the admin of any product, and installs the
+// mirrord operator after the cluster is provisioned.
+//
+// Creating and deleting the cluster is left to the wrapped admin.
+type mirrordAdmin struct {
+	Admin
+
+	iostreams      genericclioptions.IOStreams
+	runner         exec.CmdRunner
+	kubeconfigPath string
+	dir            string
+}
+
+var _ AdminWithSetup = &mirrordAdmin{}
+
+func newMirrordAdmin(admin Admin, iostreams genericclioptions.IOStreams, runner exec.CmdRunner, kubeconfigPath string, dir string) *mirrordAdmin {
+	return &mirrordAdmin{
+		Admin:          admin,
+		iostreams:      iostreams,
+		runner:         runner,
+		kubeconfigPath: kubeconfigPath,
+		dir:            dir,
+	}
+}
+
+// Installs the mirrord operator, and points the local mirrord config at the cluster.
+func (a *mirrordAdmin) Setup(ctx context.Context, cluster *api.Cluster, contextName string) error {
+	_, _ = fmt.Fprintf(a.iostreams.ErrOut, "   Installing the mirrord operator in cluster %s\n", cluster.Name)
+
+	manifest := bytes.NewBuffer(nil)
+	err := a.runner.RunIO(ctx,
+		genericclioptions.IOStreams{Out: manifest, ErrOut: a.iostreams.ErrOut},
+		"mirrord", "operator", "setup", "--accept-tos")
+	if err != nil {
+		return errors.Wrap(err, "generating mirrord operator manifest (mirrordEnabled requires the mirrord CLI: https://mirrord.dev/)")
+	}
+
+	err = a.runner.RunIO(ctx,
+		genericclioptions.IOStreams{In: manifest, Out: a.iostreams.Out, ErrOut: a.iostreams.ErrOut},
+		"kubectl", "--context", contextName, "apply", "-f", "-")
+	if err != nil {
+		return errors.Wrap(err, "installing mirrord operator")
+	}
+
+	err = a.writeConfig(contextName)
+	if err != nil {
+		return errors.Wrap(err, "writing mirrord config")
+	}
+	return nil
+}
+
+// Points the mirrord config at the cluster.
+//
+// Keeps any other settings already in the file.
+func (a *mirrordAdmin) writeConfig(contextName string) error {
+	path := filepath.Join(a.dir, mirrordConfigPath)
+	config := map[string]interface{}{}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &config)
+		if err != nil {
+			return fmt.Errorf("decoding %s: %v", path, err)
+		}
+	}
+
+	if a.kubeconfigPath != "" {
+		config["kubeconfig"] = a.kubeconfigPath
+	}
+	config["kube_context"] = contextName
+	config["operator"] = true
+
+	data, err = json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), os.FileMode(0755))
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(a.iostreams.ErrOut, "   Pointed %s at cluster %s\n", path, contextName)
+	return os.WriteFile(path, append(data, '\n'), os.FileMode(0644))
+}
+
+// Reads the in-cluster URL of the mirrord operator.
+func (c *Controller) populateMirrordEndpoint(ctx context.Context, cluster *api.Cluster, client kubernetes.Interface) error {
+	svc, err := client.CoreV1().Services(mirrordOperatorNamespace).Get(ctx, mirrordOperatorService, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	cluster.Status.MirrordEndpoint = mirrordEndpoint(svc)
+	return nil
+}
+
+func mirrordEndpoint(svc *corev1.Service) string {
+	port := int32(443)
+	if len(svc.Spec.Ports) > 0 {
+		port = svc.Spec.Ports[0].Port
+	}
+	return fmt.Sprintf("https://%s.%s.svc:%d", svc.Name, svc.Namespace, port)
+}
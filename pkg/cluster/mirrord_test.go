@@ -0,0 +1,82 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+const fakeMirrordManifest = "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: mirrord\n"
+
+func TestClusterApplyMirrord(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	kindAdmin := f.newFakeAdmin(clusterid.ProductKIND)
+	runner := &kubectlRunner{}
+	f.controller.runner = runner
+	f.controller.mirrordDir = t.TempDir()
+
+	cluster := &api.Cluster{
+		Product:        string(clusterid.ProductKIND),
+		MirrordEnabled: true,
+	}
+	_, err := f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	assert.Equal(t, []string{
+		"mirrord operator setup --accept-tos",
+		"kubectl --context kind-kind apply -f -",
+	}, runner.calls)
+	assert.Equal(t, []string{fakeMirrordManifest}, runner.stdin)
+
+	data, err := os.ReadFile(filepath.Join(f.controller.mirrordDir, ".mirrord", "mirrord.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"kube_context": "kind-kind"`)
+	assert.Contains(t, string(data), `"operator": true`)
+
+	_, err = f.fakeK8s.CoreV1().Services("mirrord").Create(context.Background(), &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "mirrord-operator", Namespace: "mirrord"},
+		Spec:       v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 443}}},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	c, err := f.controller.Get(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.True(t, c.MirrordEnabled)
+	assert.Equal(t, "https://mirrord-operator.mirrord.svc:443", c.Status.MirrordEndpoint)
+
+	// Applying again doesn't re-install the operator.
+	runner.calls = nil
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Empty(t, runner.calls)
+}
+
+func TestMirrordConfigKeepsOtherSettings(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".mirrord", "mirrord.json")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(`{"target": "deploy/api", "kube_context": "old"}`), 0644))
+
+	a := newMirrordAdmin(nil, newFixture(t).controller.iostreams, &kubectlRunner{}, "/home/nick/.kube/config", dir)
+	require.NoError(t, a.writeConfig("kind-kind"))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "kube_context": "kind-kind",
+  "kubeconfig": "/home/nick/.kube/config",
+  "operator": true,
+  "target": "deploy/api"
+}
+`, string(data))
+}
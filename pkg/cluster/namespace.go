@@ -0,0 +1,58 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func validateDefaultNamespace(desired *api.Cluster) error {
+	errs := validation.IsDNS1123Label(desired.DefaultNamespace)
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid defaultNamespace %q: %s", desired.DefaultNamespace, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Sets the namespace of the cluster's kubectl context, and creates
+// the namespace if it doesn't exist.
+func (c *Controller) ensureDefaultNamespace(ctx context.Context, cluster *api.Cluster) error {
+	if cluster.DefaultNamespace == "" {
+		return nil
+	}
+
+	client, err := c.client(cluster.Name)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: cluster.DefaultNamespace},
+	}, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating namespace %s: %v", cluster.DefaultNamespace, err)
+	}
+
+	contextName := c.contextName(cluster.Name)
+	config := c.configCopy()
+	ct, ok := config.Contexts[contextName]
+	if !ok {
+		return fmt.Errorf("cluster %s: kubectl context not found", cluster.Name)
+	}
+	if ct.Namespace == cluster.DefaultNamespace {
+		return nil
+	}
+
+	err = c.configWriter.SetConfig(fmt.Sprintf("contexts.%s.namespace", contextName), cluster.DefaultNamespace)
+	if err != nil {
+		return fmt.Errorf("setting default namespace: %v", err)
+	}
+	return c.reloadConfigs()
+}
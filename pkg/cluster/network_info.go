@@ -0,0 +1,179 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// ClusterNetworkInfo describes the Docker network that a cluster's nodes run in.
+type ClusterNetworkInfo struct {
+	NetworkName string                  `json:"networkName"`
+	Subnet      string                  `json:"subnet,omitempty"`
+	Gateway     string                  `json:"gateway,omitempty"`
+	Containers  []ContainerNetworkEntry `json:"containers"`
+}
+
+// ContainerNetworkEntry is a container attached to the cluster network.
+type ContainerNetworkEntry struct {
+	ContainerName string `json:"containerName"`
+	IP            string `json:"ip,omitempty"`
+
+	// One of control-plane, worker, load-balancer, or other (e.g., a registry).
+	Role string `json:"role"`
+}
+
+// The subset of `docker network inspect` output that we read.
+type dockerNetworkInspect struct {
+	Name string
+	IPAM struct {
+		Config []struct {
+			Subnet  string
+			Gateway string
+		}
+	}
+	Containers map[string]struct {
+		Name        string
+		IPv4Address string
+	}
+}
+
+// kind numbers additional nodes after the cluster name (e.g., kind-worker2).
+var kindControlPlaneSuffix = regexp.MustCompile(`^-control-plane\d*$`)
+var kindWorkerSuffix = regexp.MustCompile(`^-worker\d*$`)
+
+// GetNetworkInfo returns the Docker network that the named cluster runs in,
+// and the containers attached to it.
+//
+// Docker Desktop clusters don't have a dedicated network,
+// so we return the host network instead.
+func (c *Controller) GetNetworkInfo(ctx context.Context, clusterName string) (*ClusterNetworkInfo, error) {
+	product, err := c.productFromConfig(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	networkName, err := clusterNetworkName(product, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	out := bytes.NewBuffer(nil)
+	errOut := bytes.NewBuffer(nil)
+	err = c.runner.RunIO(ctx, genericclioptions.IOStreams{Out: out, ErrOut: errOut},
+		"docker", "network", "inspect", networkName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "inspecting network %s for cluster %s: %s",
+			networkName, clusterName, strings.TrimSpace(errOut.String()))
+	}
+
+	info, err := parseNetworkInspect(out.Bytes())
+	if err != nil {
+		return nil, errors.Wrapf(err, "inspecting network %s for cluster %s", networkName, clusterName)
+	}
+	for i, entry := range info.Containers {
+		info.Containers[i].Role = containerNetworkRole(product, clusterName, entry.ContainerName)
+	}
+	return info, nil
+}
+
+// The name of the Docker network that each product creates for a cluster.
+func clusterNetworkName(product clusterid.Product, clusterName string) (string, error) {
+	switch product {
+	case clusterid.ProductKIND:
+		return kindNetworkName(), nil
+	case clusterid.ProductK3D:
+		return clusterName, nil
+	case clusterid.ProductMinikube:
+		// Minikube v0.15.0+ creates a network named after the profile.
+		return clusterName, nil
+	case clusterid.ProductDockerDesktop:
+		return "host", nil
+	}
+	return "", fmt.Errorf("cluster %s: network info not supported for product %s", clusterName, product)
+}
+
+func parseNetworkInspect(data []byte) (*ClusterNetworkInfo, error) {
+	networks := []dockerNetworkInspect{}
+	err := json.Unmarshal(data, &networks)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing docker network inspect")
+	}
+	if len(networks) != 1 {
+		return nil, fmt.Errorf("expected 1 network from docker network inspect. Actual: %d", len(networks))
+	}
+
+	network := networks[0]
+	info := &ClusterNetworkInfo{
+		NetworkName: network.Name,
+		Containers:  []ContainerNetworkEntry{},
+	}
+	if len(network.IPAM.Config) > 0 {
+		info.Subnet = network.IPAM.Config[0].Subnet
+		info.Gateway = network.IPAM.Config[0].Gateway
+	}
+	for _, container := range network.Containers {
+		// Docker reports addresses in CIDR notation.
+		ip, _, _ := strings.Cut(container.IPv4Address, "/")
+		info.Containers = append(info.Containers, ContainerNetworkEntry{
+			ContainerName: container.Name,
+			IP:            ip,
+		})
+	}
+	sort.Slice(info.Containers, func(i, j int) bool {
+		return info.Containers[i].ContainerName < info.Containers[j].ContainerName
+	})
+	return info, nil
+}
+
+// Guesses the role of a container on the cluster network from its name,
+// following each product's naming scheme.
+//
+// Networks can be shared (e.g., every kind cluster uses the "kind" network),
+// so containers from other clusters are reported as "other".
+func containerNetworkRole(product clusterid.Product, clusterName, containerName string) string {
+	switch product {
+	case clusterid.ProductKIND:
+		prefix := strings.TrimPrefix(clusterName, "kind-")
+		rest := strings.TrimPrefix(containerName, prefix)
+		if rest == containerName {
+			return "other"
+		}
+		switch {
+		case kindControlPlaneSuffix.MatchString(rest):
+			return "control-plane"
+		case kindWorkerSuffix.MatchString(rest):
+			return "worker"
+		case rest == "-external-load-balancer":
+			return "load-balancer"
+		}
+
+	case clusterid.ProductK3D:
+		switch {
+		case strings.HasPrefix(containerName, clusterName+"-server-"):
+			return "control-plane"
+		case strings.HasPrefix(containerName, clusterName+"-agent-"):
+			return "worker"
+		case containerName == clusterName+"-serverlb":
+			return "load-balancer"
+		}
+
+	case clusterid.ProductMinikube:
+		// Minikube names additional nodes <profile>-m02, <profile>-m03, etc.
+		switch {
+		case containerName == clusterName:
+			return "control-plane"
+		case strings.HasPrefix(containerName, clusterName+"-m"):
+			return "worker"
+		}
+	}
+	return "other"
+}
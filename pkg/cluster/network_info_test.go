@@ -0,0 +1,156 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+)
+
+// Trimmed from `docker network inspect kind`.
+const kindNetworkInspect = `[
+    {
+        "Name": "kind",
+        "Id": "3c5bd4c0e2bd4e2f0fbbd3b3a1c8c0b4c7f5b6e0d3f5a7c9c8b1d4e6f2a3b5c7",
+        "Scope": "local",
+        "Driver": "bridge",
+        "EnableIPv6": true,
+        "IPAM": {
+            "Driver": "default",
+            "Options": {},
+            "Config": [
+                {
+                    "Subnet": "172.18.0.0/16",
+                    "Gateway": "172.18.0.1"
+                },
+                {
+                    "Subnet": "fc00:f853:ccd:e793::/64",
+                    "Gateway": "fc00:f853:ccd:e793::1"
+                }
+            ]
+        },
+        "Containers": {
+            "0b1c": {
+                "Name": "foo-worker",
+                "EndpointID": "e1",
+                "MacAddress": "02:42:ac:12:00:02",
+                "IPv4Address": "172.18.0.2/16",
+                "IPv6Address": "fc00:f853:ccd:e793::2/64"
+            },
+            "1c2d": {
+                "Name": "foo-control-plane",
+                "EndpointID": "e2",
+                "MacAddress": "02:42:ac:12:00:03",
+                "IPv4Address": "172.18.0.3/16",
+                "IPv6Address": "fc00:f853:ccd:e793::3/64"
+            },
+            "2d3e": {
+                "Name": "ctlptl-registry",
+                "EndpointID": "e3",
+                "MacAddress": "02:42:ac:12:00:04",
+                "IPv4Address": "172.18.0.4/16",
+                "IPv6Address": ""
+            },
+            "3e4f": {
+                "Name": "bar-control-plane",
+                "EndpointID": "e4",
+                "MacAddress": "02:42:ac:12:00:05",
+                "IPv4Address": "172.18.0.5/16",
+                "IPv6Address": ""
+            }
+        },
+        "Options": {
+            "com.docker.network.bridge.enable_ip_masquerade": "true"
+        },
+        "Labels": {}
+    }
+]`
+
+func TestGetNetworkInfoKind(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+
+	var lastArgs []string
+	f.controller.runner = exec.NewFakeCmdRunner(func(argv []string) string {
+		lastArgs = argv
+		return kindNetworkInspect
+	})
+
+	info, err := f.controller.GetNetworkInfo(context.Background(), "kind-foo")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docker", "network", "inspect", "kind"}, lastArgs)
+	assert.Equal(t, &ClusterNetworkInfo{
+		NetworkName: "kind",
+		Subnet:      "172.18.0.0/16",
+		Gateway:     "172.18.0.1",
+		Containers: []ContainerNetworkEntry{
+			{ContainerName: "bar-control-plane", IP: "172.18.0.5", Role: "other"},
+			{ContainerName: "ctlptl-registry", IP: "172.18.0.4", Role: "other"},
+			{ContainerName: "foo-control-plane", IP: "172.18.0.3", Role: "control-plane"},
+			{ContainerName: "foo-worker", IP: "172.18.0.2", Role: "worker"},
+		},
+	}, info)
+}
+
+func TestGetNetworkInfoDockerDesktop(t *testing.T) {
+	f := newFixture(t)
+
+	var lastArgs []string
+	f.controller.runner = exec.NewFakeCmdRunner(func(argv []string) string {
+		lastArgs = argv
+		return `[{"Name": "host", "Driver": "host", "IPAM": {"Config": []}, "Containers": {}}]`
+	})
+
+	info, err := f.controller.GetNetworkInfo(context.Background(), "docker-desktop")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docker", "network", "inspect", "host"}, lastArgs)
+	assert.Equal(t, &ClusterNetworkInfo{NetworkName: "host", Containers: []ContainerNetworkEntry{}}, info)
+}
+
+func TestGetNetworkInfoUnsupportedProduct(t *testing.T) {
+	f := newFixture(t)
+	_, err := f.controller.GetNetworkInfo(context.Background(), "microk8s")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "network info not supported for product microk8s")
+	}
+}
+
+func TestParseNetworkInspectMalformed(t *testing.T) {
+	_, err := parseNetworkInspect([]byte(`[]`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "expected 1 network from docker network inspect. Actual: 0")
+	}
+
+	_, err = parseNetworkInspect([]byte(`Error: No such network: kind`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "parsing docker network inspect")
+	}
+}
+
+func TestContainerNetworkRole(t *testing.T) {
+	for _, tc := range []struct {
+		product       clusterid.Product
+		clusterName   string
+		containerName string
+		role          string
+	}{
+		{clusterid.ProductKIND, "kind-kind", "kind-control-plane2", "control-plane"},
+		{clusterid.ProductKIND, "kind-kind", "kind-worker3", "worker"},
+		{clusterid.ProductKIND, "kind-kind", "kind-external-load-balancer", "load-balancer"},
+		{clusterid.ProductKIND, "kind-kind", "kind-registry", "other"},
+		{clusterid.ProductK3D, "k3d-dev", "k3d-dev-server-0", "control-plane"},
+		{clusterid.ProductK3D, "k3d-dev", "k3d-dev-agent-1", "worker"},
+		{clusterid.ProductK3D, "k3d-dev", "k3d-dev-serverlb", "load-balancer"},
+		{clusterid.ProductMinikube, "minikube", "minikube", "control-plane"},
+		{clusterid.ProductMinikube, "minikube", "minikube-m02", "worker"},
+	} {
+		t.Run(fmt.Sprintf("%s/%s", tc.product, tc.containerName), func(t *testing.T) {
+			assert.Equal(t, tc.role, containerNetworkRole(tc.product, tc.clusterName, tc.containerName))
+		})
+	}
+}
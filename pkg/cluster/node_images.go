@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/docker/distribution/reference"
+	"github.com/tilt-dev/clusterid"
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func validateNodeImages(desired *api.Cluster) error {
+	product := clusterid.Product(desired.Product)
+	if product != clusterid.ProductKIND && product != clusterid.ProductK3D {
+		return fmt.Errorf("kindControlPlaneImage and kindWorkerImage may only be set on clusters with product: kind or k3d. Actual product: %s",
+			desired.Product)
+	}
+	if desired.KindOptions != nil && desired.KindOptions.NodeImage != "" {
+		return fmt.Errorf("kindOptions.nodeImage may not be set with kindControlPlaneImage or kindWorkerImage")
+	}
+
+	for field, image := range map[string]string{
+		"kindControlPlaneImage": desired.KindControlPlaneImage,
+		"kindWorkerImage":       desired.KindWorkerImage,
+	} {
+		if image == "" {
+			continue
+		}
+		_, err := reference.ParseNormalizedNamed(image)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %v", field, image, err)
+		}
+	}
+
+	controlPlane, worker := nodeImages(desired)
+	if product == clusterid.ProductK3D && controlPlane != worker {
+		return fmt.Errorf("k3d clusters have no worker nodes, so kindWorkerImage must match kindControlPlaneImage")
+	}
+	return nil
+}
+
+// The images for the control-plane and worker nodes.
+//
+// When only one is set, the other defaults to the same image.
+func nodeImages(cluster *api.Cluster) (controlPlane string, worker string) {
+	controlPlane, worker = cluster.KindControlPlaneImage, cluster.KindWorkerImage
+	if controlPlane == "" {
+		controlPlane = worker
+	}
+	if worker == "" {
+		worker = controlPlane
+	}
+	return controlPlane, worker
+}
+
+// Sets the image of each node in the kind config by its role.
+//
+// With no nodes, kind creates a single control-plane node, so we add it
+// explicitly to give it an image.
+func addKindNodeImages(kindConfig *v1alpha4.Cluster, desired *api.Cluster) {
+	controlPlane, worker := nodeImages(desired)
+	if controlPlane == "" {
+		return
+	}
+
+	if len(kindConfig.Nodes) == 0 {
+		kindConfig.Nodes = []v1alpha4.Node{{Role: v1alpha4.ControlPlaneRole}}
+	}
+	for i, node := range kindConfig.Nodes {
+		if node.Image != "" {
+			continue
+		}
+		if node.Role == v1alpha4.WorkerRole {
+			kindConfig.Nodes[i].Image = worker
+		} else {
+			kindConfig.Nodes[i].Image = controlPlane
+		}
+	}
+}
+
+func nodeImagesEqual(a, b *api.Cluster) bool {
+	aControlPlane, aWorker := nodeImages(a)
+	bControlPlane, bWorker := nodeImages(b)
+	return aControlPlane == bControlPlane && aWorker == bWorker
+}
@@ -0,0 +1,150 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"gopkg.in/yaml.v3"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestKindConfigNodeImages(t *testing.T) {
+	iostreams := genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
+	a := newKindAdmin(iostreams, &fakeDockerClient{})
+
+	data, err := a.kindClusterConfigYAML(&api.Cluster{
+		Product:               string(clusterid.ProductKIND),
+		KindControlPlaneImage: "kindest/node:v1.29.2",
+		KindWorkerImage:       "kindest/node:v1.28.7",
+		KindV1Alpha4Cluster: &v1alpha4.Cluster{
+			Nodes: []v1alpha4.Node{
+				{Role: v1alpha4.ControlPlaneRole},
+				{Role: v1alpha4.WorkerRole},
+				{Role: v1alpha4.WorkerRole, Image: "my-node:custom"},
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	var config struct {
+		Nodes []struct {
+			Role  string `yaml:"role"`
+			Image string `yaml:"image"`
+		} `yaml:"nodes"`
+	}
+	require.NoError(t, yaml.Unmarshal(data, &config))
+	require.Len(t, config.Nodes, 3)
+	assert.Equal(t, "control-plane", config.Nodes[0].Role)
+	assert.Equal(t, "kindest/node:v1.29.2", config.Nodes[0].Image)
+	assert.Equal(t, "worker", config.Nodes[1].Role)
+	assert.Equal(t, "kindest/node:v1.28.7", config.Nodes[1].Image)
+	assert.Equal(t, "my-node:custom", config.Nodes[2].Image)
+}
+
+func TestKindConfigNodeImagesDefault(t *testing.T) {
+	iostreams := genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
+	a := newKindAdmin(iostreams, &fakeDockerClient{})
+
+	// Without nodes, the single control-plane node gets the worker image.
+	config := a.kindClusterConfig(&api.Cluster{
+		Product:         string(clusterid.ProductKIND),
+		KindWorkerImage: "kindest/node:v1.28.7",
+	}, nil)
+	assert.Equal(t, []v1alpha4.Node{
+		{Role: v1alpha4.ControlPlaneRole, Image: "kindest/node:v1.28.7"},
+	}, config.Nodes)
+}
+
+func TestK3dCreateArgsNodeImages(t *testing.T) {
+	a := newK3dAdmin(genericclioptions.IOStreams{})
+	args := a.createArgs(&api.Cluster{
+		Name:                  "k3d-foo",
+		KindControlPlaneImage: "rancher/k3s:v1.29.2-k3s1",
+	}, nil)
+	assert.Equal(t, []string{"cluster", "create", "foo", "--image", "rancher/k3s:v1.29.2-k3s1"}, args)
+}
+
+func TestValidateNodeImages(t *testing.T) {
+	err := validateNodeImages(&api.Cluster{
+		Product:               string(clusterid.ProductKIND),
+		KindControlPlaneImage: "kindest/node:v1.29.2",
+		KindWorkerImage:       "kindest/node:v1.28.7@sha256:9bc6c451a289cf96ad0bbaf33d416901de6fd632415b076ab05f5fa7e4f65c58",
+	})
+	assert.NoError(t, err)
+
+	err = validateNodeImages(&api.Cluster{
+		Product:         string(clusterid.ProductKIND),
+		KindWorkerImage: "kindest/node:V1.28!",
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid kindWorkerImage "kindest/node:V1.28!"`)
+	}
+
+	err = validateNodeImages(&api.Cluster{
+		Product:               string(clusterid.ProductMinikube),
+		KindControlPlaneImage: "kindest/node:v1.29.2",
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "may only be set on clusters with product: kind or k3d")
+	}
+
+	err = validateNodeImages(&api.Cluster{
+		Product:               string(clusterid.ProductKIND),
+		KindControlPlaneImage: "kindest/node:v1.29.2",
+		KindOptions:           &api.KindOptions{NodeImage: "kindest/node:v1.29.2"},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "kindOptions.nodeImage may not be set with kindControlPlaneImage")
+	}
+
+	err = validateNodeImages(&api.Cluster{
+		Product:               string(clusterid.ProductK3D),
+		KindControlPlaneImage: "rancher/k3s:v1.29.2-k3s1",
+		KindWorkerImage:       "rancher/k3s:v1.28.7-k3s1",
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "kindWorkerImage must match kindControlPlaneImage")
+	}
+}
+
+func TestClusterApplyNodeImages(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	kindAdmin := f.newFakeAdmin(clusterid.ProductKIND)
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:               string(clusterid.ProductKIND),
+		KindControlPlaneImage: "kindest/node:v1.29.2",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	kindAdmin.created = nil
+
+	// Spelling out the default worker image doesn't re-create.
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:               string(clusterid.ProductKIND),
+		KindControlPlaneImage: "kindest/node:v1.29.2",
+		KindWorkerImage:       "kindest/node:v1.29.2",
+	})
+	require.NoError(t, err)
+	assert.Nil(t, kindAdmin.created)
+
+	f.errOut.Truncate(0)
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:               string(clusterid.ProductKIND),
+		KindControlPlaneImage: "kindest/node:v1.29.2",
+		KindWorkerImage:       "kindest/node:v1.28.7",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.deleted.Name)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+	assert.Contains(t, f.errOut.String(),
+		"Deleting cluster kind-kind to change node images to kindest/node:v1.29.2 (control plane) and kindest/node:v1.28.7 (workers)")
+}
@@ -0,0 +1,222 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/tilt-dev/clusterid"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Labels that kind attaches to each node container.
+const (
+	kindClusterLabel = "io.x-k8s.kind.cluster"
+	kindRoleLabel    = "io.x-k8s.kind.role"
+)
+
+// Labels that k3d attaches to each node container.
+const (
+	k3dClusterLabel = "k3d.cluster"
+	k3dRoleLabel    = "k3d.role"
+)
+
+// Reads the product of the named cluster from the kubeconfig,
+// without contacting the cluster itself.
+func (c *Controller) productFromConfig(name string) (clusterid.Product, error) {
+	config := c.configCopy()
+	ct, ok := config.Contexts[c.contextName(name)]
+	if !ok {
+		return "", apierrors.NewNotFound(groupResource, name)
+	}
+	configCluster, ok := config.Clusters[ct.Cluster]
+	if !ok {
+		return "", apierrors.NewNotFound(groupResource, name)
+	}
+	return productOfContext(c.externalContexts(), c.contextName(name), ct, configCluster), nil
+}
+
+// Finds the Docker containers that run the nodes of the given cluster.
+//
+// If controlPlaneOnly is set, only returns the control-plane nodes.
+// Containers are sorted by name.
+func (c *Controller) nodeContainers(ctx context.Context, clusterName string, controlPlaneOnly bool) ([]types.Container, error) {
+	product, err := c.productFromConfig(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	args := filters.NewArgs()
+	switch product {
+	case clusterid.ProductKIND:
+		args.Add("label", fmt.Sprintf("%s=%s", kindClusterLabel, strings.TrimPrefix(clusterName, "kind-")))
+		if controlPlaneOnly {
+			args.Add("label", fmt.Sprintf("%s=control-plane", kindRoleLabel))
+		}
+	case clusterid.ProductK3D:
+		args.Add("label", fmt.Sprintf("%s=%s", k3dClusterLabel, strings.TrimPrefix(clusterName, "k3d-")))
+		if controlPlaneOnly {
+			args.Add("label", fmt.Sprintf("%s=server", k3dRoleLabel))
+		}
+	default:
+		return nil, fmt.Errorf("cluster %s: node containers not supported for product %s", clusterName, product)
+	}
+
+	dockerClient, err := c.getDockerClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := dockerClient.ContainerList(ctx, types.ContainerListOptions{Filters: args})
+	if err != nil {
+		return nil, err
+	}
+
+	if product == clusterid.ProductK3D {
+		// Skip the loadbalancer and tools containers.
+		nodes := []types.Container{}
+		for _, container := range containers {
+			role := container.Labels[k3dRoleLabel]
+			if role == "server" || role == "agent" {
+				nodes = append(nodes, container)
+			}
+		}
+		containers = nodes
+	}
+
+	sort.Slice(containers, func(i, j int) bool {
+		return containerName(containers[i]) < containerName(containers[j])
+	})
+	return containers, nil
+}
+
+func containerName(container types.Container) string {
+	if len(container.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(container.Names[0], "/")
+}
+
+// GetContainerID returns the full Docker container ID of the named node in the
+// named cluster.
+//
+// If nodeName is empty, returns the ID of the first control-plane node.
+func (c *Controller) GetContainerID(ctx context.Context, clusterName, nodeName string) (string, error) {
+	containers, err := c.nodeContainers(ctx, clusterName, nodeName == "")
+	if err != nil {
+		return "", err
+	}
+
+	for _, container := range containers {
+		if nodeName == "" || containerName(container) == nodeName {
+			return container.ID, nil
+		}
+	}
+
+	if nodeName == "" {
+		return "", fmt.Errorf("cluster %s: no control-plane node found", clusterName)
+	}
+	return "", fmt.Errorf("cluster %s: node %q not found", clusterName, nodeName)
+}
+
+// GetNodeIP returns the InternalIP of the named node in the named cluster,
+// as reported by the Kubernetes API.
+//
+// If nodeName is empty, returns the IP of the first control-plane node.
+//
+// On kind clusters, also checks that the IP belongs to the node's container,
+// so that scripts don't build endpoints from a stale or misreported address.
+func (c *Controller) GetNodeIP(ctx context.Context, clusterName, nodeName string) (net.IP, error) {
+	client, err := c.client(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %v", err)
+	}
+	sort.Slice(nodes.Items, func(i, j int) bool {
+		return nodes.Items[i].Name < nodes.Items[j].Name
+	})
+
+	var node *corev1.Node
+	for i := range nodes.Items {
+		n := &nodes.Items[i]
+		if (nodeName == "" && isControlPlaneNode(n)) || n.Name == nodeName {
+			node = n
+			break
+		}
+	}
+	if node == nil {
+		if nodeName == "" {
+			return nil, fmt.Errorf("cluster %s: no control-plane node found", clusterName)
+		}
+		return nil, fmt.Errorf("cluster %s: node %q not found", clusterName, nodeName)
+	}
+
+	var ip net.IP
+	for _, address := range node.Status.Addresses {
+		if address.Type == corev1.NodeInternalIP {
+			ip = net.ParseIP(address.Address)
+			if ip != nil {
+				break
+			}
+		}
+	}
+	if ip == nil {
+		return nil, fmt.Errorf("cluster %s: node %s has no InternalIP", clusterName, node.Name)
+	}
+
+	product, err := c.productFromConfig(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if product == clusterid.ProductKIND {
+		err := c.verifyNodeContainerIP(ctx, clusterName, node.Name, ip)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ip, nil
+}
+
+// Checks that the node's container has the given IP on one of its networks.
+func (c *Controller) verifyNodeContainerIP(ctx context.Context, clusterName, nodeName string, ip net.IP) error {
+	id, err := c.GetContainerID(ctx, clusterName, nodeName)
+	if err != nil {
+		return err
+	}
+
+	dockerClient, err := c.getDockerClient(ctx)
+	if err != nil {
+		return err
+	}
+	container, err := dockerClient.ContainerInspect(ctx, id)
+	if err != nil {
+		return fmt.Errorf("inspecting node %s: %v", nodeName, err)
+	}
+
+	containerIPs := []string{}
+	if container.NetworkSettings != nil {
+		for _, network := range container.NetworkSettings.Networks {
+			for _, containerIP := range []string{network.IPAddress, network.GlobalIPv6Address} {
+				if containerIP == "" {
+					continue
+				}
+				if ip.Equal(net.ParseIP(containerIP)) {
+					return nil
+				}
+				containerIPs = append(containerIPs, containerIP)
+			}
+		}
+	}
+	return fmt.Errorf("cluster %s: node %s has InternalIP %s, but its container has IPs [%s]",
+		clusterName, nodeName, ip, strings.Join(containerIPs, ", "))
+}
@@ -0,0 +1,136 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestGetContainerID(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+
+	ctx := context.Background()
+	id, err := f.controller.GetContainerID(ctx, "kind-foo", "")
+	require.NoError(t, err)
+	assert.Equal(t, "foo-control-plane-id", id)
+
+	id, err = f.controller.GetContainerID(ctx, "kind-foo", "foo-worker")
+	require.NoError(t, err)
+	assert.Equal(t, "foo-worker-id", id)
+
+	_, err = f.controller.GetContainerID(ctx, "kind-foo", "foo-worker2")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `node "foo-worker2" not found`)
+	}
+}
+
+func TestGetContainerIDUnsupportedProduct(t *testing.T) {
+	f := newFixture(t)
+	_, err := f.controller.GetContainerID(context.Background(), "docker-desktop", "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not supported for product docker-desktop")
+	}
+}
+
+func TestGetNodeIP(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+	f.addNode("foo-control-plane", "172.18.0.3", "node-role.kubernetes.io/control-plane")
+	f.addNode("foo-worker", "172.18.0.2")
+	f.dockerClient.containerIPs = map[string]string{
+		"foo-control-plane-id": "172.18.0.3",
+		"foo-worker-id":        "172.18.0.2",
+	}
+
+	ctx := context.Background()
+	ip, err := f.controller.GetNodeIP(ctx, "kind-foo", "")
+	require.NoError(t, err)
+	assert.Equal(t, "172.18.0.3", ip.String())
+
+	ip, err = f.controller.GetNodeIP(ctx, "kind-foo", "foo-worker")
+	require.NoError(t, err)
+	assert.Equal(t, "172.18.0.2", ip.String())
+
+	_, err = f.controller.GetNodeIP(ctx, "kind-foo", "foo-worker2")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `node "foo-worker2" not found`)
+	}
+}
+
+func TestGetNodeIPContainerMismatch(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+	f.addNode("foo-control-plane", "172.18.0.3", "node-role.kubernetes.io/control-plane")
+	f.dockerClient.containerIPs = map[string]string{"foo-control-plane-id": "172.18.0.4"}
+
+	_, err := f.controller.GetNodeIP(context.Background(), "kind-foo", "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "node foo-control-plane has InternalIP 172.18.0.3, but its container has IPs [172.18.0.4]")
+	}
+}
+
+func TestGetNodeIPNoControlPlane(t *testing.T) {
+	f := newFixture(t)
+	_, err := f.controller.GetNodeIP(context.Background(), "microk8s", "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cluster microk8s: no control-plane node found")
+	}
+
+	// Non-kind clusters trust the Kubernetes API.
+	f.addNode("microk8s-node", "10.0.0.5", "node-role.kubernetes.io/master")
+	ip, err := f.controller.GetNodeIP(context.Background(), "microk8s", "")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.5", ip.String())
+}
+
+// Adds a node to the Kubernetes API with the given InternalIP and role labels.
+func (f *fixture) addNode(name, ip string, labels ...string) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeHostName, Address: name},
+				{Type: corev1.NodeInternalIP, Address: ip},
+			},
+		},
+	}
+	for _, label := range labels {
+		node.Labels[label] = ""
+	}
+	_, err := f.fakeK8s.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+	require.NoError(f.t, err)
+}
+
+// Adds a kind cluster to the kubeconfig, with a control-plane and a worker
+// node running in Docker.
+func (f *fixture) setupKindNodes(name string) {
+	kindName := name[len("kind-"):]
+	f.controller.config.Contexts[name] = &clientcmdapi.Context{Cluster: name}
+	f.controller.config.Clusters[name] = &clientcmdapi.Cluster{Server: "https://127.0.0.1:6443"}
+	f.config.Contexts[name] = &clientcmdapi.Context{Cluster: name}
+	f.config.Clusters[name] = &clientcmdapi.Cluster{Server: "https://127.0.0.1:6443"}
+	f.dockerClient.containers = append(f.dockerClient.containers,
+		types.Container{
+			ID:    kindName + "-worker-id",
+			Names: []string{"/" + kindName + "-worker"},
+			Labels: map[string]string{
+				kindClusterLabel: kindName,
+				kindRoleLabel:    "worker",
+			},
+		},
+		types.Container{
+			ID:    kindName + "-control-plane-id",
+			Names: []string{"/" + kindName + "-control-plane"},
+			Labels: map[string]string{
+				kindClusterLabel: kindName,
+				kindRoleLabel:    "control-plane",
+			},
+		})
+}
@@ -0,0 +1,136 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	OpenAPIV2 = "v2"
+	OpenAPIV3 = "v3"
+)
+
+// GetOpenAPISpec returns the OpenAPI spec of the cluster's apiserver as JSON,
+// including the schemas of installed CRDs.
+//
+// Prefers OpenAPI v3, and falls back to v2 on clusters that don't serve v3
+// (before Kubernetes 1.24, or with the OpenAPIV3 feature gate off).
+func (c *Controller) GetOpenAPISpec(ctx context.Context, clusterName string) ([]byte, error) {
+	return c.GetOpenAPISpecVersion(ctx, clusterName, "")
+}
+
+// GetOpenAPISpecVersion returns the OpenAPI spec of the cluster's apiserver
+// with the given version (v2 or v3). An empty version means v3 if the cluster
+// serves it, else v2.
+func (c *Controller) GetOpenAPISpecVersion(ctx context.Context, clusterName, version string) ([]byte, error) {
+	if version != "" && version != OpenAPIV2 && version != OpenAPIV3 {
+		return nil, fmt.Errorf("OpenAPI version must be one of: v2, v3. Actual: %s", version)
+	}
+
+	client, err := c.client(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	restClient := client.Discovery().RESTClient()
+	if restClient == nil {
+		return nil, fmt.Errorf("cluster %s: no REST client to fetch the OpenAPI spec", clusterName)
+	}
+
+	if version != OpenAPIV2 {
+		spec, err := openAPIV3Spec(ctx, restClient)
+		if err == nil {
+			return spec, nil
+		}
+		if version == OpenAPIV3 || !apierrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "cluster %s: fetching OpenAPI v3 spec", clusterName)
+		}
+	}
+
+	spec, err := restClient.Get().AbsPath("/openapi/v2").SetHeader("Accept", "application/json").Do(ctx).Raw()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cluster %s: fetching OpenAPI v2 spec", clusterName)
+	}
+	return spec, nil
+}
+
+// The apiserver serves OpenAPI v3 as one document per group version, listed
+// in a discovery document at /openapi/v3. Merges them into one document, so
+// that code generators can read it like a v2 spec.
+func openAPIV3Spec(ctx context.Context, restClient rest.Interface) ([]byte, error) {
+	body, err := restClient.Get().AbsPath("/openapi/v3").Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	var discovery struct {
+		Paths map[string]struct {
+			ServerRelativeURL string `json:"serverRelativeURL"`
+		} `json:"paths"`
+	}
+	err = json.Unmarshal(body, &discovery)
+	if err != nil {
+		return nil, fmt.Errorf("decoding /openapi/v3: %v", err)
+	}
+
+	groupVersions := make([]string, 0, len(discovery.Paths))
+	for gv := range discovery.Paths {
+		groupVersions = append(groupVersions, gv)
+	}
+	sort.Strings(groupVersions)
+
+	merged := map[string]interface{}{}
+	paths := map[string]interface{}{}
+	components := map[string]map[string]interface{}{}
+	for _, gv := range groupVersions {
+		u := discovery.Paths[gv].ServerRelativeURL
+		if u == "" {
+			u = "/openapi/v3/" + gv
+		}
+
+		req := restClient.Get().SetHeader("Accept", "application/json")
+		body, err := req.RequestURI(u).Do(ctx).Raw()
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s: %v", gv, err)
+		}
+
+		var doc map[string]interface{}
+		err = json.Unmarshal(body, &doc)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %v", gv, err)
+		}
+
+		if _, ok := merged["openapi"]; !ok {
+			merged["openapi"] = doc["openapi"]
+			merged["info"] = doc["info"]
+		}
+		if docPaths, ok := doc["paths"].(map[string]interface{}); ok {
+			for k, v := range docPaths {
+				paths[k] = v
+			}
+		}
+		if docComponents, ok := doc["components"].(map[string]interface{}); ok {
+			for kind, entries := range docComponents {
+				entries, ok := entries.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if components[kind] == nil {
+					components[kind] = map[string]interface{}{}
+				}
+				for k, v := range entries {
+					components[kind][k] = v
+				}
+			}
+		}
+	}
+
+	merged["paths"] = paths
+	merged["components"] = components
+	return json.Marshal(merged)
+}
@@ -0,0 +1,88 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Points the controller's clients at a fake apiserver.
+func (f *fixture) useAPIServer(handler http.Handler) {
+	server := httptest.NewServer(handler)
+	f.t.Cleanup(server.Close)
+	f.controller.clientLoader = func(restConfig *rest.Config) (kubernetes.Interface, error) {
+		restConfig.Host = server.URL
+		return kubernetes.NewForConfig(restConfig)
+	}
+}
+
+func TestGetOpenAPISpecV3(t *testing.T) {
+	f := newFixture(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openapi/v3", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"paths": {
+  "api/v1": {"serverRelativeURL": "/openapi/v3/api/v1?hash=abc"},
+  "apis/example.com/v1": {"serverRelativeURL": "/openapi/v3/apis/example.com/v1?hash=def"}
+}}`)
+	})
+	mux.HandleFunc("/openapi/v3/api/v1", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "abc", r.URL.Query().Get("hash"))
+		_, _ = fmt.Fprint(w, `{"openapi": "3.0.0", "info": {"title": "Kubernetes", "version": "v1.27.3"},
+"paths": {"/api/v1/pods": {}},
+"components": {"schemas": {"io.k8s.api.core.v1.Pod": {"type": "object"}}}}`)
+	})
+	mux.HandleFunc("/openapi/v3/apis/example.com/v1", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"openapi": "3.0.0", "info": {"title": "Kubernetes", "version": "v1.27.3"},
+"paths": {"/apis/example.com/v1/widgets": {}},
+"components": {"schemas": {"com.example.v1.Widget": {"type": "object"}}, "securitySchemes": {"BearerToken": {"type": "apiKey"}}}}`)
+	})
+	f.useAPIServer(mux)
+
+	spec, err := f.controller.GetOpenAPISpec(context.Background(), "docker-desktop")
+	require.NoError(t, err)
+
+	var doc struct {
+		OpenAPI    string                            `json:"openapi"`
+		Paths      map[string]interface{}            `json:"paths"`
+		Components map[string]map[string]interface{} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(spec, &doc))
+	assert.Equal(t, "3.0.0", doc.OpenAPI)
+	assert.Contains(t, doc.Paths, "/api/v1/pods")
+	assert.Contains(t, doc.Paths, "/apis/example.com/v1/widgets")
+	assert.Contains(t, doc.Components["schemas"], "io.k8s.api.core.v1.Pod")
+	assert.Contains(t, doc.Components["schemas"], "com.example.v1.Widget")
+	assert.Contains(t, doc.Components["securitySchemes"], "BearerToken")
+}
+
+func TestGetOpenAPISpecFallsBackToV2(t *testing.T) {
+	f := newFixture(t)
+	v2 := `{"swagger": "2.0", "definitions": {"com.example.v1.Widget": {}}}`
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openapi/v2", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, v2)
+	})
+	f.useAPIServer(mux)
+
+	spec, err := f.controller.GetOpenAPISpec(context.Background(), "docker-desktop")
+	require.NoError(t, err)
+	assert.Equal(t, v2, string(spec))
+
+	_, err = f.controller.GetOpenAPISpecVersion(context.Background(), "docker-desktop", OpenAPIV3)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "fetching OpenAPI v3 spec")
+	}
+
+	_, err = f.controller.GetOpenAPISpecVersion(context.Background(), "docker-desktop", "v4")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "OpenAPI version must be one of: v2, v3. Actual: v4")
+	}
+}
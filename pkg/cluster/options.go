@@ -1,29 +1,98 @@
 package cluster
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/selection"
 
 	"github.com/tilt-dev/ctlptl/pkg/api"
 )
 
 type ListOptions struct {
 	FieldSelector string
+
+	// Only list clusters created more than this long ago.
+	//
+	// Clusters whose creation time can't be read (e.g., because they're
+	// unreachable) never match, so that age-based cleanup never deletes
+	// a cluster it can't date.
+	OlderThan time.Duration
+
+	// Only list clusters whose annotations match this label selector
+	// (e.g., team=foo). Clusters don't have labels of their own.
+	LabelSelector string
 }
 
 type clusterFields api.Cluster
 
+// Fields that we can match before populating the cluster status.
+var clusterSpecFields = []string{"name", "product"}
+
+// Fields computed from the cluster status. Matching on these
+// requires a round-trip to the cluster.
+var clusterStatusFields = []string{"registry", "status.current", "status.ready", "status.kubernetesVersion"}
+
 func (cf *clusterFields) Has(field string) bool {
-	return field == "name" || field == "product"
+	return containsString(clusterSpecFields, field) || containsString(clusterStatusFields, field)
 }
 
 func (cf *clusterFields) Get(field string) string {
-	if field == "name" {
-		return (*api.Cluster)(cf).Name
-	}
-	if field == "product" {
-		return (*api.Cluster)(cf).Product
+	cluster := (*api.Cluster)(cf)
+	switch field {
+	case "name":
+		return cluster.Name
+	case "product":
+		return cluster.Product
+	case "registry":
+		return cluster.Registry
+	case "status.current":
+		return strconv.FormatBool(cluster.Status.Current)
+	case "status.ready":
+		return strconv.FormatBool(IsReady(cluster))
+	case "status.kubernetesVersion":
+		return cluster.Status.KubernetesVersion
 	}
 	return ""
 }
 
 var _ fields.Fields = &clusterFields{}
+
+// Parses the selector, and rejects fields that we don't know about.
+//
+// Returns the full selector, and a selector that only matches on spec fields.
+func parseFieldSelector(s string) (fields.Selector, fields.Selector, error) {
+	selector, err := fields.ParseSelector(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	specSelectors := []fields.Selector{}
+	for _, r := range selector.Requirements() {
+		if !(&clusterFields{}).Has(r.Field) {
+			return nil, nil, fmt.Errorf("field selector %q: unsupported field %q. Supported fields: %s",
+				s, r.Field, strings.Join(append(append([]string{}, clusterSpecFields...), clusterStatusFields...), ", "))
+		}
+		if !containsString(clusterSpecFields, r.Field) {
+			continue
+		}
+		if r.Operator == selection.NotEquals {
+			specSelectors = append(specSelectors, fields.OneTermNotEqualSelector(r.Field, r.Value))
+		} else {
+			specSelectors = append(specSelectors, fields.OneTermEqualSelector(r.Field, r.Value))
+		}
+	}
+	return selector, fields.AndSelectors(specSelectors...), nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
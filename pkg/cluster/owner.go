@@ -0,0 +1,132 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+
+	"k8s.io/klog/v2"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// StrictOwnershipEnv is the environment variable that makes it an error,
+// rather than a warning, to apply a cluster that someone else owns.
+//
+// The --strict-ownership flag takes priority.
+const StrictOwnershipEnv = "CTLPTL_STRICT_OWNERSHIP"
+
+// OwnerEnv is the environment variable that sets the default owner,
+// for CI hosts where every job runs as the same OS user.
+const OwnerEnv = "CTLPTL_OWNER"
+
+// DefaultOwner is the owner recorded on clusters when --owner isn't set:
+// $CTLPTL_OWNER, or else the current OS user.
+func DefaultOwner() string {
+	if owner := os.Getenv(OwnerEnv); owner != "" {
+		return owner
+	}
+	if owner := os.Getenv("USER"); owner != "" {
+		return owner
+	}
+	if owner := os.Getenv("USERNAME"); owner != "" {
+		return owner
+	}
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return u.Username
+}
+
+// OwnerOf returns the owner recorded on the cluster, if any.
+func OwnerOf(cluster *api.Cluster) string {
+	if cluster == nil {
+		return ""
+	}
+	return cluster.Annotations[api.ClusterAnnotationOwner]
+}
+
+// CheckOwner makes sure that owner may apply changes to the existing cluster.
+//
+// If someone else owns the cluster, prints a warning, or returns an error
+// with --strict-ownership. Clusters with no recorded owner may be claimed by anyone.
+func (c *Controller) CheckOwner(existing *api.Cluster, owner string) error {
+	current := OwnerOf(existing)
+	if current == "" || owner == "" || current == owner {
+		return nil
+	}
+	if c.strictOwnership {
+		return fmt.Errorf("cluster %s is owned by %s, not %s. Rerun without --strict-ownership to take ownership",
+			existing.Name, current, owner)
+	}
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+		"Warning: this cluster is owned by %s. Proceeding will take ownership.\n", current)
+	return nil
+}
+
+// SetOwner records the owner of the cluster in the ctlptl state file, and in
+// its stored spec, so that other machines see it too.
+//
+// ctlptl doesn't write to external clusters, so their owner is only
+// recorded in the state file.
+//
+// Does nothing if the cluster already has that owner.
+func (c *Controller) SetOwner(ctx context.Context, clusterName, owner string) error {
+	if owner == "" {
+		return nil
+	}
+
+	cluster, err := c.Get(ctx, clusterName)
+	if err != nil {
+		return err
+	}
+	if OwnerOf(cluster) == owner {
+		return nil
+	}
+
+	c.updateClusterState(clusterName, func(state *clusterState) {
+		state.Owner = owner
+	})
+	if cluster.Product == string(ProductExternal) {
+		return nil
+	}
+
+	client, err := c.client(clusterName)
+	if err != nil {
+		return err
+	}
+	spec, err := readClusterSpec(ctx, client)
+	if err != nil {
+		return err
+	}
+	if spec == nil {
+		spec = &api.Cluster{TypeMeta: cluster.TypeMeta, Name: cluster.Name, Product: cluster.Product}
+	}
+	if spec.Annotations == nil {
+		spec.Annotations = make(map[string]string)
+	}
+	spec.Annotations[api.ClusterAnnotationOwner] = owner
+	return c.writeClusterSpec(ctx, spec)
+}
+
+// Fills in the owner from the ctlptl state file, for clusters whose stored
+// spec doesn't record one.
+func (c *Controller) populateOwner(cluster *api.Cluster) {
+	if OwnerOf(cluster) != "" {
+		return
+	}
+	state, _, err := c.readClusterState(cluster.Name)
+	if err != nil {
+		klog.V(4).Infof("WARNING: reading cluster %s owner: %v\n", cluster.Name, err)
+		return
+	}
+	if state.Owner == "" {
+		return
+	}
+	if cluster.Annotations == nil {
+		cluster.Annotations = make(map[string]string)
+	}
+	cluster.Annotations[api.ClusterAnnotationOwner] = state.Owner
+}
@@ -0,0 +1,114 @@
+package cluster
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestSetOwner(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	err := f.controller.SetOwner(ctx, "microk8s", "alice")
+	require.NoError(t, err)
+
+	cluster, err := f.controller.Get(ctx, "microk8s")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", OwnerOf(cluster))
+
+	err = f.controller.SetOwner(ctx, "microk8s", "bob")
+	require.NoError(t, err)
+
+	cluster, err = f.controller.Get(ctx, "microk8s")
+	require.NoError(t, err)
+	assert.Equal(t, "bob", OwnerOf(cluster))
+}
+
+func TestSetOwnerRecordsState(t *testing.T) {
+	f := newFixture(t)
+	f.controller.clusterStatePath = filepath.Join(t.TempDir(), "clusters.json")
+	ctx := context.Background()
+	f.newFakeAdmin(clusterid.ProductKIND)
+
+	_, err := f.controller.Apply(ctx, &api.Cluster{
+		Product:     string(clusterid.ProductKIND),
+		Annotations: map[string]string{api.ClusterAnnotationOwner: "alice"},
+	})
+	require.NoError(t, err)
+	state, _, err := f.controller.readClusterState("kind-kind")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", state.Owner)
+
+	err = f.controller.SetOwner(ctx, "kind-kind", "bob")
+	require.NoError(t, err)
+	state, _, err = f.controller.readClusterState("kind-kind")
+	require.NoError(t, err)
+	assert.Equal(t, "bob", state.Owner)
+}
+
+func TestApplyExternalWithOwner(t *testing.T) {
+	f := newFixture(t)
+	f.controller.externalClustersPath = filepath.Join(t.TempDir(), "external-clusters.json")
+	f.controller.clusterStatePath = filepath.Join(t.TempDir(), "clusters.json")
+	ctx := context.Background()
+
+	// ctlptl apply records the owner on every cluster, including external ones.
+	cluster, err := f.controller.Apply(ctx, &api.Cluster{
+		Name:        "docker-desktop",
+		Product:     string(ProductExternal),
+		Annotations: map[string]string{api.ClusterAnnotationOwner: "alice"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "", OwnerOf(cluster))
+
+	err = f.controller.SetOwner(ctx, "docker-desktop", "alice")
+	require.NoError(t, err)
+
+	cluster, err = f.controller.Get(ctx, "docker-desktop")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", OwnerOf(cluster))
+
+	// The owner is only in the state file, because ctlptl doesn't write to external clusters.
+	_, err = f.fakeK8s.CoreV1().ConfigMaps("kube-public").Get(ctx, clusterSpecConfigMap, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestCheckOwner(t *testing.T) {
+	f := newFixture(t)
+	existing := &api.Cluster{
+		Name:        "kind-kind",
+		Annotations: map[string]string{api.ClusterAnnotationOwner: "alice"},
+	}
+
+	assert.NoError(t, f.controller.CheckOwner(nil, "bob"))
+	assert.NoError(t, f.controller.CheckOwner(&api.Cluster{Name: "kind-kind"}, "bob"))
+	assert.NoError(t, f.controller.CheckOwner(existing, "alice"))
+	assert.Empty(t, f.errOut.String())
+
+	assert.NoError(t, f.controller.CheckOwner(existing, "bob"))
+	assert.Contains(t, f.errOut.String(), "Warning: this cluster is owned by alice. Proceeding will take ownership.")
+
+	f.controller.strictOwnership = true
+	err := f.controller.CheckOwner(existing, "bob")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cluster kind-kind is owned by alice, not bob")
+	}
+}
+
+func TestDefaultOwner(t *testing.T) {
+	t.Setenv("USER", "alice")
+	t.Setenv(OwnerEnv, "")
+	assert.Equal(t, "alice", DefaultOwner())
+
+	t.Setenv(OwnerEnv, "ci-bot")
+	assert.Equal(t, "ci-bot", DefaultOwner())
+}
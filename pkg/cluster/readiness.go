@@ -0,0 +1,318 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// The kinds of workloads that a readiness check can name.
+var readinessCheckKinds = []string{"deployment", "daemonset", "statefulset"}
+
+func validateReadinessChecks(desired *api.Cluster) error {
+	for _, check := range desired.ReadinessChecks {
+		if !containsString(readinessCheckKinds, strings.ToLower(check.Kind)) {
+			return fmt.Errorf("readinessChecks: kind must be one of: %s. Actual: %s",
+				strings.Join(readinessCheckKinds, ", "), check.Kind)
+		}
+		if check.Name == "" {
+			return fmt.Errorf("readinessChecks: name is required for %s", check.Kind)
+		}
+	}
+	return nil
+}
+
+// IsReady reports whether the apiserver answered, and every readiness check passed.
+func IsReady(cluster *api.Cluster) bool {
+	// We only get a version if the apiserver answered the health check.
+	if cluster.Status.KubernetesVersion == "" {
+		return false
+	}
+	if len(cluster.Status.ReadinessChecks) < len(cluster.ReadinessChecks) {
+		return false
+	}
+	for _, status := range cluster.Status.ReadinessChecks {
+		if !status.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Controller) populateReadinessChecks(ctx context.Context, cluster *api.Cluster, client kubernetes.Interface) {
+	result := make([]api.ReadinessCheckStatus, 0, len(cluster.ReadinessChecks))
+	for _, check := range cluster.ReadinessChecks {
+		if check.Namespace == "" {
+			check.Namespace = "default"
+		}
+		message, err := workloadNotReadyReason(ctx, client, check)
+		if err != nil {
+			message = err.Error()
+		}
+		result = append(result, api.ReadinessCheckStatus{
+			ReadinessCheck: check,
+			Ready:          message == "",
+			Message:        message,
+		})
+	}
+	cluster.Status.ReadinessChecks = result
+}
+
+// Returns why the workload isn't ready, or an empty string if it's ready.
+//
+// Follows the same rules as `kubectl rollout status`.
+func workloadNotReadyReason(ctx context.Context, client kubernetes.Interface, check api.ReadinessCheck) (string, error) {
+	apps := client.AppsV1()
+	switch strings.ToLower(check.Kind) {
+	case "deployment":
+		d, err := apps.Deployments(check.Namespace).Get(ctx, check.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", notFoundReason(err, check)
+		}
+		return deploymentNotReadyReason(d), nil
+
+	case "daemonset":
+		ds, err := apps.DaemonSets(check.Namespace).Get(ctx, check.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", notFoundReason(err, check)
+		}
+		if ds.Status.ObservedGeneration < ds.Generation {
+			return "waiting for the spec update to be observed", nil
+		}
+		desired := ds.Status.DesiredNumberScheduled
+		if ds.Status.UpdatedNumberScheduled < desired {
+			return fmt.Sprintf("%d of %d pods updated", ds.Status.UpdatedNumberScheduled, desired), nil
+		}
+		if ds.Status.NumberAvailable < desired {
+			return fmt.Sprintf("%d of %d pods available", ds.Status.NumberAvailable, desired), nil
+		}
+		return "", nil
+
+	case "statefulset":
+		sts, err := apps.StatefulSets(check.Namespace).Get(ctx, check.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", notFoundReason(err, check)
+		}
+		if sts.Status.ObservedGeneration < sts.Generation {
+			return "waiting for the spec update to be observed", nil
+		}
+		replicas := int32(1)
+		if sts.Spec.Replicas != nil {
+			replicas = *sts.Spec.Replicas
+		}
+		if sts.Status.ReadyReplicas < replicas {
+			return fmt.Sprintf("%d of %d pods ready", sts.Status.ReadyReplicas, replicas), nil
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("unsupported kind %s", check.Kind)
+}
+
+func deploymentNotReadyReason(d *appsv1.Deployment) string {
+	if d.Status.ObservedGeneration < d.Generation {
+		return "waiting for the spec update to be observed"
+	}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	if d.Status.UpdatedReplicas < replicas {
+		return fmt.Sprintf("%d of %d pods updated", d.Status.UpdatedReplicas, replicas)
+	}
+	if d.Status.Replicas > d.Status.UpdatedReplicas {
+		return fmt.Sprintf("%d old pods pending termination", d.Status.Replicas-d.Status.UpdatedReplicas)
+	}
+	if d.Status.AvailableReplicas < d.Status.UpdatedReplicas {
+		return fmt.Sprintf("%d of %d pods available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas)
+	}
+	return ""
+}
+
+func notFoundReason(err error, check api.ReadinessCheck) error {
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("%s %s/%s not found", strings.ToLower(check.Kind), check.Namespace, check.Name)
+	}
+	return err
+}
+
+// WaitOptions configures how WaitForReady polls the cluster.
+//
+// Zero fields fall back to the cluster's readinessPoll, then to the defaults.
+type WaitOptions struct {
+	// How long to wait for the cluster to be ready.
+	Timeout time.Duration
+
+	// How long to wait after the first check. Defaults to 1s.
+	Interval time.Duration
+
+	// Multiplies the interval after each check that isn't ready.
+	// Defaults to 1, which checks at a fixed interval.
+	Backoff float64
+
+	// Give up after this many checks, even if the timeout hasn't passed.
+	// Defaults to 0, which checks until the timeout.
+	MaxAttempts int
+}
+
+const defaultReadinessPollInterval = time.Second
+
+// Validates the options.
+func (o WaitOptions) Validate() error {
+	if o.Interval < 0 {
+		return fmt.Errorf("interval must not be negative. Actual: %s", o.Interval)
+	}
+	if o.Backoff != 0 && o.Backoff < 1 {
+		return fmt.Errorf("backoff must be at least 1. Actual: %v", o.Backoff)
+	}
+	if o.MaxAttempts < 0 {
+		return fmt.Errorf("max attempts must not be negative. Actual: %d", o.MaxAttempts)
+	}
+	return nil
+}
+
+// Converts the cluster's readinessPoll to options.
+func readinessPollOptions(spec *api.ReadinessPollSpec) (WaitOptions, error) {
+	result := WaitOptions{Backoff: spec.Backoff, MaxAttempts: spec.MaxAttempts}
+	if spec.Interval != "" {
+		interval, err := time.ParseDuration(spec.Interval)
+		if err != nil {
+			return WaitOptions{}, fmt.Errorf("readinessPoll.interval: %v", err)
+		}
+		if interval <= 0 {
+			return WaitOptions{}, fmt.Errorf("readinessPoll.interval must be positive. Actual: %s", spec.Interval)
+		}
+		result.Interval = interval
+	}
+	err := result.Validate()
+	if err != nil {
+		return WaitOptions{}, fmt.Errorf("readinessPoll: %v", err)
+	}
+	return result, nil
+}
+
+// Fills in the fields that aren't set from the cluster's readinessPoll,
+// then from the defaults.
+func (o WaitOptions) withDefaults(cluster *api.Cluster) WaitOptions {
+	if cluster.ReadinessPoll != nil {
+		spec, err := readinessPollOptions(cluster.ReadinessPoll)
+		if err == nil {
+			if o.Interval == 0 {
+				o.Interval = spec.Interval
+			}
+			if o.Backoff == 0 {
+				o.Backoff = spec.Backoff
+			}
+			if o.MaxAttempts == 0 {
+				o.MaxAttempts = spec.MaxAttempts
+			}
+		}
+	}
+	if o.Interval == 0 {
+		o.Interval = defaultReadinessPollInterval
+	}
+	if o.Backoff == 0 {
+		o.Backoff = 1
+	}
+	return o
+}
+
+// WaitForReady polls the cluster until the apiserver answers and every
+// readiness check passes, or the timeout expires.
+//
+// Errors reading the cluster don't stop the wait, in case they're
+// transient. Each check is logged at -v=4.
+func (c *Controller) WaitForReady(ctx context.Context, name string, options WaitOptions) (*api.Cluster, error) {
+	err := options.Validate()
+	if err != nil {
+		return nil, errors.Wrapf(err, "waiting for cluster %s", name)
+	}
+
+	cluster, err := c.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if IsReady(cluster) {
+		return cluster, nil
+	}
+	options = options.withDefaults(cluster)
+
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Waiting %s for cluster %q to be ready...\n",
+		duration.ShortHumanDuration(options.Timeout), name)
+	klog.V(4).Infof("Cluster %s: check 1: not ready: %s\n", name, notReadySummary(cluster))
+
+	deadline := time.Now().Add(options.Timeout)
+	interval := options.Interval
+	attempts := 1
+	var lastErr error
+	for {
+		if options.MaxAttempts > 0 && attempts >= options.MaxAttempts {
+			return nil, fmt.Errorf("cluster %s not ready after %d checks: %s",
+				name, attempts, lastCheckSummary(cluster, lastErr))
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, fmt.Errorf("timed out waiting for cluster %s to be ready after %d checks: %s",
+				name, attempts, lastCheckSummary(cluster, lastErr))
+		}
+		if interval > remaining {
+			interval = remaining
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(ctx.Err(), "waiting for cluster %s", name)
+		case <-time.After(interval):
+		}
+		interval = time.Duration(float64(interval) * options.Backoff)
+		attempts++
+
+		current, err := c.Get(ctx, name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, errors.Wrapf(err, "waiting for cluster %s", name)
+			}
+			lastErr = err
+			klog.V(4).Infof("Cluster %s: check %d: %v\n", name, attempts, err)
+			continue
+		}
+		cluster, lastErr = current, nil
+		if IsReady(cluster) {
+			klog.V(4).Infof("Cluster %s: check %d: ready\n", name, attempts)
+			return cluster, nil
+		}
+		klog.V(4).Infof("Cluster %s: check %d: not ready: %s\n", name, attempts, notReadySummary(cluster))
+	}
+}
+
+// Describes the result of the last check.
+func lastCheckSummary(cluster *api.Cluster, lastErr error) string {
+	if lastErr != nil {
+		return lastErr.Error()
+	}
+	return notReadySummary(cluster)
+}
+
+func notReadySummary(cluster *api.Cluster) string {
+	if cluster.Status.KubernetesVersion == "" {
+		return "apiserver not responding"
+	}
+	reasons := []string{}
+	for _, status := range cluster.Status.ReadinessChecks {
+		if !status.Ready {
+			reasons = append(reasons, fmt.Sprintf("%s %s/%s: %s",
+				strings.ToLower(status.Kind), status.Namespace, status.Name, status.Message))
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
@@ -0,0 +1,175 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestClusterReadinessChecks(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	f.newFakeAdmin(clusterid.ProductKIND)
+
+	replicas := int32(2)
+	coredns := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1},
+	}
+	_, err := f.fakeK8s.AppsV1().Deployments("kube-system").Create(context.Background(), coredns, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product: string(clusterid.ProductKIND),
+		ReadinessChecks: []api.ReadinessCheck{
+			{Kind: "deployment", Name: "coredns", Namespace: "kube-system"},
+			{Kind: "DaemonSet", Name: "kindnet", Namespace: "kube-system"},
+		},
+	})
+	require.NoError(t, err)
+
+	cluster, err := f.controller.Get(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.False(t, IsReady(cluster))
+	assert.Equal(t, []api.ReadinessCheckStatus{
+		{
+			ReadinessCheck: api.ReadinessCheck{Kind: "deployment", Name: "coredns", Namespace: "kube-system"},
+			Message:        "1 of 2 pods available",
+		},
+		{
+			ReadinessCheck: api.ReadinessCheck{Kind: "DaemonSet", Name: "kindnet", Namespace: "kube-system"},
+			Message:        "daemonset kube-system/kindnet not found",
+		},
+	}, cluster.Status.ReadinessChecks)
+
+	list, err := f.controller.List(context.Background(), ListOptions{FieldSelector: "name=kind-kind,status.ready=true"})
+	require.NoError(t, err)
+	assert.Empty(t, list.Items)
+
+	coredns.Status.AvailableReplicas = 2
+	_, err = f.fakeK8s.AppsV1().Deployments("kube-system").UpdateStatus(context.Background(), coredns, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	_, err = f.fakeK8s.AppsV1().DaemonSets("kube-system").Create(context.Background(), &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "kindnet", Namespace: "kube-system"},
+		Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 1, UpdatedNumberScheduled: 1, NumberAvailable: 1},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	cluster, err = f.controller.WaitForReady(context.Background(), "kind-kind", WaitOptions{})
+	require.NoError(t, err)
+	assert.True(t, IsReady(cluster))
+
+	list, err = f.controller.List(context.Background(), ListOptions{FieldSelector: "name=kind-kind,status.ready=true"})
+	require.NoError(t, err)
+	assert.Len(t, list.Items, 1)
+}
+
+func TestWaitForReadyMaxAttempts(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	f.newFakeAdmin(clusterid.ProductKIND)
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:         string(clusterid.ProductKIND),
+		ReadinessChecks: []api.ReadinessCheck{{Kind: "deployment", Name: "web"}},
+		ReadinessPoll:   &api.ReadinessPollSpec{Interval: "1ms", MaxAttempts: 2},
+	})
+	require.NoError(t, err)
+
+	// The cluster's readinessPoll applies when the options don't say otherwise.
+	_, err = f.controller.WaitForReady(context.Background(), "kind-kind", WaitOptions{Timeout: time.Minute})
+	if assert.Error(t, err) {
+		assert.Equal(t, "cluster kind-kind not ready after 2 checks: deployment default/web: deployment default/web not found", err.Error())
+	}
+
+	_, err = f.controller.WaitForReady(context.Background(), "kind-kind", WaitOptions{Timeout: time.Minute, MaxAttempts: 4})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not ready after 4 checks")
+	}
+}
+
+func TestWaitForReadyTimeout(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	f.newFakeAdmin(clusterid.ProductKIND)
+
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product:         string(clusterid.ProductKIND),
+		ReadinessChecks: []api.ReadinessCheck{{Kind: "deployment", Name: "web"}},
+	})
+	require.NoError(t, err)
+
+	_, err = f.controller.WaitForReady(context.Background(), "kind-kind", WaitOptions{
+		Timeout:  50 * time.Millisecond,
+		Interval: time.Millisecond,
+		Backoff:  2,
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "timed out waiting for cluster kind-kind to be ready after ")
+		assert.Contains(t, err.Error(), "checks: deployment default/web: deployment default/web not found")
+	}
+}
+
+func TestReadinessPollOptions(t *testing.T) {
+	options, err := readinessPollOptions(&api.ReadinessPollSpec{Interval: "500ms", Backoff: 1.5, MaxAttempts: 10})
+	require.NoError(t, err)
+	assert.Equal(t, WaitOptions{Interval: 500 * time.Millisecond, Backoff: 1.5, MaxAttempts: 10}, options)
+
+	_, err = readinessPollOptions(&api.ReadinessPollSpec{Interval: "5"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "readinessPoll.interval: time: missing unit in duration")
+	}
+
+	_, err = readinessPollOptions(&api.ReadinessPollSpec{Backoff: 0.5})
+	if assert.Error(t, err) {
+		assert.Equal(t, "readinessPoll: backoff must be at least 1. Actual: 0.5", err.Error())
+	}
+
+	options = WaitOptions{Timeout: time.Minute, MaxAttempts: 3}.withDefaults(&api.Cluster{
+		ReadinessPoll: &api.ReadinessPollSpec{Interval: "5s", MaxAttempts: 10},
+	})
+	assert.Equal(t, WaitOptions{Timeout: time.Minute, Interval: 5 * time.Second, Backoff: 1, MaxAttempts: 3}, options)
+}
+
+func TestNotReadySummary(t *testing.T) {
+	assert.Equal(t, "apiserver not responding", notReadySummary(&api.Cluster{}))
+	assert.Equal(t, "statefulset default/db: 0 of 1 pods ready", notReadySummary(&api.Cluster{
+		Status: api.ClusterStatus{
+			KubernetesVersion: "v1.27.3",
+			ReadinessChecks: []api.ReadinessCheckStatus{
+				{ReadinessCheck: api.ReadinessCheck{Kind: "StatefulSet", Name: "db", Namespace: "default"}, Message: "0 of 1 pods ready"},
+				{ReadinessCheck: api.ReadinessCheck{Kind: "deployment", Name: "web", Namespace: "default"}, Ready: true},
+			},
+		},
+	}))
+}
+
+func TestValidateReadinessChecks(t *testing.T) {
+	err := validateReadinessChecks(&api.Cluster{
+		ReadinessChecks: []api.ReadinessCheck{{Kind: "StatefulSet", Name: "db"}},
+	})
+	assert.NoError(t, err)
+
+	err = validateReadinessChecks(&api.Cluster{
+		ReadinessChecks: []api.ReadinessCheck{{Kind: "pod", Name: "db"}},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "readinessChecks: kind must be one of: deployment, daemonset, statefulset. Actual: pod")
+	}
+
+	err = validateReadinessChecks(&api.Cluster{
+		ReadinessChecks: []api.ReadinessCheck{{Kind: "deployment"}},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "readinessChecks: name is required for deployment")
+	}
+}
@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// How long to wait for the forwarder to be removed when a session ends.
+const stopForwarderTimeout = 10 * time.Second
+
+// PortForwardSession forwards a port on localhost to a registry, until the
+// context that started it is canceled.
+type PortForwardSession struct {
+	// The registry name.
+	Registry string
+
+	// The port on localhost that forwards to the registry.
+	HostPort int
+
+	// The forwarder container.
+	ContainerName string
+
+	done chan struct{}
+	err  error
+}
+
+// The address to push to and pull from, e.g., localhost:5005.
+func (s *PortForwardSession) Address() string {
+	return fmt.Sprintf("localhost:%d", s.HostPort)
+}
+
+// Done is closed when the session has cleaned up after its context was canceled.
+func (s *PortForwardSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error from removing the forwarder, once Done is closed.
+func (s *PortForwardSession) Err() error {
+	<-s.done
+	return s.err
+}
+
+// ForwardRegistryPort exposes a registry that's only reachable on a Docker
+// network (like a cluster's internal registry) on localhost:hostPort.
+//
+// Runs a socat container on the registry's network that publishes the host
+// port, like `docker run -p <hostPort>:5000 --network=<net>`. If hostPort is 0,
+// picks a free port. Removes the container when the context is canceled.
+//
+// To keep the port after ctlptl exits, set exposeOnHostPort on the registry instead.
+func (c *Controller) ForwardRegistryPort(ctx context.Context, registryName string, hostPort int) (*PortForwardSession, error) {
+	regCtl, err := c.registryController(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	containerName, hostPort, err := regCtl.StartForwarder(ctx, registryName, hostPort, false)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &PortForwardSession{
+		Registry:      registryName,
+		HostPort:      hostPort,
+		ContainerName: containerName,
+		done:          make(chan struct{}),
+	}
+	go func() {
+		<-ctx.Done()
+
+		// The session's context is already canceled, so clean up with a new one.
+		stopCtx, cancel := context.WithTimeout(context.Background(), stopForwarderTimeout)
+		defer cancel()
+		session.err = regCtl.StopForwarder(stopCtx, containerName)
+		close(session.done)
+	}()
+	return session, nil
+}
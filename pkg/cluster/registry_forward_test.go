@@ -0,0 +1,38 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestForwardRegistryPort(t *testing.T) {
+	f := newFixture(t)
+
+	_, err := f.registryCtl.Apply(context.Background(), &api.Registry{Name: "internal-registry"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	session, err := f.controller.ForwardRegistryPort(ctx, "internal-registry", 5055)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:5055", session.Address())
+	assert.Equal(t, map[string]int{"internal-registry-forward-5055": 5055}, f.registryCtl.forwarders)
+
+	cancel()
+	<-session.Done()
+	assert.NoError(t, session.Err())
+	assert.Empty(t, f.registryCtl.forwarders)
+}
+
+func TestForwardRegistryPortNotFound(t *testing.T) {
+	f := newFixture(t)
+
+	_, err := f.controller.ForwardRegistryPort(context.Background(), "missing", 0)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "registry missing not found")
+	}
+}
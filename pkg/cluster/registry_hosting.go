@@ -0,0 +1,172 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tilt-dev/clusterid"
+	"github.com/tilt-dev/localregistry-go"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/registry"
+)
+
+// The ConfigMap that tools like Tilt and Skaffold read to discover the registry.
+// https://github.com/kubernetes/enhancements/tree/master/keps/sig-cluster-lifecycle/generic/1755-communicating-a-local-registry
+const registryHostingConfigMap = "local-registry-hosting"
+
+// Creates or updates the registry hosting ConfigMap.
+func writeRegistryHosting(ctx context.Context, client kubernetes.Interface, hosting *localregistry.LocalRegistryHostingV1) error {
+	data, err := yaml.Marshal(hosting)
+	if err != nil {
+		return err
+	}
+
+	configMaps := client.CoreV1().ConfigMaps("kube-public")
+	cMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      registryHostingConfigMap,
+			Namespace: "kube-public",
+		},
+		Data: map[string]string{"localRegistryHosting.v1": string(data)},
+	}
+
+	existing, err := configMaps.Get(ctx, registryHostingConfigMap, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		_, err = configMaps.Create(ctx, cMap, metav1.CreateOptions{})
+		return err
+	}
+
+	existing.Data = cMap.Data
+	_, err = configMaps.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+// GetRegistryAddress returns the addresses of the registry attached to the
+// named cluster, as advertised by its registry hosting ConfigMap.
+//
+// host is the address to push to from the host machine (e.g., localhost:5000).
+// internalHost is the address that the cluster's container runtime pulls from
+// (e.g., kind-registry:5000), and may be empty if it's the same as host.
+//
+// Returns empty strings if the cluster has no registry.
+func (c *Controller) GetRegistryAddress(ctx context.Context, clusterName string) (host, internalHost string, err error) {
+	client, err := c.client(clusterName)
+	if err != nil {
+		return "", "", err
+	}
+
+	hosting, err := localregistry.Discover(ctx, client.CoreV1())
+	if err != nil {
+		return "", "", fmt.Errorf("cluster %s: reading registry hosting: %v", clusterName, err)
+	}
+	return hosting.Host, hosting.HostFromContainerRuntime, nil
+}
+
+// RepairRegistryHosting recomputes the registry hosting ConfigMap of the named
+// cluster from its live registry, and re-applies it if it's out of date.
+//
+// Returns true if the ConfigMap was out of date.
+func (c *Controller) RepairRegistryHosting(ctx context.Context, name string) (bool, error) {
+	cluster, err := c.Get(ctx, name)
+	if err != nil {
+		return false, err
+	}
+
+	client, err := c.client(name)
+	if err != nil {
+		return false, err
+	}
+
+	// If the ConfigMap has drifted, we can't use it to find the registry.
+	// Use the registry that the cluster was created with.
+	spec, err := readClusterSpec(ctx, client)
+	if err != nil {
+		return false, err
+	}
+	regName := cluster.Registry
+	if spec != nil && spec.Registry != "" {
+		regName = spec.Registry
+	}
+	if regName == "" {
+		return false, fmt.Errorf("cluster %s: no registry configured", name)
+	}
+
+	regCtl, err := c.registryController(ctx)
+	if err != nil {
+		return false, err
+	}
+	regList, err := regCtl.List(ctx, registry.ListOptions{FieldSelector: fmt.Sprintf("name=%s", regName)})
+	if err != nil {
+		return false, err
+	}
+	if len(regList.Items) == 0 {
+		return false, fmt.Errorf("cluster %s: registry %s not found. Run 'ctlptl apply' to re-create it", name, regName)
+	}
+
+	admin, err := c.admin(ctx, clusterid.Product(cluster.Product))
+	if err != nil {
+		return false, err
+	}
+
+	repaired, err := c.repairRegistryHosting(ctx, admin, cluster, &regList.Items[0])
+	if err != nil || !repaired {
+		return repaired, err
+	}
+
+	// The repaired registry is the one ctlptl intended, so record it.
+	result, err := c.Get(ctx, name)
+	if err != nil {
+		return true, err
+	}
+	c.recordClusterSettings(ctx, result)
+	return true, nil
+}
+
+func (c *Controller) repairRegistryHosting(ctx context.Context, admin Admin, cluster *api.Cluster, reg *api.Registry) (bool, error) {
+	desired, err := admin.LocalRegistryHosting(ctx, cluster, reg)
+	if err != nil {
+		return false, err
+	}
+	if desired == nil {
+		// This product doesn't use the ConfigMap.
+		return false, nil
+	}
+
+	client, err := c.client(cluster.Name)
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := localregistry.Discover(ctx, client.CoreV1())
+	if err != nil {
+		return false, err
+	}
+
+	if cmp.Equal(existing, *desired) {
+		return false, nil
+	}
+
+	err = writeRegistryHosting(ctx, client, desired)
+	if err != nil {
+		return false, err
+	}
+
+	if existing.Host == "" {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔧 Registry config for cluster %s was missing. Connected to registry %s at %s\n",
+			cluster.Name, reg.Name, desired.Host)
+	} else {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔧 Registry config for cluster %s was out of date. Updated registry %s from %s to %s\n",
+			cluster.Name, reg.Name, existing.Host, desired.Host)
+	}
+	return true, nil
+}
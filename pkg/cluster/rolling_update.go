@@ -0,0 +1,153 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+const rollingUpdateStrategyName = "ctlptl-default-rolling-update-strategy"
+
+// A Kyverno policy that sets the rolling update strategy of new Deployments.
+//
+// The apiserver fills in the default strategy (RollingUpdate with 25% surge
+// and 25% unavailable) before admission webhooks run, so the policy treats
+// that strategy as unset, and leaves any other strategy alone.
+const rollingUpdateStrategyTemplate = `apiVersion: kyverno.io/v1
+kind: ClusterPolicy
+metadata:
+  name: %[1]s
+spec:
+  background: false
+  rules:
+  - name: set-rolling-update-strategy
+    match:
+      any:
+      - resources:
+          kinds:
+          - Deployment
+    exclude:
+      any:
+      - resources:
+          namespaces:
+          - kube-system
+          - kyverno
+    preconditions:
+      all:
+      - key: "{{ request.operation || '' }}"
+        operator: Equals
+        value: CREATE
+      - key: "{{ request.object.spec.strategy.type || 'RollingUpdate' }}"
+        operator: Equals
+        value: RollingUpdate
+      - key: "{{ to_string(request.object.spec.strategy.rollingUpdate.maxSurge || '25%%') }}"
+        operator: Equals
+        value: "25%%"
+      - key: "{{ to_string(request.object.spec.strategy.rollingUpdate.maxUnavailable || '25%%') }}"
+        operator: Equals
+        value: "25%%"
+    mutate:
+      patchStrategicMerge:
+        spec:
+          strategy:
+            type: RollingUpdate
+            rollingUpdate:
+%[2]s`
+
+var percentRegexp = regexp.MustCompile(`^[0-9]+%$`)
+
+func validateIntOrPercent(field string, value *intstr.IntOrString) error {
+	if value == nil {
+		return nil
+	}
+	if value.Type == intstr.String {
+		if !percentRegexp.MatchString(value.StrVal) {
+			return fmt.Errorf("%s must be a number or a percentage (e.g., 1 or 25%%). Actual: %s", field, value.StrVal)
+		}
+		return nil
+	}
+	if value.IntVal < 0 {
+		return fmt.Errorf("%s must be non-negative. Actual: %d", field, value.IntVal)
+	}
+	return nil
+}
+
+func isZero(value *intstr.IntOrString) bool {
+	return value != nil && (value.String() == "0" || value.String() == "0%")
+}
+
+func validateRollingUpdateStrategy(desired *api.Cluster) error {
+	s := desired.RollingUpdateStrategy
+	if s.MaxSurge == nil && s.MaxUnavailable == nil {
+		return fmt.Errorf("rollingUpdateStrategy must set maxSurge, maxUnavailable, or both")
+	}
+	err := validateIntOrPercent("rollingUpdateStrategy.maxSurge", s.MaxSurge)
+	if err != nil {
+		return err
+	}
+	err = validateIntOrPercent("rollingUpdateStrategy.maxUnavailable", s.MaxUnavailable)
+	if err != nil {
+		return err
+	}
+	if s.MaxUnavailable != nil && s.MaxUnavailable.Type == intstr.String {
+		percent, _ := strconv.Atoi(strings.TrimSuffix(s.MaxUnavailable.StrVal, "%"))
+		if percent > 100 {
+			return fmt.Errorf("rollingUpdateStrategy.maxUnavailable must be at most 100%%. Actual: %s", s.MaxUnavailable.StrVal)
+		}
+	}
+	if isZero(s.MaxSurge) && isZero(s.MaxUnavailable) {
+		return fmt.Errorf("rollingUpdateStrategy.maxSurge and maxUnavailable can't both be 0, or rollouts never make progress")
+	}
+	return nil
+}
+
+// Renders a value as YAML, quoting percentages.
+func intOrPercentYAML(value *intstr.IntOrString) string {
+	if value.Type == intstr.String {
+		return strconv.Quote(value.StrVal)
+	}
+	return value.String()
+}
+
+func rollingUpdateStrategyManifest(s *api.RollingUpdateStrategySpec) string {
+	fields := ""
+	if s.MaxSurge != nil {
+		fields += fmt.Sprintf("              maxSurge: %s\n", intOrPercentYAML(s.MaxSurge))
+	}
+	if s.MaxUnavailable != nil {
+		fields += fmt.Sprintf("              maxUnavailable: %s\n", intOrPercentYAML(s.MaxUnavailable))
+	}
+	return fmt.Sprintf(rollingUpdateStrategyTemplate, rollingUpdateStrategyName, fields)
+}
+
+// Installs the default rolling update strategy, or removes it if the cluster
+// no longer wants one.
+func (c *Controller) ensureRollingUpdateStrategy(ctx context.Context, cluster *api.Cluster) error {
+	s := cluster.RollingUpdateStrategy
+	if s == nil {
+		_, err := c.kubectl(ctx, cluster.Name, nil, "delete", "clusterpolicy", rollingUpdateStrategyName, "--ignore-not-found")
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔧 Removed default rolling update strategy from cluster %s\n", cluster.Name)
+		return nil
+	}
+
+	err := c.ensureKyverno(ctx, cluster, "set the default rolling update strategy")
+	if err != nil {
+		return err
+	}
+
+	_, err = c.kubectl(ctx, cluster.Name, strings.NewReader(rollingUpdateStrategyManifest(s)), "apply", "-f", "-")
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔧 Set default rolling update strategy on cluster %s\n", cluster.Name)
+	return nil
+}
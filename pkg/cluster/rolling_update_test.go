@@ -0,0 +1,121 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func intOrPercent(s string) *intstr.IntOrString {
+	v := intstr.Parse(s)
+	return &v
+}
+
+func TestRollingUpdateStrategyYAML(t *testing.T) {
+	var cluster api.Cluster
+	err := yaml.Unmarshal([]byte(`
+product: kind
+rollingUpdateStrategy:
+  maxSurge: 1
+  maxUnavailable: 50%
+`), &cluster)
+	require.NoError(t, err)
+	assert.Equal(t, intOrPercent("1"), cluster.RollingUpdateStrategy.MaxSurge)
+	assert.Equal(t, intOrPercent("50%"), cluster.RollingUpdateStrategy.MaxUnavailable)
+
+	out, err := yaml.Marshal(cluster.RollingUpdateStrategy)
+	require.NoError(t, err)
+	assert.Equal(t, "maxSurge: 1\nmaxUnavailable: 50%\n", string(out))
+
+	err = yaml.Unmarshal([]byte("rollingUpdateStrategy:\n  maxSurg: 1\n"), &cluster)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `unknown field "maxSurg"`)
+	}
+}
+
+func TestValidateRollingUpdateStrategy(t *testing.T) {
+	for _, tc := range []struct {
+		maxSurge, maxUnavailable string
+		err                      string
+	}{
+		{"1", "", ""},
+		{"25%", "0", ""},
+		{"", "", "must set maxSurge, maxUnavailable, or both"},
+		{"-1", "", "maxSurge must be non-negative"},
+		{"", "half", "maxUnavailable must be a number or a percentage"},
+		{"", "150%", "maxUnavailable must be at most 100%"},
+		{"0%", "0", "can't both be 0"},
+	} {
+		t.Run(tc.maxSurge+"/"+tc.maxUnavailable, func(t *testing.T) {
+			s := &api.RollingUpdateStrategySpec{}
+			if tc.maxSurge != "" {
+				s.MaxSurge = intOrPercent(tc.maxSurge)
+			}
+			if tc.maxUnavailable != "" {
+				s.MaxUnavailable = intOrPercent(tc.maxUnavailable)
+			}
+			err := validateRollingUpdateStrategy(&api.Cluster{RollingUpdateStrategy: s})
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestClusterApplyRollingUpdateStrategy(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	kindAdmin := f.newFakeAdmin(clusterid.ProductKIND)
+	runner := &kubectlRunner{}
+	f.controller.runner = runner
+
+	cluster := &api.Cluster{
+		Product: string(clusterid.ProductKIND),
+		RollingUpdateStrategy: &api.RollingUpdateStrategySpec{
+			MaxSurge:       intOrPercent("1"),
+			MaxUnavailable: intOrPercent("0"),
+		},
+	}
+	_, err := f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind", kindAdmin.created.Name)
+
+	require.Len(t, runner.stdin, 1)
+	assert.Contains(t, runner.stdin[0], "name: "+rollingUpdateStrategyName)
+	assert.Contains(t, runner.stdin[0], "              maxSurge: 1\n              maxUnavailable: 0\n")
+	assert.Contains(t, runner.stdin[0], `value: "25%"`)
+	assert.Contains(t, f.errOut.String(), "Set default rolling update strategy on cluster kind-kind")
+
+	// Changing the strategy doesn't re-create the cluster.
+	kindAdmin.created = nil
+	runner.calls = nil
+	cluster.RollingUpdateStrategy.MaxUnavailable = intOrPercent("50%")
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Nil(t, kindAdmin.created)
+	require.Len(t, runner.stdin, 2)
+	assert.Contains(t, runner.stdin[1], `maxUnavailable: "50%"`)
+
+	// The stored spec has the new strategy.
+	c, err := f.controller.Get(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.Equal(t, intOrPercent("50%"), c.RollingUpdateStrategy.MaxUnavailable)
+
+	// Removing the strategy deletes the policy.
+	runner.calls = nil
+	cluster.RollingUpdateStrategy = nil
+	_, err = f.controller.Apply(context.Background(), cluster)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"kubectl --context kind-kind delete clusterpolicy " + rollingUpdateStrategyName + " --ignore-not-found",
+	}, runner.callsMatching("delete clusterpolicy"))
+}
@@ -0,0 +1,205 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// The shortest token lifetime that the apiserver accepts.
+const minServiceAccountExpireSeconds = 600
+
+func validateServiceAccounts(desired *api.Cluster) error {
+	seen := make(map[string]bool, len(desired.ServiceAccounts))
+	for _, sa := range desired.ServiceAccounts {
+		errs := validation.IsDNS1123Subdomain(sa.Name)
+		if len(errs) > 0 {
+			return fmt.Errorf("serviceAccounts: invalid name %q: %s", sa.Name, strings.Join(errs, "; "))
+		}
+		namespace := serviceAccountNamespace(sa)
+		errs = validation.IsDNS1123Label(namespace)
+		if len(errs) > 0 {
+			return fmt.Errorf("serviceAccounts: invalid namespace %q: %s", namespace, strings.Join(errs, "; "))
+		}
+		if sa.ExpireSeconds != 0 && sa.ExpireSeconds < minServiceAccountExpireSeconds {
+			return fmt.Errorf("serviceAccounts: expireSeconds for %s/%s must be at least %d. Actual: %d",
+				namespace, sa.Name, minServiceAccountExpireSeconds, sa.ExpireSeconds)
+		}
+
+		key := namespace + "/" + sa.Name
+		if seen[key] {
+			return fmt.Errorf("serviceAccounts: %s is declared more than once", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+func serviceAccountNamespace(sa api.ServiceAccountSpec) string {
+	if sa.Namespace == "" {
+		return "default"
+	}
+	return sa.Namespace
+}
+
+// The ClusterRoleBinding that grants a service account its ClusterRole.
+func serviceAccountBindingName(sa api.ServiceAccountSpec) string {
+	return fmt.Sprintf("ctlptl:%s:%s", serviceAccountNamespace(sa), sa.Name)
+}
+
+// CreateServiceAccount creates a service account in the named cluster, and
+// binds it to its ClusterRole. Existing service accounts are left alone,
+// but their binding is updated to match.
+//
+// If the spec has ExpireSeconds, also returns a token for the service account.
+// Otherwise, returns nil.
+func (c *Controller) CreateServiceAccount(ctx context.Context, clusterName string, sa api.ServiceAccountSpec) (*api.ServiceAccountToken, error) {
+	client, err := c.client(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := serviceAccountNamespace(sa)
+	_, err = client.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: namespace},
+	}, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("creating namespace %s: %v", namespace, err)
+	}
+
+	_, err = client.CoreV1().ServiceAccounts(namespace).Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: sa.Name, Namespace: namespace},
+	}, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("creating service account %s/%s: %v", namespace, sa.Name, err)
+	}
+
+	err = ensureServiceAccountBinding(ctx, client, sa)
+	if err != nil {
+		return nil, err
+	}
+
+	if sa.ExpireSeconds == 0 {
+		return nil, nil
+	}
+	return requestServiceAccountToken(ctx, client, namespace, sa.Name, int64(sa.ExpireSeconds))
+}
+
+// Binds the service account to its ClusterRole, or removes the binding
+// if it no longer has one.
+func ensureServiceAccountBinding(ctx context.Context, client kubernetes.Interface, sa api.ServiceAccountSpec) error {
+	bindings := client.RbacV1().ClusterRoleBindings()
+	name := serviceAccountBindingName(sa)
+	existing, err := bindings.Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("reading cluster role binding %s: %v", name, err)
+	}
+	if err == nil {
+		if existing.RoleRef.Name == sa.ClusterRole {
+			return nil
+		}
+
+		// The role of a binding can't be changed, so delete it and start over.
+		err = bindings.Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting cluster role binding %s: %v", name, err)
+		}
+	}
+
+	if sa.ClusterRole == "" {
+		return nil
+	}
+
+	_, err = bindings.Create(ctx, &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     sa.ClusterRole,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      sa.Name,
+				Namespace: serviceAccountNamespace(sa),
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("creating cluster role binding %s: %v", name, err)
+	}
+	return nil
+}
+
+// Requests a token for the service account.
+//
+// If expireSeconds is 0, the apiserver picks the lifetime (usually an hour).
+func requestServiceAccountToken(ctx context.Context, client kubernetes.Interface, namespace, name string, expireSeconds int64) (*api.ServiceAccountToken, error) {
+	request := &authenticationv1.TokenRequest{}
+	if expireSeconds > 0 {
+		request.Spec.ExpirationSeconds = &expireSeconds
+	}
+	result, err := client.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, name, request, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("requesting token for service account %s/%s: %w", namespace, name, err)
+	}
+	return &api.ServiceAccountToken{
+		Name:                name,
+		Namespace:           namespace,
+		Token:               result.Status.Token,
+		ExpirationTimestamp: result.Status.ExpirationTimestamp,
+	}, nil
+}
+
+// Creates each of the cluster's service accounts, and returns the tokens
+// of those with ExpireSeconds.
+func (c *Controller) ensureServiceAccounts(ctx context.Context, cluster *api.Cluster) ([]api.ServiceAccountToken, error) {
+	tokens := []api.ServiceAccountToken{}
+	for _, sa := range cluster.ServiceAccounts {
+		token, err := c.CreateServiceAccount(ctx, cluster.Name, sa)
+		if err != nil {
+			return nil, err
+		}
+		if token != nil {
+			tokens = append(tokens, *token)
+		}
+	}
+	return tokens, nil
+}
+
+// GetServiceAccountToken requests a new token for a service account in the named cluster.
+//
+// If the cluster declares the service account, the token expires after its
+// expireSeconds. Otherwise, the apiserver picks the lifetime.
+func (c *Controller) GetServiceAccountToken(ctx context.Context, clusterName, namespace, name string) (*api.ServiceAccountToken, error) {
+	cluster, err := c.Get(ctx, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	expireSeconds := int64(0)
+	for _, sa := range cluster.ServiceAccounts {
+		if sa.Name == name && serviceAccountNamespace(sa) == namespace {
+			expireSeconds = int64(sa.ExpireSeconds)
+		}
+	}
+
+	client, err := c.client(cluster.Name)
+	if err != nil {
+		return nil, err
+	}
+	return requestServiceAccountToken(ctx, client, namespace, name, expireSeconds)
+}
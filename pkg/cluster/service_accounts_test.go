@@ -0,0 +1,119 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktesting "k8s.io/client-go/testing"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Answers token requests with a token naming the service account and its lifetime.
+func (f *fixture) fakeTokenRequests() {
+	f.fakeK8s.PrependReactor("create", "serviceaccounts", func(action ktesting.Action) (bool, runtime.Object, error) {
+		create, ok := action.(ktesting.CreateAction)
+		if !ok || action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		request := create.GetObject().(*authenticationv1.TokenRequest)
+		expireSeconds := int64(3600)
+		if request.Spec.ExpirationSeconds != nil {
+			expireSeconds = *request.Spec.ExpirationSeconds
+		}
+		name := create.(ktesting.CreateActionImpl).Name
+		request.Status.Token = fmt.Sprintf("token-%s-%s-%d", action.GetNamespace(), name, expireSeconds)
+		return true, request, nil
+	})
+}
+
+func TestClusterApplyServiceAccounts(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	f.newFakeAdmin(clusterid.ProductKIND)
+	f.fakeTokenRequests()
+
+	ctx := context.Background()
+	result, err := f.controller.Apply(ctx, &api.Cluster{
+		Product: string(clusterid.ProductKIND),
+		ServiceAccounts: []api.ServiceAccountSpec{
+			{Name: "ci", Namespace: "ci", ClusterRole: "edit", ExpireSeconds: 7200},
+			{Name: "viewer", ClusterRole: "view"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []api.ServiceAccountToken{
+		{Name: "ci", Namespace: "ci", Token: "token-ci-ci-7200"},
+	}, result.Status.ServiceAccountTokens)
+
+	_, err = f.fakeK8s.CoreV1().Namespaces().Get(ctx, "ci", metav1.GetOptions{})
+	require.NoError(t, err)
+	_, err = f.fakeK8s.CoreV1().ServiceAccounts("default").Get(ctx, "viewer", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	binding, err := f.fakeK8s.RbacV1().ClusterRoleBindings().Get(ctx, "ctlptl:ci:ci", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "edit", binding.RoleRef.Name)
+	assert.Equal(t, "ci", binding.Subjects[0].Name)
+	assert.Equal(t, "ci", binding.Subjects[0].Namespace)
+
+	// Changing the role replaces the binding, without re-creating the cluster.
+	_, err = f.controller.Apply(ctx, &api.Cluster{
+		Product: string(clusterid.ProductKIND),
+		ServiceAccounts: []api.ServiceAccountSpec{
+			{Name: "ci", Namespace: "ci", ClusterRole: "admin", ExpireSeconds: 7200},
+			{Name: "viewer"},
+		},
+	})
+	require.NoError(t, err)
+
+	binding, err = f.fakeK8s.RbacV1().ClusterRoleBindings().Get(ctx, "ctlptl:ci:ci", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "admin", binding.RoleRef.Name)
+	_, err = f.fakeK8s.RbacV1().ClusterRoleBindings().Get(ctx, "ctlptl:default:viewer", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	// The stored spec remembers the lifetime of each token.
+	token, err := f.controller.GetServiceAccountToken(ctx, "kind-kind", "ci", "ci")
+	require.NoError(t, err)
+	assert.Equal(t, "token-ci-ci-7200", token.Token)
+
+	token, err = f.controller.GetServiceAccountToken(ctx, "kind-kind", "", "viewer")
+	require.NoError(t, err)
+	assert.Equal(t, "token-default-viewer-3600", token.Token)
+}
+
+func TestValidateServiceAccounts(t *testing.T) {
+	err := validateServiceAccounts(&api.Cluster{
+		ServiceAccounts: []api.ServiceAccountSpec{{Name: "ci", ExpireSeconds: 600}},
+	})
+	assert.NoError(t, err)
+
+	err = validateServiceAccounts(&api.Cluster{
+		ServiceAccounts: []api.ServiceAccountSpec{{Name: "CI"}},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `serviceAccounts: invalid name "CI"`)
+	}
+
+	err = validateServiceAccounts(&api.Cluster{
+		ServiceAccounts: []api.ServiceAccountSpec{{Name: "ci", ExpireSeconds: 60}},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "serviceAccounts: expireSeconds for default/ci must be at least 600. Actual: 60")
+	}
+
+	err = validateServiceAccounts(&api.Cluster{
+		ServiceAccounts: []api.ServiceAccountSpec{{Name: "ci"}, {Name: "ci", Namespace: "default"}},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "serviceAccounts: default/ci is declared more than once")
+	}
+}
@@ -0,0 +1,117 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const serviceCIDRFlag = "--service-cluster-ip-range"
+
+// Node labels that mark the control plane, newest first.
+var controlPlaneLabels = []string{
+	"node-role.kubernetes.io/control-plane",
+	"node-role.kubernetes.io/master",
+}
+
+// GetServiceCIDR returns the range of IPs that the cluster assigns to
+// services, from the kube-apiserver's --service-cluster-ip-range flag.
+//
+// Reads the flag from the kube-apiserver static pod on the control-plane node,
+// so only works on clusters that run the apiserver in a pod (e.g., kind and
+// minikube, but not k3d). Dual-stack clusters return their first range.
+//
+// The result is cached for the life of the controller, and reported in the
+// cluster's status from then on.
+func (c *Controller) GetServiceCIDR(ctx context.Context, clusterName string) (*net.IPNet, error) {
+	if cidr := c.cachedServiceCIDR(clusterName); cidr != nil {
+		return cidr, nil
+	}
+
+	client, err := c.client(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	node := ""
+	for _, label := range controlPlaneLabels {
+		nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: label})
+		if err != nil {
+			return nil, errors.Wrapf(err, "cluster %s: listing nodes", clusterName)
+		}
+		if len(nodes.Items) > 0 {
+			node = nodes.Items[0].Name
+			break
+		}
+	}
+	if node == "" {
+		return nil, fmt.Errorf("cluster %s: no control-plane node found", clusterName)
+	}
+
+	out, err := c.kubectl(ctx, clusterName, nil, "-n", "kube-system",
+		"get", "pod", "kube-apiserver-"+node, "-o", "jsonpath={.spec.containers[0].command}")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cluster %s: reading kube-apiserver flags", clusterName)
+	}
+	cidr, err := parseServiceCIDR(out)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cluster %s", clusterName)
+	}
+
+	c.mu.Lock()
+	c.serviceCIDRs[clusterName] = cidr
+	c.mu.Unlock()
+	return copyIPNet(cidr), nil
+}
+
+func (c *Controller) cachedServiceCIDR(clusterName string) *net.IPNet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cidr, ok := c.serviceCIDRs[clusterName]
+	if !ok {
+		return nil
+	}
+	return copyIPNet(cidr)
+}
+
+// So that callers can't change the cached value.
+func copyIPNet(n *net.IPNet) *net.IPNet {
+	return &net.IPNet{
+		IP:   append(net.IP{}, n.IP...),
+		Mask: append(net.IPMask{}, n.Mask...),
+	}
+}
+
+// Finds the service CIDR in the kube-apiserver command,
+// printed by kubectl as a JSON array.
+func parseServiceCIDR(command string) (*net.IPNet, error) {
+	var args []string
+	err := json.Unmarshal([]byte(strings.TrimSpace(command)), &args)
+	if err != nil {
+		return nil, fmt.Errorf("parsing kube-apiserver command: %v", err)
+	}
+
+	for i, arg := range args {
+		value := ""
+		if strings.HasPrefix(arg, serviceCIDRFlag+"=") {
+			value = strings.TrimPrefix(arg, serviceCIDRFlag+"=")
+		} else if arg == serviceCIDRFlag && i+1 < len(args) {
+			value = args[i+1]
+		} else {
+			continue
+		}
+
+		first, _, _ := strings.Cut(value, ",")
+		_, cidr, err := net.ParseCIDR(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", serviceCIDRFlag, err)
+		}
+		return cidr, nil
+	}
+	return nil, fmt.Errorf("kube-apiserver has no %s flag", serviceCIDRFlag)
+}
@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+)
+
+const kubeAPIServerCommand = `["kube-apiserver","--advertise-address=172.18.0.2","--service-cluster-ip-range=10.96.0.0/16,fd00:10:96::/112","--tls-cert-file=/etc/kubernetes/pki/apiserver.crt"]`
+
+func TestGetServiceCIDR(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	node, err := f.fakeK8s.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	node.Labels = map[string]string{"node-role.kubernetes.io/control-plane": ""}
+	_, err = f.fakeK8s.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	calls := []string{}
+	f.controller.runner = exec.NewFakeCmdRunner(func(argv []string) string {
+		calls = append(calls, strings.Join(argv, " "))
+		return kubeAPIServerCommand
+	})
+
+	cidr, err := f.controller.GetServiceCIDR(ctx, "microk8s")
+	require.NoError(t, err)
+	assert.Equal(t, "10.96.0.0/16", cidr.String())
+	assert.Equal(t, []string{
+		"kubectl --context microk8s -n kube-system get pod kube-apiserver-node-1 -o jsonpath={.spec.containers[0].command}",
+	}, calls)
+
+	// The second call is cached.
+	cidr, err = f.controller.GetServiceCIDR(ctx, "microk8s")
+	require.NoError(t, err)
+	assert.Equal(t, "10.96.0.0/16", cidr.String())
+	assert.Len(t, calls, 1)
+
+	cluster, err := f.controller.Get(ctx, "microk8s")
+	require.NoError(t, err)
+	assert.Equal(t, "10.96.0.0/16", cluster.Status.ServiceCIDR)
+}
+
+func TestGetServiceCIDRNoControlPlane(t *testing.T) {
+	f := newFixture(t)
+
+	_, err := f.controller.GetServiceCIDR(context.Background(), "microk8s")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cluster microk8s: no control-plane node found")
+	}
+}
+
+func TestParseServiceCIDR(t *testing.T) {
+	cidr, err := parseServiceCIDR(kubeAPIServerCommand)
+	require.NoError(t, err)
+	assert.Equal(t, "10.96.0.0/16", cidr.String())
+
+	cidr, err = parseServiceCIDR(`["kube-apiserver","--service-cluster-ip-range","10.100.0.0/24"]`)
+	require.NoError(t, err)
+	assert.Equal(t, "10.100.0.0/24", cidr.String())
+
+	_, err = parseServiceCIDR(`["kube-apiserver","--secure-port=6443"]`)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "kube-apiserver has no --service-cluster-ip-range flag")
+	}
+
+	_, err = parseServiceCIDR(`["kube-apiserver","--service-cluster-ip-range=bogus"]`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/registry"
+)
+
+// SetRegistry points a running cluster at a different registry, without
+// re-creating it.
+//
+// Only supported by products that can reconfigure their container runtime
+// on a live cluster. The registry must already exist.
+func (c *Controller) SetRegistry(ctx context.Context, name, registryName string) (*api.Cluster, error) {
+	unlock, err := c.lockCluster(name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cluster, err := c.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if cluster.Registry == registryName {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Cluster %s is already connected to registry %s\n", name, registryName)
+		return cluster, nil
+	}
+
+	product := clusterid.Product(cluster.Product)
+	admin, err := c.admin(ctx, product)
+	if err != nil {
+		return nil, err
+	}
+	switcher, ok := admin.(AdminWithRegistrySwitch)
+	if !ok {
+		if product == clusterid.ProductKIND {
+			return nil, fmt.Errorf("cluster %s: kind configures registry mirrors when the cluster boots, "+
+				"so the registry can't be switched on a running cluster. Re-create the cluster with registry: %s", name, registryName)
+		}
+		return nil, fmt.Errorf("cluster %s: product %s doesn't support switching registries. Re-create the cluster with registry: %s",
+			name, product, registryName)
+	}
+
+	regCtl, err := c.registryController(ctx)
+	if err != nil {
+		return nil, err
+	}
+	newRegistry, err := findRegistry(ctx, regCtl, registryName)
+	if err != nil {
+		return nil, err
+	}
+	if newRegistry == nil {
+		return nil, fmt.Errorf("registry %s not found. Create it with 'ctlptl create registry %s'", registryName, registryName)
+	}
+
+	var oldRegistry *api.Registry
+	if cluster.Registry != "" {
+		oldRegistry, err = findRegistry(ctx, regCtl, cluster.Registry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = switcher.SwitchRegistry(ctx, cluster, oldRegistry, newRegistry)
+	if err != nil {
+		return nil, err
+	}
+
+	hosting, err := admin.LocalRegistryHosting(ctx, cluster, newRegistry)
+	if err != nil {
+		return nil, err
+	}
+	client, err := c.client(name)
+	if err != nil {
+		return nil, err
+	}
+	if hosting != nil {
+		err = writeRegistryHosting(ctx, client, hosting)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Record the new registry in the stored spec, so that we can
+	// repair the registry config later.
+	spec, err := readClusterSpec(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if spec == nil {
+		spec = &api.Cluster{TypeMeta: cluster.TypeMeta, Name: cluster.Name, Product: cluster.Product}
+	}
+	spec.Registry = registryName
+	err = c.writeClusterSpec(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if oldRegistry != nil {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔌 Switched cluster %s from registry %s to %s\n", name, oldRegistry.Name, registryName)
+	} else {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, " 🔌 Connected cluster %s to registry %s\n", name, registryName)
+	}
+
+	result, err := c.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	// We changed the registry ourselves, so it isn't drift.
+	c.recordClusterSettings(ctx, result)
+	return result, nil
+}
+
+// Returns nil if the registry doesn't exist.
+func findRegistry(ctx context.Context, regCtl registryController, name string) (*api.Registry, error) {
+	list, err := regCtl.List(ctx, registry.ListOptions{FieldSelector: fmt.Sprintf("name=%s", name)})
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Items) == 0 {
+		return nil, nil
+	}
+	return &list.Items[0], nil
+}
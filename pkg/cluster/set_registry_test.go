@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	"github.com/tilt-dev/localregistry-go"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestSetRegistry(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+	f.dockerClient.started = true
+	admin := &fakeSwitchAdmin{fakeAdmin: f.newFakeAdmin(clusterid.ProductMinikube)}
+	f.controller.admins[clusterid.ProductMinikube] = admin
+
+	ctx := context.Background()
+	_, err := f.controller.Apply(ctx, &api.Cluster{
+		Product:  string(clusterid.ProductMinikube),
+		Registry: "old-registry",
+	})
+	require.NoError(t, err)
+	_, err = f.registryCtl.Apply(ctx, &api.Registry{Name: "shared-cache"})
+	require.NoError(t, err)
+
+	cluster, err := f.controller.SetRegistry(ctx, "minikube", "shared-cache")
+	require.NoError(t, err)
+	assert.Equal(t, "shared-cache", cluster.Registry)
+	assert.Equal(t, "old-registry", admin.oldRegistry.Name)
+	assert.Equal(t, "shared-cache", admin.newRegistry.Name)
+	assert.Contains(t, f.errOut.String(), "Switched cluster minikube from registry old-registry to shared-cache")
+
+	hosting, err := localregistry.Discover(ctx, f.fakeK8s.CoreV1())
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:5001", hosting.Host)
+
+	spec, err := readClusterSpec(ctx, f.fakeK8s)
+	require.NoError(t, err)
+	assert.Equal(t, "shared-cache", spec.Registry)
+}
+
+func TestSetRegistryIsNotDrift(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+	f.dockerClient.started = true
+	f.controller.clusterStatePath = filepath.Join(t.TempDir(), "clusters.json")
+	admin := &fakeSwitchAdmin{fakeAdmin: f.newFakeAdmin(clusterid.ProductMinikube)}
+	f.controller.admins[clusterid.ProductMinikube] = admin
+
+	ctx := context.Background()
+	_, err := f.controller.Apply(ctx, &api.Cluster{Product: string(clusterid.ProductMinikube)})
+	require.NoError(t, err)
+	_, err = f.registryCtl.Apply(ctx, &api.Registry{Name: "shared-cache"})
+	require.NoError(t, err)
+
+	cluster, err := f.controller.SetRegistry(ctx, "minikube", "shared-cache")
+	require.NoError(t, err)
+	require.Equal(t, "localhost:5000", cluster.Status.LocalRegistryHosting.Host)
+
+	drifts, err := f.controller.VerifyDrift(ctx, cluster)
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+}
+
+func TestSetRegistryKind(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+	f.dockerClient.started = true
+	f.newFakeAdmin(clusterid.ProductKIND)
+
+	ctx := context.Background()
+	_, err := f.controller.Apply(ctx, &api.Cluster{
+		Product:  string(clusterid.ProductKIND),
+		Registry: "kind-registry",
+	})
+	require.NoError(t, err)
+
+	_, err = f.controller.SetRegistry(ctx, "kind-kind", "shared-cache")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "kind configures registry mirrors when the cluster boots")
+	}
+}
+
+type fakeSwitchAdmin struct {
+	*fakeAdmin
+	oldRegistry *api.Registry
+	newRegistry *api.Registry
+}
+
+func (a *fakeSwitchAdmin) SwitchRegistry(ctx context.Context, cluster *api.Cluster, oldRegistry, newRegistry *api.Registry) error {
+	a.oldRegistry = oldRegistry
+	a.newRegistry = newRegistry
+	return nil
+}
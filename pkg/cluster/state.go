@@ -0,0 +1,174 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/klog/v2"
+)
+
+// What ctlptl remembers about a cluster it created, so that we can answer
+// some questions without contacting the cluster.
+type clusterState struct {
+	CreationTimestamp time.Time `json:"creationTimestamp,omitempty"`
+
+	// Who last applied the cluster. See OwnerOf.
+	Owner string `json:"owner,omitempty"`
+
+	// The managed settings of the cluster after ctlptl last applied it.
+	Settings *clusterSettings `json:"settings,omitempty"`
+}
+
+// The file that stores the state of the clusters that ctlptl created,
+// keyed by kubeconfig context.
+//
+// Returns an empty string if there's no home directory, which disables
+// the state file.
+func defaultClusterStatePath() string {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, ".ctlptl", "clusters.json")
+}
+
+func readClusterStates(path string) (map[string]clusterState, error) {
+	result := make(map[string]clusterState)
+	if path == "" {
+		return result, nil
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(contents, &result)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	return result, nil
+}
+
+func writeClusterStates(path string, states map[string]clusterState) error {
+	contents, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(contents, '\n'), 0644)
+}
+
+// Updates the state of the named cluster with the given function.
+// A nil function removes the state.
+//
+// The state is a convenience, so failures are logged rather than returned.
+func (c *Controller) updateClusterState(name string, update func(state *clusterState)) {
+	if c.clusterStatePath == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	states, err := readClusterStates(c.clusterStatePath)
+	if err == nil {
+		contextName := c.contextName(name)
+		if update == nil {
+			delete(states, contextName)
+		} else {
+			state := states[contextName]
+			update(&state)
+			states[contextName] = state
+		}
+		err = writeClusterStates(c.clusterStatePath, states)
+	}
+	if err != nil {
+		klog.V(4).Infof("WARNING: updating state of cluster %s: %v\n", name, err)
+	}
+}
+
+// Reads the state of the named cluster. Returns false if there is none.
+func (c *Controller) readClusterState(name string) (clusterState, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	states, err := readClusterStates(c.clusterStatePath)
+	if err != nil {
+		return clusterState{}, false, err
+	}
+	state, ok := states[c.contextName(name)]
+	return state, ok, nil
+}
+
+// GetClusterAge returns how long ago the named cluster was created.
+//
+// Reads the creation time that ctlptl recorded when it created the cluster,
+// so that it works even if the cluster is unreachable. For clusters that
+// ctlptl didn't create, falls back to when the cluster's Docker container
+// was created (on kind, k3d, and minikube with the docker driver).
+func (c *Controller) GetClusterAge(ctx context.Context, clusterName string) (time.Duration, error) {
+	created, err := c.creationTime(ctx, clusterName)
+	if err != nil {
+		return 0, err
+	}
+	return time.Since(created), nil
+}
+
+func (c *Controller) creationTime(ctx context.Context, clusterName string) (time.Time, error) {
+	product, err := c.productFromConfig(clusterName)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	state, _, err := c.readClusterState(clusterName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !state.CreationTimestamp.IsZero() {
+		return state.CreationTimestamp, nil
+	}
+
+	switch product {
+	case clusterid.ProductKIND, clusterid.ProductK3D:
+		containers, err := c.nodeContainers(ctx, clusterName, false)
+		if err != nil {
+			return time.Time{}, err
+		}
+		var oldest time.Time
+		for _, container := range containers {
+			if container.Created == 0 {
+				continue
+			}
+			created := time.Unix(container.Created, 0)
+			if oldest.IsZero() || created.Before(oldest) {
+				oldest = created
+			}
+		}
+		if !oldest.IsZero() {
+			return oldest, nil
+		}
+	case clusterid.ProductMinikube:
+		dockerClient, err := c.getDockerClient(ctx)
+		if err != nil {
+			return time.Time{}, err
+		}
+		container, err := dockerClient.ContainerInspect(ctx, clusterName)
+		if err == nil && container.ContainerJSONBase != nil {
+			created, err := time.Parse(time.RFC3339Nano, container.Created)
+			if err == nil {
+				return created, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("cluster %s: no creation time recorded", clusterName)
+}
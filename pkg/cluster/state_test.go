@@ -0,0 +1,64 @@
+package cluster
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestClusterStateRecordsCreation(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	f.newFakeAdmin(clusterid.ProductKIND)
+	f.controller.clusterStatePath = filepath.Join(t.TempDir(), "clusters.json")
+
+	before := time.Now()
+	_, err := f.controller.Apply(context.Background(), &api.Cluster{
+		Product: string(clusterid.ProductKIND),
+	})
+	require.NoError(t, err)
+
+	states, err := readClusterStates(f.controller.clusterStatePath)
+	require.NoError(t, err)
+	require.Contains(t, states, "kind-kind")
+	assert.False(t, states["kind-kind"].CreationTimestamp.Before(before))
+
+	age, err := f.controller.GetClusterAge(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.True(t, age >= 0 && age < time.Since(before)+time.Second)
+
+	err = f.controller.Delete(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	states, err = readClusterStates(f.controller.clusterStatePath)
+	require.NoError(t, err)
+	assert.NotContains(t, states, "kind-kind")
+}
+
+func TestGetClusterAgeFromContainers(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+	created := time.Now().Add(-50 * time.Hour)
+	f.dockerClient.containers[0].Created = created.Unix()
+	f.dockerClient.containers[1].Created = created.Add(time.Minute).Unix()
+
+	age, err := f.controller.GetClusterAge(context.Background(), "kind-foo")
+	require.NoError(t, err)
+	assert.InDelta(t, (50 * time.Hour).Seconds(), age.Seconds(), 5)
+}
+
+func TestGetClusterAgeUnknown(t *testing.T) {
+	f := newFixture(t)
+	f.setupKindNodes("kind-foo")
+
+	_, err := f.controller.GetClusterAge(context.Background(), "kind-foo")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cluster kind-foo: no creation time recorded")
+	}
+}
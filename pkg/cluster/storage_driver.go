@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/tilt-dev/clusterid"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+const (
+	StorageDriverLocalPath       = "local-path"
+	StorageDriverOpenEBSHostPath = "openebs-hostpath"
+	StorageDriverLonghorn        = "longhorn"
+)
+
+// The OpenEBS operator, without the cStor and Jiva engines.
+var openEBSManifestURL = "https://openebs.github.io/charts/openebs-operator-lite.yaml"
+
+// Where OpenEBS keeps hostpath volumes on each node.
+const openEBSBasePath = "/var/openebs/local"
+
+var longhornChart = api.HelmChartSpec{
+	Name:      "longhorn",
+	Chart:     "longhorn",
+	Repo:      "https://charts.longhorn.io",
+	Namespace: "longhorn-system",
+}
+
+func validatePVCStorageDriver(desired *api.Cluster) error {
+	switch desired.PVCStorageDriver {
+	case StorageDriverLocalPath:
+		product := clusterid.Product(desired.Product)
+		if product != clusterid.ProductKIND && product != clusterid.ProductK3D {
+			return fmt.Errorf("pvcStorageDriver local-path is only pre-installed on kind and k3d clusters. Actual product: %s", desired.Product)
+		}
+	case StorageDriverOpenEBSHostPath, StorageDriverLonghorn:
+	default:
+		return fmt.Errorf("pvcStorageDriver must be one of: %s, %s, %s. Actual: %s",
+			StorageDriverLocalPath, StorageDriverOpenEBSHostPath, StorageDriverLonghorn, desired.PVCStorageDriver)
+	}
+	return nil
+}
+
+// Installs the cluster's storage driver, and a StorageClass named after it.
+//
+// Doesn't change the default StorageClass. PVCs that want the driver
+// should set storageClassName.
+func (c *Controller) installPVCStorageDriver(ctx context.Context, desired *api.Cluster) error {
+	switch desired.PVCStorageDriver {
+	case StorageDriverOpenEBSHostPath:
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, "   Installing OpenEBS hostpath storage in cluster %s\n", desired.Name)
+		_, err := c.kubectl(ctx, desired.Name, nil, "apply", "-f", openEBSManifestURL)
+		if err != nil {
+			return err
+		}
+		return c.ensureStorageClass(ctx, desired.Name, openEBSStorageClass())
+
+	case StorageDriverLonghorn:
+		err := c.ApplyHelmChart(ctx, desired.Name, longhornChart)
+		if err != nil {
+			return err
+		}
+
+		// The Longhorn manager creates its StorageClass once it's up,
+		// but may not have gotten to it by the time the chart is ready.
+		return c.ensureStorageClass(ctx, desired.Name, longhornStorageClass())
+	}
+
+	// local-path is pre-installed.
+	return nil
+}
+
+// Creates the StorageClass, unless one with the same name already exists.
+func (c *Controller) ensureStorageClass(ctx context.Context, clusterName string, sc *storagev1.StorageClass) error {
+	client, err := c.client(clusterName)
+	if err != nil {
+		return err
+	}
+	_, err = client.StorageV1().StorageClasses().Create(ctx, sc, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "creating StorageClass %s", sc.Name)
+	}
+	return nil
+}
+
+func openEBSStorageClass() *storagev1.StorageClass {
+	bindingMode := storagev1.VolumeBindingWaitForFirstConsumer
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	return &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: StorageDriverOpenEBSHostPath,
+			Annotations: map[string]string{
+				"openebs.io/cas-type": "local",
+				"cas.openebs.io/config": fmt.Sprintf(`- name: StorageType
+  value: hostpath
+- name: BasePath
+  value: %s
+`, openEBSBasePath),
+			},
+		},
+		Provisioner:       "openebs.io/local",
+		VolumeBindingMode: &bindingMode,
+		ReclaimPolicy:     &reclaimPolicy,
+	}
+}
+
+// A placeholder for the StorageClass that the Longhorn manager creates from
+// its longhorn-storageclass ConfigMap. The manager syncs it to that config.
+func longhornStorageClass() *storagev1.StorageClass {
+	bindingMode := storagev1.VolumeBindingImmediate
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	allowExpansion := true
+	return &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: StorageDriverLonghorn,
+		},
+		Provisioner:          "driver.longhorn.io",
+		VolumeBindingMode:    &bindingMode,
+		ReclaimPolicy:        &reclaimPolicy,
+		AllowVolumeExpansion: &allowExpansion,
+	}
+}
@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func applyWithStorageDriver(t *testing.T, driver string) (*fixture, *kubectlRunner) {
+	f := newFixture(t)
+	f.setOS("darwin")
+	_ = f.newFakeAdmin(clusterid.ProductKIND)
+	runner := &kubectlRunner{}
+	f.controller.runner = runner
+
+	// kind ships with local-path.
+	_, err := f.fakeK8s.StorageV1().StorageClasses().Create(context.Background(), &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "standard"},
+		Provisioner: "rancher.io/local-path",
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:          string(clusterid.ProductKIND),
+		PVCStorageDriver: driver,
+	})
+	require.NoError(t, err)
+	return f, runner
+}
+
+func TestClusterApplyStorageDriverLocalPath(t *testing.T) {
+	f, runner := applyWithStorageDriver(t, "local-path")
+	assert.Empty(t, runner.calls)
+
+	sc, err := f.fakeK8s.StorageV1().StorageClasses().Get(context.Background(), "standard", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "rancher.io/local-path", sc.Provisioner)
+
+	c, err := f.controller.Get(context.Background(), "kind-kind")
+	require.NoError(t, err)
+	assert.Equal(t, "local-path", c.PVCStorageDriver)
+}
+
+func TestClusterApplyStorageDriverOpenEBS(t *testing.T) {
+	f, runner := applyWithStorageDriver(t, "openebs-hostpath")
+	assert.Equal(t, []string{
+		"kubectl --context kind-kind apply -f https://openebs.github.io/charts/openebs-operator-lite.yaml",
+	}, runner.calls)
+
+	sc, err := f.fakeK8s.StorageV1().StorageClasses().Get(context.Background(), "openebs-hostpath", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "openebs.io/local", sc.Provisioner)
+	assert.Contains(t, sc.Annotations["cas.openebs.io/config"], "value: /var/openebs/local")
+
+	// Applying again doesn't re-install the driver.
+	runner.calls = nil
+	_, err = f.controller.Apply(context.Background(), &api.Cluster{
+		Product:          string(clusterid.ProductKIND),
+		PVCStorageDriver: "openebs-hostpath",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, runner.calls)
+}
+
+func TestClusterApplyStorageDriverLonghorn(t *testing.T) {
+	f, runner := applyWithStorageDriver(t, "longhorn")
+	assert.Empty(t, runner.calls)
+	assert.Equal(t, []string{
+		"kind-kind longhorn-system/longhorn https://charts.longhorn.io longhorn",
+	}, f.helm.applied)
+
+	sc, err := f.fakeK8s.StorageV1().StorageClasses().Get(context.Background(), "longhorn", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "driver.longhorn.io", sc.Provisioner)
+}
+
+func TestValidatePVCStorageDriver(t *testing.T) {
+	err := validatePVCStorageDriver(&api.Cluster{Product: "minikube", PVCStorageDriver: "local-path"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "local-path is only pre-installed on kind and k3d clusters")
+	}
+
+	err = validatePVCStorageDriver(&api.Cluster{Product: "kind", PVCStorageDriver: "nfs"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "pvcStorageDriver must be one of: local-path, openebs-hostpath, longhorn. Actual: nfs")
+	}
+
+	assert.NoError(t, validatePVCStorageDriver(&api.Cluster{Product: "minikube", PVCStorageDriver: "longhorn"}))
+}
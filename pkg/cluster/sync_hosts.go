@@ -0,0 +1,121 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/internal/hostsfile"
+)
+
+// The owner of the /etc/hosts block that SyncHosts writes. Separate from the
+// block of the cluster's hosts field, so that each leaves the other alone.
+func ingressHostsOwner(clusterName string) string {
+	return fmt.Sprintf("cluster %s ingresses", clusterName)
+}
+
+// Normalizes a zone like ".local" or "Local." to "local".
+func normalizeHostsZone(zone string) (string, error) {
+	zone = strings.ToLower(strings.Trim(zone, "."))
+	if zone == "" {
+		return "", fmt.Errorf("zone must not be empty (e.g., .local)")
+	}
+	err := hostsfile.ValidateHostname(zone)
+	if err != nil {
+		return "", fmt.Errorf("invalid zone: %v", err)
+	}
+	return zone, nil
+}
+
+// SyncHosts points the hostnames of the cluster's Ingresses that end in
+// .zone at 127.0.0.1 in /etc/hosts.
+//
+// The hostnames are read from the rules and TLS sections of the Ingresses
+// in every namespace. Wildcard hostnames are skipped, because hosts files
+// don't support them.
+//
+// Replaces the entries written by the last sync, so hostnames that no
+// Ingress uses any more are removed. If we don't have permission to write
+// /etc/hosts, prints the lines to add instead of failing.
+func (c *Controller) SyncHosts(ctx context.Context, clusterName string, zone string) error {
+	zone, err := normalizeHostsZone(zone)
+	if err != nil {
+		return err
+	}
+
+	client, err := c.client(clusterName)
+	if err != nil {
+		return err
+	}
+	ingresses, err := client.NetworkingV1().Ingresses("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("listing ingresses in cluster %s: %v", clusterName, err)
+	}
+
+	var entries []hostsfile.Entry
+	hostnames := ingressHostnames(ingresses.Items, zone)
+	if len(hostnames) > 0 {
+		entries = []hostsfile.Entry{{IP: hostsIP, Hostnames: hostnames}}
+	}
+
+	err = c.hostsFile.Set(ingressHostsOwner(clusterName), entries)
+	var permErr *hostsfile.PermissionError
+	if errors.As(err, &permErr) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, " ⚠️ Couldn't update %s for cluster %s: permission denied. %s",
+			permErr.Path, clusterName, permErr.Hint())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("updating hosts for cluster %s: %v", clusterName, err)
+	}
+	return nil
+}
+
+// The sorted, de-duplicated hostnames of the ingresses that end in .zone.
+func ingressHostnames(ingresses []networkingv1.Ingress, zone string) []string {
+	seen := make(map[string]bool)
+	add := func(host string) {
+		host = strings.ToLower(host)
+		if !strings.HasSuffix(host, "."+zone) || hostsfile.ValidateHostname(host) != nil {
+			return
+		}
+		seen[host] = true
+	}
+	for _, ing := range ingresses {
+		for _, rule := range ing.Spec.Rules {
+			add(rule.Host)
+		}
+		for _, tls := range ing.Spec.TLS {
+			for _, host := range tls.Hosts {
+				add(host)
+			}
+		}
+	}
+
+	result := make([]string, 0, len(seen))
+	for host := range seen {
+		result = append(result, host)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Removes the block that SyncHosts wrote, if any.
+func (c *Controller) removeIngressHosts(clusterName string) error {
+	err := c.hostsFile.Remove(ingressHostsOwner(clusterName))
+	var permErr *hostsfile.PermissionError
+	if errors.As(err, &permErr) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, " ⚠️ Couldn't update %s for cluster %s: permission denied. %s",
+			permErr.Path, clusterName, permErr.Hint())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("updating hosts for cluster %s: %v", clusterName, err)
+	}
+	return nil
+}
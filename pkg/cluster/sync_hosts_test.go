@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (f *fixture) createIngress(namespace, name string, tlsHosts []string, hosts ...string) {
+	ing := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	for _, host := range hosts {
+		ing.Spec.Rules = append(ing.Spec.Rules, networkingv1.IngressRule{Host: host})
+	}
+	if len(tlsHosts) > 0 {
+		ing.Spec.TLS = []networkingv1.IngressTLS{{Hosts: tlsHosts}}
+	}
+	_, err := f.fakeK8s.NetworkingV1().Ingresses(namespace).Create(context.Background(), ing, metav1.CreateOptions{})
+	require.NoError(f.t, err)
+}
+
+func TestSyncHosts(t *testing.T) {
+	f := newFixture(t)
+	path := f.controller.hostsFile.Path
+	require.NoError(t, os.WriteFile(path, []byte("127.0.0.1 localhost\n"), 0644))
+
+	f.createIngress("default", "app", nil, "app.local", "app.example.com", "*.wild.local")
+	f.createIngress("team", "api", []string{"secure.local"}, "API.local", "app.local")
+
+	err := f.controller.SyncHosts(context.Background(), "docker-desktop", ".local")
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1 localhost\n"+
+		"# BEGIN ctlptl cluster docker-desktop ingresses\n"+
+		"127.0.0.1 api.local app.local secure.local\n"+
+		"# END ctlptl cluster docker-desktop ingresses\n", string(data))
+
+	// Hostnames from a previous sync that no ingress uses are removed.
+	err = f.fakeK8s.NetworkingV1().Ingresses("team").Delete(context.Background(), "api", metav1.DeleteOptions{})
+	require.NoError(t, err)
+	err = f.controller.SyncHosts(context.Background(), "docker-desktop", "local")
+	require.NoError(t, err)
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1 localhost\n"+
+		"# BEGIN ctlptl cluster docker-desktop ingresses\n"+
+		"127.0.0.1 app.local\n"+
+		"# END ctlptl cluster docker-desktop ingresses\n", string(data))
+
+	err = f.fakeK8s.NetworkingV1().Ingresses("default").Delete(context.Background(), "app", metav1.DeleteOptions{})
+	require.NoError(t, err)
+	err = f.controller.SyncHosts(context.Background(), "docker-desktop", "local")
+	require.NoError(t, err)
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1 localhost\n", string(data))
+}
+
+func TestSyncHostsInvalidZone(t *testing.T) {
+	f := newFixture(t)
+
+	err := f.controller.SyncHosts(context.Background(), "docker-desktop", ".")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "zone must not be empty")
+	}
+	err = f.controller.SyncHosts(context.Background(), "docker-desktop", "*.local")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid zone")
+	}
+}
+
+func TestSyncHostsPermissionDenied(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("root can write read-only files")
+	}
+	f := newFixture(t)
+	path := f.controller.hostsFile.Path
+	require.NoError(t, os.WriteFile(path, []byte("127.0.0.1 localhost\n"), 0444))
+	f.createIngress("default", "app", nil, "app.local")
+
+	err := f.controller.SyncHosts(context.Background(), "docker-desktop", "local")
+	require.NoError(t, err)
+	assert.Contains(t, f.errOut.String(), "Couldn't update "+path+" for cluster docker-desktop: permission denied")
+	assert.Contains(t, f.errOut.String(), "127.0.0.1 app.local")
+}
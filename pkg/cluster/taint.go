@@ -0,0 +1,93 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The taint that kubeadm puts on control-plane nodes in production clusters.
+const controlPlaneTaintKey = "node-role.kubernetes.io/control-plane"
+
+// Older clusters label control-plane nodes as masters.
+var controlPlaneNodeLabels = []string{
+	"node-role.kubernetes.io/control-plane",
+	"node-role.kubernetes.io/master",
+}
+
+func isControlPlaneNode(node *corev1.Node) bool {
+	for _, label := range controlPlaneNodeLabels {
+		if _, ok := node.Labels[label]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// TaintControlPlane adds (or, if enabled is false, removes) the standard
+// control-plane NoSchedule taint on all control-plane nodes of the named cluster.
+//
+// Many local clusters skip this taint. Adding it lets you check that
+// your workloads schedule the way they would on a production cluster.
+func (c *Controller) TaintControlPlane(ctx context.Context, clusterName string, enabled bool) error {
+	client, err := c.client(clusterName)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("listing nodes: %v", err)
+	}
+
+	found := false
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if !isControlPlaneNode(node) {
+			continue
+		}
+		found = true
+
+		taints, changed := setControlPlaneTaint(node.Spec.Taints, enabled)
+		if !changed {
+			continue
+		}
+		node.Spec.Taints = taints
+		_, err := client.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("updating node %s: %v", node.Name, err)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("cluster %s: no control-plane nodes found", clusterName)
+	}
+	return nil
+}
+
+// Adds or removes the control-plane taint.
+//
+// Returns true if the taints changed.
+func setControlPlaneTaint(taints []corev1.Taint, enabled bool) ([]corev1.Taint, bool) {
+	result := []corev1.Taint{}
+	hasTaint := false
+	for _, taint := range taints {
+		if taint.Key == controlPlaneTaintKey && taint.Effect == corev1.TaintEffectNoSchedule {
+			hasTaint = true
+			if !enabled {
+				continue
+			}
+		}
+		result = append(result, taint)
+	}
+
+	if enabled == hasTaint {
+		return taints, false
+	}
+	if enabled {
+		result = append(result, corev1.Taint{Key: controlPlaneTaintKey, Effect: corev1.TaintEffectNoSchedule})
+	}
+	return result, true
+}
@@ -0,0 +1,82 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilt-dev/clusterid"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestTaintControlPlane(t *testing.T) {
+	f := newFixture(t)
+	f.createNode("kind-control-plane", map[string]string{"node-role.kubernetes.io/control-plane": ""})
+	f.createNode("kind-worker", nil)
+
+	ctx := context.Background()
+	err := f.controller.TaintControlPlane(ctx, "microk8s", true)
+	require.NoError(t, err)
+	assert.Equal(t, []corev1.Taint{{Key: controlPlaneTaintKey, Effect: corev1.TaintEffectNoSchedule}},
+		f.nodeTaints("kind-control-plane"))
+	assert.Empty(t, f.nodeTaints("kind-worker"))
+
+	// Idempotent.
+	err = f.controller.TaintControlPlane(ctx, "microk8s", true)
+	require.NoError(t, err)
+	assert.Len(t, f.nodeTaints("kind-control-plane"), 1)
+
+	err = f.controller.TaintControlPlane(ctx, "microk8s", false)
+	require.NoError(t, err)
+	assert.Empty(t, f.nodeTaints("kind-control-plane"))
+}
+
+func TestTaintControlPlaneNoNodes(t *testing.T) {
+	f := newFixture(t)
+	f.createNode("kind-worker", nil)
+
+	err := f.controller.TaintControlPlane(context.Background(), "microk8s", true)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no control-plane nodes found")
+	}
+}
+
+func TestClusterApplyToggleTaintControlPlane(t *testing.T) {
+	f := newFixture(t)
+	f.setOS("linux")
+	f.dockerClient.started = true
+	f.newFakeAdmin(clusterid.ProductKIND)
+	f.createNode("kind-control-plane", map[string]string{"node-role.kubernetes.io/master": ""})
+
+	ctx := context.Background()
+	_, err := f.controller.Apply(ctx, &api.Cluster{
+		Product:           string(clusterid.ProductKIND),
+		TaintControlPlane: true,
+	})
+	require.NoError(t, err)
+	assert.Len(t, f.nodeTaints("kind-control-plane"), 1)
+
+	cluster, err := f.controller.Apply(ctx, &api.Cluster{
+		Product: string(clusterid.ProductKIND),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, f.nodeTaints("kind-control-plane"))
+	assert.False(t, cluster.TaintControlPlane)
+}
+
+func (f *fixture) createNode(name string, labels map[string]string) {
+	_, err := f.fakeK8s.CoreV1().Nodes().Create(context.Background(), &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+	}, metav1.CreateOptions{})
+	require.NoError(f.t, err)
+}
+
+func (f *fixture) nodeTaints(name string) []corev1.Taint {
+	node, err := f.fakeK8s.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
+	require.NoError(f.t, err)
+	return node.Spec.Taints
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+func NewAgeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "age [cluster]",
+		Short: "Print how long ago a cluster was created",
+		Long: "Print how long ago a cluster was created, e.g., \"3 days 2 hours\".\n\n" +
+			"Reads the creation time that ctlptl recorded when it created the cluster, " +
+			"so it works even if the cluster isn't running. For clusters that ctlptl didn't create, " +
+			"falls back to when the cluster's Docker container was created.",
+		Example: "  ctlptl age kind-kind",
+		Run:     withClusterController("age", age),
+		Args:    cobra.ExactArgs(1),
+	}
+}
+
+func age(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	d, err := c.GetClusterAge(ctx, cl.Name)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(streams.Out, humanAge(d))
+	return nil
+}
+
+// Formats a duration with its two largest units, e.g., "3 days 2 hours".
+func humanAge(d time.Duration) string {
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+		{"second", time.Second},
+	}
+
+	var parts []string
+	for _, u := range units {
+		n := int64(d / u.size)
+		if n == 0 && len(parts) == 0 {
+			continue
+		}
+		d -= time.Duration(n) * u.size
+		if n > 0 {
+			part := fmt.Sprintf("%d %s", n, u.name)
+			if n != 1 {
+				part += "s"
+			}
+			parts = append(parts, part)
+		}
+		if len(parts) == 2 || (len(parts) == 1 && n == 0) {
+			break
+		}
+	}
+	if len(parts) == 0 {
+		return "0 seconds"
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHumanAge(t *testing.T) {
+	assert.Equal(t, "3 days 2 hours", humanAge(3*24*time.Hour+2*time.Hour+5*time.Minute))
+	assert.Equal(t, "1 day", humanAge(24*time.Hour+30*time.Minute))
+	assert.Equal(t, "1 hour 1 minute", humanAge(time.Hour+time.Minute+time.Second))
+	assert.Equal(t, "1 minute", humanAge(time.Minute+500*time.Millisecond))
+	assert.Equal(t, "45 seconds", humanAge(45*time.Second))
+	assert.Equal(t, "0 seconds", humanAge(0))
+}
@@ -4,11 +4,16 @@ import (
 	"runtime"
 
 	"github.com/tilt-dev/wmclient/pkg/analytics"
+
+	"github.com/tilt-dev/ctlptl/internal/egress"
 )
 
 var Version string
 
 func newAnalytics() (analytics.Analytics, error) {
+	if egress.Disabled() {
+		return analytics.NewMemoryAnalytics(), nil
+	}
 	return analytics.NewRemoteAnalytics(
 		"ctlptl",
 		analytics.WithLogger(discardLogger{}),
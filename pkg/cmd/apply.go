@@ -4,14 +4,21 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 
+	"github.com/tilt-dev/ctlptl/internal/audit"
 	"github.com/tilt-dev/ctlptl/pkg/api"
 	"github.com/tilt-dev/ctlptl/pkg/cluster"
+	"github.com/tilt-dev/ctlptl/pkg/manifest"
 	"github.com/tilt-dev/ctlptl/pkg/registry"
+	"github.com/tilt-dev/ctlptl/pkg/reporter"
 	"github.com/tilt-dev/ctlptl/pkg/visitor"
 )
 
@@ -21,12 +28,36 @@ type ApplyOptions struct {
 	genericclioptions.IOStreams
 
 	Filenames []string
+	OutputDir string
+	DryRun    bool
+	Owner     string
+	Vars      []string
+	Sets      []string
+	Wait      time.Duration
+
+	// How --wait polls. Zero values fall back to each cluster's readinessPoll.
+	WaitInterval    time.Duration
+	WaitBackoff     float64
+	WaitMaxAttempts int
+
+	AnnotateGit bool
+
+	// Never modify objects that already exist.
+	CreateOnly bool
+	OnExists   string
 }
 
+// What --create-only does when an object already exists.
+const (
+	onExistsSkip  = "skip"
+	onExistsError = "error"
+)
+
 func NewApplyOptions() *ApplyOptions {
 	o := &ApplyOptions{
 		PrintFlags: genericclioptions.NewPrintFlags("created"),
 		IOStreams:  genericclioptions.IOStreams{Out: os.Stdout, ErrOut: os.Stderr, In: os.Stdin},
+		OnExists:   onExistsSkip,
 	}
 	o.FileNameFlags = &genericclioptions.FileNameFlags{Filenames: &o.Filenames}
 	return o
@@ -37,7 +68,15 @@ func (o *ApplyOptions) Command() *cobra.Command {
 		Use:   "apply -f FILENAME",
 		Short: "Apply a cluster config to the currently running clusters",
 		Example: "  ctlptl apply -f cluster.yaml\n" +
-			"  cat cluster.yaml | ctlptl apply -f -",
+			"  cat cluster.yaml | ctlptl apply -f -\n" +
+			"  ctlptl apply -f cluster.yaml --dry-run --output-dir=./generated\n" +
+			"  ctlptl apply -f cluster.yaml --var=REGISTRY_PORT=5005\n" +
+			"  ctlptl apply -f cluster.yaml --set=workers=5 --set=kindOptions.rootless=true\n" +
+			"  ctlptl apply -f cluster.yaml --wait=5m\n" +
+			"  ctlptl apply -f cluster.yaml --wait=20m --wait-interval=5s --wait-backoff=1.5\n" +
+			"  ctlptl apply -f cluster.yaml --annotate-git\n" +
+			"  ctlptl apply -f cluster.yaml --owner=ci-bot\n" +
+			"  ctlptl apply -f cluster.yaml --create-only --on-exists=error",
 		Run: o.Run,
 	}
 
@@ -45,6 +84,32 @@ func (o *ApplyOptions) Command() *cobra.Command {
 	cmd.SetErr(o.ErrOut)
 	o.FileNameFlags.AddFlags(cmd.Flags())
 	o.PrintFlags.AddFlags(cmd)
+	cmd.Flags().StringVar(&o.OutputDir, "output-dir", o.OutputDir,
+		"If set, write the configs generated for the cluster tool (e.g., kind-config.yaml) to this directory")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", o.DryRun,
+		"If true, only print the objects that would be applied. Combine with --output-dir to generate configs without creating anything")
+	cmd.Flags().StringArrayVar(&o.Vars, "var", o.Vars,
+		"Set a variable referenced as ${KEY} in the config, as KEY=VALUE. Takes priority over environment variables. May be repeated")
+	cmd.Flags().StringArrayVar(&o.Sets, "set", o.Sets,
+		"Override a field of the objects in the config, as PATH=VALUE, where PATH is a dot-separated list of field names (e.g. kindOptions.rootless=true). "+
+			"Applies to every object that has the field. May be repeated")
+	cmd.Flags().DurationVar(&o.Wait, "wait", o.Wait,
+		"If set, wait up to this long for each cluster to pass its readinessChecks (e.g. 5m)")
+	cmd.Flags().DurationVar(&o.WaitInterval, "wait-interval", o.WaitInterval,
+		"With --wait, how long to wait after the first readiness check. Defaults to the cluster's readinessPoll.interval, then 1s")
+	cmd.Flags().Float64Var(&o.WaitBackoff, "wait-backoff", o.WaitBackoff,
+		"With --wait, multiply the interval by this much after each check that isn't ready. Defaults to the cluster's readinessPoll.backoff, then 1")
+	cmd.Flags().IntVar(&o.WaitMaxAttempts, "wait-max-attempts", o.WaitMaxAttempts,
+		"With --wait, give up after this many readiness checks, even before the timeout. Defaults to the cluster's readinessPoll.maxAttempts, then no limit")
+	cmd.Flags().BoolVar(&o.AnnotateGit, "annotate-git", o.AnnotateGit,
+		"If true, annotate newly created clusters with the Git commit checked out in the current directory. Skipped outside a Git repo")
+	cmd.Flags().StringVar(&o.Owner, "owner", o.Owner,
+		"The user to record as the owner of each cluster. Defaults to the ctlptl.dev/owner annotation in the config, then $CTLPTL_OWNER, then $USER. "+
+			"Warns when taking over a cluster that someone else owns")
+	cmd.Flags().BoolVar(&o.CreateOnly, "create-only", o.CreateOnly,
+		"If true, only create objects that don't exist. Objects that already exist are left untouched, even if they don't match the config")
+	cmd.Flags().StringVar(&o.OnExists, "on-exists", o.OnExists,
+		"With --create-only, what to do when an object already exists: skip (report it as existing) or error")
 
 	return cmd
 }
@@ -72,39 +137,154 @@ func (o *ApplyOptions) run() error {
 
 	ctx := context.TODO()
 
+	err = o.validate()
+	if err != nil {
+		return err
+	}
+
+	if o.DryRun {
+		err = o.PrintFlags.Complete("%s (dry run)")
+		if err != nil {
+			return err
+		}
+	}
+
 	printer, err := toPrinter(o.PrintFlags)
 	if err != nil {
 		return err
 	}
 
+	lookup, err := o.varLookup()
+	if err != nil {
+		return err
+	}
+
 	visitors, err := visitor.FromStrings(o.Filenames, o.In)
 	if err != nil {
 		return err
 	}
 
-	objects, err := visitor.DecodeAll(visitors)
+	objects, err := visitor.DecodeAllWithVars(visitors, lookup)
+	if err != nil {
+		return err
+	}
+
+	err = o.applyOverrides(objects)
+	if err != nil {
+		return err
+	}
+
+	if o.OutputDir != "" {
+		clusterCount := 0
+		for _, obj := range objects {
+			if _, ok := obj.(*api.Cluster); ok {
+				clusterCount++
+			}
+		}
+		if clusterCount > 1 {
+			return fmt.Errorf("--output-dir only supports one cluster per apply. Found %d clusters", clusterCount)
+		}
+	}
+
+	r := reporter.FromEnv(printer, o.Out, o.ErrOut)
+	if audit.Enabled() {
+		r = reporter.NewAuditReporter(r, "apply")
+	}
+	err = o.applyAll(ctx, objects, r)
+	doneErr := r.Done()
+	if err != nil {
+		return err
+	}
+	return doneErr
+}
+
+func (o *ApplyOptions) validate() error {
+	if o.OnExists != onExistsSkip && o.OnExists != onExistsError {
+		return fmt.Errorf("--on-exists must be one of: %s, %s. Actual: %s", onExistsSkip, onExistsError, o.OnExists)
+	}
+	if o.OnExists != onExistsSkip && !o.CreateOnly {
+		return fmt.Errorf("--on-exists only applies with --create-only")
+	}
+	err := o.waitOptions().Validate()
 	if err != nil {
+		return fmt.Errorf("--wait options: %v", err)
+	}
+	return nil
+}
+
+func (o *ApplyOptions) waitOptions() cluster.WaitOptions {
+	return cluster.WaitOptions{
+		Timeout:     o.Wait,
+		Interval:    o.WaitInterval,
+		Backoff:     o.WaitBackoff,
+		MaxAttempts: o.WaitMaxAttempts,
+	}
+}
+
+// With --create-only, reports an object that already exists instead of applying it.
+func (o *ApplyOptions) reportExists(r reporter.Reporter, obj runtime.Object, kind, name string, start time.Time) error {
+	if o.OnExists == onExistsError {
+		err := fmt.Errorf("%s %s already exists, and --create-only doesn't modify existing objects", kind, name)
+		r.Failed(kind, name, err, time.Since(start))
 		return err
 	}
+	return r.Applied(obj, reporter.Result{
+		Kind:     kind,
+		Name:     name,
+		Action:   reporter.ActionExists,
+		Duration: time.Since(start),
+	})
+}
 
+func (o *ApplyOptions) applyAll(ctx context.Context, objects []runtime.Object, r reporter.Reporter) error {
 	var cc *cluster.Controller
 	var rc *registry.Controller
 	for _, obj := range objects {
 		switch obj := obj.(type) {
 		case *api.Registry:
+			if o.DryRun {
+				err := r.Applied(registry.Redacted(obj), reporter.Result{Kind: obj.Kind, Name: obj.Name, Action: reporter.ActionDryRun})
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
 			if rc == nil {
+				var err error
 				rc, err = registry.DefaultController(o.IOStreams)
 				if err != nil {
 					return err
 				}
 			}
 
+			start := time.Now()
+			registry.FillDefaults(obj)
+			existing, err := rc.Get(ctx, obj.Name)
+			if err != nil && !errors.IsNotFound(err) {
+				r.Failed(obj.Kind, obj.Name, err, time.Since(start))
+				return err
+			}
+			if o.CreateOnly && existing != nil {
+				err := o.reportExists(r, existing, obj.Kind, obj.Name, start)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
 			newObj, err := rc.Apply(ctx, obj)
 			if err != nil {
+				r.Failed(obj.Kind, obj.Name, err, time.Since(start))
 				return err
 			}
 
-			err = printer.PrintObj(newObj, o.Out)
+			err = r.Applied(newObj, reporter.Result{
+				Kind:     obj.Kind,
+				Name:     newObj.Name,
+				Action:   registryAction(existing, newObj),
+				Duration: time.Since(start),
+			})
 			if err != nil {
 				return err
 			}
@@ -115,18 +295,105 @@ func (o *ApplyOptions) run() error {
 		switch obj := obj.(type) {
 		case *api.Cluster:
 			if cc == nil {
-				cc, err = cluster.DefaultController(o.IOStreams)
+				var err error
+				cc, err = newClusterController(o.IOStreams)
 				if err != nil {
 					return err
 				}
+				cc.SetOutputDir(o.OutputDir)
+			}
+
+			if o.DryRun {
+				if o.OutputDir != "" {
+					err := cc.WriteGeneratedConfigs(ctx, obj, o.OutputDir)
+					if err != nil {
+						return err
+					}
+				}
+				err := r.Applied(obj, reporter.Result{Kind: obj.Kind, Name: obj.Name, Action: reporter.ActionDryRun})
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			start := time.Now()
+			cluster.FillDefaults(obj)
+			existing, err := cc.Get(ctx, obj.Name)
+			if err != nil && !errors.IsNotFound(err) {
+				r.Failed(obj.Kind, obj.Name, err, time.Since(start))
+				return err
+			}
+			if o.CreateOnly && existing != nil {
+				err := o.reportExists(r, existing, obj.Kind, obj.Name, start)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			owner := o.Owner
+			if owner == "" {
+				owner = cluster.OwnerOf(obj)
+			}
+			if owner == "" {
+				owner = cluster.DefaultOwner()
+			}
+			err = cc.CheckOwner(existing, owner)
+			if err != nil {
+				r.Failed(obj.Kind, obj.Name, err, time.Since(start))
+				return err
+			}
+			if owner != "" {
+				if obj.Annotations == nil {
+					obj.Annotations = make(map[string]string)
+				}
+				obj.Annotations[api.ClusterAnnotationOwner] = owner
 			}
 
 			newObj, err := cc.Apply(ctx, obj)
 			if err != nil {
+				r.Failed(obj.Kind, obj.Name, err, time.Since(start))
 				return err
 			}
 
-			err = printer.PrintObj(newObj, o.Out)
+			if owner != "" && cluster.OwnerOf(newObj) != owner {
+				err = cc.SetOwner(ctx, newObj.Name, owner)
+				if err == nil {
+					newObj, err = cc.Get(ctx, newObj.Name)
+				}
+				if err != nil {
+					r.Failed(obj.Kind, obj.Name, err, time.Since(start))
+					return err
+				}
+			}
+
+			if o.Wait > 0 {
+				newObj, err = cc.WaitForReady(ctx, newObj.Name, o.waitOptions())
+				if err != nil {
+					r.Failed(obj.Kind, obj.Name, err, time.Since(start))
+					return err
+				}
+			}
+
+			action := clusterAction(existing, newObj)
+			if o.AnnotateGit && action == reporter.ActionCreated {
+				err = cc.AnnotateWithGitInfo(ctx, newObj.Name)
+				if err == nil {
+					newObj, err = cc.Get(ctx, newObj.Name)
+				}
+				if err != nil {
+					r.Failed(obj.Kind, obj.Name, err, time.Since(start))
+					return err
+				}
+			}
+
+			err = r.Applied(newObj, reporter.Result{
+				Kind:     obj.Kind,
+				Name:     newObj.Name,
+				Action:   action,
+				Duration: time.Since(start),
+			})
 			if err != nil {
 				return err
 			}
@@ -141,3 +408,83 @@ func (o *ApplyOptions) run() error {
 	}
 	return nil
 }
+
+// Compares a cluster before and after apply to figure out what apply did.
+//
+// Apply deletes and re-creates clusters that it can't reconcile in place,
+// so a new creation timestamp also counts as created.
+func clusterAction(before, after *api.Cluster) reporter.Action {
+	if before == nil || !before.Status.CreationTimestamp.Equal(&after.Status.CreationTimestamp) {
+		return reporter.ActionCreated
+	}
+	b := before.DeepCopy()
+	a := after.DeepCopy()
+	b.Status = api.ClusterStatus{}
+	a.Status = api.ClusterStatus{}
+	if equality.Semantic.DeepEqual(a, b) {
+		return reporter.ActionUnchanged
+	}
+	return reporter.ActionUpdated
+}
+
+// Compares a registry before and after apply to figure out what apply did.
+//
+// Registries are never changed in place, so a new container means an update.
+func registryAction(before, after *api.Registry) reporter.Action {
+	if before == nil {
+		return reporter.ActionCreated
+	}
+	if before.Status.ContainerID != after.Status.ContainerID {
+		return reporter.ActionUpdated
+	}
+	return reporter.ActionUnchanged
+}
+
+// Looks up variables from --var flags first, then the environment.
+func (o *ApplyOptions) varLookup() (visitor.VarLookup, error) {
+	vars := make(map[string]string, len(o.Vars))
+	for _, v := range o.Vars {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --var %q: must be KEY=VALUE", v)
+		}
+		vars[key] = value
+	}
+	return func(name string) (string, bool) {
+		if value, ok := vars[name]; ok {
+			return value, true
+		}
+		return os.LookupEnv(name)
+	}, nil
+}
+
+// Applies each --set to every object that has the field. An override
+// that no object has is an error.
+func (o *ApplyOptions) applyOverrides(objects []runtime.Object) error {
+	for _, set := range o.Sets {
+		path, value, ok := strings.Cut(set, "=")
+		if !ok || path == "" {
+			return fmt.Errorf("invalid --set %q: must be PATH=VALUE", set)
+		}
+
+		var unknownErr error
+		applied := false
+		for _, obj := range objects {
+			err := manifest.ApplyOverrides(obj, map[string]string{path: value})
+			if err != nil {
+				if _, ok := err.(*manifest.UnknownFieldError); ok {
+					if unknownErr == nil {
+						unknownErr = fmt.Errorf("--set %s: %s: %v", set, obj.GetObjectKind().GroupVersionKind().Kind, err)
+					}
+					continue
+				}
+				return fmt.Errorf("--set %s: %v", set, err)
+			}
+			applied = true
+		}
+		if !applied && unknownErr != nil {
+			return unknownErr
+		}
+	}
+	return nil
+}
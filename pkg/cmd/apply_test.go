@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/internal/egress"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/reporter"
+	"github.com/tilt-dev/ctlptl/pkg/visitor"
+)
+
+const parameterizedConfig = `apiVersion: ctlptl.dev/v1alpha1
+kind: Cluster
+product: ${PRODUCT}
+registry: ${REGISTRY}
+kubeconfigServer: ${SERVER}
+defaultNamespace: $${NOT_A_VAR}
+`
+
+func decodeWithVars(t *testing.T, config string, vars []string) ([]*api.Cluster, error) {
+	path := filepath.Join(t.TempDir(), "cluster.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0644))
+
+	o := NewApplyOptions()
+	o.Vars = vars
+	lookup, err := o.varLookup()
+	if err != nil {
+		return nil, err
+	}
+	objs, err := visitor.DecodeAllWithVars([]visitor.Interface{visitor.File(path)}, lookup)
+	if err != nil {
+		return nil, err
+	}
+	result := []*api.Cluster{}
+	for _, obj := range objs {
+		result = append(result, obj.(*api.Cluster))
+	}
+	return result, nil
+}
+
+func TestApplyVars(t *testing.T) {
+	t.Setenv("PRODUCT", "minikube")
+	t.Setenv("REGISTRY", "env-registry")
+
+	clusters, err := decodeWithVars(t, parameterizedConfig, []string{
+		"REGISTRY=flag-registry",
+		"SERVER=https://127.0.0.1:6443/?a=b",
+	})
+	require.NoError(t, err)
+	require.Len(t, clusters, 1)
+
+	// Environment variables fill in anything not passed with --var.
+	assert.Equal(t, "minikube", clusters[0].Product)
+	// --var takes priority over the environment.
+	assert.Equal(t, "flag-registry", clusters[0].Registry)
+	// Only the first = separates the key from the value.
+	assert.Equal(t, "https://127.0.0.1:6443/?a=b", clusters[0].KubeconfigServer)
+	// $$ escapes interpolation.
+	assert.Equal(t, "${NOT_A_VAR}", clusters[0].DefaultNamespace)
+}
+
+func TestApplyVarsLastWins(t *testing.T) {
+	clusters, err := decodeWithVars(t, "kind: Cluster\napiVersion: ctlptl.dev/v1alpha1\nproduct: ${PRODUCT}\n",
+		[]string{"PRODUCT=kind", "PRODUCT=minikube"})
+	require.NoError(t, err)
+	assert.Equal(t, "minikube", clusters[0].Product)
+}
+
+func TestApplyVarsUndefined(t *testing.T) {
+	_, err := decodeWithVars(t, parameterizedConfig, []string{"PRODUCT=kind", "REGISTRY=r"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "line 5: variable SERVER is not defined")
+	}
+}
+
+func TestApplyVarsInvalid(t *testing.T) {
+	_, err := decodeWithVars(t, parameterizedConfig, []string{"=value"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid --var "=value": must be KEY=VALUE`)
+	}
+
+	_, err = decodeWithVars(t, parameterizedConfig, []string{"PRODUCT"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid --var "PRODUCT": must be KEY=VALUE`)
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	o := NewApplyOptions()
+	o.Sets = []string{"workers=5", "port=5005", "workers=6"}
+	cluster := &api.Cluster{Product: "kwok"}
+	registry := &api.Registry{Name: "ctlptl-registry"}
+	err := o.applyOverrides([]runtime.Object{cluster, registry})
+	require.NoError(t, err)
+
+	// Each override applies to the objects that have the field. The last one wins.
+	assert.Equal(t, 6, cluster.Workers)
+	assert.Equal(t, 5005, registry.Port)
+}
+
+func TestApplyOverridesInvalid(t *testing.T) {
+	o := NewApplyOptions()
+	o.Sets = []string{"workerz=5"}
+	err := o.applyOverrides([]runtime.Object{&api.Cluster{TypeMeta: api.TypeMeta{Kind: "Cluster"}}})
+	if assert.Error(t, err) {
+		assert.Equal(t, `--set workerz=5: Cluster: invalid path "workerz": unrecognized field "workerz"`, err.Error())
+	}
+
+	o.Sets = []string{"workers=five"}
+	err = o.applyOverrides([]runtime.Object{&api.Cluster{}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid value for "workers": expected an integer, got "five"`)
+	}
+
+	o.Sets = []string{"workers"}
+	err = o.applyOverrides([]runtime.Object{&api.Cluster{}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid --set "workers": must be PATH=VALUE`)
+	}
+}
+
+func TestApplyValidateOnExists(t *testing.T) {
+	o := NewApplyOptions()
+	assert.NoError(t, o.validate())
+
+	o.OnExists = "error"
+	if assert.Error(t, o.validate()) {
+		assert.Contains(t, o.validate().Error(), "--on-exists only applies with --create-only")
+	}
+
+	o.CreateOnly = true
+	assert.NoError(t, o.validate())
+
+	o.OnExists = "replace"
+	if assert.Error(t, o.validate()) {
+		assert.Contains(t, o.validate().Error(), "--on-exists must be one of: skip, error. Actual: replace")
+	}
+}
+
+func TestApplyValidateWaitOptions(t *testing.T) {
+	o := NewApplyOptions()
+	o.Wait = time.Minute
+	o.WaitBackoff = 1.5
+	assert.NoError(t, o.validate())
+
+	o.WaitBackoff = 0.5
+	if assert.Error(t, o.validate()) {
+		assert.Contains(t, o.validate().Error(), "--wait options: backoff must be at least 1. Actual: 0.5")
+	}
+
+	o.WaitBackoff = 0
+	o.WaitMaxAttempts = -1
+	if assert.Error(t, o.validate()) {
+		assert.Contains(t, o.validate().Error(), "--wait options: max attempts must not be negative")
+	}
+}
+
+func TestApplyDryRunRedactsRegistrySecrets(t *testing.T) {
+	t.Setenv(egress.EnvVar, "true")
+	t.Setenv(reporter.GitHubActionsEnv, "")
+	path := filepath.Join(t.TempDir(), "registry.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`apiVersion: ctlptl.dev/v1alpha1
+kind: Registry
+name: ctlptl-registry
+httpSecret: s3cr3t
+auth:
+  username: me
+  password: hunter2
+webhook:
+  url: http://host.docker.internal:8080/events
+  headers:
+    Authorization: Bearer abc123
+`), 0644))
+
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewApplyOptions()
+	o.IOStreams = streams
+	o.Filenames = []string{path}
+	o.DryRun = true
+	*o.PrintFlags.OutputFormat = "yaml"
+	require.NoError(t, o.run())
+
+	assert.Contains(t, out.String(), "name: ctlptl-registry")
+	assert.Contains(t, out.String(), "httpSecret: <redacted>")
+	assert.Contains(t, out.String(), "password: <redacted>")
+	assert.Contains(t, out.String(), "Authorization: <redacted>")
+	assert.NotContains(t, out.String(), "s3cr3t")
+	assert.NotContains(t, out.String(), "hunter2")
+	assert.NotContains(t, out.String(), "abc123")
+}
+
+func TestApplyClusterAction(t *testing.T) {
+	now := metav1.Now()
+	before := &api.Cluster{Name: "kind-kind", Product: "kind", Status: api.ClusterStatus{CreationTimestamp: now}}
+
+	assert.Equal(t, reporter.ActionCreated, clusterAction(nil, before))
+
+	after := before.DeepCopy()
+	after.Status.Current = true
+	assert.Equal(t, reporter.ActionUnchanged, clusterAction(before, after))
+
+	after.DefaultNamespace = "dev"
+	assert.Equal(t, reporter.ActionUpdated, clusterAction(before, after))
+
+	recreated := before.DeepCopy()
+	recreated.Status.CreationTimestamp = metav1.NewTime(now.Add(time.Minute))
+	assert.Equal(t, reporter.ActionCreated, clusterAction(before, recreated))
+}
+
+func TestApplyRegistryAction(t *testing.T) {
+	before := &api.Registry{Name: "ctlptl-registry", Status: api.RegistryStatus{ContainerID: "a"}}
+	assert.Equal(t, reporter.ActionCreated, registryAction(nil, before))
+	assert.Equal(t, reporter.ActionUnchanged, registryAction(before, before.DeepCopy()))
+
+	after := before.DeepCopy()
+	after.Status.ContainerID = "b"
+	assert.Equal(t, reporter.ActionUpdated, registryAction(before, after))
+}
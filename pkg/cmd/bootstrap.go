@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tilt-dev/clusterid"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+type BootstrapOptions struct {
+	genericclioptions.IOStreams
+
+	Provider string
+	Name     string
+}
+
+func NewBootstrapOptions() *BootstrapOptions {
+	return &BootstrapOptions{
+		IOStreams: genericclioptions.IOStreams{Out: os.Stdout, ErrOut: os.Stderr, In: os.Stdin},
+	}
+}
+
+func (o *BootstrapOptions) Command() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "bootstrap",
+		Short: "Create a cluster with a local registry, using the best available provider",
+		Long: "Create a cluster with a local registry, using the best available provider.\n\n" +
+			"Uses the first of kind, k3d, or minikube that's installed, and names the cluster " +
+			"after the current directory. Waits for the cluster to be ready, switches the " +
+			"kubectl context to it, then prints its kubeconfig.",
+		Example: "  ctlptl bootstrap\n" +
+			"  ctlptl bootstrap --provider=k3d --name=my-app",
+		Run:  o.Run,
+		Args: cobra.NoArgs,
+	}
+
+	cmd.SetOut(o.Out)
+	cmd.SetErr(o.ErrOut)
+	cmd.Flags().StringVar(&o.Provider, "provider", o.Provider, "The cluster provider: kind, k3d, or minikube. If not specified, uses the first one installed")
+	cmd.Flags().StringVar(&o.Name, "name", o.Name, "The cluster name. If not specified, uses the name of the current directory")
+
+	return cmd
+}
+
+func (o *BootstrapOptions) Run(cmd *cobra.Command, args []string) {
+	a, err := newAnalytics()
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "analytics: %v\n", err)
+		os.Exit(1)
+	}
+	a.Incr("cmd.bootstrap", nil)
+	defer a.Flush(time.Second)
+
+	c, err := newClusterController(o.IOStreams)
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "Loading controller: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = o.run(c)
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+type bootstrapper interface {
+	Bootstrap(ctx context.Context, options cluster.BootstrapOptions) (*api.Cluster, error)
+	Kubeconfig(name string) ([]byte, error)
+}
+
+func (o *BootstrapOptions) run(c bootstrapper) error {
+	cl, err := c.Bootstrap(context.Background(), cluster.BootstrapOptions{
+		Product: clusterid.Product(o.Provider),
+		Name:    o.Name,
+	})
+	if err != nil {
+		return err
+	}
+
+	kubeconfig, err := c.Kubeconfig(cl.Name)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(o.ErrOut, " ✅ Cluster %s is ready, with registry %s\n", cl.Name, cl.Registry)
+	_, err = o.Out.Write(kubeconfig)
+	return err
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+type bundleOptions struct {
+	Output  string
+	version string
+}
+
+func NewBundleCommand() *cobra.Command {
+	o := &bundleOptions{}
+	var cmd = &cobra.Command{
+		Use:   "bundle [cluster]",
+		Short: "Export cluster diagnostics as a support bundle",
+		Long: "Export cluster diagnostics as a support bundle.\n\n" +
+			"Writes a gzipped tarball with the logs of each node container, the configs that " +
+			"ctlptl generates for kind or k3d, the registry config on each node, 'docker info', " +
+			"the versions of ctlptl and the cluster tool, and the cluster spec. " +
+			"Values that look like secrets are redacted.",
+		Example: "  ctlptl bundle kind-kind -o bundle.tar.gz",
+		Args:    cobra.ExactArgs(1),
+	}
+	run := withClusterController("bundle", o.run)
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		o.version = cmd.Root().Version
+		run(cmd, args)
+	}
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "",
+		"The path of the bundle. Defaults to <cluster>-bundle.tar.gz in the current directory")
+	return cmd
+}
+
+func (o *bundleOptions) run(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	output := o.Output
+	if output == "" {
+		output = fmt.Sprintf("%s-bundle.tar.gz", cl.Name)
+	}
+
+	// Write to a temp file first, so that we never leave a truncated bundle behind.
+	f, err := os.CreateTemp(filepath.Dir(output), ".ctlptl-bundle-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+
+	err = c.WriteBundle(ctx, cl.Name, f, cluster.BundleOptions{Version: o.version})
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(f.Name(), output)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(streams.ErrOut, "Wrote support bundle for cluster %s to %s\n", cl.Name, output)
+	return nil
+}
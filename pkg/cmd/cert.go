@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+func NewCertCommand() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:     "cert",
+		Short:   "Inspect the certificates that secure a cluster",
+		Example: "  ctlptl cert inspect kind-kind",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "inspect [cluster]",
+		Short: "Print the subject, SANs, and expiry date of each cluster certificate",
+		Run:   withClusterController("cert-inspect", certInspect),
+		Args:  cobra.ExactArgs(1),
+	})
+
+	return cmd
+}
+
+func certInspect(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	certs, err := c.GetCerts(ctx, cl.Name)
+	if err != nil {
+		return err
+	}
+
+	return printers.NewTablePrinter(printers.PrintOptions{}).PrintObj(certsAsTable(certs, time.Now()), streams.Out)
+}
+
+func certsAsTable(certs map[string]*x509.Certificate, now time.Time) *metav1.Table {
+	table := metav1.Table{
+		TypeMeta: metav1.TypeMeta{Kind: "Table", APIVersion: "metav1.k8s.io"},
+		ColumnDefinitions: []metav1.TableColumnDefinition{
+			metav1.TableColumnDefinition{
+				Name: "Name",
+				Type: "string",
+			},
+			metav1.TableColumnDefinition{
+				Name: "Subject",
+				Type: "string",
+			},
+			metav1.TableColumnDefinition{
+				Name: "SANs",
+				Type: "string",
+			},
+			metav1.TableColumnDefinition{
+				Name: "Expires",
+				Type: "string",
+			},
+		},
+	}
+
+	names := make([]string, 0, len(certs))
+	for name := range certs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cert := certs[name]
+		sans := append([]string{}, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		sansStr := strings.Join(sans, ",")
+		if sansStr == "" {
+			sansStr = "none"
+		}
+
+		expires := cert.NotAfter.UTC().Format("2006-01-02")
+		if now.After(cert.NotAfter) {
+			expires += " (expired)"
+		} else {
+			expires += fmt.Sprintf(" (in %s)", duration.ShortHumanDuration(cert.NotAfter.Sub(now)))
+		}
+
+		table.Rows = append(table.Rows, metav1.TableRow{
+			Cells: []interface{}{
+				name,
+				cert.Subject.String(),
+				sansStr,
+				expires,
+			},
+		})
+	}
+
+	return &table
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+type ClusterDNSOptions struct {
+	genericclioptions.IOStreams
+}
+
+func NewClusterDNSOptions() *ClusterDNSOptions {
+	return &ClusterDNSOptions{
+		IOStreams: genericclioptions.IOStreams{Out: os.Stdout, ErrOut: os.Stderr, In: os.Stdin},
+	}
+}
+
+func (o *ClusterDNSOptions) Command() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "cluster-dns [cluster]",
+		Short: "Print the IP of a cluster's DNS server",
+		Long: "Print the IP of a cluster's DNS server, the ClusterIP of the kube-dns service in kube-system.\n\n" +
+			"If the cluster is unreachable, prints the default IP for the product (10.96.0.10, " +
+			"or 10.43.0.10 on k3d) with a warning.",
+		Example: "  ctlptl cluster-dns kind-kind\n" +
+			"  dig @$(ctlptl cluster-dns kind-kind) kubernetes.default.svc.cluster.local",
+		Run:  o.Run,
+		Args: cobra.ExactArgs(1),
+	}
+
+	cmd.SetOut(o.Out)
+	cmd.SetErr(o.ErrOut)
+
+	return cmd
+}
+
+func (o *ClusterDNSOptions) Run(cmd *cobra.Command, args []string) {
+	a, err := newAnalytics()
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "analytics: %v\n", err)
+		os.Exit(1)
+	}
+	a.Incr("cmd.cluster-dns", nil)
+	defer a.Flush(time.Second)
+
+	c, err := newClusterController(o.IOStreams)
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "Loading controller: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = o.run(c, args[0])
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+type clusterDNSGetter interface {
+	clusterGetter
+	GetClusterDNS(ctx context.Context, clusterName string) (string, error)
+}
+
+func (o *ClusterDNSOptions) run(c clusterDNSGetter, name string) error {
+	ctx := context.Background()
+	cluster, err := normalizedGet(ctx, c, name)
+	if err != nil {
+		return err
+	}
+
+	ip, err := c.GetClusterDNS(ctx, cluster.Name)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(o.Out, ip)
+	return nil
+}
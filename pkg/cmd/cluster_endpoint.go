@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+type ClusterEndpointOptions struct {
+	genericclioptions.IOStreams
+
+	FromInsideCluster bool
+}
+
+func NewClusterEndpointOptions() *ClusterEndpointOptions {
+	return &ClusterEndpointOptions{
+		IOStreams: genericclioptions.IOStreams{Out: os.Stdout, ErrOut: os.Stderr, In: os.Stdin},
+	}
+}
+
+func (o *ClusterEndpointOptions) Command() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "cluster-endpoint [cluster]",
+		Short: "Print the URL of a cluster's apiserver",
+		Long: "Print the URL of a cluster's apiserver.\n\n" +
+			"By default, prints the URL from the kubeconfig, which usually only works from the host. " +
+			"With --from-inside-cluster, prints a URL that works from inside the cluster's Docker network " +
+			"(e.g., from a pod, or a container on the same network), using the IP of the control-plane " +
+			"node's container. --from-inside-cluster only works on kind and k3d clusters.",
+		Example: "  ctlptl cluster-endpoint kind-kind\n" +
+			"  ctlptl cluster-endpoint kind-kind --from-inside-cluster",
+		Run:  o.Run,
+		Args: cobra.ExactArgs(1),
+	}
+
+	cmd.SetOut(o.Out)
+	cmd.SetErr(o.ErrOut)
+	cmd.Flags().BoolVar(&o.FromInsideCluster, "from-inside-cluster", o.FromInsideCluster,
+		"Print a URL that's reachable from inside the cluster's Docker network, instead of from the host")
+
+	return cmd
+}
+
+func (o *ClusterEndpointOptions) Run(cmd *cobra.Command, args []string) {
+	a, err := newAnalytics()
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "analytics: %v\n", err)
+		os.Exit(1)
+	}
+	a.Incr("cmd.cluster-endpoint", nil)
+	defer a.Flush(time.Second)
+
+	c, err := newClusterController(o.IOStreams)
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "Loading controller: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = o.run(c, args[0])
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+type endpointGetter interface {
+	clusterGetter
+	GetEndpoint(ctx context.Context, clusterName string) (string, error)
+	GetReachableEndpoint(ctx context.Context, clusterName string) (string, error)
+}
+
+func (o *ClusterEndpointOptions) run(c endpointGetter, name string) error {
+	ctx := context.Background()
+	cluster, err := normalizedGet(ctx, c, name)
+	if err != nil {
+		return err
+	}
+
+	var endpoint string
+	if o.FromInsideCluster {
+		endpoint, err = c.GetReachableEndpoint(ctx, cluster.Name)
+	} else {
+		endpoint, err = c.GetEndpoint(ctx, cluster.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(o.Out, endpoint)
+	return nil
+}
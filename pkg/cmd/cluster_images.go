@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+func NewClusterImagesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "cluster-images [cluster]",
+		Short: "Print the images cached on any of a cluster's nodes, one per line",
+		Long: "Print the images cached on any of a cluster's nodes, one per line, for scripts " +
+			"that only load an image if it isn't there yet.\n\n" +
+			"Runs ctr in each node container, so only works on clusters whose nodes are " +
+			"Docker containers (kind and k3d). An image may be on some nodes and not others. " +
+			"Use 'ctlptl images' to see which.",
+		Example: "  ctlptl cluster-images kind-kind\n" +
+			"  ctlptl cluster-images kind-kind | grep my-app || kind load docker-image my-app",
+		Run:  withClusterController("cluster-images", clusterImages),
+		Args: cobra.ExactArgs(1),
+	}
+}
+
+func clusterImages(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	images, err := c.GetAvailableImages(ctx, cl.Name)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		_, _ = fmt.Fprintln(streams.Out, image)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+type ContainerIDOptions struct {
+	genericclioptions.IOStreams
+
+	Node string
+}
+
+func NewContainerIDOptions() *ContainerIDOptions {
+	return &ContainerIDOptions{
+		IOStreams: genericclioptions.IOStreams{Out: os.Stdout, ErrOut: os.Stderr, In: os.Stdin},
+	}
+}
+
+func (o *ContainerIDOptions) Command() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "container-id [cluster]",
+		Short: "Print the Docker container ID of a cluster node",
+		Long: "Print the Docker container ID of a cluster node.\n\n" +
+			"Defaults to the first control-plane node. Useful for passing to other tools, " +
+			"like 'docker exec'.",
+		Example: "  ctlptl container-id kind-kind\n" +
+			"  docker exec -it $(ctlptl container-id kind-kind --node=kind-worker) bash",
+		Run:  o.Run,
+		Args: cobra.ExactArgs(1),
+	}
+
+	cmd.SetOut(o.Out)
+	cmd.SetErr(o.ErrOut)
+	cmd.Flags().StringVar(&o.Node, "node", o.Node, "The name of the node. If not specified, uses the first control-plane node")
+
+	return cmd
+}
+
+func (o *ContainerIDOptions) Run(cmd *cobra.Command, args []string) {
+	a, err := newAnalytics()
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "analytics: %v\n", err)
+		os.Exit(1)
+	}
+	a.Incr("cmd.container-id", nil)
+	defer a.Flush(time.Second)
+
+	c, err := newClusterController(o.IOStreams)
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "Loading controller: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = o.run(c, args[0])
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+type containerIDGetter interface {
+	clusterGetter
+	GetContainerID(ctx context.Context, clusterName, nodeName string) (string, error)
+}
+
+func (o *ContainerIDOptions) run(c containerIDGetter, name string) error {
+	ctx := context.Background()
+	cluster, err := normalizedGet(ctx, c, name)
+	if err != nil {
+		return err
+	}
+
+	id, err := c.GetContainerID(ctx, cluster.Name, o.Node)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(o.Out, id)
+	return nil
+}
@@ -13,8 +13,10 @@ import (
 
 	"github.com/tilt-dev/clusterid"
 
+	"github.com/tilt-dev/ctlptl/internal/audit"
 	"github.com/tilt-dev/ctlptl/pkg/api"
 	"github.com/tilt-dev/ctlptl/pkg/cluster"
+	"github.com/tilt-dev/ctlptl/pkg/reporter"
 )
 
 type CreateClusterOptions struct {
@@ -22,6 +24,7 @@ type CreateClusterOptions struct {
 	genericclioptions.IOStreams
 
 	Cluster *api.Cluster
+	Owner   string
 }
 
 func NewCreateClusterOptions() *CreateClusterOptions {
@@ -55,6 +58,8 @@ func (o *CreateClusterOptions) Command() *cobra.Command {
 		o.Cluster.Name, "Names the context. If not specified, uses the default cluster name for this Kubernetes product")
 	cmd.Flags().IntVar(&o.Cluster.MinCPUs, "min-cpus",
 		o.Cluster.MinCPUs, "Sets the minimum CPUs for the cluster")
+	cmd.Flags().IntVar(&o.Cluster.Workers, "workers",
+		o.Cluster.Workers, "Sets the number of worker nodes. Only supported on kwok clusters")
 	cmd.Flags().StringVar(&o.Cluster.KubernetesVersion, "kubernetes-version",
 		o.Cluster.KubernetesVersion, "Sets the kubernetes version for the cluster, if possible")
 	cmd.Flags().StringSliceVar(&o.Cluster.Minikube.StartFlags, "minikube-start-flags",
@@ -63,12 +68,14 @@ func (o *CreateClusterOptions) Command() *cobra.Command {
 		o.Cluster.Minikube.ExtraConfigs, "Minikube extra configs (only applicable to a minikube cluster)")
 	cmd.Flags().StringVar(&o.Cluster.Minikube.ContainerRuntime, "minikube-container-runtime",
 		o.Cluster.Minikube.ContainerRuntime, "Minikube container runtime (only applicable to a minikube cluster)")
+	cmd.Flags().StringVar(&o.Owner, "owner",
+		o.Owner, "The user to record as the owner of the cluster. Defaults to $CTLPTL_OWNER, then $USER")
 
 	return cmd
 }
 
 func (o *CreateClusterOptions) Run(cmd *cobra.Command, args []string) {
-	controller, err := cluster.DefaultController(o.IOStreams)
+	controller, err := newClusterController(o.IOStreams)
 	if err != nil {
 		_, _ = fmt.Fprintf(o.ErrOut, "%v\n", err)
 		os.Exit(1)
@@ -104,6 +111,17 @@ func (o *CreateClusterOptions) run(controller clusterCreator, product string) er
 
 	cluster.FillDefaults(o.Cluster)
 
+	owner := o.Owner
+	if owner == "" {
+		owner = cluster.DefaultOwner()
+	}
+	if owner != "" {
+		if o.Cluster.Annotations == nil {
+			o.Cluster.Annotations = make(map[string]string)
+		}
+		o.Cluster.Annotations[api.ClusterAnnotationOwner] = owner
+	}
+
 	ctx := context.Background()
 	_, err = controller.Get(ctx, o.Cluster.Name)
 	if err == nil {
@@ -113,9 +131,13 @@ func (o *CreateClusterOptions) run(controller clusterCreator, product string) er
 	}
 
 	applied, err := controller.Apply(ctx, o.Cluster)
+	auditErr := audit.Record("create", "Cluster", o.Cluster.Name, string(reporter.ActionCreated), err)
 	if err != nil {
 		return err
 	}
+	if auditErr != nil {
+		return auditErr
+	}
 
 	printer, err := toPrinter(o.PrintFlags)
 	if err != nil {
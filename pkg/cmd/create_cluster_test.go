@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"context"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -11,6 +13,7 @@ import (
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 
 	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
 )
 
 func TestCreateCluster(t *testing.T) {
@@ -25,6 +28,18 @@ func TestCreateCluster(t *testing.T) {
 	assert.Equal(t, "kind-kind", fcc.lastApplyName)
 }
 
+func TestCreateClusterOwner(t *testing.T) {
+	streams, _, _, _ := genericclioptions.NewTestIOStreams()
+	o := NewCreateClusterOptions()
+	o.IOStreams = streams
+	o.Owner = "alice"
+
+	fcc := &fakeClusterController{}
+	err := o.run(fcc, "kind")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", cluster.OwnerOf(fcc.clusters["kind-kind"]))
+}
+
 type fakeClusterController struct {
 	clusters       map[string]*api.Cluster
 	lastApplyName  string
@@ -50,6 +65,25 @@ func (cd *fakeClusterController) Apply(ctx context.Context, cluster *api.Cluster
 	return cluster, nil
 }
 
+func (cd *fakeClusterController) List(ctx context.Context, options cluster.ListOptions) (*api.ClusterList, error) {
+	names := make([]string, 0, len(cd.clusters))
+	for name := range cd.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := &api.ClusterList{}
+	for _, name := range names {
+		c := cd.clusters[name]
+		created := c.Status.CreationTimestamp.Time
+		if options.OlderThan > 0 && (created.IsZero() || time.Since(created) < options.OlderThan) {
+			continue
+		}
+		list.Items = append(list.Items, *c)
+	}
+	return list, nil
+}
+
 func (cd *fakeClusterController) Get(ctx context.Context, name string) (*api.Cluster, error) {
 	cluster, ok := cd.clusters[name]
 	if ok {
@@ -10,8 +10,10 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 
+	"github.com/tilt-dev/ctlptl/internal/audit"
 	"github.com/tilt-dev/ctlptl/pkg/api"
 	"github.com/tilt-dev/ctlptl/pkg/registry"
+	"github.com/tilt-dev/ctlptl/pkg/reporter"
 )
 
 type CreateRegistryOptions struct {
@@ -52,6 +54,8 @@ func (o *CreateRegistryOptions) Command() *cobra.Command {
 		"The host's IP address to bind the container to. If not set defaults to 127.0.0.1")
 	cmd.Flags().StringVar(&o.Registry.Image, "image", registry.DefaultRegistryImageRef,
 		"Registry image to use")
+	cmd.Flags().IntVar(&o.Registry.ReplicaCount, "replicas", o.Registry.ReplicaCount,
+		"The number of registry containers to run behind a load balancer. Defaults to 1")
 
 	return cmd
 }
@@ -95,9 +99,13 @@ func (o *CreateRegistryOptions) run(controller registryCreator, name string) err
 	}
 
 	applied, err := controller.Apply(ctx, o.Registry)
+	auditErr := audit.Record("create", "Registry", o.Registry.Name, string(reporter.ActionCreated), err)
 	if err != nil {
 		return err
 	}
+	if auditErr != nil {
+		return auditErr
+	}
 
 	printer, err := toPrinter(o.PrintFlags)
 	if err != nil {
@@ -11,6 +11,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 
+	"github.com/tilt-dev/ctlptl/internal/audit"
 	"github.com/tilt-dev/ctlptl/pkg/api"
 	"github.com/tilt-dev/ctlptl/pkg/cluster"
 	"github.com/tilt-dev/ctlptl/pkg/registry"
@@ -24,6 +25,12 @@ type DeleteOptions struct {
 
 	IgnoreNotFound bool
 	Filenames      []string
+	OlderThan      time.Duration
+
+	// Bulk deletes (--older-than) only delete clusters that Owner owns,
+	// unless AllOwners is set. Bulk deletes of registries need AllOwners.
+	Owner     string
+	AllOwners bool
 
 	// We currently only support two modes - "true" and "false".
 	// But we expect that there may be more modes in the future
@@ -32,6 +39,7 @@ type DeleteOptions struct {
 
 	clusterController clusterController
 	registryDeleter   deleter
+	registryLister    registryLister
 }
 
 func NewDeleteOptions() *DeleteOptions {
@@ -48,7 +56,10 @@ func (o *DeleteOptions) Command() *cobra.Command {
 		Use:   "delete -f FILENAME",
 		Short: "Delete a currently running cluster",
 		Example: "  ctlptl delete -f cluster.yaml\n" +
-			"  ctlptl delete cluster minikube",
+			"  ctlptl delete cluster minikube\n" +
+			"  ctlptl delete cluster --older-than 24h\n" +
+			"  ctlptl delete cluster --older-than 24h --all-owners\n" +
+			"  ctlptl delete registry --older-than 24h --all-owners",
 		Run: o.Run,
 	}
 
@@ -57,6 +68,14 @@ func (o *DeleteOptions) Command() *cobra.Command {
 	o.FileNameFlags.AddFlags(cmd.Flags())
 
 	cmd.Flags().BoolVar(&o.IgnoreNotFound, "ignore-not-found", o.IgnoreNotFound, "If the requested object does not exist the command will return exit code 0.")
+	cmd.Flags().DurationVar(&o.OlderThan, "older-than", o.OlderThan,
+		"Delete every object of the given type created more than this long ago (e.g. 24h), instead of naming them. "+
+			"Clusters whose creation time can't be read are never deleted.")
+	cmd.Flags().StringVar(&o.Owner, "owner", o.Owner,
+		"With --older-than, only delete clusters that this user owns. Defaults to $CTLPTL_OWNER, then $USER")
+	cmd.Flags().BoolVar(&o.AllOwners, "all-owners", o.AllOwners,
+		"With --older-than, delete clusters no matter who owns them, including clusters with no recorded owner. "+
+			"Required to delete registries by age, since they don't record an owner")
 	cmd.Flags().StringVar(&o.Cascade, "cascade", "false",
 		"If 'true', objects will be deleted recursively. "+
 			"For example, deleting a cluster will delete any connected registries. Defaults to 'false'.")
@@ -79,6 +98,11 @@ type deleter interface {
 type clusterController interface {
 	deleter
 	Get(ctx context.Context, name string) (*api.Cluster, error)
+	List(ctx context.Context, options cluster.ListOptions) (*api.ClusterList, error)
+}
+
+type registryLister interface {
+	List(ctx context.Context, options registry.ListOptions) (*api.RegistryList, error)
 }
 
 func (o *DeleteOptions) run(args []string) error {
@@ -94,12 +118,17 @@ func (o *DeleteOptions) run(args []string) error {
 		return err
 	}
 
-	resources, err := o.parseExplicitResources(args)
+	ctx := context.TODO()
+	var resources []runtime.Object
+	if o.OlderThan != 0 {
+		resources, err = o.listOldResources(ctx, args)
+	} else {
+		resources, err = o.parseExplicitResources(args)
+	}
 	if err != nil {
 		return err
 	}
 
-	ctx := context.TODO()
 	resources, err = o.cascadeResources(ctx, resources)
 	if err != nil {
 		return err
@@ -130,13 +159,17 @@ func (o *DeleteOptions) run(args []string) error {
 			}
 
 			err = controller.Delete(ctx, name)
+			if err != nil && o.IgnoreNotFound && errors.IsNotFound(err) {
+				continue
+			}
 
+			auditErr := audit.Record("delete", "Cluster", name, audit.ActionDeleted, err)
 			if err != nil {
-				if o.IgnoreNotFound && errors.IsNotFound(err) {
-					continue
-				}
 				return err
 			}
+			if auditErr != nil {
+				return auditErr
+			}
 			err = printer.PrintObj(resource, o.Out)
 			if err != nil {
 				return err
@@ -151,12 +184,17 @@ func (o *DeleteOptions) run(args []string) error {
 
 			registry.FillDefaults(resource)
 			err := o.registryDeleter.Delete(ctx, resource.Name)
+			if err != nil && o.IgnoreNotFound && errors.IsNotFound(err) {
+				continue
+			}
+
+			auditErr := audit.Record("delete", "Registry", resource.Name, audit.ActionDeleted, err)
 			if err != nil {
-				if o.IgnoreNotFound && errors.IsNotFound(err) {
-					continue
-				}
 				return err
 			}
+			if auditErr != nil {
+				return auditErr
+			}
 			err = printer.PrintObj(resource, o.Out)
 			if err != nil {
 				return err
@@ -211,9 +249,82 @@ func (o *DeleteOptions) parseExplicitResources(args []string) ([]runtime.Object,
 	return resources, nil
 }
 
+// Finds every object of the given type that's older than --older-than.
+//
+// Clusters are scoped to the current owner. Registries don't record an owner,
+// so deleting them by age needs --all-owners.
+func (o *DeleteOptions) listOldResources(ctx context.Context, args []string) ([]runtime.Object, error) {
+	if o.OlderThan < 0 {
+		return nil, fmt.Errorf("--older-than must be positive. Actual: %s", o.OlderThan)
+	}
+	if len(o.Filenames) > 0 || len(args) != 1 {
+		return nil, fmt.Errorf("--older-than selects objects by age. Specify only a type ('ctlptl delete cluster --older-than 24h')")
+	}
+
+	var resources []runtime.Object
+	switch args[0] {
+	case "cluster", "clusters":
+		controller, err := o.getClusterController()
+		if err != nil {
+			return nil, err
+		}
+		list, err := controller.List(ctx, cluster.ListOptions{OlderThan: o.OlderThan})
+		if err != nil {
+			return nil, err
+		}
+		owner := o.Owner
+		if owner == "" {
+			owner = cluster.DefaultOwner()
+		}
+		for _, item := range list.Items {
+			itemOwner := cluster.OwnerOf(&item)
+			if !o.AllOwners && itemOwner != owner {
+				if itemOwner == "" {
+					itemOwner = "nobody"
+				}
+				_, _ = fmt.Fprintf(o.ErrOut, "Skipping cluster %s owned by %s. Use --all-owners to delete it\n", item.Name, itemOwner)
+				continue
+			}
+			resources = append(resources, &api.Cluster{
+				TypeMeta: cluster.TypeMeta(),
+				Name:     item.Name,
+				Registry: item.Registry,
+			})
+		}
+	case "registry", "registries":
+		if !o.AllOwners {
+			return nil, fmt.Errorf("registries don't record an owner, so --older-than can't tell which ones are yours. "+
+				"Add --all-owners to delete every registry created more than %s ago", o.OlderThan)
+		}
+		if o.registryLister == nil {
+			controller, err := registry.DefaultController(o.IOStreams)
+			if err != nil {
+				return nil, err
+			}
+			o.registryLister = controller
+			if o.registryDeleter == nil {
+				o.registryDeleter = controller
+			}
+		}
+		list, err := o.registryLister.List(ctx, registry.ListOptions{OlderThan: o.OlderThan})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			resources = append(resources, &api.Registry{
+				TypeMeta: registry.TypeMeta(),
+				Name:     item.Name,
+			})
+		}
+	default:
+		return nil, fmt.Errorf("Unrecognized type: %s", args[0])
+	}
+	return resources, nil
+}
+
 func (o *DeleteOptions) getClusterController() (clusterController, error) {
 	if o.clusterController == nil {
-		controller, err := cluster.DefaultController(o.IOStreams)
+		controller, err := newClusterController(o.IOStreams)
 		if err != nil {
 			return nil, err
 		}
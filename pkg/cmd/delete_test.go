@@ -4,14 +4,17 @@ import (
 	"context"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 
 	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/registry"
 )
 
 func TestDeleteByName(t *testing.T) {
@@ -188,6 +191,107 @@ func TestDeleteCascadeInvalid(t *testing.T) {
 	}
 }
 
+func TestDeleteOlderThan(t *testing.T) {
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewDeleteOptions()
+	o.IOStreams = streams
+
+	old := metav1.Time{Time: time.Now().Add(-48 * time.Hour)}
+	cd := &fakeClusterController{
+		clusters: map[string]*api.Cluster{
+			"kind-old": &api.Cluster{Name: "kind-old", Product: "kind", Status: api.ClusterStatus{CreationTimestamp: old},
+				Annotations: map[string]string{api.ClusterAnnotationOwner: "alice"}},
+			"kind-new": &api.Cluster{Name: "kind-new", Product: "kind",
+				Status: api.ClusterStatus{CreationTimestamp: metav1.Time{Time: time.Now()}}},
+			"kind-unknown": &api.Cluster{Name: "kind-unknown", Product: "kind"},
+		},
+	}
+	o.clusterController = cd
+	o.OlderThan = 24 * time.Hour
+	o.Owner = "alice"
+	err := o.run([]string{"cluster"})
+	require.NoError(t, err)
+	assert.Equal(t, "cluster.ctlptl.dev/kind-old deleted\n", out.String())
+	assert.Equal(t, "kind-old", cd.lastDeleteName)
+	assert.Len(t, cd.clusters, 2)
+}
+
+func TestDeleteOlderThanOtherOwners(t *testing.T) {
+	streams, _, out, errOut := genericclioptions.NewTestIOStreams()
+	o := NewDeleteOptions()
+	o.IOStreams = streams
+
+	old := metav1.Time{Time: time.Now().Add(-48 * time.Hour)}
+	cd := &fakeClusterController{
+		clusters: map[string]*api.Cluster{
+			"kind-alice": &api.Cluster{Name: "kind-alice", Product: "kind", Status: api.ClusterStatus{CreationTimestamp: old},
+				Annotations: map[string]string{api.ClusterAnnotationOwner: "alice"}},
+			"kind-bob": &api.Cluster{Name: "kind-bob", Product: "kind", Status: api.ClusterStatus{CreationTimestamp: old},
+				Annotations: map[string]string{api.ClusterAnnotationOwner: "bob"}},
+			"kind-nobody": &api.Cluster{Name: "kind-nobody", Product: "kind", Status: api.ClusterStatus{CreationTimestamp: old}},
+		},
+	}
+	o.clusterController = cd
+	o.OlderThan = 24 * time.Hour
+	o.Owner = "alice"
+	err := o.run([]string{"cluster"})
+	require.NoError(t, err)
+	assert.Equal(t, "cluster.ctlptl.dev/kind-alice deleted\n", out.String())
+	assert.Contains(t, errOut.String(), "Skipping cluster kind-bob owned by bob. Use --all-owners to delete it")
+	assert.Contains(t, errOut.String(), "Skipping cluster kind-nobody owned by nobody")
+	assert.Len(t, cd.clusters, 2)
+
+	out.Reset()
+	o.AllOwners = true
+	err = o.run([]string{"cluster"})
+	require.NoError(t, err)
+	assert.Equal(t, "cluster.ctlptl.dev/kind-bob deleted\ncluster.ctlptl.dev/kind-nobody deleted\n", out.String())
+	assert.Len(t, cd.clusters, 0)
+}
+
+func TestDeleteOlderThanRegistries(t *testing.T) {
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewDeleteOptions()
+	o.IOStreams = streams
+
+	rd := &fakeDeleter{}
+	o.registryDeleter = rd
+	o.registryLister = fakeRegistryLister{items: []api.Registry{{Name: "old-registry"}}}
+	o.OlderThan = 24 * time.Hour
+	err := o.run([]string{"registry"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "registries don't record an owner, so --older-than can't tell which ones are yours. Add --all-owners")
+	}
+	assert.Equal(t, "", rd.lastName)
+
+	o.AllOwners = true
+	err = o.run([]string{"registry"})
+	require.NoError(t, err)
+	assert.Equal(t, "registry.ctlptl.dev/old-registry deleted\n", out.String())
+	assert.Equal(t, "old-registry", rd.lastName)
+}
+
+func TestDeleteOlderThanWithNames(t *testing.T) {
+	streams, _, _, _ := genericclioptions.NewTestIOStreams()
+	o := NewDeleteOptions()
+	o.IOStreams = streams
+
+	o.clusterController = &fakeClusterController{}
+	o.OlderThan = 24 * time.Hour
+	err := o.run([]string{"cluster", "kind-kind"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--older-than selects objects by age. Specify only a type")
+	}
+}
+
+type fakeRegistryLister struct {
+	items []api.Registry
+}
+
+func (l fakeRegistryLister) List(ctx context.Context, options registry.ListOptions) (*api.RegistryList, error) {
+	return &api.RegistryList{Items: l.items}, nil
+}
+
 type fakeDeleter struct {
 	lastName  string
 	nextError error
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+// Warn when a node's disk is fuller than this percent.
+const diskUsageWarnPercent = 80
+
+type dfOptions struct {
+	Node string
+}
+
+func NewDFCommand() *cobra.Command {
+	o := &dfOptions{}
+	cmd := &cobra.Command{
+		Use:   "df [cluster]",
+		Short: "Show how full the disk of each cluster node is",
+		Long: "Show how full the root filesystem of each cluster node is, by running df in each node container.\n\n" +
+			"Nodes that run out of space fail with cryptic 'No space left on device' errors, " +
+			fmt.Sprintf("so this warns about nodes that are more than %d%% full. ", diskUsageWarnPercent) +
+			"Only works on clusters whose nodes are Docker containers (kind and k3d).",
+		Example: "  ctlptl df kind-kind\n" +
+			"  ctlptl df kind-kind --node=kind-worker",
+		Run:  withClusterController("df", o.run),
+		Args: cobra.ExactArgs(1),
+	}
+	cmd.Flags().StringVar(&o.Node, "node", o.Node, "The name of the node. If not specified, shows every node")
+	return cmd
+}
+
+func (o *dfOptions) run(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	var usages []cluster.DiskUsage
+	if o.Node != "" {
+		usage, err := c.GetNodeDiskUsage(ctx, cl.Name, o.Node)
+		if err != nil {
+			return err
+		}
+		usages = []cluster.DiskUsage{*usage}
+	} else {
+		usages, err = c.ListNodeDiskUsage(ctx, cl.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	printDiskUsage(streams.Out, usages)
+	warnDiskUsage(streams.ErrOut, usages)
+	return nil
+}
+
+func printDiskUsage(w io.Writer, usages []cluster.DiskUsage) {
+	tw := tabwriter.NewWriter(w, 0, 4, 3, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NODE\tSIZE\tUSED\tAVAIL\tUSE%")
+	for _, usage := range usages {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.0f%%\n", usage.Node,
+			units.BytesSize(float64(usage.Total)),
+			units.BytesSize(float64(usage.Used)),
+			units.BytesSize(float64(usage.Available)),
+			usage.UsePercent)
+	}
+	_ = tw.Flush()
+}
+
+func warnDiskUsage(w io.Writer, usages []cluster.DiskUsage) {
+	for _, usage := range usages {
+		if usage.UsePercent > diskUsageWarnPercent {
+			_, _ = fmt.Fprintf(w, "Warning: node %s is %.0f%% full. "+
+				"Free up space (e.g., remove unused images) before it runs out\n", usage.Node, usage.UsePercent)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+var testDiskUsage = []cluster.DiskUsage{
+	{Node: "kind-control-plane", Total: 59 << 30, Used: 50 << 30, Available: 9 << 30, UsePercent: 85},
+	{Node: "kind-worker", Total: 59 << 30, Used: 12 << 30, Available: 47 << 30, UsePercent: 21},
+}
+
+func TestPrintDiskUsage(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	printDiskUsage(out, testDiskUsage)
+	assert.Equal(t, `NODE                 SIZE    USED    AVAIL   USE%
+kind-control-plane   59GiB   50GiB   9GiB    85%
+kind-worker          59GiB   12GiB   47GiB   21%
+`, out.String())
+}
+
+func TestWarnDiskUsage(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	warnDiskUsage(out, testDiskUsage)
+	assert.Equal(t, "Warning: node kind-control-plane is 85% full. "+
+		"Free up space (e.g., remove unused images) before it runs out\n", out.String())
+}
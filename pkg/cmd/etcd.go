@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+func NewEtcdCommand() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "etcd",
+		Short: "Back up and restore the etcd data of a cluster. Only supports kind clusters",
+		Example: "  ctlptl etcd backup kind-kind\n" +
+			"  ctlptl etcd restore kind-kind ./etcd-backup/etcd-20220101-120000.db",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "backup [cluster]",
+		Short: "Snapshot etcd to the cluster's etcdBackup.hostPath",
+		Run:   withClusterController("etcd-backup", etcdBackup),
+		Args:  cobra.ExactArgs(1),
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "restore [cluster] [snapshot-path]",
+		Short: "Load an etcd snapshot into a running cluster, replacing its current data",
+		Run:   withClusterController("etcd-restore", etcdRestore),
+		Args:  cobra.ExactArgs(2),
+	})
+
+	return cmd
+}
+
+func withClusterController(name string, run func(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error) func(_ *cobra.Command, args []string) {
+	return func(_ *cobra.Command, args []string) {
+		a, err := newAnalytics()
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "analytics: %v\n", err)
+			os.Exit(1)
+		}
+		a.Incr(fmt.Sprintf("cmd.%s", name), nil)
+		defer a.Flush(time.Second)
+
+		streams := genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
+		c, err := newClusterController(streams)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Loading controller: %v\n", err)
+			os.Exit(1)
+		}
+
+		err = run(context.Background(), c, streams, args)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func etcdBackup(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	snapshotPath, err := c.BackupEtcd(ctx, cl.Name)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(streams.Out, snapshotPath)
+	return nil
+}
+
+func etcdRestore(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stat(args[1])
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %v", err)
+	}
+
+	err = c.RestoreEtcd(ctx, cl.Name, args[1])
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(streams.ErrOut, "Restored cluster %s from %s\n", cl.Name, args[1])
+	return nil
+}
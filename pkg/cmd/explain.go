@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+func NewExplainCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "explain [field]",
+		Short: "Describe the fields of a cluster or registry config",
+		Long: "Describe a field of a cluster or registry config, like kubectl explain.\n\n" +
+			"Prints the field's type, the products that support it, and its description, " +
+			"followed by the fields it contains. Fields are JSON paths that start with the kind, " +
+			"e.g., cluster.networkCalico or registry.webhook.events.",
+		Example: "  ctlptl explain cluster\n" +
+			"  ctlptl explain cluster.registry\n" +
+			"  ctlptl explain registry.platform",
+		Run: func(_ *cobra.Command, args []string) {
+			a, err := newAnalytics()
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "analytics: %v\n", err)
+				os.Exit(1)
+			}
+			a.Incr("cmd.explain", nil)
+			defer a.Flush(time.Second)
+
+			err = explain(os.Stdout, args[0])
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		},
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeExplainPath,
+	}
+	return cmd
+}
+
+func explain(w io.Writer, path string) error {
+	doc, err := api.Explain(path)
+	if err != nil {
+		return err
+	}
+
+	kind, fieldPath, isField := strings.Cut(doc.Path, ".")
+	isCluster := kind == "cluster"
+
+	var products []clusterid.Product
+	if isCluster && isField {
+		products = cluster.SupportedProducts(fieldPath)
+	}
+
+	_, _ = fmt.Fprintf(w, "KIND:      %s\n", strings.ToUpper(kind[:1])+kind[1:])
+	if isField {
+		_, _ = fmt.Fprintf(w, "FIELD:     %s <%s>\n", doc.Name, doc.Type)
+	}
+	if products != nil {
+		_, _ = fmt.Fprintf(w, "PRODUCTS:  %s\n", formatProducts(products))
+	}
+	_, _ = fmt.Fprintf(w, "\nDESCRIPTION:\n%s\n", indent(doc.Description, "    "))
+
+	if len(doc.Fields) == 0 {
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, "\nFIELDS:\n")
+	for _, field := range doc.Fields {
+		line := fmt.Sprintf("    %s <%s>", field.Name, field.Type)
+		if isCluster {
+			_, childPath, _ := strings.Cut(field.Path, ".")
+			childProducts := cluster.SupportedProducts(childPath)
+			if childProducts != nil && !productsEqual(childProducts, products) {
+				line += fmt.Sprintf(" [%s]", formatProducts(childProducts))
+			}
+		}
+		_, _ = fmt.Fprintln(w, line)
+		if summary := field.Summary(); summary != "" {
+			_, _ = fmt.Fprintf(w, "      %s\n", summary)
+		}
+	}
+	return nil
+}
+
+// Shortens the common lists of products, so that only the
+// product-specific fields stand out.
+func formatProducts(products []clusterid.Product) string {
+	if productsEqual(products, cluster.SupportedProducts("")) {
+		return "all"
+	}
+	if productsEqual(products, cluster.SupportedProducts("name")) {
+		return "all, including external"
+	}
+	names := make([]string, 0, len(products))
+	for _, p := range products {
+		names = append(names, string(p))
+	}
+	return strings.Join(names, ", ")
+}
+
+func productsEqual(a, b []clusterid.Product) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func indent(s, prefix string) string {
+	if s == "" {
+		return prefix + "<empty>"
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Completes the field paths one level at a time.
+func completeExplainPath(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	parent, _, hasParent := cutLast(toComplete, ".")
+	if !hasParent {
+		return []string{"cluster", "registry"}, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	doc, err := api.Explain(parent)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var result []string
+	for _, field := range doc.Fields {
+		candidate := parent + "." + field.Name
+		if strings.HasPrefix(candidate, toComplete) {
+			result = append(result, candidate)
+		}
+	}
+	return result, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i == -1 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
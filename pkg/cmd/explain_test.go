@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainField(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	err := explain(out, "cluster.spec.registry")
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "KIND:      Cluster\n"+
+		"FIELD:     registry <string>\n"+
+		"PRODUCTS:  kind, k3d, minikube\n"+
+		"\n"+
+		"DESCRIPTION:\n"+
+		"    The name of a registry.\n")
+	assert.NotContains(t, out.String(), "FIELDS:")
+}
+
+func TestExplainFieldsShowProducts(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	err := explain(out, "cluster")
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "    name <string> [all, including external]\n      The cluster name.\n")
+	assert.Contains(t, out.String(), "    workers <int> [kwok]\n")
+	assert.Contains(t, out.String(), "    hosts <[]string> [all]\n")
+	assert.Contains(t, out.String(), "    status <ClusterStatus>\n")
+}
+
+func TestExplainNestedFieldsInheritProducts(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	err := explain(out, "cluster.networkCalico")
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "PRODUCTS:  kind\n")
+	assert.Contains(t, out.String(), "    version <string>\n")
+}
+
+func TestExplainRegistryHasNoProducts(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	err := explain(out, "registry.platform")
+	require.NoError(t, err)
+	assert.NotContains(t, out.String(), "PRODUCTS:")
+	assert.Contains(t, out.String(), "FIELD:     platform <string>\n")
+}
+
+func TestCompleteExplainPath(t *testing.T) {
+	result, _ := completeExplainPath(&cobra.Command{}, nil, "")
+	assert.Equal(t, []string{"cluster", "registry"}, result)
+
+	result, _ = completeExplainPath(&cobra.Command{}, nil, "cluster.networkCalico.v")
+	assert.Equal(t, []string{"cluster.networkCalico.version", "cluster.networkCalico.vxlan"}, result)
+
+	result, _ = completeExplainPath(&cobra.Command{}, nil, "registry.pl")
+	assert.Equal(t, []string{"registry.platform"}, result)
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -26,6 +29,23 @@ type GetOptions struct {
 	StartTime      time.Time
 	IgnoreNotFound bool
 	FieldSelector  string
+	LabelSelector  string
+	OlderThan      time.Duration
+	ShowKubeconfig bool
+	Flatten        bool
+	VerifyDrift    bool
+	Strict         bool
+
+	// With -o wide, the ages of clusters that we couldn't read a creation
+	// time from (e.g., because they're not running), by name.
+	clusterAges map[string]time.Duration
+
+	// With --verify-drift, a summary of each cluster's drift, by name.
+	clusterDrift map[string]string
+}
+
+type driftVerifier interface {
+	VerifyDrift(ctx context.Context, cluster *api.Cluster) ([]cluster.ClusterDrift, error)
 }
 
 func NewGetOptions() *GetOptions {
@@ -49,7 +69,14 @@ https://kubernetes.io/docs/reference/kubectl/cheatsheet/#formatting-output
 `,
 		Example: "  ctlptl get\n" +
 			"  ctlptl get cluster microk8s -o yaml\n" +
-			"  ctlptl get cluster kind-kind -o template --template '{{.status.localRegistryHosting.host}}'\n",
+			"  ctlptl get cluster -o wide\n" +
+			"  ctlptl get cluster kind-kind -o template --template '{{.status.localRegistryHosting.host}}'\n" +
+			"  ctlptl get cluster -o go-template='{{range .items}}{{.name}} {{.product}}{{\"\\n\"}}{{end}}'\n" +
+			"  ctlptl get cluster --field-selector=product=kind,status.ready=true\n" +
+			"  ctlptl get cluster --older-than 4h\n" +
+			"  ctlptl get cluster kind-kind --show-kubeconfig --flatten > kind-kind.kubeconfig\n" +
+			"  ctlptl get cluster --verify-drift --strict\n" +
+			"  ctlptl get all -l team=foo -o json\n",
 		Run:  o.Run,
 		Args: cobra.MaximumNArgs(2),
 	}
@@ -59,7 +86,21 @@ https://kubernetes.io/docs/reference/kubectl/cheatsheet/#formatting-output
 	o.PrintFlags.AddFlags(cmd)
 
 	cmd.Flags().BoolVar(&o.IgnoreNotFound, "ignore-not-found", o.IgnoreNotFound, "If the requested object does not exist the command will return exit code 0.")
-	cmd.Flags().StringVar(&o.FieldSelector, "field-selector", o.FieldSelector, "Selector (field query) to filter on, supports '=', '==', and '!='.(e.g. --field-selector key1=value1,key2=value2). The server only supports a limited number of field queries per type.")
+	cmd.Flags().StringVar(&o.FieldSelector, "field-selector", o.FieldSelector, "Selector (field query) to filter on, supports '=', '==', and '!='.(e.g. --field-selector key1=value1,key2=value2). "+
+		"Clusters support name, product, registry, status.current, status.ready, and status.kubernetesVersion. "+
+		"Registries support name, port, status.state, and status.ready.")
+	cmd.Flags().StringVarP(&o.LabelSelector, "selector", "l", o.LabelSelector, "Selector (label query) to filter on, supports '=', '==', '!=', 'in', 'notin', and 'exists'.(e.g. -l key1=value1,key2=value2). "+
+		"Clusters match on their annotations. Registries match on their container labels.")
+	cmd.Flags().DurationVar(&o.OlderThan, "older-than", o.OlderThan,
+		"Only list objects created more than this long ago (e.g. 4h). Clusters whose creation time can't be read are never listed.")
+	cmd.Flags().BoolVar(&o.ShowKubeconfig, "show-kubeconfig", o.ShowKubeconfig,
+		"Print a standalone kubeconfig with only the context, cluster, and user of the named cluster, so it can be shared.")
+	cmd.Flags().BoolVar(&o.Flatten, "flatten", o.Flatten,
+		"With --show-kubeconfig, inline the certificates and keys that the kubeconfig references by path, so it works on other machines.")
+	cmd.Flags().BoolVar(&o.VerifyDrift, "verify-drift", o.VerifyDrift,
+		"Compare each cluster's Kubernetes version, node count, and registry against the ones ctlptl recorded when it last applied the cluster, "+
+			"and report the settings that changed out-of-band.")
+	cmd.Flags().BoolVar(&o.Strict, "strict", o.Strict, "With --verify-drift, exit with a non-zero code if any cluster has drifted.")
 
 	return cmd
 }
@@ -78,7 +119,24 @@ func (o *GetOptions) Run(cmd *cobra.Command, args []string) {
 	if len(args) >= 1 {
 		t = args[0]
 	}
+	if len(args) >= 2 && t == "all" {
+		_, _ = fmt.Fprintf(o.ErrOut, "ctlptl get all lists every cluster and registry. It can't be combined with a name\n")
+		os.Exit(1)
+	}
+	if len(args) >= 2 && o.OlderThan != 0 {
+		_, _ = fmt.Fprintf(o.ErrOut, "--older-than filters lists. It can't be combined with a name\n")
+		os.Exit(1)
+	}
+	err = o.validateShowKubeconfig(t, args)
+	if err == nil {
+		err = o.validateVerifyDrift(t)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "%v\n", err)
+		os.Exit(1)
+	}
 	var resource runtime.Object
+	drifted := 0
 	switch t {
 	case "registry", "registries":
 		c, err := registry.DefaultController(o.IOStreams)
@@ -97,7 +155,7 @@ func (o *GetOptions) Run(cmd *cobra.Command, args []string) {
 				os.Exit(1)
 			}
 		} else {
-			resource, err = c.List(ctx, registry.ListOptions{FieldSelector: o.FieldSelector})
+			resource, err = c.List(ctx, o.registryListOptions())
 			if err != nil {
 				_, _ = fmt.Fprintf(o.ErrOut, "List registries: %v\n", err)
 				os.Exit(1)
@@ -105,14 +163,14 @@ func (o *GetOptions) Run(cmd *cobra.Command, args []string) {
 		}
 
 	case "cluster", "clusters":
-		c, err := cluster.DefaultController(o.IOStreams)
+		c, err := newClusterController(o.IOStreams)
 		if err != nil {
 			_, _ = fmt.Fprintf(o.ErrOut, "Loading controller: %v\n", err)
 			os.Exit(1)
 		}
 
 		if len(args) >= 2 {
-			resource, err = normalizedGet(ctx, c, args[1])
+			cl, err := normalizedGet(ctx, c, args[1])
 			if err != nil {
 				if errors.IsNotFound(err) && o.IgnoreNotFound {
 					os.Exit(0)
@@ -120,16 +178,40 @@ func (o *GetOptions) Run(cmd *cobra.Command, args []string) {
 				_, _ = fmt.Fprintf(o.ErrOut, "%v\n", err)
 				os.Exit(1)
 			}
+			if o.ShowKubeconfig {
+				kubeconfig, err := c.MinifiedKubeconfig(cl.Name, o.Flatten)
+				if err != nil {
+					_, _ = fmt.Fprintf(o.ErrOut, "%v\n", err)
+					os.Exit(1)
+				}
+				_, _ = o.Out.Write(kubeconfig)
+				return
+			}
+			resource = cl
 		} else {
-			resource, err = c.List(ctx, cluster.ListOptions{FieldSelector: o.FieldSelector})
+			resource, err = c.List(ctx, o.clusterListOptions())
 			if err != nil {
 				_, _ = fmt.Fprintf(o.ErrOut, "List clusters: %v\n", err)
 				os.Exit(1)
 			}
 		}
 
+		if o.wideOutput() {
+			o.loadClusterAges(ctx, c, resource)
+		}
+		if o.VerifyDrift {
+			drifted = o.verifyDrift(ctx, c, resource)
+		}
+
+	case "all":
+		resource, err = o.listAll(ctx)
+		if err != nil {
+			_, _ = fmt.Fprintf(o.ErrOut, "%v\n", err)
+			os.Exit(1)
+		}
+
 	default:
-		_, _ = fmt.Fprintf(o.ErrOut, "Unrecognized type: %s. Possible values: cluster, registry.\n", t)
+		_, _ = fmt.Fprintf(o.ErrOut, "Unrecognized type: %s. Possible values: cluster, registry, all.\n", t)
 		os.Exit(1)
 	}
 
@@ -138,12 +220,169 @@ func (o *GetOptions) Run(cmd *cobra.Command, args []string) {
 		_, _ = fmt.Fprintf(o.ErrOut, "Error: %s\n", err)
 		os.Exit(1)
 	}
+	if o.Strict && drifted > 0 {
+		os.Exit(1)
+	}
+}
+
+// Reads the ages of clusters with no creation time from ctlptl's state
+// file, falling back to their Docker containers.
+func (o *GetOptions) loadClusterAges(ctx context.Context, c *cluster.Controller, obj runtime.Object) {
+	var clusters []api.Cluster
+	switch r := obj.(type) {
+	case *api.Cluster:
+		clusters = []api.Cluster{*r}
+	case *api.ClusterList:
+		clusters = r.Items
+	}
+
+	for _, cl := range clusters {
+		if !cl.Status.CreationTimestamp.Time.IsZero() {
+			continue
+		}
+		age, err := c.GetClusterAge(ctx, cl.Name)
+		if err != nil {
+			continue
+		}
+		if o.clusterAges == nil {
+			o.clusterAges = make(map[string]time.Duration)
+		}
+		o.clusterAges[cl.Name] = age
+	}
+}
+
+// --show-kubeconfig prints one cluster's kubeconfig instead of the cluster.
+func (o *GetOptions) validateShowKubeconfig(t string, args []string) error {
+	if !o.ShowKubeconfig {
+		if o.Flatten {
+			return fmt.Errorf("--flatten only works with --show-kubeconfig")
+		}
+		return nil
+	}
+	if (t != "cluster" && t != "clusters") || len(args) < 2 {
+		return fmt.Errorf("--show-kubeconfig prints the kubeconfig of one cluster. Usage: ctlptl get cluster NAME --show-kubeconfig")
+	}
+	if o.OutputFlagSpecified() {
+		return fmt.Errorf("--show-kubeconfig always prints YAML. It can't be combined with --output")
+	}
+	return nil
+}
+
+func (o *GetOptions) validateVerifyDrift(t string) error {
+	if !o.VerifyDrift {
+		if o.Strict {
+			return fmt.Errorf("--strict only works with --verify-drift")
+		}
+		return nil
+	}
+	if t != "cluster" && t != "clusters" {
+		return fmt.Errorf("--verify-drift only works with clusters")
+	}
+	return nil
+}
+
+// Checks the clusters for drift in parallel, and prints the settings that
+// drifted. Returns how many clusters drifted.
+func (o *GetOptions) verifyDrift(ctx context.Context, v driftVerifier, obj runtime.Object) int {
+	var clusters []api.Cluster
+	switch r := obj.(type) {
+	case *api.Cluster:
+		clusters = []api.Cluster{*r}
+	case *api.ClusterList:
+		clusters = r.Items
+	}
+
+	drifts := make([][]cluster.ClusterDrift, len(clusters))
+	errs := make([]error, len(clusters))
+	wg := sync.WaitGroup{}
+	for i := range clusters {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			drifts[i], errs[i] = v.VerifyDrift(ctx, &clusters[i])
+		}()
+	}
+	wg.Wait()
+
+	o.clusterDrift = make(map[string]string, len(clusters))
+	drifted := 0
+	for i, cl := range clusters {
+		if errs[i] != nil {
+			o.clusterDrift[cl.Name] = "unknown"
+			_, _ = fmt.Fprintf(o.ErrOut, "Can't verify drift: %v\n", errs[i])
+			continue
+		}
+		if len(drifts[i]) == 0 {
+			o.clusterDrift[cl.Name] = "none"
+			continue
+		}
+
+		drifted++
+		fields := make([]string, 0, len(drifts[i]))
+		for _, d := range drifts[i] {
+			fields = append(fields, d.Field)
+			_, _ = fmt.Fprintf(o.ErrOut, "Cluster %s drifted: %s\n", cl.Name, d)
+		}
+		o.clusterDrift[cl.Name] = strings.Join(fields, ",")
+	}
+	return drifted
+}
+
+func (o *GetOptions) clusterListOptions() cluster.ListOptions {
+	return cluster.ListOptions{FieldSelector: o.FieldSelector, LabelSelector: o.LabelSelector, OlderThan: o.OlderThan}
+}
+
+func (o *GetOptions) registryListOptions() registry.ListOptions {
+	return registry.ListOptions{FieldSelector: o.FieldSelector, LabelSelector: o.LabelSelector, OlderThan: o.OlderThan}
+}
+
+// Lists clusters, then registries, in one list.
+//
+// Clusters and registries support different fields, so a field selector
+// has to name fields that both support (like name).
+func (o *GetOptions) listAll(ctx context.Context) (*api.List, error) {
+	cc, err := newClusterController(o.IOStreams)
+	if err != nil {
+		return nil, fmt.Errorf("Loading controller: %v", err)
+	}
+	clusters, err := cc.List(ctx, o.clusterListOptions())
+	if err != nil {
+		return nil, fmt.Errorf("List clusters: %v", err)
+	}
+
+	rc, err := registry.DefaultController(o.IOStreams)
+	if err != nil {
+		return nil, fmt.Errorf("Loading controller: %v", err)
+	}
+	registries, err := rc.List(ctx, o.registryListOptions())
+	if err != nil {
+		return nil, fmt.Errorf("List registries: %v", err)
+	}
+	return newAllList(clusters, registries), nil
+}
+
+func newAllList(clusters *api.ClusterList, registries *api.RegistryList) *api.List {
+	list := &api.List{
+		TypeMeta: api.TypeMeta{APIVersion: "ctlptl.dev/v1alpha1", Kind: "List"},
+		Items:    []runtime.Object{},
+	}
+	for i := range clusters.Items {
+		list.Items = append(list.Items, &clusters.Items[i])
+	}
+	for i := range registries.Items {
+		list.Items = append(list.Items, &registries.Items[i])
+	}
+	return list
 }
 
 func (o *GetOptions) ToPrinter() (printers.ResourcePrinter, error) {
 	if !o.OutputFlagSpecified() {
 		return printers.NewTablePrinter(printers.PrintOptions{}), nil
 	}
+	if o.wideOutput() {
+		return printers.NewTablePrinter(printers.PrintOptions{Wide: true}), nil
+	}
 	return toPrinter(o.PrintFlags)
 }
 
@@ -169,8 +408,13 @@ func (o *GetOptions) OutputFlagSpecified() bool {
 	return o.PrintFlags.OutputFlagSpecified != nil && o.PrintFlags.OutputFlagSpecified()
 }
 
+// The table, with extra columns.
+func (o *GetOptions) wideOutput() bool {
+	return o.PrintFlags.OutputFormat != nil && *o.PrintFlags.OutputFormat == "wide"
+}
+
 func (o *GetOptions) transformForOutput(obj runtime.Object) runtime.Object {
-	if o.OutputFlagSpecified() {
+	if o.OutputFlagSpecified() && !o.wideOutput() {
 		return obj
 	}
 
@@ -183,6 +427,8 @@ func (o *GetOptions) transformForOutput(obj runtime.Object) runtime.Object {
 		return o.clustersAsTable([]api.Cluster{*r})
 	case *api.ClusterList:
 		return o.clustersAsTable(r.Items)
+	case *api.List:
+		return o.allAsTable(r.Items)
 	default:
 		return obj
 	}
@@ -212,14 +458,31 @@ func (o *GetOptions) clustersAsTable(clusters []api.Cluster) runtime.Object {
 				Name: "Registry",
 				Type: "string",
 			},
+			metav1.TableColumnDefinition{
+				Name: "Owner",
+				Type: "string",
+			},
+			metav1.TableColumnDefinition{
+				Name:     "Kubernetes Version",
+				Type:     "string",
+				Priority: 1,
+			},
 		},
 	}
+	if o.clusterDrift != nil {
+		table.ColumnDefinitions = append(table.ColumnDefinitions, metav1.TableColumnDefinition{
+			Name: "Drift",
+			Type: "string",
+		})
+	}
 
 	for _, cluster := range clusters {
 		age := "unknown"
 		cTime := cluster.Status.CreationTimestamp.Time
 		if !cTime.IsZero() {
 			age = duration.ShortHumanDuration(o.StartTime.Sub(cTime))
+		} else if clusterAge, ok := o.clusterAges[cluster.Name]; ok {
+			age = duration.ShortHumanDuration(clusterAge)
 		}
 
 		rHost := ""
@@ -230,20 +493,34 @@ func (o *GetOptions) clustersAsTable(clusters []api.Cluster) runtime.Object {
 			rHost = "none"
 		}
 
+		owner := cluster.Annotations[api.ClusterAnnotationOwner]
+		if owner == "" {
+			owner = "none"
+		}
+
+		version := cluster.Status.KubernetesVersion
+		if version == "" {
+			version = "unknown"
+		}
+
 		current := ""
 		if cluster.Status.Current {
 			current = "*"
 		}
 
-		table.Rows = append(table.Rows, metav1.TableRow{
-			Cells: []interface{}{
-				current,
-				cluster.Name,
-				cluster.Product,
-				age,
-				rHost,
-			},
-		})
+		cells := []interface{}{
+			current,
+			cluster.Name,
+			cluster.Product,
+			age,
+			rHost,
+			owner,
+			version,
+		}
+		if o.clusterDrift != nil {
+			cells = append(cells, o.clusterDrift[cluster.Name])
+		}
+		table.Rows = append(table.Rows, metav1.TableRow{Cells: cells})
 	}
 
 	return &table
@@ -306,3 +583,58 @@ func (o *GetOptions) registriesAsTable(registries []api.Registry) runtime.Object
 
 	return &table
 }
+
+// A table of clusters and registries, with the columns they have in common.
+func (o *GetOptions) allAsTable(items []runtime.Object) runtime.Object {
+	table := metav1.Table{
+		TypeMeta: metav1.TypeMeta{Kind: "Table", APIVersion: "metav1.k8s.io"},
+		ColumnDefinitions: []metav1.TableColumnDefinition{
+			metav1.TableColumnDefinition{
+				Name: "Kind",
+				Type: "string",
+			},
+			metav1.TableColumnDefinition{
+				Name: "Name",
+				Type: "string",
+			},
+			metav1.TableColumnDefinition{
+				Name: "Ready",
+				Type: "string",
+			},
+			metav1.TableColumnDefinition{
+				Name: "Age",
+				Type: "string",
+			},
+		},
+	}
+
+	for _, item := range items {
+		var kind, name string
+		var ready bool
+		var created metav1.Time
+		switch r := item.(type) {
+		case *api.Cluster:
+			kind, name, ready, created = "Cluster", r.Name, cluster.IsReady(r), r.Status.CreationTimestamp
+		case *api.Registry:
+			kind, name, ready, created = "Registry", r.Name, r.Status.State == "running", r.Status.CreationTimestamp
+		default:
+			continue
+		}
+
+		age := "unknown"
+		if !created.IsZero() {
+			age = duration.ShortHumanDuration(o.StartTime.Sub(created.Time))
+		}
+
+		table.Rows = append(table.Rows, metav1.TableRow{
+			Cells: []interface{}{
+				kind,
+				name,
+				strconv.FormatBool(ready),
+				age,
+			},
+		})
+	}
+
+	return &table
+}
@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
@@ -12,6 +15,7 @@ import (
 
 	"github.com/tilt-dev/ctlptl/pkg/api"
 	"github.com/tilt-dev/ctlptl/pkg/cluster"
+	"github.com/tilt-dev/ctlptl/pkg/encoding"
 	"github.com/tilt-dev/ctlptl/pkg/registry"
 )
 
@@ -85,12 +89,29 @@ func TestDefaultPrint(t *testing.T) {
 
 	err := o.Print(o.transformForOutput(clusterList))
 	require.NoError(t, err)
-	assert.Equal(t, out.String(), `CURRENT   NAME        PRODUCT    AGE   REGISTRY
-*         microk8s    microk8s   3y    none
-          kind-kind   KIND       3y    localhost:5000
+	assert.Equal(t, out.String(), `CURRENT   NAME        PRODUCT    AGE   REGISTRY         OWNER
+*         microk8s    microk8s   3y    none             none
+          kind-kind   KIND       3y    localhost:5000   none
 `)
 }
 
+func TestWidePrint(t *testing.T) {
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewGetOptions()
+	o.IOStreams = streams
+	o.StartTime = startTime
+
+	err := o.Command().Flags().Set("output", "wide")
+	require.NoError(t, err)
+
+	err = o.Print(o.transformForOutput(clusterList))
+	require.NoError(t, err)
+	assert.Equal(t, `CURRENT   NAME        PRODUCT    AGE   REGISTRY         OWNER   KUBERNETES VERSION
+*         microk8s    microk8s   3y    none             none    unknown
+          kind-kind   KIND       3y    localhost:5000   none    unknown
+`, out.String())
+}
+
 func TestYAML(t *testing.T) {
 	streams, _, out, _ := genericclioptions.NewTestIOStreams()
 	o := NewGetOptions()
@@ -123,6 +144,31 @@ kind: ClusterList
 `, out.String())
 }
 
+func TestYAMLRoundTrip(t *testing.T) {
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewGetOptions()
+	o.IOStreams = streams
+
+	err := o.Command().Flags().Set("output", "yaml")
+	require.NoError(t, err)
+
+	err = o.Print(o.transformForOutput(clusterList))
+	require.NoError(t, err)
+	_, _ = out.WriteString("---\n")
+	err = o.Print(o.transformForOutput(registryList))
+	require.NoError(t, err)
+
+	objs, err := encoding.ParseStream(out)
+	require.NoError(t, err)
+	objs = encoding.FlattenLists(objs)
+	require.Equal(t, 4, len(objs))
+	assert.Equal(t, clusterList.Items[0].Name, objs[0].(*api.Cluster).Name)
+	assert.True(t, clusterList.Items[0].Status.CreationTimestamp.Equal(&objs[0].(*api.Cluster).Status.CreationTimestamp))
+	assert.Equal(t, clusterList.Items[1].Status.LocalRegistryHosting, objs[1].(*api.Cluster).Status.LocalRegistryHosting)
+	assert.Equal(t, registryList.Items[0].Name, objs[2].(*api.Registry).Name)
+	assert.Equal(t, registryList.Items[1].Port, objs[3].(*api.Registry).Port)
+}
+
 func TestRegistryPrint(t *testing.T) {
 	streams, _, out, _ := genericclioptions.NewTestIOStreams()
 	o := NewGetOptions()
@@ -136,3 +182,156 @@ ctlptl-registry            0.0.0.0:5001     172.17.0.2:5000     3y
 ctlptl-registry-loopback   127.0.0.1:5002   172.17.0.3:5000     3y
 `, out.String())
 }
+
+func TestAllPrint(t *testing.T) {
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewGetOptions()
+	o.IOStreams = streams
+	o.StartTime = startTime
+
+	err := o.Print(o.transformForOutput(newAllList(clusterList, registryList)))
+	require.NoError(t, err)
+	assert.Equal(t, `KIND       NAME                       READY   AGE
+Cluster    microk8s                   false   3y
+Cluster    kind-kind                  false   3y
+Registry   ctlptl-registry            false   3y
+Registry   ctlptl-registry-loopback   false   3y
+`, out.String())
+}
+
+func TestAllJSON(t *testing.T) {
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewGetOptions()
+	o.IOStreams = streams
+
+	err := o.Command().Flags().Set("output", "json")
+	require.NoError(t, err)
+
+	err = o.Print(o.transformForOutput(newAllList(clusterList, registryList)))
+	require.NoError(t, err)
+
+	var list struct {
+		Kind  string
+		Items []struct {
+			Kind string
+			Name string
+		}
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &list))
+	assert.Equal(t, "List", list.Kind)
+	require.Len(t, list.Items, 4)
+	assert.Equal(t, "Cluster", list.Items[0].Kind)
+	assert.Equal(t, "microk8s", list.Items[0].Name)
+	assert.Equal(t, "Registry", list.Items[3].Kind)
+	assert.Equal(t, "ctlptl-registry-loopback", list.Items[3].Name)
+}
+
+func TestGoTemplateList(t *testing.T) {
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewGetOptions()
+	o.IOStreams = streams
+
+	err := o.Command().Flags().Set("output", `go-template={{range .items}}{{.name}} {{.product}}{{"\n"}}{{end}}`)
+	require.NoError(t, err)
+
+	err = o.Print(o.transformForOutput(clusterList))
+	require.NoError(t, err)
+	assert.Equal(t, "microk8s microk8s\nkind-kind KIND\n", out.String())
+}
+
+func TestGoTemplateSingle(t *testing.T) {
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewGetOptions()
+	o.IOStreams = streams
+
+	cmd := o.Command()
+	require.NoError(t, cmd.Flags().Set("output", "template"))
+	require.NoError(t, cmd.Flags().Set("template", "{{.name}} {{.status.localRegistryHosting.host}}"))
+
+	err := o.Print(o.transformForOutput(&clusterList.Items[1]))
+	require.NoError(t, err)
+	assert.Equal(t, "kind-kind localhost:5000", out.String())
+}
+
+func TestGoTemplateError(t *testing.T) {
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewGetOptions()
+	o.IOStreams = streams
+
+	cmd := o.Command()
+	require.NoError(t, cmd.Flags().Set("output", "go-template={{.name}}"))
+	require.NoError(t, cmd.Flags().Set("allow-missing-template-keys", "false"))
+
+	err := o.Print(o.transformForOutput(clusterList))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `map has no entry for key "name"`)
+		assert.Contains(t, err.Error(), "The object is a list; use {{range .items}} to render each item")
+	}
+	assert.Equal(t, "", out.String())
+
+	require.NoError(t, cmd.Flags().Set("output", "go-template={{.name"))
+	err = o.Print(o.transformForOutput(clusterList))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unclosed action")
+	}
+}
+
+func TestValidateShowKubeconfig(t *testing.T) {
+	o := NewGetOptions()
+	o.ShowKubeconfig = true
+	assert.NoError(t, o.validateShowKubeconfig("cluster", []string{"cluster", "kind-kind"}))
+
+	err := o.validateShowKubeconfig("cluster", []string{"cluster"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--show-kubeconfig prints the kubeconfig of one cluster")
+	}
+	err = o.validateShowKubeconfig("registry", []string{"registry", "ctlptl-registry"})
+	assert.Error(t, err)
+
+	o.ShowKubeconfig = false
+	o.Flatten = true
+	err = o.validateShowKubeconfig("cluster", []string{"cluster", "kind-kind"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--flatten only works with --show-kubeconfig")
+	}
+}
+
+type fakeDriftVerifier map[string][]cluster.ClusterDrift
+
+func (v fakeDriftVerifier) VerifyDrift(ctx context.Context, cl *api.Cluster) ([]cluster.ClusterDrift, error) {
+	drifts, ok := v[cl.Name]
+	if !ok {
+		return nil, fmt.Errorf("cluster %s: no settings recorded", cl.Name)
+	}
+	return drifts, nil
+}
+
+func TestVerifyDriftPrint(t *testing.T) {
+	streams, _, out, errOut := genericclioptions.NewTestIOStreams()
+	o := NewGetOptions()
+	o.IOStreams = streams
+	o.StartTime = startTime
+	o.VerifyDrift = true
+
+	list := clusterList.DeepCopy()
+	list.Items = append(list.Items, api.Cluster{TypeMeta: clusterType, Name: "k3d-k3s-default", Product: "k3d"})
+	drifted := o.verifyDrift(context.Background(), fakeDriftVerifier{
+		"microk8s": {},
+		"kind-kind": {
+			{Field: "kubernetesVersion", Recorded: "v1.27.3", Live: "v1.28.0"},
+			{Field: "nodes", Recorded: "1", Live: "2"},
+		},
+	}, list)
+	assert.Equal(t, 1, drifted)
+	assert.Equal(t, "Cluster kind-kind drifted: kubernetesVersion: recorded v1.27.3, live v1.28.0\n"+
+		"Cluster kind-kind drifted: nodes: recorded 1, live 2\n"+
+		"Can't verify drift: cluster k3d-k3s-default: no settings recorded\n", errOut.String())
+
+	err := o.Print(o.transformForOutput(list))
+	require.NoError(t, err)
+	assert.Equal(t, `CURRENT   NAME              PRODUCT    AGE       REGISTRY         OWNER   DRIFT
+*         microk8s          microk8s   3y        none             none    none
+          kind-kind         KIND       3y        localhost:5000   none    kubernetesVersion,nodes
+          k3d-k3s-default   k3d        unknown   none             none    unknown
+`, out.String())
+}
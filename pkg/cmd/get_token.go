@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+type getTokenOptions struct {
+	Namespace string
+}
+
+func NewGetTokenCommand() *cobra.Command {
+	o := &getTokenOptions{}
+	cmd := &cobra.Command{
+		Use:   "get-token [cluster] [service-account]",
+		Short: "Print a new token for a service account in a cluster",
+		Long: "Print a new token for a service account in a cluster.\n\n" +
+			"If the cluster declares the service account in serviceAccounts, the token expires " +
+			"after its expireSeconds. Otherwise, the token gets the apiserver's default lifetime.",
+		Example: "  ctlptl get-token kind-kind ci\n" +
+			"  export TOKEN=$(ctlptl get-token kind-kind deployer -n ci)",
+		Run:  withClusterController("get-token", o.run),
+		Args: cobra.ExactArgs(2),
+	}
+	cmd.Flags().StringVarP(&o.Namespace, "namespace", "n", "default", "The namespace of the service account")
+	return cmd
+}
+
+func (o *getTokenOptions) run(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+	token, err := c.GetServiceAccountToken(ctx, cl.Name, o.Namespace, args[1])
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(streams.Out, token.Token)
+	return err
+}
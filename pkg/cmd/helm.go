@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+func NewHelmCommand() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "helm",
+		Short: "Install Helm charts in a cluster",
+		Example: "  ctlptl helm install kind-kind ingress-nginx --repo https://kubernetes.github.io/ingress-nginx -n ingress-nginx\n" +
+			"  ctlptl helm install kind-kind oci://registry-1.docker.io/bitnamicharts/redis --set architecture=standalone",
+	}
+
+	cmd.AddCommand(newHelmInstallCommand())
+	return cmd
+}
+
+type helmInstallOptions struct {
+	api.HelmChartSpec
+}
+
+func newHelmInstallCommand() *cobra.Command {
+	o := &helmInstallOptions{}
+	var cmd = &cobra.Command{
+		Use:   "install [cluster] [chart]",
+		Short: "Install a chart, or upgrade its release if it's already installed",
+		Long: "Install a chart, or upgrade its release if it's already installed, " +
+			"and wait for the release to become ready.\n\n" +
+			"The chart may be a chart name in --repo, an oci:// reference, or a path to a local chart. " +
+			"To install charts every time a cluster is created, list them in the cluster's helmCharts instead.",
+		Run:  withClusterController("helm-install", o.run),
+		Args: cobra.ExactArgs(2),
+	}
+
+	cmd.Flags().StringVar(&o.Name, "name", "", "The name of the release. Defaults to the last path element of the chart")
+	cmd.Flags().StringVar(&o.Repo, "repo", "", "The URL of the chart repository")
+	cmd.Flags().StringVar(&o.Version, "version", "", "The version of the chart. Defaults to the latest version")
+	cmd.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "The namespace of the release. Created if it doesn't exist")
+	cmd.Flags().StringArrayVarP(&o.ValuesFiles, "values", "f", nil, "A values file. May be repeated")
+	cmd.Flags().StringArrayVar(&o.Set, "set", nil, "A value in key=value form. May be repeated")
+	return cmd
+}
+
+func (o *helmInstallOptions) run(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	chart := o.HelmChartSpec
+	chart.Chart = args[1]
+	c.OnStatus(func(status string) {
+		_, _ = fmt.Fprintf(streams.ErrOut, "%s\n", status)
+	})
+	return c.ApplyHelmChart(ctx, cl.Name, chart)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+func NewHubbleCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hubble [cluster]",
+		Short: "Open the Hubble UI of a cluster with hubbleEnabled",
+		Long: "Open the Hubble UI of a cluster with hubbleEnabled in the browser, and forward " +
+			"Hubble relay to localhost:4245, so that the hubble CLI can observe the cluster's flows.\n\n" +
+			"Runs until interrupted. Requires the cilium CLI.",
+		Example: "  ctlptl hubble kind-kind",
+		Run:     withClusterController("hubble", runHubble),
+		Args:    cobra.ExactArgs(1),
+	}
+	return cmd
+}
+
+func runHubble(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+	return c.Hubble(ctx, cl.Name)
+}
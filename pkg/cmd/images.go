@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+type imagesOptions struct {
+	Check  string
+	Output string
+}
+
+func NewImagesCommand() *cobra.Command {
+	o := &imagesOptions{}
+	cmd := &cobra.Command{
+		Use:   "images [cluster]",
+		Short: "List the images in each node's container runtime",
+		Long: "List the images in each node's container runtime, where the kubelet looks for them.\n\n" +
+			"Runs crictl in each node container, so only works on clusters whose nodes are " +
+			"Docker containers (kind and k3d). Use --check to confirm that a loaded or pushed image " +
+			"landed on every node. The check fails if any node is missing the image.",
+		Example: "  ctlptl images kind-kind\n" +
+			"  ctlptl images kind-kind --check my-app:dev\n" +
+			"  ctlptl images k3d-k3s-default -o json",
+		Run:  withClusterController("images", o.run),
+		Args: cobra.ExactArgs(1),
+	}
+	cmd.Flags().StringVar(&o.Check, "check", "", "An image reference to look for on every node")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "Output format. One of: json")
+	return cmd
+}
+
+// Whether a node has the image passed to --check.
+type imageCheckResult struct {
+	Node    string `json:"node"`
+	Image   string `json:"image"`
+	Present bool   `json:"present"`
+}
+
+func (o *imagesOptions) run(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	if o.Output != "" && o.Output != "json" {
+		return fmt.Errorf("unsupported output format %q. Supported: json", o.Output)
+	}
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+	nodes, err := c.ListNodeImages(ctx, cl.Name)
+	if err != nil {
+		return err
+	}
+
+	if o.Check == "" {
+		if o.Output == "json" {
+			return writeIndentedJSON(streams.Out, nodes)
+		}
+		printNodeImages(streams.Out, nodes)
+		return nil
+	}
+
+	results, err := checkNodeImages(nodes, o.Check)
+	if err != nil {
+		return err
+	}
+	if o.Output == "json" {
+		err = writeIndentedJSON(streams.Out, results)
+	} else {
+		printImageCheck(streams.Out, results)
+	}
+	if err != nil {
+		return err
+	}
+
+	missing := []string{}
+	for _, result := range results {
+		if !result.Present {
+			missing = append(missing, result.Node)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("image %s is missing from nodes: %s", o.Check, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func checkNodeImages(nodes []cluster.NodeImages, ref string) ([]imageCheckResult, error) {
+	results := make([]imageCheckResult, 0, len(nodes))
+	for _, node := range nodes {
+		present, err := node.HasImage(ref)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, imageCheckResult{Node: node.Node, Image: ref, Present: present})
+	}
+	return results, nil
+}
+
+func writeIndentedJSON(w io.Writer, obj interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(obj)
+}
+
+func printNodeImages(w io.Writer, nodes []cluster.NodeImages) {
+	tw := tabwriter.NewWriter(w, 0, 4, 3, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NODE\tIMAGE\tID\tSIZE")
+	for _, node := range nodes {
+		for _, image := range node.Images {
+			id := strings.TrimPrefix(image.ID, "sha256:")
+			if len(id) > 12 {
+				id = id[:12]
+			}
+			size := units.HumanSize(float64(image.Size))
+
+			// Untagged images are only known by digest.
+			names := image.RepoTags
+			if len(names) == 0 {
+				names = image.RepoDigests
+			}
+			if len(names) == 0 {
+				names = []string{"<none>"}
+			}
+			for _, name := range names {
+				_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", node.Node, name, id, size)
+			}
+		}
+	}
+	_ = tw.Flush()
+}
+
+func printImageCheck(w io.Writer, results []imageCheckResult) {
+	for _, result := range results {
+		status := "present"
+		if !result.Present {
+			status = "missing"
+		}
+		_, _ = fmt.Fprintf(w, "%s: %s %s\n", result.Node, result.Image, status)
+	}
+}
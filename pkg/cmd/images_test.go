@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+var testNodeImages = []cluster.NodeImages{
+	{
+		Node: "kind-control-plane",
+		Images: []cluster.NodeImage{
+			{
+				ID:       "sha256:a8758716bb6aa4d90071160d27028fe4eaee7ce8166221a97d30440c8eac2be6",
+				RepoTags: []string{"docker.io/library/nginx:1.25"},
+				Size:     70520324,
+			},
+		},
+	},
+	{Node: "kind-worker", Images: []cluster.NodeImage{}},
+}
+
+func TestPrintNodeImages(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	printNodeImages(out, testNodeImages)
+	assert.Equal(t, `NODE                 IMAGE                          ID             SIZE
+kind-control-plane   docker.io/library/nginx:1.25   a8758716bb6a   70.52MB
+`, out.String())
+}
+
+func TestCheckNodeImages(t *testing.T) {
+	results, err := checkNodeImages(testNodeImages, "nginx:1.25")
+	require.NoError(t, err)
+
+	out := bytes.NewBuffer(nil)
+	printImageCheck(out, results)
+	assert.Equal(t, `kind-control-plane: nginx:1.25 present
+kind-worker: nginx:1.25 missing
+`, out.String())
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+func NewIngressIPCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ingress-ip [cluster]",
+		Short: "Print the IP that serves a cluster's Ingresses",
+		Long: "Print the IP that serves a cluster's Ingresses, e.g., to point hostnames at in /etc/hosts.\n\n" +
+			"If the cluster maps port 80 of a node to port 80 of the host with extraPortMappings, prints 127.0.0.1. " +
+			"Otherwise, prints the IP of the control-plane node on its Docker network.\n\n" +
+			"Only works on kind clusters.",
+		Example: "  ctlptl ingress-ip kind-kind\n" +
+			"  echo \"$(ctlptl ingress-ip kind-kind) app.local\" | sudo tee -a /etc/hosts",
+		Run:  withClusterController("ingress-ip", ingressIP),
+		Args: cobra.ExactArgs(1),
+	}
+}
+
+func ingressIP(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	ip, err := c.GetIngressIP(ctx, cl.Name)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(streams.Out, ip.String())
+	return nil
+}
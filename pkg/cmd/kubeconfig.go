@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+type kubeconfigOptions struct {
+	Prefix string
+}
+
+func NewKubeconfigCommand() *cobra.Command {
+	o := &kubeconfigOptions{}
+	cmd := &cobra.Command{
+		Use:   "kubeconfig [cluster]",
+		Short: "Print a standalone kubeconfig for a cluster, with optionally prefixed names",
+		Long: "Print a standalone kubeconfig for a cluster, with credentials inlined.\n\n" +
+			"With --prefix, the names of the context, cluster, and user start with the prefix, " +
+			"so kubeconfigs from many projects can be merged into one file without collisions.",
+		Example: "  ctlptl kubeconfig kind-dev --prefix=myproject- > myproject.kubeconfig\n" +
+			"  KUBECONFIG=~/.kube/config:myproject.kubeconfig kubectl config view --flatten",
+		Run:  withClusterController("kubeconfig", o.run),
+		Args: cobra.ExactArgs(1),
+	}
+	cmd.Flags().StringVar(&o.Prefix, "prefix", "", "A prefix for the names of the context, cluster, and user (e.g., myproject-)")
+	return cmd
+}
+
+func (o *kubeconfigOptions) run(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	kubeconfig, err := c.ExportKubeconfig(ctx, cl.Name, o.Prefix)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprint(streams.Out, kubeconfig)
+	return nil
+}
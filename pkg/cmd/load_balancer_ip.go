@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+type loadBalancerIPOptions struct {
+	Timeout time.Duration
+	Output  string
+}
+
+func NewLoadBalancerIPCommand() *cobra.Command {
+	o := &loadBalancerIPOptions{Timeout: 2 * time.Minute}
+	cmd := &cobra.Command{
+		Use:   "load-balancer-ip [cluster] [namespace/]service",
+		Short: "Wait for a LoadBalancer service to get an external IP, and print it",
+		Long: "Wait for a LoadBalancer service to get an external IP, and print it.\n\n" +
+			"Also prints the address pool that MetalLB assigns from, on clusters with loadBalancer set. " +
+			"The namespace defaults to 'default'. With --timeout=0, prints the IP without waiting, " +
+			"or <pending> if there isn't one yet.",
+		Example: "  ctlptl load-balancer-ip kind-kind my-app\n" +
+			"  curl http://$(ctlptl load-balancer-ip kind-kind ingress-nginx/ingress-nginx-controller)/\n" +
+			"  ctlptl load-balancer-ip kind-kind my-app --timeout=5m -o json",
+		Run:  withClusterController("load-balancer-ip", o.run),
+		Args: cobra.ExactArgs(2),
+	}
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", o.Timeout, "How long to wait for an external IP. 0 means don't wait")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "Output format. One of: json")
+	return cmd
+}
+
+func (o *loadBalancerIPOptions) run(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	if o.Output != "" && o.Output != "json" {
+		return fmt.Errorf("unsupported output format %q. Supported: json", o.Output)
+	}
+	if o.Timeout < 0 {
+		return fmt.Errorf("--timeout must be non-negative. Actual: %s", o.Timeout)
+	}
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	namespace, name, ok := strings.Cut(args[1], "/")
+	if !ok {
+		namespace, name = "default", args[1]
+	}
+	if namespace == "" || name == "" {
+		return fmt.Errorf("service must be NAME or NAMESPACE/NAME. Actual: %s", args[1])
+	}
+
+	addr, err := c.WaitForLoadBalancerIP(ctx, cl.Name, namespace, name, o.Timeout)
+	if err != nil {
+		return err
+	}
+	if o.Output == "json" {
+		return writeIndentedJSON(streams.Out, addr)
+	}
+	printLoadBalancerAddress(streams.Out, streams.ErrOut, addr)
+	return nil
+}
+
+// Prints the IPs to stdout, so that scripts can use them, and the pool to stderr.
+func printLoadBalancerAddress(out, errOut io.Writer, addr *cluster.LoadBalancerAddress) {
+	if addr.Pool != "" {
+		_, _ = fmt.Fprintf(errOut, "Address pool: %s\n", addr.Pool)
+	}
+	if len(addr.IPs) == 0 {
+		_, _ = fmt.Fprintln(out, "<pending>")
+		return
+	}
+	for _, ip := range addr.IPs {
+		_, _ = fmt.Fprintln(out, ip)
+	}
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+type loadImagesOptions struct {
+	Parallelism int
+}
+
+func NewLoadImagesCommand() *cobra.Command {
+	o := &loadImagesOptions{Parallelism: 2}
+	cmd := &cobra.Command{
+		Use:   "load-images [cluster] [image...]",
+		Short: "Load images from the local Docker daemon into every node of a cluster",
+		Long: "Load images from the local Docker daemon into every node of a cluster, " +
+			"so pods can start without pulling them. Images that the daemon doesn't have are pulled first.\n\n" +
+			"Loads --parallelism images at once. Raise it to warm up faster on machines with fast disks, " +
+			"or set it to 1 on constrained ones. Keeps going when an image fails, and lists the failures at the end.\n\n" +
+			"Only works on kind and k3d clusters.",
+		Example: "  ctlptl load-images kind-kind nginx:1.25 redis:7\n" +
+			"  ctlptl load-images kind-kind --parallelism=4 $(cat images.txt)",
+		Run:  withClusterController("load-images", o.run),
+		Args: cobra.MinimumNArgs(2),
+	}
+	cmd.Flags().IntVar(&o.Parallelism, "parallelism", o.Parallelism, "How many images to pull and load at once")
+	return cmd
+}
+
+func (o *loadImagesOptions) run(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	if o.Parallelism < 1 {
+		return fmt.Errorf("--parallelism must be at least 1. Actual: %d", o.Parallelism)
+	}
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	images := args[1:]
+	err = c.LoadImages(ctx, cl.Name, images, cluster.LoadImagesOptions{
+		Parallelism: o.Parallelism,
+		OnProgress: func(p cluster.ImageLoadProgress) {
+			printImageLoadProgress(streams.ErrOut, p)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(streams.Out, "Loaded %d images into cluster %s in %s\n",
+		len(images), cl.Name, time.Since(start).Round(100*time.Millisecond))
+	return nil
+}
+
+func printImageLoadProgress(w io.Writer, p cluster.ImageLoadProgress) {
+	prefix := fmt.Sprintf("[%d/%d]", p.Index+1, p.Total)
+	switch {
+	case !p.Done:
+		_, _ = fmt.Fprintf(w, "%s Loading %s\n", prefix, p.Image)
+	case p.Err != nil:
+		_, _ = fmt.Fprintf(w, "%s Failed %s: %v\n", prefix, p.Image, p.Err)
+	default:
+		_, _ = fmt.Fprintf(w, "%s Loaded %s in %s\n", prefix, p.Image, p.Duration.Round(100*time.Millisecond))
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+type networkOptions struct {
+	Output string
+}
+
+func NewNetworkCommand() *cobra.Command {
+	o := &networkOptions{}
+	cmd := &cobra.Command{
+		Use:   "network [cluster]",
+		Short: "Print the Docker network of a cluster, and the containers attached to it",
+		Long: "Print the Docker network of a cluster, and the containers attached to it.\n\n" +
+			"Useful for debugging connectivity between the cluster and other containers, like registries. " +
+			"Docker Desktop clusters don't have their own network, so this prints the host network.",
+		Example: "  ctlptl network kind-kind\n" +
+			"  ctlptl network k3d-k3s-default -o json",
+		Run:  withClusterController("network", o.run),
+		Args: cobra.ExactArgs(1),
+	}
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "Output format. One of: json")
+	return cmd
+}
+
+func (o *networkOptions) run(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	if o.Output != "" && o.Output != "json" {
+		return fmt.Errorf("unsupported output format %q. Supported: json", o.Output)
+	}
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+	info, err := c.GetNetworkInfo(ctx, cl.Name)
+	if err != nil {
+		return err
+	}
+	if o.Output == "json" {
+		return writeIndentedJSON(streams.Out, info)
+	}
+	printNetworkInfo(streams.Out, info)
+	return nil
+}
+
+func printNetworkInfo(w io.Writer, info *cluster.ClusterNetworkInfo) {
+	_, _ = fmt.Fprintf(w, "Network: %s\n", info.NetworkName)
+	if info.Subnet != "" {
+		_, _ = fmt.Fprintf(w, "Subnet:  %s\n", info.Subnet)
+	}
+	if info.Gateway != "" {
+		_, _ = fmt.Fprintf(w, "Gateway: %s\n", info.Gateway)
+	}
+	if len(info.Containers) == 0 {
+		_, _ = fmt.Fprintln(w, "No containers attached")
+		return
+	}
+
+	_, _ = fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 4, 3, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "CONTAINER\tIP\tROLE")
+	for _, container := range info.Containers {
+		ip := container.IP
+		if ip == "" {
+			ip = "<none>"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", container.ContainerName, ip, container.Role)
+	}
+	_ = tw.Flush()
+}
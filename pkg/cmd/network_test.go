@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+func TestPrintNetworkInfo(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	printNetworkInfo(out, &cluster.ClusterNetworkInfo{
+		NetworkName: "kind",
+		Subnet:      "172.18.0.0/16",
+		Gateway:     "172.18.0.1",
+		Containers: []cluster.ContainerNetworkEntry{
+			{ContainerName: "ctlptl-registry", IP: "172.18.0.4", Role: "other"},
+			{ContainerName: "kind-control-plane", IP: "172.18.0.3", Role: "control-plane"},
+		},
+	})
+	assert.Equal(t, `Network: kind
+Subnet:  172.18.0.0/16
+Gateway: 172.18.0.1
+
+CONTAINER            IP           ROLE
+ctlptl-registry      172.18.0.4   other
+kind-control-plane   172.18.0.3   control-plane
+`, out.String())
+
+	out.Reset()
+	printNetworkInfo(out, &cluster.ClusterNetworkInfo{NetworkName: "host"})
+	assert.Equal(t, "Network: host\nNo containers attached\n", out.String())
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+type NodeIPOptions struct {
+	genericclioptions.IOStreams
+
+	Node string
+}
+
+func NewNodeIPOptions() *NodeIPOptions {
+	return &NodeIPOptions{
+		IOStreams: genericclioptions.IOStreams{Out: os.Stdout, ErrOut: os.Stderr, In: os.Stdin},
+	}
+}
+
+func (o *NodeIPOptions) Command() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "node-ip [cluster]",
+		Short: "Print the internal IP of a cluster node",
+		Long: "Print the internal IP of a cluster node, as reported by the Kubernetes API.\n\n" +
+			"Defaults to the first control-plane node. On kind clusters, also checks that the IP " +
+			"matches the node's Docker container.",
+		Example: "  ctlptl node-ip kind-kind\n" +
+			"  curl http://$(ctlptl node-ip kind-kind --node=kind-worker):30080",
+		Run:  o.Run,
+		Args: cobra.ExactArgs(1),
+	}
+
+	cmd.SetOut(o.Out)
+	cmd.SetErr(o.ErrOut)
+	cmd.Flags().StringVar(&o.Node, "node", o.Node, "The name of the node. If not specified, uses the first control-plane node")
+
+	return cmd
+}
+
+func (o *NodeIPOptions) Run(cmd *cobra.Command, args []string) {
+	a, err := newAnalytics()
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "analytics: %v\n", err)
+		os.Exit(1)
+	}
+	a.Incr("cmd.node-ip", nil)
+	defer a.Flush(time.Second)
+
+	c, err := newClusterController(o.IOStreams)
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "Loading controller: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = o.run(c, args[0])
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+type nodeIPGetter interface {
+	clusterGetter
+	GetNodeIP(ctx context.Context, clusterName, nodeName string) (net.IP, error)
+}
+
+func (o *NodeIPOptions) run(c nodeIPGetter, name string) error {
+	ctx := context.Background()
+	cluster, err := normalizedGet(ctx, c, name)
+	if err != nil {
+		return err
+	}
+
+	ip, err := c.GetNodeIP(ctx, cluster.Name, o.Node)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(o.Out, ip)
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 
 	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
 )
 
 type clusterGetter interface {
@@ -19,9 +20,9 @@ type clusterGetter interface {
 // But for cases where they don't match, we want
 // `ctlptl delete cluster kind` to automatically map to `ctlptl delete cluster kind-kind`
 func normalizedGet(ctx context.Context, controller clusterGetter, name string) (*api.Cluster, error) {
-	cluster, err := controller.Get(ctx, name)
+	cl, err := controller.Get(ctx, name)
 	if err == nil {
-		return cluster, nil
+		return cl, nil
 	}
 
 	if !errors.IsNotFound(err) {
@@ -34,15 +35,17 @@ func normalizedGet(ctx context.Context, controller clusterGetter, name string) (
 		retryName = clusterid.ProductKIND.DefaultClusterName()
 	} else if name == string(clusterid.ProductK3D) {
 		retryName = clusterid.ProductK3D.DefaultClusterName()
+	} else if name == string(cluster.ProductKwok) {
+		retryName = cluster.DefaultClusterName(cluster.ProductKwok)
 	}
 
 	if retryName == "" {
 		return nil, origErr
 	}
 
-	cluster, err = controller.Get(ctx, retryName)
+	cl, err = controller.Get(ctx, retryName)
 	if err == nil {
-		return cluster, nil
+		return cl, nil
 	}
 	return nil, origErr
 }
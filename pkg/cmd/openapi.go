@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+type OpenAPIOptions struct {
+	genericclioptions.IOStreams
+
+	Version string
+	Output  string
+}
+
+func NewOpenAPIOptions() *OpenAPIOptions {
+	return &OpenAPIOptions{
+		IOStreams: genericclioptions.IOStreams{Out: os.Stdout, ErrOut: os.Stderr, In: os.Stdin},
+	}
+}
+
+func (o *OpenAPIOptions) Command() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "openapi [cluster]",
+		Short: "Print the OpenAPI spec of a cluster's apiserver, including CRDs",
+		Long: "Print the OpenAPI spec of a cluster's apiserver as JSON, including the schemas of installed CRDs.\n\n" +
+			"By default, prints the OpenAPI v3 spec, merged into one document, or the v2 spec " +
+			"if the cluster doesn't serve v3.",
+		Example: "  ctlptl openapi kind-kind > schema.json\n" +
+			"  ctlptl openapi kind-kind --version v2 --output swagger.json",
+		Run:  o.Run,
+		Args: cobra.ExactArgs(1),
+	}
+
+	cmd.SetOut(o.Out)
+	cmd.SetErr(o.ErrOut)
+	cmd.Flags().StringVar(&o.Version, "version", o.Version, "The OpenAPI version to print. One of: v2, v3")
+	cmd.Flags().StringVar(&o.Output, "output", o.Output, "Write the spec to this file, instead of stdout")
+
+	return cmd
+}
+
+func (o *OpenAPIOptions) Run(cmd *cobra.Command, args []string) {
+	a, err := newAnalytics()
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "analytics: %v\n", err)
+		os.Exit(1)
+	}
+	a.Incr("cmd.openapi", nil)
+	defer a.Flush(time.Second)
+
+	c, err := newClusterController(o.IOStreams)
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "Loading controller: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = o.run(c, args[0])
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+type openAPIGetter interface {
+	clusterGetter
+	GetOpenAPISpecVersion(ctx context.Context, clusterName, version string) ([]byte, error)
+}
+
+func (o *OpenAPIOptions) run(c openAPIGetter, name string) error {
+	ctx := context.Background()
+	cluster, err := normalizedGet(ctx, c, name)
+	if err != nil {
+		return err
+	}
+
+	spec, err := c.GetOpenAPISpecVersion(ctx, cluster.Name, o.Version)
+	if err != nil {
+		return err
+	}
+
+	if o.Output != "" {
+		err = os.WriteFile(o.Output, spec, 0644)
+		if err != nil {
+			return fmt.Errorf("writing OpenAPI spec: %v", err)
+		}
+		_, _ = fmt.Fprintf(o.ErrOut, "Wrote OpenAPI spec of cluster %s to %s\n", cluster.Name, o.Output)
+		return nil
+	}
+
+	_, err = o.Out.Write(spec)
+	return err
+}
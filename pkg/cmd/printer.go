@@ -1,12 +1,20 @@
 package cmd
 
 import (
+	"os"
+	"strings"
+
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/printers"
 
 	myprinters "github.com/tilt-dev/ctlptl/internal/printers"
 )
 
+var templateFileFormats = map[string]bool{
+	"templatefile":     true,
+	"go-template-file": true,
+}
+
 func toPrinter(flags *genericclioptions.PrintFlags) (printers.ResourcePrinter, error) {
 	p, err := flags.ToPrinter()
 	if err != nil {
@@ -19,5 +27,40 @@ func toPrinter(flags *genericclioptions.PrintFlags) (printers.ResourcePrinter, e
 			Operation:   namePrinter.Operation,
 		}, nil
 	}
+	if _, ok := p.(*printers.GoTemplatePrinter); ok {
+		return toTemplatePrinter(flags)
+	}
 	return p, nil
 }
+
+// Re-reads the template flags that kubectl's printer has already validated,
+// and renders with our own template printer instead.
+func toTemplatePrinter(flags *genericclioptions.PrintFlags) (printers.ResourcePrinter, error) {
+	format := ""
+	if flags.OutputFormat != nil {
+		format = *flags.OutputFormat
+	}
+
+	tmpl := ""
+	templateFlags := flags.TemplatePrinterFlags
+	if templateFlags.TemplateArgument != nil && *templateFlags.TemplateArgument != "" {
+		tmpl = *templateFlags.TemplateArgument
+	} else if i := strings.Index(format, "="); i >= 0 {
+		tmpl = format[i+1:]
+		format = format[:i]
+	}
+
+	if templateFileFormats[format] {
+		data, err := os.ReadFile(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		tmpl = string(data)
+	}
+
+	allowMissingKeys := true
+	if templateFlags.AllowMissingKeys != nil {
+		allowMissingKeys = *templateFlags.AllowMissingKeys
+	}
+	return myprinters.NewTemplatePrinter(tmpl, allowMissingKeys)
+}
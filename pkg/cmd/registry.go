@@ -0,0 +1,518 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/internal/toolinstall"
+	"github.com/tilt-dev/ctlptl/pkg/registry"
+)
+
+func NewRegistryCommand() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "registry",
+		Short: "Inspect and maintain local registries",
+		Example: "  ctlptl registry last-push ctlptl-registry\n" +
+			"  ctlptl registry catalog ctlptl-registry\n" +
+			"  ctlptl registry tags registry.example.com my-app --username me --password-stdin\n" +
+			"  ctlptl registry scan ctlptl-registry my-app:latest --severity=HIGH,CRITICAL --exit-code=1\n" +
+			"  ctlptl registry defragment ctlptl-registry\n" +
+			"  ctlptl registry gc ctlptl-registry --dry-run\n" +
+			"  ctlptl registry auto-cleanup ctlptl-registry --keep=3\n" +
+			"  ctlptl registry push ctlptl-registry --match 'dev/*'\n" +
+			"  ctlptl registry watch ctlptl-registry\n" +
+			"  ctlptl registry rotate-auth ctlptl-registry\n" +
+			"  ctlptl registry test ctlptl-registry --cluster kind-kind",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "last-push [registry]",
+		Short: "Print when an image was last pushed to a registry",
+		Long: "Print when an image was last pushed to a registry.\n\n" +
+			"Prints 'never' if nothing has been pushed. Useful in CI for deciding whether to re-push images.",
+		Run:  withRegistryController("registry-last-push", registryLastPush),
+		Args: cobra.ExactArgs(1),
+	})
+
+	auth := &registryAuthOptions{}
+	catalogCmd := &cobra.Command{
+		Use:   "catalog [registry]",
+		Short: "List the repositories in a registry",
+		Long: "List the repositories in a registry.\n\n" +
+			"The registry may be the name of a local registry, or the address of any registry " +
+			"that implements the Docker Registry HTTP API V2.",
+		Run:  withRegistryController("registry-catalog", auth.catalog),
+		Args: cobra.ExactArgs(1),
+	}
+	auth.addFlags(catalogCmd)
+	cmd.AddCommand(catalogCmd)
+
+	tagsCmd := &cobra.Command{
+		Use:   "tags [registry] [repository]",
+		Short: "List the tags of a repository in a registry",
+		Run:   withRegistryController("registry-tags", auth.tags),
+		Args:  cobra.ExactArgs(2),
+	}
+	auth.addFlags(tagsCmd)
+	cmd.AddCommand(tagsCmd)
+
+	scan := &registryScanOptions{}
+	scanCmd := &cobra.Command{
+		Use:   "scan [registry] [repository:tag]",
+		Short: "Scan an image in a registry for vulnerabilities with trivy",
+		Long: "Scan an image in a registry for vulnerabilities with trivy, and print them, most severe first.\n\n" +
+			"Downloads the image with the registry API, not with docker pull, so it works with any registry " +
+			"that implements the Docker Registry HTTP API V2. For a multi-platform image, scans the image for " +
+			"the host's architecture. Uses trivy from the PATH, or downloads it with --auto-install.",
+		Run:  withRegistryController("registry-scan", scan.run),
+		Args: cobra.ExactArgs(2),
+	}
+	scan.addFlags(scanCmd)
+	scanCmd.Flags().StringVar(&scan.Severity, "severity", "",
+		"Only report vulnerabilities with these comma-separated severities (UNKNOWN, LOW, MEDIUM, HIGH, CRITICAL)")
+	scanCmd.Flags().IntVar(&scan.ExitCode, "exit-code", 0, "The exit code when vulnerabilities are found")
+	cmd.AddCommand(scanCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "defragment [registry]",
+		Short: "Rebuild a local registry's storage to reclaim disk space",
+		Long: "Rebuild a local registry's storage to reclaim disk space.\n\n" +
+			"Exports every tagged image, then re-imports them into a new registry container " +
+			"with fresh storage. Untagged images are dropped. The registry is read-only while " +
+			"it's being exported. If anything fails, the original registry is restored.",
+		Run:  withRegistryController("registry-defragment", registryDefragment),
+		Args: cobra.ExactArgs(1),
+	})
+
+	gc := &registryGCOptions{}
+	gcCmd := &cobra.Command{
+		Use:   "gc [registry]",
+		Short: "Delete blobs that no image references from a local registry",
+		Long: "Delete blobs that no image references from a local registry.\n\n" +
+			"Runs 'registry garbage-collect' inside the registry container. The registry is read-only " +
+			"while it's being collected. Use --dry-run to see what would be deleted and how much space " +
+			"it would reclaim, without deleting anything.",
+		Run:  withRegistryController("registry-gc", gc.run),
+		Args: cobra.ExactArgs(1),
+	}
+	gcCmd.Flags().BoolVar(&gc.DryRun, "dry-run", false, "Report what would be deleted, without deleting anything")
+	gcCmd.Flags().StringVarP(&gc.Output, "output", "o", "", "Output format. One of: json")
+	cmd.AddCommand(gcCmd)
+
+	cleanup := &registryAutoCleanupOptions{}
+	cleanupCmd := &cobra.Command{
+		Use:   "auto-cleanup [registry]",
+		Short: "Keep the most recent images of each repository in a local registry, and delete the rest",
+		Long: "Keep the most recent images of each repository in a local registry, and delete the rest.\n\n" +
+			"Images are ordered by when they were created. Tags that point at the same image count once. " +
+			"Untagged manifests, like the ones left behind when a tag is pushed again, are deleted too. " +
+			"Deleting an image doesn't free its disk space until the registry is garbage collected " +
+			"with 'ctlptl registry gc'. Registries with auth use Docker's credentials for the registry, " +
+			"unless --username is set.",
+		Run:  withRegistryController("registry-auto-cleanup", cleanup.run),
+		Args: cobra.ExactArgs(1),
+	}
+	cleanupCmd.Flags().IntVar(&cleanup.Keep, "keep", 0, "The number of images to keep in each repository")
+	cleanup.addFlags(cleanupCmd)
+	_ = cleanupCmd.MarkFlagRequired("keep")
+	cmd.AddCommand(cleanupCmd)
+
+	push := &registryPushOptions{}
+	pushCmd := &cobra.Command{
+		Use:   "push [registry]",
+		Short: "Re-tag local images that match a pattern for a local registry, and push them",
+		Long: "Re-tag local images that match a pattern for a local registry, and push them.\n\n" +
+			"The pattern is a glob matched against the repository of each local image " +
+			"(dev/*), or against repository:tag (dev/*:latest). A * doesn't match a /. " +
+			"Images keep their repository and tag, minus any registry host. " +
+			"Use --dry-run to see what would be pushed.",
+		Run:  withRegistryController("registry-push", push.run),
+		Args: cobra.ExactArgs(1),
+	}
+	pushCmd.Flags().StringVar(&push.Match, "match", "", "A glob that selects the local images to push")
+	pushCmd.Flags().BoolVar(&push.DryRun, "dry-run", false, "Report what would be pushed, without pushing anything")
+	_ = pushCmd.MarkFlagRequired("match")
+	cmd.AddCommand(pushCmd)
+
+	watch := &registryWatchOptions{}
+	watchCmd := &cobra.Command{
+		Use:   "watch [registry]",
+		Short: "Print each tag that's pushed to or deleted from a local registry",
+		Long: "Print each tag that's pushed to or deleted from a local registry, until interrupted.\n\n" +
+			"Prints '[PUSHED] <repo>:<tag> @ <digest>' when a tag is pushed, or re-pushed with a new image, " +
+			"and '[DELETED] <repo>:<tag>' when a tag is deleted. Tags that exist when the watch starts " +
+			"aren't printed. Checks the registry every --interval. Registries with auth use Docker's " +
+			"credentials for the registry, unless --username is set.",
+		Run:  withRegistryController("registry-watch", watch.run),
+		Args: cobra.ExactArgs(1),
+	}
+	watchCmd.Flags().DurationVar(&watch.Interval, "interval", 500*time.Millisecond, "How often to check the registry for changes")
+	watch.addFlags(watchCmd)
+	cmd.AddCommand(watchCmd)
+
+	rotate := &registryRotateAuthOptions{}
+	rotateCmd := &cobra.Command{
+		Use:   "rotate-auth [registry]",
+		Short: "Replace the password of a local registry with auth, and update the Docker credentials",
+		Long: "Replace the password of a local registry with auth, and update the Docker credentials.\n\n" +
+			"Generates a new password, unless one is passed with --password-stdin. Registries that re-read " +
+			"their credentials while running (registry:3 and later) pick up the new password without restarting. " +
+			"Older registries are re-created with the new password, and keep their storage. " +
+			"Either way, logs Docker in to the registry with the new password.",
+		Run:  withRegistryController("registry-rotate-auth", rotate.run),
+		Args: cobra.ExactArgs(1),
+	}
+	rotateCmd.Flags().BoolVar(&rotate.PasswordStdin, "password-stdin", false, "Read the new password from stdin")
+	cmd.AddCommand(rotateCmd)
+
+	test := &registryTestOptions{}
+	testCmd := &cobra.Command{
+		Use:   "test [registry]",
+		Short: "Check that images pushed to a local registry from the host can be pulled in a cluster",
+		Long: "Check that images pushed to a local registry from the host can be pulled in a cluster.\n\n" +
+			"Pushes a tiny test image to the registry with the docker CLI, then runs a short-lived pod " +
+			"in the cluster that pulls it, from the address that the cluster advertises for the registry. " +
+			"Reports whether each leg worked and how long it took, and how to fix it if it didn't.",
+		Run:  withRegistryController("registry-test", test.run),
+		Args: cobra.ExactArgs(1),
+	}
+	testCmd.Flags().StringVar(&test.Cluster, "cluster", "", "The cluster to pull the image in. Defaults to the current cluster")
+	testCmd.Flags().DurationVar(&test.Timeout, "timeout", time.Minute, "How long to wait for the cluster to pull the image")
+	cmd.AddCommand(testCmd)
+
+	return cmd
+}
+
+func withRegistryController(name string, run func(ctx context.Context, c *registry.Controller, streams genericclioptions.IOStreams, args []string) error) func(_ *cobra.Command, args []string) {
+	return func(_ *cobra.Command, args []string) {
+		a, err := newAnalytics()
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "analytics: %v\n", err)
+			os.Exit(1)
+		}
+		a.Incr(fmt.Sprintf("cmd.%s", name), nil)
+		defer a.Flush(time.Second)
+
+		streams := genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
+		c, err := registry.DefaultController(streams)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Loading controller: %v\n", err)
+			os.Exit(1)
+		}
+
+		err = run(context.Background(), c, streams, args)
+		if exitErr, ok := err.(exitCodeError); ok {
+			a.Flush(time.Second)
+			os.Exit(int(exitErr))
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func registryLastPush(ctx context.Context, c *registry.Controller, streams genericclioptions.IOStreams, args []string) error {
+	lastPushed, err := c.GetLastPushed(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(streams.Out, formatLastPushed(lastPushed, time.Now()))
+	return nil
+}
+
+func registryDefragment(ctx context.Context, c *registry.Controller, streams genericclioptions.IOStreams, args []string) error {
+	result, err := c.Defragment(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(streams.Out, "Defragmented registry %s: %d images, %s -> %s\n",
+		args[0], result.Images, units.BytesSize(float64(result.SizeBefore)), units.BytesSize(float64(result.SizeAfter)))
+	return nil
+}
+
+type registryGCOptions struct {
+	DryRun bool
+	Output string
+}
+
+func (o *registryGCOptions) run(ctx context.Context, c *registry.Controller, streams genericclioptions.IOStreams, args []string) error {
+	if o.Output != "" && o.Output != "json" {
+		return fmt.Errorf("unsupported output format %q. Supported: json", o.Output)
+	}
+	result, err := c.GarbageCollect(ctx, args[0], registry.GarbageCollectOptions{DryRun: o.DryRun})
+	if err != nil {
+		return err
+	}
+	if o.Output == "json" {
+		encoder := json.NewEncoder(streams.Out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	printGarbageCollect(streams.Out, args[0], result)
+	return nil
+}
+
+func printGarbageCollect(w io.Writer, name string, result *registry.GarbageCollectResult) {
+	verb := "Deleted"
+	if result.DryRun {
+		verb = "Would delete"
+	}
+	_, _ = fmt.Fprintf(w, "%s %d blobs from registry %s, reclaiming %s (%d blobs in use)\n",
+		verb, len(result.Blobs), name, units.BytesSize(float64(result.ReclaimableBytes)), result.BlobsMarked)
+	for _, blob := range result.Blobs {
+		_, _ = fmt.Fprintf(w, "  %s  %s\n", blob.Digest, units.BytesSize(float64(blob.Size)))
+	}
+}
+
+type registryAutoCleanupOptions struct {
+	registryAuthOptions
+	Keep int
+}
+
+func (o *registryAutoCleanupOptions) run(ctx context.Context, c *registry.Controller, streams genericclioptions.IOStreams, args []string) error {
+	password, err := o.password(streams)
+	if err != nil {
+		return err
+	}
+	return c.AutoCleanup(ctx, args[0], registry.AutoCleanupOptions{Keep: o.Keep, Username: o.Username, Password: password})
+}
+
+type registryPushOptions struct {
+	Match  string
+	DryRun bool
+}
+
+func (o *registryPushOptions) run(ctx context.Context, c *registry.Controller, streams genericclioptions.IOStreams, args []string) error {
+	result, err := c.Push(ctx, args[0], registry.PushOptions{Match: o.Match, DryRun: o.DryRun})
+	if err != nil {
+		return err
+	}
+	printPush(streams.Out, args[0], o.Match, result)
+	return nil
+}
+
+func printPush(w io.Writer, name, match string, result *registry.PushResult) {
+	if len(result.Images) == 0 {
+		_, _ = fmt.Fprintf(w, "No local images match %s\n", match)
+		return
+	}
+	verb := "Pushed"
+	if result.DryRun {
+		verb = "Would push"
+	}
+	_, _ = fmt.Fprintf(w, "%s %d images to registry %s\n", verb, len(result.Images), name)
+	for _, image := range result.Images {
+		_, _ = fmt.Fprintf(w, "  %s -> %s\n", image.Source, image.Target)
+	}
+}
+
+type registryWatchOptions struct {
+	registryAuthOptions
+	Interval time.Duration
+}
+
+func (o *registryWatchOptions) run(ctx context.Context, c *registry.Controller, streams genericclioptions.IOStreams, args []string) error {
+	password, err := o.password(streams)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	_, _ = fmt.Fprintf(streams.ErrOut, "Watching registry %s. Press Ctrl-C to stop.\n", args[0])
+	options := registry.WatchOptions{Interval: o.Interval, Username: o.Username, Password: password}
+	return c.Watch(ctx, args[0], options, func(event registry.WatchEvent) {
+		_, _ = fmt.Fprintln(streams.Out, event)
+	})
+}
+
+type registryRotateAuthOptions struct {
+	PasswordStdin bool
+}
+
+func (o *registryRotateAuthOptions) run(ctx context.Context, c *registry.Controller, streams genericclioptions.IOStreams, args []string) error {
+	password := ""
+	if o.PasswordStdin {
+		data, err := io.ReadAll(streams.In)
+		if err != nil {
+			return fmt.Errorf("reading password: %v", err)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+		if password == "" {
+			return fmt.Errorf("--password-stdin: empty password")
+		}
+	}
+
+	result, err := c.RotateAuth(ctx, args[0], registry.RotateAuthOptions{Password: password})
+	if err != nil {
+		return err
+	}
+	how := "re-created"
+	if result.Reloaded {
+		how = "reloaded"
+	}
+	_, _ = fmt.Fprintf(streams.Out, "Rotated credentials of registry %s (%s)\n", args[0], how)
+	return nil
+}
+
+// Credentials for registries that require auth.
+type registryAuthOptions struct {
+	Username      string
+	PasswordStdin bool
+}
+
+func (o *registryAuthOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Username, "username", "", "Username for registries that require auth")
+	cmd.Flags().BoolVar(&o.PasswordStdin, "password-stdin", false, "Read the password for --username from stdin")
+}
+
+// Reads the password for --username from stdin, if --password-stdin is set.
+func (o *registryAuthOptions) password(streams genericclioptions.IOStreams) (string, error) {
+	if !o.PasswordStdin {
+		return "", nil
+	}
+	if o.Username == "" {
+		return "", fmt.Errorf("--password-stdin requires --username")
+	}
+	data, err := io.ReadAll(streams.In)
+	if err != nil {
+		return "", fmt.Errorf("reading password: %v", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func (o *registryAuthOptions) client(ctx context.Context, c *registry.Controller, streams genericclioptions.IOStreams, nameOrAddress string) (*registry.Client, error) {
+	password, err := o.password(streams)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := registryAddress(ctx, c, nameOrAddress)
+	if err != nil {
+		return nil, err
+	}
+	return registry.NewClient(address, o.Username, password)
+}
+
+// Resolves the name of a local registry to its address on the host.
+// Anything that looks like a host or URL is used as-is.
+func registryAddress(ctx context.Context, c *registry.Controller, nameOrAddress string) (string, error) {
+	if strings.ContainsAny(nameOrAddress, ".:/") {
+		return nameOrAddress, nil
+	}
+	reg, err := c.Get(ctx, nameOrAddress)
+	if err != nil {
+		return "", err
+	}
+	if reg.Status.HostPort == 0 {
+		return "", fmt.Errorf("registry %s is not listening on the host", nameOrAddress)
+	}
+	return fmt.Sprintf("localhost:%d", reg.Status.HostPort), nil
+}
+
+func (o *registryAuthOptions) catalog(ctx context.Context, c *registry.Controller, streams genericclioptions.IOStreams, args []string) error {
+	client, err := o.client(ctx, c, streams, args[0])
+	if err != nil {
+		return err
+	}
+	repos, err := client.Catalog(ctx)
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		_, _ = fmt.Fprintln(streams.Out, repo)
+	}
+	return nil
+}
+
+func (o *registryAuthOptions) tags(ctx context.Context, c *registry.Controller, streams genericclioptions.IOStreams, args []string) error {
+	client, err := o.client(ctx, c, streams, args[0])
+	if err != nil {
+		return err
+	}
+	tags, err := client.Tags(ctx, args[1])
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		_, _ = fmt.Fprintln(streams.Out, tag)
+	}
+	return nil
+}
+
+type registryScanOptions struct {
+	registryAuthOptions
+	Severity string
+	ExitCode int
+}
+
+func (o *registryScanOptions) run(ctx context.Context, c *registry.Controller, streams genericclioptions.IOStreams, args []string) error {
+	severities, err := registry.ParseSeverities(o.Severity)
+	if err != nil {
+		return fmt.Errorf("--severity: %v", err)
+	}
+	trivy, err := toolinstall.Ensure(ctx, streams.ErrOut, toolinstall.Trivy)
+	if err != nil {
+		return err
+	}
+	if trivy == "" {
+		return fmt.Errorf("trivy not installed. Please install trivy with these instructions: " +
+			"https://aquasecurity.github.io/trivy/latest/getting-started/installation/ or rerun with --auto-install")
+	}
+	client, err := o.client(ctx, c, streams, args[0])
+	if err != nil {
+		return err
+	}
+
+	vulns, err := c.Scan(ctx, client, args[1], registry.ScanOptions{Trivy: trivy, Severities: severities})
+	if err != nil {
+		return err
+	}
+	printScan(streams.Out, args[1], vulns)
+	if len(vulns) > 0 && o.ExitCode != 0 {
+		return exitCodeError(o.ExitCode)
+	}
+	return nil
+}
+
+func printScan(w io.Writer, image string, vulns []registry.Vulnerability) {
+	if len(vulns) == 0 {
+		_, _ = fmt.Fprintf(w, "No vulnerabilities found in %s\n", image)
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SEVERITY\tID\tPACKAGE\tINSTALLED\tFIXED\tTITLE")
+	for _, v := range vulns {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			v.Severity, v.ID, v.Package, v.InstalledVersion, v.FixedVersion, v.Title)
+	}
+	_ = tw.Flush()
+}
+
+// Exits with the given code, without printing an error.
+type exitCodeError int
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("exit code %d", int(e))
+}
+
+func formatLastPushed(t *time.Time, now time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return fmt.Sprintf("%s ago (%s)", units.HumanDuration(now.Sub(*t)), t.UTC().Format(time.RFC3339))
+}
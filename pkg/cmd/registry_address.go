@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+type registryAddressOptions struct {
+	Internal bool
+}
+
+func NewRegistryAddressCommand() *cobra.Command {
+	o := &registryAddressOptions{}
+	cmd := &cobra.Command{
+		Use:   "registry-address [cluster]",
+		Short: "Print the address of the registry attached to a cluster",
+		Long: "Print the address of the registry attached to a cluster, as advertised in its " +
+			"local-registry-hosting ConfigMap.\n\n" +
+			"By default, prints the address to push to from the host (e.g., localhost:5000). " +
+			"With --internal, prints the address that the cluster pulls from (e.g., kind-registry:5000).",
+		Example: "  ctlptl registry-address kind-kind\n" +
+			"  docker push $(ctlptl registry-address kind-kind)/my-app\n" +
+			"  ctlptl registry-address kind-kind --internal",
+		Run:  withClusterController("registry-address", o.run),
+		Args: cobra.ExactArgs(1),
+	}
+	cmd.Flags().BoolVar(&o.Internal, "internal", o.Internal,
+		"Print the address that the cluster's container runtime pulls from, instead of the address on the host")
+	return cmd
+}
+
+func (o *registryAddressOptions) run(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	host, internalHost, err := c.GetRegistryAddress(ctx, cl.Name)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("cluster %s has no registry. Set registry in the cluster config to attach one", cl.Name)
+	}
+
+	// Per KEP-1755, the container runtime uses the host address
+	// unless the cluster advertises a different one.
+	if o.Internal && internalHost != "" {
+		host = internalHost
+	}
+	_, _ = fmt.Fprintln(streams.Out, host)
+	return nil
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/clusterid"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/registry"
+)
+
+type registryTestOptions struct {
+	Cluster string
+	Timeout time.Duration
+}
+
+// The result of one leg of the round trip.
+type registryTestLeg struct {
+	Name     string
+	Image    string
+	Duration time.Duration
+	Err      error
+
+	// How to fix the failure, if we can tell.
+	Fix string
+}
+
+func (o *registryTestOptions) run(ctx context.Context, c *registry.Controller, streams genericclioptions.IOStreams, args []string) error {
+	name := args[0]
+	cc, err := newClusterController(streams)
+	if err != nil {
+		return err
+	}
+
+	var cl *api.Cluster
+	if o.Cluster != "" {
+		cl, err = normalizedGet(ctx, cc, o.Cluster)
+	} else {
+		cl, err = cc.Current(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	host, internalHost, err := cc.GetRegistryAddress(ctx, cl.Name)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("cluster %s has no registry. Set 'registry: %s' in the cluster config, and run 'ctlptl apply'", cl.Name, name)
+	}
+	if cl.Registry != "" && cl.Registry != name {
+		_, _ = fmt.Fprintf(streams.ErrOut, "Warning: cluster %s is connected to registry %s, not %s\n", cl.Name, cl.Registry, name)
+	}
+
+	start := time.Now()
+	pushed, err := c.PushTestImage(ctx, name)
+	push := registryTestLeg{Name: "host push", Image: pushed, Duration: time.Since(start), Err: err}
+	if err != nil {
+		push.Fix = registryPushFix(err, name)
+		printRegistryTestLeg(streams.Out, push)
+		return fmt.Errorf("registry %s failed the round trip", name)
+	}
+	printRegistryTestLeg(streams.Out, push)
+
+	// Per KEP-1755, the container runtime uses the host address
+	// unless the cluster advertises a different one.
+	pullHost := host
+	if internalHost != "" {
+		pullHost = internalHost
+	}
+	image := fmt.Sprintf("%s/%s:latest", pullHost, registry.TestImageRepository)
+
+	start = time.Now()
+	err = cc.CheckImagePull(ctx, cl.Name, image, o.Timeout)
+	pull := registryTestLeg{Name: "cluster pull", Image: image, Duration: time.Since(start), Err: err}
+	if err != nil {
+		pull.Fix = registryPullFix(err, name, cl, pullHost)
+	}
+	printRegistryTestLeg(streams.Out, pull)
+	if err != nil {
+		return fmt.Errorf("registry %s failed the round trip", name)
+	}
+	return nil
+}
+
+func printRegistryTestLeg(w io.Writer, leg registryTestLeg) {
+	d := leg.Duration.Round(100 * time.Millisecond)
+	if leg.Err == nil {
+		_, _ = fmt.Fprintf(w, "%-13s OK      %s (%s)\n", leg.Name+":", leg.Image, d)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "%-13s FAILED  (%s) %v\n", leg.Name+":", d, leg.Err)
+	if leg.Fix != "" {
+		_, _ = fmt.Fprintf(w, "  Fix: %s\n", leg.Fix)
+	}
+}
+
+// Suggests a fix for a failed push from the host.
+func registryPushFix(err error, name string) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "HTTP response to HTTPS client"):
+		return "Docker expects the registry to use HTTPS. " +
+			"Add the registry's address to insecure-registries in Docker's daemon.json, and restart Docker"
+	case strings.Contains(msg, "unauthorized") || strings.Contains(msg, "no basic auth credentials"):
+		return fmt.Sprintf("Docker isn't logged in to the registry. Run 'ctlptl registry rotate-auth %s'", name)
+	case strings.Contains(msg, "connection refused") || strings.Contains(msg, "connection reset"):
+		return fmt.Sprintf("Nothing is answering on the registry's port. Check 'ctlptl get registry %s'", name)
+	}
+	return ""
+}
+
+// Suggests a fix for a failed pull from inside the cluster.
+func registryPullFix(err error, name string, cl *api.Cluster, pullHost string) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "HTTP response to HTTPS client"):
+		return fmt.Sprintf("The cluster's container runtime expects %s to use HTTPS. "+
+			"Add /etc/containerd/certs.d/%s/hosts.toml on each node, pointing at http://%s. "+
+			"Clusters that ctlptl creates with a registry are set up this way", pullHost, pullHost, pullHost)
+	case strings.Contains(msg, "no such host") || strings.Contains(msg, "i/o timeout") ||
+		strings.Contains(msg, "connection refused") || strings.Contains(msg, "network is unreachable"):
+		network := "NETWORK"
+		if cl.Product == string(clusterid.ProductKIND) {
+			network = "kind"
+		}
+		return fmt.Sprintf("The cluster's nodes can't reach the registry. "+
+			"Connect it to the cluster's network with 'docker network connect %s %s'", network, name)
+	case strings.Contains(msg, "not found") || strings.Contains(msg, "manifest unknown"):
+		return fmt.Sprintf("The cluster pulls from %s, which isn't the registry that we pushed to. "+
+			"Run 'ctlptl repair-registry-config %s'", pullHost, cl.Name)
+	case strings.Contains(msg, "timed out"):
+		return "Check the events in the default namespace with 'kubectl get events'"
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/registry"
+)
+
+func TestFormatLastPushed(t *testing.T) {
+	now := time.Date(2022, 1, 1, 15, 0, 0, 0, time.UTC)
+	pushed := now.Add(-3 * time.Hour)
+	assert.Equal(t, "3 hours ago (2022-01-01T12:00:00Z)", formatLastPushed(&pushed, now))
+	assert.Equal(t, "never", formatLastPushed(nil, now))
+}
+
+func TestPrintGarbageCollect(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	printGarbageCollect(out, "ctlptl-registry", &registry.GarbageCollectResult{
+		DryRun:      true,
+		BlobsMarked: 4,
+		Blobs: []registry.GarbageCollectBlob{
+			{Digest: "sha256:fd61a03a", Size: 1536},
+			{Digest: "sha256:a4e624d6", Size: 3 * 1024 * 1024},
+		},
+		ReclaimableBytes: 1536 + 3*1024*1024,
+	})
+	assert.Equal(t, `Would delete 2 blobs from registry ctlptl-registry, reclaiming 3.001MiB (4 blobs in use)
+  sha256:fd61a03a  1.5KiB
+  sha256:a4e624d6  3MiB
+`, out.String())
+}
+
+func TestPrintPush(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	printPush(out, "ctlptl-registry", "dev/*", &registry.PushResult{
+		Images: []registry.PushedImage{
+			{Source: "dev/backend:v2", Target: "localhost:5001/dev/backend:v2"},
+		},
+	})
+	assert.Equal(t, `Pushed 1 images to registry ctlptl-registry
+  dev/backend:v2 -> localhost:5001/dev/backend:v2
+`, out.String())
+
+	out.Reset()
+	printPush(out, "ctlptl-registry", "dev/*", &registry.PushResult{DryRun: true})
+	assert.Equal(t, "No local images match dev/*\n", out.String())
+}
+
+func TestPrintScan(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	printScan(out, "my-app:latest", []registry.Vulnerability{
+		{ID: "CVE-2022-40674", Package: "expat", InstalledVersion: "2.4.8-r0", FixedVersion: "2.4.9-r0",
+			Severity: "CRITICAL", Title: "use-after-free in doContent"},
+		{ID: "CVE-2023-0286", Package: "libcrypto1.1", InstalledVersion: "1.1.1q-r0",
+			Severity: "HIGH", Title: "X.400 address type confusion"},
+	})
+	assert.Equal(t, `SEVERITY   ID               PACKAGE        INSTALLED   FIXED      TITLE
+CRITICAL   CVE-2022-40674   expat          2.4.8-r0    2.4.9-r0   use-after-free in doContent
+HIGH       CVE-2023-0286    libcrypto1.1   1.1.1q-r0              X.400 address type confusion
+`, out.String())
+
+	out.Reset()
+	printScan(out, "my-app:latest", nil)
+	assert.Equal(t, "No vulnerabilities found in my-app:latest\n", out.String())
+}
+
+func TestPrintRegistryTestLeg(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	printRegistryTestLeg(out, registryTestLeg{
+		Name:     "host push",
+		Image:    "localhost:5001/ctlptl-registry-test:latest",
+		Duration: 1234 * time.Millisecond,
+	})
+	printRegistryTestLeg(out, registryTestLeg{
+		Name:     "cluster pull",
+		Duration: 2 * time.Second,
+		Err:      fmt.Errorf("cluster kind-kind: pulling kind-registry:5000/ctlptl-registry-test:latest: ErrImagePull: dial tcp: lookup kind-registry: no such host"),
+		Fix:      "Connect it",
+	})
+	assert.Equal(t, `host push:    OK      localhost:5001/ctlptl-registry-test:latest (1.2s)
+cluster pull: FAILED  (2s) cluster kind-kind: pulling kind-registry:5000/ctlptl-registry-test:latest: ErrImagePull: dial tcp: lookup kind-registry: no such host
+  Fix: Connect it
+`, out.String())
+}
+
+func TestRegistryPushFix(t *testing.T) {
+	assert.Contains(t,
+		registryPushFix(fmt.Errorf("http: server gave HTTP response to HTTPS client"), "ctlptl-registry"),
+		"insecure-registries")
+	assert.Contains(t,
+		registryPushFix(fmt.Errorf("unauthorized: authentication required"), "ctlptl-registry"),
+		"ctlptl registry rotate-auth ctlptl-registry")
+	assert.Equal(t, "", registryPushFix(fmt.Errorf("disk full"), "ctlptl-registry"))
+}
+
+func TestRegistryPullFix(t *testing.T) {
+	kind := &api.Cluster{Name: "kind-kind", Product: "kind"}
+	assert.Contains(t,
+		registryPullFix(fmt.Errorf("http: server gave HTTP response to HTTPS client"), "ctlptl-registry", kind, "localhost:5001"),
+		"/etc/containerd/certs.d/localhost:5001/hosts.toml")
+	assert.Contains(t,
+		registryPullFix(fmt.Errorf("lookup ctlptl-registry: no such host"), "ctlptl-registry", kind, "ctlptl-registry:5000"),
+		"docker network connect kind ctlptl-registry")
+	assert.Contains(t,
+		registryPullFix(fmt.Errorf("manifest unknown"), "ctlptl-registry", kind, "localhost:5002"),
+		"ctlptl repair-registry-config kind-kind")
+}
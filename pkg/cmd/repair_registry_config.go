@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+func NewRepairRegistryConfigCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "repair-registry-config [cluster]",
+		Short: "Check that a cluster's local-registry-hosting ConfigMap points at its registry, and fix it if not",
+		Long: "Recomputes the local-registry-hosting ConfigMap from the cluster's live registry.\n\n" +
+			"If the registry has been re-created (e.g., on a new port) since the cluster was created,\n" +
+			"the ConfigMap goes stale. This command updates it. 'ctlptl apply' runs the same check.",
+		Example: "  ctlptl repair-registry-config kind-kind",
+		Run:     withClusterController("repair-registry-config", repairRegistryConfig),
+		Args:    cobra.ExactArgs(1),
+	}
+}
+
+func repairRegistryConfig(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	repaired, err := c.RepairRegistryHosting(ctx, cl.Name)
+	if err != nil {
+		return err
+	}
+	if repaired {
+		_, _ = fmt.Fprintf(streams.Out, "cluster %s: registry config repaired\n", cl.Name)
+	} else {
+		_, _ = fmt.Fprintf(streams.Out, "cluster %s: registry config up to date\n", cl.Name)
+	}
+	return nil
+}
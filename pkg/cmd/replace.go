@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/internal/audit"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/registry"
+	"github.com/tilt-dev/ctlptl/pkg/reporter"
+	"github.com/tilt-dev/ctlptl/pkg/visitor"
+)
+
+type ReplaceOptions struct {
+	*genericclioptions.PrintFlags
+	*genericclioptions.FileNameFlags
+	genericclioptions.IOStreams
+
+	Filenames []string
+
+	registryReplacer registryReplacer
+}
+
+type registryReplacer interface {
+	Replace(ctx context.Context, desired *api.Registry) (*api.Registry, error)
+}
+
+func NewReplaceOptions() *ReplaceOptions {
+	o := &ReplaceOptions{
+		PrintFlags: genericclioptions.NewPrintFlags("replaced"),
+		IOStreams:  genericclioptions.IOStreams{Out: os.Stdout, ErrOut: os.Stderr, In: os.Stdin},
+	}
+	o.FileNameFlags = &genericclioptions.FileNameFlags{Filenames: &o.Filenames}
+	return o
+}
+
+func (o *ReplaceOptions) Command() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "replace -f FILENAME",
+		Short: "Re-create a registry container with a new config, keeping its images",
+		Long: "Re-create a registry container with a new config, keeping its images.\n\n" +
+			"Stops and removes the registry container, even if its config hasn't changed, and " +
+			"creates a new one that mounts the old storage volume. Fails without removing anything " +
+			"if the new config can't use the old volume (e.g., when switching between one and many replicas).\n\n" +
+			"Only registries can be replaced.",
+		Example: "  ctlptl replace -f registry.yaml",
+		Run:     o.Run,
+	}
+
+	cmd.SetOut(o.Out)
+	cmd.SetErr(o.ErrOut)
+	o.FileNameFlags.AddFlags(cmd.Flags())
+	o.PrintFlags.AddFlags(cmd)
+
+	return cmd
+}
+
+func (o *ReplaceOptions) Run(cmd *cobra.Command, args []string) {
+	if len(o.Filenames) == 0 {
+		_, _ = fmt.Fprintf(o.ErrOut, "Expected source files with -f\n")
+		os.Exit(1)
+	}
+
+	err := o.run()
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func (o *ReplaceOptions) run() error {
+	a, err := newAnalytics()
+	if err != nil {
+		return err
+	}
+	a.Incr("cmd.replace", nil)
+	defer a.Flush(time.Second)
+
+	ctx := context.TODO()
+
+	printer, err := toPrinter(o.PrintFlags)
+	if err != nil {
+		return err
+	}
+
+	visitors, err := visitor.FromStrings(o.Filenames, o.In)
+	if err != nil {
+		return err
+	}
+
+	objects, err := visitor.DecodeAll(visitors)
+	if err != nil {
+		return err
+	}
+
+	// Check everything before replacing anything.
+	for _, obj := range objects {
+		switch obj.(type) {
+		case *api.Registry:
+		case *api.Cluster:
+			return fmt.Errorf("cannot replace clusters. Use 'ctlptl delete' and 'ctlptl apply' to re-create a cluster")
+		default:
+			return fmt.Errorf("cannot replace: %T", obj)
+		}
+	}
+
+	for _, obj := range objects {
+		if o.registryReplacer == nil {
+			o.registryReplacer, err = registry.DefaultController(o.IOStreams)
+			if err != nil {
+				return err
+			}
+		}
+
+		desired := obj.(*api.Registry)
+		newObj, err := o.registryReplacer.Replace(ctx, desired)
+		auditErr := audit.Record("replace", "Registry", desired.Name, string(reporter.ActionUpdated), err)
+		if err != nil {
+			return err
+		}
+		if auditErr != nil {
+			return auditErr
+		}
+		err = printer.PrintObj(newObj, o.Out)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+type fakeRegistryReplacer struct {
+	replaced []string
+}
+
+func (r *fakeRegistryReplacer) Replace(ctx context.Context, desired *api.Registry) (*api.Registry, error) {
+	r.replaced = append(r.replaced, desired.Name)
+	return desired, nil
+}
+
+func TestReplaceRegistry(t *testing.T) {
+	streams, in, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewReplaceOptions()
+	o.IOStreams = streams
+
+	_, _ = in.Write([]byte(`apiVersion: ctlptl.dev/v1alpha1
+kind: Registry
+name: ctlptl-registry
+port: 5005
+`))
+
+	r := &fakeRegistryReplacer{}
+	o.registryReplacer = r
+	o.Filenames = []string{"-"}
+	err := o.run()
+	require.NoError(t, err)
+	assert.Equal(t, "registry.ctlptl.dev/ctlptl-registry replaced\n", out.String())
+	assert.Equal(t, []string{"ctlptl-registry"}, r.replaced)
+}
+
+func TestReplaceClusterFails(t *testing.T) {
+	streams, in, _, _ := genericclioptions.NewTestIOStreams()
+	o := NewReplaceOptions()
+	o.IOStreams = streams
+
+	_, _ = in.Write([]byte(`apiVersion: ctlptl.dev/v1alpha1
+kind: Registry
+name: ctlptl-registry
+---
+apiVersion: ctlptl.dev/v1alpha1
+kind: Cluster
+product: kind
+`))
+
+	r := &fakeRegistryReplacer{}
+	o.registryReplacer = r
+	o.Filenames = []string{"-"}
+	err := o.run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot replace clusters")
+	}
+	assert.Empty(t, r.replaced)
+}
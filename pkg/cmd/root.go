@@ -1,10 +1,31 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/spf13/cobra"
 	"github.com/tilt-dev/wmclient/pkg/analytics"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/internal/audit"
+	"github.com/tilt-dev/ctlptl/internal/egress"
+	"github.com/tilt-dev/ctlptl/internal/toolinstall"
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
 )
 
+// The cluster controller options from the global flags, the environment,
+// and the ctlptl config file. Set before each command runs.
+var clusterControllerOptions *cluster.ControllerOptions
+
+// Creates a cluster controller with the options from the global flags.
+func newClusterController(streams genericclioptions.IOStreams) (*cluster.Controller, error) {
+	if clusterControllerOptions == nil {
+		return cluster.DefaultController(streams)
+	}
+	return cluster.DefaultControllerWithOptions(streams, *clusterControllerOptions)
+}
+
 func NewRootCommand() *cobra.Command {
 	var rootCmd = &cobra.Command{
 		Use:   "ctlptl [command]",
@@ -13,11 +34,100 @@ func NewRootCommand() *cobra.Command {
 			"  ctlptl apply -f my-cluster.yaml",
 	}
 
+	var contextPrefix string
+	rootCmd.PersistentFlags().StringVar(&contextPrefix, "context-prefix", "",
+		fmt.Sprintf("A prefix for all kubeconfig contexts that ctlptl manages (e.g., 'ci-42-'). "+
+			"Overrides $%s and contextPrefix in ~/.ctlptl/config.yaml", cluster.ContextPrefixEnv))
+
+	var noNetworkEgress bool
+	rootCmd.PersistentFlags().BoolVar(&noNetworkEgress, "no-network-egress", false,
+		fmt.Sprintf("Fail instead of connecting to anything but Docker, clusters, loopback registries, and the mirrors in $%s. "+
+			"Also disables analytics. Same as $%s=true", egress.MirrorsEnvVar, egress.EnvVar))
+
+	var auditLog string
+	rootCmd.PersistentFlags().StringVar(&auditLog, "audit-log", "",
+		fmt.Sprintf("Append a JSON line to this file for each cluster and registry that apply, create, replace, or delete changes. "+
+			"Overrides $%s and auditLog in ~/.ctlptl/config.yaml", audit.EnvVar))
+
+	var autoInstall bool
+	rootCmd.PersistentFlags().BoolVar(&autoInstall, "auto-install", false,
+		fmt.Sprintf("Download kind or trivy to ~/.ctlptl/bin if they're missing or too old, and verify their checksums. Same as $%s=true", toolinstall.EnvVar))
+
+	var strictOwnership bool
+	rootCmd.PersistentFlags().BoolVar(&strictOwnership, "strict-ownership", false,
+		fmt.Sprintf("Fail instead of warning when applying a cluster that another --owner owns. "+
+			"Same as $%s=true, or strictOwnership: true in ~/.ctlptl/config.yaml", cluster.StrictOwnershipEnv))
+
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		options, err := cluster.DefaultControllerOptions()
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("context-prefix") {
+			options.ContextPrefix = contextPrefix
+		}
+		if strictOwnership {
+			options.StrictOwnership = true
+		}
+		if cmd.Flags().Changed("audit-log") {
+			options.AuditLog = auditLog
+		}
+		clusterControllerOptions = &options
+
+		// The audit log is written by commands that don't use the cluster
+		// controller, so pass it on in the environment.
+		err = os.Setenv(audit.EnvVar, options.AuditLog)
+		if err != nil {
+			return err
+		}
+		if autoInstall {
+			err := os.Setenv(toolinstall.EnvVar, "true")
+			if err != nil {
+				return err
+			}
+		}
+		if noNetworkEgress {
+			return os.Setenv(egress.EnvVar, "true")
+		}
+		return nil
+	}
+
 	rootCmd.AddCommand(NewCreateOptions().Command())
 	rootCmd.AddCommand(NewGetOptions().Command())
+	rootCmd.AddCommand(NewGetTokenCommand())
 	rootCmd.AddCommand(NewApplyOptions().Command())
+	rootCmd.AddCommand(NewBootstrapOptions().Command())
 	rootCmd.AddCommand(NewDeleteOptions().Command())
+	rootCmd.AddCommand(NewReplaceOptions().Command())
+	rootCmd.AddCommand(NewContainerIDOptions().Command())
+	rootCmd.AddCommand(NewClusterEndpointOptions().Command())
+	rootCmd.AddCommand(NewClusterDNSOptions().Command())
+	rootCmd.AddCommand(NewNodeIPOptions().Command())
+	rootCmd.AddCommand(NewLoadBalancerIPCommand())
+	rootCmd.AddCommand(NewRegistryAddressCommand())
+	rootCmd.AddCommand(NewServiceCIDRCommand())
+	rootCmd.AddCommand(NewSyncHostsCommand())
+	rootCmd.AddCommand(NewAgeCommand())
+	rootCmd.AddCommand(NewLoadImagesCommand())
+	rootCmd.AddCommand(NewKubeconfigCommand())
+	rootCmd.AddCommand(NewIngressIPCommand())
+	rootCmd.AddCommand(NewExplainCommand())
+	rootCmd.AddCommand(NewOpenAPIOptions().Command())
+	rootCmd.AddCommand(NewDFCommand())
+	rootCmd.AddCommand(NewBundleCommand())
+	rootCmd.AddCommand(NewCertCommand())
 	rootCmd.AddCommand(NewDockerDesktopCommand())
+	rootCmd.AddCommand(NewEtcdCommand())
+	rootCmd.AddCommand(NewHelmCommand())
+	rootCmd.AddCommand(NewHubbleCommand())
+	rootCmd.AddCommand(NewImagesCommand())
+	rootCmd.AddCommand(NewClusterImagesCommand())
+	rootCmd.AddCommand(NewNetworkCommand())
+	rootCmd.AddCommand(NewRegistryCommand())
+	rootCmd.AddCommand(NewRepairRegistryConfigCommand())
+	rootCmd.AddCommand(NewSetRegistryCommand())
+	rootCmd.AddCommand(NewServeOptions().Command())
+	rootCmd.AddCommand(NewUseCommand())
 	rootCmd.AddCommand(newDocsCommand(rootCmd))
 	rootCmd.AddCommand(analytics.NewCommand())
 	rootCmd.AddCommand(NewSocatCommand())
@@ -0,0 +1,367 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+	"github.com/tilt-dev/ctlptl/pkg/encoding"
+	"github.com/tilt-dev/ctlptl/pkg/registry"
+)
+
+// The environment variable that holds the API token for 'ctlptl serve'.
+const ServeTokenEnv = "CTLPTL_SERVE_TOKEN"
+
+// The largest request body we accept. Cluster configs are small.
+const maxServeRequestBytes = 1 << 20
+
+type ServeOptions struct {
+	genericclioptions.IOStreams
+
+	Address   string
+	TokenFile string
+}
+
+func NewServeOptions() *ServeOptions {
+	return &ServeOptions{
+		IOStreams: genericclioptions.IOStreams{Out: os.Stdout, ErrOut: os.Stderr, In: os.Stdin},
+		Address:   "localhost:8484",
+	}
+}
+
+func (o *ServeOptions) Command() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Serve an HTTP API for creating and deleting clusters and registries",
+		Long: "Serves a small JSON API backed by the same controllers as 'ctlptl apply', " +
+			"'ctlptl get', and 'ctlptl delete':\n\n" +
+			"  GET    /clusters            List clusters\n" +
+			"  POST   /clusters            Apply a cluster config (JSON or YAML)\n" +
+			"  GET    /clusters/{name}     Get a cluster\n" +
+			"  DELETE /clusters/{name}     Delete a cluster\n\n" +
+			"The same routes exist under /registries. " +
+			"Every request except GET /healthz must send 'Authorization: Bearer <token>', " +
+			fmt.Sprintf("where the token is read from --token-file or $%s.", ServeTokenEnv),
+		Example: "  CTLPTL_SERVE_TOKEN=s3cret ctlptl serve\n" +
+			"  ctlptl serve --address 0.0.0.0:8484 --token-file /etc/ctlptl/token",
+		Run:  o.Run,
+		Args: cobra.NoArgs,
+	}
+
+	cmd.SetOut(o.Out)
+	cmd.SetErr(o.ErrOut)
+	cmd.Flags().StringVar(&o.Address, "address", o.Address, "The host:port to listen on")
+	cmd.Flags().StringVar(&o.TokenFile, "token-file", o.TokenFile,
+		fmt.Sprintf("A file containing the API token. Overrides $%s", ServeTokenEnv))
+
+	return cmd
+}
+
+func (o *ServeOptions) Run(cmd *cobra.Command, args []string) {
+	err := o.run()
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func (o *ServeOptions) run() error {
+	a, err := newAnalytics()
+	if err != nil {
+		return err
+	}
+	a.Incr("cmd.serve", nil)
+	defer a.Flush(time.Second)
+
+	token, err := o.token()
+	if err != nil {
+		return err
+	}
+
+	cc, err := newClusterController(o.IOStreams)
+	if err != nil {
+		return err
+	}
+	rc, err := registry.DefaultController(o.IOStreams)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	server := &http.Server{
+		Addr:              o.Address,
+		Handler:           newServeHandler(token, cc, rc),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(_ net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	_, _ = fmt.Fprintf(o.ErrOut, "Serving ctlptl API on http://%s\n", o.Address)
+	err = server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Reads the API token. We refuse to serve without one, because
+// the API can create and delete anything ctlptl manages.
+func (o *ServeOptions) token() (string, error) {
+	token := os.Getenv(ServeTokenEnv)
+	if o.TokenFile != "" {
+		contents, err := os.ReadFile(o.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("reading --token-file: %v", err)
+		}
+		token = string(contents)
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("ctlptl serve requires an API token. Set $%s or --token-file", ServeTokenEnv)
+	}
+	return token, nil
+}
+
+type serveClusterController interface {
+	clusterController
+	Apply(ctx context.Context, desired *api.Cluster) (*api.Cluster, error)
+}
+
+type serveRegistryController interface {
+	deleter
+	registryLister
+	Get(ctx context.Context, name string) (*api.Registry, error)
+	Apply(ctx context.Context, desired *api.Registry) (*api.Registry, error)
+}
+
+type serveHandler struct {
+	token    string
+	clusters serveClusterController
+	registry serveRegistryController
+
+	// The controllers print progress and shell out to cluster tools,
+	// so we only let one request change things at a time.
+	mu sync.Mutex
+}
+
+func newServeHandler(token string, clusters serveClusterController, registries serveRegistryController) http.Handler {
+	h := &serveHandler{token: token, clusters: clusters, registry: registries}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.Handle("/clusters", h.authorized(h.serveClusters))
+	mux.Handle("/clusters/", h.authorized(h.serveCluster))
+	mux.Handle("/registries", h.authorized(h.serveRegistries))
+	mux.Handle("/registries/", h.authorized(h.serveRegistry))
+	return mux
+}
+
+func (h *serveHandler) authorized(f http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(header, "Bearer ")
+		if token == header || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid bearer token"))
+			return
+		}
+		f(w, r)
+	})
+}
+
+func (h *serveHandler) serveClusters(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := h.clusters.List(r.Context(), cluster.ListOptions{FieldSelector: r.URL.Query().Get("fieldSelector")})
+		writeResult(w, http.StatusOK, list, err)
+
+	case http.MethodPost:
+		obj, err := decodeServeRequest(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		desired, ok := obj.(*api.Cluster)
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("expected a Cluster. Actual: %T", obj))
+			return
+		}
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		result, err := h.clusters.Apply(r.Context(), desired)
+		writeResult(w, http.StatusOK, result, err)
+
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (h *serveHandler) serveCluster(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/clusters/")
+	if name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found: %s", r.URL.Path))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		result, err := normalizedGet(r.Context(), h.clusters, name)
+		writeResult(w, http.StatusOK, result, err)
+
+	case http.MethodDelete:
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		// Normalize the name, so that DELETE /clusters/kind works
+		// like 'ctlptl delete cluster kind'.
+		existing, err := normalizedGet(r.Context(), h.clusters, name)
+		if err != nil {
+			writeResult(w, http.StatusOK, nil, err)
+			return
+		}
+		err = h.clusters.Delete(r.Context(), existing.Name)
+		existing.TypeMeta = cluster.TypeMeta()
+		writeResult(w, http.StatusOK, existing, err)
+
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodDelete)
+	}
+}
+
+func (h *serveHandler) serveRegistries(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := h.registry.List(r.Context(), registry.ListOptions{FieldSelector: r.URL.Query().Get("fieldSelector")})
+		writeResult(w, http.StatusOK, list, err)
+
+	case http.MethodPost:
+		obj, err := decodeServeRequest(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		desired, ok := obj.(*api.Registry)
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("expected a Registry. Actual: %T", obj))
+			return
+		}
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		result, err := h.registry.Apply(r.Context(), desired)
+		writeResult(w, http.StatusOK, result, err)
+
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (h *serveHandler) serveRegistry(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/registries/")
+	if name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found: %s", r.URL.Path))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		result, err := h.registry.Get(r.Context(), name)
+		writeResult(w, http.StatusOK, result, err)
+
+	case http.MethodDelete:
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		existing, err := h.registry.Get(r.Context(), name)
+		if err != nil {
+			writeResult(w, http.StatusOK, nil, err)
+			return
+		}
+		err = h.registry.Delete(r.Context(), name)
+		existing.TypeMeta = registry.TypeMeta()
+		writeResult(w, http.StatusOK, existing, err)
+
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodDelete)
+	}
+}
+
+// Decodes a single ctlptl object from the request body.
+//
+// JSON is a subset of YAML, so this accepts either.
+func decodeServeRequest(r *http.Request) (runtime.Object, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxServeRequestBytes))
+	if err != nil {
+		return nil, fmt.Errorf("reading request: %v", err)
+	}
+	objs, err := encoding.ParseStream(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	objs = encoding.FlattenLists(objs)
+	if len(objs) != 1 {
+		return nil, fmt.Errorf("expected exactly one object in the request. Actual: %d", len(objs))
+	}
+	return objs[0], nil
+}
+
+func writeResult(w http.ResponseWriter, status int, obj interface{}, err error) {
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, status, obj)
+}
+
+// Maps controller errors onto HTTP status codes.
+func errorStatus(err error) int {
+	switch {
+	case errors.IsNotFound(err):
+		return http.StatusNotFound
+	case errors.IsAlreadyExists(err), errors.IsConflict(err):
+		return http.StatusConflict
+	case errors.IsBadRequest(err), errors.IsInvalid(err):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed. Allowed: %s", strings.Join(allowed, ", ")))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(obj)
+}
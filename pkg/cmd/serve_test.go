@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+type fakeServeRegistryController struct {
+	*fakeRegistryController
+	fakeRegistryLister
+	*fakeDeleter
+}
+
+type serveFixture struct {
+	t        *testing.T
+	clusters *fakeClusterController
+	handler  http.Handler
+}
+
+func newServeFixture(t *testing.T) *serveFixture {
+	clusters := &fakeClusterController{}
+	registries := fakeServeRegistryController{
+		fakeRegistryController: &fakeRegistryController{},
+		fakeRegistryLister:     fakeRegistryLister{items: []api.Registry{{Name: "ctlptl-registry"}}},
+		fakeDeleter:            &fakeDeleter{},
+	}
+	return &serveFixture{
+		t:        t,
+		clusters: clusters,
+		handler:  newServeHandler("s3cret", clusters, registries),
+	}
+}
+
+func (f *serveFixture) do(method, path, body string) (int, map[string]interface{}) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w := httptest.NewRecorder()
+	f.handler.ServeHTTP(w, req)
+	assert.Equal(f.t, "application/json", w.Header().Get("Content-Type"))
+
+	result := map[string]interface{}{}
+	require.NoError(f.t, json.Unmarshal(w.Body.Bytes(), &result))
+	return w.Code, result
+}
+
+func TestServeRequiresToken(t *testing.T) {
+	f := newServeFixture(t)
+
+	for _, header := range []string{"", "s3cret", "Bearer wrong"} {
+		req := httptest.NewRequest("GET", "/clusters", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		f.handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "header %q", header)
+		assert.Contains(t, w.Body.String(), "missing or invalid bearer token")
+	}
+
+	w := httptest.NewRecorder()
+	f.handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestServeClusterLifecycle(t *testing.T) {
+	f := newServeFixture(t)
+
+	code, body := f.do("POST", "/clusters", `{"apiVersion": "ctlptl.dev/v1alpha1", "kind": "Cluster", "name": "kind-kind", "product": "kind"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "kind-kind", body["name"])
+	assert.Equal(t, "kind-kind", f.clusters.lastApplyName)
+
+	code, body = f.do("GET", "/clusters", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, body["items"], 1)
+
+	// Names are normalized, like 'ctlptl delete cluster kind'.
+	code, body = f.do("GET", "/clusters/kind", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "kind-kind", body["name"])
+
+	code, body = f.do("DELETE", "/clusters/kind", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Cluster", body["kind"])
+	assert.Equal(t, "kind-kind", f.clusters.lastDeleteName)
+
+	code, body = f.do("GET", "/clusters/kind-kind", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Contains(t, body["error"], `"kind-kind" not found`)
+}
+
+func TestServeApplyYAML(t *testing.T) {
+	f := newServeFixture(t)
+
+	code, _ := f.do("POST", "/clusters", `apiVersion: ctlptl.dev/v1alpha1
+kind: Cluster
+name: kind-dev
+product: kind
+`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "kind-dev", f.clusters.lastApplyName)
+}
+
+func TestServeBadRequests(t *testing.T) {
+	f := newServeFixture(t)
+
+	code, body := f.do("POST", "/clusters", `{"apiVersion": "ctlptl.dev/v1alpha1", "kind": "Registry", "name": "ctlptl-registry"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "expected a Cluster. Actual: *api.Registry", body["error"])
+
+	code, body = f.do("POST", "/clusters", `{"apiVersion": "ctlptl.dev/v1alpha1", "kind": "Cluster", "prodcut": "kind"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body["error"], "field prodcut not found")
+
+	code, _ = f.do("PUT", "/clusters/kind-kind", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	assert.Equal(t, "", f.clusters.lastApplyName)
+}
+
+func TestServeRegistries(t *testing.T) {
+	f := newServeFixture(t)
+
+	code, body := f.do("GET", "/registries", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, body["items"], 1)
+
+	code, body = f.do("POST", "/registries", `{"apiVersion": "ctlptl.dev/v1alpha1", "kind": "Registry", "name": "my-registry"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "my-registry", body["name"])
+
+	code, _ = f.do("DELETE", "/registries/missing", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
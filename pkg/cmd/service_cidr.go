@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+func NewServiceCIDRCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "service-cidr [cluster]",
+		Short: "Print the range of IPs that a cluster assigns to services",
+		Long: "Print the range of IPs that a cluster assigns to services, from the kube-apiserver's " +
+			"--service-cluster-ip-range flag.\n\n" +
+			"Only works on clusters that run the kube-apiserver in a pod, like kind and minikube. " +
+			"Dual-stack clusters print their first range.",
+		Example: "  ctlptl service-cidr kind-kind\n" +
+			"  sudo ip route add $(ctlptl service-cidr kind-kind) via $(ctlptl node-ip kind-kind)",
+		Run:  withClusterController("service-cidr", serviceCIDR),
+		Args: cobra.ExactArgs(1),
+	}
+}
+
+func serviceCIDR(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	cidr, err := c.GetServiceCIDR(ctx, cl.Name)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(streams.Out, cidr.String())
+	return nil
+}
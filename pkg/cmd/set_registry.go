@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+func NewSetRegistryCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set-registry [cluster] [registry]",
+		Short: "Switch a running cluster to a different registry, without re-creating it",
+		Long: "Reconfigures the container runtime mirrors on each node, moves the cluster network\n" +
+			"from the old registry to the new one, and updates the local-registry-hosting ConfigMap.\n\n" +
+			"The registry must already exist. Only supported on products that can reconfigure\n" +
+			"a live cluster (currently minikube v1.26+). kind clusters must be re-created.",
+		Example: "  ctlptl set-registry minikube shared-cache",
+		Run:     withClusterController("set-registry", setRegistry),
+		Args:    cobra.ExactArgs(2),
+	}
+}
+
+func setRegistry(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	_, err = c.SetRegistry(ctx, cl.Name, args[1])
+	return err
+}
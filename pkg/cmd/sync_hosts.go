@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+type syncHostsOptions struct {
+	Zone     string
+	Watch    bool
+	Interval time.Duration
+}
+
+func NewSyncHostsCommand() *cobra.Command {
+	o := &syncHostsOptions{Interval: 30 * time.Second}
+	cmd := &cobra.Command{
+		Use:   "sync-hosts [cluster] --zone=ZONE",
+		Short: "Point the hostnames of a cluster's Ingresses at localhost in /etc/hosts",
+		Long: "Point the hostnames of a cluster's Ingresses at localhost in /etc/hosts.\n\n" +
+			"Adds an entry for each Ingress hostname that ends in the zone, and removes the entries " +
+			"of hostnames that no Ingress uses any more. With --watch, syncs again every --interval until interrupted.\n\n" +
+			"Writing /etc/hosts usually requires sudo. Without permission, prints the lines to add instead.",
+		Example: "  sudo ctlptl sync-hosts kind-kind --zone=.local\n" +
+			"  sudo ctlptl sync-hosts kind-kind --zone=.local --watch",
+		Run:  withClusterController("sync-hosts", o.run),
+		Args: cobra.ExactArgs(1),
+	}
+	cmd.Flags().StringVar(&o.Zone, "zone", "", "The domain of the hostnames to sync (e.g., .local)")
+	cmd.Flags().BoolVar(&o.Watch, "watch", false, "Keep syncing until interrupted")
+	cmd.Flags().DurationVar(&o.Interval, "interval", o.Interval, "How often to sync with --watch")
+	_ = cmd.MarkFlagRequired("zone")
+	return cmd
+}
+
+func (o *syncHostsOptions) run(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	if o.Interval <= 0 {
+		return fmt.Errorf("--interval must be positive. Actual: %s", o.Interval)
+	}
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	err = c.SyncHosts(ctx, cl.Name, o.Zone)
+	if err != nil || !o.Watch {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	_, _ = fmt.Fprintf(streams.ErrOut, "Syncing hosts of cluster %s every %s. Press Ctrl-C to stop.\n", cl.Name, o.Interval)
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		// Keep watching through errors, e.g., while the cluster restarts.
+		err := c.SyncHosts(ctx, cl.Name, o.Zone)
+		if err != nil && ctx.Err() == nil {
+			_, _ = fmt.Fprintf(streams.ErrOut, "Syncing hosts: %v\n", err)
+		}
+	}
+}
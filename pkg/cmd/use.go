@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/cluster"
+)
+
+func NewUseCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "use [cluster]",
+		Short: "Switch the kubectl context to a cluster",
+		Long: "Switch the current kubectl context to a cluster.\n\n" +
+			"Works the same for clusters that ctlptl created, and for external clusters\n" +
+			"(existing kubeconfig contexts applied with product: external).",
+		Example: "  ctlptl use kind-kind\n" +
+			"  ctlptl use remote-dev",
+		Run:  withClusterController("use", useCluster),
+		Args: cobra.ExactArgs(1),
+	}
+}
+
+func useCluster(ctx context.Context, c *cluster.Controller, streams genericclioptions.IOStreams, args []string) error {
+	cl, err := normalizedGet(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Use(ctx, cl.Name)
+	return err
+}
@@ -0,0 +1,111 @@
+// Package cost estimates what a local cluster's nodes would cost to run
+// in a cloud provider.
+package cost
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	ProviderAWS   = "aws"
+	ProviderGCP   = "gcp"
+	ProviderAzure = "azure"
+)
+
+// Cloud providers bill by the hour. This is the average month.
+const HoursPerMonth = 730
+
+// NodeSize is the capacity of a node.
+type NodeSize struct {
+	Name      string
+	CPUs      float64
+	MemoryGiB float64
+}
+
+// NodeEstimate is the instance type that fits a node, and what it costs.
+type NodeEstimate struct {
+	Node         string
+	InstanceType InstanceType
+
+	// Nodes bigger than the largest instance type count as several instances.
+	Count       int
+	MonthlyCost float64
+}
+
+// Estimate is the cost of every node in a cluster.
+type Estimate struct {
+	Provider    string
+	Nodes       []NodeEstimate
+	MonthlyCost float64
+}
+
+// Providers returns the supported cloud providers, sorted.
+func Providers() []string {
+	result := make([]string, 0, len(Pricing))
+	for p := range Pricing {
+		result = append(result, p)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ValidateProvider checks that we have prices for the provider.
+func ValidateProvider(provider string) error {
+	if _, ok := Pricing[provider]; !ok {
+		return fmt.Errorf("cloud provider must be one of: %s. Actual: %s",
+			strings.Join(Providers(), ", "), provider)
+	}
+	return nil
+}
+
+// FitInstance returns the cheapest instance type with at least the given
+// CPUs and memory, and how many of them the node needs.
+//
+// Only nodes bigger than the largest instance type need more than one.
+func FitInstance(provider string, cpus, memoryGiB float64) (InstanceType, int, error) {
+	err := ValidateProvider(provider)
+	if err != nil {
+		return InstanceType{}, 0, err
+	}
+
+	types := Pricing[provider]
+	var best *InstanceType
+	for i, t := range types {
+		if t.CPUs < cpus || t.MemoryGiB < memoryGiB {
+			continue
+		}
+		if best == nil || t.HourlyPrice < best.HourlyPrice {
+			best = &types[i]
+		}
+	}
+	if best != nil {
+		return *best, 1, nil
+	}
+
+	largest := types[len(types)-1]
+	count := int(math.Ceil(math.Max(cpus/largest.CPUs, memoryGiB/largest.MemoryGiB)))
+	return largest, count, nil
+}
+
+// EstimateNodes prices each node as the cheapest instance type that fits it.
+func EstimateNodes(provider string, nodes []NodeSize) (*Estimate, error) {
+	result := &Estimate{Provider: provider}
+	for _, node := range nodes {
+		t, count, err := FitInstance(provider, node.CPUs, node.MemoryGiB)
+		if err != nil {
+			return nil, err
+		}
+		monthly := t.HourlyPrice * float64(count) * HoursPerMonth
+		result.Nodes = append(result.Nodes, NodeEstimate{
+			Node:         node.Name,
+			InstanceType: t,
+			Count:        count,
+			MonthlyCost:  monthly,
+		})
+		result.MonthlyCost += monthly
+	}
+	return result, nil
+}
@@ -0,0 +1,52 @@
+package cost
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFitInstance(t *testing.T) {
+	for _, tc := range []struct {
+		provider  string
+		cpus      float64
+		memoryGiB float64
+		expected  string
+		count     int
+	}{
+		{ProviderAWS, 2, 3.8, "t3.medium", 1},
+		{ProviderAWS, 2, 7.7, "m5.large", 1},
+		{ProviderAWS, 4, 15.6, "m5.xlarge", 1},
+		{ProviderAWS, 3, 4, "m5.xlarge", 1},
+		{ProviderGCP, 8, 31.3, "e2-standard-8", 1},
+		{ProviderAzure, 16, 62.8, "Standard_D16s_v5", 1},
+		{ProviderAWS, 64, 128, "m5.8xlarge", 2},
+		{ProviderGCP, 32, 300, "e2-standard-32", 3},
+	} {
+		it, count, err := FitInstance(tc.provider, tc.cpus, tc.memoryGiB)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, it.Name, "%s %v CPU %v GiB", tc.provider, tc.cpus, tc.memoryGiB)
+		assert.Equal(t, tc.count, count, "%s %v CPU %v GiB", tc.provider, tc.cpus, tc.memoryGiB)
+	}
+}
+
+func TestFitInstanceUnknownProvider(t *testing.T) {
+	_, _, err := FitInstance("ibm", 2, 4)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cloud provider must be one of: aws, azure, gcp. Actual: ibm")
+	}
+}
+
+func TestEstimateNodes(t *testing.T) {
+	estimate, err := EstimateNodes(ProviderAWS, []NodeSize{
+		{Name: "kind-control-plane", CPUs: 4, MemoryGiB: 15.6},
+		{Name: "kind-worker", CPUs: 2, MemoryGiB: 7.7},
+	})
+	require.NoError(t, err)
+	require.Len(t, estimate.Nodes, 2)
+	// m5.xlarge + m5.large = $0.288/hour.
+	assert.InDelta(t, 140.16, estimate.Nodes[0].MonthlyCost, 0.001)
+	assert.InDelta(t, 70.08, estimate.Nodes[1].MonthlyCost, 0.001)
+	assert.InDelta(t, 210.24, estimate.MonthlyCost, 0.001)
+}
@@ -0,0 +1,42 @@
+package cost
+
+// InstanceType is a cloud VM size with its on-demand Linux price.
+type InstanceType struct {
+	Name        string
+	CPUs        float64
+	MemoryGiB   float64
+	HourlyPrice float64
+}
+
+// Pricing lists general-purpose instance types for each cloud provider,
+// from smallest to largest.
+//
+// Prices are on-demand Linux prices in US dollars per hour, in us-east-1 (aws),
+// us-central1 (gcp), and East US (azure). They're only updated occasionally,
+// so treat estimates as a rough guide.
+var Pricing = map[string][]InstanceType{
+	ProviderAWS: {
+		{Name: "t3.medium", CPUs: 2, MemoryGiB: 4, HourlyPrice: 0.0416},
+		{Name: "m5.large", CPUs: 2, MemoryGiB: 8, HourlyPrice: 0.096},
+		{Name: "m5.xlarge", CPUs: 4, MemoryGiB: 16, HourlyPrice: 0.192},
+		{Name: "m5.2xlarge", CPUs: 8, MemoryGiB: 32, HourlyPrice: 0.384},
+		{Name: "m5.4xlarge", CPUs: 16, MemoryGiB: 64, HourlyPrice: 0.768},
+		{Name: "m5.8xlarge", CPUs: 32, MemoryGiB: 128, HourlyPrice: 1.536},
+	},
+	ProviderGCP: {
+		{Name: "e2-medium", CPUs: 2, MemoryGiB: 4, HourlyPrice: 0.033503},
+		{Name: "e2-standard-2", CPUs: 2, MemoryGiB: 8, HourlyPrice: 0.067006},
+		{Name: "e2-standard-4", CPUs: 4, MemoryGiB: 16, HourlyPrice: 0.134012},
+		{Name: "e2-standard-8", CPUs: 8, MemoryGiB: 32, HourlyPrice: 0.268024},
+		{Name: "e2-standard-16", CPUs: 16, MemoryGiB: 64, HourlyPrice: 0.536048},
+		{Name: "e2-standard-32", CPUs: 32, MemoryGiB: 128, HourlyPrice: 1.072096},
+	},
+	ProviderAzure: {
+		{Name: "Standard_B2s", CPUs: 2, MemoryGiB: 4, HourlyPrice: 0.0416},
+		{Name: "Standard_D2s_v5", CPUs: 2, MemoryGiB: 8, HourlyPrice: 0.096},
+		{Name: "Standard_D4s_v5", CPUs: 4, MemoryGiB: 16, HourlyPrice: 0.192},
+		{Name: "Standard_D8s_v5", CPUs: 8, MemoryGiB: 32, HourlyPrice: 0.384},
+		{Name: "Standard_D16s_v5", CPUs: 16, MemoryGiB: 64, HourlyPrice: 0.768},
+		{Name: "Standard_D32s_v5", CPUs: 32, MemoryGiB: 128, HourlyPrice: 1.536},
+	},
+}
@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+)
+
+// The Docker API calls needed to manage networks.
+type DockerClient interface {
+	NetworkInspect(ctx context.Context, networkID string, options types.NetworkInspectOptions) (types.NetworkResource, error)
+	NetworkCreate(ctx context.Context, name string, options types.NetworkCreate) (types.NetworkCreateResponse, error)
+}
+
+type NetworkCreateOptions struct {
+	// The network driver. Defaults to bridge.
+	Driver string
+
+	// The subnet of the network, in CIDR form (e.g., 172.30.0.0/16).
+	// If empty, Docker picks one.
+	Subnet string
+
+	// Labels to attach to the network.
+	Labels map[string]string
+}
+
+// Returns the ID of the network with the given name, creating it if it
+// doesn't exist.
+//
+// The options only apply when creating the network. An existing network is
+// returned as-is, even if it has a different driver or subnet.
+func EnsureNetworkExists(ctx context.Context, c DockerClient, name string, opts NetworkCreateOptions) (string, error) {
+	existing, err := c.NetworkInspect(ctx, name, types.NetworkInspectOptions{})
+	if err == nil {
+		return existing.ID, nil
+	}
+	if !client.IsErrNotFound(err) {
+		return "", fmt.Errorf("inspecting network %s: %v", name, err)
+	}
+
+	create := types.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         opts.Driver,
+		Labels:         opts.Labels,
+	}
+	if create.Driver == "" {
+		create.Driver = "bridge"
+	}
+	if opts.Subnet != "" {
+		_, _, err := net.ParseCIDR(opts.Subnet)
+		if err != nil {
+			return "", fmt.Errorf("creating network %s: invalid subnet %q: %v", name, opts.Subnet, err)
+		}
+		create.IPAM = &network.IPAM{Config: []network.IPAMConfig{{Subnet: opts.Subnet}}}
+	}
+
+	resp, err := c.NetworkCreate(ctx, name, create)
+	if err != nil {
+		// Someone else may have created the network since we checked.
+		existing, inspectErr := c.NetworkInspect(ctx, name, types.NetworkInspectOptions{})
+		if inspectErr == nil {
+			return existing.ID, nil
+		}
+		return "", fmt.Errorf("creating network %s: %v", name, err)
+	}
+	return resp.ID, nil
+}
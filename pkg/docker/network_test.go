@@ -0,0 +1,72 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureNetworkExists(t *testing.T) {
+	c := &fakeNetworkClient{networks: map[string]types.NetworkResource{}}
+
+	id, err := EnsureNetworkExists(context.Background(), c, "registry-net", NetworkCreateOptions{
+		Subnet: "172.30.0.0/16",
+		Labels: map[string]string{ContainerLabelRole: "registry"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "registry-net-id", id)
+	require.Len(t, c.created, 1)
+	assert.Equal(t, "bridge", c.created[0].Driver)
+	assert.Equal(t, "172.30.0.0/16", c.created[0].IPAM.Config[0].Subnet)
+	assert.Equal(t, map[string]string{ContainerLabelRole: "registry"}, c.created[0].Labels)
+
+	// Calling it again returns the same network.
+	id, err = EnsureNetworkExists(context.Background(), c, "registry-net", NetworkCreateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "registry-net-id", id)
+	assert.Len(t, c.created, 1)
+}
+
+func TestEnsureNetworkExistsInvalidSubnet(t *testing.T) {
+	c := &fakeNetworkClient{networks: map[string]types.NetworkResource{}}
+
+	_, err := EnsureNetworkExists(context.Background(), c, "registry-net", NetworkCreateOptions{Subnet: "172.30.0.0"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `creating network registry-net: invalid subnet "172.30.0.0"`)
+	}
+	assert.Empty(t, c.created)
+}
+
+type fakeNetworkClient struct {
+	networks map[string]types.NetworkResource
+	created  []types.NetworkCreate
+}
+
+type networkNotFoundError string
+
+func (e networkNotFoundError) NotFound() {}
+
+func (e networkNotFoundError) Error() string {
+	return fmt.Sprintf("Error: No such network: %s", string(e))
+}
+
+func (c *fakeNetworkClient) NetworkInspect(ctx context.Context, networkID string, options types.NetworkInspectOptions) (types.NetworkResource, error) {
+	network, ok := c.networks[networkID]
+	if !ok {
+		return types.NetworkResource{}, networkNotFoundError(networkID)
+	}
+	return network, nil
+}
+
+func (c *fakeNetworkClient) NetworkCreate(ctx context.Context, name string, options types.NetworkCreate) (types.NetworkCreateResponse, error) {
+	if _, ok := c.networks[name]; ok {
+		return types.NetworkCreateResponse{}, fmt.Errorf("network with name %s already exists", name)
+	}
+	c.created = append(c.created, options)
+	c.networks[name] = types.NetworkResource{Name: name, ID: name + "-id", Driver: options.Driver}
+	return types.NetworkCreateResponse{ID: name + "-id"}, nil
+}
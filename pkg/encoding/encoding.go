@@ -60,10 +60,43 @@ func determineObj(tm api.TypeMeta) (runtime.Object, error) {
 			return &api.Cluster{}, nil
 		case "Registry":
 			return &api.Registry{}, nil
+		case "ClusterList":
+			return &api.ClusterList{}, nil
+		case "RegistryList":
+			return &api.RegistryList{}, nil
 		default:
-			return nil, fmt.Errorf("ctlptl config must contain: `kind: Cluster` or `kind: Registry`")
+			return nil, fmt.Errorf("ctlptl config must contain: `kind: Cluster`, `kind: Registry`, `kind: ClusterList`, or `kind: RegistryList`")
 		}
 	default:
 		return nil, fmt.Errorf("ctlptl config must contain: `apiVersion: ctlptl.dev/v1alpha1`")
 	}
 }
+
+// Replaces each ClusterList and RegistryList with its items, so that
+// the output of `ctlptl get -o yaml` can be applied.
+func FlattenLists(objs []runtime.Object) []runtime.Object {
+	result := []runtime.Object{}
+	for _, obj := range objs {
+		switch obj := obj.(type) {
+		case *api.ClusterList:
+			for i := range obj.Items {
+				item := obj.Items[i]
+				if item.Kind == "" {
+					item.TypeMeta = api.TypeMeta{APIVersion: obj.APIVersion, Kind: "Cluster"}
+				}
+				result = append(result, &item)
+			}
+		case *api.RegistryList:
+			for i := range obj.Items {
+				item := obj.Items[i]
+				if item.Kind == "" {
+					item.TypeMeta = api.TypeMeta{APIVersion: obj.APIVersion, Kind: "Registry"}
+				}
+				result = append(result, &item)
+			}
+		default:
+			result = append(result, obj)
+		}
+	}
+	return result
+}
@@ -59,3 +59,77 @@ product: microk8s
 		assert.Contains(t, err.Error(), "decoding {Cluster ctlptl.dev/v1alpha1}: yaml: unmarshal errors:\n  line 9: field nameTypo not found in type api.Cluster")
 	}
 }
+
+func TestParseLists(t *testing.T) {
+	yaml := `
+apiVersion: ctlptl.dev/v1alpha1
+kind: ClusterList
+items:
+- apiVersion: ctlptl.dev/v1alpha1
+  kind: Cluster
+  name: kind-kind
+  product: kind
+  status:
+    creationTimestamp: "2022-01-01T12:00:00Z"
+    current: true
+- name: microk8s
+  product: microk8s
+---
+apiVersion: ctlptl.dev/v1alpha1
+kind: RegistryList
+items:
+- apiVersion: ctlptl.dev/v1alpha1
+  kind: Registry
+  name: ctlptl-registry
+  port: 5000
+  status:
+    hostPort: 5000
+    state: running
+`
+	data, err := ParseStream(strings.NewReader(yaml))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(data))
+	assert.Equal(t, 2, len(data[0].(*api.ClusterList).Items))
+	assert.Equal(t, 1, len(data[1].(*api.RegistryList).Items))
+
+	objs := FlattenLists(data)
+	require.Equal(t, 3, len(objs))
+	assert.Equal(t, "kind-kind", objs[0].(*api.Cluster).Name)
+	assert.Equal(t, "Cluster", objs[1].(*api.Cluster).Kind)
+	assert.Equal(t, "microk8s", objs[1].(*api.Cluster).Name)
+	assert.Equal(t, "ctlptl-registry", objs[2].(*api.Registry).Name)
+}
+
+func TestParseExtraHosts(t *testing.T) {
+	yaml := `
+apiVersion: ctlptl.dev/v1alpha1
+kind: Cluster
+product: kind
+extraHosts:
+- ip: 10.1.2.3
+  hostnames: [git.corp.example, wiki.corp.example]
+- ip: fd00::1
+  hostnames: [db.local]
+`
+	data, err := ParseStream(strings.NewReader(yaml))
+	require.NoError(t, err)
+	assert.Equal(t, []api.HostEntry{
+		{IP: "10.1.2.3", Hostnames: []string{"git.corp.example", "wiki.corp.example"}},
+		{IP: "fd00::1", Hostnames: []string{"db.local"}},
+	}, data[0].(*api.Cluster).ExtraHosts)
+}
+
+func TestParseExtraHostsTypo(t *testing.T) {
+	yaml := `
+apiVersion: ctlptl.dev/v1alpha1
+kind: Cluster
+product: kind
+extraHosts:
+- ip: 10.1.2.3
+  hostname: git.corp.example
+`
+	_, err := ParseStream(strings.NewReader(yaml))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "line 7: field hostname not found in type api.HostEntry")
+	}
+}
@@ -0,0 +1,285 @@
+// Package manifest edits ctlptl objects after they've been decoded.
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// UnknownFieldError means that an override path names a field that the
+// object doesn't have.
+type UnknownFieldError struct {
+	Path  string
+	Field string
+
+	// The path of the object that doesn't have the field, or "" for the
+	// top-level object.
+	Parent string
+}
+
+func (e *UnknownFieldError) Error() string {
+	if e.Parent == "" {
+		return fmt.Sprintf("invalid path %q: unrecognized field %q", e.Path, e.Field)
+	}
+	return fmt.Sprintf("invalid path %q: unrecognized field %q in %s", e.Path, e.Field, e.Parent)
+}
+
+// ApplyOverrides sets fields of the object, like Helm's --set.
+//
+// Each key is a dot-separated path of the fields' JSON names, like
+// kindOptions.rootless or readinessChecks.0.name. Map keys that contain
+// dots are escaped with a backslash, like annotations.ctlptl\.dev/owner.
+// Since ctlptl objects keep their spec at the top level, a leading "spec."
+// is ignored.
+//
+// Each value is converted to the field's type: numbers, booleans, and
+// durations are parsed, and anything else is parsed as JSON (e.g.,
+// ["a","b"] for a list). Missing structs, maps, and pointers along the
+// path are created. A list index one past the end appends.
+func ApplyOverrides(obj runtime.Object, overrides map[string]string) error {
+	paths := make([]string, 0, len(overrides))
+	for path := range overrides {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		v := reflect.ValueOf(obj)
+		if v.Kind() != reflect.Ptr || v.IsNil() {
+			return fmt.Errorf("can't override fields of %T", obj)
+		}
+
+		segments := splitPath(path)
+		if len(segments) == 0 || containsEmpty(segments) {
+			return fmt.Errorf("invalid path %q: must be dot-separated field names, like kindOptions.rootless", path)
+		}
+		if segments[0] == "spec" && len(segments) > 1 && !hasField(v.Elem(), "spec") {
+			segments = segments[1:]
+		}
+
+		err := set(v.Elem(), path, nil, segments, overrides[path])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sets the field at the end of the path, relative to v. Parents is the
+// path we've resolved so far.
+func set(v reflect.Value, path string, parents []string, segments []string, value string) error {
+	if len(segments) == 0 {
+		err := setValue(v, value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %q: %v", path, err)
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return set(v.Elem(), path, parents, segments, value)
+	}
+
+	segment := segments[0]
+	parent := strings.Join(parents, ".")
+	children := append(append([]string{}, parents...), segment)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		field, ok := findField(v, segment)
+		if !ok {
+			return &UnknownFieldError{Path: path, Field: segment, Parent: parent}
+		}
+		return set(field, path, children, segments[1:], value)
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("invalid path %q: %s is not a map with string keys", path, parent)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+
+		// Map values can't be set in place, so set a copy and store it.
+		key := reflect.ValueOf(segment).Convert(v.Type().Key())
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			elem.Set(existing)
+		}
+		err := set(elem, path, children, segments[1:], value)
+		if err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+
+	case reflect.Slice:
+		i, err := strconv.Atoi(segment)
+		if err != nil || i < 0 || i > v.Len() {
+			return fmt.Errorf("invalid path %q: %s has %d items, so %q isn't an index (use 0 to %d)",
+				path, parent, v.Len(), segment, v.Len())
+		}
+		if i == v.Len() {
+			v.Set(reflect.Append(v, reflect.New(v.Type().Elem()).Elem()))
+		}
+		return set(v.Index(i), path, children, segments[1:], value)
+	}
+
+	return fmt.Errorf("invalid path %q: %s is a %s, and has no field %q", path, parent, v.Type(), segment)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// Parses the value into v, according to its type.
+func setValue(v reflect.Value, value string) error {
+	if reflect.PtrTo(v.Type()).Implements(jsonUnmarshalerType) {
+		return setJSON(v, value)
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		err := setValue(elem.Elem(), value)
+		if err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.String:
+		v.SetString(value)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", value)
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", value)
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a non-negative integer, got %q", value)
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a number, got %q", value)
+		}
+		v.SetFloat(f)
+		return nil
+	}
+	return setJSON(v, value)
+}
+
+// Parses the value as JSON. Values that aren't valid JSON are treated as
+// strings, so that, e.g., an IntOrString field accepts both 1 and 25%.
+func setJSON(v reflect.Value, value string) error {
+	ptr := reflect.New(v.Type())
+	err := json.Unmarshal([]byte(value), ptr.Interface())
+	if err != nil {
+		if json.Valid([]byte(value)) {
+			return err
+		}
+		err = json.Unmarshal([]byte(strconv.Quote(value)), ptr.Interface())
+		if err != nil {
+			return fmt.Errorf("expected JSON for %s, got %q", v.Type(), value)
+		}
+	}
+	v.Set(ptr.Elem())
+	return nil
+}
+
+// Finds the struct field with the given JSON name, including the
+// fields of embedded structs.
+func findField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// Unexported.
+			continue
+		}
+		jsonName, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+		if f.Anonymous && jsonName == "" && f.Type.Kind() == reflect.Struct {
+			field, ok := findField(v.Field(i), name)
+			if ok {
+				return field, true
+			}
+			continue
+		}
+		if jsonName == "" {
+			jsonName = f.Name
+		}
+		if jsonName == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func hasField(v reflect.Value, name string) bool {
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	_, ok := findField(v, name)
+	return ok
+}
+
+// Splits the path on dots, except for dots escaped with a backslash.
+func splitPath(path string) []string {
+	segments := []string{}
+	var current strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path) && path[i+1] == '.':
+			current.WriteByte('.')
+			i++
+		case path[i] == '.':
+			segments = append(segments, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(path[i])
+		}
+	}
+	return append(segments, current.String())
+}
+
+func containsEmpty(segments []string) bool {
+	for _, s := range segments {
+		if s == "" {
+			return true
+		}
+	}
+	return false
+}
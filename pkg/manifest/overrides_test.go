@@ -0,0 +1,102 @@
+package manifest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	cluster := &api.Cluster{
+		Product:         "kind",
+		ReadinessChecks: []api.ReadinessCheck{{Kind: "deployment", Name: "web"}},
+	}
+	err := ApplyOverrides(cluster, map[string]string{
+		"spec.workers":                         "5",
+		"kindOptions.rootless":                 "true",
+		"readinessChecks.0.name":               "api",
+		"readinessChecks.1.name":               "db",
+		"annotations.ctlptl\\.dev/owner":       "alice",
+		"rollingUpdateStrategy.maxSurge":       "25%",
+		"apiServerCertSANs":                    `["my-laptop.local"]`,
+		"kubeletArgs.max-pods":                 "250",
+		"rollingUpdateStrategy.maxUnavailable": "1",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, cluster.Workers)
+	assert.Equal(t, "kind", cluster.Product)
+	assert.True(t, cluster.KindOptions.Rootless)
+	assert.Equal(t, []api.ReadinessCheck{
+		{Kind: "deployment", Name: "api"},
+		{Name: "db"},
+	}, cluster.ReadinessChecks)
+	assert.Equal(t, map[string]string{"ctlptl.dev/owner": "alice"}, cluster.Annotations)
+	assert.Equal(t, intstr.FromString("25%"), *cluster.RollingUpdateStrategy.MaxSurge)
+	assert.Equal(t, intstr.FromInt(1), *cluster.RollingUpdateStrategy.MaxUnavailable)
+	assert.Equal(t, []string{"my-laptop.local"}, cluster.APIServerCertSANs)
+	assert.Equal(t, map[string]string{"max-pods": "250"}, cluster.KubeletArgs)
+}
+
+// A cluster with a field of every type that the api types don't have yet.
+type extendedCluster struct {
+	api.Cluster
+	Timeout time.Duration `json:"timeout"`
+	Ratio   float64       `json:"ratio"`
+	Retries *uint         `json:"retries"`
+}
+
+func TestApplyOverridesTypes(t *testing.T) {
+	obj := &extendedCluster{}
+	err := ApplyOverrides(obj, map[string]string{
+		"name":    "kind-ci",
+		"timeout": "90s",
+		"ratio":   "0.5",
+		"retries": "3",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "kind-ci", obj.Name)
+	assert.Equal(t, 90*time.Second, obj.Timeout)
+	assert.Equal(t, 0.5, obj.Ratio)
+	assert.Equal(t, uint(3), *obj.Retries)
+}
+
+func TestApplyOverridesUnknownField(t *testing.T) {
+	err := ApplyOverrides(&api.Cluster{}, map[string]string{"spec.workerz": "5"})
+	if assert.Error(t, err) {
+		assert.IsType(t, &UnknownFieldError{}, err)
+		assert.Equal(t, `invalid path "spec.workerz": unrecognized field "workerz"`, err.Error())
+	}
+
+	err = ApplyOverrides(&api.Cluster{}, map[string]string{"kindOptions.rootles": "true"})
+	if assert.Error(t, err) {
+		assert.Equal(t, `invalid path "kindOptions.rootles": unrecognized field "rootles" in kindOptions`, err.Error())
+	}
+}
+
+func TestApplyOverridesInvalid(t *testing.T) {
+	err := ApplyOverrides(&api.Cluster{}, map[string]string{"workers": "five"})
+	if assert.Error(t, err) {
+		assert.Equal(t, `invalid value for "workers": expected an integer, got "five"`, err.Error())
+	}
+
+	err = ApplyOverrides(&api.Cluster{}, map[string]string{"kindOptions..rootless": "true"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid path "kindOptions..rootless"`)
+	}
+
+	err = ApplyOverrides(&api.Cluster{}, map[string]string{"readinessChecks.3.name": "web"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `readinessChecks has 0 items, so "3" isn't an index`)
+	}
+
+	err = ApplyOverrides(&api.Cluster{}, map[string]string{"product.name": "kind"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `product is a string, and has no field "name"`)
+	}
+}
@@ -0,0 +1,350 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types/container"
+	"golang.org/x/crypto/bcrypt"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// The labels that record the username, and where the password came from,
+// on the registry container.
+//
+// The password only lives in the htpasswd file inside the container.
+const (
+	authUsernameLabel     = "dev.tilt.ctlptl.registry-auth-username"
+	authPasswordFromLabel = "dev.tilt.ctlptl.registry-auth-password-from"
+)
+
+// Where the registry reads its htpasswd file.
+const (
+	htpasswdDir  = "/auth"
+	htpasswdPath = htpasswdDir + "/htpasswd"
+)
+
+// The environment variable that seeds the htpasswd file when the
+// container first starts.
+//
+// Rotating the password rewrites the file, but can't change the
+// environment, so the file wins once it exists.
+const htpasswdSeedEnv = "CTLPTL_REGISTRY_HTPASSWD"
+
+func validateAuth(auth *api.RegistryAuthSpec) error {
+	if auth == nil {
+		return nil
+	}
+	if auth.Username == "" {
+		return fmt.Errorf("auth.username is required")
+	}
+	if strings.ContainsAny(auth.Username, ":\r\n") {
+		return fmt.Errorf("auth.username must not contain ':' or newlines. Actual: %q", auth.Username)
+	}
+	if strings.ContainsAny(auth.Password, "\r\n") {
+		return fmt.Errorf("auth.password must not contain newlines")
+	}
+	if auth.PasswordFrom != "" {
+		if auth.Password != "" {
+			return fmt.Errorf("auth.password and auth.passwordFrom can't both be set")
+		}
+		return validateSecretRef("auth.passwordFrom", auth.PasswordFrom)
+	}
+	return nil
+}
+
+// The registry environment variables for htpasswd auth.
+func authEnv(auth *api.RegistryAuthSpec, htpasswd string) []string {
+	if auth == nil {
+		return nil
+	}
+	return []string{
+		"REGISTRY_AUTH=htpasswd",
+		"REGISTRY_AUTH_HTPASSWD_REALM=Registry Realm",
+		"REGISTRY_AUTH_HTPASSWD_PATH=" + htpasswdPath,
+		htpasswdSeedEnv + "=" + htpasswd,
+	}
+}
+
+// Writes the htpasswd file before the registry starts, by wrapping the
+// entrypoint in a shell. Composes with the log file wrapper.
+func addAuth(config *container.Config, auth *api.RegistryAuthSpec) {
+	if auth == nil {
+		return
+	}
+	if len(config.Entrypoint) == 0 {
+		config.Entrypoint = []string{"/bin/sh", "-c"}
+		config.Cmd = []string{"exec " + registryEntrypoint}
+	}
+	seed := fmt.Sprintf(`[ -f %[1]s ] || { mkdir -p %[2]s && printf '%%s\n' "$%[3]s" > %[1]s; }`,
+		htpasswdPath, htpasswdDir, htpasswdSeedEnv)
+	config.Cmd = []string{seed + "\n" + config.Cmd[0]}
+}
+
+// Replaces the auth label with the one for the desired auth config.
+func setAuthLabels(labels map[string]string, auth *api.RegistryAuthSpec) {
+	delete(labels, authUsernameLabel)
+	delete(labels, authPasswordFromLabel)
+	if auth != nil {
+		labels[authUsernameLabel] = auth.Username
+		if auth.PasswordFrom != "" {
+			labels[authPasswordFromLabel] = auth.PasswordFrom
+		}
+	}
+}
+
+// Reads the auth config from the registry container's labels.
+//
+// Returns nil if the registry doesn't require auth.
+func authFromLabels(labels map[string]string) *api.RegistryAuthSpec {
+	username := labels[authUsernameLabel]
+	if username == "" {
+		return nil
+	}
+	return &api.RegistryAuthSpec{Username: username, PasswordFrom: labels[authPasswordFromLabel]}
+}
+
+// Checks if the existing registry accepts the desired credentials.
+//
+// An empty desired password matches any password, so that ctlptl
+// can keep the one it generated.
+func authMatches(existing *api.RegistryAuthSpec, existingHtpasswd string, desired *api.RegistryAuthSpec) bool {
+	if existing == nil || desired == nil {
+		return existing == nil && desired == nil
+	}
+	if existing.Username != desired.Username {
+		return false
+	}
+	if desired.Password == "" {
+		return true
+	}
+	return htpasswdMatches(existingHtpasswd, desired.Username, desired.Password)
+}
+
+// Creates an htpasswd line with a bcrypt hash, the only kind the registry accepts.
+func htpasswdLine(username, password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%s", username, hash), nil
+}
+
+func htpasswdMatches(htpasswd, username, password string) bool {
+	for _, line := range strings.Split(htpasswd, "\n") {
+		user, hash, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || user != username {
+			continue
+		}
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+	return false
+}
+
+func newPassword() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Reads the htpasswd file of the existing registry container.
+//
+// Returns an empty string if the registry doesn't require auth, or isn't running.
+func (c *Controller) htpasswd(ctx context.Context, existing *api.Registry) (string, error) {
+	if existing.Status.Auth == nil || existing.Status.State != containerStateRunning {
+		return "", nil
+	}
+	id := existing.Status.ContainerID
+	if replicaCount(existing) > 1 {
+		// The replicas share the same credentials.
+		id = replicaName(existing.Name, 1)
+	}
+	out, err := c.dockerOutput(ctx, "exec", id, "cat", htpasswdPath)
+	if err != nil {
+		return "", fmt.Errorf("reading credentials of registry %s: %v", existing.Name, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Decides which htpasswd file the new container gets.
+//
+// Keeps the existing file if the desired config doesn't have a password,
+// so that re-creating the registry doesn't change it. Also returns the
+// password, if it's a new one that Docker needs to log in with.
+func htpasswdToUse(desired *api.Registry, existing *api.Registry, existingHtpasswd string) (string, string, error) {
+	auth := desired.Auth
+	if auth == nil {
+		return "", "", nil
+	}
+
+	password := auth.Password
+	if password == "" {
+		if existingHtpasswd != "" && existing.Status.Auth != nil && existing.Status.Auth.Username == auth.Username {
+			return existingHtpasswd, "", nil
+		}
+
+		var err error
+		password, err = newPassword()
+		if err != nil {
+			return "", "", fmt.Errorf("creating registry: %v", err)
+		}
+	}
+
+	line, err := htpasswdLine(auth.Username, password)
+	if err != nil {
+		return "", "", fmt.Errorf("creating registry: %v", err)
+	}
+	return line, password, nil
+}
+
+// Logs Docker in to the registry on the host, so that the Docker config
+// (or its credential helper) has the new password.
+func (c *Controller) dockerLogin(ctx context.Context, hostPort int, username, password string) error {
+	server := fmt.Sprintf("localhost:%d", hostPort)
+	out := bytes.NewBuffer(nil)
+	errOut := bytes.NewBuffer(nil)
+	err := c.runner.RunIO(ctx,
+		genericclioptions.IOStreams{In: strings.NewReader(password), Out: out, ErrOut: errOut},
+		"docker", "login", server, "--username", username, "--password-stdin")
+	if err != nil {
+		return fmt.Errorf("updating Docker credentials for %s: %v: %s", server, err, strings.TrimSpace(errOut.String()))
+	}
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Updated Docker credentials for %s\n", server)
+	return nil
+}
+
+// Waits for a new registry to start, then logs Docker in to it.
+func (c *Controller) loginWhenReady(ctx context.Context, hostPort int, username, password string) error {
+	client, err := NewClient(fmt.Sprintf("localhost:%d", hostPort), username, password)
+	if err != nil {
+		return err
+	}
+	err = waitForRegistry(ctx, client)
+	if err != nil {
+		return err
+	}
+	return c.dockerLogin(ctx, hostPort, username, password)
+}
+
+// Registry 3.0 and later re-read the htpasswd file when it changes.
+// Older registries only read it at startup.
+func htpasswdReloads(image string) bool {
+	ref, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return false
+	}
+	tagged, ok := ref.(reference.Tagged)
+	if !ok {
+		return false
+	}
+	major, _, _ := strings.Cut(strings.TrimPrefix(tagged.Tag(), "v"), ".")
+	n, err := strconv.Atoi(major)
+	return err == nil && n >= 3
+}
+
+type RotateAuthOptions struct {
+	// The new password. If empty, generates one.
+	Password string
+}
+
+type RotateAuthResult struct {
+	Registry *api.Registry
+
+	// True if the registry picked up the new password while running.
+	// False if it was re-created.
+	Reloaded bool
+}
+
+// RotateAuth replaces the password of a registry with htpasswd auth, and
+// logs Docker in with the new password.
+//
+// If the registry re-reads its htpasswd file (registry 3.0 and later),
+// rewrites the file in the running container. Otherwise, re-creates the
+// registry with the new password, keeping its storage.
+func (c *Controller) RotateAuth(ctx context.Context, name string, options RotateAuthOptions) (*RotateAuthResult, error) {
+	if strings.ContainsAny(options.Password, "\r\n") {
+		return nil, fmt.Errorf("password must not contain newlines")
+	}
+
+	reg, err := c.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if reg.Status.Auth == nil {
+		return nil, fmt.Errorf("registry %s doesn't require auth. Set auth in its config, and apply it", name)
+	}
+	if reg.Status.State != containerStateRunning {
+		return nil, fmt.Errorf("registry %s is not running", name)
+	}
+
+	password := options.Password
+	if password == "" {
+		password, err = newPassword()
+		if err != nil {
+			return nil, fmt.Errorf("rotating credentials: %v", err)
+		}
+	}
+	auth := &api.RegistryAuthSpec{Username: reg.Status.Auth.Username, Password: password}
+
+	if !htpasswdReloads(reg.Status.Image) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Warning: registry %s (%s) only reads its credentials at startup. Re-creating it, and keeping its storage\n",
+			name, reg.Status.Image)
+		desired, err := c.specFromStatus(ctx, reg)
+		if err != nil {
+			return nil, err
+		}
+		desired.Auth = auth
+		// Skip the rest of apply, so that we don't touch the registry's hosts.
+		newReg, err := c.applyContainer(ctx, desired, true)
+		if err != nil {
+			return nil, err
+		}
+		// The registry may have a new address.
+		err = c.applyExposeOnHostPort(ctx, newReg, reg.ExposeOnHostPort)
+		if err != nil {
+			return nil, err
+		}
+		newReg.ExposeOnHostPort = reg.ExposeOnHostPort
+		return &RotateAuthResult{Registry: newReg}, nil
+	}
+
+	line, err := htpasswdLine(auth.Username, auth.Password)
+	if err != nil {
+		return nil, fmt.Errorf("rotating credentials: %v", err)
+	}
+
+	ids := []string{reg.Status.ContainerID}
+	if replicaCount(reg) > 1 {
+		ids = nil
+		for i := 1; i <= replicaCount(reg); i++ {
+			ids = append(ids, replicaName(name, i))
+		}
+	}
+
+	// Write a temp file and rename it, so the registry never reads a partial file.
+	script := fmt.Sprintf(`printf '%%s\n' "$1" > %[1]s.tmp && mv %[1]s.tmp %[1]s`, htpasswdPath)
+	for _, id := range ids {
+		err := c.docker(ctx, "exec", id, "sh", "-c", script, "sh", line)
+		if err != nil {
+			return nil, fmt.Errorf("rotating credentials: %v", err)
+		}
+	}
+
+	err = c.dockerLogin(ctx, reg.Status.HostPort, auth.Username, auth.Password)
+	if err != nil {
+		return nil, err
+	}
+	return &RotateAuthResult{Registry: reg, Reloaded: true}, nil
+}
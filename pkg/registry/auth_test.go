@@ -0,0 +1,393 @@
+package registry
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// A registry with auth, listening on the port of a fake registry server.
+func authRegistry(t *testing.T, image string) types.Container {
+	server := newFakeRegistryServer(t)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+
+	registry := kindRegistry()
+	registry.Image = image
+	registry.Ports[0].PublicPort = uint16(port)
+	registry.Labels = registryLabels()
+	registry.Labels[authUsernameLabel] = "me"
+	return registry
+}
+
+// Records each command, and serves the htpasswd file.
+func fakeAuthRunner(calls *[]string, htpasswd string) exec.CmdRunner {
+	return exec.NewFakeCmdRunner(func(argv []string) string {
+		*calls = append(*calls, strings.Join(argv, " "))
+		if len(argv) > 3 && argv[1] == "exec" && argv[3] == "cat" {
+			return htpasswd + "\n"
+		}
+		return ""
+	})
+}
+
+func envValue(env []string, key string) string {
+	for _, e := range env {
+		if strings.HasPrefix(e, key+"=") {
+			return strings.TrimPrefix(e, key+"=")
+		}
+	}
+	return ""
+}
+
+func TestApplyAuth(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	existing := authRegistry(t, "registry:2")
+	f.docker.onCreate = func() {
+		existing.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{existing}
+	}
+	calls := []string{}
+	f.c.runner = fakeAuthRunner(&calls, "")
+
+	registry, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Port:     int(existing.Ports[0].PublicPort),
+		Auth:     &api.RegistryAuthSpec{Username: "me"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &api.RegistryAuthSpec{Username: "me"}, registry.Status.Auth)
+
+	config := f.docker.lastCreateConfig
+	assert.Equal(t, "htpasswd", envValue(config.Env, "REGISTRY_AUTH"))
+	assert.Equal(t, htpasswdPath, envValue(config.Env, "REGISTRY_AUTH_HTPASSWD_PATH"))
+	assert.True(t, strings.HasPrefix(envValue(config.Env, htpasswdSeedEnv), "me:$2a$"))
+	assert.Equal(t, []string{"/bin/sh", "-c"}, []string(config.Entrypoint))
+	assert.Contains(t, config.Cmd[0], "exec "+registryEntrypoint)
+
+	server := "localhost:" + strconv.Itoa(int(existing.Ports[0].PublicPort))
+	assert.Equal(t, []string{"docker login " + server + " --username me --password-stdin"}, calls)
+}
+
+func TestApplyAuthKeepsPassword(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	line, err := htpasswdLine("me", "secret")
+	require.NoError(t, err)
+
+	existing := authRegistry(t, "registry:2")
+	f.docker.containers = []types.Container{existing}
+	calls := []string{}
+	f.c.runner = fakeAuthRunner(&calls, line)
+
+	// Without a password, or with the same password, the registry stays.
+	for _, password := range []string{"", "secret"} {
+		_, err = f.c.Apply(context.Background(), &api.Registry{
+			TypeMeta: typeMeta,
+			Name:     "kind-registry",
+			Auth:     &api.RegistryAuthSpec{Username: "me", Password: password},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, f.docker.lastCreateConfig)
+	}
+
+	// A new password re-creates it.
+	f.docker.onCreate = func() {
+		existing.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{existing}
+	}
+	_, err = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Auth:     &api.RegistryAuthSpec{Username: "me", Password: "new-secret"},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, f.docker.lastCreateConfig)
+	seed := envValue(f.docker.lastCreateConfig.Env, htpasswdSeedEnv)
+	assert.True(t, htpasswdMatches(seed, "me", "new-secret"))
+	assert.Contains(t, calls[len(calls)-1], "docker login")
+}
+
+func TestRotateAuthReload(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	existing := authRegistry(t, "registry:3.0.0")
+	f.docker.containers = []types.Container{existing}
+	calls := []string{}
+	runner := exec.NewFakeCmdRunner(func(argv []string) string {
+		calls = append(calls, strings.Join(argv[:3], " "))
+		if argv[1] == "exec" {
+			assert.True(t, htpasswdMatches(argv[len(argv)-1], "me", "rotated"))
+		}
+		return ""
+	})
+	f.c.runner = runner
+
+	result, err := f.c.RotateAuth(context.Background(), "kind-registry", RotateAuthOptions{Password: "rotated"})
+	require.NoError(t, err)
+	assert.True(t, result.Reloaded)
+	assert.Nil(t, f.docker.lastCreateConfig)
+	assert.Equal(t, []string{
+		"docker exec " + existing.ID,
+		"docker login localhost:" + strconv.Itoa(int(existing.Ports[0].PublicPort)),
+	}, calls)
+}
+
+func TestRotateAuthRecreate(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	line, err := htpasswdLine("me", "secret")
+	require.NoError(t, err)
+
+	existing := authRegistry(t, "registry:2")
+	f.docker.containers = []types.Container{existing}
+	f.docker.mounts = map[string][]types.MountPoint{
+		existing.ID: {{Type: mount.TypeVolume, Name: "registry-data", Destination: registryStoragePath}},
+	}
+	f.docker.onCreate = func() {
+		existing.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{existing}
+	}
+	calls := []string{}
+	f.c.runner = fakeAuthRunner(&calls, line)
+
+	result, err := f.c.RotateAuth(context.Background(), "kind-registry", RotateAuthOptions{})
+	require.NoError(t, err)
+	assert.False(t, result.Reloaded)
+	assert.Equal(t, &api.RegistryAuthSpec{Username: "me"}, result.Registry.Status.Auth)
+
+	require.NotNil(t, f.docker.lastCreateConfig)
+	seed := envValue(f.docker.lastCreateConfig.Env, htpasswdSeedEnv)
+	assert.NotEqual(t, line, seed)
+	assert.False(t, htpasswdMatches(seed, "me", "secret"))
+	assert.Equal(t, "registry-data", f.docker.lastCreateHostConfig.Mounts[0].Source)
+	assert.Contains(t, calls[len(calls)-1], "docker login")
+}
+
+func TestRotateAuthRecreateKeepsSettings(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	line, err := htpasswdLine("me", "secret")
+	require.NoError(t, err)
+
+	webhook := &api.RegistryWebhookSpec{
+		URL:     "http://host.docker.internal:8080/events",
+		Headers: map[string]string{"Authorization": "Bearer abc"},
+	}
+	_, err = f.c.writeWebhookConfig("kind-registry", webhook)
+	require.NoError(t, err)
+
+	existing := authRegistry(t, "registry:2")
+	setWebhookLabels(existing.Labels, webhook)
+	existing.Labels[platformLabel] = "linux/arm64"
+	existing.Labels["team"] = "build"
+	f.docker.containers = []types.Container{existing}
+	f.docker.env = map[string][]string{
+		existing.ID: {httpSecretEnv + "=s3cr3t"},
+	}
+	f.docker.mounts = map[string][]types.MountPoint{
+		existing.ID: {{Type: mount.TypeVolume, Name: "registry-data", Destination: registryStoragePath}},
+	}
+	f.docker.onCreate = func() {
+		existing.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{existing}
+	}
+	calls := []string{}
+	f.c.runner = fakeAuthRunner(&calls, line)
+
+	result, err := f.c.RotateAuth(context.Background(), "kind-registry", RotateAuthOptions{})
+	require.NoError(t, err)
+	assert.False(t, result.Reloaded)
+
+	require.NotNil(t, f.docker.lastCreateConfig)
+	assert.Equal(t, "s3cr3t", envValue(f.docker.lastCreateConfig.Env, httpSecretEnv))
+	headers, err := f.c.webhookHeaders(&api.Registry{Name: "kind-registry"})
+	require.NoError(t, err)
+	assert.Equal(t, webhook.Headers, headers)
+	assert.Contains(t, f.docker.lastCreateHostConfig.Binds,
+		webhookConfigFile(f.c.webhookConfigDir, "kind-registry")+":"+webhookConfigPath+":ro")
+	assert.Equal(t, webhook.URL, f.docker.lastCreateConfig.Labels[webhookURLLabel])
+	assert.Equal(t, webhookHeadersHash(webhook.Headers), f.docker.lastCreateConfig.Labels[webhookHeadersLabel])
+	assert.Equal(t, "linux/arm64", f.docker.lastCreateConfig.Labels[platformLabel])
+	assert.Equal(t, "build", f.docker.lastCreateConfig.Labels["team"])
+	assert.Equal(t, &specs.Platform{OS: "linux", Architecture: "arm64"}, f.docker.created[0].platform)
+}
+
+func TestRotateAuthNoAuth(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{kindRegistry()}
+	_, err := f.c.RotateAuth(context.Background(), "kind-registry", RotateAuthOptions{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "registry kind-registry doesn't require auth")
+	}
+}
+
+func TestHtpasswdReloads(t *testing.T) {
+	assert.False(t, htpasswdReloads("registry:2"))
+	assert.False(t, htpasswdReloads("docker.io/library/registry:2.8.1"))
+	assert.False(t, htpasswdReloads("registry"))
+	assert.True(t, htpasswdReloads("registry:3"))
+	assert.True(t, htpasswdReloads("docker.io/library/registry:3.0.0"))
+}
+
+func TestValidateAuth(t *testing.T) {
+	assert.NoError(t, validateAuth(nil))
+	assert.NoError(t, validateAuth(&api.RegistryAuthSpec{Username: "me"}))
+
+	err := validateAuth(&api.RegistryAuthSpec{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "auth.username is required")
+	}
+	err = validateAuth(&api.RegistryAuthSpec{Username: "me:you"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "auth.username must not contain ':'")
+	}
+}
+
+func TestValidateAuthPasswordFrom(t *testing.T) {
+	assert.NoError(t, validateAuth(&api.RegistryAuthSpec{Username: "me", PasswordFrom: "env:REGISTRY_PASS"}))
+	assert.NoError(t, validateAuth(&api.RegistryAuthSpec{Username: "me", PasswordFrom: "file:/run/secrets/registry"}))
+
+	err := validateAuth(&api.RegistryAuthSpec{Username: "me", Password: "pw", PasswordFrom: "env:REGISTRY_PASS"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "auth.password and auth.passwordFrom can't both be set")
+	}
+	err = validateAuth(&api.RegistryAuthSpec{Username: "me", PasswordFrom: "REGISTRY_PASS"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `auth.passwordFrom must be env:NAME or file:PATH. Actual: "REGISTRY_PASS"`)
+	}
+}
+
+func TestApplyAuthPasswordFromEnv(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+	t.Setenv("CTLPTL_TEST_REGISTRY_PASS", "from-env")
+
+	existing := authRegistry(t, "registry:2")
+	f.docker.onCreate = func() {
+		existing.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{existing}
+	}
+	calls := []string{}
+	f.c.runner = fakeAuthRunner(&calls, "")
+
+	desired := &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Port:     int(existing.Ports[0].PublicPort),
+		Auth:     &api.RegistryAuthSpec{Username: "me", PasswordFrom: "env:CTLPTL_TEST_REGISTRY_PASS"},
+	}
+	registry, err := f.c.Apply(context.Background(), desired)
+	require.NoError(t, err)
+
+	seed := envValue(f.docker.lastCreateConfig.Env, htpasswdSeedEnv)
+	assert.True(t, htpasswdMatches(seed, "me", "from-env"))
+	assert.Equal(t, &api.RegistryAuthSpec{Username: "me", PasswordFrom: "env:CTLPTL_TEST_REGISTRY_PASS"}, registry.Status.Auth)
+	assert.Equal(t, "", desired.Auth.Password, "The resolved password should not leak into the config")
+	assert.Contains(t, calls[len(calls)-1], "docker login")
+
+	// The same password from the same source keeps the registry.
+	f.docker.lastCreateConfig = nil
+	f.c.runner = fakeAuthRunner(&calls, seed)
+	_, err = f.c.Apply(context.Background(), desired)
+	require.NoError(t, err)
+	assert.Nil(t, f.docker.lastCreateConfig)
+}
+
+func TestApplyAuthPasswordFromFile(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	path := filepath.Join(t.TempDir(), "registry-password")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0600))
+
+	existing := authRegistry(t, "registry:2")
+	f.docker.onCreate = func() {
+		existing.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{existing}
+	}
+	calls := []string{}
+	f.c.runner = fakeAuthRunner(&calls, "")
+
+	_, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Port:     int(existing.Ports[0].PublicPort),
+		Auth:     &api.RegistryAuthSpec{Username: "me", PasswordFrom: "file:" + path},
+	})
+	require.NoError(t, err)
+	seed := envValue(f.docker.lastCreateConfig.Env, htpasswdSeedEnv)
+	assert.True(t, htpasswdMatches(seed, "me", "from-file"))
+}
+
+func TestApplyAuthPasswordFromUnresolved(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	_, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Auth:     &api.RegistryAuthSpec{Username: "me", PasswordFrom: "env:CTLPTL_TEST_UNSET_PASS"},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "auth.passwordFrom: environment variable CTLPTL_TEST_UNSET_PASS is not set")
+	}
+
+	_, err = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Auth:     &api.RegistryAuthSpec{Username: "me", PasswordFrom: "file:" + filepath.Join(t.TempDir(), "missing")},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "auth.passwordFrom: open")
+	}
+	assert.Nil(t, f.docker.lastCreateConfig)
+}
+
+func TestRedacted(t *testing.T) {
+	registry := &api.Registry{Name: "kind-registry", Auth: &api.RegistryAuthSpec{Username: "me", Password: "secret"}}
+	assert.Equal(t, "<redacted>", Redacted(registry).Auth.Password)
+	assert.Equal(t, "secret", registry.Auth.Password)
+
+	fromEnv := &api.Registry{Name: "kind-registry", Auth: &api.RegistryAuthSpec{Username: "me", PasswordFrom: "env:REGISTRY_PASS"}}
+	assert.Equal(t, fromEnv, Redacted(fromEnv))
+
+	withSecrets := &api.Registry{
+		Name:       "kind-registry",
+		HTTPSecret: "s3cr3t",
+		Webhook: &api.RegistryWebhookSpec{
+			URL:     "http://host.docker.internal:8080/events",
+			Headers: map[string]string{"Authorization": "Bearer abc"},
+		},
+	}
+	result := Redacted(withSecrets)
+	assert.Equal(t, "<redacted>", result.HTTPSecret)
+	assert.Equal(t, map[string]string{"Authorization": "<redacted>"}, result.Webhook.Headers)
+	assert.Equal(t, "http://host.docker.internal:8080/events", result.Webhook.URL)
+	assert.Equal(t, "s3cr3t", withSecrets.HTTPSecret)
+	assert.Equal(t, "Bearer abc", withSecrets.Webhook.Headers["Authorization"])
+}
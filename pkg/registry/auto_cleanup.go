@@ -0,0 +1,300 @@
+package registry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// Untagged manifests newer than this may belong to a push that's still in
+// progress (e.g., the per-platform manifests of a multi-platform image are
+// pushed before the index that tags them), so auto-cleanup leaves them alone.
+var untaggedGracePeriod = time.Minute
+
+// A manifest in the registry's storage, and when it was pushed.
+type manifestRevision struct {
+	Repository string
+	Digest     string
+	Pushed     time.Time
+}
+
+// A tag, and when its image was created.
+type taggedImage struct {
+	Tag     string
+	Digest  string
+	Created time.Time
+
+	// If the tag points at a multi-platform index, the manifests it references.
+	Children []string
+}
+
+type AutoCleanupOptions struct {
+	// The number of images to keep in each repository.
+	Keep int
+
+	// Credentials for a registry with auth. If empty, uses Docker's
+	// credentials for the registry.
+	Username string
+	Password string
+}
+
+// AutoCleanup keeps the most recent options.Keep images of each repository in a
+// local registry, and deletes the tags of the rest. Images are ordered by the
+// creation time in their config. Tags that point at the same image count once.
+//
+// Untagged manifests (e.g., left behind when a tag is pushed again) are
+// deleted too, unless they were pushed in the last minute.
+//
+// Deletes go through the registry API, one at a time, so that the registry
+// keeps serving pushes and pulls. Before each tag is deleted, we check that
+// it hasn't been pushed again since we listed it. The space isn't reclaimed
+// until the registry is garbage collected.
+func (c *Controller) AutoCleanup(ctx context.Context, name string, options AutoCleanupOptions) error {
+	if options.Keep < 1 {
+		return fmt.Errorf("auto-cleanup must keep at least 1 image per repository. Actual: %d", options.Keep)
+	}
+	reg, err := c.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	if reg.Status.State != containerStateRunning {
+		return fmt.Errorf("registry %s is not running", name)
+	}
+	client, err := c.hostClient(reg, options.Username, options.Password)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := c.listManifestRevisions(ctx, reg.Status.ContainerID)
+	if err != nil {
+		return fmt.Errorf("reading registry %s storage: %v", name, err)
+	}
+
+	return c.autoCleanupClient(ctx, client, options.Keep, revisions, time.Now())
+}
+
+func (c *Controller) autoCleanupClient(ctx context.Context, client *Client, keepCount int, revisions []manifestRevision, now time.Time) error {
+	repos, err := client.Catalog(ctx)
+	if err != nil {
+		return err
+	}
+
+	revisionsByRepo := make(map[string][]manifestRevision)
+	for _, r := range revisions {
+		revisionsByRepo[r.Repository] = append(revisionsByRepo[r.Repository], r)
+	}
+
+	deleted := 0
+	for _, repo := range repos {
+		images, err := listTaggedImages(ctx, client, repo)
+		if err != nil {
+			return err
+		}
+
+		// Newest first.
+		sort.SliceStable(images, func(i, j int) bool {
+			if !images[i].Created.Equal(images[j].Created) {
+				return images[i].Created.After(images[j].Created)
+			}
+			return images[i].Tag < images[j].Tag
+		})
+
+		// Manifests that a kept tag still needs.
+		inUse := make(map[string]bool)
+		kept := 0
+		old := []taggedImage{}
+		for _, image := range images {
+			if !inUse[image.Digest] && kept < keepCount {
+				kept++
+				inUse[image.Digest] = true
+				for _, child := range image.Children {
+					inUse[child] = true
+				}
+			}
+			if !inUse[image.Digest] {
+				old = append(old, image)
+			}
+		}
+
+		deletedDigests := make(map[string]bool)
+		for _, image := range old {
+			if deletedDigests[image.Digest] {
+				_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Deleted %s:%s\n", repo, image.Tag)
+				continue
+			}
+
+			current, err := client.ManifestDigest(ctx, repo, image.Tag)
+			if err != nil {
+				return err
+			}
+			if current != image.Digest {
+				// Deleted or re-pushed since we listed it.
+				continue
+			}
+
+			err = client.DeleteManifest(ctx, repo, image.Digest)
+			if err != nil {
+				return fmt.Errorf("deleting %s:%s: %v", repo, image.Tag, err)
+			}
+			deletedDigests[image.Digest] = true
+			deleted++
+			_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Deleted %s:%s\n", repo, image.Tag)
+		}
+
+		for _, r := range revisionsByRepo[repo] {
+			if inUse[r.Digest] || deletedDigests[r.Digest] || now.Sub(r.Pushed) < untaggedGracePeriod {
+				continue
+			}
+			err = client.DeleteManifest(ctx, repo, r.Digest)
+			if err != nil {
+				return fmt.Errorf("deleting %s@%s: %v", repo, r.Digest, err)
+			}
+			deleted++
+			_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Deleted untagged %s@%s\n", repo, r.Digest)
+		}
+	}
+
+	if deleted > 0 {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut,
+			"Deleted %d manifests. Run 'ctlptl registry gc' to reclaim their disk space\n", deleted)
+	}
+	return nil
+}
+
+// Lists the tags of the repository, with the creation time of each image.
+func listTaggedImages(ctx context.Context, client *Client, repo string) ([]taggedImage, error) {
+	tags, err := client.Tags(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []taggedImage{}
+	for _, tag := range tags {
+		digest, err := client.ManifestDigest(ctx, repo, tag)
+		if err != nil {
+			return nil, err
+		}
+		if digest == "" {
+			// Deleted since we listed the tags.
+			continue
+		}
+		image := taggedImage{Tag: tag, Digest: digest}
+		err = readImageCreated(ctx, client, repo, digest, &image)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s:%s: %v", repo, tag, err)
+		}
+		result = append(result, image)
+	}
+	return result, nil
+}
+
+// Fills in when the image was created, from its config. Multi-platform
+// images use the config of their first platform.
+//
+// Images whose config has no creation time are treated as the oldest.
+func readImageCreated(ctx context.Context, client *Client, repo, digest string, image *taggedImage) error {
+	_, data, err := client.GetManifest(ctx, repo, digest)
+	if err != nil {
+		return err
+	}
+	var manifest ociManifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return fmt.Errorf("parsing manifest: %v", err)
+	}
+
+	if len(manifest.Manifests) > 0 {
+		for _, child := range manifest.Manifests {
+			image.Children = append(image.Children, child.Digest)
+		}
+		_, data, err = client.GetManifest(ctx, repo, manifest.Manifests[0].Digest)
+		if err != nil {
+			return err
+		}
+		manifest = ociManifest{}
+		err = json.Unmarshal(data, &manifest)
+		if err != nil {
+			return fmt.Errorf("parsing manifest: %v", err)
+		}
+	}
+	if manifest.Config == nil {
+		return nil
+	}
+
+	config := bytes.NewBuffer(nil)
+	err = client.GetBlob(ctx, repo, manifest.Config.Digest, config)
+	if err != nil {
+		return err
+	}
+	var fields struct {
+		Created time.Time `json:"created"`
+	}
+	// Not every artifact has an image config, so ignore configs we can't read.
+	_ = json.Unmarshal(config.Bytes(), &fields)
+	image.Created = fields.Created
+	return nil
+}
+
+// Lists every manifest in the registry's storage, tagged or not.
+//
+// The registry API can only list tags, so we look in the storage directly:
+// each manifest has a link file under its repository's _manifests/revisions.
+func (c *Controller) listManifestRevisions(ctx context.Context, containerID string) ([]manifestRevision, error) {
+	script := fmt.Sprintf(
+		"[ -d %[1]s ] || exit 0; find %[1]s -path '*/_manifests/revisions/*' -name link -type f -exec stat -c '%%Y %%n' {} +",
+		registryRepositoriesPath)
+	out := bytes.NewBuffer(nil)
+	errOut := bytes.NewBuffer(nil)
+	err := c.runner.RunIO(ctx,
+		genericclioptions.IOStreams{Out: out, ErrOut: errOut},
+		"docker", "exec", containerID, "sh", "-c", script)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(errOut.String()))
+	}
+	return parseManifestRevisions(out.String())
+}
+
+// Parses lines like
+// "1600000000 /var/lib/registry/.../repositories/team/app/_manifests/revisions/sha256/abc/link".
+func parseManifestRevisions(out string) ([]manifestRevision, error) {
+	result := []manifestRevision{}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		mtime, file, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("unexpected manifest revision %q", line)
+		}
+		secs, err := strconv.ParseInt(mtime, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing modification time %q: %v", mtime, err)
+		}
+
+		file = strings.TrimPrefix(file, registryRepositoriesPath+"/")
+		repo, revision, ok := strings.Cut(file, "/_manifests/revisions/")
+		if !ok {
+			return nil, fmt.Errorf("unexpected manifest revision %q", line)
+		}
+		parts := strings.Split(revision, "/")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("unexpected manifest revision %q", line)
+		}
+		result = append(result, manifestRevision{
+			Repository: repo,
+			Digest:     parts[0] + ":" + parts[1],
+			Pushed:     time.Unix(secs, 0),
+		})
+	}
+	return result, scanner.Err()
+}
@@ -0,0 +1,155 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+)
+
+var cleanupNow = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// Adds a single-platform image created at the given time, and returns its digest.
+func (s *fakeRegistryServer) addImage(repo, tag string, created time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config, err := json.Marshal(map[string]interface{}{"created": created, "architecture": "amd64"})
+	require.NoError(s.t, err)
+	configDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(config))
+	s.blobs[configDigest] = config
+
+	data, err := json.Marshal(ociManifest{
+		MediaType: mediaTypeOCIManifest,
+		Config:    &ociDescriptor{Digest: configDigest, Size: int64(len(config))},
+	})
+	require.NoError(s.t, err)
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	s.manifests[repo+"@"+digest] = fakeManifest{mediaType: mediaTypeOCIManifest, data: data}
+	if tag != "" {
+		s.tagged[repo+":"+tag] = digest
+	}
+	return digest
+}
+
+func TestAutoCleanup(t *testing.T) {
+	server := newFakeRegistryServer(t)
+	day := 24 * time.Hour
+	v1 := server.addImage("app", "v1", cleanupNow.Add(-3*day))
+	v2 := server.addImage("app", "v2", cleanupNow.Add(-2*day))
+	v3 := server.addImage("app", "v3", cleanupNow.Add(-day))
+	server.tagged["app:latest"] = v3
+	overwritten := server.addImage("app", "", cleanupNow.Add(-4*day))
+	inProgress := server.addImage("app", "", cleanupNow)
+	server.addImage("tools", "v1", cleanupNow.Add(-day))
+
+	client, err := NewClient(server.URL, "", "")
+	require.NoError(t, err)
+
+	revisions := []manifestRevision{}
+	for _, d := range []string{v1, v2, v3, overwritten} {
+		revisions = append(revisions, manifestRevision{Repository: "app", Digest: d, Pushed: cleanupNow.Add(-time.Hour)})
+	}
+	revisions = append(revisions, manifestRevision{Repository: "app", Digest: inProgress, Pushed: cleanupNow.Add(-time.Second)})
+
+	f := newFixture(t)
+	defer f.TearDown()
+	streams, _, _, errOut := genericclioptions.NewTestIOStreams()
+	f.c.iostreams = streams
+	err = f.c.autoCleanupClient(context.Background(), client, 2, revisions, cleanupNow)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"app:latest", "app:v2", "app:v3", "tools:v1"}, server.tags())
+	assert.NotContains(t, server.manifests, "app@"+v1)
+	assert.NotContains(t, server.manifests, "app@"+overwritten)
+	assert.Contains(t, server.manifests, "app@"+inProgress)
+	assert.Contains(t, errOut.String(), "Deleted app:v1\n")
+	assert.Contains(t, errOut.String(), "Deleted untagged app@"+overwritten)
+	assert.Contains(t, errOut.String(), "Deleted 2 manifests")
+}
+
+func TestAutoCleanupKeepsMultiPlatformImages(t *testing.T) {
+	server := newFakeRegistryServer(t)
+	server.seed()
+
+	client, err := NewClient(server.URL, "", "")
+	require.NoError(t, err)
+
+	// The per-platform manifests of team/app:latest aren't tagged.
+	revisions := []manifestRevision{}
+	for ref := range server.manifests {
+		repo, digest, _ := strings.Cut(ref, "@")
+		revisions = append(revisions, manifestRevision{Repository: repo, Digest: digest, Pushed: cleanupNow.Add(-time.Hour)})
+	}
+
+	f := newFixture(t)
+	defer f.TearDown()
+	streams, _, _, errOut := genericclioptions.NewTestIOStreams()
+	f.c.iostreams = streams
+	err = f.c.autoCleanupClient(context.Background(), client, 1, revisions, cleanupNow)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"alpine:3.16", "alpine:latest", "team/app:latest"}, server.tags())
+	assert.Len(t, server.manifests, 4)
+	assert.Equal(t, "", errOut.String())
+}
+
+func TestAutoCleanupKeepCount(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	err := f.c.AutoCleanup(context.Background(), "kind-registry", AutoCleanupOptions{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "must keep at least 1 image per repository")
+	}
+}
+
+func TestAutoCleanupAuth(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	server := newFakeRegistryServer(t)
+	server.addImage("team/app", "v1", cleanupNow.Add(-time.Hour))
+	server.addImage("team/app", "v2", cleanupNow)
+	server.username = "me"
+	server.password = "secret"
+
+	reg := authRegistry(t, "registry:2")
+	reg.Ports[0].PublicPort = fakeServerPort(t, server.URL)
+	f.docker.containers = []types.Container{reg}
+	f.c.runner = exec.NewFakeCmdRunner(func(argv []string) string { return "" })
+
+	// Explicit credentials win over Docker's.
+	address := fmt.Sprintf("localhost:%d", reg.Ports[0].PublicPort)
+	writeDockerCredentials(t, f, address, "me", "secret")
+	err := f.c.AutoCleanup(context.Background(), "kind-registry",
+		AutoCleanupOptions{Keep: 1, Username: "me", Password: "wrong"})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"team/app:v1", "team/app:v2"}, server.tags())
+
+	err = f.c.AutoCleanup(context.Background(), "kind-registry", AutoCleanupOptions{Keep: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team/app:v2"}, server.tags())
+}
+
+func TestParseManifestRevisions(t *testing.T) {
+	revisions, err := parseManifestRevisions(
+		"1600000000 " + registryRepositoriesPath + "/team/app/_manifests/revisions/sha256/abc/link\n")
+	require.NoError(t, err)
+	assert.Equal(t, []manifestRevision{
+		{Repository: "team/app", Digest: "sha256:abc", Pushed: time.Unix(1600000000, 0)},
+	}, revisions)
+
+	_, err = parseManifestRevisions("1600000000 /elsewhere/link\n")
+	assert.Error(t, err)
+}
@@ -0,0 +1,489 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tilt-dev/ctlptl/internal/egress"
+)
+
+// The maximum number of repositories to request per catalog page.
+const catalogPageSize = 100
+
+// Client talks to the Docker Registry HTTP API V2.
+//
+// Supports anonymous registries, basic auth, and the token auth flow
+// (https://docs.docker.com/registry/spec/auth/token/). Tokens are cached
+// for the lifetime of the client.
+type Client struct {
+	httpClient *http.Client
+	baseURL    *url.URL
+	username   string
+	password   string
+
+	mu     sync.Mutex
+	tokens map[string]bearerToken
+}
+
+type bearerToken struct {
+	token   string
+	expires time.Time
+}
+
+// NewClient creates a client for the registry at the given address.
+//
+// The address may be a URL or a host:port. Addresses without a scheme
+// use https, except for localhost, which uses http.
+func NewClient(address, username, password string) (*Client, error) {
+	baseURL, err := parseRegistryAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		httpClient: http.DefaultClient,
+		baseURL:    baseURL,
+		username:   username,
+		password:   password,
+		tokens:     make(map[string]bearerToken),
+	}, nil
+}
+
+func parseRegistryAddress(address string) (*url.URL, error) {
+	if !strings.Contains(address, "://") {
+		scheme := "https"
+		host := strings.Split(address, ":")[0]
+		if host == "localhost" || host == "127.0.0.1" {
+			scheme = "http"
+		}
+		address = fmt.Sprintf("%s://%s", scheme, address)
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry address %q: %v", address, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid registry address %q: missing host", address)
+	}
+	return u, nil
+}
+
+// Catalog lists the repositories in the registry.
+func (c *Client) Catalog(ctx context.Context) ([]string, error) {
+	result := []string{}
+	next := fmt.Sprintf("/v2/_catalog?n=%d", catalogPageSize)
+	for next != "" {
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		var err error
+		next, err = c.getJSON(ctx, next, &page)
+		if err != nil {
+			return nil, fmt.Errorf("listing catalog: %v", err)
+		}
+		result = append(result, page.Repositories...)
+	}
+	return result, nil
+}
+
+// Tags lists the tags of a repository in the registry.
+func (c *Client) Tags(ctx context.Context, repository string) ([]string, error) {
+	result := []string{}
+	next := fmt.Sprintf("/v2/%s/tags/list", repository)
+	for next != "" {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		var err error
+		next, err = c.getJSON(ctx, next, &page)
+		if err != nil {
+			return nil, fmt.Errorf("listing tags of %s: %v", repository, err)
+		}
+		result = append(result, page.Tags...)
+	}
+	return result, nil
+}
+
+// Ping checks that the registry is serving the V2 API.
+func (c *Client) Ping(ctx context.Context) error {
+	resp, err := c.request(ctx, http.MethodGet, "/v2/", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return checkStatus(resp, http.StatusOK)
+}
+
+// GetManifest fetches a manifest by tag or digest.
+//
+// Returns the media type and the raw manifest, which must be preserved byte-for-byte
+// so that its digest doesn't change.
+func (c *Client) GetManifest(ctx context.Context, repository, reference string) (string, []byte, error) {
+	header := http.Header{"Accept": manifestMediaTypes}
+	resp, err := c.request(ctx, http.MethodGet, fmt.Sprintf("/v2/%s/manifests/%s", repository, reference), header, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	err = checkStatus(resp, http.StatusOK)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.Header.Get("Content-Type"), data, nil
+}
+
+// ManifestDigest looks up the digest of a manifest by tag, without fetching it.
+//
+// Returns an empty string if the manifest doesn't exist.
+func (c *Client) ManifestDigest(ctx context.Context, repository, reference string) (string, error) {
+	header := http.Header{"Accept": manifestMediaTypes}
+	resp, err := c.request(ctx, http.MethodHead, fmt.Sprintf("/v2/%s/manifests/%s", repository, reference), header, nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	err = checkStatus(resp, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+	return resp.Header.Get("Docker-Content-Digest"), nil
+}
+
+// PutManifest uploads a manifest under a tag or digest.
+func (c *Client) PutManifest(ctx context.Context, repository, reference, mediaType string, data []byte) error {
+	header := http.Header{"Content-Type": []string{mediaType}}
+	resp, err := c.request(ctx, http.MethodPut, fmt.Sprintf("/v2/%s/manifests/%s", repository, reference), header,
+		func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil })
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return checkStatus(resp, http.StatusCreated)
+}
+
+// DeleteManifest deletes a manifest by digest, along with every tag that points at it.
+//
+// The registry must have deletes enabled. Registries that ctlptl creates do.
+func (c *Client) DeleteManifest(ctx context.Context, repository, digest string) error {
+	resp, err := c.request(ctx, http.MethodDelete, fmt.Sprintf("/v2/%s/manifests/%s", repository, digest), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return checkStatus(resp, http.StatusAccepted)
+}
+
+// GetBlob copies a blob into w.
+func (c *Client) GetBlob(ctx context.Context, repository, digest string, w io.Writer) error {
+	resp, err := c.request(ctx, http.MethodGet, fmt.Sprintf("/v2/%s/blobs/%s", repository, digest), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	err = checkStatus(resp, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// BlobExists checks if the repository already has a blob.
+func (c *Client) BlobExists(ctx context.Context, repository, digest string) (bool, error) {
+	resp, err := c.request(ctx, http.MethodHead, fmt.Sprintf("/v2/%s/blobs/%s", repository, digest), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	err = checkStatus(resp, http.StatusOK)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PutBlob uploads a blob in a single request.
+//
+// The open func is called for each attempt, so that the upload can be retried with credentials.
+func (c *Client) PutBlob(ctx context.Context, repository, digest string, open func() (io.ReadCloser, error)) error {
+	resp, err := c.request(ctx, http.MethodPost, fmt.Sprintf("/v2/%s/blobs/uploads/", repository), nil, nil)
+	if err != nil {
+		return err
+	}
+	err = checkStatus(resp, http.StatusAccepted)
+	_ = resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("uploading blob %s: invalid upload location: %v", digest, err)
+	}
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	header := http.Header{"Content-Type": []string{"application/octet-stream"}}
+	resp, err = c.request(ctx, http.MethodPut, location.String(), header, open)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return checkStatus(resp, http.StatusCreated)
+}
+
+// Fetches the path and decodes the JSON response into v.
+//
+// Returns the path of the next page, if the response is paginated.
+func (c *Client) getJSON(ctx context.Context, path string, v interface{}) (string, error) {
+	resp, err := c.request(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	err = checkStatus(resp, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return "", fmt.Errorf("GET %s: decoding response: %v", path, err)
+	}
+	return nextLink(resp.Header.Get("Link")), nil
+}
+
+// Returns an error with the response body if the response status isn't one of the expected ones.
+func checkStatus(resp *http.Response, expected ...int) error {
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// Sends a request, and retries with credentials if the registry asks for them.
+//
+// The body func is called once per attempt, so that the body can be re-sent.
+func (c *Client) request(ctx context.Context, method, path string, header http.Header, body func() (io.ReadCloser, error)) (*http.Response, error) {
+	u, err := c.baseURL.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(ctx, method, u.String(), header, body, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+
+	scheme, params := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	var authorization string
+	switch scheme {
+	case "basic":
+		if c.username == "" {
+			return nil, fmt.Errorf("%s %s: registry requires credentials", method, path)
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(c.username, c.password)
+		authorization = req.Header.Get("Authorization")
+	case "bearer":
+		token, err := c.bearerToken(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		authorization = "Bearer " + token
+	default:
+		return nil, fmt.Errorf("%s %s: unsupported auth challenge %q", method, path, resp.Header.Get("WWW-Authenticate"))
+	}
+
+	return c.do(ctx, method, u.String(), header, body, authorization)
+}
+
+func (c *Client) do(ctx context.Context, method, u string, header http.Header, body func() (io.ReadCloser, error), authorization string) (*http.Response, error) {
+	err := egress.CheckURL(fmt.Sprintf("%s %s", method, u), u)
+	if err != nil {
+		return nil, err
+	}
+
+	var reqBody io.ReadCloser
+	if body != nil {
+		var err error
+		reqBody, err = body()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		if reqBody != nil {
+			_ = reqBody.Close()
+		}
+		return nil, err
+	}
+	if f, ok := reqBody.(*os.File); ok {
+		// Send a Content-Length instead of a chunked upload.
+		info, err := f.Stat()
+		if err == nil {
+			req.ContentLength = info.Size()
+		}
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return c.httpClient.Do(req)
+}
+
+// Fetches a token from the realm in the bearer challenge,
+// or reuses one we fetched earlier for the same challenge.
+func (c *Client) bearerToken(ctx context.Context, params map[string]string) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("bearer auth challenge missing realm")
+	}
+
+	key := strings.Join([]string{realm, params["service"], params["scope"]}, " ")
+	c.mu.Lock()
+	cached, ok := c.tokens[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.token, nil
+	}
+
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %q: %v", realm, err)
+	}
+	query := u.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		query.Set("scope", params["scope"])
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetching token: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching token from %s: %s", realm, resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return "", fmt.Errorf("decoding token: %v", err)
+	}
+	token := body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+	if token == "" {
+		return "", fmt.Errorf("fetching token from %s: empty token", realm)
+	}
+
+	// The spec says tokens without an expiry last 60 seconds.
+	expiresIn := body.ExpiresIn
+	if expiresIn <= 0 {
+		expiresIn = 60
+	}
+	c.mu.Lock()
+	c.tokens[key] = bearerToken{token: token, expires: time.Now().Add(time.Duration(expiresIn) * time.Second)}
+	c.mu.Unlock()
+	return token, nil
+}
+
+// Parses a WWW-Authenticate header, like:
+//
+//	Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="registry:catalog:*"
+//
+// Returns the lowercase scheme and the challenge parameters.
+func parseChallenge(header string) (string, map[string]string) {
+	header = strings.TrimSpace(header)
+	scheme, rest, _ := strings.Cut(header, " ")
+	params := make(map[string]string)
+	for {
+		rest = strings.TrimLeft(rest, " ,")
+		if rest == "" {
+			break
+		}
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end == -1 {
+				params[key] = value[1:]
+				break
+			}
+			params[key] = value[1 : end+1]
+			rest = value[end+2:]
+		} else {
+			value, rest, _ = strings.Cut(value, ",")
+			params[key] = strings.TrimSpace(value)
+		}
+	}
+	return strings.ToLower(scheme), params
+}
+
+// Parses the next page out of a Link header, like:
+//
+//	</v2/_catalog?last=foo&n=100>; rel="next"
+func nextLink(header string) string {
+	if header == "" || !strings.Contains(header, `rel="next"`) {
+		return ""
+	}
+	start := strings.Index(header, "<")
+	end := strings.Index(header, ">")
+	if start == -1 || end < start {
+		return ""
+	}
+	return header[start+1 : end]
+}
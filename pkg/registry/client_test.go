@@ -0,0 +1,120 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/internal/egress"
+)
+
+func TestClientCatalogBearerAuth(t *testing.T) {
+	tokenRequests := 0
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "me", user)
+		assert.Equal(t, "secret", pass)
+		assert.Equal(t, "test-registry", r.URL.Query().Get("service"))
+		assert.Equal(t, "registry:catalog:*", r.URL.Query().Get("scope"))
+		_, _ = fmt.Fprint(w, `{"token": "abc123", "expires_in": 300}`)
+	})
+	mux.HandleFunc("/v2/_catalog", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc123" {
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="test-registry",scope="registry:catalog:*"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/_catalog?last=a&n=100>; rel="next"`)
+			_, _ = fmt.Fprint(w, `{"repositories": ["a"]}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"repositories": ["b"]}`)
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "me", "secret")
+	require.NoError(t, err)
+
+	repos, err := client.Catalog(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, repos)
+	assert.Equal(t, 1, tokenRequests)
+}
+
+func TestClientTagsBasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "me" || pass != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Registry Realm"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "/v2/my-app/tags/list", r.URL.Path)
+		_, _ = fmt.Fprint(w, `{"name": "my-app", "tags": ["latest", "v1"]}`)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "me", "secret")
+	require.NoError(t, err)
+	tags, err := client.Tags(context.Background(), "my-app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"latest", "v1"}, tags)
+
+	client, err = NewClient(server.URL, "", "")
+	require.NoError(t, err)
+	_, err = client.Tags(context.Background(), "my-app")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "registry requires credentials")
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(
+		`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:samalba/my-app:pull,push"`)
+	assert.Equal(t, "bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:samalba/my-app:pull,push",
+	}, params)
+
+	scheme, params = parseChallenge(`Basic realm=registry`)
+	assert.Equal(t, "basic", scheme)
+	assert.Equal(t, map[string]string{"realm": "registry"}, params)
+}
+
+func TestParseRegistryAddress(t *testing.T) {
+	for address, expected := range map[string]string{
+		"localhost:5000":            "http://localhost:5000",
+		"registry.example.com":      "https://registry.example.com",
+		"http://registry.internal/": "http://registry.internal/",
+	} {
+		u, err := parseRegistryAddress(address)
+		if assert.NoError(t, err, address) {
+			assert.Equal(t, expected, u.String())
+		}
+	}
+}
+
+func TestClientNoNetworkEgress(t *testing.T) {
+	t.Setenv(egress.EnvVar, "true")
+
+	client, err := NewClient("registry.example.com", "", "")
+	require.NoError(t, err)
+	_, err = client.Catalog(context.Background())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "requires network egress")
+	}
+}
@@ -0,0 +1,39 @@
+package registry
+
+import (
+	"fmt"
+
+	"github.com/docker/cli/cli/config"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Returns a client for a local registry, through its port on the host.
+//
+// Uses the given credentials, if any. Otherwise, registries with auth use
+// the credentials in the Docker config (or its credential helper), since
+// ctlptl logs Docker in whenever it sets a password.
+func (c *Controller) hostClient(reg *api.Registry, username, password string) (*Client, error) {
+	if reg.Status.HostPort == 0 {
+		return nil, fmt.Errorf("registry %s is not listening on the host", reg.Name)
+	}
+	address := fmt.Sprintf("localhost:%d", reg.Status.HostPort)
+	if username != "" || reg.Status.Auth == nil {
+		return NewClient(address, username, password)
+	}
+
+	cfg, err := config.Load(c.dockerConfigDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading Docker credentials for %s: %v", address, err)
+	}
+	auth, err := cfg.GetAuthConfig(address)
+	if err != nil {
+		return nil, fmt.Errorf("reading Docker credentials for %s: %v", address, err)
+	}
+	if auth.Username != reg.Status.Auth.Username || auth.Password == "" {
+		return nil, fmt.Errorf("no Docker credentials for user %s of registry %s. "+
+			"Log in with 'docker login %s', or set a new password with 'ctlptl registry rotate-auth %s'",
+			reg.Status.Auth.Username, reg.Name, address, reg.Name)
+	}
+	return NewClient(address, auth.Username, auth.Password)
+}
@@ -0,0 +1,249 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Where the registry image reads its config.
+const registryConfigPath = "/etc/docker/registry/config.yml"
+
+// Where the registry image stores everything.
+const registryStoragePath = "/var/lib/registry"
+
+// How long to wait for a registry to serve requests after a restart.
+var registryReadyTimeout = 30 * time.Second
+
+type DefragmentResult struct {
+	// The number of tags copied into the fresh registry.
+	Images int
+
+	// Storage used by the registry, in bytes.
+	SizeBefore int64
+	SizeAfter  int64
+}
+
+// Defragment rebuilds the registry's storage from scratch.
+//
+// Puts the registry in read-only mode, exports all tagged images to a
+// temporary OCI layout on the host, and re-imports them into a new registry
+// container with fresh storage. Untagged and unreferenced blobs are dropped.
+//
+// The old container is kept (stopped) until the re-import succeeds. If anything
+// fails, the old container is restored.
+func (c *Controller) Defragment(ctx context.Context, name string) (*DefragmentResult, error) {
+	reg, err := c.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if reg.Status.State != containerStateRunning {
+		return nil, fmt.Errorf("registry %s is not running", name)
+	}
+	if replicaCount(reg) > 1 {
+		return nil, fmt.Errorf("registry %s has %d replicas. Defragmenting only supports single-container registries", name, reg.ReplicaCount)
+	}
+
+	client, err := c.hostClient(reg, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the config before we move the container out of the way.
+	desired, err := c.specFromStatus(ctx, reg)
+	if err != nil {
+		return nil, err
+	}
+	if desired.Auth != nil && desired.Auth.PasswordFrom == "" {
+		// Keep the password, so that we can import into the new registry.
+		desired.Auth.Password = client.password
+	}
+
+	sizeBefore, err := c.storageSize(ctx, reg.Status.ContainerID)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "ctlptl-defragment-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Putting registry %s in read-only mode\n", name)
+	err = c.setReadOnly(ctx, reg.Status.ContainerID)
+	if err != nil {
+		_ = c.restoreConfig(ctx, reg.Status.ContainerID)
+		return nil, err
+	}
+
+	err = waitForRegistry(ctx, client)
+	if err != nil {
+		_ = c.restoreConfig(ctx, reg.Status.ContainerID)
+		return nil, err
+	}
+
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Exporting images from registry %s\n", name)
+	exported, err := ExportOCILayout(ctx, client, tmpDir)
+	if err != nil {
+		_ = c.restoreConfig(ctx, reg.Status.ContainerID)
+		return nil, err
+	}
+
+	// Move the old container out of the way, so that the new one can take its name and port.
+	backupName := fmt.Sprintf("%s-ctlptl-defragment-backup", name)
+	err = c.docker(ctx, "stop", name)
+	if err == nil {
+		err = c.docker(ctx, "rename", name, backupName)
+	}
+	if err != nil {
+		_ = c.restoreConfig(ctx, reg.Status.ContainerID)
+		return nil, err
+	}
+
+	newReg, err := c.recreateWithFreshStorage(ctx, desired, client)
+	if err == nil {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Importing %d images into registry %s\n", exported, name)
+		_, err = ImportOCILayout(ctx, client, tmpDir)
+	}
+	if err != nil {
+		rollbackErr := c.rollbackDefragment(ctx, name, backupName, reg.Status.ContainerID)
+		if rollbackErr != nil {
+			return nil, fmt.Errorf("%v (restoring original registry: %v. It was left in container %s)", err, rollbackErr, backupName)
+		}
+		return nil, err
+	}
+
+	err = c.docker(ctx, "rm", "-f", "-v", backupName)
+	if err != nil {
+		return nil, err
+	}
+
+	sizeAfter, err := c.storageSize(ctx, newReg.Status.ContainerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DefragmentResult{
+		Images:     exported,
+		SizeBefore: sizeBefore,
+		SizeAfter:  sizeAfter,
+	}, nil
+}
+
+// Creates a new registry from the old one's config, without its storage.
+func (c *Controller) recreateWithFreshStorage(ctx context.Context, desired *api.Registry, client *Client) (*api.Registry, error) {
+	// Skip the rest of apply, so that we don't touch the registry's hosts.
+	newReg, err := c.applyContainer(ctx, desired, false)
+	if err != nil {
+		return nil, err
+	}
+	err = c.applyExposeOnHostPort(ctx, newReg, desired.ExposeOnHostPort)
+	if err != nil {
+		return nil, err
+	}
+	newReg.ExposeOnHostPort = desired.ExposeOnHostPort
+	return newReg, waitForRegistry(ctx, client)
+}
+
+// Removes the new registry container, and puts the old one back.
+func (c *Controller) rollbackDefragment(ctx context.Context, name, backupName, containerID string) error {
+	_ = c.docker(ctx, "rm", "-f", "-v", name)
+	err := c.docker(ctx, "rename", backupName, name)
+	if err != nil {
+		return err
+	}
+	err = c.docker(ctx, "start", name)
+	if err != nil {
+		return err
+	}
+	return c.restoreConfig(ctx, containerID)
+}
+
+// Enables the registry's read-only maintenance mode, and restarts it.
+// Keeps a copy of the original config to restore.
+func (c *Controller) setReadOnly(ctx context.Context, containerID string) error {
+	script := fmt.Sprintf(`set -e
+cp %[1]s %[1]s.ctlptl-backup
+awk '{print} /^storage:/{print "  maintenance:"; print "    readonly:"; print "      enabled: true"}' %[1]s.ctlptl-backup > %[1]s`,
+		registryConfigPath)
+	err := c.docker(ctx, "exec", containerID, "sh", "-c", script)
+	if err != nil {
+		return err
+	}
+	return c.docker(ctx, "restart", containerID)
+}
+
+// Restores the config saved by setReadOnly, and restarts the registry.
+func (c *Controller) restoreConfig(ctx context.Context, containerID string) error {
+	script := fmt.Sprintf(`[ ! -f %[1]s.ctlptl-backup ] || mv %[1]s.ctlptl-backup %[1]s`, registryConfigPath)
+	err := c.docker(ctx, "exec", containerID, "sh", "-c", script)
+	if err != nil {
+		return err
+	}
+	return c.docker(ctx, "restart", containerID)
+}
+
+// Returns the size of the registry storage, in bytes.
+func (c *Controller) storageSize(ctx context.Context, containerID string) (int64, error) {
+	out := bytes.NewBuffer(nil)
+	errOut := bytes.NewBuffer(nil)
+	err := c.runner.RunIO(ctx,
+		genericclioptions.IOStreams{Out: out, ErrOut: errOut},
+		"docker", "exec", containerID, "du", "-sk", registryStoragePath)
+	if err != nil {
+		return 0, fmt.Errorf("measuring registry storage: %v: %s", err, strings.TrimSpace(errOut.String()))
+	}
+
+	fields := strings.Fields(out.String())
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("measuring registry storage: unexpected output %q", out.String())
+	}
+	kb, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("measuring registry storage: %v", err)
+	}
+	return kb * 1024, nil
+}
+
+func (c *Controller) docker(ctx context.Context, args ...string) error {
+	_, err := c.dockerOutput(ctx, args...)
+	return err
+}
+
+func (c *Controller) dockerOutput(ctx context.Context, args ...string) (string, error) {
+	out := bytes.NewBuffer(nil)
+	errOut := bytes.NewBuffer(nil)
+	err := c.runner.RunIO(ctx, genericclioptions.IOStreams{Out: out, ErrOut: errOut}, "docker", args...)
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(errOut.String()))
+	}
+	return out.String(), nil
+}
+
+func waitForRegistry(ctx context.Context, client *Client) error {
+	deadline := time.Now().Add(registryReadyTimeout)
+	for {
+		err := client.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("waiting for registry: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+)
+
+func TestDefragment(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	server := newFakeRegistryServer(t)
+	server.seed()
+	tagsBefore := server.tags()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+
+	original := kindRegistry()
+	original.Ports[0].PublicPort = uint16(port)
+	f.docker.containers = []types.Container{original}
+	calls := defragmentFixture(f, server, original, port)
+
+	result, err := f.c.Defragment(context.Background(), "kind-registry")
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Images)
+	assert.Equal(t, int64(2048*1024), result.SizeBefore)
+	assert.Equal(t, int64(512*1024), result.SizeAfter)
+	assert.Equal(t, tagsBefore, server.tags())
+
+	commands := []string{}
+	for _, argv := range *calls {
+		commands = append(commands, strings.Join(argv[:3], " "))
+	}
+	assert.Contains(t, commands, "docker stop kind-registry")
+	assert.Contains(t, commands, "docker rename kind-registry")
+	assert.Equal(t, []string{"docker", "rm", "-f", "-v", "kind-registry-ctlptl-defragment-backup"}, (*calls)[len(*calls)-2])
+	assert.Equal(t, []string{"kind-registry"}, f.docker.networks["kind"])
+}
+
+func TestDefragmentKeepsHosts(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	hosts := `127.0.0.1 localhost
+# BEGIN ctlptl registry kind-registry
+127.0.0.1 registry.local
+# END ctlptl registry kind-registry
+`
+	path := f.c.hostsFile.Path
+	require.NoError(t, os.WriteFile(path, []byte(hosts), 0644))
+
+	server := newFakeRegistryServer(t)
+	server.seed()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+
+	original := kindRegistry()
+	original.Ports[0].PublicPort = uint16(port)
+	f.docker.containers = []types.Container{original}
+	defragmentFixture(f, server, original, port)
+
+	_, err = f.c.Defragment(context.Background(), "kind-registry")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, hosts, string(data))
+}
+
+func TestDefragmentAuth(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	server := newFakeRegistryServer(t)
+	server.seed()
+	server.username = "me"
+	server.password = "secret"
+	tagsBefore := server.tags()
+
+	original := authRegistry(t, "registry:2")
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+	original.Ports[0].PublicPort = uint16(port)
+	original.Labels[platformLabel] = "linux/arm64"
+	f.docker.containers = []types.Container{original}
+	f.docker.env = map[string][]string{original.ID: {httpSecretEnv + "=s3cr3t"}}
+	writeDockerCredentials(t, f, fmt.Sprintf("localhost:%d", port), "me", "secret")
+	defragmentFixture(f, server, original, port)
+
+	result, err := f.c.Defragment(context.Background(), "kind-registry")
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Images)
+	assert.Equal(t, tagsBefore, server.tags())
+
+	// The new registry has the same settings and password.
+	config := f.docker.lastCreateConfig
+	assert.Equal(t, "s3cr3t", envValue(config.Env, httpSecretEnv))
+	assert.True(t, htpasswdMatches(envValue(config.Env, htpasswdSeedEnv), "me", "secret"))
+	assert.Equal(t, "me", config.Labels[authUsernameLabel])
+	assert.Equal(t, "linux/arm64", config.Labels[platformLabel])
+}
+
+func TestDefragmentAuthNoCredentials(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{authRegistry(t, "registry:2")}
+	f.c.dockerConfigDir = t.TempDir()
+
+	_, err := f.c.Defragment(context.Background(), "kind-registry")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no Docker credentials for user me of registry kind-registry")
+	}
+}
+
+// Simulates a new container with fresh storage on the same port, and
+// returns the docker commands that run.
+func defragmentFixture(f *fixture, server *fakeRegistryServer, original types.Container, port int) *[][]string {
+	f.docker.onCreate = func() {
+		fresh := kindRegistry()
+		fresh.ID = "fresh-container-id"
+		fresh.Labels = f.docker.lastCreateConfig.Labels
+		fresh.Ports[0].PublicPort = uint16(port)
+		fresh.NetworkSettings.Networks = map[string]*network.EndpointSettings{
+			"bridge": fresh.NetworkSettings.Networks["bridge"],
+		}
+		f.docker.containers = append(f.docker.containers, fresh)
+		server.reset()
+	}
+
+	calls := [][]string{}
+	f.c.runner = exec.NewFakeCmdRunner(func(argv []string) string {
+		calls = append(calls, argv)
+		switch argv[1] {
+		case "rename":
+			for i, c := range f.docker.containers {
+				if c.Names[0] == "/"+argv[2] {
+					f.docker.containers[i].Names = []string{"/" + argv[3]}
+				}
+			}
+		case "exec":
+			if argv[3] == "du" {
+				if argv[2] == original.ID {
+					return "2048\t/var/lib/registry\n"
+				}
+				return "512\t/var/lib/registry\n"
+			}
+		}
+		return ""
+	})
+	return &calls
+}
+
+// Points the controller at a Docker config with credentials for the server.
+func writeDockerCredentials(t *testing.T, f *fixture, server, username, password string) {
+	dir := t.TempDir()
+	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	data := fmt.Sprintf(`{"auths": {%q: {"auth": %q}}}`, server, auth)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(data), 0600))
+	f.c.dockerConfigDir = dir
+}
+
+func TestDefragmentRollback(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	server := newFakeRegistryServer(t)
+	server.seed()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+
+	original := kindRegistry()
+	original.Ports[0].PublicPort = uint16(port)
+	f.docker.containers = []types.Container{original}
+	f.docker.onCreate = func() {
+		fresh := kindRegistry()
+		fresh.ID = "fresh-container-id"
+		fresh.Ports[0].PublicPort = uint16(port)
+		f.docker.containers = append(f.docker.containers, fresh)
+		server.reset()
+		server.failUploads = true
+	}
+
+	calls := []string{}
+	f.c.runner = exec.NewFakeCmdRunner(func(argv []string) string {
+		calls = append(calls, strings.Join(argv, " "))
+		if argv[1] == "rename" {
+			for i, c := range f.docker.containers {
+				if c.Names[0] == "/"+argv[2] {
+					f.docker.containers[i].Names = []string{"/" + argv[3]}
+				}
+			}
+		}
+		if argv[1] == "exec" && argv[3] == "du" {
+			return "2048\t/var/lib/registry\n"
+		}
+		return ""
+	})
+
+	_, err = f.c.Defragment(context.Background(), "kind-registry")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "503 Service Unavailable")
+	}
+	assert.Contains(t, calls, "docker rm -f -v kind-registry")
+	assert.Contains(t, calls, "docker rename kind-registry-ctlptl-defragment-backup kind-registry")
+	assert.Contains(t, calls, "docker start kind-registry")
+	assert.NotContains(t, calls, "docker rm -f -v kind-registry-ctlptl-defragment-backup")
+}
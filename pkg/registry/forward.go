@@ -0,0 +1,230 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"github.com/phayes/freeport"
+
+	"github.com/tilt-dev/ctlptl/internal/dctr"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Labels on the containers that forward a host port to a registry.
+const (
+	forwardOfLabel     = "dev.tilt.ctlptl.registry-forward-of"
+	forwardPortLabel   = "dev.tilt.ctlptl.registry-forward-port"
+	forwardTargetLabel = "dev.tilt.ctlptl.registry-forward-target"
+
+	// Set on the forwarder for exposeOnHostPort, which outlives ctlptl.
+	forwardExposeLabel = "dev.tilt.ctlptl.registry-expose"
+)
+
+const forwarderImage = "alpine/socat"
+
+func forwarderName(name string, hostPort int) string {
+	return fmt.Sprintf("%s-forward-%d", name, hostPort)
+}
+
+func validateExposeOnHostPort(desired *api.Registry) error {
+	port := desired.ExposeOnHostPort
+	if port < 0 || port > 65535 {
+		return fmt.Errorf("exposeOnHostPort must be between 1 and 65535. Actual: %d", port)
+	}
+	if port != 0 && port == desired.Port {
+		return fmt.Errorf("exposeOnHostPort must be different from port %d", desired.Port)
+	}
+	return nil
+}
+
+// Where a forwarder can reach the registry: a network that the registry is
+// on, and its address on that network.
+//
+// Prefers user-defined networks, where Docker resolves the registry by name,
+// so that the address doesn't change when the registry is re-created.
+func forwardTarget(reg *api.Registry) (string, string, error) {
+	port := reg.Status.ContainerPort
+	if port == 0 {
+		port = 5000
+	}
+	for _, n := range reg.Status.Networks {
+		if n != "bridge" && n != "host" && n != "none" {
+			return n, fmt.Sprintf("%s:%d", reg.Name, port), nil
+		}
+	}
+	if reg.Status.IPAddress != "" {
+		return "bridge", fmt.Sprintf("%s:%d", reg.Status.IPAddress, port), nil
+	}
+	return "", "", fmt.Errorf("registry %s isn't on a Docker network that a forwarder can reach", reg.Name)
+}
+
+// StartForwarder runs a container that listens on localhost:hostPort, and
+// forwards connections to the registry over a Docker network that it's on.
+// Useful for registries that are only reachable on an internal network.
+//
+// If hostPort is 0, picks a free port. If permanent, Docker restarts the
+// forwarder with the daemon, and it outlives ctlptl. Returns the name of
+// the forwarder container, and its host port.
+func (c *Controller) StartForwarder(ctx context.Context, name string, hostPort int, permanent bool) (string, int, error) {
+	reg, err := c.Get(ctx, name)
+	if err != nil {
+		return "", 0, err
+	}
+	if reg.Status.State != containerStateRunning {
+		return "", 0, fmt.Errorf("registry %s is not running", name)
+	}
+	return c.startForwarder(ctx, reg, hostPort, permanent)
+}
+
+func (c *Controller) startForwarder(ctx context.Context, reg *api.Registry, hostPort int, permanent bool) (string, int, error) {
+	networkName, target, err := forwardTarget(reg)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if hostPort == 0 {
+		hostPort, err = freeport.GetFreePort()
+		if err != nil {
+			return "", 0, fmt.Errorf("forwarding registry %s: %v", reg.Name, err)
+		}
+	}
+
+	labels := map[string]string{
+		forwardOfLabel:     reg.Name,
+		forwardPortLabel:   strconv.Itoa(hostPort),
+		forwardTargetLabel: target,
+	}
+	hostConfig := &container.HostConfig{
+		PortBindings: nat.PortMap{
+			"5000/tcp": []nat.PortBinding{{HostIP: "127.0.0.1", HostPort: strconv.Itoa(hostPort)}},
+		},
+	}
+	if permanent {
+		labels[forwardExposeLabel] = "true"
+		hostConfig.RestartPolicy = container.RestartPolicy{Name: "always"}
+	}
+
+	name := forwarderName(reg.Name, hostPort)
+	err = dctr.RemoveIfNecessary(ctx, c.dockerClient, name)
+	if err != nil {
+		return "", 0, err
+	}
+
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Forwarding localhost:%d to registry %q\n", hostPort, reg.Name)
+	err = dctr.Run(ctx, c.dockerClient, name,
+		&container.Config{
+			Hostname:     name,
+			Image:        forwarderImage,
+			Labels:       labels,
+			ExposedPorts: nat.PortSet{"5000/tcp": struct{}{}},
+			Cmd:          []string{"TCP-LISTEN:5000,fork,reuseaddr", "TCP:" + target},
+		},
+		hostConfig,
+		&network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{networkName: {}},
+		})
+	if err != nil {
+		return "", 0, fmt.Errorf("forwarding registry %s: %v", reg.Name, err)
+	}
+
+	err = c.maybeCreateForwarder(ctx, hostPort)
+	if err != nil {
+		return "", 0, err
+	}
+	return name, hostPort, nil
+}
+
+// StopForwarder removes a forwarder container started by StartForwarder.
+func (c *Controller) StopForwarder(ctx context.Context, containerName string) error {
+	return dctr.RemoveIfNecessary(ctx, c.dockerClient, containerName)
+}
+
+// The forwarder containers of all registries. If name isn't empty, only
+// returns the forwarders of that registry.
+func (c *Controller) forwarders(ctx context.Context, name string) ([]types.Container, error) {
+	label := forwardOfLabel
+	if name != "" {
+		label = fmt.Sprintf("%s=%s", forwardOfLabel, name)
+	}
+	return c.dockerClient.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", label)),
+		All:     true,
+	})
+}
+
+// Maps each registry name to the host port of its exposeOnHostPort forwarder.
+func exposedPorts(forwarders []types.Container) map[string]int {
+	result := make(map[string]int)
+	for _, f := range forwarders {
+		if f.Labels[forwardExposeLabel] != "true" {
+			continue
+		}
+		port, err := strconv.Atoi(f.Labels[forwardPortLabel])
+		if err != nil {
+			continue
+		}
+		result[f.Labels[forwardOfLabel]] = port
+	}
+	return result
+}
+
+// Makes the registry's exposeOnHostPort forwarder match its spec.
+//
+// Keeps a running forwarder on the same port that still points at the
+// registry. Removes the rest.
+func (c *Controller) applyExposeOnHostPort(ctx context.Context, reg *api.Registry, hostPort int) error {
+	forwarders, err := c.forwarders(ctx, reg.Name)
+	if err != nil {
+		return err
+	}
+
+	_, target, err := forwardTarget(reg)
+	if hostPort != 0 && err != nil {
+		return err
+	}
+
+	upToDate := false
+	for _, f := range forwarders {
+		if f.Labels[forwardExposeLabel] != "true" {
+			continue
+		}
+		if hostPort != 0 && !upToDate &&
+			f.Labels[forwardPortLabel] == strconv.Itoa(hostPort) &&
+			f.Labels[forwardTargetLabel] == target &&
+			f.State == containerStateRunning {
+			upToDate = true
+			continue
+		}
+		err := c.dockerClient.ContainerRemove(ctx, f.ID, types.ContainerRemoveOptions{Force: true})
+		if err != nil {
+			return err
+		}
+	}
+
+	if hostPort == 0 || upToDate {
+		return nil
+	}
+	_, _, err = c.startForwarder(ctx, reg, hostPort, true)
+	return err
+}
+
+// Removes every forwarder of the registry.
+func (c *Controller) deleteForwarders(ctx context.Context, name string) error {
+	forwarders, err := c.forwarders(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, f := range forwarders {
+		err := c.dockerClient.ContainerRemove(ctx, f.ID, types.ContainerRemoveOptions{Force: true})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestApplyExposeOnHostPort(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{kindRegistry()}
+	f.docker.onCreate = func() {
+		forwarder := types.Container{
+			ID:     "forwarder-id",
+			Names:  []string{"/kind-registry-forward-5055"},
+			Image:  forwarderImage,
+			Labels: f.docker.lastCreateConfig.Labels,
+			State:  "running",
+		}
+		f.docker.containers = append(f.docker.containers, forwarder)
+	}
+
+	registry, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta:         typeMeta,
+		Name:             "kind-registry",
+		ExposeOnHostPort: 5055,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 5055, registry.ExposeOnHostPort)
+
+	require.Len(t, f.docker.created, 1)
+	created := f.docker.created[0]
+	assert.Equal(t, "kind-registry-forward-5055", created.name)
+	assert.Equal(t, []string{"TCP-LISTEN:5000,fork,reuseaddr", "TCP:kind-registry:5000"}, []string(created.config.Cmd))
+	assert.Equal(t, "always", created.hostConfig.RestartPolicy.Name)
+	assert.Equal(t, "5055", created.hostConfig.PortBindings["5000/tcp"][0].HostPort)
+	assert.Contains(t, created.networkingConfig.EndpointsConfig, "kind")
+
+	registry, err = f.c.Get(context.Background(), "kind-registry")
+	require.NoError(t, err)
+	assert.Equal(t, 5055, registry.ExposeOnHostPort)
+
+	// The same port keeps the forwarder.
+	_, err = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta:         typeMeta,
+		Name:             "kind-registry",
+		ExposeOnHostPort: 5055,
+	})
+	require.NoError(t, err)
+	assert.Len(t, f.docker.created, 1)
+	assert.Equal(t, "", f.docker.lastRemovedContainer)
+
+	// Unsetting it removes the forwarder, but not the registry.
+	_, err = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+	})
+	require.NoError(t, err)
+	assert.Len(t, f.docker.created, 1)
+	assert.Equal(t, "forwarder-id", f.docker.lastRemovedContainer)
+}
+
+func TestForwardTarget(t *testing.T) {
+	reg := &api.Registry{
+		Name: "kind-registry",
+		Status: api.RegistryStatus{
+			IPAddress:     "172.0.1.2",
+			ContainerPort: 5000,
+			Networks:      []string{"bridge"},
+		},
+	}
+	network, target, err := forwardTarget(reg)
+	require.NoError(t, err)
+	assert.Equal(t, "bridge", network)
+	assert.Equal(t, "172.0.1.2:5000", target)
+
+	reg.Status.Networks = []string{"bridge", "kind"}
+	network, target, err = forwardTarget(reg)
+	require.NoError(t, err)
+	assert.Equal(t, "kind", network)
+	assert.Equal(t, "kind-registry:5000", target)
+}
+
+func TestValidateExposeOnHostPort(t *testing.T) {
+	assert.NoError(t, validateExposeOnHostPort(&api.Registry{ExposeOnHostPort: 5055}))
+
+	err := validateExposeOnHostPort(&api.Registry{Port: 5055, ExposeOnHostPort: 5055})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "exposeOnHostPort must be different from port 5055")
+	}
+}
@@ -0,0 +1,162 @@
+package registry
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Where the registry image stores blob data, by digest.
+const registryBlobsPath = "/var/lib/registry/docker/registry/v2/blobs"
+
+const gcEligibleBlobPrefix = "blob eligible for deletion: "
+
+var gcSummaryRe = regexp.MustCompile(`(\d+) blobs marked, (\d+) blobs and (\d+) manifests eligible for deletion`)
+
+type GarbageCollectOptions struct {
+	// Report what would be deleted, without deleting anything.
+	DryRun bool
+}
+
+type GarbageCollectResult struct {
+	DryRun bool `json:"dryRun"`
+
+	// The number of blobs still referenced by a manifest.
+	BlobsMarked int `json:"blobsMarked"`
+
+	// Blobs that aren't referenced by any manifest.
+	Blobs []GarbageCollectBlob `json:"blobs"`
+
+	// The number of manifests eligible for deletion.
+	Manifests int `json:"manifests"`
+
+	// The total size of the eligible blobs, in bytes.
+	ReclaimableBytes int64 `json:"reclaimableBytes"`
+}
+
+type GarbageCollectBlob struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// GarbageCollect deletes blobs that aren't referenced by any manifest.
+//
+// Always starts with a dry run, so that we can measure the blobs before
+// they're gone. When the registry is collected for real, it's put in
+// read-only mode so that a concurrent push can't lose blobs.
+func (c *Controller) GarbageCollect(ctx context.Context, name string, options GarbageCollectOptions) (*GarbageCollectResult, error) {
+	reg, err := c.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if reg.Status.State != containerStateRunning {
+		return nil, fmt.Errorf("registry %s is not running", name)
+	}
+	if replicaCount(reg) > 1 {
+		return nil, fmt.Errorf("registry %s has %d replicas. Garbage collection only supports single-container registries", name, reg.ReplicaCount)
+	}
+	containerID := reg.Status.ContainerID
+
+	out, err := c.dockerOutput(ctx, "exec", containerID, "registry", "garbage-collect", "--dry-run", registryConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	result, err := parseGarbageCollect(out)
+	if err != nil {
+		return nil, err
+	}
+	result.DryRun = options.DryRun
+
+	err = c.measureBlobs(ctx, containerID, result)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.DryRun || len(result.Blobs) == 0 {
+		return result, nil
+	}
+
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Putting registry %s in read-only mode\n", name)
+	err = c.setReadOnly(ctx, containerID)
+	if err == nil {
+		_, err = c.dockerOutput(ctx, "exec", containerID, "registry", "garbage-collect", registryConfigPath)
+	}
+	restoreErr := c.restoreConfig(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	if restoreErr != nil {
+		return nil, fmt.Errorf("restoring registry %s config: %v", name, restoreErr)
+	}
+	return result, nil
+}
+
+// Parses the output of `registry garbage-collect --dry-run`.
+func parseGarbageCollect(out string) (*GarbageCollectResult, error) {
+	result := &GarbageCollectResult{Blobs: []GarbageCollectBlob{}}
+	foundSummary := false
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, gcEligibleBlobPrefix) {
+			result.Blobs = append(result.Blobs, GarbageCollectBlob{Digest: strings.TrimPrefix(line, gcEligibleBlobPrefix)})
+			continue
+		}
+
+		match := gcSummaryRe.FindStringSubmatch(line)
+		if match != nil {
+			foundSummary = true
+			result.BlobsMarked, _ = strconv.Atoi(match[1])
+			result.Manifests, _ = strconv.Atoi(match[3])
+		}
+	}
+	if !foundSummary {
+		return nil, fmt.Errorf("unexpected output from registry garbage-collect: %s", strings.TrimSpace(out))
+	}
+	return result, nil
+}
+
+// Fills in the size of each blob, and the total.
+func (c *Controller) measureBlobs(ctx context.Context, containerID string, result *GarbageCollectResult) error {
+	if len(result.Blobs) == 0 {
+		return nil
+	}
+
+	args := []string{"exec", containerID, "stat", "-c", "%s %n"}
+	for _, blob := range result.Blobs {
+		algorithm, hex, ok := strings.Cut(blob.Digest, ":")
+		if !ok || len(hex) < 2 {
+			return fmt.Errorf("unexpected blob digest %q", blob.Digest)
+		}
+		args = append(args, path.Join(registryBlobsPath, algorithm, hex[:2], hex, "data"))
+	}
+	out, err := c.dockerOutput(ctx, args...)
+	if err != nil {
+		return err
+	}
+
+	sizes := make(map[string]int64, len(result.Blobs))
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		size, file, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return fmt.Errorf("measuring blob %s: %v", file, err)
+		}
+		dir := path.Dir(file)
+		sizes[path.Base(path.Dir(path.Dir(dir)))+":"+path.Base(dir)] = n
+	}
+
+	for i, blob := range result.Blobs {
+		result.Blobs[i].Size = sizes[blob.Digest]
+		result.ReclaimableBytes += sizes[blob.Digest]
+	}
+	return nil
+}
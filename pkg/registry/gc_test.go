@@ -0,0 +1,104 @@
+package registry
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+)
+
+const gcDryRunOutput = `team/app
+team/app: marking manifest sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+team/app: marking blob sha256:60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752
+
+1 blobs marked, 2 blobs and 0 manifests eligible for deletion
+blob eligible for deletion: sha256:fd61a03af4f77d870fc21e05e7e80678095c92d808cfb3b5c279ee04c74aca13
+blob eligible for deletion: sha256:a4e624d686e03ed2767c0abd85c14426b0b1157d2ce81d27bb4fe4f6f01d688a
+`
+
+const gcStatOutput = `1024 /var/lib/registry/docker/registry/v2/blobs/sha256/fd/fd61a03af4f77d870fc21e05e7e80678095c92d808cfb3b5c279ee04c74aca13/data
+2048 /var/lib/registry/docker/registry/v2/blobs/sha256/a4/a4e624d686e03ed2767c0abd85c14426b0b1157d2ce81d27bb4fe4f6f01d688a/data
+`
+
+func fakeGCRunner(calls *[]string) exec.CmdRunner {
+	return exec.NewFakeCmdRunner(func(argv []string) string {
+		*calls = append(*calls, strings.Join(argv, " "))
+		if len(argv) > 4 && argv[3] == "registry" {
+			return gcDryRunOutput
+		}
+		if len(argv) > 3 && argv[3] == "stat" {
+			return gcStatOutput
+		}
+		return ""
+	})
+}
+
+func TestGarbageCollectDryRun(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{kindRegistry()}
+	calls := []string{}
+	f.c.runner = fakeGCRunner(&calls)
+
+	result, err := f.c.GarbageCollect(context.Background(), "kind-registry", GarbageCollectOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, &GarbageCollectResult{
+		DryRun:      true,
+		BlobsMarked: 1,
+		Blobs: []GarbageCollectBlob{
+			{Digest: "sha256:fd61a03af4f77d870fc21e05e7e80678095c92d808cfb3b5c279ee04c74aca13", Size: 1024},
+			{Digest: "sha256:a4e624d686e03ed2767c0abd85c14426b0b1157d2ce81d27bb4fe4f6f01d688a", Size: 2048},
+		},
+		ReclaimableBytes: 3072,
+	}, result)
+
+	require.Len(t, calls, 2)
+	assert.Equal(t, "docker exec "+kindRegistry().ID+" registry garbage-collect --dry-run /etc/docker/registry/config.yml", calls[0])
+	for _, call := range calls {
+		assert.NotContains(t, call, "restart")
+	}
+}
+
+func TestGarbageCollect(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{kindRegistry()}
+	calls := []string{}
+	f.c.runner = fakeGCRunner(&calls)
+
+	result, err := f.c.GarbageCollect(context.Background(), "kind-registry", GarbageCollectOptions{})
+	require.NoError(t, err)
+	assert.False(t, result.DryRun)
+	assert.Equal(t, int64(3072), result.ReclaimableBytes)
+
+	id := kindRegistry().ID
+	assert.Contains(t, calls, "docker exec "+id+" registry garbage-collect /etc/docker/registry/config.yml")
+	assert.Contains(t, calls, "docker restart "+id)
+	assert.Contains(t, calls[len(calls)-2], ".ctlptl-backup")
+	assert.Equal(t, "docker restart "+id, calls[len(calls)-1])
+}
+
+func TestGarbageCollectNothingToDelete(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{kindRegistry()}
+	calls := []string{}
+	f.c.runner = exec.NewFakeCmdRunner(func(argv []string) string {
+		calls = append(calls, strings.Join(argv, " "))
+		return "3 blobs marked, 0 blobs and 0 manifests eligible for deletion\n"
+	})
+
+	result, err := f.c.GarbageCollect(context.Background(), "kind-registry", GarbageCollectOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.BlobsMarked)
+	assert.Empty(t, result.Blobs)
+	assert.Len(t, calls, 1)
+}
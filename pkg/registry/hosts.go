@@ -0,0 +1,53 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/tilt-dev/ctlptl/internal/hostsfile"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func validateHosts(hosts []string) error {
+	err := hostsfile.ValidateHostnames(hosts)
+	if err != nil {
+		return fmt.Errorf("hosts: %v", err)
+	}
+	return nil
+}
+
+// The IP that the registry's hostnames point at.
+//
+// Registries listen on all interfaces by default, so we use loopback
+// unless the registry listens on one specific address.
+func hostsIP(registry *api.Registry) string {
+	ip := net.ParseIP(registry.Status.ListenAddress)
+	if ip == nil || ip.IsUnspecified() {
+		return "127.0.0.1"
+	}
+	return ip.String()
+}
+
+// Replaces the registry's block of /etc/hosts. Removes the block if hosts is empty.
+//
+// Doesn't write /etc/hosts if the block is already up to date. If we don't
+// have permission to write it, prints the lines to add instead of failing.
+func (c *Controller) updateHosts(registry *api.Registry, hosts []string) error {
+	var entries []hostsfile.Entry
+	if len(hosts) > 0 {
+		entries = []hostsfile.Entry{{IP: hostsIP(registry), Hostnames: hosts}}
+	}
+
+	err := c.hostsFile.Set("registry "+registry.Name, entries)
+	var permErr *hostsfile.PermissionError
+	if errors.As(err, &permErr) {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Couldn't update %s for registry %s: permission denied. %s",
+			permErr.Path, registry.Name, permErr.Hint())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("updating hosts for registry %s: %v", registry.Name, err)
+	}
+	return nil
+}
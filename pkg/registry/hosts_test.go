@@ -0,0 +1,68 @@
+package registry
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestApplyHosts(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	path := f.c.hostsFile.Path
+	require.NoError(t, os.WriteFile(path, []byte("127.0.0.1 localhost\n"), 0644))
+
+	f.docker.onCreate = func() {
+		f.docker.containers = []types.Container{kindRegistry()}
+	}
+	_, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Port:     5001,
+		Hosts:    []string{"registry.local"},
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `127.0.0.1 localhost
+# BEGIN ctlptl registry kind-registry
+127.0.0.1 registry.local
+# END ctlptl registry kind-registry
+`, string(data))
+
+	err = f.c.Delete(context.Background(), "kind-registry")
+	require.NoError(t, err)
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1 localhost\n", string(data))
+}
+
+func TestApplyHostsWildcard(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	_, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Hosts:    []string{"*.local"},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "hosts: *.local: hosts files don't support wildcards")
+	}
+	assert.Nil(t, f.docker.lastCreateConfig)
+}
+
+func TestHostsIP(t *testing.T) {
+	assert.Equal(t, "127.0.0.1", hostsIP(&api.Registry{}))
+	assert.Equal(t, "127.0.0.1", hostsIP(&api.Registry{Status: api.RegistryStatus{ListenAddress: "0.0.0.0"}}))
+	assert.Equal(t, "192.168.1.5", hostsIP(&api.Registry{Status: api.RegistryStatus{ListenAddress: "192.168.1.5"}}))
+}
@@ -0,0 +1,67 @@
+package registry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// Where the registry image stores repositories. Every push writes
+// a link file under the repository's _manifests directory.
+const registryRepositoriesPath = "/var/lib/registry/docker/registry/v2/repositories"
+
+// GetLastPushed returns the time of the most recent push to the registry.
+//
+// Returns nil if nothing has been pushed to the registry.
+func (c *Controller) GetLastPushed(ctx context.Context, name string) (*time.Time, error) {
+	registry, err := c.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if registry.Status.State != containerStateRunning {
+		return nil, fmt.Errorf("registry %s is not running", name)
+	}
+
+	// The registry image is alpine, so we can rely on busybox find and stat.
+	script := fmt.Sprintf(
+		"[ -d %[1]s ] || exit 0; find %[1]s -path '*/_manifests/*' -name link -type f -exec stat -c %%Y {} +",
+		registryRepositoriesPath)
+	out := bytes.NewBuffer(nil)
+	errOut := bytes.NewBuffer(nil)
+	err = c.runner.RunIO(ctx,
+		genericclioptions.IOStreams{Out: out, ErrOut: errOut},
+		"docker", "exec", registry.Status.ContainerID, "sh", "-c", script)
+	if err != nil {
+		return nil, fmt.Errorf("reading registry %s storage: %v: %s", name, err, strings.TrimSpace(errOut.String()))
+	}
+
+	return parseLastModified(out.String())
+}
+
+// Parses the output of `stat -c %Y`, one unix timestamp per line,
+// and returns the latest.
+func parseLastModified(out string) (*time.Time, error) {
+	var latest *time.Time
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		secs, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing modification time %q: %v", line, err)
+		}
+		t := time.Unix(secs, 0)
+		if latest == nil || t.After(*latest) {
+			latest = &t
+		}
+	}
+	return latest, scanner.Err()
+}
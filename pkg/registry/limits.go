@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-units"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Labels that record the limits on the registry container,
+// so that we can report them without inspecting the container.
+const (
+	maxThreadsLabel     = "dev.tilt.ctlptl.registry-max-threads"
+	maxConnectionsLabel = "dev.tilt.ctlptl.registry-max-connections"
+)
+
+// The registry refuses to start with fewer threads.
+const minMaxThreads = 25
+
+// Fewer file descriptors than this starve the registry of connections.
+const minMaxConnections = 1024
+
+func validateLimits(limits *api.RegistryLimitsSpec) error {
+	if limits == nil {
+		return nil
+	}
+	if limits.MaxThreads < 0 || limits.MaxConnections < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	if limits.MaxThreads != 0 && limits.MaxThreads < minMaxThreads {
+		return fmt.Errorf("limits.maxThreads must be at least %d. Actual: %d", minMaxThreads, limits.MaxThreads)
+	}
+	if limits.MaxConnections != 0 && limits.MaxConnections < minMaxConnections {
+		return fmt.Errorf("limits.maxConnections must be at least %d. Actual: %d", minMaxConnections, limits.MaxConnections)
+	}
+	return nil
+}
+
+// The registry environment variables for the limits.
+func limitsEnv(limits *api.RegistryLimitsSpec) []string {
+	if limits == nil || limits.MaxThreads == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("REGISTRY_STORAGE_FILESYSTEM_MAXTHREADS=%d", limits.MaxThreads)}
+}
+
+// Raises the container's open file limit to fit the connections.
+func addLimits(hostConfig *container.HostConfig, limits *api.RegistryLimitsSpec) {
+	if limits == nil || limits.MaxConnections == 0 {
+		return
+	}
+	n := int64(limits.MaxConnections)
+	hostConfig.Ulimits = append(hostConfig.Ulimits, &units.Ulimit{Name: "nofile", Soft: n, Hard: n})
+}
+
+// Replaces the limit labels with the ones for the desired limits.
+func setLimitsLabels(labels map[string]string, limits *api.RegistryLimitsSpec) {
+	delete(labels, maxThreadsLabel)
+	delete(labels, maxConnectionsLabel)
+	if limits == nil {
+		return
+	}
+	if limits.MaxThreads != 0 {
+		labels[maxThreadsLabel] = strconv.Itoa(limits.MaxThreads)
+	}
+	if limits.MaxConnections != 0 {
+		labels[maxConnectionsLabel] = strconv.Itoa(limits.MaxConnections)
+	}
+}
+
+// Reads the limits from the registry container's labels.
+//
+// Returns nil if the registry uses the defaults.
+func limitsFromLabels(labels map[string]string) *api.RegistryLimitsSpec {
+	maxThreads, _ := strconv.Atoi(labels[maxThreadsLabel])
+	maxConnections, _ := strconv.Atoi(labels[maxConnectionsLabel])
+	if maxThreads == 0 && maxConnections == 0 {
+		return nil
+	}
+	return &api.RegistryLimitsSpec{MaxThreads: maxThreads, MaxConnections: maxConnections}
+}
+
+func limitsSpecsEqual(a, b *api.RegistryLimitsSpec) bool {
+	if a == nil {
+		a = &api.RegistryLimitsSpec{}
+	}
+	if b == nil {
+		b = &api.RegistryLimitsSpec{}
+	}
+	return *a == *b
+}
@@ -0,0 +1,76 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-units"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestApplyLimits(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.onCreate = func() {
+		registry := kindRegistry()
+		registry.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{registry}
+	}
+
+	limits := &api.RegistryLimitsSpec{MaxThreads: 200, MaxConnections: 16384}
+	registry, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Limits:   limits,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, limits, registry.Status.Limits)
+	assert.Equal(t, []string{"REGISTRY_STORAGE_FILESYSTEM_MAXTHREADS=200"}, f.docker.lastCreateConfig.Env[3:])
+	assert.Equal(t, []*units.Ulimit{{Name: "nofile", Soft: 16384, Hard: 16384}},
+		f.docker.lastCreateHostConfig.Ulimits)
+
+	// The same limits don't re-create the registry.
+	f.docker.lastCreateConfig = nil
+	_, err = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Limits:   limits.DeepCopy(),
+	})
+	require.NoError(t, err)
+	assert.Nil(t, f.docker.lastCreateConfig)
+
+	// Removing the limits re-creates the registry with the defaults.
+	registry, err = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+	})
+	require.NoError(t, err)
+	assert.Nil(t, registry.Status.Limits)
+	assert.Len(t, f.docker.lastCreateConfig.Env, 3)
+	assert.Empty(t, f.docker.lastCreateHostConfig.Ulimits)
+}
+
+func TestValidateLimits(t *testing.T) {
+	assert.NoError(t, validateLimits(nil))
+	assert.NoError(t, validateLimits(&api.RegistryLimitsSpec{MaxThreads: 25}))
+
+	err := validateLimits(&api.RegistryLimitsSpec{MaxThreads: 10})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "limits.maxThreads must be at least 25. Actual: 10")
+	}
+
+	err = validateLimits(&api.RegistryLimitsSpec{MaxConnections: 100})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "limits.maxConnections must be at least 1024. Actual: 100")
+	}
+
+	err = validateLimits(&api.RegistryLimitsSpec{MaxConnections: -1})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "limits must not be negative")
+	}
+}
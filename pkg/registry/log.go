@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Labels that record the log config on the registry container,
+// so that we can report it without inspecting the container.
+const (
+	logLevelLabel     = "dev.tilt.ctlptl.registry-log-level"
+	logFormatterLabel = "dev.tilt.ctlptl.registry-log-formatter"
+	accessLogLabel    = "dev.tilt.ctlptl.registry-access-log"
+	logFileLabel      = "dev.tilt.ctlptl.registry-log-file"
+)
+
+var logLabels = []string{logLevelLabel, logFormatterLabel, accessLogLabel, logFileLabel}
+
+var logLevels = []string{"error", "warn", "info", "debug"}
+var logFormatters = []string{"text", "json", "logstash"}
+
+// Where the log file's directory is mounted in the registry container.
+const logDirPath = "/var/log/ctlptl-registry"
+
+// The default registry image runs its config through this entrypoint.
+const registryEntrypoint = "/entrypoint.sh /etc/docker/registry/config.yml"
+
+func validateLog(desired *api.Registry) error {
+	log := desired.Log
+	if log == nil {
+		return nil
+	}
+	if log.Level != "" && !containsString(logLevels, log.Level) {
+		return fmt.Errorf("log.level must be one of: %s. Actual: %s", strings.Join(logLevels, ", "), log.Level)
+	}
+	if log.Formatter != "" && !containsString(logFormatters, log.Formatter) {
+		return fmt.Errorf("log.formatter must be one of: %s. Actual: %s", strings.Join(logFormatters, ", "), log.Formatter)
+	}
+	if log.File != "" {
+		if !filepath.IsAbs(log.File) {
+			return fmt.Errorf("log.file must be an absolute path. Actual: %s", log.File)
+		}
+		if replicaCount(desired) > 1 {
+			return fmt.Errorf("log.file isn't supported with replicaCount greater than 1")
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// The registry environment variables for the log config.
+func logEnv(log *api.RegistryLogSpec) []string {
+	if log == nil {
+		return nil
+	}
+	env := []string{}
+	if log.Level != "" {
+		env = append(env, "REGISTRY_LOG_LEVEL="+log.Level)
+	}
+	if log.Formatter != "" {
+		env = append(env, "REGISTRY_LOG_FORMATTER="+log.Formatter)
+	}
+	if log.AccessLogDisabled {
+		env = append(env, "REGISTRY_LOG_ACCESSLOG_DISABLED=true")
+	}
+	return env
+}
+
+// Replaces the log labels with the ones for the desired log config.
+func setLogLabels(labels map[string]string, log *api.RegistryLogSpec) {
+	for _, label := range logLabels {
+		delete(labels, label)
+	}
+	if log == nil {
+		return
+	}
+	if log.Level != "" {
+		labels[logLevelLabel] = log.Level
+	}
+	if log.Formatter != "" {
+		labels[logFormatterLabel] = log.Formatter
+	}
+	if log.AccessLogDisabled {
+		labels[accessLogLabel] = "disabled"
+	}
+	if log.File != "" {
+		labels[logFileLabel] = log.File
+	}
+}
+
+// Reads the log config from the registry container's labels.
+//
+// Returns nil if the registry uses the default log config.
+func logFromLabels(labels map[string]string) *api.RegistryLogSpec {
+	log := &api.RegistryLogSpec{
+		Level:             labels[logLevelLabel],
+		Formatter:         labels[logFormatterLabel],
+		AccessLogDisabled: labels[accessLogLabel] == "disabled",
+		File:              labels[logFileLabel],
+	}
+	if *log == (api.RegistryLogSpec{}) {
+		return nil
+	}
+	return log
+}
+
+func logSpecsEqual(a, b *api.RegistryLogSpec) bool {
+	if a == nil {
+		a = &api.RegistryLogSpec{}
+	}
+	if b == nil {
+		b = &api.RegistryLogSpec{}
+	}
+	return *a == *b
+}
+
+// Tees the registry's output to the log file, by wrapping the entrypoint
+// in a shell and bind-mounting the file's directory.
+func addLogFile(config *container.Config, hostConfig *container.HostConfig, log *api.RegistryLogSpec) {
+	if log == nil || log.File == "" {
+		return
+	}
+	dir, file := filepath.Split(log.File)
+	hostConfig.Binds = append(hostConfig.Binds, fmt.Sprintf("%s:%s", filepath.Clean(dir), logDirPath))
+	config.Entrypoint = []string{"/bin/sh", "-c"}
+	config.Cmd = []string{
+		fmt.Sprintf("%s 2>&1 | tee -a '%s'", registryEntrypoint, path.Join(logDirPath, file)),
+	}
+}
@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestApplyLog(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.onCreate = func() {
+		registry := kindRegistry()
+		registry.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{registry}
+	}
+
+	log := &api.RegistryLogSpec{
+		Level:             "debug",
+		Formatter:         "json",
+		AccessLogDisabled: true,
+		File:              "/var/log/ctlptl/registry.log",
+	}
+	registry, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Log:      log,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, log, registry.Status.Log)
+
+	config := f.docker.lastCreateConfig
+	assert.Equal(t, []string{
+		"REGISTRY_LOG_LEVEL=debug",
+		"REGISTRY_LOG_FORMATTER=json",
+		"REGISTRY_LOG_ACCESSLOG_DISABLED=true",
+	}, config.Env[3:])
+	assert.Equal(t, []string{"/bin/sh", "-c"}, []string(config.Entrypoint))
+	assert.Equal(t, []string{
+		"/entrypoint.sh /etc/docker/registry/config.yml 2>&1 | tee -a '/var/log/ctlptl-registry/registry.log'",
+	}, []string(config.Cmd))
+	assert.Equal(t, []string{"/var/log/ctlptl:/var/log/ctlptl-registry"}, f.docker.lastCreateHostConfig.Binds)
+
+	// The same config doesn't re-create the registry.
+	f.docker.lastCreateConfig = nil
+	_, err = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Log:      log.DeepCopy(),
+	})
+	require.NoError(t, err)
+	assert.Nil(t, f.docker.lastCreateConfig)
+
+	// Removing the config re-creates the registry with the defaults.
+	registry, err = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+	})
+	require.NoError(t, err)
+	assert.Nil(t, registry.Status.Log)
+	assert.Len(t, f.docker.lastCreateConfig.Env, 3)
+	assert.Nil(t, f.docker.lastCreateConfig.Cmd)
+	assert.Equal(t, registryLabels(), f.docker.lastCreateConfig.Labels)
+}
+
+func TestValidateLog(t *testing.T) {
+	err := validateLog(&api.Registry{Log: &api.RegistryLogSpec{Level: "trace"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "log.level must be one of: error, warn, info, debug. Actual: trace")
+	}
+
+	err = validateLog(&api.Registry{Log: &api.RegistryLogSpec{Formatter: "xml"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "log.formatter must be one of: text, json, logstash. Actual: xml")
+	}
+
+	err = validateLog(&api.Registry{Log: &api.RegistryLogSpec{File: "registry.log"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "log.file must be an absolute path. Actual: registry.log")
+	}
+
+	err = validateLog(&api.Registry{ReplicaCount: 2, Log: &api.RegistryLogSpec{File: "/tmp/registry.log"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "log.file isn't supported with replicaCount greater than 1")
+	}
+}
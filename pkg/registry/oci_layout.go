@@ -0,0 +1,303 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Manifest media types that we know how to walk.
+const (
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+var manifestMediaTypes = []string{
+	mediaTypeOCIIndex,
+	mediaTypeOCIManifest,
+	mediaTypeDockerManifestList,
+	mediaTypeDockerManifest,
+}
+
+// Annotations on the entries of an OCI layout index.json.
+//
+// The standard ref.name annotation only holds the tag. An OCI layout
+// has no notion of repositories, so we record the repository separately.
+const (
+	annotationRefName    = "org.opencontainers.image.ref.name"
+	annotationRepository = "dev.ctlptl.repository"
+)
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType,omitempty"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// The platform of an image in a multi-platform index.
+	Platform *ociPlatform `json:"platform,omitempty"`
+}
+
+type ociPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType,omitempty"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// The fields we need from any manifest or index.
+type ociManifest struct {
+	MediaType string          `json:"mediaType,omitempty"`
+	Config    *ociDescriptor  `json:"config,omitempty"`
+	Layers    []ociDescriptor `json:"layers,omitempty"`
+	Manifests []ociDescriptor `json:"manifests,omitempty"`
+}
+
+func isIndexMediaType(mediaType string) bool {
+	return mediaType == mediaTypeOCIIndex || mediaType == mediaTypeDockerManifestList
+}
+
+func blobPath(dir, digest string) (string, error) {
+	algorithm, hex, ok := strings.Cut(digest, ":")
+	if !ok || algorithm == "" || hex == "" || strings.ContainsAny(hex, `/\.`) {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+	return filepath.Join(dir, "blobs", algorithm, hex), nil
+}
+
+// ExportOCILayout copies every tagged image in the registry into an
+// OCI image layout directory.
+//
+// Returns the number of tags exported.
+func ExportOCILayout(ctx context.Context, client *Client, dir string) (int, error) {
+	err := os.MkdirAll(filepath.Join(dir, "blobs"), 0755)
+	if err != nil {
+		return 0, err
+	}
+	err = os.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	repos, err := client.Catalog(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	index := ociIndex{SchemaVersion: 2, MediaType: mediaTypeOCIIndex, Manifests: []ociDescriptor{}}
+	for _, repo := range repos {
+		tags, err := client.Tags(ctx, repo)
+		if err != nil {
+			return 0, err
+		}
+		for _, tag := range tags {
+			desc, err := exportManifest(ctx, client, dir, repo, tag)
+			if err != nil {
+				return 0, fmt.Errorf("exporting %s:%s: %v", repo, tag, err)
+			}
+			desc.Annotations = map[string]string{
+				annotationRefName:    tag,
+				annotationRepository: repo,
+			}
+			index.Manifests = append(index.Manifests, desc)
+		}
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return 0, err
+	}
+	err = os.WriteFile(filepath.Join(dir, "index.json"), data, 0644)
+	if err != nil {
+		return 0, err
+	}
+	return len(index.Manifests), nil
+}
+
+// Copies a manifest and everything it references into the layout.
+func exportManifest(ctx context.Context, client *Client, dir, repo, reference string) (ociDescriptor, error) {
+	mediaType, data, err := client.GetManifest(ctx, repo, reference)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+
+	var manifest ociManifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return ociDescriptor{}, fmt.Errorf("decoding manifest: %v", err)
+	}
+	if mediaType == "" || mediaType == "application/json" {
+		mediaType = manifest.MediaType
+	}
+
+	desc := ociDescriptor{
+		MediaType: mediaType,
+		Digest:    fmt.Sprintf("sha256:%x", sha256.Sum256(data)),
+		Size:      int64(len(data)),
+	}
+
+	if isIndexMediaType(mediaType) {
+		for _, child := range manifest.Manifests {
+			_, err := exportManifest(ctx, client, dir, repo, child.Digest)
+			if err != nil {
+				return ociDescriptor{}, err
+			}
+		}
+	} else {
+		blobs := append([]ociDescriptor{}, manifest.Layers...)
+		if manifest.Config != nil {
+			blobs = append(blobs, *manifest.Config)
+		}
+		for _, blob := range blobs {
+			err := exportBlob(ctx, client, dir, repo, blob.Digest)
+			if err != nil {
+				return ociDescriptor{}, err
+			}
+		}
+	}
+
+	path, err := blobPath(dir, desc.Digest)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	return desc, os.WriteFile(path, data, 0644)
+}
+
+func exportBlob(ctx context.Context, client *Client, dir, repo, digest string) error {
+	path, err := blobPath(dir, digest)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		// Shared with an image we already exported.
+		return nil
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file, so that an interrupted download doesn't
+	// look like a complete blob.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".download-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	err = client.GetBlob(ctx, repo, digest, tmp)
+	closeErr := tmp.Close()
+	if err != nil {
+		return fmt.Errorf("fetching blob %s: %v", digest, err)
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ImportOCILayout pushes every tagged image in an OCI layout directory
+// written by ExportOCILayout into the registry.
+//
+// Returns the number of tags imported.
+func ImportOCILayout(ctx context.Context, client *Client, dir string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return 0, err
+	}
+	var index ociIndex
+	err = json.Unmarshal(data, &index)
+	if err != nil {
+		return 0, fmt.Errorf("decoding index.json: %v", err)
+	}
+
+	for _, desc := range index.Manifests {
+		repo := desc.Annotations[annotationRepository]
+		tag := desc.Annotations[annotationRefName]
+		if repo == "" || tag == "" {
+			return 0, fmt.Errorf("index.json entry %s missing repository or tag annotations", desc.Digest)
+		}
+
+		err := importManifest(ctx, client, dir, repo, tag, desc)
+		if err != nil {
+			return 0, fmt.Errorf("importing %s:%s: %v", repo, tag, err)
+		}
+	}
+	return len(index.Manifests), nil
+}
+
+// Pushes everything a manifest references, then the manifest itself.
+func importManifest(ctx context.Context, client *Client, dir, repo, reference string, desc ociDescriptor) error {
+	path, err := blobPath(dir, desc.Digest)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var manifest ociManifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return fmt.Errorf("decoding manifest %s: %v", desc.Digest, err)
+	}
+
+	if isIndexMediaType(desc.MediaType) {
+		for _, child := range manifest.Manifests {
+			err := importManifest(ctx, client, dir, repo, child.Digest, child)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		blobs := append([]ociDescriptor{}, manifest.Layers...)
+		if manifest.Config != nil {
+			blobs = append(blobs, *manifest.Config)
+		}
+		for _, blob := range blobs {
+			err := importBlob(ctx, client, dir, repo, blob.Digest)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return client.PutManifest(ctx, repo, reference, desc.MediaType, data)
+}
+
+func importBlob(ctx context.Context, client *Client, dir, repo, digest string) error {
+	exists, err := client.BlobExists(ctx, repo, digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	path, err := blobPath(dir, digest)
+	if err != nil {
+		return err
+	}
+	err = client.PutBlob(ctx, repo, digest, func() (io.ReadCloser, error) { return os.Open(path) })
+	if err != nil {
+		return fmt.Errorf("uploading blob %s: %v", digest, err)
+	}
+	return nil
+}
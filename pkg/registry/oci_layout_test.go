@@ -0,0 +1,263 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOCILayoutRoundTrip(t *testing.T) {
+	src := newFakeRegistryServer(t)
+	src.seed()
+	dst := newFakeRegistryServer(t)
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	srcClient, err := NewClient(src.URL, "", "")
+	require.NoError(t, err)
+	exported, err := ExportOCILayout(ctx, srcClient, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, exported)
+
+	dstClient, err := NewClient(dst.URL, "", "")
+	require.NoError(t, err)
+	imported, err := ImportOCILayout(ctx, dstClient, dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, imported)
+
+	assert.Equal(t, src.tags(), dst.tags())
+	assert.Equal(t, src.manifests, dst.manifests)
+	assert.Equal(t, src.blobs, dst.blobs)
+}
+
+// An in-memory registry that implements enough of the
+// Docker Registry HTTP API V2 to push and pull images.
+type fakeRegistryServer struct {
+	*httptest.Server
+	t *testing.T
+
+	mu        sync.Mutex
+	manifests map[string]fakeManifest // keyed by repo@digest
+	tagged    map[string]string       // repo:tag -> digest
+	blobs     map[string][]byte       // keyed by digest
+	uploads   int
+
+	failUploads bool
+
+	// If set, requests need basic auth with these credentials.
+	username, password string
+}
+
+type fakeManifest struct {
+	mediaType string
+	data      []byte
+}
+
+func newFakeRegistryServer(t *testing.T) *fakeRegistryServer {
+	s := &fakeRegistryServer{t: t}
+	s.reset()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Returns the port that a test server listens on.
+func fakeServerPort(t *testing.T, serverURL string) uint16 {
+	u, err := url.Parse(serverURL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+	return uint16(port)
+}
+
+// Drops all images, like a registry with fresh storage.
+func (s *fakeRegistryServer) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.manifests = make(map[string]fakeManifest)
+	s.tagged = make(map[string]string)
+	s.blobs = make(map[string][]byte)
+}
+
+// Returns every repo:tag, sorted.
+func (s *fakeRegistryServer) tags() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []string{}
+	for ref := range s.tagged {
+		result = append(result, ref)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Adds two single-platform images, and a multi-platform image that shares their layers.
+func (s *fakeRegistryServer) seed() {
+	addBlob := func(content string) ociDescriptor {
+		data := []byte(content)
+		digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+		s.blobs[digest] = data
+		return ociDescriptor{Digest: digest, Size: int64(len(data))}
+	}
+	addManifest := func(repo, tag, mediaType string, v interface{}) ociDescriptor {
+		data, err := json.Marshal(v)
+		require.NoError(s.t, err)
+		digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+		s.manifests[repo+"@"+digest] = fakeManifest{mediaType: mediaType, data: data}
+		if tag != "" {
+			s.tagged[repo+":"+tag] = digest
+		}
+		return ociDescriptor{MediaType: mediaType, Digest: digest, Size: int64(len(data))}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	base := addBlob("base layer")
+	amd64 := addManifest("team/app", "", mediaTypeOCIManifest, ociManifest{
+		MediaType: mediaTypeOCIManifest,
+		Config:    &[]ociDescriptor{addBlob(`{"architecture":"amd64"}`)}[0],
+		Layers:    []ociDescriptor{base, addBlob("amd64 layer")},
+	})
+	arm64 := addManifest("team/app", "", mediaTypeOCIManifest, ociManifest{
+		MediaType: mediaTypeOCIManifest,
+		Config:    &[]ociDescriptor{addBlob(`{"architecture":"arm64"}`)}[0],
+		Layers:    []ociDescriptor{base, addBlob("arm64 layer")},
+	})
+	addManifest("team/app", "latest", mediaTypeOCIIndex, ociManifest{
+		MediaType: mediaTypeOCIIndex,
+		Manifests: []ociDescriptor{amd64, arm64},
+	})
+	addManifest("alpine", "3.16", mediaTypeDockerManifest, ociManifest{
+		MediaType: mediaTypeDockerManifest,
+		Config:    &[]ociDescriptor{addBlob(`{"os":"linux"}`)}[0],
+		Layers:    []ociDescriptor{base},
+	})
+	s.tagged["alpine:latest"] = s.tagged["alpine:3.16"]
+}
+
+func (s *fakeRegistryServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.username != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != s.username || password != s.password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case path == "":
+		w.WriteHeader(http.StatusOK)
+
+	case path == "_catalog":
+		repos := map[string]bool{}
+		for ref := range s.tagged {
+			repos[ref[:strings.LastIndex(ref, ":")]] = true
+		}
+		result := []string{}
+		for repo := range repos {
+			result = append(result, repo)
+		}
+		sort.Strings(result)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"repositories": result})
+
+	case strings.HasSuffix(path, "/tags/list"):
+		repo := strings.TrimSuffix(path, "/tags/list")
+		result := []string{}
+		for ref := range s.tagged {
+			if strings.HasPrefix(ref, repo+":") {
+				result = append(result, strings.TrimPrefix(ref, repo+":"))
+			}
+		}
+		sort.Strings(result)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": repo, "tags": result})
+
+	case strings.Contains(path, "/manifests/"):
+		repo, ref, _ := strings.Cut(path, "/manifests/")
+		if r.Method == http.MethodPut {
+			data, _ := io.ReadAll(r.Body)
+			digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+			s.manifests[repo+"@"+digest] = fakeManifest{mediaType: r.Header.Get("Content-Type"), data: data}
+			if !strings.HasPrefix(ref, "sha256:") {
+				s.tagged[repo+":"+ref] = digest
+			}
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		digest := ref
+		if !strings.HasPrefix(ref, "sha256:") {
+			digest = s.tagged[repo+":"+ref]
+		}
+		m, ok := s.manifests[repo+"@"+digest]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(s.manifests, repo+"@"+digest)
+			for tag, d := range s.tagged {
+				if d == digest && strings.HasPrefix(tag, repo+":") {
+					delete(s.tagged, tag)
+				}
+			}
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Header().Set("Docker-Content-Digest", digest)
+		_, _ = w.Write(m.data)
+
+	case strings.Contains(path, "/blobs/uploads/"):
+		repo, id, _ := strings.Cut(path, "/blobs/uploads/")
+		if s.failUploads {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method == http.MethodPost {
+			s.uploads++
+			w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%d", repo, s.uploads))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		assert.NotEmpty(s.t, id)
+		data, _ := io.ReadAll(r.Body)
+		digest := r.URL.Query().Get("digest")
+		assert.Equal(s.t, digest, fmt.Sprintf("sha256:%x", sha256.Sum256(data)))
+		s.blobs[digest] = data
+		w.WriteHeader(http.StatusCreated)
+
+	case strings.Contains(path, "/blobs/"):
+		_, digest, _ := strings.Cut(path, "/blobs/")
+		data, ok := s.blobs[digest]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		_, _ = w.Write(data)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -2,6 +2,9 @@ package registry
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/fields"
 
@@ -10,22 +13,57 @@ import (
 
 type ListOptions struct {
 	FieldSelector string
+
+	// Only list registries whose containers were created more than this long ago.
+	OlderThan time.Duration
+
+	// Only list registries whose container labels match this label
+	// selector (e.g., team=foo).
+	LabelSelector string
 }
 
 type registryFields api.Registry
 
+var registryFieldNames = []string{"name", "port", "status.state", "status.ready"}
+
 func (cf *registryFields) Has(field string) bool {
-	return field == "name"
+	for _, f := range registryFieldNames {
+		if f == field {
+			return true
+		}
+	}
+	return false
 }
 
 func (cf *registryFields) Get(field string) string {
-	if field == "name" {
-		return (*api.Registry)(cf).Name
-	}
-	if field == "port" {
-		return fmt.Sprintf("%d", (*api.Registry)(cf).Port)
+	registry := (*api.Registry)(cf)
+	switch field {
+	case "name":
+		return registry.Name
+	case "port":
+		return fmt.Sprintf("%d", registry.Port)
+	case "status.state":
+		return registry.Status.State
+	case "status.ready":
+		return strconv.FormatBool(registry.Status.State == "running")
 	}
 	return ""
 }
 
 var _ fields.Fields = &registryFields{}
+
+// Parses the selector, and rejects fields that we don't know about.
+func parseFieldSelector(s string) (fields.Selector, error) {
+	selector, err := fields.ParseSelector(s)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range selector.Requirements() {
+		if !(&registryFields{}).Has(r.Field) {
+			return nil, fmt.Errorf("field selector %q: unsupported field %q. Supported fields: %s",
+				s, r.Field, strings.Join(registryFieldNames, ", "))
+		}
+	}
+	return selector, nil
+}
@@ -0,0 +1,128 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/tilt-dev/ctlptl/internal/dctr"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Records the platform of the registry container, so that we can report it
+// without inspecting its image.
+const platformLabel = "dev.tilt.ctlptl.registry-platform"
+
+// The platforms that the default registry image is published for.
+var defaultImagePlatforms = []string{
+	"linux/386",
+	"linux/amd64",
+	"linux/arm/v6",
+	"linux/arm/v7",
+	"linux/arm64",
+	"linux/ppc64le",
+	"linux/s390x",
+}
+
+// Parses a platform like linux/arm64 or linux/arm/v7.
+func parsePlatform(platform string) (*specs.Platform, error) {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("platform must be OS/ARCH or OS/ARCH/VARIANT (e.g., linux/arm64). Actual: %q", platform)
+	}
+	for _, part := range parts {
+		if part == "" || strings.ToLower(part) != part || strings.ContainsAny(part, " \t") {
+			return nil, fmt.Errorf("platform must be OS/ARCH or OS/ARCH/VARIANT (e.g., linux/arm64). Actual: %q", platform)
+		}
+	}
+	result := &specs.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		result.Variant = parts[2]
+	}
+
+	// arm64 images only have one variant, so Docker leaves it off.
+	if result.Architecture == "arm64" && result.Variant == "v8" {
+		result.Variant = ""
+	}
+	return result, nil
+}
+
+func validatePlatform(desired *api.Registry) error {
+	if desired.Platform == "" {
+		return nil
+	}
+	platform, err := parsePlatform(desired.Platform)
+	if err != nil {
+		return err
+	}
+	if platform.OS != "linux" {
+		return fmt.Errorf("platform must be a linux platform. Actual: %s", desired.Platform)
+	}
+
+	// We only know the platforms of the default image. Docker checks the
+	// platforms of other images when it pulls them.
+	if imagesRefsEqual(desired.Image, DefaultRegistryImageRef) {
+		formatted := dctr.FormatPlatform(*platform)
+		if !containsString(defaultImagePlatforms, formatted) {
+			return fmt.Errorf("platform %s isn't available for image %s. Available: %s",
+				desired.Platform, desired.Image, strings.Join(defaultImagePlatforms, ", "))
+		}
+	}
+	return nil
+}
+
+// The platform to run the registry on.
+//
+// Defaults to the Docker daemon's native platform, so that the registry
+// doesn't run under emulation because Docker picked an image for another
+// platform (e.g., on Apple silicon). Returns nil to let Docker decide if we
+// can't tell what the native platform is, or the image may not support it.
+func (c *Controller) platformToUse(ctx context.Context, desired *api.Registry) *specs.Platform {
+	if desired.Platform != "" {
+		platform, err := parsePlatform(desired.Platform)
+		if err != nil {
+			return nil
+		}
+		return platform
+	}
+	if !imagesRefsEqual(desired.Image, DefaultRegistryImageRef) {
+		return nil
+	}
+
+	v, err := c.dockerClient.ServerVersion(ctx)
+	if err != nil {
+		klog.V(4).Infof("WARNING: reading Docker platform: %v\n", err)
+		return nil
+	}
+	platform := &specs.Platform{OS: v.Os, Architecture: v.Arch}
+	if !containsString(defaultImagePlatforms, dctr.FormatPlatform(*platform)) {
+		return nil
+	}
+	return platform
+}
+
+// Whether the existing registry runs on the desired platform.
+//
+// A registry without a desired platform matches any, so that we don't
+// re-create registries that older versions of ctlptl created.
+func platformMatches(existingLabels map[string]string, desired *api.Registry) bool {
+	if desired.Platform == "" {
+		return true
+	}
+	platform, err := parsePlatform(desired.Platform)
+	if err != nil {
+		return true
+	}
+	return existingLabels[platformLabel] == dctr.FormatPlatform(*platform)
+}
+
+func setPlatformLabel(labels map[string]string, platform *specs.Platform) {
+	if platform == nil {
+		delete(labels, platformLabel)
+		return
+	}
+	labels[platformLabel] = dctr.FormatPlatform(*platform)
+}
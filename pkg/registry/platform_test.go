@@ -0,0 +1,150 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestApplyPlatform(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.onCreate = func() {
+		existing := kindRegistry()
+		existing.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{existing}
+	}
+
+	registry, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Port:     5001,
+		Platform: "linux/arm/v7",
+	})
+	require.NoError(t, err)
+	require.Len(t, f.docker.created, 1)
+	assert.Equal(t, &specs.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, f.docker.created[0].platform)
+	assert.Equal(t, "linux/arm/v7", registry.Status.Platform)
+}
+
+func TestApplyPlatformDefaultsToNative(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.serverVersion = types.Version{Os: "linux", Arch: "arm64"}
+	f.docker.onCreate = func() {
+		existing := kindRegistry()
+		existing.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{existing}
+	}
+	_, err := f.c.Apply(context.Background(), &api.Registry{TypeMeta: typeMeta, Name: "kind-registry", Port: 5001})
+	require.NoError(t, err)
+	require.Len(t, f.docker.created, 1)
+	assert.Equal(t, &specs.Platform{OS: "linux", Architecture: "arm64"}, f.docker.created[0].platform)
+	assert.Equal(t, "linux/arm64", f.docker.lastCreateConfig.Labels[platformLabel])
+}
+
+func TestApplyPlatformCustomImageLetsDockerDecide(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.serverVersion = types.Version{Os: "linux", Arch: "arm64"}
+	f.docker.onCreate = func() {
+		existing := kindRegistry()
+		existing.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{existing}
+	}
+	_, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Port:     5001,
+		Image:    "fake.tilt.dev/my-registry-image:latest",
+	})
+	require.NoError(t, err)
+	require.Len(t, f.docker.created, 1)
+	assert.Nil(t, f.docker.created[0].platform)
+	assert.NotContains(t, f.docker.lastCreateConfig.Labels, platformLabel)
+}
+
+func TestApplyPlatformUnchanged(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	existing := kindRegistry()
+	existing.Labels[platformLabel] = "linux/arm64"
+	f.docker.containers = []types.Container{existing}
+
+	// arm64 has only one variant.
+	_, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Port:     5001,
+		Platform: "linux/arm64/v8",
+	})
+	require.NoError(t, err)
+	assert.Nil(t, f.docker.lastCreateConfig, "Registry should not have been re-created")
+}
+
+func TestApplyPlatformChangeKeepsVolume(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	existing := kindRegistry()
+	existing.Labels[platformLabel] = "linux/amd64"
+	f.docker.containers = []types.Container{existing}
+	f.docker.mounts = map[string][]types.MountPoint{
+		existing.ID: {{Type: mount.TypeVolume, Name: "3f2a9c", Destination: "/var/lib/registry"}},
+	}
+
+	_, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Port:     5001,
+		Platform: "linux/arm64",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, f.docker.lastRemovedContainer)
+	assert.Equal(t, []mount.Mount{
+		{Type: mount.TypeVolume, Source: "3f2a9c", Target: "/var/lib/registry"},
+	}, f.docker.lastCreateHostConfig.Mounts)
+	assert.Equal(t, "linux/arm64", f.docker.lastCreateConfig.Labels[platformLabel])
+}
+
+func TestValidatePlatform(t *testing.T) {
+	tests := []struct {
+		name     string
+		registry api.Registry
+		err      string
+	}{
+		{"default", api.Registry{Image: DefaultRegistryImageRef}, ""},
+		{"amd64", api.Registry{Image: DefaultRegistryImageRef, Platform: "linux/amd64"}, ""},
+		{"arm64 with variant", api.Registry{Image: DefaultRegistryImageRef, Platform: "linux/arm64/v8"}, ""},
+		{"no arch", api.Registry{Image: DefaultRegistryImageRef, Platform: "linux"},
+			`platform must be OS/ARCH or OS/ARCH/VARIANT (e.g., linux/arm64). Actual: "linux"`},
+		{"empty part", api.Registry{Image: DefaultRegistryImageRef, Platform: "linux//v7"},
+			`platform must be OS/ARCH or OS/ARCH/VARIANT (e.g., linux/arm64). Actual: "linux//v7"`},
+		{"windows", api.Registry{Image: DefaultRegistryImageRef, Platform: "windows/amd64"},
+			"platform must be a linux platform. Actual: windows/amd64"},
+		{"unavailable", api.Registry{Image: DefaultRegistryImageRef, Platform: "linux/riscv64"},
+			"platform linux/riscv64 isn't available for image docker.io/library/registry:2"},
+		{"custom image", api.Registry{Image: "fake.tilt.dev/my-registry-image:latest", Platform: "linux/riscv64"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePlatform(&tt.registry)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
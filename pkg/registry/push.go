@@ -0,0 +1,112 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+type PushOptions struct {
+	// A glob matched against the repository of each local image, or against
+	// repository:tag (e.g., dev/* or dev/*:latest).
+	Match string
+
+	// Report what would be pushed, without tagging or pushing anything.
+	DryRun bool
+}
+
+type PushResult struct {
+	DryRun bool `json:"dryRun"`
+
+	// The images pushed, in the order they were pushed.
+	Images []PushedImage `json:"images"`
+}
+
+type PushedImage struct {
+	// The local image, as repository:tag.
+	Source string `json:"source"`
+
+	// The image in the registry, as host:port/repository:tag.
+	Target string `json:"target"`
+}
+
+// Push re-tags local images that match a pattern for a local registry, and
+// pushes them to it.
+//
+// Images are pushed through the registry's port on the host, with the docker CLI.
+func (c *Controller) Push(ctx context.Context, name string, options PushOptions) (*PushResult, error) {
+	if options.Match == "" {
+		return nil, fmt.Errorf("pushing images: a pattern to match is required")
+	}
+	_, err := path.Match(options.Match, "")
+	if err != nil {
+		return nil, fmt.Errorf("pushing images: invalid pattern %q: %v", options.Match, err)
+	}
+
+	host, err := c.pushHost(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := c.dockerOutput(ctx, "image", "ls", "--format", "{{.Repository}}:{{.Tag}}")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PushResult{DryRun: options.DryRun, Images: []PushedImage{}}
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		image := strings.TrimSpace(line)
+		i := strings.LastIndex(image, ":")
+		if i == -1 {
+			continue
+		}
+		repo, tag := image[:i], image[i+1:]
+		if repo == "<none>" || tag == "<none>" || seen[image] || strings.HasPrefix(repo, host+"/") {
+			continue
+		}
+		seen[image] = true
+
+		repoMatch, _ := path.Match(options.Match, repo)
+		imageMatch, _ := path.Match(options.Match, image)
+		if !repoMatch && !imageMatch {
+			continue
+		}
+		result.Images = append(result.Images, PushedImage{
+			Source: image,
+			Target: fmt.Sprintf("%s/%s:%s", host, repoPath(repo), tag),
+		})
+	}
+	sort.Slice(result.Images, func(i, j int) bool {
+		return result.Images[i].Source < result.Images[j].Source
+	})
+
+	if options.DryRun {
+		return result, nil
+	}
+
+	for _, image := range result.Images {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Pushing %s to %s\n", image.Source, image.Target)
+		err := c.docker(ctx, "tag", image.Source, image.Target)
+		if err != nil {
+			return nil, err
+		}
+		err = c.docker(ctx, "push", image.Target)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Strips the registry host from a repository, the same way Docker decides
+// whether the first path component is a host.
+func repoPath(repo string) string {
+	first, rest, ok := strings.Cut(repo, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return rest
+	}
+	return repo
+}
@@ -0,0 +1,97 @@
+package registry
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+)
+
+const imageLsOutput = `dev/frontend:latest
+dev/backend:v2
+dev/backend:v2
+ghcr.io/dev/tools:latest
+localhost:5001/dev/frontend:latest
+nginx:1.25
+<none>:<none>
+`
+
+func fakePushRunner(calls *[]string) exec.CmdRunner {
+	return exec.NewFakeCmdRunner(func(argv []string) string {
+		*calls = append(*calls, strings.Join(argv, " "))
+		if len(argv) > 2 && argv[1] == "image" && argv[2] == "ls" {
+			return imageLsOutput
+		}
+		return ""
+	})
+}
+
+func TestPush(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{kindRegistry()}
+	calls := []string{}
+	f.c.runner = fakePushRunner(&calls)
+
+	result, err := f.c.Push(context.Background(), "kind-registry", PushOptions{Match: "dev/*"})
+	require.NoError(t, err)
+	assert.Equal(t, []PushedImage{
+		{Source: "dev/backend:v2", Target: "localhost:5001/dev/backend:v2"},
+		{Source: "dev/frontend:latest", Target: "localhost:5001/dev/frontend:latest"},
+	}, result.Images)
+	assert.Equal(t, []string{
+		"docker image ls --format {{.Repository}}:{{.Tag}}",
+		"docker tag dev/backend:v2 localhost:5001/dev/backend:v2",
+		"docker push localhost:5001/dev/backend:v2",
+		"docker tag dev/frontend:latest localhost:5001/dev/frontend:latest",
+		"docker push localhost:5001/dev/frontend:latest",
+	}, calls)
+}
+
+func TestPushDryRun(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{kindRegistry()}
+	calls := []string{}
+	f.c.runner = fakePushRunner(&calls)
+
+	result, err := f.c.Push(context.Background(), "kind-registry", PushOptions{Match: "*/*:latest", DryRun: true})
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []PushedImage{
+		{Source: "dev/frontend:latest", Target: "localhost:5001/dev/frontend:latest"},
+	}, result.Images)
+	assert.Equal(t, []string{"docker image ls --format {{.Repository}}:{{.Tag}}"}, calls)
+}
+
+func TestPushStripsRegistryHost(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{kindRegistry()}
+	calls := []string{}
+	f.c.runner = fakePushRunner(&calls)
+
+	result, err := f.c.Push(context.Background(), "kind-registry", PushOptions{Match: "ghcr.io/*/*", DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, []PushedImage{
+		{Source: "ghcr.io/dev/tools:latest", Target: "localhost:5001/dev/tools:latest"},
+	}, result.Images)
+}
+
+func TestPushInvalidPattern(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	_, err := f.c.Push(context.Background(), "kind-registry", PushOptions{Match: "dev/["})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid pattern "dev/["`)
+	}
+}
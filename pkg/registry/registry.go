@@ -2,6 +2,8 @@ package registry
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"sort"
 	"strings"
@@ -11,16 +13,20 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 	"github.com/phayes/freeport"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 
 	"github.com/tilt-dev/ctlptl/internal/dctr"
+	"github.com/tilt-dev/ctlptl/internal/exec"
+	"github.com/tilt-dev/ctlptl/internal/hostsfile"
 	"github.com/tilt-dev/ctlptl/internal/socat"
 	"github.com/tilt-dev/ctlptl/pkg/api"
 	"github.com/tilt-dev/ctlptl/pkg/docker"
@@ -34,6 +40,9 @@ var (
 
 const DefaultRegistryImageRef = "docker.io/library/registry:2" // The registry everyone uses.
 
+// The environment variable that configures the registry's HTTP secret.
+const httpSecretEnv = "REGISTRY_HTTP_SECRET"
+
 // https://github.com/moby/moby/blob/v20.10.3/api/types/types.go#L313
 const containerStateRunning = "running"
 
@@ -68,21 +77,40 @@ func FillDefaults(registry *api.Registry) {
 	}
 }
 
+type dockerClient interface {
+	dctr.Client
+	docker.DockerClient
+	NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error
+	ServerVersion(ctx context.Context) (types.Version, error)
+}
+
 type socatController interface {
 	ConnectRemoteDockerPort(ctx context.Context, port int) error
 }
 
 type Controller struct {
 	iostreams    genericclioptions.IOStreams
-	dockerClient dctr.Client
+	dockerClient dockerClient
 	socat        socatController
+	runner       exec.CmdRunner
+	hostsFile    *hostsfile.File
+
+	// Where to read Docker credentials from. Empty for the default.
+	dockerConfigDir string
+
+	// Where to write the registry configs with webhooks.
+	webhookConfigDir string
 }
 
-func NewController(iostreams genericclioptions.IOStreams, dockerClient dctr.Client) *Controller {
+func NewController(iostreams genericclioptions.IOStreams, dockerClient dockerClient) *Controller {
 	return &Controller{
 		iostreams:    iostreams,
 		dockerClient: dockerClient,
 		socat:        socat.NewController(dockerClient),
+		runner:       exec.RealCmdRunner{},
+		hostsFile:    hostsfile.Default(),
+
+		webhookConfigDir: defaultWebhookConfigDir(),
 	}
 }
 
@@ -96,6 +124,10 @@ func DefaultController(iostreams genericclioptions.IOStreams) (*Controller, erro
 		iostreams:    iostreams,
 		dockerClient: dockerClient,
 		socat:        socat.NewController(dockerClient),
+		runner:       exec.RealCmdRunner{},
+		hostsFile:    hostsfile.Default(),
+
+		webhookConfigDir: defaultWebhookConfigDir(),
 	}, nil
 }
 
@@ -114,7 +146,11 @@ func (c *Controller) Get(ctx context.Context, name string) (*api.Registry, error
 }
 
 func (c *Controller) List(ctx context.Context, options ListOptions) (*api.RegistryList, error) {
-	selector, err := fields.ParseSelector(options.FieldSelector)
+	selector, err := parseFieldSelector(options.FieldSelector)
+	if err != nil {
+		return nil, err
+	}
+	labelSelector, err := labels.Parse(options.LabelSelector)
 	if err != nil {
 		return nil, err
 	}
@@ -123,12 +159,25 @@ func (c *Controller) List(ctx context.Context, options ListOptions) (*api.Regist
 	if err != nil {
 		return nil, err
 	}
+	forwarders, err := c.forwarders(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	exposed := exposedPorts(forwarders)
 
+	now := time.Now()
 	result := []api.Registry{}
 	for _, container := range containers {
 		if len(container.Names) == 0 {
 			continue
 		}
+		if container.Labels[replicaOfLabel] != "" {
+			// Replicas are reported through their load balancer.
+			continue
+		}
+		if options.OlderThan > 0 && (container.Created == 0 || !time.Unix(container.Created, 0).Before(now.Add(-options.OlderThan))) {
+			continue
+		}
 		name := strings.TrimPrefix(container.Names[0], "/")
 		created := time.Unix(container.Created, 0)
 
@@ -149,9 +198,10 @@ func (c *Controller) List(ctx context.Context, options ListOptions) (*api.Regist
 		listenAddress, hostPort, containerPort := c.ipAndPortsFrom(container.Ports)
 
 		registry := &api.Registry{
-			TypeMeta: typeMeta,
-			Name:     name,
-			Port:     hostPort,
+			TypeMeta:         typeMeta,
+			Name:             name,
+			Port:             hostPort,
+			ExposeOnHostPort: exposed[name],
 			Status: api.RegistryStatus{
 				CreationTimestamp: metav1.Time{Time: created},
 				ContainerID:       container.ID,
@@ -163,12 +213,23 @@ func (c *Controller) List(ctx context.Context, options ListOptions) (*api.Regist
 				State:             container.State,
 				Labels:            container.Labels,
 				Image:             container.Image,
+				Platform:          container.Labels[platformLabel],
+				Log:               logFromLabels(container.Labels),
+				Limits:            limitsFromLabels(container.Labels),
+				Auth:              authFromLabels(container.Labels),
+				Webhook:           webhookFromLabels(container.Labels),
+				UploadPurge:       uploadPurgeFromLabels(container.Labels),
 			},
 		}
 
+		populateReplicas(registry, container.Labels)
+
 		if !selector.Matches((*registryFields)(registry)) {
 			continue
 		}
+		if !labelSelector.Matches(labels.Set(registry.Status.Labels)) {
+			continue
+		}
 		result = append(result, *registry)
 	}
 	return &api.RegistryList{
@@ -189,7 +250,74 @@ func (c *Controller) ipAndPortsFrom(ports []types.Port) (listenAddress string, h
 // Compare the desired registry against the existing registry, and reconcile
 // the two to match.
 func (c *Controller) Apply(ctx context.Context, desired *api.Registry) (*api.Registry, error) {
+	return c.apply(ctx, desired, false)
+}
+
+// If replace is true, always re-creates the registry container, keeping its storage.
+func (c *Controller) apply(ctx context.Context, desired *api.Registry, replace bool) (*api.Registry, error) {
 	FillDefaults(desired)
+	if desired.ReplicaCount < 0 {
+		return nil, fmt.Errorf("replicaCount must be at least 1. Actual: %d", desired.ReplicaCount)
+	}
+	err := validateLog(desired)
+	if err != nil {
+		return nil, err
+	}
+	err = validateLimits(desired.Limits)
+	if err != nil {
+		return nil, err
+	}
+	err = validateUploadPurge(desired.UploadPurge)
+	if err != nil {
+		return nil, err
+	}
+	err = validateAuth(desired.Auth)
+	if err != nil {
+		return nil, err
+	}
+	desired, err = resolveSecrets(desired)
+	if err != nil {
+		return nil, err
+	}
+	err = validateWebhook(desired)
+	if err != nil {
+		return nil, err
+	}
+	if warning := webhookWarning(desired.Webhook); warning != "" {
+		_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Warning: %s\n", warning)
+	}
+	err = validateExposeOnHostPort(desired)
+	if err != nil {
+		return nil, err
+	}
+	err = validateHosts(desired.Hosts)
+	if err != nil {
+		return nil, err
+	}
+	err = validatePlatform(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.applyContainer(ctx, desired, replace)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.updateHosts(result, desired.Hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.applyExposeOnHostPort(ctx, result, desired.ExposeOnHostPort)
+	if err != nil {
+		return nil, err
+	}
+	result.ExposeOnHostPort = desired.ExposeOnHostPort
+	return result, nil
+}
+
+func (c *Controller) applyContainer(ctx context.Context, desired *api.Registry, replace bool) (*api.Registry, error) {
 	existing, err := c.Get(ctx, desired.Name)
 	if err != nil && !errors.IsNotFound(err) {
 		return nil, err
@@ -199,6 +327,16 @@ func (c *Controller) Apply(ctx context.Context, desired *api.Registry) (*api.Reg
 		existing = &api.Registry{}
 	}
 
+	// Read the secret and credentials before we delete anything, so that we can carry them over.
+	existingSecret, err := c.httpSecret(ctx, existing)
+	if err != nil {
+		return nil, err
+	}
+	existingHtpasswd, err := c.htpasswd(ctx, existing)
+	if err != nil {
+		return nil, err
+	}
+
 	needsDelete := false
 	if existing.Port != 0 && desired.Port != 0 && existing.Port != desired.Port {
 		// If the port has changed, let's delete the registry and recreate it.
@@ -218,8 +356,57 @@ func (c *Controller) Apply(ctx context.Context, desired *api.Registry) (*api.Reg
 			needsDelete = true
 		}
 	}
+	if desired.HTTPSecret != "" && desired.HTTPSecret != existingSecret {
+		needsDelete = true
+	}
+	if existing.Name != "" && replicaCount(existing) != replicaCount(desired) {
+		needsDelete = true
+	}
+	if existing.Name != "" && !logSpecsEqual(existing.Status.Log, desired.Log) {
+		needsDelete = true
+	}
+	if existing.Name != "" && !limitsSpecsEqual(existing.Status.Limits, desired.Limits) {
+		needsDelete = true
+	}
+	if existing.Name != "" && !authMatches(existing.Status.Auth, existingHtpasswd, desired.Auth) {
+		needsDelete = true
+	}
+
+	// The registry only reads its notifications config when it starts, so a
+	// new webhook needs a new container. Keep the images that it stores.
+	keepStorage := false
+	if existing.Name != "" && !webhookMatches(existing.Status.Labels, desired.Webhook) {
+		needsDelete = true
+		keepStorage = existing.Status.ContainerID != "" &&
+			replicaCount(existing) == 1 && replicaCount(desired) == 1
+	}
+
+	// Switching platforms needs a new container, but the stored images
+	// don't depend on the registry's platform.
+	if existing.Name != "" && !platformMatches(existing.Status.Labels, desired) {
+		needsDelete = true
+		keepStorage = existing.Status.ContainerID != "" &&
+			replicaCount(existing) == 1 && replicaCount(desired) == 1
+	}
+
+	// Same for the upload purge config.
+	if existing.Name != "" && !uploadPurgeMatches(existing.Status.UploadPurge, desired.UploadPurge) {
+		needsDelete = true
+		keepStorage = existing.Status.ContainerID != "" &&
+			replicaCount(existing) == 1 && replicaCount(desired) == 1
+	}
+
+	var storage *mount.Mount
+	if replace || keepStorage {
+		storage, err = c.storageToKeep(ctx, existing, desired)
+		if err != nil {
+			return nil, err
+		}
+		needsDelete = true
+	}
+
 	if needsDelete && existing.Name != "" {
-		err = c.Delete(ctx, existing.Name)
+		err = c.deleteContainers(ctx, existing)
 		if err != nil {
 			return nil, err
 		}
@@ -228,8 +415,16 @@ func (c *Controller) Apply(ctx context.Context, desired *api.Registry) (*api.Reg
 	}
 
 	if existing.Status.ContainerID != "" {
-		// If we got to this point, and the container id exists, then the registry is up to date!
-		return existing, nil
+		// If we got to this point, and the container id exists, then the registry is up to date,
+		// except maybe for its networks, which we can connect without re-creating it.
+		if len(missingNetworks(desired, existing.Status.Networks)) == 0 {
+			return existing, nil
+		}
+		err = c.connectNetworks(ctx, desired, existing.Status.Networks)
+		if err != nil {
+			return nil, err
+		}
+		return c.Get(ctx, desired.Name)
 	}
 
 	_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Creating registry %q...\n", desired.Name)
@@ -244,22 +439,69 @@ func (c *Controller) Apply(ctx context.Context, desired *api.Registry) (*api.Reg
 		return nil, err
 	}
 
-	err = dctr.Run(
-		ctx,
-		c.dockerClient,
-		desired.Name,
-		&container.Config{
+	secret := desired.HTTPSecret
+	if secret == "" {
+		secret = existingSecret
+	}
+	if secret == "" {
+		secret, err = newHTTPSecret()
+		if err != nil {
+			return nil, fmt.Errorf("creating registry: %v", err)
+		}
+	}
+
+	// Always set the upload purge config, so that the registry cleans up
+	// aborted pushes even if its image's config doesn't.
+	env := []string{"REGISTRY_STORAGE_DELETE_ENABLED=true", httpSecretEnv + "=" + secret}
+	env = append(env, uploadPurgeEnv(desired.UploadPurge)...)
+	env = append(env, logEnv(desired.Log)...)
+	env = append(env, limitsEnv(desired.Limits)...)
+
+	htpasswd, newPassword, err := htpasswdToUse(desired, existing, existingHtpasswd)
+	if err != nil {
+		return nil, err
+	}
+	env = append(env, authEnv(desired.Auth, htpasswd)...)
+	webhookConfigFile := ""
+	if desired.Webhook != nil {
+		webhookConfigFile, err = c.writeWebhookConfig(desired.Name, desired.Webhook)
+	} else {
+		err = c.deleteWebhookConfig(desired.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	labels := c.labelConfigs(existing, desired)
+	platform := c.platformToUse(ctx, desired)
+	setPlatformLabel(labels, platform)
+	if replicaCount(desired) > 1 {
+		err = c.runReplicated(ctx, desired, env, labels, exposedPorts, portBindings, platform)
+	} else {
+		config := &container.Config{
 			Hostname:     desired.Name,
 			Image:        desired.Image,
 			ExposedPorts: exposedPorts,
-			Labels:       c.labelConfigs(existing, desired),
-			Env:          []string{"REGISTRY_STORAGE_DELETE_ENABLED=true"},
-		},
-		&container.HostConfig{
+			Labels:       labels,
+			Env:          env,
+		}
+		hostConfig := &container.HostConfig{
 			RestartPolicy: container.RestartPolicy{Name: "always"},
 			PortBindings:  portBindings,
-		},
-		&network.NetworkingConfig{})
+		}
+		if storage != nil {
+			hostConfig.Mounts = append(hostConfig.Mounts, *storage)
+		}
+		addLogFile(config, hostConfig, desired.Log)
+		addAuth(config, desired.Auth)
+		addWebhook(config, hostConfig, webhookConfigFile)
+		addLimits(hostConfig, desired.Limits)
+		err = dctr.RunWithPlatform(ctx, c.dockerClient, desired.Name, config, hostConfig, &network.NetworkingConfig{}, platform)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.connectNetworks(ctx, desired, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -269,9 +511,149 @@ func (c *Controller) Apply(ctx context.Context, desired *api.Registry) (*api.Reg
 		return nil, err
 	}
 
+	if newPassword != "" {
+		err = c.loginWhenReady(ctx, hostPort, desired.Auth.Username, newPassword)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return c.Get(ctx, desired.Name)
 }
 
+// The networks in the registry spec that the container isn't connected to.
+func missingNetworks(desired *api.Registry, connected []string) []string {
+	result := []string{}
+	for _, name := range desired.Networks {
+		found := false
+		for _, c := range connected {
+			if c == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// Connects the registry container to the networks in its spec, creating
+// any networks that don't exist yet.
+func (c *Controller) connectNetworks(ctx context.Context, desired *api.Registry, connected []string) error {
+	for _, name := range missingNetworks(desired, connected) {
+		_, err := docker.EnsureNetworkExists(ctx, c.dockerClient, name, docker.NetworkCreateOptions{
+			Labels: ctlptlLabels,
+		})
+		if err != nil {
+			return err
+		}
+		err = c.dockerClient.NetworkConnect(ctx, name, desired.Name, nil)
+		if err != nil {
+			return fmt.Errorf("connecting registry %s to network %s: %v", desired.Name, name, err)
+		}
+	}
+	return nil
+}
+
+// Returns a config that re-creates the registry as it runs now, for commands
+// that need a new container but don't have the user's config.
+//
+// The HTTP secret only lives in the container's environment, and the
+// webhook headers in its config file, so we read them from there.
+func (c *Controller) specFromStatus(ctx context.Context, existing *api.Registry) (*api.Registry, error) {
+	secret, err := c.httpSecret(ctx, existing)
+	if err != nil {
+		return nil, err
+	}
+	webhook := existing.Status.Webhook.DeepCopy()
+	if webhook != nil {
+		webhook.Headers, err = c.webhookHeaders(existing)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	labels := make(map[string]string, len(existing.Status.Labels))
+	for k, v := range existing.Status.Labels {
+		labels[k] = v
+	}
+
+	// Every container joins the default bridge network, so we only connect the rest.
+	networks := []string{}
+	for _, network := range existing.Status.Networks {
+		if network != "bridge" {
+			networks = append(networks, network)
+		}
+	}
+
+	return &api.Registry{
+		TypeMeta:         typeMeta,
+		Name:             existing.Name,
+		Port:             existing.Status.HostPort,
+		ListenAddress:    existing.Status.ListenAddress,
+		ExposeOnHostPort: existing.ExposeOnHostPort,
+		Labels:           labels,
+		Image:            existing.Status.Image,
+		Platform:         existing.Status.Platform,
+		ReplicaCount:     existing.ReplicaCount,
+		HTTPSecret:       secret,
+		Networks:         networks,
+		Log:              existing.Status.Log.DeepCopy(),
+		Limits:           existing.Status.Limits.DeepCopy(),
+		Auth:             existing.Status.Auth.DeepCopy(),
+		Webhook:          webhook,
+		UploadPurge:      existing.Status.UploadPurge.DeepCopy(),
+	}, nil
+}
+
+// Reads the HTTP secret from the environment of the existing registry container.
+//
+// Returns an empty string if there's no container, or it has no secret.
+func (c *Controller) httpSecret(ctx context.Context, existing *api.Registry) (string, error) {
+	return c.containerEnv(ctx, existing, httpSecretEnv)
+}
+
+// Reads an environment variable of the existing registry container.
+//
+// Returns an empty string if there's no container, or the variable isn't set.
+func (c *Controller) containerEnv(ctx context.Context, existing *api.Registry, name string) (string, error) {
+	if existing.Status.ContainerID == "" {
+		return "", nil
+	}
+	id := existing.Status.ContainerID
+	if replicaCount(existing) > 1 {
+		// The load balancer doesn't have the registry's environment, but the replicas share it.
+		id = replicaName(existing.Name, 1)
+	}
+	container, err := c.dockerClient.ContainerInspect(ctx, id)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if container.Config == nil {
+		return "", nil
+	}
+	for _, env := range container.Config.Env {
+		if strings.HasPrefix(env, name+"=") {
+			return strings.TrimPrefix(env, name+"="), nil
+		}
+	}
+	return "", nil
+}
+
+func newHTTPSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // Compute the ports to ContainerCreate() call
 func (c *Controller) portConfigs(existing *api.Registry, desired *api.Registry) (map[nat.Port]struct{}, map[nat.Port][]nat.PortBinding, int, error) {
 	// Preserve existing address by default
@@ -334,6 +716,12 @@ func (c *Controller) labelConfigs(existing *api.Registry, desired *api.Registry)
 		newLabels[k] = v
 	}
 
+	setLogLabels(newLabels, desired.Log)
+	setLimitsLabels(newLabels, desired.Limits)
+	setAuthLabels(newLabels, desired.Auth)
+	setWebhookLabels(newLabels, desired.Webhook)
+	setUploadPurgeLabels(newLabels, desired.UploadPurge)
+
 	return newLabels
 }
 
@@ -391,14 +779,36 @@ func (c *Controller) Delete(ctx context.Context, name string) error {
 		return err
 	}
 
+	err = c.deleteContainers(ctx, registry)
+	if err != nil {
+		return err
+	}
+	err = c.deleteForwarders(ctx, name)
+	if err != nil {
+		return err
+	}
+	err = c.deleteWebhookConfig(name)
+	if err != nil {
+		return err
+	}
+	return c.updateHosts(registry, nil)
+}
+
+// Deletes the registry's containers, but leaves its hosts entries,
+// so that it can be re-created.
+func (c *Controller) deleteContainers(ctx context.Context, registry *api.Registry) error {
 	cID := registry.Status.ContainerID
 	if cID == "" {
-		return fmt.Errorf("container not running registry: %s", name)
+		return fmt.Errorf("container not running registry: %s", registry.Name)
 	}
 
-	return c.dockerClient.ContainerRemove(ctx, registry.Status.ContainerID, types.ContainerRemoveOptions{
+	err := c.dockerClient.ContainerRemove(ctx, registry.Status.ContainerID, types.ContainerRemoveOptions{
 		Force: true,
 	})
+	if err != nil {
+		return err
+	}
+	return c.deleteReplicas(ctx, registry.Name)
 }
 
 // imageRefsEqual returns true of the normalized versions of the refs are equal.
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -18,9 +19,27 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 
+	"github.com/tilt-dev/ctlptl/internal/exec"
+	"github.com/tilt-dev/ctlptl/internal/hostsfile"
 	"github.com/tilt-dev/ctlptl/pkg/api"
 )
 
+// The labels of a registry that ctlptl created with the default config.
+func registryLabels() map[string]string {
+	return map[string]string{
+		"dev.tilt.ctlptl.role":                           "registry",
+		"dev.tilt.ctlptl.registry-upload-purge":          "enabled",
+		"dev.tilt.ctlptl.registry-upload-purge-age":      "168h",
+		"dev.tilt.ctlptl.registry-upload-purge-interval": "24h",
+	}
+}
+
+// The upload purge status of a registry with registryLabels().
+func defaultUploadPurge() *api.RegistryUploadPurgeSpec {
+	enabled := true
+	return &api.RegistryUploadPurgeSpec{Enabled: &enabled, Age: "168h", Interval: "24h"}
+}
+
 func kindRegistry() types.Container {
 	return types.Container{
 		ID:      "a815c0ec15f1f7430bd402e3fffe65026dd692a1a99861a52b3e30ad6e253a08",
@@ -29,7 +48,7 @@ func kindRegistry() types.Container {
 		ImageID: "sha256:2d4f4b5309b1e41b4f83ae59b44df6d673ef44433c734b14c1c103ebca82c116",
 		Command: "/entrypoint.sh /etc/docker/registry/config.yml",
 		Created: 1603483645,
-		Labels:  map[string]string{"dev.tilt.ctlptl.role": "registry"},
+		Labels:  registryLabels(),
 		Ports: []types.Port{
 			types.Port{IP: "127.0.0.1", PrivatePort: 5000, PublicPort: 5001, Type: "tcp"},
 		},
@@ -58,7 +77,7 @@ func kindRegistryLoopback() types.Container {
 		ImageID: "sha256:2d4f4b5309b1e41b4f83ae59b44df6d673ef44433c734b14c1c103ebca82c116",
 		Command: "/entrypoint.sh /etc/docker/registry/config.yml",
 		Created: 1603483646,
-		Labels:  map[string]string{"dev.tilt.ctlptl.role": "registry"},
+		Labels:  registryLabels(),
 		Ports: []types.Port{
 			types.Port{IP: "127.0.0.1", PrivatePort: 5000, PublicPort: 5001, Type: "tcp"},
 		},
@@ -87,7 +106,7 @@ func kindRegistryCustomImage() types.Container {
 		ImageID: "sha256:0ac33e5f5afa79e084075e8698a22d574816eea8d7b7d480586835657c3e1c8b",
 		Command: "/entrypoint.sh /etc/docker/registry/config.yml",
 		Created: 1603483647,
-		Labels:  map[string]string{"dev.tilt.ctlptl.role": "registry"},
+		Labels:  registryLabels(),
 		Ports: []types.Port{
 			types.Port{IP: "127.0.0.1", PrivatePort: 5000, PublicPort: 5001, Type: "tcp"},
 		},
@@ -136,8 +155,9 @@ func TestListRegistries(t *testing.T) {
 			Networks:          []string{"bridge", "kind"},
 			ContainerID:       "a815c0ec15f1f7430bd402e3fffe65026dd692a1a99861a52b3e30ad6e253a08",
 			State:             "running",
-			Labels:            map[string]string{"dev.tilt.ctlptl.role": "registry"},
+			Labels:            registryLabels(),
 			Image:             "registry:2",
+			UploadPurge:       defaultUploadPurge(),
 		},
 	}, list.Items[0])
 	assert.Equal(t, api.Registry{
@@ -153,8 +173,9 @@ func TestListRegistries(t *testing.T) {
 			Networks:          []string{"bridge", "kind"},
 			ContainerID:       "c7f123e65474f951c3bc4232c888616c0f9b1052c7ae706a3b6d4701bea6e90d",
 			State:             "running",
-			Labels:            map[string]string{"dev.tilt.ctlptl.role": "registry"},
+			Labels:            registryLabels(),
 			Image:             "fake.tilt.dev/my-registry-image:latest",
+			UploadPurge:       defaultUploadPurge(),
 		},
 	}, list.Items[1])
 	assert.Equal(t, api.Registry{
@@ -175,6 +196,63 @@ func TestListRegistries(t *testing.T) {
 	}, list.Items[2])
 }
 
+func TestListRegistriesOlderThan(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	newRegistry := kindRegistryLoopback()
+	newRegistry.Created = time.Now().Add(-time.Minute).Unix()
+	f.docker.containers = []types.Container{kindRegistry(), newRegistry}
+
+	list, err := f.c.List(context.Background(), ListOptions{OlderThan: time.Hour})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "kind-registry", list.Items[0].Name)
+}
+
+func TestListRegistriesLabelSelector(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	team := kindRegistryLoopback()
+	team.Labels = map[string]string{"dev.tilt.ctlptl.role": "registry", "team": "foo"}
+	f.docker.containers = []types.Container{kindRegistry(), team}
+
+	list, err := f.c.List(context.Background(), ListOptions{LabelSelector: "team=foo"})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "kind-registry-loopback", list.Items[0].Name)
+
+	list, err = f.c.List(context.Background(), ListOptions{LabelSelector: "!team"})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "kind-registry", list.Items[0].Name)
+}
+
+func TestListRegistriesFieldSelector(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	stopped := kindRegistryLoopback()
+	stopped.State = "exited"
+	f.docker.containers = []types.Container{kindRegistry(), stopped}
+
+	list, err := f.c.List(context.Background(), ListOptions{FieldSelector: "status.ready=true"})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "kind-registry", list.Items[0].Name)
+
+	list, err = f.c.List(context.Background(), ListOptions{FieldSelector: "status.state!=running"})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "kind-registry-loopback", list.Items[0].Name)
+
+	_, err = f.c.List(context.Background(), ListOptions{FieldSelector: "status.color=blue"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `unsupported field "status.color"`)
+	}
+}
+
 func TestGetRegistry(t *testing.T) {
 	f := newFixture(t)
 	defer f.TearDown()
@@ -196,12 +274,42 @@ func TestGetRegistry(t *testing.T) {
 			Networks:          []string{"bridge", "kind"},
 			ContainerID:       "a815c0ec15f1f7430bd402e3fffe65026dd692a1a99861a52b3e30ad6e253a08",
 			State:             "running",
-			Labels:            map[string]string{"dev.tilt.ctlptl.role": "registry"},
+			Labels:            registryLabels(),
 			Image:             "registry:2",
+			UploadPurge:       defaultUploadPurge(),
 		},
 	}, registry)
 }
 
+func TestGetLastPushed(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{kindRegistry()}
+	runner := exec.NewFakeCmdRunner(func(argv []string) string {
+		return "1603483645\n1603487245\n1603480045\n"
+	})
+	f.c.runner = runner
+
+	lastPushed, err := f.c.GetLastPushed(context.Background(), "kind-registry")
+	require.NoError(t, err)
+	require.NotNil(t, lastPushed)
+	assert.Equal(t, time.Unix(1603487245, 0), *lastPushed)
+	assert.Equal(t, []string{"docker", "exec", kindRegistry().ID, "sh", "-c"}, runner.LastArgs[:5])
+}
+
+func TestGetLastPushedEmpty(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{kindRegistry()}
+	f.c.runner = exec.NewFakeCmdRunner(func(argv []string) string { return "" })
+
+	lastPushed, err := f.c.GetLastPushed(context.Background(), "kind-registry")
+	require.NoError(t, err)
+	assert.Nil(t, lastPushed)
+}
+
 func TestApplyDeadRegistry(t *testing.T) {
 	f := newFixture(t)
 	defer f.TearDown()
@@ -232,6 +340,7 @@ func TestApplyLabels(t *testing.T) {
 	// Make sure the previous registry is wiped out
 	// because it doesn't have the labels we want.
 	f.docker.containers = []types.Container{kindRegistry()}
+	f.docker.env = map[string][]string{kindRegistry().ID: {"REGISTRY_HTTP_SECRET=existing-secret"}}
 
 	f.docker.onCreate = func() {
 		f.docker.containers = []types.Container{kindRegistry()}
@@ -247,13 +356,16 @@ func TestApplyLabels(t *testing.T) {
 	}
 	config := f.docker.lastCreateConfig
 	if assert.NotNil(t, config) {
-		assert.Equal(t, map[string]string{
-			"managed-by":           "ctlptl",
-			"dev.tilt.ctlptl.role": "registry",
-		}, config.Labels)
+		labels := registryLabels()
+		labels["managed-by"] = "ctlptl"
+		assert.Equal(t, labels, config.Labels)
 		assert.Equal(t, "kind-registry", config.Hostname)
 		assert.Equal(t, "docker.io/library/registry:2", config.Image)
-		assert.Equal(t, []string{"REGISTRY_STORAGE_DELETE_ENABLED=true"}, config.Env)
+		assert.Equal(t, []string{
+			"REGISTRY_STORAGE_DELETE_ENABLED=true",
+			"REGISTRY_HTTP_SECRET=existing-secret",
+			"REGISTRY_STORAGE_MAINTENANCE_UPLOADPURGING={enabled: true, age: 168h, interval: 24h, dryrun: false}",
+		}, config.Env)
 	}
 }
 
@@ -281,7 +393,7 @@ func TestPreservePort(t *testing.T) {
 
 	config := f.docker.lastCreateConfig
 	if assert.NotNil(t, config) {
-		assert.Equal(t, map[string]string{"dev.tilt.ctlptl.role": "registry"}, config.Labels)
+		assert.Equal(t, registryLabels(), config.Labels)
 		assert.Equal(t, "kind-registry", config.Hostname)
 		assert.Equal(t, "docker.io/library/registry:2", config.Image)
 	}
@@ -320,18 +432,112 @@ func TestCustomImage(t *testing.T) {
 	}
 	config := f.docker.lastCreateConfig
 	if assert.NotNil(t, config) {
-		assert.Equal(t, map[string]string{"dev.tilt.ctlptl.role": "registry"}, config.Labels)
+		assert.Equal(t, registryLabels(), config.Labels)
 		assert.Equal(t, "kind-registry", config.Hostname)
 		assert.Equal(t, "fake.tilt.dev/different-registry-image:latest", config.Image)
 	}
 }
 
+func TestApplyHTTPSecret(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{kindRegistry()}
+	f.docker.env = map[string][]string{kindRegistry().ID: {"REGISTRY_HTTP_SECRET=old-secret"}}
+	f.docker.onCreate = func() {
+		f.docker.containers = []types.Container{kindRegistry()}
+		f.docker.env[kindRegistry().ID] = f.docker.lastCreateConfig.Env
+	}
+
+	// The same secret doesn't re-create the registry.
+	desired := &api.Registry{TypeMeta: typeMeta, Name: "kind-registry", HTTPSecret: "old-secret"}
+	_, err := f.c.Apply(context.Background(), desired)
+	require.NoError(t, err)
+	assert.Nil(t, f.docker.lastCreateConfig)
+
+	// A new secret does.
+	desired.HTTPSecret = "new-secret"
+	registry, err := f.c.Apply(context.Background(), desired)
+	require.NoError(t, err)
+	assert.Equal(t, kindRegistry().ID, f.docker.lastRemovedContainer)
+	assert.Contains(t, f.docker.lastCreateConfig.Env, "REGISTRY_HTTP_SECRET=new-secret")
+	assert.Equal(t, "", registry.HTTPSecret)
+}
+
+func TestApplyGeneratesHTTPSecret(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.onCreate = func() {
+		f.docker.containers = []types.Container{kindRegistry()}
+	}
+
+	_, err := f.c.Apply(context.Background(), &api.Registry{TypeMeta: typeMeta, Name: "kind-registry"})
+	require.NoError(t, err)
+	require.Len(t, f.docker.lastCreateConfig.Env, 3)
+	assert.Regexp(t, "^REGISTRY_HTTP_SECRET=[0-9a-f]{64}$", f.docker.lastCreateConfig.Env[1])
+}
+
+func TestApplyNetworks(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.onCreate = func() {
+		f.docker.containers = []types.Container{kindRegistry()}
+	}
+
+	_, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Networks: []string{"ci"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"ci": {"kind-registry"}}, f.docker.networks)
+	assert.Len(t, f.docker.created, 1)
+
+	// Adding a network connects the registry without re-creating it.
+	_, err = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Networks: []string{"ci", "kind", "e2e"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, f.docker.created, 1)
+	assert.Equal(t, []string{"kind-registry"}, f.docker.networks["e2e"])
+
+	// The registry was already on the kind network.
+	assert.NotContains(t, f.docker.networks, "kind")
+}
+
 type fakeDocker struct {
 	containers           []types.Container
 	lastRemovedContainer string
 	lastCreateConfig     *container.Config
 	lastCreateHostConfig *container.HostConfig
 	onCreate             func()
+
+	// Container ID -> environment.
+	env map[string][]string
+
+	// Container ID -> mounts.
+	mounts map[string][]types.MountPoint
+
+	// Every container created, in order.
+	created []fakeCreate
+
+	// Network name -> names of the containers connected to it.
+	networks map[string][]string
+
+	// The daemon's version, including its native platform.
+	serverVersion types.Version
+}
+
+type fakeCreate struct {
+	name             string
+	config           *container.Config
+	hostConfig       *container.HostConfig
+	networkingConfig *network.NetworkingConfig
+	platform         *specs.Platform
 }
 
 type objectNotFoundError struct {
@@ -358,6 +564,8 @@ func (d *fakeDocker) ContainerInspect(ctx context.Context, containerID string) (
 						Running: c.State == "running",
 					},
 				},
+				Config: &container.Config{Env: d.env[c.ID]},
+				Mounts: d.mounts[c.ID],
 			}, nil
 		}
 	}
@@ -397,16 +605,50 @@ func (d *fakeDocker) ContainerCreate(ctx context.Context, config *container.Conf
 	containerName string) (container.ContainerCreateCreatedBody, error) {
 	d.lastCreateConfig = config
 	d.lastCreateHostConfig = hostConfig
+	d.created = append(d.created, fakeCreate{
+		name:             containerName,
+		config:           config,
+		hostConfig:       hostConfig,
+		networkingConfig: networkingConfig,
+		platform:         platform,
+	})
 	if d.onCreate != nil {
 		d.onCreate()
 	}
 	return container.ContainerCreateCreatedBody{}, nil
 }
+func (d *fakeDocker) ServerVersion(ctx context.Context) (types.Version, error) {
+	return d.serverVersion, nil
+}
+
 func (d *fakeDocker) ContainerStart(ctx context.Context, containerID string,
 	options types.ContainerStartOptions) error {
 	return nil
 }
 
+func (d *fakeDocker) NetworkInspect(ctx context.Context, networkID string, options types.NetworkInspectOptions) (types.NetworkResource, error) {
+	if _, ok := d.networks[networkID]; !ok {
+		return types.NetworkResource{}, objectNotFoundError{"network", networkID}
+	}
+	return types.NetworkResource{Name: networkID, ID: networkID + "-id"}, nil
+}
+
+func (d *fakeDocker) NetworkCreate(ctx context.Context, name string, options types.NetworkCreate) (types.NetworkCreateResponse, error) {
+	if d.networks == nil {
+		d.networks = make(map[string][]string)
+	}
+	d.networks[name] = []string{}
+	return types.NetworkCreateResponse{ID: name + "-id"}, nil
+}
+
+func (d *fakeDocker) NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error {
+	if _, ok := d.networks[networkID]; !ok {
+		return objectNotFoundError{"network", networkID}
+	}
+	d.networks[networkID] = append(d.networks[networkID], containerID)
+	return nil
+}
+
 type fixture struct {
 	t      *testing.T
 	c      *Controller
@@ -419,6 +661,8 @@ func newFixture(t *testing.T) *fixture {
 	d := &fakeDocker{}
 	controller := NewController(
 		genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, d)
+	controller.hostsFile = &hostsfile.File{Path: filepath.Join(t.TempDir(), "hosts")}
+	controller.webhookConfigDir = filepath.Join(t.TempDir(), "registries")
 	return &fixture{
 		t:      t,
 		docker: d,
@@ -0,0 +1,65 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/mount"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Replace re-creates the registry container with the desired config, even if
+// nothing changed, and keeps the volume that stores its images.
+//
+// Fails if the registry doesn't exist, or if the new config can't use the old
+// volume.
+func (c *Controller) Replace(ctx context.Context, desired *api.Registry) (*api.Registry, error) {
+	FillDefaults(desired)
+	_, err := c.Get(ctx, desired.Name)
+	if err != nil {
+		return nil, err
+	}
+	return c.apply(ctx, desired, true)
+}
+
+// Finds the storage of the existing registry, so that the new container can mount it.
+//
+// Returns nil for replicated registries, which always mount their shared named volume.
+func (c *Controller) storageToKeep(ctx context.Context, existing, desired *api.Registry) (*mount.Mount, error) {
+	if existing.Status.ContainerID == "" {
+		return nil, fmt.Errorf("can't replace registry %s: it has no container", desired.Name)
+	}
+
+	oldCount, newCount := replicaCount(existing), replicaCount(desired)
+	if (oldCount > 1) != (newCount > 1) {
+		return nil, fmt.Errorf("can't replace registry %s: changing replicaCount from %d to %d moves "+
+			"its storage to a different volume. Delete and re-create it instead", desired.Name, oldCount, newCount)
+	}
+	if oldCount > 1 {
+		return nil, nil
+	}
+
+	container, err := c.dockerClient.ContainerInspect(ctx, existing.Status.ContainerID)
+	if err != nil {
+		return nil, fmt.Errorf("can't replace registry %s: %v", desired.Name, err)
+	}
+	for _, m := range container.Mounts {
+		if m.Destination != registryStoragePath {
+			continue
+		}
+
+		switch m.Type {
+		case mount.TypeVolume:
+			_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Keeping volume %s of registry %q\n", m.Name, desired.Name)
+			return &mount.Mount{Type: mount.TypeVolume, Source: m.Name, Target: registryStoragePath}, nil
+		case mount.TypeBind:
+			_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Keeping directory %s of registry %q\n", m.Source, desired.Name)
+			return &mount.Mount{Type: mount.TypeBind, Source: m.Source, Target: registryStoragePath}, nil
+		default:
+			return nil, fmt.Errorf("can't replace registry %s: its storage is a %s mount, "+
+				"which doesn't outlive the container", desired.Name, m.Type)
+		}
+	}
+	return nil, fmt.Errorf("can't replace registry %s: it has no volume at %s to keep", desired.Name, registryStoragePath)
+}
@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestReplaceKeepsVolume(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	existing := kindRegistry()
+	f.docker.containers = []types.Container{existing}
+	f.docker.mounts = map[string][]types.MountPoint{
+		existing.ID: {{Type: mount.TypeVolume, Name: "3f2a9c", Destination: "/var/lib/registry"}},
+	}
+
+	// Nothing changed, but replace re-creates the container anyway.
+	registry, err := f.c.Replace(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Port:     5001,
+		Image:    "registry:2",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "kind-registry", registry.Name)
+	assert.Equal(t, existing.ID, f.docker.lastRemovedContainer)
+	assert.Equal(t, []mount.Mount{
+		{Type: mount.TypeVolume, Source: "3f2a9c", Target: "/var/lib/registry"},
+	}, f.docker.lastCreateHostConfig.Mounts)
+}
+
+func TestReplaceNotFound(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	_, err := f.c.Replace(context.Background(), &api.Registry{TypeMeta: typeMeta, Name: "kind-registry"})
+	assert.True(t, errors.IsNotFound(err))
+	assert.Nil(t, f.docker.lastCreateConfig)
+}
+
+func TestReplaceWithoutVolume(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{kindRegistry()}
+
+	_, err := f.c.Replace(context.Background(), &api.Registry{TypeMeta: typeMeta, Name: "kind-registry", Port: 5001})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "can't replace registry kind-registry: it has no volume at /var/lib/registry to keep")
+	}
+	assert.Equal(t, "", f.docker.lastRemovedContainer)
+}
+
+func TestReplaceIncompatibleReplicaCount(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	existing := kindRegistry()
+	f.docker.containers = []types.Container{existing}
+	f.docker.mounts = map[string][]types.MountPoint{
+		existing.ID: {{Type: mount.TypeVolume, Name: "3f2a9c", Destination: "/var/lib/registry"}},
+	}
+
+	_, err := f.c.Replace(context.Background(), &api.Registry{
+		TypeMeta:     typeMeta,
+		Name:         "kind-registry",
+		Port:         5001,
+		ReplicaCount: 3,
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "changing replicaCount from 1 to 3 moves its storage to a different volume")
+	}
+	assert.Equal(t, "", f.docker.lastRemovedContainer)
+}
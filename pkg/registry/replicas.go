@@ -0,0 +1,201 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/tilt-dev/ctlptl/internal/dctr"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+	"github.com/tilt-dev/ctlptl/pkg/docker"
+)
+
+// The load balancer in front of registry replicas.
+var loadBalancerImage = "docker.io/library/nginx:1.25-alpine"
+
+// Labels on the containers of a replicated registry.
+const (
+	// On each replica, the name of the registry it belongs to.
+	replicaOfLabel = "dev.tilt.ctlptl.registry-replica-of"
+
+	// On the load balancer, the number of replicas behind it.
+	replicaCountLabel = "dev.tilt.ctlptl.registry-replicas"
+
+	// On the load balancer, the image that the replicas run.
+	replicaImageLabel = "dev.tilt.ctlptl.registry-image"
+)
+
+// Routes requests to the replicas in round-robin order.
+//
+// Uploads are streamed rather than buffered, and may be any size.
+const loadBalancerConfigTemplate = `events {}
+http {
+  client_max_body_size 0;
+  upstream registry {
+%s  }
+  server {
+    listen 5000;
+    location / {
+      proxy_pass http://registry;
+      proxy_set_header Host $http_host;
+      proxy_set_header X-Forwarded-Proto $scheme;
+      proxy_request_buffering off;
+      proxy_read_timeout 900;
+    }
+  }
+}
+`
+
+// Writes the config from the environment, so that we don't need to mount
+// files from the host (which may be a remote Docker).
+const loadBalancerCommand = `printf '%s' "$NGINX_CONF" > /etc/nginx/nginx.conf && exec nginx -g 'daemon off;'`
+
+func replicaCount(registry *api.Registry) int {
+	if registry.ReplicaCount < 1 {
+		return 1
+	}
+	return registry.ReplicaCount
+}
+
+func replicaName(name string, i int) string {
+	return fmt.Sprintf("%s-replica-%d", name, i)
+}
+
+// The network that connects the load balancer to the replicas.
+func replicaNetworkName(name string) string {
+	return name + "-replicas"
+}
+
+// The volume that the replicas share.
+func replicaVolumeName(name string) string {
+	return name + "-data"
+}
+
+func loadBalancerConfig(name string, count int) string {
+	servers := strings.Builder{}
+	for i := 1; i <= count; i++ {
+		servers.WriteString(fmt.Sprintf("    server %s:5000;\n", replicaName(name, i)))
+	}
+	return fmt.Sprintf(loadBalancerConfigTemplate, servers.String())
+}
+
+// Fills in the replica fields of a registry from the labels on its load balancer.
+func populateReplicas(registry *api.Registry, labels map[string]string) {
+	count, err := strconv.Atoi(labels[replicaCountLabel])
+	if err != nil || count < 2 {
+		return
+	}
+	registry.ReplicaCount = count
+	if image := labels[replicaImageLabel]; image != "" {
+		registry.Status.Image = image
+	}
+}
+
+// Runs the registry replicas, and the load balancer in front of them.
+//
+// The load balancer takes the registry's name, port, and labels, so that
+// clusters and `ctlptl get` treat it like a single-container registry.
+func (c *Controller) runReplicated(ctx context.Context, desired *api.Registry, env []string, labels map[string]string,
+	exposedPorts nat.PortSet, portBindings nat.PortMap, platform *specs.Platform) error {
+	count := replicaCount(desired)
+	networkName := replicaNetworkName(desired.Name)
+	_, err := docker.EnsureNetworkExists(ctx, c.dockerClient, networkName, docker.NetworkCreateOptions{
+		Labels: ctlptlLabels,
+	})
+	if err != nil {
+		return err
+	}
+	networkingConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{networkName: {}},
+	}
+
+	for i := 1; i <= count; i++ {
+		name := replicaName(desired.Name, i)
+		err := dctr.RemoveIfNecessary(ctx, c.dockerClient, name)
+		if err != nil {
+			return err
+		}
+
+		hostConfig := &container.HostConfig{
+			RestartPolicy: container.RestartPolicy{Name: "always"},
+			Mounts: []mount.Mount{
+				{
+					Type:   mount.TypeVolume,
+					Source: replicaVolumeName(desired.Name),
+					Target: registryStoragePath,
+				},
+			},
+		}
+		addLimits(hostConfig, desired.Limits)
+		config := &container.Config{
+			Hostname: name,
+			Image:    desired.Image,
+			Labels:   map[string]string{replicaOfLabel: desired.Name},
+			Env:      env,
+		}
+		addAuth(config, desired.Auth)
+		err = dctr.RunWithPlatform(ctx, c.dockerClient, name, config, hostConfig, networkingConfig, platform)
+		if err != nil {
+			return err
+		}
+	}
+
+	lbLabels := make(map[string]string, len(labels)+2)
+	for k, v := range labels {
+		lbLabels[k] = v
+	}
+	lbLabels[replicaCountLabel] = strconv.Itoa(count)
+	lbLabels[replicaImageLabel] = desired.Image
+
+	_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Load balancing registry %q across %d replicas\n", desired.Name, count)
+	return dctr.Run(
+		ctx,
+		c.dockerClient,
+		desired.Name,
+		&container.Config{
+			Hostname:     desired.Name,
+			Image:        loadBalancerImage,
+			ExposedPorts: exposedPorts,
+			Labels:       lbLabels,
+			Env:          []string{"NGINX_CONF=" + loadBalancerConfig(desired.Name, count)},
+			Cmd:          []string{"sh", "-c", loadBalancerCommand},
+		},
+		&container.HostConfig{
+			RestartPolicy: container.RestartPolicy{Name: "always"},
+			PortBindings:  portBindings,
+		},
+		networkingConfig)
+}
+
+// Removes the replicas of a registry and their network.
+//
+// Keeps the shared storage volume, so that re-creating the registry keeps its images.
+func (c *Controller) deleteReplicas(ctx context.Context, name string) error {
+	replicas, err := c.dockerClient.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", replicaOfLabel, name))),
+		All:     true,
+	})
+	if err != nil {
+		return err
+	}
+	if len(replicas) == 0 {
+		return nil
+	}
+
+	for _, replica := range replicas {
+		err := c.dockerClient.ContainerRemove(ctx, replica.ID, types.ContainerRemoveOptions{Force: true})
+		if err != nil {
+			return err
+		}
+	}
+	return c.docker(ctx, "network", "rm", replicaNetworkName(name))
+}
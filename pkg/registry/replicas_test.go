@@ -0,0 +1,134 @@
+package registry
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/internal/exec"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestApplyReplicas(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	var calls []string
+	f.c.runner = exec.NewFakeCmdRunner(func(argv []string) string {
+		calls = append(calls, strings.Join(argv, " "))
+		return ""
+	})
+
+	// Each create adds a running container with the labels it was created with.
+	f.docker.onCreate = func() {
+		create := f.docker.created[len(f.docker.created)-1]
+		c := kindRegistry()
+		c.ID = create.name + "-id"
+		c.Names = []string{"/" + create.name}
+		c.Labels = create.config.Labels
+		c.Image = create.config.Image
+		if create.name != "kind-registry" {
+			c.Ports = nil
+		}
+		f.docker.containers = append(f.docker.containers, c)
+	}
+
+	registry, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta:     typeMeta,
+		Name:         "kind-registry",
+		Port:         5001,
+		ReplicaCount: 3,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, registry.ReplicaCount)
+	assert.Equal(t, 5001, registry.Status.HostPort)
+	assert.Equal(t, "docker.io/library/registry:2", registry.Status.Image)
+
+	require.Len(t, f.docker.created, 4)
+	secret := ""
+	for i, create := range f.docker.created[:3] {
+		assert.Equal(t, replicaName("kind-registry", i+1), create.name)
+		assert.Equal(t, "docker.io/library/registry:2", create.config.Image)
+		assert.Equal(t, map[string]string{replicaOfLabel: "kind-registry"}, create.config.Labels)
+		assert.Equal(t, []mount.Mount{
+			{Type: mount.TypeVolume, Source: "kind-registry-data", Target: "/var/lib/registry"},
+		}, create.hostConfig.Mounts)
+		assert.Contains(t, create.networkingConfig.EndpointsConfig, "kind-registry-replicas")
+		assert.Empty(t, create.hostConfig.PortBindings)
+
+		// The replicas share a secret, so that uploads can hop between them.
+		require.Len(t, create.config.Env, 3)
+		if secret == "" {
+			secret = create.config.Env[1]
+		}
+		assert.Equal(t, secret, create.config.Env[1])
+	}
+
+	lb := f.docker.created[3]
+	assert.Equal(t, "kind-registry", lb.name)
+	assert.Equal(t, loadBalancerImage, lb.config.Image)
+	assert.Equal(t, "3", lb.config.Labels[replicaCountLabel])
+	assert.Equal(t, "registry", lb.config.Labels["dev.tilt.ctlptl.role"])
+	assert.Equal(t, "5001", lb.hostConfig.PortBindings["5000/tcp"][0].HostPort)
+	assert.Contains(t, lb.networkingConfig.EndpointsConfig, "kind-registry-replicas")
+	require.Len(t, lb.config.Env, 1)
+	assert.Contains(t, lb.config.Env[0], "    server kind-registry-replica-1:5000;\n"+
+		"    server kind-registry-replica-2:5000;\n"+
+		"    server kind-registry-replica-3:5000;\n")
+	assert.Contains(t, f.docker.networks, "kind-registry-replicas")
+
+	// Replicas aren't listed as registries of their own.
+	list, err := f.c.List(context.Background(), ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "kind-registry", list.Items[0].Name)
+
+	// Applying the same config again doesn't re-create anything.
+	_, err = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta:     typeMeta,
+		Name:         "kind-registry",
+		ReplicaCount: 3,
+	})
+	require.NoError(t, err)
+	assert.Len(t, f.docker.created, 4)
+
+	// Deleting the registry deletes the replicas and their network.
+	calls = nil
+	err = f.c.Delete(context.Background(), "kind-registry")
+	require.NoError(t, err)
+	assert.Equal(t, "kind-registry-replica-3-id", f.docker.lastRemovedContainer)
+	assert.Equal(t, []string{"docker network rm kind-registry-replicas"}, calls)
+}
+
+func TestApplyReplicasInvalid(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	_, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta:     typeMeta,
+		Name:         "kind-registry",
+		ReplicaCount: -1,
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "replicaCount must be at least 1. Actual: -1")
+	}
+}
+
+func TestDefragmentReplicas(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	lb := kindRegistry()
+	lb.Labels = map[string]string{"dev.tilt.ctlptl.role": "registry", replicaCountLabel: "2"}
+	f.docker.containers = []types.Container{lb}
+
+	_, err := f.c.Defragment(context.Background(), "kind-registry")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "registry kind-registry has 2 replicas. Defragmenting only supports single-container registries")
+	}
+}
@@ -0,0 +1,84 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// TestImageRepository is the repository that PushTestImage pushes to.
+const TestImageRepository = "ctlptl-registry-test"
+
+// PushTestImage pushes a tiny image to a local registry from the host, with
+// the docker CLI, and returns it as host:port/repository:tag.
+//
+// The image has a single small file and nothing to run. Each push overwrites
+// the last one, so testing a registry over and over doesn't fill it up.
+func (c *Controller) PushTestImage(ctx context.Context, name string) (string, error) {
+	host, err := c.pushHost(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	target := fmt.Sprintf("%s/%s:latest", host, TestImageRepository)
+
+	layer, err := testImageLayer()
+	if err != nil {
+		return "", err
+	}
+	errOut := bytes.NewBuffer(nil)
+	err = c.runner.RunIO(ctx,
+		genericclioptions.IOStreams{In: bytes.NewReader(layer), Out: bytes.NewBuffer(nil), ErrOut: errOut},
+		"docker", "import", "-", target)
+	if err != nil {
+		return "", fmt.Errorf("docker import: %v: %s", err, strings.TrimSpace(errOut.String()))
+	}
+	defer func() {
+		// The registry has its own copy, so we don't need to keep the local one.
+		_ = c.docker(ctx, "rmi", target)
+	}()
+
+	err = c.docker(ctx, "push", target)
+	if err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// The address on the host to push to a running local registry.
+func (c *Controller) pushHost(ctx context.Context, name string) (string, error) {
+	reg, err := c.Get(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if reg.Status.State != containerStateRunning {
+		return "", fmt.Errorf("registry %s is not running", name)
+	}
+	if reg.Status.HostPort == 0 {
+		return "", fmt.Errorf("registry %s is not listening on the host", name)
+	}
+	return fmt.Sprintf("localhost:%d", reg.Status.HostPort), nil
+}
+
+// A tarball with one file, for docker import.
+func testImageLayer() ([]byte, error) {
+	contents := []byte("pushed by ctlptl registry test\n")
+	buf := bytes.NewBuffer(nil)
+	w := tar.NewWriter(buf)
+	err := w.WriteHeader(&tar.Header{Name: TestImageRepository, Mode: 0644, Size: int64(len(contents))})
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(contents)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,38 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushTestImage(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.containers = []types.Container{kindRegistry()}
+	calls := []string{}
+	f.c.runner = fakePushRunner(&calls)
+
+	image, err := f.c.PushTestImage(context.Background(), "kind-registry")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:5001/ctlptl-registry-test:latest", image)
+	assert.Equal(t, []string{
+		"docker import - localhost:5001/ctlptl-registry-test:latest",
+		"docker push localhost:5001/ctlptl-registry-test:latest",
+		"docker rmi localhost:5001/ctlptl-registry-test:latest",
+	}, calls)
+}
+
+func TestPushTestImageNotFound(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	_, err := f.c.PushTestImage(context.Background(), "kind-registry")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not found")
+	}
+}
@@ -0,0 +1,248 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/internal/egress"
+)
+
+// The severities that trivy reports, from most to least severe.
+var scanSeverities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "UNKNOWN"}
+
+type ScanOptions struct {
+	// The trivy binary to run.
+	Trivy string
+
+	// Only report vulnerabilities with these severities. Empty means all.
+	Severities []string
+}
+
+// A vulnerability that trivy found in an image.
+type Vulnerability struct {
+	// The CVE or advisory, e.g., CVE-2023-0464.
+	ID string `json:"id"`
+
+	// The package that has the vulnerability, at its installed version.
+	Package          string `json:"package"`
+	InstalledVersion string `json:"installedVersion"`
+
+	// The version that fixes the vulnerability, if there is one.
+	FixedVersion string `json:"fixedVersion,omitempty"`
+
+	// One of CRITICAL, HIGH, MEDIUM, LOW, or UNKNOWN.
+	Severity string `json:"severity"`
+
+	Title string `json:"title,omitempty"`
+
+	// Where trivy found the package, e.g., the OS or a lockfile.
+	Target string `json:"target,omitempty"`
+}
+
+// ParseSeverities parses a comma-separated list of severities, like HIGH,CRITICAL.
+func ParseSeverities(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	result := []string{}
+	for _, severity := range strings.Split(s, ",") {
+		severity = strings.ToUpper(strings.TrimSpace(severity))
+		if !containsString(scanSeverities, severity) {
+			return nil, fmt.Errorf("severity must be one of: %s. Actual: %s", strings.Join(scanSeverities, ", "), severity)
+		}
+		result = append(result, severity)
+	}
+	return result, nil
+}
+
+// Splits an image reference like repo:tag or repo@sha256:... into the
+// repository and the tag or digest. The tag defaults to latest.
+func parseScanImage(image string) (repository, reference string, err error) {
+	if repo, digest, ok := strings.Cut(image, "@"); ok {
+		if repo == "" || digest == "" {
+			return "", "", fmt.Errorf("invalid image %q: must be REPOSITORY:TAG or REPOSITORY@DIGEST", image)
+		}
+		return repo, digest, nil
+	}
+	repository, reference = image, "latest"
+	if i := strings.LastIndex(image, ":"); i != -1 && !strings.Contains(image[i:], "/") {
+		repository, reference = image[:i], image[i+1:]
+	}
+	if repository == "" || reference == "" {
+		return "", "", fmt.Errorf("invalid image %q: must be REPOSITORY:TAG or REPOSITORY@DIGEST", image)
+	}
+	return repository, reference, nil
+}
+
+// Scan runs trivy against an image in a registry, and returns the
+// vulnerabilities it found, most severe first.
+//
+// Downloads the image with the registry API into a temporary OCI layout,
+// rather than with docker pull, so that scanning doesn't fill the Docker
+// daemon with images.
+//
+// When network egress is disabled, tells trivy not to download its
+// vulnerability DBs, so it only scans with the DBs it already has cached.
+func (c *Controller) Scan(ctx context.Context, client *Client, image string, options ScanOptions) ([]Vulnerability, error) {
+	repo, reference, err := parseScanImage(image)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "ctlptl-scan-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	err = exportImage(ctx, client, dir, repo, reference)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %v", image, err)
+	}
+
+	args := []string{"image", "--input", dir, "--format", "json", "--quiet"}
+	if len(options.Severities) > 0 {
+		args = append(args, "--severity", strings.Join(options.Severities, ","))
+	}
+	if egress.Disabled() {
+		args = append(args, "--skip-db-update", "--skip-java-db-update", "--offline-scan")
+	}
+	out := bytes.NewBuffer(nil)
+	errOut := bytes.NewBuffer(nil)
+	err = c.runner.RunIO(ctx, genericclioptions.IOStreams{Out: out, ErrOut: errOut}, options.Trivy, args...)
+	if err != nil {
+		return nil, fmt.Errorf("scanning %s: %v: %s", image, err, strings.TrimSpace(errOut.String()))
+	}
+
+	vulns, err := parseTrivyReport(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("scanning %s: %v", image, err)
+	}
+	return vulns, nil
+}
+
+// Writes an OCI layout with just the given image.
+//
+// Trivy scans one image at a time, so for a multi-platform image, picks
+// the image for the host's architecture (or else the first image).
+func exportImage(ctx context.Context, client *Client, dir, repo, reference string) error {
+	err := os.MkdirAll(filepath.Join(dir, "blobs"), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644)
+	if err != nil {
+		return err
+	}
+
+	mediaType, data, err := client.GetManifest(ctx, repo, reference)
+	if err != nil {
+		return err
+	}
+	var manifest ociManifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return fmt.Errorf("decoding manifest: %v", err)
+	}
+	if mediaType == "" || mediaType == "application/json" {
+		mediaType = manifest.MediaType
+	}
+	if isIndexMediaType(mediaType) {
+		child, err := platformManifest(manifest.Manifests, runtime.GOARCH)
+		if err != nil {
+			return err
+		}
+		reference = child.Digest
+	}
+
+	desc, err := exportManifest(ctx, client, dir, repo, reference)
+	if err != nil {
+		return err
+	}
+	index := ociIndex{SchemaVersion: 2, MediaType: mediaTypeOCIIndex, Manifests: []ociDescriptor{desc}}
+	data, err = json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "index.json"), data, 0644)
+}
+
+func platformManifest(manifests []ociDescriptor, arch string) (ociDescriptor, error) {
+	if len(manifests) == 0 {
+		return ociDescriptor{}, fmt.Errorf("image index has no images")
+	}
+	for _, m := range manifests {
+		if m.Platform != nil && m.Platform.OS == "linux" && m.Platform.Architecture == arch {
+			return m, nil
+		}
+	}
+	return manifests[0], nil
+}
+
+// The fields we need from trivy's JSON report.
+type trivyReport struct {
+	Results []struct {
+		Target          string `json:"Target"`
+		Vulnerabilities []struct {
+			VulnerabilityID  string `json:"VulnerabilityID"`
+			PkgName          string `json:"PkgName"`
+			InstalledVersion string `json:"InstalledVersion"`
+			FixedVersion     string `json:"FixedVersion"`
+			Severity         string `json:"Severity"`
+			Title            string `json:"Title"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+func parseTrivyReport(data []byte) ([]Vulnerability, error) {
+	var report trivyReport
+	err := json.Unmarshal(data, &report)
+	if err != nil {
+		return nil, fmt.Errorf("decoding trivy report: %v", err)
+	}
+
+	result := []Vulnerability{}
+	for _, r := range report.Results {
+		for _, v := range r.Vulnerabilities {
+			result = append(result, Vulnerability{
+				ID:               v.VulnerabilityID,
+				Package:          v.PkgName,
+				InstalledVersion: v.InstalledVersion,
+				FixedVersion:     v.FixedVersion,
+				Severity:         v.Severity,
+				Title:            v.Title,
+				Target:           r.Target,
+			})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		si, sj := severityRank(result[i].Severity), severityRank(result[j].Severity)
+		if si != sj {
+			return si < sj
+		}
+		if result[i].ID != result[j].ID {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].Package < result[j].Package
+	})
+	return result, nil
+}
+
+// Ranks severities from most severe (0) to least severe.
+func severityRank(severity string) int {
+	for i, s := range scanSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(scanSeverities)
+}
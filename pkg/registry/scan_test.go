@@ -0,0 +1,190 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/internal/egress"
+	"github.com/tilt-dev/ctlptl/internal/exec"
+)
+
+const trivyReportJSON = `{
+  "Results": [
+    {
+      "Target": "localhost:5001/team/app (alpine 3.16.2)",
+      "Vulnerabilities": [
+        {"VulnerabilityID": "CVE-2022-37434", "PkgName": "zlib", "InstalledVersion": "1.2.12-r1",
+         "FixedVersion": "1.2.12-r2", "Severity": "MEDIUM", "Title": "heap-based buffer over-read"},
+        {"VulnerabilityID": "CVE-2023-0286", "PkgName": "libcrypto1.1", "InstalledVersion": "1.1.1q-r0",
+         "FixedVersion": "1.1.1t-r0", "Severity": "HIGH", "Title": "X.400 address type confusion"},
+        {"VulnerabilityID": "CVE-2022-40674", "PkgName": "expat", "InstalledVersion": "2.4.8-r0",
+         "FixedVersion": "2.4.9-r0", "Severity": "CRITICAL", "Title": "use-after-free in doContent"}
+      ]
+    },
+    {"Target": "app/go.sum"}
+  ]
+}`
+
+func TestScan(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	server := newFakeRegistryServer(t)
+	server.seed()
+	client, err := NewClient(server.URL, "", "")
+	require.NoError(t, err)
+
+	var argv []string
+	var index ociIndex
+	f.c.runner = exec.NewFakeCmdRunner(func(a []string) string {
+		argv = a
+		dir := a[3]
+		data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &index))
+		for _, m := range index.Manifests {
+			path, err := blobPath(dir, m.Digest)
+			require.NoError(t, err)
+			assert.FileExists(t, path)
+		}
+		assert.FileExists(t, filepath.Join(dir, "oci-layout"))
+		return trivyReportJSON
+	})
+
+	vulns, err := f.c.Scan(context.Background(), client, "alpine:3.16", ScanOptions{
+		Trivy:      "/usr/local/bin/trivy",
+		Severities: []string{"HIGH", "CRITICAL"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/usr/local/bin/trivy", "image", "--input", argv[3],
+		"--format", "json", "--quiet", "--severity", "HIGH,CRITICAL"}, argv)
+	assert.Len(t, index.Manifests, 1)
+	assert.Equal(t, server.tagged["alpine:3.16"], index.Manifests[0].Digest)
+
+	ids := []string{}
+	for _, v := range vulns {
+		ids = append(ids, v.Severity+" "+v.ID)
+	}
+	assert.Equal(t, []string{"CRITICAL CVE-2022-40674", "HIGH CVE-2023-0286", "MEDIUM CVE-2022-37434"}, ids)
+	assert.Equal(t, "localhost:5001/team/app (alpine 3.16.2)", vulns[0].Target)
+	assert.NoDirExists(t, argv[3])
+}
+
+func TestScanEgressDisabled(t *testing.T) {
+	t.Setenv(egress.EnvVar, "true")
+	f := newFixture(t)
+	defer f.TearDown()
+
+	server := newFakeRegistryServer(t)
+	server.seed()
+	client, err := NewClient(server.URL, "", "")
+	require.NoError(t, err)
+
+	var argv []string
+	f.c.runner = exec.NewFakeCmdRunner(func(a []string) string {
+		argv = a
+		return trivyReportJSON
+	})
+
+	_, err = f.c.Scan(context.Background(), client, "alpine:3.16", ScanOptions{Trivy: "trivy"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"trivy", "image", "--input", argv[3], "--format", "json", "--quiet",
+		"--skip-db-update", "--skip-java-db-update", "--offline-scan"}, argv)
+}
+
+func TestScanMultiPlatformImage(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	server := newFakeRegistryServer(t)
+	server.seed()
+	client, err := NewClient(server.URL, "", "")
+	require.NoError(t, err)
+
+	var index ociIndex
+	f.c.runner = exec.NewFakeCmdRunner(func(a []string) string {
+		data, err := os.ReadFile(filepath.Join(a[3], "index.json"))
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &index))
+		return `{}`
+	})
+
+	vulns, err := f.c.Scan(context.Background(), client, "team/app", ScanOptions{Trivy: "trivy"})
+	require.NoError(t, err)
+	assert.Empty(t, vulns)
+
+	// The seeded index has no platforms, so we scan its first image.
+	require.Len(t, index.Manifests, 1)
+	assert.Equal(t, mediaTypeOCIManifest, index.Manifests[0].MediaType)
+}
+
+func TestScanImageNotFound(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	server := newFakeRegistryServer(t)
+	client, err := NewClient(server.URL, "", "")
+	require.NoError(t, err)
+
+	_, err = f.c.Scan(context.Background(), client, "team/app:v2", ScanOptions{Trivy: "trivy"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "downloading team/app:v2")
+	}
+}
+
+func TestPlatformManifest(t *testing.T) {
+	manifests := []ociDescriptor{
+		{Digest: "sha256:amd64", Platform: &ociPlatform{OS: "linux", Architecture: "amd64"}},
+		{Digest: "sha256:arm64", Platform: &ociPlatform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+	}
+	m, err := platformManifest(manifests, "arm64")
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:arm64", m.Digest)
+
+	m, err = platformManifest(manifests, "s390x")
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:amd64", m.Digest)
+
+	_, err = platformManifest(nil, "amd64")
+	assert.Error(t, err)
+}
+
+func TestParseScanImage(t *testing.T) {
+	for _, tc := range []struct {
+		image, repo, ref string
+	}{
+		{"alpine", "alpine", "latest"},
+		{"team/app:v1", "team/app", "v1"},
+		{"team/app@sha256:abc", "team/app", "sha256:abc"},
+	} {
+		repo, ref, err := parseScanImage(tc.image)
+		require.NoError(t, err)
+		assert.Equal(t, tc.repo, repo)
+		assert.Equal(t, tc.ref, ref)
+	}
+
+	_, _, err := parseScanImage("team/app:")
+	assert.Error(t, err)
+}
+
+func TestParseSeverities(t *testing.T) {
+	severities, err := ParseSeverities("high, Critical")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HIGH", "CRITICAL"}, severities)
+
+	severities, err = ParseSeverities("")
+	require.NoError(t, err)
+	assert.Nil(t, severities)
+
+	_, err = ParseSeverities("HIGH,SEVERE")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Actual: SEVERE")
+	}
+}
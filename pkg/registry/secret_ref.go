@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// What we print instead of a password that's in the config.
+const redacted = "<redacted>"
+
+// Checks the syntax of a reference like env:NAME or file:PATH.
+func validateSecretRef(field, ref string) error {
+	kind, value, ok := strings.Cut(ref, ":")
+	if !ok || value == "" || (kind != "env" && kind != "file") {
+		return fmt.Errorf("%s must be env:NAME or file:PATH. Actual: %q", field, ref)
+	}
+	return nil
+}
+
+// Reads the secret that a reference names.
+//
+// Fails if the environment variable isn't set, or the file is missing or
+// empty, so that we never fall back to an empty secret.
+func resolveSecretRef(field, ref string) (string, error) {
+	err := validateSecretRef(field, ref)
+	if err != nil {
+		return "", err
+	}
+	kind, value, _ := strings.Cut(ref, ":")
+	switch kind {
+	case "env":
+		secret, ok := os.LookupEnv(value)
+		if !ok || secret == "" {
+			return "", fmt.Errorf("%s: environment variable %s is not set", field, value)
+		}
+		return secret, nil
+	default:
+		path, err := homedir.Expand(value)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", field, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", field, err)
+		}
+		secret := strings.TrimRight(string(data), "\r\n")
+		if secret == "" {
+			return "", fmt.Errorf("%s: %s is empty", field, path)
+		}
+		return secret, nil
+	}
+}
+
+// Returns a copy of the registry with its secrets read from their sources.
+//
+// Returns the registry itself if it has no secret references, so that
+// the caller's object never holds the resolved secrets.
+func resolveSecrets(desired *api.Registry) (*api.Registry, error) {
+	if desired.Auth == nil || desired.Auth.PasswordFrom == "" {
+		return desired, nil
+	}
+	password, err := resolveSecretRef("auth.passwordFrom", desired.Auth.PasswordFrom)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(password, "\r\n") {
+		return nil, fmt.Errorf("auth.passwordFrom: the password must not contain newlines")
+	}
+	desired = desired.DeepCopy()
+	desired.Auth.Password = password
+	return desired, nil
+}
+
+// Redacted returns a copy of the registry that's safe to print, with any
+// password, HTTP secret, or webhook header value in the config replaced
+// by <redacted>.
+//
+// References like auth.passwordFrom are kept, since they only say where the
+// password is.
+func Redacted(registry *api.Registry) *api.Registry {
+	hasPassword := registry.Auth != nil && registry.Auth.Password != ""
+	hasHeaders := registry.Webhook != nil && len(registry.Webhook.Headers) > 0
+	if !hasPassword && registry.HTTPSecret == "" && !hasHeaders {
+		return registry
+	}
+	registry = registry.DeepCopy()
+	if hasPassword {
+		registry.Auth.Password = redacted
+	}
+	if registry.HTTPSecret != "" {
+		registry.HTTPSecret = redacted
+	}
+	if hasHeaders {
+		// The names say what the headers are for, so we keep them.
+		for name := range registry.Webhook.Headers {
+			registry.Webhook.Headers[name] = redacted
+		}
+	}
+	return registry
+}
@@ -0,0 +1,149 @@
+package registry
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Labels that record the upload purge config on the registry container,
+// so that we can report it without inspecting the container.
+const (
+	uploadPurgeLabel         = "dev.tilt.ctlptl.registry-upload-purge"
+	uploadPurgeAgeLabel      = "dev.tilt.ctlptl.registry-upload-purge-age"
+	uploadPurgeIntervalLabel = "dev.tilt.ctlptl.registry-upload-purge-interval"
+)
+
+var uploadPurgeLabels = []string{uploadPurgeLabel, uploadPurgeAgeLabel, uploadPurgeIntervalLabel}
+
+// The registry's own defaults, which we make explicit.
+const (
+	defaultUploadPurgeAge      = "168h"
+	defaultUploadPurgeInterval = "24h"
+)
+
+// Shorter ages purge uploads that are still in progress, and shorter
+// intervals keep the registry busy walking its storage.
+const minUploadPurgeDuration = time.Minute
+
+// The registry reads the whole uploadpurging config from one variable.
+//
+// Setting each key in its own variable (..._UPLOADPURGING_ENABLED) creates
+// a config map that the registry can't read, and it panics at startup.
+const uploadPurgingEnv = "REGISTRY_STORAGE_MAINTENANCE_UPLOADPURGING"
+
+func validateUploadPurge(purge *api.RegistryUploadPurgeSpec) error {
+	if purge == nil {
+		return nil
+	}
+	for _, field := range []struct {
+		name, value string
+	}{
+		{"uploadPurge.age", purge.Age},
+		{"uploadPurge.interval", purge.Interval},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil {
+			return fmt.Errorf("%s must be a duration, like 24h. Actual: %s", field.name, field.value)
+		}
+		if d < minUploadPurgeDuration {
+			return fmt.Errorf("%s must be at least %s. Actual: %s", field.name, minUploadPurgeDuration, field.value)
+		}
+	}
+	return nil
+}
+
+// Fills in the defaults.
+func uploadPurgeToUse(purge *api.RegistryUploadPurgeSpec) api.RegistryUploadPurgeSpec {
+	result := api.RegistryUploadPurgeSpec{}
+	if purge != nil {
+		result = *purge.DeepCopy()
+	}
+	if result.Enabled == nil {
+		enabled := true
+		result.Enabled = &enabled
+	}
+	if result.Age == "" {
+		result.Age = defaultUploadPurgeAge
+	}
+	if result.Interval == "" {
+		result.Interval = defaultUploadPurgeInterval
+	}
+	return result
+}
+
+// The registry environment variables for the upload purge config.
+func uploadPurgeEnv(purge *api.RegistryUploadPurgeSpec) []string {
+	p := uploadPurgeToUse(purge)
+	if !*p.Enabled {
+		return []string{uploadPurgingEnv + "={enabled: false}"}
+	}
+	return []string{fmt.Sprintf("%s={enabled: true, age: %s, interval: %s, dryrun: false}",
+		uploadPurgingEnv, p.Age, p.Interval)}
+}
+
+// Replaces the upload purge labels with the ones for the desired config.
+func setUploadPurgeLabels(labels map[string]string, purge *api.RegistryUploadPurgeSpec) {
+	for _, label := range uploadPurgeLabels {
+		delete(labels, label)
+	}
+	p := uploadPurgeToUse(purge)
+	if !*p.Enabled {
+		labels[uploadPurgeLabel] = "disabled"
+		return
+	}
+	labels[uploadPurgeLabel] = "enabled"
+	labels[uploadPurgeAgeLabel] = p.Age
+	labels[uploadPurgeIntervalLabel] = p.Interval
+}
+
+// Reads the upload purge config from the registry container's labels.
+//
+// Returns nil if the container has no labels, e.g., because an older
+// version of ctlptl created it.
+func uploadPurgeFromLabels(labels map[string]string) *api.RegistryUploadPurgeSpec {
+	switch labels[uploadPurgeLabel] {
+	case "enabled":
+		enabled := true
+		return &api.RegistryUploadPurgeSpec{
+			Enabled:  &enabled,
+			Age:      labels[uploadPurgeAgeLabel],
+			Interval: labels[uploadPurgeIntervalLabel],
+		}
+	case "disabled":
+		enabled := false
+		return &api.RegistryUploadPurgeSpec{Enabled: &enabled}
+	}
+	return nil
+}
+
+// Checks whether the running registry purges uploads the way we want.
+func uploadPurgeMatches(existing, desired *api.RegistryUploadPurgeSpec) bool {
+	if existing == nil {
+		// We don't know how the registry was configured, so re-create it
+		// with the config that we want.
+		return false
+	}
+	a, b := uploadPurgeToUse(existing), uploadPurgeToUse(desired)
+	if *a.Enabled != *b.Enabled {
+		return false
+	}
+	if !*a.Enabled {
+		return true
+	}
+	return durationsEqual(a.Age, b.Age) && durationsEqual(a.Interval, b.Interval)
+}
+
+// Compares durations by value, so that 24h matches 1440m.
+func durationsEqual(a, b string) bool {
+	da, errA := time.ParseDuration(a)
+	db, errB := time.ParseDuration(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return da == db
+}
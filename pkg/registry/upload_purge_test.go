@@ -0,0 +1,138 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestApplyUploadPurge(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.onCreate = func() {
+		registry := kindRegistry()
+		registry.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{registry}
+	}
+	f.docker.mounts = map[string][]types.MountPoint{
+		kindRegistry().ID: {{Type: mount.TypeVolume, Name: "3f2a9c", Destination: "/var/lib/registry"}},
+	}
+
+	purge := &api.RegistryUploadPurgeSpec{Age: "12h", Interval: "1h"}
+	registry, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta:    typeMeta,
+		Name:        "kind-registry",
+		UploadPurge: purge,
+	})
+	require.NoError(t, err)
+	enabled := true
+	assert.Equal(t, &api.RegistryUploadPurgeSpec{Enabled: &enabled, Age: "12h", Interval: "1h"}, registry.Status.UploadPurge)
+	assert.Equal(t, "REGISTRY_STORAGE_MAINTENANCE_UPLOADPURGING={enabled: true, age: 12h, interval: 1h, dryrun: false}",
+		f.docker.lastCreateConfig.Env[2])
+
+	// The same config, written differently, doesn't re-create the registry.
+	f.docker.lastCreateConfig = nil
+	_, err = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta:    typeMeta,
+		Name:        "kind-registry",
+		UploadPurge: &api.RegistryUploadPurgeSpec{Enabled: &enabled, Age: "720m", Interval: "60m"},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, f.docker.lastCreateConfig)
+
+	// Disabling purge re-creates the registry, and keeps its images.
+	disabled := false
+	registry, err = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta:    typeMeta,
+		Name:        "kind-registry",
+		UploadPurge: &api.RegistryUploadPurgeSpec{Enabled: &disabled, Age: "12h"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &api.RegistryUploadPurgeSpec{Enabled: &disabled}, registry.Status.UploadPurge)
+	assert.Equal(t, "REGISTRY_STORAGE_MAINTENANCE_UPLOADPURGING={enabled: false}", f.docker.lastCreateConfig.Env[2])
+	assert.Equal(t, []mount.Mount{
+		{Type: mount.TypeVolume, Source: "3f2a9c", Target: "/var/lib/registry"},
+	}, f.docker.lastCreateHostConfig.Mounts)
+
+	// Removing the config re-creates the registry with the defaults.
+	registry, err = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, defaultUploadPurge(), registry.Status.UploadPurge)
+	assert.Equal(t, "REGISTRY_STORAGE_MAINTENANCE_UPLOADPURGING={enabled: true, age: 168h, interval: 24h, dryrun: false}",
+		f.docker.lastCreateConfig.Env[2])
+	assert.Equal(t, registryLabels(), f.docker.lastCreateConfig.Labels)
+}
+
+func TestApplyUploadPurgeDefaultsKeepExistingRegistry(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	// A registry that purges with the defaults matches the same config,
+	// written out.
+	f.docker.containers = []types.Container{kindRegistry()}
+
+	enabled := true
+	_, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta:    typeMeta,
+		Name:        "kind-registry",
+		UploadPurge: &api.RegistryUploadPurgeSpec{Enabled: &enabled, Age: "168h"},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, f.docker.lastCreateConfig)
+}
+
+func TestApplyUploadPurgeRecreatesUnlabeledRegistry(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	// Registries from older versions of ctlptl have no upload purge labels,
+	// and may not have the upload purge config.
+	existing := kindRegistry()
+	existing.Labels = map[string]string{"dev.tilt.ctlptl.role": "registry"}
+	f.docker.containers = []types.Container{existing}
+	f.docker.onCreate = func() {
+		registry := kindRegistry()
+		registry.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{registry}
+	}
+	f.docker.mounts = map[string][]types.MountPoint{
+		existing.ID: {{Type: mount.TypeVolume, Name: "3f2a9c", Destination: "/var/lib/registry"}},
+	}
+
+	registry, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, defaultUploadPurge(), registry.Status.UploadPurge)
+	assert.Equal(t, "REGISTRY_STORAGE_MAINTENANCE_UPLOADPURGING={enabled: true, age: 168h, interval: 24h, dryrun: false}",
+		f.docker.lastCreateConfig.Env[2])
+	assert.Equal(t, []mount.Mount{
+		{Type: mount.TypeVolume, Source: "3f2a9c", Target: "/var/lib/registry"},
+	}, f.docker.lastCreateHostConfig.Mounts)
+}
+
+func TestValidateUploadPurge(t *testing.T) {
+	assert.NoError(t, validateUploadPurge(nil))
+	assert.NoError(t, validateUploadPurge(&api.RegistryUploadPurgeSpec{Age: "1m", Interval: "24h"}))
+
+	err := validateUploadPurge(&api.RegistryUploadPurgeSpec{Age: "a week"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "uploadPurge.age must be a duration, like 24h. Actual: a week")
+	}
+
+	err = validateUploadPurge(&api.RegistryUploadPurgeSpec{Interval: "30s"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "uploadPurge.interval must be at least 1m0s. Actual: 30s")
+	}
+}
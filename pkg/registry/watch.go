@@ -0,0 +1,173 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// How often to check the registry for changes, by default.
+const defaultWatchInterval = 500 * time.Millisecond
+
+type WatchEventType string
+
+const (
+	WatchEventPushed  WatchEventType = "PUSHED"
+	WatchEventDeleted WatchEventType = "DELETED"
+)
+
+// WatchEvent describes a tag that was pushed to, or deleted from, a registry.
+type WatchEvent struct {
+	Type       WatchEventType
+	Repository string
+	Tag        string
+
+	// The digest of the manifest that the tag points to. Empty for deleted tags.
+	Digest string
+}
+
+func (e WatchEvent) String() string {
+	if e.Type == WatchEventDeleted {
+		return fmt.Sprintf("[%s] %s:%s", e.Type, e.Repository, e.Tag)
+	}
+	return fmt.Sprintf("[%s] %s:%s @ %s", e.Type, e.Repository, e.Tag, e.Digest)
+}
+
+type WatchOptions struct {
+	// How often to check the registry. Defaults to 500ms.
+	Interval time.Duration
+
+	// Credentials for a registry with auth. If empty, uses Docker's
+	// credentials for the registry.
+	Username string
+	Password string
+}
+
+// Watch reports each tag that's pushed to or deleted from a local registry,
+// until the context is canceled.
+//
+// The registry's notifications can only be pointed at a listener when the
+// container is created, so instead we poll the catalog and diff the tags.
+// Re-pushing a tag with a new image counts as a push.
+//
+// Tags that exist when the watch starts aren't reported.
+func (c *Controller) Watch(ctx context.Context, name string, options WatchOptions, onEvent func(WatchEvent)) error {
+	registry, err := c.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	if registry.Status.State != containerStateRunning {
+		return fmt.Errorf("registry %s is not running", name)
+	}
+	client, err := c.hostClient(registry, options.Username, options.Password)
+	if err != nil {
+		return err
+	}
+	return c.watchClient(ctx, client, options, onEvent)
+}
+
+func (c *Controller) watchClient(ctx context.Context, client *Client, options WatchOptions, onEvent func(WatchEvent)) error {
+	interval := options.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	last, err := tagSnapshot(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		next, err := tagSnapshot(ctx, client)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			// The registry may be restarting. Keep trying, but only warn once.
+			if !failing {
+				_, _ = fmt.Fprintf(c.iostreams.ErrOut, "Warning: reading registry: %v\n", err)
+				failing = true
+			}
+			continue
+		}
+		failing = false
+
+		for _, event := range diffTagSnapshots(last, next) {
+			onEvent(event)
+		}
+		last = next
+	}
+}
+
+// Maps each repository:tag in the registry to its manifest digest.
+func tagSnapshot(ctx context.Context, client *Client) (map[string]string, error) {
+	repos, err := client.Catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for _, repo := range repos {
+		tags, err := client.Tags(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			digest, err := client.ManifestDigest(ctx, repo, tag)
+			if err != nil {
+				return nil, err
+			}
+			if digest == "" {
+				// Deleted since we listed the tags.
+				continue
+			}
+			result[repo+":"+tag] = digest
+		}
+	}
+	return result, nil
+}
+
+// Returns the events that turn the old snapshot into the new one,
+// sorted by repository:tag.
+func diffTagSnapshots(before, after map[string]string) []WatchEvent {
+	result := []WatchEvent{}
+	for ref, digest := range after {
+		if before[ref] == digest {
+			continue
+		}
+		repo, tag := splitRef(ref)
+		result = append(result, WatchEvent{Type: WatchEventPushed, Repository: repo, Tag: tag, Digest: digest})
+	}
+	for ref := range before {
+		if _, ok := after[ref]; ok {
+			continue
+		}
+		repo, tag := splitRef(ref)
+		result = append(result, WatchEvent{Type: WatchEventDeleted, Repository: repo, Tag: tag})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Repository != result[j].Repository {
+			return result[i].Repository < result[j].Repository
+		}
+		return result[i].Tag < result[j].Tag
+	})
+	return result
+}
+
+// Splits repository:tag. Neither can contain a colon.
+func splitRef(ref string) (string, string) {
+	i := strings.LastIndex(ref, ":")
+	return ref[:i], ref[i+1:]
+}
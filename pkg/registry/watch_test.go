@@ -0,0 +1,165 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A registry that serves the catalog, tags, and manifest digests of a
+// mutable set of tags.
+type fakeTagRegistry struct {
+	mu       sync.Mutex
+	refs     map[string]string
+	catalogs int
+}
+
+func (r *fakeTagRegistry) catalogCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.catalogs
+}
+
+func (r *fakeTagRegistry) set(ref, digest string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if digest == "" {
+		delete(r.refs, ref)
+		return
+	}
+	r.refs[ref] = digest
+}
+
+func (r *fakeTagRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case path == "_catalog":
+		r.catalogs++
+		repos := []string{}
+		seen := map[string]bool{}
+		for ref := range r.refs {
+			repo, _ := splitRef(ref)
+			if !seen[repo] {
+				seen[repo] = true
+				repos = append(repos, repo)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"repositories": repos})
+	case strings.HasSuffix(path, "/tags/list"):
+		repo := strings.TrimSuffix(path, "/tags/list")
+		tags := []string{}
+		for ref := range r.refs {
+			refRepo, tag := splitRef(ref)
+			if refRepo == repo {
+				tags = append(tags, tag)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": repo, "tags": tags})
+	case strings.Contains(path, "/manifests/"):
+		parts := strings.SplitN(path, "/manifests/", 2)
+		digest, ok := r.refs[parts[0]+":"+parts[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	fake := &fakeTagRegistry{refs: map[string]string{"app:v1": "sha256:111"}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "", "")
+	require.NoError(t, err)
+
+	f := newFixture(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan WatchEvent, 10)
+	done := make(chan error)
+	go func() {
+		done <- f.c.watchClient(ctx, client, WatchOptions{Interval: 10 * time.Millisecond}, func(e WatchEvent) {
+			events <- e
+		})
+	}()
+
+	next := func() string {
+		select {
+		case e := <-events:
+			return e.String()
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for watch event")
+			return ""
+		}
+	}
+
+	// Wait until the first snapshot is done, and the watch has started polling.
+	require.Eventually(t, func() bool { return fake.catalogCount() >= 2 }, 5*time.Second, 5*time.Millisecond)
+
+	fake.set("app:v2", "sha256:222")
+	assert.Equal(t, "[PUSHED] app:v2 @ sha256:222", next())
+
+	fake.set("app:v1", "sha256:333")
+	assert.Equal(t, "[PUSHED] app:v1 @ sha256:333", next())
+
+	fake.set("app:v2", "")
+	assert.Equal(t, "[DELETED] app:v2", next())
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestWatchAuth(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	server := newFakeRegistryServer(t)
+	server.seed()
+	server.username = "me"
+	server.password = "secret"
+
+	reg := authRegistry(t, "registry:2")
+	reg.Ports[0].PublicPort = fakeServerPort(t, server.URL)
+	f.docker.containers = []types.Container{reg}
+	f.c.dockerConfigDir = t.TempDir()
+
+	err := f.c.Watch(context.Background(), "kind-registry", WatchOptions{}, func(e WatchEvent) {})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no Docker credentials for user me of registry kind-registry")
+	}
+
+	// The first snapshot needs the credentials. After that, the watch runs until it's canceled.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	options := WatchOptions{Interval: 10 * time.Millisecond, Username: "me", Password: "secret"}
+	err = f.c.Watch(ctx, "kind-registry", options, func(e WatchEvent) {})
+	assert.NoError(t, err)
+}
+
+func TestDiffTagSnapshots(t *testing.T) {
+	events := diffTagSnapshots(
+		map[string]string{"b:latest": "sha256:1", "a:v1": "sha256:2", "a:v2": "sha256:3"},
+		map[string]string{"b:latest": "sha256:4", "a:v1": "sha256:2", "c:dev": "sha256:5"})
+	assert.Equal(t, []WatchEvent{
+		{Type: WatchEventDeleted, Repository: "a", Tag: "v2"},
+		{Type: WatchEventPushed, Repository: "b", Tag: "latest", Digest: "sha256:4"},
+		{Type: WatchEventPushed, Repository: "c", Tag: "dev", Digest: "sha256:5"},
+	}, events)
+}
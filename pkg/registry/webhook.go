@@ -0,0 +1,316 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mitchellh/go-homedir"
+	"gopkg.in/yaml.v3"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Labels that record the webhook on the registry container, so that we
+// can report it without inspecting the container.
+//
+// The headers may hold credentials, so we only record a hash of them.
+const (
+	webhookURLLabel     = "dev.tilt.ctlptl.registry-webhook-url"
+	webhookEventsLabel  = "dev.tilt.ctlptl.registry-webhook-events"
+	webhookHeadersLabel = "dev.tilt.ctlptl.registry-webhook-headers-sha256"
+)
+
+var webhookLabels = []string{webhookURLLabel, webhookEventsLabel, webhookHeadersLabel}
+
+// The actions that the registry sends events for.
+var webhookEvents = []string{"push", "pull", "mount", "delete"}
+
+// Where the registry config with the webhook is mounted in the container.
+const webhookConfigPath = "/etc/docker/registry/ctlptl-config.yml"
+
+// The name of the endpoint in the registry's notifications config.
+const webhookEndpointName = "ctlptl-webhook"
+
+func validateWebhook(desired *api.Registry) error {
+	webhook := desired.Webhook
+	if webhook == nil {
+		return nil
+	}
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook.url must be an http or https URL. Actual: %q", webhook.URL)
+	}
+	for _, event := range webhook.Events {
+		if !containsString(webhookEvents, event) {
+			return fmt.Errorf("webhook.events must be some of: %s. Actual: %s", strings.Join(webhookEvents, ", "), event)
+		}
+	}
+	for name, value := range webhook.Headers {
+		if name == "" || strings.ContainsAny(name, ": \r\n") {
+			return fmt.Errorf("invalid webhook.headers name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("webhook.headers value for %s must not contain newlines", name)
+		}
+	}
+	if replicaCount(desired) > 1 {
+		return fmt.Errorf("webhook isn't supported with replicaCount greater than 1")
+	}
+	return nil
+}
+
+// The events to send, sorted. Defaults to push.
+func webhookEventsOrDefault(webhook *api.RegistryWebhookSpec) []string {
+	events := append([]string{}, webhook.Events...)
+	if len(events) == 0 {
+		events = []string{"push"}
+	}
+	sort.Strings(events)
+	return events
+}
+
+// Warns about webhook URLs that the registry container can't reach.
+func webhookWarning(webhook *api.RegistryWebhookSpec) string {
+	if webhook == nil {
+		return ""
+	}
+	u, err := url.Parse(webhook.URL)
+	if err != nil {
+		return ""
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return fmt.Sprintf("webhook.url %s points at the registry container itself. "+
+			"To reach a server on the host, use host.docker.internal", webhook.URL)
+	}
+	return ""
+}
+
+// The registry config, with the webhook as its only notifications endpoint.
+//
+// The rest matches the config in the default registry image, so that the
+// REGISTRY_* environment variables that ctlptl sets still apply.
+func webhookConfig(webhook *api.RegistryWebhookSpec) ([]byte, error) {
+	headers := make(map[string][]string, len(webhook.Headers))
+	for name, value := range webhook.Headers {
+		headers[name] = []string{value}
+	}
+	ignored := []string{}
+	events := webhookEventsOrDefault(webhook)
+	for _, event := range webhookEvents {
+		if !containsString(events, event) {
+			ignored = append(ignored, event)
+		}
+	}
+	endpoint := map[string]interface{}{
+		"name":      webhookEndpointName,
+		"url":       webhook.URL,
+		"timeout":   "5s",
+		"threshold": 5,
+		"backoff":   "1s",
+	}
+	if len(headers) > 0 {
+		endpoint["headers"] = headers
+	}
+	if len(ignored) > 0 {
+		endpoint["ignore"] = map[string]interface{}{"actions": ignored}
+	}
+
+	config := map[string]interface{}{
+		"version": "0.1",
+		"log": map[string]interface{}{
+			"fields": map[string]string{"service": "registry"},
+		},
+		"storage": map[string]interface{}{
+			"cache":      map[string]string{"blobdescriptor": "inmemory"},
+			"filesystem": map[string]string{"rootdirectory": registryStoragePath},
+		},
+		"http": map[string]interface{}{
+			"addr":    ":5000",
+			"headers": map[string][]string{"X-Content-Type-Options": {"nosniff"}},
+		},
+		"health": map[string]interface{}{
+			"storagedriver": map[string]interface{}{"enabled": true, "interval": "10s", "threshold": 3},
+		},
+		"notifications": map[string]interface{}{
+			"events":    map[string]bool{"includereferences": true},
+			"endpoints": []interface{}{endpoint},
+		},
+	}
+	return yaml.Marshal(config)
+}
+
+// The directory where the registry configs with webhooks are stored.
+//
+// Returns an empty string if there's no home directory, which disables
+// webhooks.
+func defaultWebhookConfigDir() string {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, ".ctlptl", "registries")
+}
+
+func webhookConfigFile(dir, registryName string) string {
+	return filepath.Join(dir, registryName+"-webhook.yml")
+}
+
+// Writes the registry config with the webhook, and returns its path.
+//
+// The headers may hold credentials, so only the user can read the file.
+// We don't put the config in the container's environment, where anyone
+// who can run `docker inspect` could read it.
+func (c *Controller) writeWebhookConfig(registryName string, webhook *api.RegistryWebhookSpec) (string, error) {
+	if c.webhookConfigDir == "" {
+		return "", fmt.Errorf("writing webhook config of registry %s: no home directory", registryName)
+	}
+	config, err := webhookConfig(webhook)
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(c.webhookConfigDir, 0700)
+	if err != nil {
+		return "", fmt.Errorf("writing webhook config of registry %s: %v", registryName, err)
+	}
+
+	// Writes in place, rather than renaming over the file, so that an existing
+	// container that bind-mounts it keeps seeing the same file.
+	path := webhookConfigFile(c.webhookConfigDir, registryName)
+	err = os.WriteFile(path, config, 0600)
+	if err == nil {
+		// WriteFile only sets the mode of new files.
+		err = os.Chmod(path, 0600)
+	}
+	if err != nil {
+		return "", fmt.Errorf("writing webhook config of registry %s: %v", registryName, err)
+	}
+	return path, nil
+}
+
+// Deletes the registry config with the webhook, if there is one.
+func (c *Controller) deleteWebhookConfig(registryName string) error {
+	if c.webhookConfigDir == "" {
+		return nil
+	}
+	err := os.Remove(webhookConfigFile(c.webhookConfigDir, registryName))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("deleting webhook config of registry %s: %v", registryName, err)
+	}
+	return nil
+}
+
+// Mounts the config file and points the registry at it, by wrapping the
+// entrypoint in a shell. Composes with the log file and auth wrappers.
+//
+// Lets the registry reach servers on the host as host.docker.internal,
+// which Docker on Linux doesn't do by default.
+func addWebhook(config *container.Config, hostConfig *container.HostConfig, configFile string) {
+	if configFile == "" {
+		return
+	}
+	if len(config.Entrypoint) == 0 {
+		config.Entrypoint = []string{"/bin/sh", "-c"}
+		config.Cmd = []string{"exec " + registryEntrypoint}
+	}
+	config.Cmd = []string{strings.Replace(config.Cmd[0], registryEntrypoint, "/entrypoint.sh "+webhookConfigPath, 1)}
+	hostConfig.Binds = append(hostConfig.Binds, fmt.Sprintf("%s:%s:ro", configFile, webhookConfigPath))
+	hostConfig.ExtraHosts = append(hostConfig.ExtraHosts, "host.docker.internal:host-gateway")
+}
+
+func webhookHeadersHash(headers map[string]string) string {
+	if len(headers) == 0 {
+		return ""
+	}
+	// JSON sorts the keys, so the hash doesn't depend on map order.
+	data, _ := json.Marshal(headers)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Reads the webhook headers from the registry's config file, since the
+// labels only have their hash.
+func (c *Controller) webhookHeaders(existing *api.Registry) (map[string]string, error) {
+	if c.webhookConfigDir == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(webhookConfigFile(c.webhookConfigDir, existing.Name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading webhook of registry %s: %v", existing.Name, err)
+	}
+	var config struct {
+		Notifications struct {
+			Endpoints []struct {
+				Name    string              `yaml:"name"`
+				Headers map[string][]string `yaml:"headers"`
+			} `yaml:"endpoints"`
+		} `yaml:"notifications"`
+	}
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return nil, fmt.Errorf("reading webhook of registry %s: %v", existing.Name, err)
+	}
+	for _, endpoint := range config.Notifications.Endpoints {
+		if endpoint.Name != webhookEndpointName || len(endpoint.Headers) == 0 {
+			continue
+		}
+		headers := make(map[string]string, len(endpoint.Headers))
+		for name, values := range endpoint.Headers {
+			headers[name] = strings.Join(values, ",")
+		}
+		return headers, nil
+	}
+	return nil, nil
+}
+
+// Replaces the webhook labels with the ones for the desired webhook.
+func setWebhookLabels(labels map[string]string, webhook *api.RegistryWebhookSpec) {
+	for _, label := range webhookLabels {
+		delete(labels, label)
+	}
+	if webhook == nil {
+		return
+	}
+	labels[webhookURLLabel] = webhook.URL
+	labels[webhookEventsLabel] = strings.Join(webhookEventsOrDefault(webhook), ",")
+	if hash := webhookHeadersHash(webhook.Headers); hash != "" {
+		labels[webhookHeadersLabel] = hash
+	}
+}
+
+// Reads the webhook from the registry container's labels.
+//
+// Returns nil if the registry has no webhook.
+func webhookFromLabels(labels map[string]string) *api.RegistryWebhookSpec {
+	u := labels[webhookURLLabel]
+	if u == "" {
+		return nil
+	}
+	webhook := &api.RegistryWebhookSpec{URL: u}
+	if events := labels[webhookEventsLabel]; events != "" {
+		webhook.Events = strings.Split(events, ",")
+	}
+	return webhook
+}
+
+// Checks if the existing registry sends the desired webhook.
+func webhookMatches(existingLabels map[string]string, desired *api.RegistryWebhookSpec) bool {
+	existing := webhookFromLabels(existingLabels)
+	if existing == nil || desired == nil {
+		return existing == nil && desired == nil
+	}
+	return existing.URL == desired.URL &&
+		strings.Join(existing.Events, ",") == strings.Join(webhookEventsOrDefault(desired), ",") &&
+		existingLabels[webhookHeadersLabel] == webhookHeadersHash(desired.Headers)
+}
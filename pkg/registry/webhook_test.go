@@ -0,0 +1,217 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestApplyWebhook(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	f.docker.onCreate = func() {
+		existing := kindRegistry()
+		existing.Labels = f.docker.lastCreateConfig.Labels
+		f.docker.containers = []types.Container{existing}
+	}
+
+	registry, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Port:     5001,
+		Webhook: &api.RegistryWebhookSpec{
+			URL:     "http://host.docker.internal:8080/events",
+			Events:  []string{"push", "delete"},
+			Headers: map[string]string{"Authorization": "Bearer s3cr3t"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &api.RegistryWebhookSpec{URL: "http://host.docker.internal:8080/events", Events: []string{"delete", "push"}}, registry.Status.Webhook)
+
+	config := f.docker.lastCreateConfig
+	assert.Equal(t, []string{"/bin/sh", "-c"}, []string(config.Entrypoint))
+	assert.Equal(t, "exec /entrypoint.sh "+webhookConfigPath, config.Cmd[0])
+	assert.NotContains(t, config.Labels[webhookHeadersLabel], "s3cr3t")
+	assert.Contains(t, f.docker.lastCreateHostConfig.ExtraHosts, "host.docker.internal:host-gateway")
+	for _, env := range config.Env {
+		assert.NotContains(t, env, "s3cr3t")
+	}
+
+	// The config is only readable by the user, and mounted into the container.
+	path := webhookConfigFile(f.c.webhookConfigDir, "kind-registry")
+	assert.Contains(t, f.docker.lastCreateHostConfig.Binds, path+":"+webhookConfigPath+":ro")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The registry reads its notifications from the config.
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	parsed, err := configuration.Parse(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, parsed.Notifications.Endpoints, 1)
+	endpoint := parsed.Notifications.Endpoints[0]
+	assert.Equal(t, "http://host.docker.internal:8080/events", endpoint.URL)
+	assert.Equal(t, http.Header{"Authorization": {"Bearer s3cr3t"}}, endpoint.Headers)
+	assert.Equal(t, []string{"pull", "mount"}, endpoint.Ignore.Actions)
+	assert.False(t, endpoint.Disabled)
+	assert.Equal(t, registryStoragePath, parsed.Storage.Parameters()["rootdirectory"])
+}
+
+func TestApplyWebhookUnchanged(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	webhook := &api.RegistryWebhookSpec{URL: "http://host.docker.internal:8080/events"}
+	existing := kindRegistry()
+	setWebhookLabels(existing.Labels, webhook)
+	f.docker.containers = []types.Container{existing}
+
+	_, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Port:     5001,
+		Webhook:  &api.RegistryWebhookSpec{URL: "http://host.docker.internal:8080/events", Events: []string{"push"}},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, f.docker.lastCreateConfig, "Registry should not have been re-created")
+}
+
+func TestApplyWebhookChangeKeepsVolume(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	existing := kindRegistry()
+	setWebhookLabels(existing.Labels, &api.RegistryWebhookSpec{
+		URL:     "http://host.docker.internal:8080/events",
+		Headers: map[string]string{"Authorization": "Bearer old"},
+	})
+	f.docker.containers = []types.Container{existing}
+	f.docker.mounts = map[string][]types.MountPoint{
+		existing.ID: {{Type: mount.TypeVolume, Name: "3f2a9c", Destination: "/var/lib/registry"}},
+	}
+
+	// Only the headers changed.
+	_, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Port:     5001,
+		Webhook: &api.RegistryWebhookSpec{
+			URL:     "http://host.docker.internal:8080/events",
+			Headers: map[string]string{"Authorization": "Bearer new"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, f.docker.lastRemovedContainer)
+	assert.Equal(t, []mount.Mount{
+		{Type: mount.TypeVolume, Source: "3f2a9c", Target: "/var/lib/registry"},
+	}, f.docker.lastCreateHostConfig.Mounts)
+	data, err := os.ReadFile(webhookConfigFile(f.c.webhookConfigDir, "kind-registry"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Bearer new")
+}
+
+func TestApplyWebhookRemoved(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	existing := kindRegistry()
+	setWebhookLabels(existing.Labels, &api.RegistryWebhookSpec{URL: "http://host.docker.internal:8080/events"})
+	f.docker.containers = []types.Container{existing}
+	f.docker.mounts = map[string][]types.MountPoint{
+		existing.ID: {{Type: mount.TypeVolume, Name: "3f2a9c", Destination: "/var/lib/registry"}},
+	}
+
+	path := webhookConfigFile(f.c.webhookConfigDir, "kind-registry")
+	require.NoError(t, os.MkdirAll(f.c.webhookConfigDir, 0700))
+	require.NoError(t, os.WriteFile(path, []byte("version: 0.1\n"), 0600))
+
+	_, err := f.c.Apply(context.Background(), &api.Registry{TypeMeta: typeMeta, Name: "kind-registry", Port: 5001})
+	require.NoError(t, err)
+	config := f.docker.lastCreateConfig
+	assert.NoFileExists(t, path)
+	assert.Empty(t, f.docker.lastCreateHostConfig.Binds)
+	assert.NotContains(t, config.Labels, webhookURLLabel)
+	assert.Len(t, f.docker.lastCreateHostConfig.Mounts, 1)
+}
+
+func TestApplyWebhookLocalhostWarning(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+	streams, _, _, errOut := genericclioptions.NewTestIOStreams()
+	f.c.iostreams = streams
+	f.docker.onCreate = func() {
+		f.docker.containers = []types.Container{kindRegistry()}
+	}
+
+	_, err := f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Webhook:  &api.RegistryWebhookSpec{URL: "http://localhost:8080/events"},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, errOut.String(), "Warning: webhook.url http://localhost:8080/events points at the registry container itself")
+}
+
+func TestValidateWebhook(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		desired *api.Registry
+		err     string
+	}{
+		{"no webhook", &api.Registry{}, ""},
+		{"valid", &api.Registry{Webhook: &api.RegistryWebhookSpec{URL: "https://example.com/hook", Events: []string{"pull"}}}, ""},
+		{"no scheme", &api.Registry{Webhook: &api.RegistryWebhookSpec{URL: "example.com/hook"}},
+			`webhook.url must be an http or https URL. Actual: "example.com/hook"`},
+		{"unknown event", &api.Registry{Webhook: &api.RegistryWebhookSpec{URL: "http://example.com", Events: []string{"tag"}}},
+			"webhook.events must be some of: push, pull, mount, delete. Actual: tag"},
+		{"bad header", &api.Registry{Webhook: &api.RegistryWebhookSpec{URL: "http://example.com", Headers: map[string]string{"X Token": "a"}}},
+			`invalid webhook.headers name "X Token"`},
+		{"replicas", &api.Registry{ReplicaCount: 2, Webhook: &api.RegistryWebhookSpec{URL: "http://example.com"}},
+			"webhook isn't supported with replicaCount greater than 1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateWebhook(tc.desired)
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equal(t, tc.err, err.Error())
+			}
+		})
+	}
+}
+
+func TestWebhookComposesWithAuth(t *testing.T) {
+	f := newFixture(t)
+	defer f.TearDown()
+
+	calls := []string{}
+	f.c.runner = fakeAuthRunner(&calls, "")
+	f.docker.onCreate = func() {
+		f.docker.containers = []types.Container{authRegistry(t, "registry:2")}
+	}
+
+	_, _ = f.c.Apply(context.Background(), &api.Registry{
+		TypeMeta: typeMeta,
+		Name:     "kind-registry",
+		Auth:     &api.RegistryAuthSpec{Username: "me", Password: "pw"},
+		Webhook:  &api.RegistryWebhookSpec{URL: "http://host.docker.internal:8080/events"},
+	})
+	require.NotNil(t, f.docker.lastCreateConfig)
+	cmd := f.docker.lastCreateConfig.Cmd[0]
+	assert.Contains(t, cmd, htpasswdPath)
+	assert.True(t, strings.HasSuffix(cmd, "exec /entrypoint.sh "+webhookConfigPath), cmd)
+}
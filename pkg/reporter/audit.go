@@ -0,0 +1,51 @@
+package reporter
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/tilt-dev/ctlptl/internal/audit"
+)
+
+// AuditReporter records each applied object in the audit log, then
+// passes it on to another reporter.
+//
+// Dry runs don't change anything, so they aren't recorded.
+type AuditReporter struct {
+	inner     Reporter
+	operation string
+
+	// Failed can't return an error, so Done returns it.
+	err error
+}
+
+func NewAuditReporter(inner Reporter, operation string) *AuditReporter {
+	return &AuditReporter{inner: inner, operation: operation}
+}
+
+func (r *AuditReporter) Applied(obj runtime.Object, result Result) error {
+	if result.Action != ActionDryRun {
+		err := audit.Record(r.operation, result.Kind, result.Name, string(result.Action), nil)
+		if err != nil {
+			return err
+		}
+	}
+	return r.inner.Applied(obj, result)
+}
+
+func (r *AuditReporter) Failed(kind, name string, err error, duration time.Duration) {
+	auditErr := audit.Record(r.operation, kind, name, "", err)
+	if auditErr != nil && r.err == nil {
+		r.err = auditErr
+	}
+	r.inner.Failed(kind, name, err, duration)
+}
+
+func (r *AuditReporter) Done() error {
+	err := r.inner.Done()
+	if err != nil {
+		return err
+	}
+	return r.err
+}
@@ -0,0 +1,43 @@
+package reporter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/ctlptl/internal/audit"
+	myprinters "github.com/tilt-dev/ctlptl/internal/printers"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestAuditReporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	t.Setenv(audit.EnvVar, path)
+
+	out := bytes.NewBuffer(nil)
+	r := NewAuditReporter(NewConsoleReporter(&myprinters.NamePrinter{Operation: "created"}, out), "apply")
+
+	cluster := &api.Cluster{
+		TypeMeta: api.TypeMeta{Kind: "Cluster", APIVersion: "ctlptl.dev/v1alpha1"},
+		Name:     "kind-kind",
+	}
+	require.NoError(t, r.Applied(cluster, Result{Kind: "Cluster", Name: "kind-kind", Action: ActionCreated}))
+	require.NoError(t, r.Applied(cluster, Result{Kind: "Cluster", Name: "kind-kind", Action: ActionDryRun}))
+	r.Failed("Registry", "ctlptl-registry", fmt.Errorf("port taken"), time.Second)
+	require.NoError(t, r.Done())
+
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"operation":"apply","kind":"Cluster","name":"kind-kind","action":"created"`)
+	assert.Contains(t, lines[1], `"name":"ctlptl-registry","action":"failed","error":"port taken"`)
+	assert.Equal(t, "cluster.ctlptl.dev/kind-kind created\ncluster.ctlptl.dev/kind-kind created\n", out.String())
+}
@@ -0,0 +1,138 @@
+package reporter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Set to "true" when running in GitHub Actions.
+const GitHubActionsEnv = "GITHUB_ACTIONS"
+
+// The path of the file that GitHub renders as Markdown on the job summary page.
+const GitHubStepSummaryEnv = "GITHUB_STEP_SUMMARY"
+
+type gitHubRow struct {
+	result Result
+	err    error
+}
+
+// GitHubReporter prints to the console, and also appends a table of
+// everything it applied to the GitHub Actions step summary.
+//
+// Failures are reported as ::error:: annotations, and success as a
+// ::notice:: annotation, so that they show up on the workflow run.
+// The runner reads workflow commands from stderr too, so we write them
+// there, and keep -o yaml and -o json output on stdout parseable.
+//
+// https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions
+type GitHubReporter struct {
+	console     Reporter
+	errOut      io.Writer
+	summaryPath string
+	start       time.Time
+	rows        []gitHubRow
+}
+
+func NewGitHubReporter(console Reporter, errOut io.Writer, summaryPath string) *GitHubReporter {
+	return &GitHubReporter{
+		console:     console,
+		errOut:      errOut,
+		summaryPath: summaryPath,
+		start:       time.Now(),
+	}
+}
+
+func (r *GitHubReporter) Applied(obj runtime.Object, result Result) error {
+	r.rows = append(r.rows, gitHubRow{result: result})
+	return r.console.Applied(obj, result)
+}
+
+func (r *GitHubReporter) Failed(kind, name string, err error, duration time.Duration) {
+	r.rows = append(r.rows, gitHubRow{
+		result: Result{Kind: kind, Name: name, Duration: duration},
+		err:    err,
+	})
+	r.console.Failed(kind, name, err, duration)
+	_, _ = fmt.Fprintf(r.errOut, "::error title=ctlptl apply %s %s::%s\n",
+		escapeProperty(strings.ToLower(kind)), escapeProperty(name), escapeData(err.Error()))
+}
+
+func (r *GitHubReporter) Done() error {
+	elapsed := time.Since(r.start)
+	failed := false
+	for _, row := range r.rows {
+		if row.err != nil {
+			failed = true
+		}
+	}
+	if !failed {
+		_, _ = fmt.Fprintf(r.errOut, "::notice title=ctlptl apply::%s\n",
+			escapeData(fmt.Sprintf("Applied %d objects in %s", len(r.rows), formatDuration(elapsed))))
+	}
+
+	f, err := os.OpenFile(r.summaryPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("writing GitHub step summary: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	_, err = f.Write(r.summary(elapsed))
+	if err != nil {
+		return fmt.Errorf("writing GitHub step summary: %v", err)
+	}
+	return r.console.Done()
+}
+
+// Renders the step summary as Markdown.
+func (r *GitHubReporter) summary(elapsed time.Duration) []byte {
+	buf := bytes.NewBuffer(nil)
+	_, _ = fmt.Fprintf(buf, "### ctlptl apply\n\n")
+	if len(r.rows) == 0 {
+		_, _ = fmt.Fprintf(buf, "Nothing to apply.\n\n")
+		return buf.Bytes()
+	}
+
+	_, _ = fmt.Fprintf(buf, "| Kind | Name | Status | Time |\n")
+	_, _ = fmt.Fprintf(buf, "| --- | --- | --- | --- |\n")
+	for _, row := range r.rows {
+		status := string(row.result.Action)
+		if row.err != nil {
+			status = "❌ failed: " + row.err.Error()
+		}
+		_, _ = fmt.Fprintf(buf, "| %s | %s | %s | %s |\n",
+			escapeCell(row.result.Kind), escapeCell(row.result.Name), escapeCell(status),
+			formatDuration(row.result.Duration))
+	}
+	_, _ = fmt.Fprintf(buf, "\nTotal time: %s\n\n", formatDuration(elapsed))
+	return buf.Bytes()
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// Keeps a value from breaking out of its Markdown table cell.
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// Escapes the message of a workflow command.
+func escapeData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+// Escapes a property of a workflow command, like its title.
+func escapeProperty(s string) string {
+	s = escapeData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
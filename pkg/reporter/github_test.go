@@ -0,0 +1,117 @@
+package reporter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/printers"
+	"sigs.k8s.io/yaml"
+
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+// Prints nothing, so that tests only see what the reporter adds.
+type nopPrinter struct{}
+
+func (nopPrinter) PrintObj(obj runtime.Object, w io.Writer) error { return nil }
+
+// Returns the reporter and its stderr.
+func newTestGitHubReporter(t *testing.T) (*GitHubReporter, *bytes.Buffer, string) {
+	errOut := bytes.NewBuffer(nil)
+	summaryPath := filepath.Join(t.TempDir(), "summary.md")
+	console := NewConsoleReporter(nopPrinter{}, io.Discard)
+	return NewGitHubReporter(console, errOut, summaryPath), errOut, summaryPath
+}
+
+func TestGitHubReporterSummary(t *testing.T) {
+	r, out, summaryPath := newTestGitHubReporter(t)
+	require.NoError(t, r.Applied(&api.Registry{Name: "ctlptl-registry"}, Result{
+		Kind: "Registry", Name: "ctlptl-registry", Action: ActionUnchanged, Duration: 120 * time.Millisecond,
+	}))
+	require.NoError(t, r.Applied(&api.Cluster{Name: "kind-kind"}, Result{
+		Kind: "Cluster", Name: "kind-kind", Action: ActionCreated, Duration: 42 * time.Second,
+	}))
+	require.NoError(t, r.Done())
+
+	summary, err := os.ReadFile(summaryPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(summary), `### ctlptl apply
+
+| Kind | Name | Status | Time |
+| --- | --- | --- | --- |
+| Registry | ctlptl-registry | unchanged | 100ms |
+| Cluster | kind-kind | created | 42s |
+`)
+	assert.Contains(t, out.String(), "::notice title=ctlptl apply::Applied 2 objects in ")
+	assert.NotContains(t, out.String(), "::error")
+}
+
+func TestGitHubReporterAppendsToSummary(t *testing.T) {
+	r, _, summaryPath := newTestGitHubReporter(t)
+	require.NoError(t, os.WriteFile(summaryPath, []byte("# Earlier step\n\n"), 0644))
+	require.NoError(t, r.Done())
+
+	summary, err := os.ReadFile(summaryPath)
+	require.NoError(t, err)
+	assert.Equal(t, "# Earlier step\n\n### ctlptl apply\n\nNothing to apply.\n\n", string(summary))
+}
+
+func TestGitHubReporterFailed(t *testing.T) {
+	r, out, summaryPath := newTestGitHubReporter(t)
+	r.Failed("Cluster", "kind-kind", fmt.Errorf("creating cluster: exit status 1\ndocker is not running"), time.Second)
+	require.NoError(t, r.Done())
+
+	assert.Equal(t,
+		"::error title=ctlptl apply cluster kind-kind::creating cluster: exit status 1%0Adocker is not running\n",
+		out.String())
+
+	summary, err := os.ReadFile(summaryPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(summary),
+		"| Cluster | kind-kind | ❌ failed: creating cluster: exit status 1 docker is not running | 1s |\n")
+}
+
+func TestGitHubReporterKeepsOutputParseable(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	errOut := bytes.NewBuffer(nil)
+	console := NewConsoleReporter(&printers.YAMLPrinter{}, out)
+	r := NewGitHubReporter(console, errOut, filepath.Join(t.TempDir(), "summary.md"))
+
+	cluster := &api.Cluster{
+		TypeMeta: api.TypeMeta{Kind: "Cluster", APIVersion: "ctlptl.dev/v1alpha1"},
+		Name:     "kind-kind",
+	}
+	require.NoError(t, r.Applied(cluster, Result{Kind: "Cluster", Name: "kind-kind", Action: ActionCreated}))
+	r.Failed("Registry", "ctlptl-registry", fmt.Errorf("port 5000 is in use"), time.Second)
+	require.NoError(t, r.Done())
+
+	// Stdout is only the printed cluster.
+	var printed api.Cluster
+	require.NoError(t, yaml.UnmarshalStrict(out.Bytes(), &printed))
+	assert.Equal(t, "kind-kind", printed.Name)
+	assert.NotContains(t, out.String(), "::")
+	assert.Contains(t, errOut.String(), "::error title=ctlptl apply registry ctlptl-registry::port 5000 is in use\n")
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(GitHubActionsEnv, "")
+	t.Setenv(GitHubStepSummaryEnv, "")
+	_, ok := FromEnv(nopPrinter{}, os.Stdout, os.Stderr).(*ConsoleReporter)
+	assert.True(t, ok)
+
+	t.Setenv(GitHubActionsEnv, "true")
+	_, ok = FromEnv(nopPrinter{}, os.Stdout, os.Stderr).(*ConsoleReporter)
+	assert.True(t, ok, "needs a step summary file")
+
+	t.Setenv(GitHubStepSummaryEnv, filepath.Join(t.TempDir(), "summary.md"))
+	_, ok = FromEnv(nopPrinter{}, os.Stdout, os.Stderr).(*GitHubReporter)
+	assert.True(t, ok)
+}
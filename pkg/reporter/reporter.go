@@ -0,0 +1,84 @@
+// Package reporter reports the progress of `ctlptl apply`.
+package reporter
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/printers"
+
+	myprinters "github.com/tilt-dev/ctlptl/internal/printers"
+)
+
+// Action describes what apply did to an object.
+type Action string
+
+const (
+	ActionCreated   Action = "created"
+	ActionUpdated   Action = "updated"
+	ActionUnchanged Action = "unchanged"
+	ActionExists    Action = "exists"
+	ActionDryRun    Action = "dry run"
+)
+
+// Result describes an object that apply finished with.
+type Result struct {
+	Kind     string
+	Name     string
+	Action   Action
+	Duration time.Duration
+}
+
+// Reporter reports what apply does to each object.
+type Reporter interface {
+	// Applied reports an object that was applied (or would be, on a dry run).
+	Applied(obj runtime.Object, result Result) error
+
+	// Failed reports an object that couldn't be applied.
+	Failed(kind, name string, err error, duration time.Duration)
+
+	// Done is called once, after everything is applied or after the first failure.
+	Done() error
+}
+
+// ConsoleReporter prints each applied object, like kubectl does.
+type ConsoleReporter struct {
+	printer printers.ResourcePrinter
+	out     io.Writer
+}
+
+func NewConsoleReporter(printer printers.ResourcePrinter, out io.Writer) *ConsoleReporter {
+	return &ConsoleReporter{printer: printer, out: out}
+}
+
+func (r *ConsoleReporter) Applied(obj runtime.Object, result Result) error {
+	// Objects that apply left alone aren't "created".
+	if p, ok := r.printer.(*myprinters.NamePrinter); ok && result.Action == ActionExists {
+		existsPrinter := *p
+		existsPrinter.Operation = string(ActionExists)
+		return existsPrinter.PrintObj(obj, r.out)
+	}
+	return r.printer.PrintObj(obj, r.out)
+}
+
+// The caller prints errors, so there's nothing to add.
+func (r *ConsoleReporter) Failed(kind, name string, err error, duration time.Duration) {}
+
+func (r *ConsoleReporter) Done() error {
+	return nil
+}
+
+// FromEnv picks a reporter for the environment that ctlptl is running in.
+//
+// In GitHub Actions, also writes a step summary and annotations.
+// Otherwise, only prints to the console.
+func FromEnv(printer printers.ResourcePrinter, out, errOut io.Writer) Reporter {
+	console := NewConsoleReporter(printer, out)
+	summaryPath := os.Getenv(GitHubStepSummaryEnv)
+	if os.Getenv(GitHubActionsEnv) == "true" && summaryPath != "" {
+		return NewGitHubReporter(console, errOut, summaryPath)
+	}
+	return console
+}
@@ -0,0 +1,25 @@
+package reporter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	myprinters "github.com/tilt-dev/ctlptl/internal/printers"
+	"github.com/tilt-dev/ctlptl/pkg/api"
+)
+
+func TestConsoleReporterExists(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	r := NewConsoleReporter(&myprinters.NamePrinter{Operation: "created"}, out)
+
+	cluster := &api.Cluster{
+		TypeMeta: api.TypeMeta{Kind: "Cluster", APIVersion: "ctlptl.dev/v1alpha1"},
+		Name:     "kind-kind",
+	}
+	require.NoError(t, r.Applied(cluster, Result{Kind: "Cluster", Name: "kind-kind", Action: ActionCreated}))
+	require.NoError(t, r.Applied(cluster, Result{Kind: "Cluster", Name: "kind-kind", Action: ActionExists}))
+	assert.Equal(t, "cluster.ctlptl.dev/kind-kind created\ncluster.ctlptl.dev/kind-kind exists\n", out.String())
+}
@@ -1,6 +1,9 @@
 package visitor
 
 import (
+	"bytes"
+	"io"
+
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 
@@ -8,9 +11,15 @@ import (
 )
 
 func DecodeAll(vs []Interface) ([]runtime.Object, error) {
+	return DecodeAllWithVars(vs, nil)
+}
+
+// DecodeAllWithVars decodes every visitor, replacing ${NAME} variables
+// with values from lookup first. A nil lookup skips interpolation.
+func DecodeAllWithVars(vs []Interface, lookup VarLookup) ([]runtime.Object, error) {
 	result := []runtime.Object{}
 	for _, v := range vs {
-		objs, err := Decode(v)
+		objs, err := decode(v, lookup)
 		if err != nil {
 			return nil, err
 		}
@@ -20,15 +29,32 @@ func DecodeAll(vs []Interface) ([]runtime.Object, error) {
 }
 
 func Decode(v Interface) ([]runtime.Object, error) {
+	return decode(v, nil)
+}
+
+func decode(v Interface, lookup VarLookup) ([]runtime.Object, error) {
 	r, err := v.Open()
 	if err != nil {
 		return nil, err
 	}
 	defer r.Close()
 
-	result, err := encoding.ParseStream(r)
+	var stream io.Reader = r
+	if lookup != nil {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, errors.Wrapf(err, "visiting %s", v.Name())
+		}
+		data, err = Interpolate(data, lookup)
+		if err != nil {
+			return nil, errors.Wrapf(err, "visiting %s", v.Name())
+		}
+		stream = bytes.NewReader(data)
+	}
+
+	result, err := encoding.ParseStream(stream)
 	if err != nil {
 		return nil, errors.Wrapf(err, "visiting %s", v.Name())
 	}
-	return result, nil
+	return encoding.FlattenLists(result), nil
 }
@@ -0,0 +1,42 @@
+package visitor
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// Looks up the value of a variable. Returns false if the variable isn't defined.
+type VarLookup func(name string) (string, bool)
+
+// Matches ${NAME}, and the escaped form $${NAME}.
+var varRe = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Interpolate replaces every ${NAME} in the data with the value of the variable.
+//
+// Write $${NAME} for a literal ${NAME}. Other uses of $ are left alone.
+func Interpolate(data []byte, lookup VarLookup) ([]byte, error) {
+	result := bytes.NewBuffer(make([]byte, 0, len(data)))
+	last := 0
+	for _, match := range varRe.FindAllSubmatchIndex(data, -1) {
+		start, end := match[0], match[1]
+		name := string(data[match[2]:match[3]])
+		result.Write(data[last:start])
+		last = end
+
+		if data[start+1] == '$' {
+			// Escaped.
+			result.Write(data[start+1 : end])
+			continue
+		}
+
+		value, ok := lookup(name)
+		if !ok {
+			line := bytes.Count(data[:start], []byte("\n")) + 1
+			return nil, fmt.Errorf("line %d: variable %s is not defined. Pass --var=%s=VALUE or set it in the environment", line, name, name)
+		}
+		result.WriteString(value)
+	}
+	result.Write(data[last:])
+	return result.Bytes(), nil
+}
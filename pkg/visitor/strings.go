@@ -1,12 +1,15 @@
 package visitor
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
 
 	"github.com/pkg/errors"
+
+	"github.com/tilt-dev/ctlptl/internal/egress"
 )
 
 func FromStrings(filenames []string, stdin io.Reader) ([]Interface, error) {
@@ -22,6 +25,10 @@ func FromStrings(filenames []string, stdin io.Reader) ([]Interface, error) {
 			if err != nil {
 				return nil, errors.Wrapf(err, "invalid URL %s", url)
 			}
+			err = egress.CheckURL(fmt.Sprintf("reading %s", f), f)
+			if err != nil {
+				return nil, err
+			}
 			result = append(result, URL(http.DefaultClient, f))
 
 		default: